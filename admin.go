@@ -0,0 +1,280 @@
+package main
+
+import (
+	"crypto/subtle"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kennygrant/coronavirus/series"
+)
+
+// Defaults for backtesting forecasts, in days
+const (
+	defaultBacktestHorizon = 7
+	defaultBacktestMinDays = 14
+)
+
+// adminAuthorized returns true if the request may use admin endpoints
+// requests must send server.admin_token as a bearer token, in development no token is required
+func adminAuthorized(r *http.Request) bool {
+	token := cfg.Server.AdminToken
+	if token == "" {
+		return development
+	}
+	auth := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(auth), []byte(token)) == 1
+}
+
+// discrepancyPercent returns the percentage difference reported as a discrepancy
+// the value given is used if valid, otherwise data.discrepancy_percent
+func discrepancyPercent(value string) float64 {
+	p, err := strconv.ParseFloat(value, 64)
+	if err == nil && p >= 0 {
+		return p
+	}
+	return cfg.Data.DiscrepancyPercent
+}
+
+// handleSnapshots serves the list of saved snapshots of the series file as json
+func handleSnapshots(w http.ResponseWriter, r *http.Request) {
+
+	log.Printf("request:%s", r.URL)
+
+	if !adminAuthorized(r) {
+		http.Error(w, "not authorized", http.StatusUnauthorized)
+		return
+	}
+
+	snapshots, err := series.Snapshots(snapshotsPath)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+
+	renderJSON(w, r, snapshots)
+}
+
+// handleSnapshotDiff serves every value changed between two snapshots as json
+// params: from=n&to=n, a version of 0 (the default) is the current series file
+func handleSnapshotDiff(w http.ResponseWriter, r *http.Request) {
+
+	log.Printf("request:%s", r.URL)
+
+	if !adminAuthorized(r) {
+		http.Error(w, "not authorized", http.StatusUnauthorized)
+		return
+	}
+
+	revisions, err := series.DiffSnapshots(snapshotsPath, intParam(r, "from"), intParam(r, "to"), seriesPath)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	renderJSON(w, r, revisions)
+}
+
+// handleRollback restores the snapshot version given and reloads data, it must be POSTed
+// params: version=n
+func handleRollback(w http.ResponseWriter, r *http.Request) {
+
+	log.Printf("request:%s", r.URL)
+
+	if !adminAuthorized(r) {
+		http.Error(w, "not authorized", http.StatusUnauthorized)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	version := intParam(r, "version")
+	err := series.RestoreSnapshot(snapshotsPath, version, seriesPath)
+	if err != nil {
+		log.Printf("rollback error:%s", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = series.LoadData(cfg.Data.Path)
+	if err != nil {
+		log.Printf("rollback reload error:%s", err)
+		http.Error(w, err.Error(), 500)
+		return
+	}
+
+	log.Printf("rollback: restored snapshot version:%d", version)
+	renderJSON(w, r, map[string]int{"version": version})
+}
+
+// handleDiscrepancies serves values on which sources disagree by more than percent as json
+// params: percent=n
+func handleDiscrepancies(w http.ResponseWriter, r *http.Request) {
+
+	log.Printf("request:%s", r.URL)
+
+	if !adminAuthorized(r) {
+		http.Error(w, "not authorized", http.StatusUnauthorized)
+		return
+	}
+
+	renderJSON(w, r, series.SourceDiscrepancies(discrepancyPercent(param(r, "percent"))))
+}
+
+// handleValidate serves the problems found in each series as json, see series.Data.Validate
+func handleValidate(w http.ResponseWriter, r *http.Request) {
+
+	log.Printf("request:%s", r.URL)
+
+	if !adminAuthorized(r) {
+		http.Error(w, "not authorized", http.StatusUnauthorized)
+		return
+	}
+
+	renderJSON(w, r, series.Validate())
+}
+
+// handleBacktest serves the accuracy of a forecast model replayed over the history of each area as json
+// params: model=growth|exponential&kind=deaths&horizon=n&min=n, areas are ordered most accurate first
+func handleBacktest(w http.ResponseWriter, r *http.Request) {
+
+	log.Printf("request:%s", r.URL)
+
+	if !adminAuthorized(r) {
+		http.Error(w, "not authorized", http.StatusUnauthorized)
+		return
+	}
+
+	name := param(r, "model")
+	if name == "" {
+		name = "growth"
+	}
+	model, ok := series.ForecastModels[name]
+	if !ok {
+		http.Error(w, "unknown model", http.StatusBadRequest)
+		return
+	}
+
+	dataKind := series.DataKindFromName(param(r, "kind"))
+	if dataKind == series.DataNone {
+		dataKind = series.DataDeaths
+	}
+
+	horizon := intParam(r, "horizon")
+	if horizon <= 0 {
+		horizon = defaultBacktestHorizon
+	}
+	minDays := intParam(r, "min")
+	if minDays <= 0 {
+		minDays = defaultBacktestMinDays
+	}
+
+	renderJSON(w, r, series.Areas().Backtest(dataKind, horizon, minDays, model))
+}
+
+// handleOverrides serves the corrections made to day values as json, or adds one if POSTed
+// params: area_id=n&date=2020-04-01&kind=deaths&value=n&reason=text
+// overrides replace values from sources after every refresh, a new override replaces any for the same day and kind
+func handleOverrides(w http.ResponseWriter, r *http.Request) {
+
+	log.Printf("request:%s", r.URL)
+
+	if !adminAuthorized(r) {
+		http.Error(w, "not authorized", http.StatusUnauthorized)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		renderJSON(w, r, series.Overrides())
+		return
+	}
+
+	date, err := time.Parse("2006-01-02", param(r, "date"))
+	if err != nil {
+		http.Error(w, "invalid date", http.StatusBadRequest)
+		return
+	}
+	value, err := strconv.Atoi(param(r, "value"))
+	if err != nil {
+		http.Error(w, "invalid value", http.StatusBadRequest)
+		return
+	}
+
+	o := series.Override{
+		AreaID: intParam(r, "area_id"),
+		Date:   date,
+		Kind:   param(r, "kind"),
+		Value:  value,
+		Reason: strings.TrimSpace(param(r, "reason")),
+	}
+	err = series.AddOverride(o, adminActor(r))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = series.SaveOverrides(overridesPath)
+	if err != nil {
+		log.Printf("overrides: failed to save overrides:%s", err)
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	err = series.Save(seriesPath)
+	if err != nil {
+		log.Printf("overrides: failed to save series data:%s", err)
+	}
+
+	log.Printf("overrides: set area:%d date:%s %s:%d reason:%s", o.AreaID, param(r, "date"), o.Kind, o.Value, o.Reason)
+	renderJSON(w, r, series.Overrides())
+}
+
+// defaultAuditLimit is the default number of audit entries served
+const defaultAuditLimit = 100
+
+// adminActor returns the actor recorded in the audit log for changes made by an admin request
+func adminActor(r *http.Request) string {
+	return "admin:" + remoteIP(r)
+}
+
+// handleAudit serves the changes made to stored day values as json, most recent first
+// params: area_id=n&kind=deaths&actor=refresh&since=2020-04-01&limit=n
+// actor matches the start of the actor so actor=admin matches all admin changes
+func handleAudit(w http.ResponseWriter, r *http.Request) {
+
+	log.Printf("request:%s", r.URL)
+
+	if !adminAuthorized(r) {
+		http.Error(w, "not authorized", http.StatusUnauthorized)
+		return
+	}
+
+	var since time.Time
+	if param(r, "since") != "" {
+		var err error
+		since, err = time.Parse("2006-01-02", param(r, "since"))
+		if err != nil {
+			http.Error(w, "invalid since", http.StatusBadRequest)
+			return
+		}
+	}
+
+	areaID, hasArea := intParam(r, "area_id"), param(r, "area_id") != ""
+	kind, actor := param(r, "kind"), param(r, "actor")
+	limit := intParam(r, "limit")
+	if limit <= 0 {
+		limit = defaultAuditLimit
+	}
+
+	entries := series.AuditLog(func(e series.AuditEntry) bool {
+		return (!hasArea || e.AreaID == areaID) &&
+			(kind == "" || e.Kind == kind) &&
+			strings.HasPrefix(e.Actor, actor) &&
+			!e.CreatedAt.Before(since)
+	}, limit)
+	renderJSON(w, r, entries)
+}
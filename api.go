@@ -0,0 +1,487 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/kennygrant/coronavirus/charts"
+	"github.com/kennygrant/coronavirus/msgpack"
+	"github.com/kennygrant/coronavirus/series"
+)
+
+// maxImageSize is the maximum width or height of rendered images
+const maxImageSize = 2000
+
+// maxForecastDays is the maximum number of days forecast on charts
+const maxForecastDays = 28
+
+// Defaults for pages of areas
+const (
+	defaultPerPage = 50
+	maxPerPage     = 500
+)
+
+// defaultSearchResults is the number of areas returned by search if not specified
+const defaultSearchResults = 10
+
+// AreaPage is one page of area summaries, NextCursor fetches the page after it and is empty on the last page
+type AreaPage struct {
+	Areas      []AreaSummary `json:"areas"`
+	Page       int           `json:"page,omitempty"`
+	PerPage    int           `json:"per_page"`
+	Total      int           `json:"total"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+}
+
+// AreaSummary holds the latest totals for an area with its id, and for countries their ranks
+type AreaSummary struct {
+	ID int `json:"id"`
+	AreaProperties
+	Ranks []series.Ranking `json:"ranks,omitempty"`
+}
+
+// handleChanges serves the changelog of dataset versions after the since param as json
+func handleChanges(w http.ResponseWriter, r *http.Request) {
+
+	log.Printf("request:%s", r.URL)
+
+	// Read since if any, default to all changes
+	since, err := strconv.Atoi(param(r, "since"))
+	if err != nil {
+		since = 0
+	}
+
+	renderJSON(w, r, series.ChangesSince(since))
+}
+
+// handleDataset serves the disease tracked and the metrics counted each day as json
+// metric names are those used for kind params, the series file header and imports
+func handleDataset(w http.ResponseWriter, r *http.Request) {
+
+	log.Printf("request:%s", r.URL)
+
+	renderJSON(w, r, series.CurrentDataset())
+}
+
+// handleAreas serves a page of area summaries as json
+// params: sort=deaths|confirmed|recovered|tested|name|id (default deaths), per_page=n
+// and either cursor=x from the last page, or page=n (from 1)
+func handleAreas(w http.ResponseWriter, r *http.Request) {
+
+	log.Printf("request:%s", r.URL)
+
+	sortName := param(r, "sort")
+	if sortName == "" {
+		sortName = "deaths"
+	}
+	perPage := intParam(r, "per_page")
+	if perPage <= 0 {
+		perPage = defaultPerPage
+	}
+	if perPage > maxPerPage {
+		perPage = maxPerPage
+	}
+	cursor := param(r, "cursor")
+	page := intParam(r, "page")
+	if page < 1 || cursor != "" {
+		page = 1
+	}
+
+	p, err := series.Areas().Page(sortName, cursor, page, perPage)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	areas := AreaPage{
+		Areas:      make([]AreaSummary, len(p.Series)),
+		PerPage:    perPage,
+		Total:      p.Total,
+		NextCursor: p.Next,
+	}
+	if cursor == "" {
+		areas.Page = page
+	}
+	for i, s := range p.Series {
+		areas.Areas[i] = AreaSummary{ID: s.ID, AreaProperties: areaProperties(s), Ranks: series.AreaRankings(s.ID)}
+	}
+
+	renderJSON(w, r, areas)
+}
+
+// handleSearch serves the areas with names matching the q param as json, closest matches first
+// params: q=name, n=max results (default 10)
+func handleSearch(w http.ResponseWriter, r *http.Request) {
+
+	log.Printf("request:%s", r.URL)
+
+	n := intParam(r, "n")
+	if n <= 0 || n > maxPerPage {
+		n = defaultSearchResults
+	}
+
+	results := series.Areas().Search(param(r, "q"))
+	if len(results) > n {
+		results = results[:n]
+	}
+
+	areas := make([]AreaSummary, len(results))
+	for i, s := range results {
+		areas[i] = AreaSummary{ID: s.ID, AreaProperties: areaProperties(s)}
+	}
+	renderJSON(w, r, areas)
+}
+
+// handleAutocomplete serves area names starting with the q param, with their slugs, as json
+// params: q=prefix, n=max results (default 10)
+func handleAutocomplete(w http.ResponseWriter, r *http.Request) {
+
+	log.Printf("request:%s", r.URL)
+
+	n := intParam(r, "n")
+	if n <= 0 || n > maxPerPage {
+		n = defaultSearchResults
+	}
+
+	renderJSON(w, r, series.Autocomplete(param(r, "q"), n))
+}
+
+// handleChart serves a chart spec for an area as json, or as a png image if the path ends in .png
+// the path after /api/v1/chart is parsed as for the home page
+// params: kind=deaths|confirmed|recovered|tested|tests_per_case plus chart options (persisted in a cookie)
+// forecast=n adds a forecast for n days with its uncertainty band, weekly=1 charts totals per ISO week
+// png images may also set width and height in pixels
+func handleChart(w http.ResponseWriter, r *http.Request) {
+
+	log.Printf("request:chart%s", r.URL)
+
+	country, province, period, _ := parseParams(r)
+
+	s, err := series.FetchSeries(country, province)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	if notModified(w, r, s) {
+		return
+	}
+
+	dataKind := series.DataKindFromName(param(r, "kind"))
+	if dataKind == series.DataNone {
+		dataKind = series.DataDeaths
+	}
+
+	from, to := parseDateRange(r)
+	if !from.IsZero() || !to.IsZero() {
+		s = withArchive(s, from).PeriodRange(from, to)
+	} else if period > 0 {
+		s = s.Period(period)
+	}
+
+	// Tests per case are ratios, so are charted without daily or cumulative values and are not forecast
+	options := chartOptions(w, r)
+	testsPerCase := param(r, "kind") == charts.MetricTestsPerCase
+	var chart *charts.Chart
+	if testsPerCase {
+		chart = charts.TestsPerCase(s, options)
+	} else {
+		chart = charts.New(s, dataKind, options)
+	}
+
+	// Forecasts are daily, so are not added to weekly charts
+	if days := intParam(r, "forecast"); days > 0 && !options.Weekly && !testsPerCase {
+		if days > maxForecastDays {
+			days = maxForecastDays
+		}
+		chart.AddForecast(s, dataKind, days, options)
+	}
+
+	if strings.HasSuffix(r.URL.Path, ".png") {
+		renderPNG(w, r, chart)
+		return
+	}
+
+	renderJSON(w, r, chart)
+}
+
+// DaySources records the source of each value on one day for the sources api
+type DaySources struct {
+	Date         string            `json:"date"`
+	Provisional  bool              `json:"provisional"`
+	Interpolated bool              `json:"interpolated"`
+	Sources      map[string]string `json:"sources"`
+}
+
+// handleSources serves the source of each value for each day of an area as json
+// the path after /api/v1/sources is parsed as for the home page, params: period=n
+// sources are blank for values loaded before sources were recorded
+func handleSources(w http.ResponseWriter, r *http.Request) {
+
+	log.Printf("request:sources%s", r.URL)
+
+	country, province, period, _ := parseParams(r)
+
+	s, err := series.FetchSeries(country, province)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	if notModified(w, r, s) {
+		return
+	}
+
+	if period > 0 {
+		s = s.Period(period)
+	}
+
+	days := make([]DaySources, len(s.Days))
+	for i, day := range s.Days {
+		days[i] = DaySources{
+			Date:         day.DateMachine(),
+			Provisional:  day.Provisional,
+			Interpolated: day.Interpolated,
+			Sources:      make(map[string]string, len(series.DataKinds())),
+		}
+		for _, kind := range series.DataKinds() {
+			days[i].Sources[series.DataKindName(kind)] = day.Source(kind).String()
+		}
+	}
+
+	renderJSON(w, r, days)
+}
+
+// handleSex serves deaths and confirmed cases by sex for each day of an area which has them as json
+// the path after /api/v1/sex is parsed as for the home page, params: period=n
+// areas without a breakdown by sex return an empty list
+func handleSex(w http.ResponseWriter, r *http.Request) {
+
+	log.Printf("request:sex%s", r.URL)
+
+	country, province, period, _ := parseParams(r)
+
+	s, err := series.FetchSeries(country, province)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	if notModified(w, r, s) {
+		return
+	}
+
+	if period > 0 {
+		s = s.Period(period)
+	}
+
+	renderJSON(w, r, s.SexDays())
+}
+
+// handleVariants serves the share of sequenced cases by variant for each day of an area which has them as json
+// the path after /api/v1/variants is parsed as for the home page, params: period=n
+// areas without variant data return an empty list
+func handleVariants(w http.ResponseWriter, r *http.Request) {
+
+	log.Printf("request:variants%s", r.URL)
+
+	country, province, period, _ := parseParams(r)
+
+	s, err := series.FetchSeries(country, province)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	if notModified(w, r, s) {
+		return
+	}
+
+	if period > 0 {
+		s = s.Period(period)
+	}
+
+	renderJSON(w, r, s.VariantDays())
+}
+
+// handleWaves serves the epidemic waves of an area with statistics for each as json
+// the path after /api/v1/waves is parsed as for the home page
+func handleWaves(w http.ResponseWriter, r *http.Request) {
+
+	log.Printf("request:waves%s", r.URL)
+
+	country, province, _, _ := parseParams(r)
+
+	s, err := series.FetchSeries(country, province)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	if notModified(w, r, s) {
+		return
+	}
+
+	waves := s.Waves()
+	if waves == nil {
+		waves = []series.Wave{}
+	}
+
+	renderJSON(w, r, waves)
+}
+
+// handleMonthlyAPI serves the totals, averages and peak days of each calendar month for an area as json
+// the path after /api/v1/monthly is parsed as for the home page, params: kind=deaths|confirmed|recovered|tested
+func handleMonthlyAPI(w http.ResponseWriter, r *http.Request) {
+
+	log.Printf("request:monthly%s", r.URL)
+
+	country, province, _, _ := parseParams(r)
+
+	s, err := series.FetchSeries(country, province)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	if notModified(w, r, s) {
+		return
+	}
+
+	dataKind := series.DataKindFromName(param(r, "kind"))
+	if dataKind == series.DataNone {
+		dataKind = series.DataDeaths
+	}
+
+	months := s.Monthly(dataKind)
+	if months == nil {
+		months = []series.Month{}
+	}
+
+	renderJSON(w, r, months)
+}
+
+// handleOutliers serves the days on which daily values of an area deviate from the days before as json
+// the path after /api/v1/outliers is parsed as for the home page
+// params: kind=deaths|confirmed|recovered|tested&k=n for the standard deviations, see series.Outliers
+func handleOutliers(w http.ResponseWriter, r *http.Request) {
+
+	log.Printf("request:outliers%s", r.URL)
+
+	country, province, _, _ := parseParams(r)
+
+	s, err := series.FetchSeries(country, province)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	if notModified(w, r, s) {
+		return
+	}
+
+	dataKind := series.DataKindFromName(param(r, "kind"))
+	if dataKind == series.DataNone {
+		dataKind = series.DataDeaths
+	}
+
+	k := floatParam(r, "k")
+	if k <= 0 {
+		k = series.OutlierDeviations
+	}
+
+	renderJSON(w, r, s.Outliers(dataKind, k))
+}
+
+// handleChoropleth serves choropleth map data for all countries keyed by ISO code as json
+// params: metric=deaths|confirmed|recovered|tested optionally suffixed with _per_100k
+func handleChoropleth(w http.ResponseWriter, r *http.Request) {
+
+	log.Printf("request:%s", r.URL)
+
+	metric := param(r, "metric")
+	if metric == "" {
+		metric = "deaths_per_100k"
+	}
+
+	choropleth, err := charts.NewChoropleth(series.Countries(), metric)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	renderJSON(w, r, choropleth)
+}
+
+// renderPNG renders the chart as a png image, sized by the width and height params
+func renderPNG(w http.ResponseWriter, r *http.Request, chart *charts.Chart) {
+	width, height := intParam(r, "width"), intParam(r, "height")
+	if width <= 0 || width > maxImageSize {
+		width = 800
+	}
+	if height <= 0 || height > maxImageSize {
+		height = 400
+	}
+
+	var buf bytes.Buffer
+	err := chart.RenderPNG(&buf, width, height)
+	if err != nil {
+		log.Printf("png render error:%s", err)
+		http.Error(w, err.Error(), 500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.WriteHeader(200)
+	w.Write(buf.Bytes())
+}
+
+// renderJSON renders the value given as json, or as MessagePack if the client accepts it
+// any content type already set on w is kept for json
+func renderJSON(w http.ResponseWriter, r *http.Request, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("json render error:%s", err)
+		http.Error(w, err.Error(), 500)
+		return
+	}
+
+	w.Header().Add("Vary", "Accept")
+	if acceptsMsgpack(r) {
+		data, err = msgpack.FromJSON(data)
+		if err != nil {
+			log.Printf("msgpack render error:%s", err)
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		w.Header().Set("Content-Type", msgpack.ContentType)
+	} else if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	}
+	w.WriteHeader(200)
+	w.Write(data)
+}
+
+// acceptsMsgpack returns true if the Accept header of the request lists MessagePack and not with q=0
+// clients should send Accept: application/msgpack, the older application/x-msgpack is also accepted
+func acceptsMsgpack(r *http.Request) bool {
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		params := strings.Split(accept, ";")
+		media := strings.ToLower(strings.TrimSpace(params[0]))
+		if media != msgpack.ContentType && media != "application/x-msgpack" {
+			continue
+		}
+		for _, p := range params[1:] {
+			name, value, _ := strings.Cut(strings.TrimSpace(p), "=")
+			if q, err := strconv.ParseFloat(value, 64); name == "q" && err == nil && q == 0 {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// apiKeysPath is the file in which issued api keys are saved, keys are secret so this is not in data
+const apiKeysPath = "secrets/api_keys.json"
+
+// APIKey is a key issued to a client of the api, with the usage recorded since startup
+// requests with a valid key are not rate limited and may use the bulk endpoints
+type APIKey struct {
+	Name      string    `json:"name"`
+	Key       string    `json:"key"`
+	CreatedAt time.Time `json:"created_at"`
+	Requests  int64     `json:"requests"`
+	LastUsed  time.Time `json:"last_used"`
+}
+
+// apiKeys holds the keys accepted by the server, use apiKeysMutex to access
+var apiKeys []*APIKey
+
+var apiKeysMutex sync.Mutex
+
+// setupAPIKeys reads api keys from COVID_API_KEYS as a comma separated list of name:key
+// and any keys previously issued by the admin endpoint from apiKeysPath
+func setupAPIKeys() {
+	apiKeysMutex.Lock()
+	defer apiKeysMutex.Unlock()
+
+	for _, entry := range strings.Split(os.Getenv("COVID_API_KEYS"), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, ":", 2)
+		if len(parts) != 2 || parts[1] == "" {
+			log.Printf("server: invalid api key in COVID_API_KEYS for name:%s", parts[0])
+			continue
+		}
+		apiKeys = append(apiKeys, &APIKey{Name: parts[0], Key: parts[1]})
+	}
+
+	data, err := ioutil.ReadFile(apiKeysPath)
+	if err == nil {
+		var issued []*APIKey
+		err = json.Unmarshal(data, &issued)
+		if err != nil {
+			log.Printf("server: error reading api keys:%s", err)
+		}
+		apiKeys = append(apiKeys, issued...)
+	} else if !os.IsNotExist(err) {
+		log.Printf("server: error reading api keys:%s", err)
+	}
+
+	if len(apiKeys) > 0 {
+		log.Printf("server: loaded %d api keys", len(apiKeys))
+	}
+}
+
+// requestKey returns the api key sent with the request in the X-API-Key header
+// keys are not read from params, as request urls are logged
+func requestKey(r *http.Request) string {
+	return r.Header.Get("X-API-Key")
+}
+
+// useAPIKey records a request for the key sent with r, and returns false if no valid key was sent
+func useAPIKey(r *http.Request) bool {
+	key := requestKey(r)
+	if key == "" {
+		return false
+	}
+
+	apiKeysMutex.Lock()
+	defer apiKeysMutex.Unlock()
+	for _, k := range apiKeys {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(k.Key)) == 1 {
+			k.Requests++
+			k.LastUsed = time.Now().UTC()
+			return true
+		}
+	}
+	return false
+}
+
+// validAPIKey returns true if the request was sent with a valid key, without recording usage
+func validAPIKey(r *http.Request) bool {
+	key := requestKey(r)
+	if key == "" {
+		return false
+	}
+
+	apiKeysMutex.Lock()
+	defer apiKeysMutex.Unlock()
+	for _, k := range apiKeys {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(k.Key)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// requireAPIKey restricts h to requests with a valid api key, usage is recorded by rateLimit
+func requireAPIKey(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !validAPIKey(r) {
+			http.Error(w, "api key required", http.StatusUnauthorized)
+			return
+		}
+		h(w, r)
+	}
+}
+
+// issueAPIKey creates a random key for name and saves the keys issued so far to apiKeysPath
+func issueAPIKey(name string) (*APIKey, error) {
+	b := make([]byte, 24)
+	_, err := rand.Read(b)
+	if err != nil {
+		return nil, fmt.Errorf("server: error generating api key:%s", err)
+	}
+	key := &APIKey{
+		Name:      name,
+		Key:       hex.EncodeToString(b),
+		CreatedAt: time.Now().UTC(),
+	}
+
+	apiKeysMutex.Lock()
+	defer apiKeysMutex.Unlock()
+	apiKeys = append(apiKeys, key)
+
+	// Keys from the environment have no creation date and are not saved
+	var issued []*APIKey
+	for _, k := range apiKeys {
+		if !k.CreatedAt.IsZero() {
+			issued = append(issued, k)
+		}
+	}
+	data, err := json.MarshalIndent(issued, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("server: error encoding api keys:%s", err)
+	}
+	err = os.MkdirAll("secrets", 0700)
+	if err != nil {
+		return nil, fmt.Errorf("server: error saving api keys:%s", err)
+	}
+	err = ioutil.WriteFile(apiKeysPath, data, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("server: error saving api keys:%s", err)
+	}
+	return key, nil
+}
+
+// handleAPIKeys serves the usage of each api key as json, keys are shortened so they are not exposed
+// a POST with param name=x issues a new key for x, which is served in full once only
+func handleAPIKeys(w http.ResponseWriter, r *http.Request) {
+
+	log.Printf("request:%s", r.URL.Path)
+
+	if !adminAuthorized(r) {
+		http.Error(w, "not authorized", http.StatusUnauthorized)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		name := param(r, "name")
+		if name == "" {
+			http.Error(w, "name required", http.StatusBadRequest)
+			return
+		}
+		key, err := issueAPIKey(name)
+		if err != nil {
+			log.Printf("api keys error:%s", err)
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		log.Printf("api keys: issued key for name:%s", name)
+		renderJSON(w, r, key)
+		return
+	}
+
+	apiKeysMutex.Lock()
+	usage := make([]APIKey, len(apiKeys))
+	for i, k := range apiKeys {
+		usage[i] = *k
+		if len(usage[i].Key) > 6 {
+			usage[i].Key = usage[i].Key[:6] + "..."
+		}
+	}
+	apiKeysMutex.Unlock()
+
+	sort.SliceStable(usage, func(i, j int) bool {
+		return usage[i].Requests > usage[j].Requests
+	})
+	renderJSON(w, r, usage)
+}
+
+// handleBulkSeries serves the complete series file as csv to clients with an api key
+func handleBulkSeries(w http.ResponseWriter, r *http.Request) {
+
+	log.Printf("request:%s", r.URL.Path)
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	http.ServeFile(w, r, seriesPath)
+}
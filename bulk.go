@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/kennygrant/coronavirus/parquet"
+	"github.com/kennygrant/coronavirus/rpc"
+	"github.com/kennygrant/coronavirus/series"
+)
+
+// parquetMutex serialises writes of the parquet export
+var parquetMutex sync.Mutex
+
+// writeParquet writes every day of all areas to parquetPath as a parquet file for analysts
+// the file is written alongside and renamed into place, so downloads never see a partial file
+func writeParquet() error {
+	parquetMutex.Lock()
+	defer parquetMutex.Unlock()
+
+	f, err := ioutil.TempFile(filepath.Dir(parquetPath), "series-*.parquet")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	err = parquet.WriteSeries(f, series.Areas())
+	if err != nil {
+		f.Close()
+		return err
+	}
+	err = f.Close()
+	if err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), parquetPath)
+}
+
+// handleBulkParquet serves every day of all areas as a parquet file to clients with an api key
+// the file is written nightly, or on demand if it has not been written yet or fresh=1
+func handleBulkParquet(w http.ResponseWriter, r *http.Request) {
+
+	log.Printf("request:%s", r.URL)
+
+	_, err := os.Stat(parquetPath)
+	if err != nil || param(r, "fresh") == "1" {
+		err = writeParquet()
+		if err != nil {
+			log.Printf("parquet: failed to write:%s", err)
+			http.Error(w, "failed to write parquet", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/vnd.apache.parquet")
+	w.Header().Set("Content-Disposition", `attachment; filename="series.parquet"`)
+	http.ServeFile(w, r, parquetPath)
+}
+
+// handleBulkProtobuf serves every day of all areas as a protobuf Dataset message to clients with an api key
+// the message is defined in rpc/series.proto, with the days of each area in packed columns
+func handleBulkProtobuf(w http.ResponseWriter, r *http.Request) {
+
+	log.Printf("request:%s", r.URL)
+
+	w.Header().Set("Content-Type", "application/x-protobuf; messageType=coronavirus.v1.Dataset")
+	w.WriteHeader(http.StatusOK)
+	err := rpc.WriteDataset(w, series.Areas())
+	if err != nil {
+		log.Printf("protobuf: failed to write dataset:%s", err)
+	}
+}
+
+// DumpRow is one day of one area in the json lines dump, values are cumulative with the daily change
+type DumpRow struct {
+	AreaID         int    `json:"area_id"`
+	Country        string `json:"country"`
+	Province       string `json:"province"`
+	Date           string `json:"date"`
+	Deaths         int    `json:"deaths"`
+	Confirmed      int    `json:"confirmed"`
+	Recovered      int    `json:"recovered"`
+	Tested         int    `json:"tested"`
+	DeathsDaily    int    `json:"deaths_daily"`
+	ConfirmedDaily int    `json:"confirmed_daily"`
+	Provisional    bool   `json:"provisional,omitempty"`
+}
+
+// handleDump streams a json object per line for each day of each area, for piping into jq or ingestion pipelines
+// params: area_ids=1,2,3 for these areas, all areas if blank, and from=date&to=date to limit the days
+// rows are written as they are encoded and flushed after each area, so a slow client slows the dump
+// rather than the whole dump being held in memory
+func handleDump(w http.ResponseWriter, r *http.Request) {
+
+	log.Printf("request:%s", r.URL)
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	areas := series.Areas()
+	if ids := parseAreaIDs(param(r, "area_ids")); len(ids) > 0 {
+		areas = nil
+		for _, id := range ids {
+			s, err := series.FindSeries(id)
+			if err == nil {
+				areas = append(areas, s)
+			}
+		}
+	}
+	from, to := parseDateRange(r)
+
+	// The dump may take longer than the server write timeout for slow clients
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+
+	encoder := json.NewEncoder(w)
+	for _, s := range areas {
+		s = withArchive(s, from).PeriodRange(from, to)
+		deaths, confirmed := s.DailyValues(series.DataDeaths), s.DailyValues(series.DataConfirmed)
+		for i, day := range s.Days {
+			err := encoder.Encode(DumpRow{
+				AreaID:         s.ID,
+				Country:        s.Country,
+				Province:       s.Province,
+				Date:           day.DateMachine(),
+				Deaths:         day.Value(series.DataDeaths),
+				Confirmed:      day.Value(series.DataConfirmed),
+				Recovered:      day.Value(series.DataRecovered),
+				Tested:         day.Value(series.DataTested),
+				DeathsDaily:    deaths[i],
+				ConfirmedDaily: confirmed[i],
+				Provisional:    day.Provisional,
+			})
+			if err != nil {
+				log.Printf("dump: closed stream:%s", err)
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/kennygrant/coronavirus/series"
+)
+
+// startedAt is used in etags so that responses change when the server is redeployed
+var startedAt = time.Now().UTC()
+
+// notModified sets the ETag and Last-Modified headers for a response showing series s
+// it returns true after writing a 304 if the client's copy is current, handlers should then return
+// responses vary with the url, chart option cookies, language, device and encoding, so these are part of the etag
+// in development responses are never cached, as templates are reloaded on each request
+func notModified(w http.ResponseWriter, r *http.Request, s *series.Data) bool {
+	if development {
+		return false
+	}
+
+	last := s.LastDay()
+	h := sha256.New()
+	fmt.Fprintf(h, "%s|%s|%d|%s|%s|%s|%s|%t|%t", startedAt, s.UpdatedAt, len(s.Days), last,
+		r.URL.RequestURI(), r.Header.Get("Cookie"), r.Header.Get("Accept-Language"),
+		strings.Contains(strings.ToLower(r.UserAgent()), "mobile"), acceptsMsgpack(r))
+	etag := fmt.Sprintf(`W/"%s"`, hex.EncodeToString(h.Sum(nil))[:16])
+
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Vary", "Cookie, Accept-Language, User-Agent")
+	modified := s.UpdatedAt.Truncate(time.Second)
+	if !modified.IsZero() {
+		w.Header().Set("Last-Modified", modified.Format(http.TimeFormat))
+	}
+
+	// If-None-Match takes precedence over If-Modified-Since
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		for _, m := range strings.Split(match, ",") {
+			m = strings.TrimSpace(m)
+			if m == etag || m == "*" {
+				w.WriteHeader(http.StatusNotModified)
+				return true
+			}
+		}
+		return false
+	}
+	if since, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err == nil && !modified.IsZero() && !modified.After(since) {
+		w.WriteHeader(http.StatusNotModified)
+		return true
+	}
+	return false
+}
@@ -0,0 +1,100 @@
+package charts
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kennygrant/coronavirus/series"
+)
+
+// eventColors are the colours used for annotations of each event type
+var eventColors = map[string]string{
+	series.EventLockdown:  "#ff0000",
+	series.EventMasks:     "#1f77b4",
+	series.EventReopening: "#2ca02c",
+	series.EventSchools:   "#ff7f0e",
+}
+
+// noteColor is used for annotations of notes on days
+const noteColor = "#666666"
+
+// Annotation marks one date on a chart with a vertical line
+// Index is the position of the date in the chart labels
+// notes are shown as markers with the label shown on hover
+type Annotation struct {
+	Label string `json:"label"`
+	Date  string `json:"date"`
+	Index int    `json:"index"`
+	Color string `json:"color"`
+	Note  bool   `json:"note,omitempty"`
+}
+
+// Annotations returns annotations for events and notes on days in the series given
+// events with an end date have a second annotation at the end
+// dates outside the days in the series are omitted
+func Annotations(s *series.Data) []Annotation {
+	var annotations []Annotation
+	for _, e := range s.Events {
+		label := e.Description
+		if label == "" {
+			label = strings.Title(e.Type)
+		}
+		annotations = appendAnnotation(annotations, s, label, e.Start, eventColors[e.Type])
+		if e.HasEnd() {
+			annotations = appendAnnotation(annotations, s, label+" ends", e.End, eventColors[e.Type])
+		}
+	}
+	for i, day := range s.Days {
+		for _, note := range day.Notes {
+			annotations = append(annotations, Annotation{
+				Label: note,
+				Date:  day.DateMachine(),
+				Index: i,
+				Color: noteColor,
+				Note:  true,
+			})
+		}
+	}
+	return annotations
+}
+
+// appendAnnotation appends an annotation for the date given if it is within the series
+func appendAnnotation(annotations []Annotation, s *series.Data, label string, date time.Time, color string) []Annotation {
+	i := dateIndex(s, date)
+	if i < 0 {
+		return annotations
+	}
+	return append(annotations, Annotation{
+		Label: label,
+		Date:  date.Format("2006-01-02"),
+		Index: i,
+		Color: color,
+	})
+}
+
+// seriesAnnotations returns annotations for one of several series on a chart
+// labels are prefixed with the series title, and indexes offset by the offset given
+func seriesAnnotations(s *series.Data, offset, count int) []Annotation {
+	var annotations []Annotation
+	for _, a := range Annotations(s) {
+		a.Index -= offset
+		if a.Index < 0 || a.Index >= count {
+			continue
+		}
+		a.Label = fmt.Sprintf("%s %s", s.Title(), a.Label)
+		a.Color = s.Color
+		annotations = append(annotations, a)
+	}
+	return annotations
+}
+
+// dateIndex returns the index of the day with the date given, or -1 if not found
+func dateIndex(s *series.Data, date time.Time) int {
+	for i, day := range s.Days {
+		if day.Date.Equal(date) {
+			return i
+		}
+	}
+	return -1
+}
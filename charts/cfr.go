@@ -0,0 +1,38 @@
+package charts
+
+import (
+	"fmt"
+
+	"github.com/kennygrant/coronavirus/series"
+)
+
+// adjustedColor is used for case fatality rates adjusted for the lag from confirmation to death
+const adjustedColor = "#cc3333"
+
+// CFR returns a line chart of the case fatality rate on each day, as deaths over cases on the same day
+// and adjusted for the lag from confirmation to death given, only the scale option is used as rates are percentages
+func CFR(s *series.Data, lag series.DeathLag, options Options) *Chart {
+	chart := &Chart{
+		Title:       fmt.Sprintf("%s case fatality rate %%", s.Title()),
+		Type:        "line",
+		Scale:       options.Scale,
+		Labels:      s.Dates(),
+		Annotations: Annotations(s),
+		Provisional: s.ProvisionalCount(),
+	}
+
+	chart.Datasets = append(chart.Datasets, Dataset{
+		Label: "Same day",
+		Type:  "line",
+		Color: s.Color,
+		Data:  s.CaseFatalityRates(),
+	}, Dataset{
+		Label: fmt.Sprintf("Adjusted for %g day lag", lag.Mean),
+		Type:  "line",
+		Color: adjustedColor,
+		Data:  s.AdjustedCaseFatalityRates(lag),
+	})
+
+	chart.SetTicks()
+	return chart
+}
@@ -0,0 +1,382 @@
+// Package charts builds chart specifications from series data
+// for rendering by the browser or server side
+package charts
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"github.com/kennygrant/coronavirus/series"
+)
+
+// Scales available for charts
+const (
+	ScaleLinear      = "linear"
+	ScaleLogarithmic = "logarithmic"
+)
+
+// averageDays is the number of days used for moving averages
+const averageDays = 7
+
+// averageColor is used for average lines overlaid on bars
+const averageColor = "#333333"
+
+// per100kTitle is appended to chart titles for per capita values
+const per100kTitle = " per 100k"
+
+// linearTickCount is the approximate number of ticks shown on linear axes
+const linearTickCount = 5
+
+// Options holds the user selected display options for charts
+type Options struct {
+	// Average is true if daily values should be shown as a 7 day average
+	Average bool
+
+	// Scale is either linear or logarithmic
+	Scale string
+
+	// Cumulative is true if charts show cumulative totals rather than daily values
+	Cumulative bool
+
+	// PerCapita is true if values are shown per 100k population
+	PerCapita bool
+
+	// Weekly is true if charts made by New show totals per ISO week rather than per day
+	// averages and cumulative totals are not shown on weekly charts
+	Weekly bool
+
+	// ExcludeOutliers is true if days flagged as outliers, like backlogs reported on one day, are left out of averages
+	ExcludeOutliers bool
+
+	// Smooth is the name of a smoother in series.Smoothers used instead of the moving average, or blank
+	// outliers are not excluded from smoothed values, and they end on the last complete day
+	Smooth string
+}
+
+// DefaultOptions returns the default chart options
+func DefaultOptions() Options {
+	return Options{Scale: ScaleLinear}
+}
+
+// ParseOptions reads options from the values given starting from the base options
+// params are average=7|0, scale=log|linear, cumulative=1|0, per100k=1|0, weekly=1|0, outliers=exclude|include
+// and smooth=kalman|loess|none, unknown smoothers are ignored
+// ok is true if any option params were present
+func ParseOptions(values url.Values, base Options) (options Options, ok bool) {
+	options = base
+
+	if v := values.Get("average"); v != "" {
+		options.Average = v != "0"
+		ok = true
+	}
+
+	switch values.Get("scale") {
+	case "log", ScaleLogarithmic:
+		options.Scale = ScaleLogarithmic
+		ok = true
+	case "linear":
+		options.Scale = ScaleLinear
+		ok = true
+	}
+
+	if v := values.Get("cumulative"); v != "" {
+		options.Cumulative = v == "1" || v == "true"
+		ok = true
+	}
+
+	if v := values.Get("per100k"); v != "" {
+		options.PerCapita = v == "1" || v == "true"
+		ok = true
+	}
+
+	if v := values.Get("weekly"); v != "" {
+		options.Weekly = v == "1" || v == "true"
+		ok = true
+	}
+
+	if v := values.Get("outliers"); v != "" {
+		options.ExcludeOutliers = v == "exclude"
+		ok = true
+	}
+
+	if v := values.Get("smooth"); v == "none" {
+		options.Smooth = ""
+		ok = true
+	} else if _, known := series.Smoothers[v]; known {
+		options.Smooth = v
+		ok = true
+	}
+
+	return options, ok
+}
+
+// Encode encodes the options as a query string
+func (o Options) Encode() string {
+	values := url.Values{}
+	values.Set("average", "0")
+	if o.Average {
+		values.Set("average", strconv.Itoa(averageDays))
+	}
+	values.Set("scale", "linear")
+	if o.Scale == ScaleLogarithmic {
+		values.Set("scale", "log")
+	}
+	values.Set("cumulative", "0")
+	if o.Cumulative {
+		values.Set("cumulative", "1")
+	}
+	values.Set("per100k", "0")
+	if o.PerCapita {
+		values.Set("per100k", "1")
+	}
+	values.Set("weekly", "0")
+	if o.Weekly {
+		values.Set("weekly", "1")
+	}
+	values.Set("outliers", "include")
+	if o.ExcludeOutliers {
+		values.Set("outliers", "exclude")
+	}
+	values.Set("smooth", "none")
+	if o.Smooth != "" {
+		values.Set("smooth", o.Smooth)
+	}
+	return values.Encode()
+}
+
+// DecodeOptions decodes options encoded with Encode
+func DecodeOptions(s string) (Options, error) {
+	values, err := url.ParseQuery(s)
+	if err != nil {
+		return DefaultOptions(), fmt.Errorf("charts: invalid options:%s", err)
+	}
+	options, _ := ParseOptions(values, DefaultOptions())
+	return options, nil
+}
+
+// Chart is a specification for one chart which can be serialised to json
+type Chart struct {
+	Title       string       `json:"title"`
+	Type        string       `json:"type"`
+	Scale       string       `json:"scale"`
+	Labels      []string     `json:"labels"`
+	Ticks       []float64    `json:"ticks"`
+	Datasets    []Dataset    `json:"datasets"`
+	Annotations []Annotation `json:"annotations"`
+
+	// Stacked is true if the values of the datasets are stacked, as for shares of a total
+	Stacked bool `json:"stacked,omitempty"`
+
+	// Provisional is the count of days at the end of the labels which may still be revised
+	Provisional int `json:"provisional"`
+}
+
+// SetTicks sets the y axis ticks for the chart scale and datasets
+// this should be called after all datasets are added
+func (c *Chart) SetTicks() {
+	max := maxValue(c.Datasets)
+	if c.Scale == ScaleLogarithmic {
+		c.Ticks = LogTicks(max)
+	} else {
+		c.Ticks = LinearTicks(max, linearTickCount)
+	}
+}
+
+// Dataset is one set of values plotted on a chart
+type Dataset struct {
+	Label string    `json:"label"`
+	Type  string    `json:"type"`
+	Color string    `json:"color"`
+	Data  []float64 `json:"data"`
+
+	// Offset is the index of the label for the first value, for datasets starting after the others
+	Offset int `json:"offset,omitempty"`
+
+	// Fill is the chart.js fill target, -1 shades down to the previous dataset
+	Fill string `json:"fill,omitempty"`
+
+	// Dashed is true if the line should be drawn dashed, as for projected values
+	Dashed bool `json:"dashed,omitempty"`
+}
+
+// New returns a chart for the series and data kind given, using the options to transform the data
+// daily charts are bars with a moving average line overlaid, or just the average line if averaged
+func New(s *series.Data, dataKind int, options Options) *Chart {
+	if options.Weekly {
+		return Weekly(s, dataKind, options)
+	}
+	name := series.DataKindName(dataKind)
+
+	chart := &Chart{
+		Title:       fmt.Sprintf("%s daily %s", s.Title(), name),
+		Type:        "bar",
+		Scale:       options.Scale,
+		Labels:      s.Dates(),
+		Annotations: Annotations(s),
+		Provisional: s.ProvisionalCount(),
+	}
+
+	if options.Cumulative {
+		chart.Title = fmt.Sprintf("%s total %s", s.Title(), name)
+		chart.Type = "line"
+	}
+	if options.PerCapita {
+		chart.Title += per100kTitle
+	}
+
+	chart.Datasets = append(chart.Datasets, Dataset{
+		Label: chart.Title,
+		Type:  chart.Type,
+		Color: s.Color,
+		Data:  Values(s, dataKind, options),
+	})
+
+	// Overlay the average on daily bars
+	if !options.Cumulative && !options.Average {
+		label := fmt.Sprintf("%d day average", averageDays)
+		if options.Smooth != "" {
+			label = fmt.Sprintf("%s smoothed", options.Smooth)
+		}
+		chart.Datasets = append(chart.Datasets, Dataset{
+			Label: label,
+			Type:  "line",
+			Color: averageColor,
+			Data:  Average(s, dataKind, options),
+		})
+	}
+
+	chart.SetTicks()
+	return chart
+}
+
+// Average returns the moving average of daily values for the series and data kind given
+// only the per capita, outlier and smoothing options are used, as averages are always of daily values
+// provisional days are excluded so the average ends on the last complete day
+func Average(s *series.Data, dataKind int, options Options) []float64 {
+	values := Values(s, dataKind, Options{Average: true, PerCapita: options.PerCapita, ExcludeOutliers: options.ExcludeOutliers, Smooth: options.Smooth})
+	return values[:s.CompleteCount()]
+}
+
+// Compare returns a line chart comparing the series given for one data kind
+// all series are aligned by date, using the dates of the first series
+func Compare(slice []*series.Data, dataKind int, options Options) *Chart {
+	name := series.DataKindName(dataKind)
+
+	chart := &Chart{
+		Title: fmt.Sprintf("Daily %s", name),
+		Type:  "line",
+		Scale: options.Scale,
+	}
+	if options.Cumulative {
+		chart.Title = fmt.Sprintf("Total %s", name)
+	}
+	if options.PerCapita {
+		chart.Title += per100kTitle
+	}
+
+	if len(slice) == 0 {
+		return chart
+	}
+	chart.Labels = slice[0].Dates()
+
+	for _, s := range slice {
+		chart.Datasets = append(chart.Datasets, Dataset{
+			Label: s.Title(),
+			Type:  "line",
+			Color: s.Color,
+			Data:  Values(s, dataKind, options),
+		})
+		chart.Annotations = append(chart.Annotations, seriesAnnotations(s, 0, len(chart.Labels))...)
+	}
+
+	chart.SetTicks()
+	return chart
+}
+
+// Aligned returns a line chart comparing the series given for one data kind
+// each series is aligned from the day on which it reached n, labels count days from then
+func Aligned(slice []*series.Data, dataKind, n int, options Options) *Chart {
+	chart := Compare(nil, dataKind, options)
+	chart.Title = fmt.Sprintf("%s from %d %s", chart.Title, n, series.DataKindName(dataKind))
+	chart.Labels = series.Slice(slice).AlignedDaysFrom(dataKind, n)
+
+	for _, s := range slice {
+		i := s.AlignedIndex(dataKind, n)
+		if i < 0 {
+			continue
+		}
+		// Align values after transforming, ignoring today's incomplete data
+		values := Values(s, dataKind, options)
+		if i >= len(values) {
+			continue
+		}
+		chart.Datasets = append(chart.Datasets, Dataset{
+			Label: s.Title(),
+			Type:  "line",
+			Color: s.Color,
+			Data:  values[i : len(values)-1],
+		})
+		chart.Annotations = append(chart.Annotations, seriesAnnotations(s, i, len(chart.Labels))...)
+	}
+
+	chart.SetTicks()
+	return chart
+}
+
+// Values returns the values to plot for the series and data kind given
+// transformed according to the options, values are cached by the series and must not be modified
+func Values(s *series.Data, dataKind int, options Options) []float64 {
+	key := fmt.Sprintf("values:%d:%t:%t:%t:%t:%s", dataKind, options.Cumulative, options.Average, options.PerCapita, options.ExcludeOutliers, options.Smooth)
+	return s.Memo(key, func() interface{} {
+		return values(s, dataKind, options)
+	}).([]float64)
+}
+
+// values calculates the values returned by Values
+func values(s *series.Data, dataKind int, options Options) []float64 {
+	var values []int
+	if options.Cumulative {
+		values = s.Values(dataKind)
+	} else {
+		values = s.DailyValues(dataKind)
+	}
+
+	// Outliers are found in daily values, so are only excluded from averages of them, and smoothing replaces the average
+	// smoothed values are copied as they are cached by the series, and per capita values are set in place
+	// smoothed values leave out provisional days, which are padded with the last smoothed value to keep one value per day
+	var result []float64
+	if options.Average && options.Smooth != "" && !options.Cumulative {
+		result = append(make([]float64, 0, len(values)), s.Smoothed(dataKind, options.Smooth)...)
+		last := 0.0
+		if len(result) > 0 {
+			last = result[len(result)-1]
+		}
+		for len(result) < len(values) {
+			result = append(result, last)
+		}
+	} else if options.Average && options.ExcludeOutliers && !options.Cumulative {
+		result = series.MovingAverageExcluding(values, s.OutlierDays(dataKind, series.OutlierDeviations), averageDays)
+	} else if options.Average {
+		result = series.MovingAverage(values, averageDays)
+	} else {
+		result = floats(values)
+	}
+
+	if options.PerCapita {
+		for i, v := range result {
+			result[i] = s.Per100k(v)
+		}
+	}
+
+	return result
+}
+
+// floats converts integer values to floats
+func floats(values []int) []float64 {
+	result := make([]float64, len(values))
+	for i, v := range values {
+		result[i] = float64(v)
+	}
+	return result
+}
@@ -0,0 +1,298 @@
+package charts
+
+import (
+	"math"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/kennygrant/coronavirus/series"
+)
+
+// TestTicks tests tick generation for log and linear scales
+func TestTicks(t *testing.T) {
+	got := LogTicks(150)
+	want := []float64{1, 2, 5, 10, 20, 50, 100, 200}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ticks: log ticks wrong want:%v got:%v", want, got)
+	}
+
+	got = LogTicks(2000000)
+	want = []float64{1, 10, 100, 1000, 10000, 100000, 1000000, 10000000}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ticks: log ticks wrong want:%v got:%v", want, got)
+	}
+
+	got = LinearTicks(930, 5)
+	want = []float64{0, 200, 400, 600, 800, 1000}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ticks: linear ticks wrong want:%v got:%v", want, got)
+	}
+}
+
+// TestHeatmap tests bucketing of daily values on the heatmap calendar
+func TestHeatmap(t *testing.T) {
+	s := &series.Data{}
+	// 2020-01-22 is a Wednesday
+	s.SetData(time.Date(2020, 1, 22, 0, 0, 0, 0, time.UTC), series.DataConfirmed, []int{0, 0, 60, 60, 120, 180})
+
+	h := NewHeatmap(s, series.DataConfirmed)
+	if len(h.Days) != 6 || h.Weeks != 2 {
+		t.Fatalf("heatmap: days wrong got:%d weeks:%d", len(h.Days), h.Weeks)
+	}
+
+	if h.Days[0].Bucket != 0 || h.Days[0].Weekday != 2 {
+		t.Errorf("heatmap: first day wrong got:%v", h.Days[0])
+	}
+
+	// Daily values are 0,0,60,0,60,60 so the max bucket is reached on day 3
+	if h.Days[2].Bucket != len(h.Buckets)-1 || h.Days[3].Bucket != 0 {
+		t.Errorf("heatmap: buckets wrong got:%v", h.Days)
+	}
+
+	if h.Days[5].Week != 1 || h.Days[5].Weekday != 0 {
+		t.Errorf("heatmap: last day position wrong got:%v", h.Days[5])
+	}
+}
+
+// TestChoropleth tests per capita values and buckets on the choropleth
+func TestChoropleth(t *testing.T) {
+	start := time.Date(2020, 1, 22, 0, 0, 0, 0, time.UTC)
+	var slice []*series.Data
+	for i, country := range []string{"Italy", "Spain", "Atlantis"} {
+		s := &series.Data{Country: country, Population: 1000000}
+		s.SetData(start, series.DataDeaths, []int{0, 100 * i})
+		slice = append(slice, s)
+	}
+
+	c, err := NewChoropleth(slice, "deaths_per_100k")
+	if err != nil {
+		t.Fatalf("choropleth: error:%s", err)
+	}
+
+	// Unknown countries have no code so are omitted
+	if len(c.Areas) != 2 {
+		t.Fatalf("choropleth: areas wrong got:%v", c.Areas)
+	}
+
+	if c.Areas["ITA"].Value != 0 || c.Areas["ITA"].Bucket != 0 {
+		t.Errorf("choropleth: ITA wrong got:%v", c.Areas["ITA"])
+	}
+
+	if c.Areas["ESP"].Value != 10 || c.Areas["ESP"].Bucket == 0 {
+		t.Errorf("choropleth: ESP wrong got:%v", c.Areas["ESP"])
+	}
+
+	_, err = NewChoropleth(slice, "invalid")
+	if err == nil {
+		t.Errorf("choropleth: expected error for invalid metric")
+	}
+}
+
+// TestAnnotations tests event annotations on single and aligned charts
+func TestAnnotations(t *testing.T) {
+	start := time.Date(2020, 1, 22, 0, 0, 0, 0, time.UTC)
+	s := &series.Data{Country: "Italy", Events: []series.Event{
+		{Type: series.EventLockdown, Start: start.AddDate(0, 0, 3), Description: "Lockdown"},
+	}}
+	s.SetData(start, series.DataDeaths, []int{0, 1, 10, 20, 40, 80})
+
+	chart := New(s, series.DataDeaths, DefaultOptions())
+	if len(chart.Annotations) != 1 || chart.Annotations[0].Index != 3 {
+		t.Fatalf("annotations: chart annotations wrong got:%v", chart.Annotations)
+	}
+
+	// Aligned from 10 deaths on day 2, so lockdown is on day 1
+	chart = Aligned([]*series.Data{s}, series.DataDeaths, 10, DefaultOptions())
+	if len(chart.Annotations) != 1 || chart.Annotations[0].Index != 1 {
+		t.Errorf("annotations: aligned annotations wrong got:%v", chart.Annotations)
+	}
+
+	// Smoothed values leave out provisional days, so an area reaching n on a provisional day is padded to align
+	provisional := &series.Data{Country: "Spain"}
+	provisional.SetData(start, series.DataDeaths, []int{0, 1, 10, 20, 40, 80})
+	provisional.SetProvisional(start.AddDate(0, 0, 4))
+	options := DefaultOptions()
+	options.Average, options.Smooth = true, "kalman"
+	chart = Aligned([]*series.Data{s, provisional}, series.DataDeaths, 40, options)
+	if len(chart.Datasets) != 2 || len(chart.Datasets[0].Data) != 1 || len(chart.Datasets[1].Data) != 1 {
+		t.Errorf("annotations: smoothed aligned datasets wrong got:%v", chart.Datasets)
+	}
+
+	// Events with an end have an annotation at each end
+	s.Events = append(s.Events, series.Event{Type: series.EventSchools, Start: start.AddDate(0, 0, 1), End: start.AddDate(0, 0, 4)})
+	chart = New(s, series.DataDeaths, DefaultOptions())
+	if len(chart.Annotations) != 3 || chart.Annotations[2].Label != "Schools ends" || chart.Annotations[2].Index != 4 {
+		t.Errorf("annotations: event annotations wrong got:%v", chart.Annotations)
+	}
+
+	// Events outside the period are omitted
+	chart = New(s.Period(1), series.DataDeaths, DefaultOptions())
+	if len(chart.Annotations) != 0 {
+		t.Errorf("annotations: expected no annotations got:%v", chart.Annotations)
+	}
+
+	// Notes on days are annotated as notes
+	s.Days[5].Notes = []string{"Reporting changed"}
+	chart = New(s.Period(1), series.DataDeaths, DefaultOptions())
+	if len(chart.Annotations) != 1 || !chart.Annotations[0].Note || chart.Annotations[0].Index != 0 || chart.Annotations[0].Label != "Reporting changed" {
+		t.Errorf("annotations: note annotations wrong got:%v", chart.Annotations)
+	}
+}
+
+// TestForecast tests forecast datasets are offset from the last complete day
+func TestForecast(t *testing.T) {
+	s := &series.Data{}
+	values := make([]int, 30)
+	for i := range values {
+		values[i] = 10 * (i + 1)
+	}
+	start := time.Date(2020, 1, 22, 0, 0, 0, 0, time.UTC)
+	s.SetData(start, series.DataDeaths, values)
+	s.SetProvisional(start.AddDate(0, 0, 29))
+
+	c := New(s, series.DataDeaths, DefaultOptions())
+	c.AddForecast(s, series.DataDeaths, 3, DefaultOptions())
+	if len(c.Labels) != 32 || c.Labels[31] != "Feb 22" || len(c.Datasets) != 5 {
+		t.Fatalf("forecast: chart wrong got labels:%d datasets:%d", len(c.Labels), len(c.Datasets))
+	}
+
+	// Constant daily values are forecast exactly with no uncertainty
+	f := c.Datasets[4]
+	want := []float64{10, 10, 10}
+	if f.Offset != 29 || !f.Dashed || !reflect.DeepEqual(f.Data, want) || !reflect.DeepEqual(c.Datasets[3].Data, want) {
+		t.Errorf("forecast: dataset wrong got:%v", f)
+	}
+}
+
+// TestSparkline tests scaling of values to unicode blocks
+func TestSparkline(t *testing.T) {
+	got := Sparkline([]int{0, 1, 4, 8, -2})
+	want := "▁▁▄█▁"
+	if got != want {
+		t.Errorf("sparkline: wrong line want:%s got:%s", want, got)
+	}
+	if Sparkline(nil) != "" || Sparkline([]int{0, 0}) != "▁▁" {
+		t.Errorf("sparkline: wrong line for empty values")
+	}
+}
+
+// TestWeekly tests weekly charts show totals per ISO week, and the weekly option is encoded
+func TestWeekly(t *testing.T) {
+	s := &series.Data{Population: 100000}
+	values := make([]int, 14)
+	for i := range values {
+		values[i] = 2 * (i + 1)
+	}
+	start := time.Date(2020, 1, 22, 0, 0, 0, 0, time.UTC)
+	s.SetData(start, series.DataDeaths, values)
+	s.SetProvisional(start.AddDate(0, 0, 13))
+
+	options := DefaultOptions()
+	options.Weekly = true
+	c := New(s, series.DataDeaths, options)
+	want := []float64{10, 14, 4}
+	if !reflect.DeepEqual(c.Labels, []string{"2020-W04", "2020-W05", "2020-W06"}) || !reflect.DeepEqual(c.Datasets[0].Data, want) || c.Provisional != 1 {
+		t.Errorf("weekly: chart wrong got labels:%v data:%v", c.Labels, c.Datasets[0].Data)
+	}
+
+	decoded, err := DecodeOptions(options.Encode())
+	if err != nil || decoded != options {
+		t.Errorf("weekly: options not decoded got:%v", decoded)
+	}
+}
+
+func TestExcludeOutliers(t *testing.T) {
+	s := &series.Data{}
+	daily := make([]int, 30)
+	for i := range daily {
+		daily[i] = 10
+	}
+	daily[20] = 80
+	s.SetData(time.Date(2020, 1, 22, 0, 0, 0, 0, time.UTC), series.DataDeaths, series.CumulativeFromDaily(daily, 0))
+
+	options, ok := ParseOptions(url.Values{"outliers": {"exclude"}}, DefaultOptions())
+	if !ok || !options.ExcludeOutliers {
+		t.Fatalf("outliers: options not parsed got:%v", options)
+	}
+	if averages := Average(s, series.DataDeaths, options); averages[20] != 10 || averages[26] != 10 {
+		t.Errorf("outliers: outlier included in averages got:%v", averages)
+	}
+	if averages := Average(s, series.DataDeaths, DefaultOptions()); averages[20] != 20 {
+		t.Errorf("outliers: outlier excluded from averages got:%v", averages)
+	}
+
+	decoded, err := DecodeOptions(options.Encode())
+	if err != nil || decoded != options {
+		t.Errorf("outliers: options not decoded got:%v", decoded)
+	}
+}
+
+func TestTestsPerCase(t *testing.T) {
+	a, b := &series.Data{Country: "A"}, &series.Data{Country: "B"}
+	start := time.Date(2020, 1, 22, 0, 0, 0, 0, time.UTC)
+	for _, s := range []*series.Data{a, b} {
+		s.SetData(start, series.DataConfirmed, []int{10, 20, 30})
+		s.SetData(start, series.DataTested, []int{100, 200, 300})
+	}
+
+	c := TestsPerCase(a, DefaultOptions())
+	if len(c.Datasets) != 1 || !reflect.DeepEqual(c.Datasets[0].Data, []float64{10, 10, 10}) {
+		t.Errorf("tests per case: wrong chart got:%v", c.Datasets)
+	}
+	c = CompareTestsPerCase([]*series.Data{a, b}, DefaultOptions())
+	if len(c.Datasets) != 2 || len(c.Labels) != 3 || c.Datasets[1].Label != "B" {
+		t.Errorf("tests per case: wrong comparison got:%v", c.Datasets)
+	}
+}
+
+func TestDeathsPerMillion(t *testing.T) {
+	start := time.Date(2020, 1, 22, 0, 0, 0, 0, time.UTC)
+	a := &series.Data{Country: "A", Population: 1000000}
+	a.SetData(start, series.DataDeaths, []int{0, 1, 3, 6, 10})
+	b := &series.Data{Country: "B", Population: 2000000}
+	b.SetData(start, series.DataDeaths, []int{2, 4, 6, 8, 10})
+	c := &series.Data{Country: "C"}
+	c.SetData(start, series.DataDeaths, []int{1, 2, 3, 4, 5})
+
+	// Series are aligned from their first death, without today, and areas without population are left out
+	options := DefaultOptions()
+	options.Cumulative = true
+	chart := DeathsPerMillion([]*series.Data{a, b, c}, options)
+	if len(chart.Datasets) != 2 || len(chart.Labels) != 4 || chart.Labels[0] != "Day 1" {
+		t.Fatalf("per million: wrong chart got:%v labels:%v", chart.Datasets, chart.Labels)
+	}
+	if !reflect.DeepEqual(chart.Datasets[0].Data, []float64{1, 3, 6}) || !reflect.DeepEqual(chart.Datasets[1].Data, []float64{1, 2, 3, 4}) {
+		t.Errorf("per million: wrong values got:%v %v", chart.Datasets[0].Data, chart.Datasets[1].Data)
+	}
+	if chart = DeathsPerMillion([]*series.Data{a}, DefaultOptions()); chart.Title != "Daily deaths per million from first death" {
+		t.Errorf("per million: wrong daily title got:%s", chart.Title)
+	}
+}
+
+func TestSmooth(t *testing.T) {
+	s := &series.Data{}
+	daily := make([]int, 30)
+	for i := range daily {
+		daily[i] = 10
+	}
+	s.SetData(time.Date(2020, 1, 22, 0, 0, 0, 0, time.UTC), series.DataDeaths, series.CumulativeFromDaily(daily, 0))
+
+	options, _ := ParseOptions(url.Values{"smooth": {"loess"}}, DefaultOptions())
+	if options.Smooth != "loess" {
+		t.Fatalf("smooth: options not parsed got:%v", options)
+	}
+	c := New(s, series.DataDeaths, options)
+	if len(c.Datasets) != 2 || c.Datasets[1].Label != "loess smoothed" || len(c.Datasets[1].Data) != 30 || math.Abs(c.Datasets[1].Data[29]-10) > 1e-9 {
+		t.Errorf("smooth: wrong smoothed dataset got:%v", c.Datasets)
+	}
+
+	if options, _ = ParseOptions(url.Values{"smooth": {"spline"}}, options); options.Smooth != "loess" {
+		t.Errorf("smooth: unknown smoother parsed got:%v", options)
+	}
+	decoded, err := DecodeOptions(options.Encode())
+	if err != nil || decoded != options {
+		t.Errorf("smooth: options not decoded got:%v", decoded)
+	}
+}
@@ -0,0 +1,101 @@
+package charts
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/kennygrant/coronavirus/series"
+)
+
+// per100kSuffix marks choropleth metrics normalised by population
+const per100kSuffix = "_per_100k"
+
+// Choropleth maps areas keyed by ISO code to a colour bucket for one metric
+type Choropleth struct {
+	Title   string                    `json:"title"`
+	Metric  string                    `json:"metric"`
+	Buckets []MapBucket               `json:"buckets"`
+	Areas   map[string]ChoroplethArea `json:"areas"`
+}
+
+// MapBucket is one colour bucket, values from Min up to the next bucket are shown in Color
+type MapBucket struct {
+	Min   float64 `json:"min"`
+	Color string  `json:"color"`
+}
+
+// ChoroplethArea holds the value and bucket for one area on the map
+type ChoroplethArea struct {
+	Code      string  `json:"code"`
+	Country   string  `json:"country"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Value     float64 `json:"value"`
+	Bucket    int     `json:"bucket"`
+	Color     string  `json:"color"`
+}
+
+// NewChoropleth returns choropleth data for the country series given
+// metric is a data kind name like deaths, optionally suffixed with _per_100k
+// buckets are split at quantiles of the non-zero values as values vary widely between countries
+// areas without an ISO code, or without population for per 100k metrics, are omitted
+func NewChoropleth(slice []*series.Data, metric string) (*Choropleth, error) {
+	name := strings.TrimSuffix(metric, per100kSuffix)
+	perCapita := name != metric
+	dataKind := series.DataKindFromName(name)
+	if dataKind == series.DataNone {
+		return nil, fmt.Errorf("charts: invalid choropleth metric:%s", metric)
+	}
+
+	c := &Choropleth{
+		Title:  fmt.Sprintf("Total %s", name),
+		Metric: metric,
+		Areas:  make(map[string]ChoroplethArea, len(slice)),
+	}
+	if perCapita {
+		c.Title += per100kTitle
+	}
+
+	var values []float64
+	for _, s := range slice {
+		code := s.CountryCode()
+		if code == "" || (perCapita && s.Population == 0) {
+			continue
+		}
+		v := float64(s.LastDay().Value(dataKind))
+		if perCapita {
+			v = s.Per100k(v)
+		}
+		c.Areas[code] = ChoroplethArea{
+			Code:      code,
+			Country:   s.Country,
+			Latitude:  s.Latitude,
+			Longitude: s.Longitude,
+			Value:     v,
+		}
+		if v > 0 {
+			values = append(values, v)
+		}
+	}
+
+	// Bucket 0 is for zero values, others start at quantiles of the remaining values
+	sort.Float64s(values)
+	count := len(heatmapColors) - 1
+	c.Buckets = append(c.Buckets, MapBucket{Min: 0, Color: heatmapColors[0]})
+	for i := 0; i < count && len(values) > 0; i++ {
+		c.Buckets = append(c.Buckets, MapBucket{Min: values[len(values)*i/count], Color: heatmapColors[i+1]})
+	}
+
+	for code, area := range c.Areas {
+		for b, bucket := range c.Buckets {
+			if area.Value >= bucket.Min && (b > 0 || area.Value <= 0) {
+				area.Bucket = b
+			}
+		}
+		area.Color = c.Buckets[area.Bucket].Color
+		c.Areas[code] = area
+	}
+
+	return c, nil
+}
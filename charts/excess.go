@@ -0,0 +1,44 @@
+package charts
+
+import (
+	"fmt"
+
+	"github.com/kennygrant/coronavirus/series"
+)
+
+// excessColor is used for excess deaths when compared with reported deaths
+const excessColor = "#999999"
+
+// Excess returns a line chart comparing reported deaths with excess deaths from all-cause mortality
+// daily excess deaths are weekly values spread evenly over each week, so are best viewed cumulatively
+func Excess(s *series.Data, options Options) *Chart {
+	chart := &Chart{
+		Title:       fmt.Sprintf("%s reported and excess daily deaths", s.Title()),
+		Type:        "line",
+		Scale:       options.Scale,
+		Labels:      s.Dates(),
+		Annotations: Annotations(s),
+		Provisional: s.ProvisionalCount(),
+	}
+	if options.Cumulative {
+		chart.Title = fmt.Sprintf("%s reported and excess total deaths", s.Title())
+	}
+	if options.PerCapita {
+		chart.Title += per100kTitle
+	}
+
+	chart.Datasets = append(chart.Datasets, Dataset{
+		Label: "Reported deaths",
+		Type:  "line",
+		Color: s.Color,
+		Data:  Values(s, series.DataDeaths, options),
+	}, Dataset{
+		Label: "Excess deaths",
+		Type:  "line",
+		Color: excessColor,
+		Data:  Values(s, series.DataExcess, options),
+	})
+
+	chart.SetTicks()
+	return chart
+}
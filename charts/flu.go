@@ -0,0 +1,44 @@
+package charts
+
+import (
+	"fmt"
+
+	"github.com/kennygrant/coronavirus/series"
+)
+
+// fluColor is used for the flu baseline when compared with reported deaths
+const fluColor = "#6baed6"
+
+// Flu returns a line chart comparing reported deaths with the seasonal flu baseline for the same area
+// the baseline is the mean of weekly flu deaths in past seasons spread evenly over each week, so daily values are best averaged
+func Flu(s *series.Data, options Options) *Chart {
+	chart := &Chart{
+		Title:       fmt.Sprintf("%s reported daily deaths and flu baseline", s.Title()),
+		Type:        "line",
+		Scale:       options.Scale,
+		Labels:      s.Dates(),
+		Annotations: Annotations(s),
+		Provisional: s.ProvisionalCount(),
+	}
+	if options.Cumulative {
+		chart.Title = fmt.Sprintf("%s reported total deaths and flu baseline", s.Title())
+	}
+	if options.PerCapita {
+		chart.Title += per100kTitle
+	}
+
+	chart.Datasets = append(chart.Datasets, Dataset{
+		Label: "Reported deaths",
+		Type:  "line",
+		Color: s.Color,
+		Data:  Values(s, series.DataDeaths, options),
+	}, Dataset{
+		Label: "Flu baseline deaths",
+		Type:  "line",
+		Color: fluColor,
+		Data:  Values(s, series.DataFlu, options),
+	})
+
+	chart.SetTicks()
+	return chart
+}
@@ -0,0 +1,50 @@
+package charts
+
+import (
+	"fmt"
+
+	"github.com/kennygrant/coronavirus/series"
+)
+
+// forecastBandColor is used to shade the uncertainty band of forecasts
+const forecastBandColor = "#dddddd"
+
+// AddForecast adds a dashed forecast line for the series to a chart made by New, for days after the last complete day
+// with datasets for the low and high bounds, the high bound is filled to the low to shade the band
+// labels are extended with the forecast dates, datasets are offset to start on the first forecast day
+func (c *Chart) AddForecast(s *series.Data, dataKind, days int, options Options) {
+	projections := s.Forecast(dataKind, days)
+	if len(projections) == 0 {
+		return
+	}
+
+	offset := s.CompleteCount()
+	for i := len(c.Labels) - offset; i < len(projections); i++ {
+		c.Labels = append(c.Labels, projections[i].Date.Format("Jan 2"))
+	}
+
+	// Daily values are the difference from the previous total, starting from the last complete day
+	previous := float64(s.Days[offset-1].Value(dataKind))
+	value, low, high := make([]float64, len(projections)), make([]float64, len(projections)), make([]float64, len(projections))
+	for i, p := range projections {
+		value[i], low[i], high[i] = p.Value, p.Low, p.High
+	}
+	for _, values := range [][]float64{value, low, high} {
+		last := previous
+		for i, v := range values {
+			if !options.Cumulative {
+				values[i], last = v-last, v
+			}
+			if options.PerCapita {
+				values[i] = s.Per100k(values[i])
+			}
+		}
+	}
+
+	c.Datasets = append(c.Datasets,
+		Dataset{Label: "Forecast low", Type: "line", Color: forecastBandColor, Data: low, Offset: offset},
+		Dataset{Label: "Forecast high", Type: "line", Color: forecastBandColor, Data: high, Offset: offset, Fill: "-1"},
+		Dataset{Label: fmt.Sprintf("%d day forecast", days), Type: "line", Color: s.Color, Data: value, Offset: offset, Dashed: true},
+	)
+	c.SetTicks()
+}
@@ -0,0 +1,91 @@
+package charts
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/kennygrant/coronavirus/series"
+)
+
+// heatmapColors are the colours used for each intensity bucket, from zero to the maximum
+var heatmapColors = []string{"#eeeeee", "#fde0dd", "#fcc5c0", "#fa9fb5", "#f768a1", "#c51b8a", "#7a0177"}
+
+// Heatmap maps each calendar day to an intensity bucket for one metric
+type Heatmap struct {
+	Title   string       `json:"title"`
+	Buckets []HeatBucket `json:"buckets"`
+	Weeks   int          `json:"weeks"`
+	Days    []HeatDay    `json:"days"`
+}
+
+// HeatBucket is one intensity bucket, values from Min up to the next bucket are shown in Color
+type HeatBucket struct {
+	Min   int    `json:"min"`
+	Color string `json:"color"`
+}
+
+// HeatDay is one day on the heatmap calendar
+// Week counts weeks from the first week shown, Weekday is 0 for Monday
+type HeatDay struct {
+	Date    string `json:"date"`
+	Value   int    `json:"value"`
+	Bucket  int    `json:"bucket"`
+	Color   string `json:"color"`
+	Week    int    `json:"week"`
+	Weekday int    `json:"weekday"`
+}
+
+// NewHeatmap returns a calendar heatmap of daily values for the series and data kind given
+// buckets are evenly spaced up to the maximum daily value, zero days have their own bucket
+func NewHeatmap(s *series.Data, dataKind int) *Heatmap {
+	h := &Heatmap{
+		Title: fmt.Sprintf("%s daily %s", s.Title(), series.DataKindName(dataKind)),
+	}
+
+	values := s.DailyValues(dataKind)
+	max := 0
+	for _, v := range values {
+		if v > max {
+			max = v
+		}
+	}
+
+	// Bucket 0 is for zero or negative values, others split the range up to max
+	count := len(heatmapColors) - 1
+	h.Buckets = append(h.Buckets, HeatBucket{Min: 0, Color: heatmapColors[0]})
+	for i := 0; i < count; i++ {
+		h.Buckets = append(h.Buckets, HeatBucket{Min: 1 + max*i/count, Color: heatmapColors[i+1]})
+	}
+
+	if len(values) == 0 {
+		return h
+	}
+
+	// Weeks start on Monday, counting from the week of the first day
+	first := s.FirstDay().Date
+	start := first.AddDate(0, 0, -weekday(first))
+
+	for i, day := range s.Days {
+		hd := HeatDay{
+			Date:    day.DateMachine(),
+			Value:   values[i],
+			Week:    int(day.Date.Sub(start).Hours()/24) / 7,
+			Weekday: weekday(day.Date),
+		}
+		for b, bucket := range h.Buckets {
+			if hd.Value >= bucket.Min && (b > 0 || hd.Value <= 0) {
+				hd.Bucket = b
+			}
+		}
+		hd.Color = h.Buckets[hd.Bucket].Color
+		h.Days = append(h.Days, hd)
+		h.Weeks = hd.Week + 1
+	}
+
+	return h
+}
+
+// weekday returns the day of the week with Monday as 0
+func weekday(t time.Time) int {
+	return (int(t.Weekday()) + 6) % 7
+}
@@ -0,0 +1,53 @@
+package charts
+
+import (
+	"github.com/kennygrant/coronavirus/series"
+)
+
+// DeathsPerMillion returns a line chart comparing deaths per million population of the series given
+// each series is aligned from the day of its first death, labels count days from then
+// totals are shown if options.Cumulative, otherwise the moving average of daily deaths
+// series without population are left out as they cannot be compared per capita
+func DeathsPerMillion(slice []*series.Data, options Options) *Chart {
+	chart := &Chart{
+		Title: "Daily deaths per million from first death",
+		Type:  "line",
+		Scale: options.Scale,
+	}
+	if options.Cumulative {
+		chart.Title = "Total deaths per million from first death"
+	}
+
+	var included series.Slice
+	for _, s := range slice {
+		if s.Population > 0 {
+			included = append(included, s)
+		}
+	}
+	chart.Labels = included.AlignedDaysFrom(series.DataDeaths, 1)
+
+	values := Options{Cumulative: options.Cumulative, Average: !options.Cumulative}
+	for _, s := range included {
+		i := s.AlignedIndex(series.DataDeaths, 1)
+		if i < 0 {
+			continue
+		}
+
+		// Align values after transforming, ignoring today's incomplete data, values are copied as they are cached
+		aligned := Values(s, series.DataDeaths, values)
+		data := make([]float64, len(aligned)-1-i)
+		for j, v := range aligned[i : len(aligned)-1] {
+			data[j] = s.PerMillion(v)
+		}
+		chart.Datasets = append(chart.Datasets, Dataset{
+			Label: s.Title(),
+			Type:  "line",
+			Color: s.Color,
+			Data:  data,
+		})
+		chart.Annotations = append(chart.Annotations, seriesAnnotations(s, i, len(chart.Labels))...)
+	}
+
+	chart.SetTicks()
+	return chart
+}
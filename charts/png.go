@@ -0,0 +1,264 @@
+package charts
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+	"strconv"
+)
+
+// Margins around the plot area in pixels, labels are drawn in the right margin
+const (
+	marginTop    = 10
+	marginLeft   = 10
+	marginBottom = 10
+	marginRight  = 70
+)
+
+var (
+	backgroundColor = color.RGBA{255, 255, 255, 255}
+	gridColor       = color.RGBA{230, 230, 230, 255}
+	labelColor      = color.RGBA{120, 120, 120, 255}
+	defaultColor    = color.RGBA{100, 100, 100, 255}
+)
+
+// RenderPNG renders the chart as a png image of width x height pixels to w, see Render
+func (c *Chart) RenderPNG(w io.Writer, width, height int) error {
+	img, err := c.Render(width, height)
+	if err != nil {
+		return err
+	}
+	return png.Encode(w, img)
+}
+
+// Render draws the chart on an image of width x height pixels
+// text is limited to tick labels drawn with a small built in font
+func (c *Chart) Render(width, height int) (*image.RGBA, error) {
+	if width <= marginLeft+marginRight || height <= marginTop+marginBottom {
+		return nil, fmt.Errorf("charts: image size too small:%dx%d", width, height)
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	fill(img, img.Bounds(), backgroundColor)
+
+	plot := image.Rect(marginLeft, marginTop, width-marginRight, height-marginBottom)
+
+	// Use generated ticks to set the top of the axis
+	ticks := c.Ticks
+	if len(ticks) == 0 {
+		c.SetTicks()
+		ticks = c.Ticks
+	}
+	top := ticks[len(ticks)-1]
+
+	// Draw grid lines and labels for each tick
+	for _, t := range ticks {
+		y := c.y(plot, t, top)
+		fill(img, image.Rect(plot.Min.X, y, plot.Max.X, y+1), gridColor)
+		drawText(img, plot.Max.X+6, y-3, formatTick(t), labelColor)
+	}
+
+	// Draw bars first, then lines over them
+	for _, d := range c.Datasets {
+		if d.Type == "bar" {
+			c.drawBars(img, plot, d, top)
+		}
+	}
+	for _, d := range c.Datasets {
+		if d.Type != "bar" {
+			c.drawLine(img, plot, d, top)
+		}
+	}
+
+	// Draw annotations as dashed vertical lines over the data
+	for _, a := range c.Annotations {
+		c.drawAnnotation(img, plot, a)
+	}
+
+	return img, nil
+}
+
+// y returns the y position of value v in the plot for an axis topping out at top
+func (c *Chart) y(plot image.Rectangle, v, top float64) int {
+	var ratio float64
+	if c.Scale == ScaleLogarithmic {
+		if v > 1 && top > 1 {
+			ratio = math.Log10(v) / math.Log10(top)
+		}
+	} else if top > 0 {
+		ratio = v / top
+	}
+	// Negative values (from revisions) are drawn at zero
+	if ratio < 0 {
+		ratio = 0
+	}
+	return plot.Max.Y - int(ratio*float64(plot.Dy()))
+}
+
+// x returns the x position of the centre of index i of count values
+func (c *Chart) x(plot image.Rectangle, i, count int) int {
+	step := float64(plot.Dx()) / float64(count)
+	return plot.Min.X + int(step*float64(i)+step/2)
+}
+
+// drawBars draws a dataset as bars
+func (c *Chart) drawBars(img *image.RGBA, plot image.Rectangle, d Dataset, top float64) {
+	if len(d.Data) == 0 {
+		return
+	}
+
+	col := parseColor(d.Color)
+	half := int(float64(plot.Dx())/float64(len(d.Data))*0.4) + 1
+	for i, v := range d.Data {
+		x := c.x(plot, i, len(d.Data))
+		fill(img, image.Rect(x-half, c.y(plot, v, top), x+half, plot.Max.Y), col)
+	}
+}
+
+// drawLine draws a dataset as a line 2px wide, offset datasets are positioned by label
+func (c *Chart) drawLine(img *image.RGBA, plot image.Rectangle, d Dataset, top float64) {
+	col := parseColor(d.Color)
+	count := len(d.Data)
+	if d.Offset > 0 {
+		count = len(c.Labels)
+	}
+	for i := 1; i < len(d.Data); i++ {
+		// Leave gaps in dashed lines
+		if d.Dashed && i%2 == 0 {
+			continue
+		}
+		x0, y0 := c.x(plot, d.Offset+i-1, count), c.y(plot, d.Data[i-1], top)
+		x1, y1 := c.x(plot, d.Offset+i, count), c.y(plot, d.Data[i], top)
+		drawSegment(img, x0, y0, x1, y1, col)
+		drawSegment(img, x0, y0+1, x1, y1+1, col)
+	}
+}
+
+// drawAnnotation draws an annotation as a dashed vertical line at the annotation index
+// notes are drawn as a marker at the top of the plot
+func (c *Chart) drawAnnotation(img *image.RGBA, plot image.Rectangle, a Annotation) {
+	if a.Index < 0 || a.Index >= len(c.Labels) {
+		return
+	}
+	col := parseColor(a.Color)
+	x := c.x(plot, a.Index, len(c.Labels))
+	if a.Note {
+		fill(img, image.Rect(x-3, plot.Min.Y, x+3, plot.Min.Y+6), col)
+		return
+	}
+	for y := plot.Min.Y; y < plot.Max.Y; y += 8 {
+		fill(img, image.Rect(x, y, x+2, y+4), col)
+	}
+}
+
+// drawSegment draws a line from x0,y0 to x1,y1 using Bresenham's algorithm
+func drawSegment(img *image.RGBA, x0, y0, x1, y1 int, col color.RGBA) {
+	dx := abs(x1 - x0)
+	dy := -abs(y1 - y0)
+	sx, sy := 1, 1
+	if x0 > x1 {
+		sx = -1
+	}
+	if y0 > y1 {
+		sy = -1
+	}
+	e := dx + dy
+	for {
+		img.SetRGBA(x0, y0, col)
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+		e2 := 2 * e
+		if e2 >= dy {
+			e += dy
+			x0 += sx
+		}
+		if e2 <= dx {
+			e += dx
+			y0 += sy
+		}
+	}
+}
+
+// fill fills the rectangle r with col
+func fill(img *image.RGBA, r image.Rectangle, col color.RGBA) {
+	r = r.Intersect(img.Bounds())
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			img.SetRGBA(x, y, col)
+		}
+	}
+}
+
+// parseColor parses a colour in the form #rrggbb, returning a default colour if invalid
+func parseColor(s string) color.RGBA {
+	if len(s) != 7 || s[0] != '#' {
+		return defaultColor
+	}
+	v, err := strconv.ParseUint(s[1:], 16, 32)
+	if err != nil {
+		return defaultColor
+	}
+	return color.RGBA{uint8(v >> 16), uint8(v >> 8), uint8(v), 255}
+}
+
+// formatTick formats a tick value using k and m suffixes
+func formatTick(v float64) string {
+	switch {
+	case v >= 1000000:
+		return strconv.FormatFloat(v/1000000, 'f', -1, 64) + "m"
+	case v >= 1000:
+		return strconv.FormatFloat(v/1000, 'f', -1, 64) + "k"
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// glyphs is a 3x5 pixel font for the characters used in tick labels
+// each row is 3 bits, most significant bit on the left
+var glyphs = map[rune][5]uint8{
+	'0': {7, 5, 5, 5, 7},
+	'1': {2, 6, 2, 2, 7},
+	'2': {7, 1, 7, 4, 7},
+	'3': {7, 1, 7, 1, 7},
+	'4': {5, 5, 7, 1, 1},
+	'5': {7, 4, 7, 1, 7},
+	'6': {7, 4, 7, 5, 7},
+	'7': {7, 1, 1, 1, 1},
+	'8': {7, 5, 7, 5, 7},
+	'9': {7, 5, 7, 1, 7},
+	'.': {0, 0, 0, 0, 2},
+	'-': {0, 0, 7, 0, 0},
+	'k': {4, 5, 6, 5, 5},
+	'm': {0, 0, 7, 7, 5},
+}
+
+// drawText draws s at x,y using our built in font at 2x scale
+// unknown characters are skipped
+func drawText(img *image.RGBA, x, y int, s string, col color.RGBA) {
+	const scale = 2
+	for _, r := range s {
+		glyph, ok := glyphs[r]
+		if ok {
+			for row, bits := range glyph {
+				for bit := 0; bit < 3; bit++ {
+					if bits&(4>>uint(bit)) != 0 {
+						px, py := x+bit*scale, y+row*scale
+						fill(img, image.Rect(px, py, px+scale, py+scale), col)
+					}
+				}
+			}
+		}
+		x += 4 * scale
+	}
+}
+
+// abs returns the absolute value of i
+func abs(i int) int {
+	if i < 0 {
+		return -i
+	}
+	return i
+}
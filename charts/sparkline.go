@@ -0,0 +1,25 @@
+package charts
+
+// sparkBlocks are the unicode blocks used for sparklines, from lowest to highest
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// Sparkline returns a line of unicode blocks, one per value, scaled from zero to the largest value
+// negative values, such as revisions to daily counts, are shown as the lowest block
+func Sparkline(values []int) string {
+	max := 0
+	for _, v := range values {
+		if v > max {
+			max = v
+		}
+	}
+
+	line := make([]rune, len(values))
+	for i, v := range values {
+		level := 0
+		if max > 0 && v > 0 {
+			level = v * (len(sparkBlocks) - 1) / max
+		}
+		line[i] = sparkBlocks[level]
+	}
+	return string(line)
+}
@@ -0,0 +1,60 @@
+package charts
+
+import (
+	"fmt"
+
+	"github.com/kennygrant/coronavirus/series"
+)
+
+// MetricTestsPerCase is the name of the tests per case metric in chart and compare params
+const MetricTestsPerCase = "tests_per_case"
+
+// TestsPerCase returns a line chart of the tests per confirmed case over each week, see series.TestsPerCase
+// only the scale option is used, as the values are ratios
+func TestsPerCase(s *series.Data, options Options) *Chart {
+	chart := &Chart{
+		Title:       fmt.Sprintf("%s tests per case", s.Title()),
+		Type:        "line",
+		Scale:       options.Scale,
+		Labels:      s.Dates(),
+		Annotations: Annotations(s),
+		Provisional: s.ProvisionalCount(),
+	}
+
+	chart.Datasets = append(chart.Datasets, Dataset{
+		Label: chart.Title,
+		Type:  "line",
+		Color: s.Color,
+		Data:  s.TestsPerCase(),
+	})
+
+	chart.SetTicks()
+	return chart
+}
+
+// CompareTestsPerCase returns a line chart comparing the tests per case of the series given
+// all series are aligned by date, using the dates of the first series
+func CompareTestsPerCase(slice []*series.Data, options Options) *Chart {
+	chart := &Chart{
+		Title: "Tests per case",
+		Type:  "line",
+		Scale: options.Scale,
+	}
+	if len(slice) == 0 {
+		return chart
+	}
+	chart.Labels = slice[0].Dates()
+
+	for _, s := range slice {
+		chart.Datasets = append(chart.Datasets, Dataset{
+			Label: s.Title(),
+			Type:  "line",
+			Color: s.Color,
+			Data:  s.TestsPerCase(),
+		})
+		chart.Annotations = append(chart.Annotations, seriesAnnotations(s, 0, len(chart.Labels))...)
+	}
+
+	chart.SetTicks()
+	return chart
+}
@@ -0,0 +1,72 @@
+package charts
+
+import (
+	"math"
+)
+
+// LogTicks returns ticks for a logarithmic axis from 1 up to at least max
+// ticks are at 1, 2 and 5 times each power of 10, or just powers of 10 for large ranges
+func LogTicks(max float64) []float64 {
+	ticks := []float64{}
+	if max < 1 {
+		max = 1
+	}
+
+	// For wide ranges intermediate ticks are too crowded
+	steps := []float64{1, 2, 5}
+	if max > 100000 {
+		steps = []float64{1}
+	}
+
+	for p := 1.0; ; p *= 10 {
+		for _, s := range steps {
+			ticks = append(ticks, p*s)
+			if p*s >= max {
+				return ticks
+			}
+		}
+	}
+}
+
+// LinearTicks returns about count evenly spaced ticks from 0 up to at least max
+// the step between ticks is rounded to 1, 2 or 5 times a power of 10
+func LinearTicks(max float64, count int) []float64 {
+	if count < 1 {
+		count = 1
+	}
+	if max <= 0 {
+		return []float64{0}
+	}
+
+	// Find a round step size near max/count
+	raw := max / float64(count)
+	magnitude := math.Pow(10, math.Floor(math.Log10(raw)))
+	step := magnitude
+	for _, s := range []float64{1, 2, 5, 10} {
+		step = s * magnitude
+		if step >= raw {
+			break
+		}
+	}
+
+	ticks := []float64{0}
+	for v := step; ; v += step {
+		ticks = append(ticks, v)
+		if v >= max {
+			return ticks
+		}
+	}
+}
+
+// maxValue returns the maximum value in all datasets
+func maxValue(datasets []Dataset) float64 {
+	var max float64
+	for _, d := range datasets {
+		for _, v := range d.Data {
+			if v > max {
+				max = v
+			}
+		}
+	}
+	return max
+}
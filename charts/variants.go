@@ -0,0 +1,41 @@
+package charts
+
+import (
+	"fmt"
+
+	"github.com/kennygrant/coronavirus/series"
+)
+
+// variantColors are the colours used for each variant in the order first reported, repeating if there are more variants
+var variantColors = []string{"#4e79a7", "#f28e2b", "#e15759", "#76b7b2", "#59a14f", "#edc948", "#b07aa1", "#ff9da7", "#9c755f", "#bab0ac"}
+
+// Variants returns a stacked area chart of the share of sequenced cases by variant on each day
+// the first variant reported is at the bottom, and each variant shades down to the one below
+func Variants(s *series.Data) *Chart {
+	chart := &Chart{
+		Title:       fmt.Sprintf("%s share of sequenced cases by variant", s.Title()),
+		Type:        "line",
+		Scale:       ScaleLinear,
+		Labels:      s.Dates(),
+		Ticks:       LinearTicks(100, linearTickCount),
+		Annotations: Annotations(s),
+		Stacked:     true,
+		Provisional: s.ProvisionalCount(),
+	}
+
+	for i, variant := range s.Variants() {
+		fill := "-1"
+		if i == 0 {
+			fill = "origin"
+		}
+		chart.Datasets = append(chart.Datasets, Dataset{
+			Label: variant,
+			Type:  "line",
+			Color: variantColors[i%len(variantColors)],
+			Data:  s.VariantShares(variant),
+			Fill:  fill,
+		})
+	}
+
+	return chart
+}
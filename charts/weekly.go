@@ -0,0 +1,43 @@
+package charts
+
+import (
+	"fmt"
+
+	"github.com/kennygrant/coronavirus/series"
+)
+
+// Weekly returns a bar chart of the totals of dataKind for each ISO week in the series
+// only the scale and per capita options are used, provisional weeks are counted from the end of the labels
+func Weekly(s *series.Data, dataKind int, options Options) *Chart {
+	chart := &Chart{
+		Title: fmt.Sprintf("%s weekly %s", s.Title(), series.DataKindName(dataKind)),
+		Type:  "bar",
+		Scale: options.Scale,
+	}
+	if options.PerCapita {
+		chart.Title += per100kTitle
+	}
+
+	weeks := s.Weekly(dataKind)
+	values := make([]float64, len(weeks))
+	for i, w := range weeks {
+		chart.Labels = append(chart.Labels, w.Label())
+		values[i] = float64(w.Value)
+		if options.PerCapita {
+			values[i] = s.Per100k(values[i])
+		}
+		if w.Provisional {
+			chart.Provisional++
+		}
+	}
+
+	chart.Datasets = append(chart.Datasets, Dataset{
+		Label: chart.Title,
+		Type:  chart.Type,
+		Color: s.Color,
+		Data:  values,
+	})
+
+	chart.SetTicks()
+	return chart
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/kennygrant/coronavirus/charts"
+	"github.com/kennygrant/coronavirus/series"
+	"github.com/kennygrant/coronavirus/xlsx"
+)
+
+// maxCompareAreas is the maximum number of areas which may be compared at once
+const maxCompareAreas = 20
+
+// defaultPerMillionAreas is the number of countries with most deaths compared per million if no areas are given
+const defaultPerMillionAreas = 10
+
+// Lags in days tested by default and at most when correlating areas
+const (
+	defaultCorrelationLag = 21
+	maxCorrelationLag     = 60
+)
+
+// Days of growth compared by default and at most either side of the date when correlating with vaccination coverage
+const (
+	defaultVaccinationDays = 28
+	maxVaccinationDays     = 90
+)
+
+// handleCompare shows a chart comparing several areas for one metric
+// params: areas=italy,spain,uk,us/new-york and metric=deaths|deaths_daily|confirmed_daily|tests_per_case etc
+// align=n aligns the series from the day each reached n of the metric, except for tests per case
+// if the path ends in .json the chart spec is returned as json
+func handleCompare(w http.ResponseWriter, r *http.Request) {
+
+	log.Printf("request:%s", r.URL)
+
+	// Read the metric, defaulting to daily deaths
+	metric := param(r, "metric")
+	if metric == "" {
+		metric = "deaths_daily"
+	}
+	daily := strings.HasSuffix(metric, "_daily")
+	dataKind := series.DataKindFromName(strings.TrimSuffix(metric, "_daily"))
+	if dataKind == series.DataNone && metric != charts.MetricTestsPerCase {
+		http.Error(w, "invalid metric", http.StatusBadRequest)
+		return
+	}
+
+	slice := compareAreas(r)
+	if len(slice) == 0 {
+		http.Error(w, "no valid areas to compare", http.StatusBadRequest)
+		return
+	}
+
+	// The metric decides between daily and cumulative values
+	options := chartOptions(w, r)
+	options.Cumulative = !daily
+	var chart *charts.Chart
+	if metric == charts.MetricTestsPerCase {
+		chart = charts.CompareTestsPerCase(slice, options)
+	} else if align := intParam(r, "align"); align > 0 {
+		chart = charts.Aligned(slice, dataKind, align, options)
+	} else {
+		chart = charts.Compare(slice, dataKind, options)
+	}
+
+	if strings.HasSuffix(r.URL.Path, ".json") {
+		renderJSON(w, r, chart)
+		return
+	}
+
+	context := map[string]interface{}{
+		"chart":   chart,
+		"areas":   slice,
+		"metric":  metric,
+		"jsonURL": "/compare.json?" + r.URL.RawQuery,
+		"xlsxURL": "/compare.xlsx?" + r.URL.RawQuery,
+	}
+
+	if development {
+		loadTemplates()
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(200)
+	err := compareTemplate.Execute(w, context)
+	if err != nil {
+		log.Printf("template render error:%s", err)
+	}
+}
+
+// handleCompareXLSX downloads an Excel workbook with a sheet of days for each area compared
+// with daily values, 7 day averages and values per 100k people computed in columns
+// params: areas as for compare, the metric is ignored as every value is included
+func handleCompareXLSX(w http.ResponseWriter, r *http.Request) {
+
+	log.Printf("request:%s", r.URL)
+
+	slice := compareAreas(r)
+	if len(slice) == 0 {
+		http.Error(w, "no valid areas to export", http.StatusBadRequest)
+		return
+	}
+
+	var buf bytes.Buffer
+	err := xlsx.WriteSeries(&buf, slice)
+	if err != nil {
+		log.Printf("xlsx render error:%s", err)
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "covid-"+slice[0].LastDay().DateMachine()+".xlsx"))
+	w.WriteHeader(200)
+	w.Write(buf.Bytes())
+}
+
+// compareAreas returns the series for the areas param, skipping any we don't know
+// params: areas=italy,spain,us/new-york with from=date&to=date or period=n to limit the days
+func compareAreas(r *http.Request) []*series.Data {
+	var slice []*series.Data
+	from, to := parseDateRange(r)
+	period := intParam(r, "period")
+	for _, area := range strings.Split(param(r, "areas"), ",") {
+		if area == "" || len(slice) >= maxCompareAreas {
+			continue
+		}
+		country, province := parseArea(area)
+		s, err := series.FetchSeries(country, province)
+		if err != nil {
+			log.Printf("compare: area not found:%s", area)
+			continue
+		}
+
+		if !from.IsZero() || !to.IsZero() {
+			s = withArchive(s, from).PeriodRange(from, to)
+		} else if period > 0 {
+			s = s.Period(period)
+		}
+		slice = append(slice, s)
+	}
+	return slice
+}
+
+// handleDeathsPerMillion shows a chart comparing deaths per million population of several areas
+// aligned by the days since the first death in each, so areas are compared at the same stage
+// params: areas as for compare, or the countries with most deaths if none, cumulative=0 for daily deaths
+// if the path ends in .json the chart spec is returned as json
+func handleDeathsPerMillion(w http.ResponseWriter, r *http.Request) {
+
+	log.Printf("request:%s", r.URL)
+
+	slice := compareAreas(r)
+	if len(slice) == 0 {
+		slice = series.Countries().Rank(series.DataDeaths, true)
+		if len(slice) > defaultPerMillionAreas {
+			slice = slice[:defaultPerMillionAreas]
+		}
+	}
+
+	// Totals are shown unless daily values are asked for, whatever the options saved
+	options := chartOptions(w, r)
+	options.Cumulative = param(r, "cumulative") != "0"
+	chart := charts.DeathsPerMillion(slice, options)
+
+	if strings.HasSuffix(r.URL.Path, ".json") {
+		renderJSON(w, r, chart)
+		return
+	}
+
+	context := map[string]interface{}{
+		"chart":   chart,
+		"areas":   slice,
+		"metric":  "deaths_per_million",
+		"jsonURL": "/compare/deaths-per-million.json?" + r.URL.RawQuery,
+	}
+
+	if development {
+		loadTemplates()
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(200)
+	err := compareTemplate.Execute(w, context)
+	if err != nil {
+		log.Printf("template render error:%s", err)
+	}
+}
+
+// handleCorrelation serves the lagged correlation of daily values between two areas as json
+// params: areas=spain,france and metric=deaths|confirmed, lag=n is the maximum lag in days tested
+// a positive best lag means the first area leads the second
+func handleCorrelation(w http.ResponseWriter, r *http.Request) {
+
+	log.Printf("request:%s", r.URL)
+
+	dataKind := series.DataKindFromName(param(r, "metric"))
+	if dataKind == series.DataNone {
+		dataKind = series.DataDeaths
+	}
+
+	areas := strings.Split(param(r, "areas"), ",")
+	if len(areas) != 2 {
+		http.Error(w, "two areas are required", http.StatusBadRequest)
+		return
+	}
+	var slice []*series.Data
+	for _, area := range areas {
+		country, province := parseArea(area)
+		s, err := series.FetchSeries(country, province)
+		if err != nil {
+			http.Error(w, "area not found:"+area, http.StatusNotFound)
+			return
+		}
+		slice = append(slice, s)
+	}
+
+	lag := intParam(r, "lag")
+	if lag <= 0 {
+		lag = defaultCorrelationLag
+	} else if lag > maxCorrelationLag {
+		lag = maxCorrelationLag
+	}
+
+	renderJSON(w, r, series.LaggedCorrelation(slice[0], slice[1], dataKind, lag))
+}
+
+// handleVaccination serves the correlation across areas of vaccination coverage with the growth of cases or deaths as json
+// params: metric=confirmed|deaths, date=2021-03-01 for the coverage, days=n compared before and after the date
+// and areas=italy,spain to limit the areas, all countries otherwise, points are returned for a scatter plot
+// the date defaults to the latest with days complete after it
+func handleVaccination(w http.ResponseWriter, r *http.Request) {
+
+	log.Printf("request:%s", r.URL)
+
+	dataKind := series.DataKindFromName(param(r, "metric"))
+	if dataKind == series.DataNone {
+		dataKind = series.DataConfirmed
+	}
+
+	days := intParam(r, "days")
+	if days <= 0 {
+		days = defaultVaccinationDays
+	} else if days > maxVaccinationDays {
+		days = maxVaccinationDays
+	}
+
+	var date time.Time
+	if param(r, "date") != "" {
+		var err error
+		date, err = time.Parse("2006-01-02", param(r, "date"))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid date:%s", param(r, "date")), http.StatusBadRequest)
+			return
+		}
+	} else {
+		global, err := series.FetchSeries("", "")
+		if err != nil || global.CompleteCount() <= days {
+			http.Error(w, "no complete days", http.StatusNotFound)
+			return
+		}
+		date = global.Days[global.CompleteCount()-1-days].Date
+	}
+
+	slice := series.Slice(compareAreas(r))
+	if len(slice) == 0 {
+		slice = series.Countries()
+	}
+
+	renderJSON(w, r, slice.VaccinationCorrelation(dataKind, date, days))
+}
+
+// parseArea parses an area key in the form country or country/province
+func parseArea(area string) (country, province string) {
+	parts := strings.SplitN(strings.TrimSpace(area), "/", 2)
+	country = countryAlias(parts[0])
+	if len(parts) > 1 {
+		province = parts[1]
+	}
+	return country, province
+}
@@ -0,0 +1,681 @@
+// Package config reads server configuration from a TOML file
+// every setting has a default, so the file need only contain settings which differ
+package config
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kennygrant/coronavirus/cron"
+)
+
+// Config holds the configuration of the server
+type Config struct {
+	// Path is the file this configuration was loaded from, blank if defaults were used
+	Path string
+
+	Server  Server
+	Data    Data
+	Refresh Refresh
+	Sources Sources
+	Digest  Digest
+
+	// Groups are region groups of country names by group name, see series.StoreConfig
+	Groups map[string][]string
+
+	// Features are optional features by name, see Feature
+	Features map[string]bool
+
+	// origins records where each setting which is not a default was read from, keyed by table.key
+	origins map[string]string
+}
+
+// Server configures the web server
+type Server struct {
+	// Development serves over http and reloads templates on each request
+	Development bool
+
+	// Listen is the address to serve on, if blank :443 is used or :3000 in development
+	Listen string
+
+	// HTTPListen is the address used for http challenges when requesting certificates
+	HTTPListen string
+
+	// Domains are the domains certificates are requested for
+	Domains []string
+
+	// RateLimit is the number of json requests per minute allowed from each ip, 0 disables limits
+	// RateBurst is the number of requests allowed at once before the limit applies
+	RateLimit int
+	RateBurst int
+
+	// AdminToken is the bearer token required by admin endpoints, if blank they are only served in development
+	AdminToken string
+}
+
+// Data configures where series data is stored
+type Data struct {
+	// Path is the directory containing data files
+	Path string
+
+	// StartDate is the date of the first day in the series file
+	StartDate time.Time
+
+	// Disease is the name of the disease tracked, shown with the metrics if they are set
+	Disease string
+
+	// Metrics names the values counted each day for the disease, they are those of COVID-19 if blank
+	// e.g. deaths,cases,hospitalised for flu, data files must use these names
+	Metrics []string
+
+	// LazyProvinces loads province series on first use to reduce memory
+	LazyProvinces bool
+
+	// SourcePriority lists sources in order of priority when they provide the same value
+	SourcePriority []string
+
+	// Interpolation fills days skipped by sources: none, carry (the day before) or linear
+	Interpolation string
+
+	// RetentionDays is the number of days kept at daily resolution, older days are kept weekly, 0 keeps every day
+	RetentionDays int
+
+	// ArchiveDays is the number of days kept in memory, older days are moved to a compressed archive, 0 keeps every day
+	ArchiveDays int
+
+	// Cache saves the parsed dataset as a compressed cache, loaded at startup while the data files are unchanged
+	Cache bool
+
+	// DiscrepancyPercent is the difference between sources reported as a discrepancy, unless a request sets another
+	DiscrepancyPercent float64
+}
+
+// Refresh configures the schedule of updates from sources
+type Refresh struct {
+	// Enabled schedules updates, they are disabled by default in development
+	Enabled bool
+
+	// Interval is the time between updates of today's data, unless Schedule is set
+	Interval time.Duration
+
+	// Schedule is a cron expression in UTC for updates of today's data, like */15 * * * *
+	// if blank updates are made every Interval
+	Schedule string
+
+	// Retries is the number of times a failed update is retried before waiting for the next update
+	Retries int
+
+	// RetryDelay is the delay before the first retry, which doubles for each retry
+	// a random jitter of up to half the delay either way is added to each
+	RetryDelay time.Duration
+
+	// DailyAt is the UTC time of day at which a day is added to every series, as 15:04:05
+	DailyAt string
+}
+
+// Sources configures the urls data is fetched from
+type Sources struct {
+	JHUCountry string
+	JHUStates  string
+
+	// JHUDailyReport is formatted with the date as 01-02-2006 to find the report for a day
+	JHUDailyReport string
+
+	// UKGov is the daily indicators of the UK government, as geojson
+	UKGov string
+}
+
+// Digest configures the daily digest of changes sent after the first refresh of each day
+type Digest struct {
+	// Areas are the areas listed in the digest as country or country/province names
+	Areas []string
+
+	// Fastest is the number of fastest growing countries listed
+	Fastest int
+}
+
+// Default returns the default configuration
+func Default() *Config {
+	return &Config{
+		Server: Server{
+			HTTPListen: ":80",
+			Domains:    []string{"coronavirus.projectpage.app"},
+			RateLimit:  60,
+			RateBurst:  20,
+		},
+		Data: Data{
+			Path:               "./data",
+			StartDate:          time.Date(2020, 1, 22, 0, 0, 0, 0, time.UTC),
+			Disease:            "COVID-19",
+			Metrics:            []string{},
+			Interpolation:      "none",
+			DiscrepancyPercent: 5,
+		},
+		Refresh: Refresh{
+			Enabled:    true,
+			Interval:   15 * time.Minute,
+			Retries:    3,
+			RetryDelay: time.Minute,
+			DailyAt:    "00:00:01",
+		},
+		Sources: Sources{
+			JHUCountry:     "https://raw.githubusercontent.com/CSSEGISandData/COVID-19/web-data/data/cases_country.csv",
+			JHUStates:      "https://raw.githubusercontent.com/CSSEGISandData/COVID-19/web-data/data/cases_state.csv",
+			JHUDailyReport: "https://raw.githubusercontent.com/CSSEGISandData/COVID-19/master/csse_covid_19_data/csse_covid_19_daily_reports/%s.csv",
+			UKGov:          "https://services1.arcgis.com/0IrmI40n5ZYxTUrV/arcgis/rest/services/DailyIndicators/FeatureServer/0/query?where=TotalUKCases%3E0&objectIds=&time=&resultType=standard&outFields=*&returnIdsOnly=false&returnUniqueIdsOnly=false&returnCountOnly=false&returnDistinctValues=false&cacheHint=false&orderByFields=&groupByFieldsForStatistics=&outStatistics=&having=&resultOffset=&resultRecordCount=&sqlFormat=none&f=pgeojson&token=",
+		},
+		Digest: Digest{
+			Fastest: 5,
+		},
+		Groups:   map[string][]string{},
+		Features: map[string]bool{},
+		origins:  map[string]string{},
+	}
+}
+
+// Load reads the configuration file at p over the defaults
+// a missing file is not an error, the defaults are returned
+func Load(p string) (*Config, error) {
+	c := Default()
+
+	f, err := os.Open(p)
+	if os.IsNotExist(err) {
+		return c, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	tables, err := parseTOML(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s in file:%s", err, p)
+	}
+
+	err = c.read(tables, func(key string) string { return "file " + p })
+	if err != nil {
+		return nil, fmt.Errorf("%s in file:%s", err, p)
+	}
+
+	err = c.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	c.Path = p
+	return c, nil
+}
+
+// envPrefix is the prefix of environment variables which override settings
+const envPrefix = "COVID_"
+
+// envAliases maps environment variables used before config files to the settings they set
+var envAliases = map[string]string{
+	"COVID_LAZY_PROVINCES":      "data.lazy_provinces",
+	"COVID_SOURCE_PRIORITY":     "data.source_priority",
+	"COVID_REBUILD_GLOBAL":      "features.rebuild_global",
+	"COVID_RATE_LIMIT":          "server.rate_limit",
+	"COVID_RATE_BURST":          "server.rate_burst",
+	"COVID_ADMIN_TOKEN":         "server.admin_token",
+	"COVID_DISCREPANCY_PERCENT": "data.discrepancy_percent",
+}
+
+// Overlay sets settings from environment variables given as key=value, as returned by os.Environ
+// variables are named COVID_TABLE_KEY for the key in the table of the config file, for example
+// COVID_SERVER_LISTEN=:8080 or COVID_GROUPS_NORDIC=Sweden,Norway, arrays are comma separated
+// COVID=dev sets server.development, variables for other tables, like COVID_SECRET, are ignored
+// the order of precedence is defaults, then the config file, then the environment
+func (c *Config) Overlay(environ []string) error {
+	tables := make(map[string]map[string]interface{})
+	names := make(map[string]string)
+	set := func(setting, name, value string) {
+		parts := strings.SplitN(setting, ".", 2)
+		if tables[parts[0]] == nil {
+			tables[parts[0]] = make(map[string]interface{})
+		}
+		tables[parts[0]][parts[1]] = value
+		names[setting] = name
+	}
+
+	// Aliases are set first, so that variables named for the setting take precedence
+	var settings [][2]string
+	for _, entry := range environ {
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		name, value := parts[0], parts[1]
+		if name == "COVID" && value == "dev" {
+			set("server.development", name, "true")
+		} else if setting, ok := envAliases[name]; ok {
+			set(setting, name, value)
+		} else if strings.HasPrefix(name, envPrefix) {
+			settings = append(settings, [2]string{name, value})
+		}
+	}
+	for _, entry := range settings {
+		name := strings.TrimPrefix(entry[0], envPrefix)
+		parts := strings.SplitN(strings.ToLower(name), "_", 2)
+		if len(parts) != 2 || !tableNames[parts[0]] {
+			continue
+		}
+		set(parts[0]+"."+parts[1], entry[0], entry[1])
+	}
+
+	err := c.read(tables, func(key string) string { return "env " + names[key] })
+	if err != nil {
+		return err
+	}
+	return c.Validate()
+}
+
+// tableNames are the tables of the config file
+var tableNames = map[string]bool{"server": true, "data": true, "refresh": true, "sources": true, "digest": true, "groups": true, "features": true}
+
+// read sets the configuration from parsed tables, unknown tables and keys are errors
+// origin returns where the setting at table.key was read from
+func (c *Config) read(tables map[string]map[string]interface{}, origin func(key string) string) error {
+	for name, values := range tables {
+		t := &table{name: name, values: values}
+		switch name {
+		case "":
+			if len(values) > 0 {
+				return fmt.Errorf("config: keys must be in a table")
+			}
+		case "server":
+			t.bool("development", &c.Server.Development)
+			t.string("listen", &c.Server.Listen)
+			t.string("http_listen", &c.Server.HTTPListen)
+			t.strings("domains", &c.Server.Domains)
+			t.int("rate_limit", &c.Server.RateLimit)
+			t.int("rate_burst", &c.Server.RateBurst)
+			t.string("admin_token", &c.Server.AdminToken)
+		case "data":
+			t.string("path", &c.Data.Path)
+			t.date("start_date", &c.Data.StartDate)
+			t.string("disease", &c.Data.Disease)
+			t.strings("metrics", &c.Data.Metrics)
+			t.bool("lazy_provinces", &c.Data.LazyProvinces)
+			t.strings("source_priority", &c.Data.SourcePriority)
+			t.string("interpolation", &c.Data.Interpolation)
+			t.int("retention_days", &c.Data.RetentionDays)
+			t.int("archive_days", &c.Data.ArchiveDays)
+			t.bool("cache", &c.Data.Cache)
+			t.float("discrepancy_percent", &c.Data.DiscrepancyPercent)
+		case "refresh":
+			t.bool("enabled", &c.Refresh.Enabled)
+			t.duration("interval", &c.Refresh.Interval)
+			t.string("schedule", &c.Refresh.Schedule)
+			t.int("retries", &c.Refresh.Retries)
+			t.duration("retry_delay", &c.Refresh.RetryDelay)
+			t.string("daily_at", &c.Refresh.DailyAt)
+		case "sources":
+			t.string("jhu_country", &c.Sources.JHUCountry)
+			t.string("jhu_states", &c.Sources.JHUStates)
+			t.string("jhu_daily_report", &c.Sources.JHUDailyReport)
+			t.string("ukgov", &c.Sources.UKGov)
+		case "digest":
+			t.strings("areas", &c.Digest.Areas)
+			t.int("fastest", &c.Digest.Fastest)
+		case "groups":
+			for key := range values {
+				var countries []string
+				t.strings(key, &countries)
+				c.Groups[key] = countries
+			}
+		case "features":
+			for key := range values {
+				var enabled bool
+				t.bool(key, &enabled)
+				c.Features[key] = enabled
+			}
+		default:
+			return fmt.Errorf("config: unknown table:%s", name)
+		}
+		err := t.check()
+		if err != nil {
+			return err
+		}
+		for _, key := range t.used {
+			c.origins[name+"."+key] = origin(name + "." + key)
+		}
+	}
+	return nil
+}
+
+// Validate returns an error if any setting is invalid
+func (c *Config) Validate() error {
+	if c.Data.Path == "" {
+		return fmt.Errorf("config: data path is blank")
+	}
+	if c.Server.RateLimit < 0 || c.Server.RateBurst < 0 {
+		return fmt.Errorf("config: server rate limit and burst must not be negative")
+	}
+	if c.Data.DiscrepancyPercent < 0 {
+		return fmt.Errorf("config: data discrepancy percent must not be negative")
+	}
+	if c.Refresh.Interval <= 0 {
+		return fmt.Errorf("config: refresh interval must be positive")
+	}
+	if c.Refresh.Schedule != "" {
+		_, err := cron.Parse(c.Refresh.Schedule)
+		if err != nil {
+			return fmt.Errorf("config: invalid refresh schedule:%s", err)
+		}
+	}
+	if c.Refresh.Retries < 0 || c.Refresh.RetryDelay <= 0 {
+		return fmt.Errorf("config: refresh retries must not be negative and retry delay must be positive")
+	}
+	if c.Digest.Fastest < 0 {
+		return fmt.Errorf("config: digest fastest must not be negative")
+	}
+	_, err := c.Refresh.DailyTime(time.Now())
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// ListenAddr returns the address to serve on
+func (c *Config) ListenAddr() string {
+	if c.Server.Listen != "" {
+		return c.Server.Listen
+	}
+	if c.Server.Development {
+		return ":3000"
+	}
+	return ":443"
+}
+
+// Feature returns true if the feature with this name is enabled
+func (c *Config) Feature(name string) bool {
+	return c.Features[name]
+}
+
+// FeatureNames returns the names of enabled features in alphabetical order
+func (c *Config) FeatureNames() (names []string) {
+	for name, enabled := range c.Features {
+		if enabled {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Setting is the value of one setting and where it was read from
+type Setting struct {
+	Key    string
+	Value  string
+	Origin string
+}
+
+// String returns the setting in the form table.key = value (origin)
+func (s Setting) String() string {
+	return fmt.Sprintf("%s = %s (%s)", s.Key, s.Value, s.Origin)
+}
+
+// Settings returns every setting with its effective value, in the order of the config file
+// settings not read from the file or the environment have the origin default
+func (c *Config) Settings() []Setting {
+	settings := []Setting{
+		{Key: "server.development", Value: strconv.FormatBool(c.Server.Development)},
+		{Key: "server.listen", Value: c.ListenAddr()},
+		{Key: "server.http_listen", Value: c.Server.HTTPListen},
+		{Key: "server.domains", Value: strings.Join(c.Server.Domains, ",")},
+		{Key: "server.rate_limit", Value: strconv.Itoa(c.Server.RateLimit)},
+		{Key: "server.rate_burst", Value: strconv.Itoa(c.Server.RateBurst)},
+		{Key: "server.admin_token", Value: secret(c.Server.AdminToken)},
+		{Key: "data.path", Value: c.Data.Path},
+		{Key: "data.start_date", Value: c.Data.StartDate.Format("2006-01-02")},
+		{Key: "data.disease", Value: c.Data.Disease},
+		{Key: "data.metrics", Value: strings.Join(c.Data.Metrics, ",")},
+		{Key: "data.lazy_provinces", Value: strconv.FormatBool(c.Data.LazyProvinces)},
+		{Key: "data.source_priority", Value: strings.Join(c.Data.SourcePriority, ",")},
+		{Key: "data.interpolation", Value: c.Data.Interpolation},
+		{Key: "data.retention_days", Value: strconv.Itoa(c.Data.RetentionDays)},
+		{Key: "data.archive_days", Value: strconv.Itoa(c.Data.ArchiveDays)},
+		{Key: "data.cache", Value: strconv.FormatBool(c.Data.Cache)},
+		{Key: "data.discrepancy_percent", Value: strconv.FormatFloat(c.Data.DiscrepancyPercent, 'f', -1, 64)},
+		{Key: "refresh.enabled", Value: strconv.FormatBool(c.Refresh.Enabled)},
+		{Key: "refresh.interval", Value: c.Refresh.Interval.String()},
+		{Key: "refresh.schedule", Value: c.Refresh.Schedule},
+		{Key: "refresh.retries", Value: strconv.Itoa(c.Refresh.Retries)},
+		{Key: "refresh.retry_delay", Value: c.Refresh.RetryDelay.String()},
+		{Key: "refresh.daily_at", Value: c.Refresh.DailyAt},
+		{Key: "sources.jhu_country", Value: c.Sources.JHUCountry},
+		{Key: "sources.jhu_states", Value: c.Sources.JHUStates},
+		{Key: "sources.jhu_daily_report", Value: c.Sources.JHUDailyReport},
+		{Key: "sources.ukgov", Value: c.Sources.UKGov},
+		{Key: "digest.areas", Value: strings.Join(c.Digest.Areas, ",")},
+		{Key: "digest.fastest", Value: strconv.Itoa(c.Digest.Fastest)},
+	}
+
+	var groups []string
+	for name := range c.Groups {
+		groups = append(groups, name)
+	}
+	sort.Strings(groups)
+	for _, name := range groups {
+		settings = append(settings, Setting{Key: "groups." + name, Value: strings.Join(c.Groups[name], ",")})
+	}
+
+	var features []string
+	for name := range c.Features {
+		features = append(features, name)
+	}
+	sort.Strings(features)
+	for _, name := range features {
+		settings = append(settings, Setting{Key: "features." + name, Value: strconv.FormatBool(c.Features[name])})
+	}
+
+	for i, setting := range settings {
+		settings[i].Origin = c.origins[setting.Key]
+		if settings[i].Origin == "" {
+			settings[i].Origin = "default"
+		}
+	}
+	return settings
+}
+
+// secret returns the value of a secret setting for display, which is only shown as set or blank
+func secret(value string) string {
+	if value == "" {
+		return ""
+	}
+	return "(set)"
+}
+
+// DailyTime returns the time of the daily update on the UTC date of now
+func (r Refresh) DailyTime(now time.Time) (time.Time, error) {
+	t, err := time.Parse("15:04:05", r.DailyAt)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("config: invalid daily refresh time:%s", r.DailyAt)
+	}
+	now = now.UTC()
+	return time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.UTC), nil
+}
+
+// table reads typed values from one parsed table, recording the first error
+type table struct {
+	name   string
+	values map[string]interface{}
+	used   []string
+	err    error
+}
+
+// value returns the value for key if present, marking it as used
+func (t *table) value(key string) (interface{}, bool) {
+	v, ok := t.values[key]
+	if ok {
+		t.used = append(t.used, key)
+	}
+	return v, ok
+}
+
+// fail records an error for key if none has been recorded
+func (t *table) fail(key, want string) {
+	if t.err == nil {
+		t.err = fmt.Errorf("config: %s.%s must be %s", t.name, key, want)
+	}
+}
+
+func (t *table) string(key string, s *string) {
+	v, ok := t.value(key)
+	if !ok {
+		return
+	}
+	if str, ok := v.(string); ok {
+		*s = str
+		return
+	}
+	t.fail(key, "a string")
+}
+
+func (t *table) bool(key string, b *bool) {
+	v, ok := t.value(key)
+	if !ok {
+		return
+	}
+	if value, ok := v.(bool); ok {
+		*b = value
+		return
+	}
+	// Values from the environment are strings
+	if str, ok := v.(string); ok {
+		value, err := strconv.ParseBool(str)
+		if err == nil {
+			*b = value
+			return
+		}
+	}
+	t.fail(key, "true or false")
+}
+
+func (t *table) int(key string, i *int) {
+	v, ok := t.value(key)
+	if !ok {
+		return
+	}
+	if value, ok := v.(int); ok {
+		*i = value
+		return
+	}
+	// Values from the environment are strings
+	if str, ok := v.(string); ok {
+		value, err := strconv.Atoi(str)
+		if err == nil {
+			*i = value
+			return
+		}
+	}
+	t.fail(key, "an integer")
+}
+
+func (t *table) float(key string, f *float64) {
+	v, ok := t.value(key)
+	if !ok {
+		return
+	}
+	switch value := v.(type) {
+	case float64:
+		*f = value
+		return
+	case int:
+		*f = float64(value)
+		return
+	case string:
+		// Values from the environment are strings
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err == nil {
+			*f = parsed
+			return
+		}
+	}
+	t.fail(key, "a number")
+}
+
+func (t *table) strings(key string, s *[]string) {
+	v, ok := t.value(key)
+	if !ok {
+		return
+	}
+	// Values from the environment are comma separated strings
+	if str, ok := v.(string); ok {
+		var values []string
+		for _, item := range strings.Split(str, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				values = append(values, item)
+			}
+		}
+		*s = values
+		return
+	}
+	items, ok := v.([]interface{})
+	if !ok {
+		t.fail(key, "an array of strings")
+		return
+	}
+	values := make([]string, len(items))
+	for i, item := range items {
+		values[i], ok = item.(string)
+		if !ok {
+			t.fail(key, "an array of strings")
+			return
+		}
+	}
+	*s = values
+}
+
+func (t *table) duration(key string, d *time.Duration) {
+	var s string
+	t.string(key, &s)
+	if s == "" {
+		return
+	}
+	value, err := time.ParseDuration(s)
+	if err != nil {
+		t.fail(key, "a duration like 15m")
+		return
+	}
+	*d = value
+}
+
+func (t *table) date(key string, d *time.Time) {
+	var s string
+	t.string(key, &s)
+	if s == "" {
+		return
+	}
+	value, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		t.fail(key, "a date like 2020-01-22")
+		return
+	}
+	*d = value
+}
+
+// check returns the first error reading values, or an error for any keys which were not read
+func (t *table) check() error {
+	if t.err != nil {
+		return t.err
+	}
+	var unknown []string
+	for key := range t.values {
+		found := false
+		for _, used := range t.used {
+			found = found || used == key
+		}
+		if !found {
+			unknown = append(unknown, key)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("config: unknown keys in %s:%s", t.name, strings.Join(unknown, ","))
+	}
+	return nil
+}
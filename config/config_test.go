@@ -0,0 +1,165 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestExample tests that the example config file lists every setting at its default
+func TestExample(t *testing.T) {
+	c, err := Load("../covid.example.toml")
+	if err != nil {
+		t.Fatalf("config: failed to load example:%s", err)
+	}
+	c.Path = ""
+	c.Groups = map[string][]string{}
+	c.Features = map[string]bool{}
+	c.Data.SourcePriority = nil
+	c.Digest.Areas = nil
+	c.origins = map[string]string{}
+	if !reflect.DeepEqual(c, Default()) {
+		t.Errorf("config: example differs from defaults got:%+v", c)
+	}
+}
+
+// TestLoad tests reading settings over the defaults and rejecting invalid files
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatalf("config: failed to create dir:%s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	c, err := Load(filepath.Join(dir, "missing.toml"))
+	if err != nil || c.Path != "" || c.ListenAddr() != ":443" {
+		t.Fatalf("config: missing file should give defaults err:%v", err)
+	}
+
+	p := filepath.Join(dir, "covid.toml")
+	ioutil.WriteFile(p, []byte(`# comment
+[server]
+development = true # trailing comment
+domains = [
+  "a.example.com", # first
+  "b.example.com",
+]
+
+[data]
+start_date = "2020-03-01"
+
+[refresh]
+interval = "1h"
+schedule = "*/10 * * * *"
+retries = 5
+
+[groups]
+nordic = ["Sweden", "Norway", "Denmark # not a comment"]
+
+[features]
+rebuild_global = true
+`), 0644)
+	c, err = Load(p)
+	if err != nil {
+		t.Fatalf("config: failed to load:%s", err)
+	}
+	if !c.Server.Development || c.ListenAddr() != ":3000" || len(c.Server.Domains) != 2 || c.Server.Domains[1] != "b.example.com" {
+		t.Errorf("config: wrong server got:%+v", c.Server)
+	}
+	if !c.Data.StartDate.Equal(time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC)) || c.Data.Path != "./data" || c.Refresh.Interval != time.Hour || c.Refresh.Schedule != "*/10 * * * *" || c.Refresh.Retries != 5 {
+		t.Errorf("config: wrong data got:%+v refresh:%+v", c.Data, c.Refresh)
+	}
+	if len(c.Groups["nordic"]) != 3 || c.Groups["nordic"][2] != "Denmark # not a comment" || !c.Feature("rebuild_global") || c.Feature("other") {
+		t.Errorf("config: wrong groups or features got:%v %v", c.Groups, c.Features)
+	}
+
+	invalid := []string{
+		"[server]\nlisten = 443\n",
+		"[server]\nlisten\n",
+		"[data]\nbackend = \"csv\"\n",
+		"[data]\npathh = \"x\"\n",
+		"[unknown]\n",
+		"[refresh]\ndaily_at = \"noon\"\n",
+		"[refresh]\nschedule = \"every 10 minutes\"\n",
+		"[refresh]\nretries = -1\n",
+		"[server]\ndomains = [\"a\"\n",
+		"listen = \":80\"\n",
+	}
+	for _, contents := range invalid {
+		ioutil.WriteFile(p, []byte(contents), 0644)
+		_, err = Load(p)
+		if err == nil {
+			t.Errorf("config: loaded invalid file:%s", strings.Replace(contents, "\n", "\\n", -1))
+		}
+	}
+}
+
+// TestOverlay tests environment variables taking precedence over the config file
+func TestOverlay(t *testing.T) {
+	c := Default()
+	c.Server.Listen = ":8000"
+	c.origins["server.listen"] = "file covid.toml"
+
+	err := c.Overlay([]string{
+		"COVID=dev",
+		"COVID_SERVER_LISTEN=:8080",
+		"COVID_SERVER_DOMAINS=a.example.com, b.example.com",
+		"COVID_DATA_LAZY_PROVINCES=1",
+		"COVID_SOURCE_PRIORITY=jhu,ukgov",
+		"COVID_DATA_SOURCE_PRIORITY=ukgov,jhu",
+		"COVID_REFRESH_INTERVAL=5m",
+		"COVID_GROUPS_NORDIC=Sweden,Norway",
+		"COVID_FEATURES_REBUILD_GLOBAL=true",
+		"COVID_RATE_LIMIT=0",
+		"COVID_SERVER_RATE_BURST=5",
+		"COVID_ADMIN_TOKEN=secret",
+		"COVID_DISCREPANCY_PERCENT=12.5",
+		"COVID_SECRET=ignored",
+		"PATH=/bin",
+	})
+	if err != nil {
+		t.Fatalf("config: failed to overlay:%s", err)
+	}
+	if !c.Server.Development || c.ListenAddr() != ":8080" || len(c.Server.Domains) != 2 || c.Server.Domains[1] != "b.example.com" {
+		t.Errorf("config: wrong server got:%+v", c.Server)
+	}
+	if !c.Data.LazyProvinces || c.Data.SourcePriority[0] != "ukgov" || c.Refresh.Interval != 5*time.Minute {
+		t.Errorf("config: wrong data got:%+v refresh:%+v", c.Data, c.Refresh)
+	}
+	if c.Server.RateLimit != 0 || c.Server.RateBurst != 5 || c.Server.AdminToken != "secret" || c.Data.DiscrepancyPercent != 12.5 {
+		t.Errorf("config: wrong limits got:%+v discrepancy:%v", c.Server, c.Data.DiscrepancyPercent)
+	}
+	if len(c.Groups["nordic"]) != 2 || !c.Feature("rebuild_global") {
+		t.Errorf("config: wrong groups or features got:%v %v", c.Groups, c.Features)
+	}
+
+	// Secrets are not shown in settings, which are logged at startup
+	origins := make(map[string]string)
+	for _, setting := range c.Settings() {
+		origins[setting.Key] = setting.Origin
+		if setting.Key == "server.admin_token" && setting.Value != "(set)" {
+			t.Errorf("config: admin token shown got:%s", setting.Value)
+		}
+	}
+	if origins["server.listen"] != "env COVID_SERVER_LISTEN" || origins["server.development"] != "env COVID" || origins["server.rate_limit"] != "env COVID_RATE_LIMIT" || origins["data.path"] != "default" {
+		t.Errorf("config: wrong origins got:%v", origins)
+	}
+
+	invalid := []string{
+		"COVID_SERVER_LISTENN=:80",
+		"COVID_DATA_LAZY_PROVINCES=maybe",
+		"COVID_REFRESH_INTERVAL=-1m",
+		"COVID_RATE_BURST=-1",
+		"COVID_DATA_DISCREPANCY_PERCENT=lots",
+	}
+	for _, entry := range invalid {
+		err = Default().Overlay([]string{entry})
+		if err == nil {
+			t.Errorf("config: overlaid invalid variable:%s", entry)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// parseTOML parses the subset of TOML used by config files into values keyed by table then key
+// supported values are strings, integers, floats, booleans and arrays of these, keys outside
+// any table are in the table "", inline tables, dotted keys and dates are not supported
+func parseTOML(r io.Reader) (map[string]map[string]interface{}, error) {
+	tables := map[string]map[string]interface{}{"": {}}
+	table := ""
+
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(stripComment(scanner.Text()))
+		if text == "" {
+			continue
+		}
+
+		// Table headers select the table for the keys following them
+		if strings.HasPrefix(text, "[") {
+			if !strings.HasSuffix(text, "]") || strings.HasPrefix(text, "[[") {
+				return nil, fmt.Errorf("config: invalid table at line:%d", line)
+			}
+			table = strings.TrimSpace(text[1 : len(text)-1])
+			if _, ok := tables[table]; ok && table != "" {
+				return nil, fmt.Errorf("config: duplicate table:%s at line:%d", table, line)
+			}
+			tables[table] = make(map[string]interface{})
+			continue
+		}
+
+		parts := strings.SplitN(text, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("config: expected key = value at line:%d", line)
+		}
+		key := unquote(strings.TrimSpace(parts[0]))
+		raw := strings.TrimSpace(parts[1])
+		if key == "" {
+			return nil, fmt.Errorf("config: missing key at line:%d", line)
+		}
+
+		// Arrays may continue over several lines until the closing bracket
+		for strings.HasPrefix(raw, "[") && !arrayClosed(raw) {
+			if !scanner.Scan() {
+				return nil, fmt.Errorf("config: unterminated array for key:%s", key)
+			}
+			line++
+			raw += " " + strings.TrimSpace(stripComment(scanner.Text()))
+		}
+
+		value, err := parseValue(raw)
+		if err != nil {
+			return nil, fmt.Errorf("config: %s for key:%s at line:%d", err, key, line)
+		}
+		if _, ok := tables[table][key]; ok {
+			return nil, fmt.Errorf("config: duplicate key:%s at line:%d", key, line)
+		}
+		tables[table][key] = value
+	}
+
+	return tables, scanner.Err()
+}
+
+// parseValue parses a single value, which may be an array
+func parseValue(raw string) (interface{}, error) {
+	switch {
+	case raw == "":
+		return nil, fmt.Errorf("missing value")
+	case raw == "true":
+		return true, nil
+	case raw == "false":
+		return false, nil
+	case raw[0] == '"' || raw[0] == '\'':
+		if len(raw) < 2 || raw[len(raw)-1] != raw[0] {
+			return nil, fmt.Errorf("unterminated string")
+		}
+		if raw[0] == '\'' {
+			return raw[1 : len(raw)-1], nil
+		}
+		return strconv.Unquote(raw)
+	case raw[0] == '[':
+		var values []interface{}
+		for _, item := range splitArray(raw[1 : len(raw)-1]) {
+			v, err := parseValue(item)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, v)
+		}
+		return values, nil
+	}
+
+	number := strings.Replace(raw, "_", "", -1)
+	if i, err := strconv.ParseInt(number, 10, 64); err == nil {
+		return int(i), nil
+	}
+	if f, err := strconv.ParseFloat(number, 64); err == nil {
+		return f, nil
+	}
+	return nil, fmt.Errorf("invalid value:%s", raw)
+}
+
+// splitArray splits the items of an array on commas outside strings, dropping a trailing comma
+func splitArray(s string) (items []string) {
+	var quote byte
+	start := 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == ',':
+			items = append(items, strings.TrimSpace(s[start:i]))
+			start = i + 1
+		}
+	}
+	if last := strings.TrimSpace(s[start:]); last != "" {
+		items = append(items, last)
+	}
+	return items
+}
+
+// arrayClosed returns true if the brackets of an array value are balanced outside strings
+func arrayClosed(s string) bool {
+	var quote byte
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+		}
+	}
+	return depth == 0
+}
+
+// stripComment removes a comment starting with # outside strings from the line
+func stripComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// unquote removes quotes from a quoted key
+func unquote(key string) string {
+	if len(key) >= 2 && (key[0] == '"' || key[0] == '\'') && key[len(key)-1] == key[0] {
+		return key[1 : len(key)-1]
+	}
+	return key
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/kennygrant/coronavirus/charts"
+)
+
+// chartOptionsCookie is the name of the cookie used to store chart options
+const chartOptionsCookie = "chart_options"
+
+// cookieSecret is used to sign cookies, set from COVID_SECRET or generated on start
+var cookieSecret []byte
+
+// setupCookieSecret reads the cookie secret from the environment
+// if none is set a random secret is used, so cookies will not survive restarts
+func setupCookieSecret() {
+	secret := os.Getenv("COVID_SECRET")
+	if secret != "" {
+		cookieSecret = []byte(secret)
+		return
+	}
+
+	log.Printf("server: COVID_SECRET not set, using random cookie secret")
+	cookieSecret = make([]byte, 32)
+	_, err := rand.Read(cookieSecret)
+	if err != nil {
+		log.Fatalf("server: failed to generate cookie secret:%s", err)
+	}
+}
+
+// signValue returns the value with an hmac signature appended
+func signValue(value string) string {
+	mac := hmac.New(sha256.New, cookieSecret)
+	mac.Write([]byte(value))
+	return base64.RawURLEncoding.EncodeToString([]byte(value)) + "." + hex.EncodeToString(mac.Sum(nil))
+}
+
+// verifyValue checks the signature on a signed value and returns the value
+func verifyValue(signed string) (string, error) {
+	parts := strings.Split(signed, ".")
+	if len(parts) != 2 {
+		return "", fmt.Errorf("cookies: invalid signed value")
+	}
+
+	value, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return "", fmt.Errorf("cookies: invalid signed value:%s", err)
+	}
+
+	signature, err := hex.DecodeString(parts[1])
+	if err != nil {
+		return "", fmt.Errorf("cookies: invalid signature:%s", err)
+	}
+
+	mac := hmac.New(sha256.New, cookieSecret)
+	mac.Write(value)
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return "", fmt.Errorf("cookies: signature mismatch")
+	}
+
+	return string(value), nil
+}
+
+// chartOptions returns the chart options for this request
+// options are read from the signed cookie then overridden by any params,
+// if params are present the cookie is updated so that they persist across pages
+func chartOptions(w http.ResponseWriter, r *http.Request) charts.Options {
+	options := charts.DefaultOptions()
+
+	cookie, err := r.Cookie(chartOptionsCookie)
+	if err == nil {
+		value, err := verifyValue(cookie.Value)
+		if err == nil {
+			options, _ = charts.DecodeOptions(value)
+		}
+	}
+
+	options, ok := charts.ParseOptions(r.URL.Query(), options)
+	if ok {
+		http.SetCookie(w, &http.Cookie{
+			Name:     chartOptionsCookie,
+			Value:    signValue(options.Encode()),
+			Path:     "/",
+			Expires:  time.Now().AddDate(1, 0, 0),
+			HttpOnly: true,
+			Secure:   !development,
+			SameSite: http.SameSiteLaxMode,
+		})
+	}
+
+	return options
+}
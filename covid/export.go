@@ -0,0 +1,172 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kennygrant/coronavirus/parquet"
+	"github.com/kennygrant/coronavirus/series"
+	"github.com/kennygrant/coronavirus/xlsx"
+)
+
+// exportColumns returns the columns of exported csv files, a column for each metric of the dataset after the area
+// they may be imported again by mapping each column to itself, e.g. -map date=date,area_id=area_id,deaths=deaths
+func exportColumns() []string {
+	columns := []string{"date", "area_id", "country", "province"}
+	for _, kind := range series.DataKinds() {
+		columns = append(columns, series.DataKindName(kind))
+	}
+	return columns
+}
+
+// ExportSeries is one area exported as json
+type ExportSeries struct {
+	AreaID   int         `json:"area_id"`
+	Country  string      `json:"country"`
+	Province string      `json:"province"`
+	Code     string      `json:"code"`
+	Days     []ExportDay `json:"days"`
+}
+
+// ExportDay is one day of an area exported as json, values are cumulative
+type ExportDay struct {
+	Date      string `json:"date"`
+	Deaths    int    `json:"deaths"`
+	Confirmed int    `json:"confirmed"`
+	Recovered int    `json:"recovered"`
+	Tested    int    `json:"tested"`
+}
+
+// exportCommand writes the days of selected areas from the local data files as csv, json, an xlsx workbook or parquet
+func exportCommand(args []string) error {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	format := flags.String("format", "csv", "output format, csv, json, xlsx or parquet")
+	areaList := flags.String("areas", "", "comma separated areas as country/province or ISO codes, all areas if blank")
+	from := flags.String("from", "", "first date to export as 2006-01-02")
+	to := flags.String("to", "", "last date to export as 2006-01-02")
+	dataPath := flags.String("data", "./data", "path of the local data files")
+	output := flags.String("o", "", "file to write, standard output if blank")
+
+	_, err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+	if *format != "csv" && *format != "json" && *format != "xlsx" && *format != "parquet" {
+		return fmt.Errorf("usage: covid export [-format csv|json|xlsx|parquet] [-areas a,b] [-from date] [-to date] [-data path] [-o file]")
+	}
+
+	var fromDate, toDate time.Time
+	if *from != "" {
+		fromDate, err = time.Parse("2006-01-02", *from)
+		if err != nil {
+			return fmt.Errorf("invalid from date:%s", *from)
+		}
+	}
+	if *to != "" {
+		toDate, err = time.Parse("2006-01-02", *to)
+		if err != nil {
+			return fmt.Errorf("invalid to date:%s", *to)
+		}
+	}
+
+	err = loadLocal(*dataPath)
+	if err != nil {
+		return err
+	}
+
+	var areas series.Slice
+	if *areaList == "" {
+		areas = series.Areas()
+	} else {
+		for _, area := range strings.Split(*areaList, ",") {
+			s, err := fetchLocal(strings.TrimSpace(area))
+			if err != nil {
+				return err
+			}
+			areas = append(areas, s)
+		}
+	}
+	for i, s := range areas {
+		areas[i] = s.PeriodRange(fromDate, toDate)
+	}
+
+	w := io.Writer(os.Stdout)
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	switch *format {
+	case "json":
+		return exportJSON(w, areas)
+	case "xlsx":
+		return xlsx.WriteSeries(w, areas)
+	case "parquet":
+		return parquet.WriteSeries(w, areas)
+	}
+	return exportCSV(w, areas)
+}
+
+// exportCSV writes a row per day per area with cumulative values, days with no data are omitted
+func exportCSV(w io.Writer, areas series.Slice) error {
+	cw := csv.NewWriter(w)
+	err := cw.Write(exportColumns())
+	if err != nil {
+		return err
+	}
+	for _, s := range areas {
+		for _, day := range s.Days {
+			if day.IsZero() {
+				continue
+			}
+			row := []string{day.DateMachine(), strconv.Itoa(s.ID), s.Country, s.Province}
+			for _, kind := range series.DataKinds() {
+				row = append(row, strconv.Itoa(day.Value(kind)))
+			}
+			err = cw.Write(row)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// exportJSON writes a list of areas, each with every day in the range exported
+func exportJSON(w io.Writer, areas series.Slice) error {
+	export := make([]ExportSeries, len(areas))
+	for i, s := range areas {
+		export[i] = ExportSeries{
+			AreaID:   s.ID,
+			Country:  s.Country,
+			Province: s.Province,
+			Code:     s.CountryCode(),
+			Days:     make([]ExportDay, len(s.Days)),
+		}
+		if s.IsProvince() {
+			export[i].Code = s.SubdivisionCode()
+		}
+		for j, day := range s.Days {
+			export[i].Days[j] = ExportDay{
+				Date:      day.DateMachine(),
+				Deaths:    day.Value(series.DataDeaths),
+				Confirmed: day.Value(series.DataConfirmed),
+				Recovered: day.Value(series.DataRecovered),
+				Tested:    day.Value(series.DataTested),
+			}
+		}
+	}
+	return json.NewEncoder(w).Encode(export)
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/kennygrant/coronavirus/seriesgen"
+)
+
+// generateCommand writes areas and series files of synthetic data to a data directory
+// the server and other commands load these with -data or a data path of that directory
+func generateCommand(args []string) error {
+	flags := flag.NewFlagSet("generate", flag.ExitOnError)
+	areas := flags.Int("areas", 10, "number of countries to generate")
+	days := flags.Int("days", 120, "number of days from the series start date")
+	seed := flags.Int64("seed", 1, "seed for random numbers, the same seed generates the same data")
+	noise := flags.Float64("noise", 0.15, "relative standard deviation of daily counts")
+	weekend := flags.Float64("weekend", 0.4, "fraction of weekend counts reported on Monday")
+
+	positional, err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return fmt.Errorf("usage: covid generate [-areas n] [-days n] [-seed n] [-noise f] [-weekend f] <dir>")
+	}
+
+	slice, err := seriesgen.Generate(seriesgen.Options{
+		Areas:         *areas,
+		Days:          *days,
+		Seed:          *seed,
+		Noise:         *noise,
+		WeekendEffect: *weekend,
+	})
+	if err != nil {
+		return err
+	}
+
+	err = seriesgen.WriteData(positional[0], slice)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("generated %d areas over %d days in %s\n", len(slice)-1, *days, positional[0])
+	return nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+
+	"github.com/kennygrant/coronavirus/series"
+)
+
+// importCommand imports cumulative values, or daily values with -daily, from csv files into the local series file
+// columns are mapped to our fields with -map, see series.ParseImportMapping
+func importCommand(args []string) error {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	mapping := flags.String("map", "", "columns for each field as field=column,... fields: date,area_id,code,country,province,deaths,confirmed,recovered,tested")
+	dateFormat := flags.String("date-format", "2006-01-02", "format of dates in the files, as a go time layout")
+	dataPath := flags.String("data", "./data", "path of the local data files")
+	dryRun := flags.Bool("dry-run", false, "validate and summarise the files without saving")
+	daily := flags.Bool("daily", false, "values are new per day rather than cumulative totals")
+
+	files, err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 || *mapping == "" {
+		return fmt.Errorf("usage: covid import -map field=column,... [-date-format layout] [-daily] [-data path] [-dry-run] <file>...")
+	}
+
+	m, err := series.ParseImportMapping(*mapping, *dateFormat)
+	if err != nil {
+		return err
+	}
+	m.Daily = *daily
+
+	err = loadLocal(*dataPath)
+	if err != nil {
+		return err
+	}
+
+	imported := 0
+	for _, p := range files {
+		summary, err := series.ImportCSV(p, m)
+		if err != nil {
+			return fmt.Errorf("failed to import:%s error:%s", p, err)
+		}
+		fmt.Println(summary)
+		for _, e := range summary.Errors {
+			fmt.Printf("  %s\n", e)
+		}
+		imported += summary.Imported
+	}
+
+	if *dryRun || imported == 0 {
+		fmt.Println("no changes saved")
+		return nil
+	}
+
+	// A running server picks up imported values on its next reload
+	seriesPath := filepath.Join(*dataPath, "series.csv")
+	err = series.Save(seriesPath)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("saved %s\n", seriesPath)
+	return nil
+}
@@ -0,0 +1,119 @@
+// Command covid queries the coronavirus dataset from the terminal
+// data is read from local data files, or from a running server with -server
+//
+//	covid stats italy --last 14
+//	covid stats us/new-york -server https://coronavirus.projectpage.app
+//	covid import -map date=Date,code=ISO,deaths=Deaths regions.csv
+//	covid export -format json -areas italy,DE -from 2020-04-01
+//	covid generate -areas 20 -days 180 ./demo
+//	covid social italy -server https://coronavirus.projectpage.app
+//	covid report -format pdf -out ./reports italy us/new-york
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/kennygrant/coronavirus/series"
+)
+
+// commands maps each subcommand to the function which runs it with the remaining args
+var commands = map[string]func(args []string) error{
+	"stats":    statsCommand,
+	"import":   importCommand,
+	"export":   exportCommand,
+	"top":      topCommand,
+	"generate": generateCommand,
+	"social":   socialCommand,
+	"report":   reportCommand,
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	command, ok := commands[os.Args[1]]
+	if !ok {
+		usage()
+		os.Exit(2)
+	}
+
+	// Progress is not logged so that output is just the results, errors are returned by commands
+	log.SetOutput(ioutil.Discard)
+
+	err := command(os.Args[2:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "covid: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+// usage prints the commands available
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: covid <command> [flags] [args]\n\ncommands:\n")
+	fmt.Fprintf(os.Stderr, "  stats <area>      print totals, daily changes and doubling times for an area\n")
+	fmt.Fprintf(os.Stderr, "  import <file>...  import values from csv files with columns mapped by -map\n")
+	fmt.Fprintf(os.Stderr, "  export            export the days of selected areas as csv or json\n")
+	fmt.Fprintf(os.Stderr, "  top               show an interactive dashboard of countries\n")
+	fmt.Fprintf(os.Stderr, "  generate <dir>    write synthetic data files for tests and demos\n")
+	fmt.Fprintf(os.Stderr, "  social <area>     print a summary of an area short enough for a social post\n")
+	fmt.Fprintf(os.Stderr, "  report [area...]  write pdf or markdown reports for areas, or every country\n")
+}
+
+// parseFlags parses flags from args in any position, so that flags may follow arguments
+// the arguments which are not flags are returned in order
+func parseFlags(flags *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		err := flags.Parse(args)
+		if err != nil {
+			return nil, err
+		}
+		args = flags.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// parseArea splits an area given as country/province as in urls
+// global (or a blank area) is the global series
+func parseArea(area string) (country, province string) {
+	parts := strings.SplitN(strings.Trim(area, "/"), "/", 2)
+	country = parts[0]
+	if len(parts) > 1 {
+		province = parts[1]
+	}
+	if country == "global" {
+		country = ""
+	}
+	return country, province
+}
+
+// loadLocal loads the local dataset at dataPath
+func loadLocal(dataPath string) error {
+	return series.LoadData(dataPath)
+}
+
+// fetchLocal returns the series for area from the local dataset
+// areas may also be given as ISO codes, see series.FetchCode
+func fetchLocal(area string) (*series.Data, error) {
+	country, province := parseArea(area)
+	s, err := series.FetchSeries(country, province)
+	if err == nil {
+		return s, nil
+	}
+	s, codeErr := series.FetchCode(area)
+	if codeErr == nil {
+		return s, nil
+	}
+	return nil, fmt.Errorf("area not found:%s", area)
+}
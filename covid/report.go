@@ -0,0 +1,80 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kennygrant/coronavirus/digest"
+	"github.com/kennygrant/coronavirus/series"
+)
+
+// reportCommand writes a report for each area given, or every country, to files in the output directory
+// as one page PDF situation reports, or Markdown reports linking charts on the server given by -site
+func reportCommand(args []string) error {
+	flags := flag.NewFlagSet("report", flag.ExitOnError)
+	format := flags.String("format", "pdf", "format of the reports, pdf or md")
+	out := flags.String("out", ".", "directory the reports are written to")
+	dataPath := flags.String("data", "./data", "path of the local data files")
+	site := flags.String("site", "https://coronavirus.projectpage.app", "url of the server linked from reports")
+	days := flags.Int("days", digest.ReportDays, "number of recent days listed in markdown reports")
+
+	areas, err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+	if *format != "pdf" && *format != "md" {
+		return fmt.Errorf("usage: covid report [-format pdf|md] [-out dir] [-data path] [-site url] [area...]")
+	}
+
+	err = loadLocal(*dataPath)
+	if err != nil {
+		return err
+	}
+
+	var slice series.Slice
+	for _, area := range areas {
+		s, err := fetchLocal(area)
+		if err != nil {
+			return err
+		}
+		slice = append(slice, s)
+	}
+	if len(areas) == 0 {
+		slice = series.Countries()
+	}
+
+	err = os.MkdirAll(*out, 0755)
+	if err != nil {
+		return err
+	}
+	for _, s := range slice {
+		name := "global"
+		if !s.IsGlobal() {
+			name = strings.Replace(s.Slug(), "/", "-", -1)
+		}
+		err = writeReport(filepath.Join(*out, name+"."+*format), s, *format, *site, *days)
+		if err != nil {
+			return fmt.Errorf("failed to write report for:%s error:%s", s.Title(), err)
+		}
+	}
+	fmt.Printf("wrote %d reports to %s\n", len(slice), *out)
+	return nil
+}
+
+// writeReport writes the report of s in format to the file at p
+func writeReport(p string, s *series.Data, format, site string, days int) error {
+	f, err := os.Create(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if format == "md" {
+		_, err = f.WriteString(digest.Report(s, days, site))
+		return err
+	}
+	return digest.WritePDF(f, s, strings.TrimPrefix(strings.TrimPrefix(site, "https://"), "http://"))
+}
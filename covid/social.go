@@ -0,0 +1,46 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/kennygrant/coronavirus/digest"
+	"github.com/kennygrant/coronavirus/series"
+)
+
+// socialCommand prints a summary of an area short enough for a social post, with sparklines of the last two weeks
+// from the local data files, or from a running server if -server is set, which is also linked from the summary
+func socialCommand(args []string) error {
+	flags := flag.NewFlagSet("social", flag.ExitOnError)
+	dataPath := flags.String("data", "./data", "path of the local data files")
+	server := flags.String("server", "", "url of a running server to query instead of local data")
+	link := flags.String("link", "", "url linked from the summary, the area page on the server by default")
+
+	areas, err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(areas) != 1 {
+		return fmt.Errorf("usage: covid social <area> [-data path] [-server url] [-link url]")
+	}
+
+	var s *series.Data
+	if *server != "" {
+		s, err = fetchRemote(*server, areas[0])
+	} else {
+		err = loadLocal(*dataPath)
+		if err == nil {
+			s, err = fetchLocal(areas[0])
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	if *link == "" && *server != "" {
+		*link = strings.TrimSuffix(*server, "/") + "/" + s.Slug()
+	}
+	fmt.Println(digest.Social(s, *link))
+	return nil
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kennygrant/coronavirus/series"
+)
+
+// defaultLastDays is the number of days shown by stats if not specified
+const defaultLastDays = 7
+
+// statsCommand prints totals, daily changes and doubling times for an area
+// from the local data files, or from a running server if -server is set
+func statsCommand(args []string) error {
+	flags := flag.NewFlagSet("stats", flag.ExitOnError)
+	last := flags.Int("last", defaultLastDays, "number of days to show")
+	dataPath := flags.String("data", "./data", "path of the local data files")
+	server := flags.String("server", "", "url of a running server to query instead of local data")
+
+	areas, err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(areas) != 1 {
+		return fmt.Errorf("usage: covid stats <area> [-last n] [-data path] [-server url]")
+	}
+
+	var s *series.Data
+	if *server != "" {
+		s, err = fetchRemote(*server, areas[0])
+	} else {
+		err = loadLocal(*dataPath)
+		if err == nil {
+			s, err = fetchLocal(areas[0])
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	return printStats(s, *last)
+}
+
+// printStats prints a summary of totals and doubling times for s, then a table of the last days
+// columns are aligned with spaces, and values are printed in full so that scripts may read them
+func printStats(s *series.Data, last int) error {
+	fmt.Printf("%s\n\n", s.Title())
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "Deaths\t%d\tdoubling in %d days\n", s.TotalDeaths(), s.DoubleDeathDays())
+	fmt.Fprintf(w, "Confirmed\t%d\tdoubling in %d days\n", s.TotalConfirmed(), s.DoubleConfirmedDays())
+	fmt.Fprintf(w, "Recovered\t%d\t\n", s.TotalRecovered())
+	fmt.Fprintf(w, "Tested\t%d\t\n", s.TotalTested())
+	err := w.Flush()
+	if err != nil {
+		return err
+	}
+	fmt.Println()
+
+	period := s.Period(last)
+	deathsDaily := period.DeathsDaily()
+	confirmedDaily := period.ConfirmedDaily()
+
+	w = tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "Date\tDeaths\tDaily\tConfirmed\tDaily\n")
+	for i, day := range period.Days {
+		date := day.DateMachine()
+		if day.Provisional {
+			date += "*"
+		}
+		fmt.Fprintf(w, "%s\t%d\t%+d\t%d\t%+d\n", date, day.Value(series.DataDeaths), deathsDaily[i], day.Value(series.DataConfirmed), confirmedDaily[i])
+	}
+	err = w.Flush()
+	if err != nil {
+		return err
+	}
+
+	if period.ProvisionalCount() > 0 {
+		fmt.Printf("\n* provisional, may be revised\n")
+	}
+	return nil
+}
+
+// remoteSeries holds the fields of the json feed for an area used by the cli
+type remoteSeries struct {
+	Country         string    `json:"country"`
+	Province        string    `json:"province"`
+	ProvisionalDays int       `json:"provisionalDays"`
+	Start           time.Time `json:"start"`
+	Deaths          []int     `json:"deaths"`
+	Confirmed       []int     `json:"confirmed"`
+	Recovered       []int     `json:"recovered"`
+	Tested          []int     `json:"tested"`
+}
+
+// fetchRemote returns the full series for area from the json feed of the server at url
+func fetchRemote(url, area string) (*series.Data, error) {
+	path := strings.Trim(area, "/")
+	if path == "" {
+		path = "global"
+	}
+
+	// A negative period requests every day rather than the default period
+	url = fmt.Sprintf("%s/%s.json?period=-1", strings.TrimSuffix(url, "/"), path)
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("area not found:%s status:%d", area, resp.StatusCode)
+	}
+
+	var remote remoteSeries
+	err = json.NewDecoder(resp.Body).Decode(&remote)
+	if err != nil {
+		return nil, fmt.Errorf("invalid json from:%s error:%s", url, err)
+	}
+
+	n := len(remote.Deaths)
+	if len(remote.Confirmed) != n || len(remote.Recovered) != n || len(remote.Tested) != n {
+		return nil, fmt.Errorf("invalid json from:%s columns differ in length", url)
+	}
+
+	s := &series.Data{Country: remote.Country, Province: remote.Province}
+	for i := 0; i < n; i++ {
+		err = s.AddDay(remote.Start.AddDate(0, 0, i), remote.Deaths[i], remote.Confirmed[i], remote.Recovered[i], remote.Tested[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+	if remote.ProvisionalDays > 0 && remote.ProvisionalDays <= n {
+		s.SetProvisional(s.Days[n-remote.ProvisionalDays].Date)
+	}
+
+	return s, nil
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/kennygrant/coronavirus/charts"
+	"github.com/kennygrant/coronavirus/series"
+)
+
+// clearScreen moves the cursor home and clears the terminal
+const clearScreen = "\033[H\033[2J"
+
+// topSorts are the orders available for the country table, keyed by the command which selects them
+var topSorts = map[string]struct {
+	name string
+	less func(a, b *series.Data) bool
+}{
+	"d": {"deaths", func(a, b *series.Data) bool { return a.TotalDeaths() > b.TotalDeaths() }},
+	"c": {"confirmed", func(a, b *series.Data) bool { return a.TotalConfirmed() > b.TotalConfirmed() }},
+	"w": {"deaths this week", func(a, b *series.Data) bool { return lastWeek(a, series.DataDeaths) > lastWeek(b, series.DataDeaths) }},
+	"n": {"name", func(a, b *series.Data) bool { return a.Country < b.Country }},
+}
+
+// dashboard holds the state of the terminal dashboard
+type dashboard struct {
+	dataPath string
+	rows     int
+	days     int
+	sortKey  string
+	areas    series.Slice
+	selected *series.Data
+}
+
+// topCommand runs an interactive dashboard of countries in the terminal
+// commands are read a line at a time, so it works in any terminal without raw mode
+func topCommand(args []string) error {
+	flags := flag.NewFlagSet("top", flag.ExitOnError)
+	dataPath := flags.String("data", "./data", "path of the local data files")
+	rows := flags.Int("n", 20, "number of countries shown")
+	days := flags.Int("days", 28, "number of days shown in sparklines")
+
+	_, err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+
+	d := &dashboard{dataPath: *dataPath, rows: *rows, days: *days, sortKey: "d"}
+	err = d.refresh()
+	if err != nil {
+		return err
+	}
+	return d.run(os.Stdin, os.Stdout)
+}
+
+// run draws the dashboard, then redraws it after each command read from in until q or the end of input
+func (d *dashboard) run(in io.Reader, out io.Writer) error {
+	scanner := bufio.NewScanner(in)
+	for {
+		err := d.draw(out)
+		if err != nil {
+			return err
+		}
+		if !scanner.Scan() {
+			return scanner.Err()
+		}
+
+		command := strings.TrimSpace(scanner.Text())
+		switch {
+		case command == "q":
+			return nil
+		case command == "r":
+			err = d.refresh()
+			if err != nil {
+				return err
+			}
+		case topSorts[command].less != nil:
+			d.sortKey = command
+			d.selected = nil
+			d.sort()
+		case command == "":
+			d.selected = nil
+		default:
+			// Select a row by number to show the detail for that country
+			i, err := strconv.Atoi(command)
+			if err == nil && i > 0 && i <= len(d.areas) {
+				d.selected = d.areas[i-1]
+			}
+		}
+	}
+}
+
+// refresh reloads the countries from the data store and sorts them
+func (d *dashboard) refresh() error {
+	err := loadLocal(d.dataPath)
+	if err != nil {
+		return err
+	}
+	d.areas = series.Countries()
+	d.selected = nil
+	d.sort()
+	return nil
+}
+
+// sort orders the countries by the current sort
+func (d *dashboard) sort() {
+	less := topSorts[d.sortKey].less
+	sort.SliceStable(d.areas, func(i, j int) bool {
+		return less(d.areas[i], d.areas[j])
+	})
+}
+
+// draw clears the terminal and draws the table, or the detail of the selected country
+func (d *dashboard) draw(out io.Writer) error {
+	fmt.Fprint(out, clearScreen)
+	if d.selected != nil {
+		d.drawDetail(out)
+	} else {
+		err := d.drawTable(out)
+		if err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(out, "\n[d]eaths [c]onfirmed [w]eek [n]ame sort, [1-%d] detail, [r]efresh, [q]uit > ", len(d.areas))
+	return nil
+}
+
+// drawTable draws the countries in the current order with sparklines of daily values
+func (d *dashboard) drawTable(out io.Writer) error {
+	fmt.Fprintf(out, "Countries by %s, last %d days\n\n", topSorts[d.sortKey].name, d.days)
+
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(w, "#\tCountry\tDeaths\tWeek\tDaily deaths\tConfirmed\tWeek\tDaily confirmed\t\n")
+	for i, s := range d.areas {
+		if i >= d.rows {
+			break
+		}
+		period := s.Period(d.days)
+		fmt.Fprintf(w, "%d\t%s\t%d\t%d\t%s\t%d\t%d\t%s\t\n", i+1, s.Country,
+			s.TotalDeaths(), lastWeek(s, series.DataDeaths), charts.Sparkline(period.DeathsDaily()),
+			s.TotalConfirmed(), lastWeek(s, series.DataConfirmed), charts.Sparkline(period.ConfirmedDaily()))
+	}
+	return w.Flush()
+}
+
+// drawDetail draws totals and sparklines of daily values for the selected country
+func (d *dashboard) drawDetail(out io.Writer) {
+	s := d.selected
+	period := s.Period(d.days)
+	deaths, confirmed := period.DeathsDaily(), period.ConfirmedDaily()
+
+	fmt.Fprintf(out, "%s, last %d days from %s\n\n", s.Title(), d.days, period.FirstDay().DateMachine())
+	fmt.Fprintf(out, "Deaths     %d (doubling in %d days)\n", s.TotalDeaths(), s.DoubleDeathDays())
+	fmt.Fprintf(out, "  %s  max %d\n\n", charts.Sparkline(deaths), maxValue(deaths))
+	fmt.Fprintf(out, "Confirmed  %d (doubling in %d days)\n", s.TotalConfirmed(), s.DoubleConfirmedDays())
+	fmt.Fprintf(out, "  %s  max %d\n\n", charts.Sparkline(confirmed), maxValue(confirmed))
+	fmt.Fprintf(out, "Trend      deaths %s %s, confirmed %s %s\n", s.Trend(series.DataDeaths).Arrow(), s.Trend(series.DataDeaths), s.Trend(series.DataConfirmed).Arrow(), s.Trend(series.DataConfirmed))
+}
+
+// lastWeek returns the change in dataKind over the last 7 days
+func lastWeek(s *series.Data, dataKind int) int {
+	total := 0
+	for _, v := range s.Period(7).DailyValues(dataKind) {
+		total += v
+	}
+	return total
+}
+
+// maxValue returns the largest of values, or 0 if there are none
+func maxValue(values []int) int {
+	max := 0
+	for _, v := range values {
+		if v > max {
+			max = v
+		}
+	}
+	return max
+}
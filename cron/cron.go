@@ -0,0 +1,165 @@
+// Package cron parses cron expressions and finds the times at which they are due
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// field describes the range of values for one field of an expression
+type field struct {
+	name     string
+	min, max int
+}
+
+// fields are the five fields of an expression in order
+var fields = []field{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+// Schedule is a parsed cron expression, with the values allowed for each field
+type Schedule struct {
+	expr                         string
+	minutes, hours, days, months map[int]bool
+	weekdays                     map[int]bool
+	anyDay, anyWeekday           bool
+}
+
+// Parse parses a standard five field expression: minute hour day-of-month month day-of-week
+// each field is * or a list of values, ranges like 1-5 and steps like */15 or 0-30/10
+// days of the week are 0-6 from Sunday, 7 is also accepted for Sunday
+// as in cron, if both day fields are restricted a time matching either is due
+func Parse(expr string) (*Schedule, error) {
+	parts := strings.Fields(expr)
+	if len(parts) != len(fields) {
+		return nil, fmt.Errorf("cron: expected %d fields in expression:%s", len(fields), expr)
+	}
+
+	values := make([]map[int]bool, len(fields))
+	for i, f := range fields {
+		v, err := parseField(parts[i], f)
+		if err != nil {
+			return nil, fmt.Errorf("cron: %s in expression:%s", err, expr)
+		}
+		values[i] = v
+	}
+
+	// Sunday is both 0 and 7 in the day of week
+	if values[4][7] {
+		values[4][0] = true
+	}
+
+	return &Schedule{
+		expr:       expr,
+		minutes:    values[0],
+		hours:      values[1],
+		days:       values[2],
+		months:     values[3],
+		weekdays:   values[4],
+		anyDay:     parts[2] == "*",
+		anyWeekday: parts[4] == "*",
+	}, nil
+}
+
+// parseField returns the set of values allowed by one field
+func parseField(s string, f field) (map[int]bool, error) {
+	values := make(map[int]bool)
+	for _, item := range strings.Split(s, ",") {
+		step := 1
+		if i := strings.Index(item, "/"); i >= 0 {
+			var err error
+			step, err = strconv.Atoi(item[i+1:])
+			if err != nil || step < 1 {
+				return nil, fmt.Errorf("invalid step in %s:%s", f.name, item)
+			}
+			item = item[:i]
+		}
+
+		start, end := f.min, f.max
+		if item != "*" {
+			bounds := strings.SplitN(item, "-", 2)
+			var err error
+			start, err = strconv.Atoi(bounds[0])
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s:%s", f.name, item)
+			}
+			end = start
+			if len(bounds) == 2 {
+				end, err = strconv.Atoi(bounds[1])
+				if err != nil {
+					return nil, fmt.Errorf("invalid %s:%s", f.name, item)
+				}
+			} else if step > 1 {
+				// A step from a single value runs to the end of the range, as 5/15 in the minutes
+				end = f.max
+			}
+		}
+		if start < f.min || end > f.max || start > end {
+			return nil, fmt.Errorf("%s out of range %d-%d:%s", f.name, f.min, f.max, item)
+		}
+
+		for v := start; v <= end; v += step {
+			values[v] = true
+		}
+	}
+	return values, nil
+}
+
+// String returns the expression this schedule was parsed from
+func (s *Schedule) String() string {
+	return s.expr
+}
+
+// Due returns true if the schedule is due in the minute of t
+func (s *Schedule) Due(t time.Time) bool {
+	return s.minutes[t.Minute()] && s.hours[t.Hour()] && s.dayDue(t)
+}
+
+// dayDue returns true if the schedule is due on the day of t
+func (s *Schedule) dayDue(t time.Time) bool {
+	if !s.months[int(t.Month())] {
+		return false
+	}
+	day, weekday := s.days[t.Day()], s.weekdays[int(t.Weekday())]
+	switch {
+	case s.anyDay && s.anyWeekday:
+		return true
+	case s.anyDay:
+		return weekday
+	case s.anyWeekday:
+		return day
+	}
+	return day || weekday
+}
+
+// maxSearch limits the search for the next time, an expression like 0 0 30 2 * is never due
+const maxSearch = 5 * 366 * 24 * time.Hour
+
+// Next returns the first time the schedule is due after t, in the location of t
+// the zero time is returned if the schedule is never due
+func (s *Schedule) Next(t time.Time) time.Time {
+	next := t.Truncate(time.Minute).Add(time.Minute)
+	limit := next.Add(maxSearch)
+	for next.Before(limit) {
+		// Skip whole days and hours which can't be due rather than checking every minute
+		if !s.dayDue(next) {
+			next = time.Date(next.Year(), next.Month(), next.Day()+1, 0, 0, 0, 0, next.Location())
+			continue
+		}
+		if !s.hours[next.Hour()] {
+			next = next.Truncate(time.Hour).Add(time.Hour)
+			continue
+		}
+		if s.minutes[next.Minute()] {
+			return next
+		}
+		next = next.Add(time.Minute)
+	}
+	return time.Time{}
+}
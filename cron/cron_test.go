@@ -0,0 +1,51 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+// TestNext tests finding the next time expressions are due
+func TestNext(t *testing.T) {
+	from := time.Date(2020, 4, 18, 10, 7, 30, 0, time.UTC) // a Saturday
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2020, 4, 18, 10, 8, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2020, 4, 18, 10, 15, 0, 0, time.UTC)},
+		{"5 */6 * * *", time.Date(2020, 4, 18, 12, 5, 0, 0, time.UTC)},
+		{"0 0 * * *", time.Date(2020, 4, 19, 0, 0, 0, 0, time.UTC)},
+		{"30 9 * * 1-5", time.Date(2020, 4, 20, 9, 30, 0, 0, time.UTC)},
+		{"0 12 1 * *", time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC)},
+		{"0 12 1 * 0", time.Date(2020, 4, 19, 12, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0,20,40 10 * * 7", time.Date(2020, 4, 19, 10, 0, 0, 0, time.UTC)},
+	}
+	for _, test := range tests {
+		s, err := Parse(test.expr)
+		if err != nil {
+			t.Fatalf("cron: failed to parse:%s error:%s", test.expr, err)
+		}
+		got := s.Next(from)
+		if !got.Equal(test.want) {
+			t.Errorf("cron: wrong next for:%s want:%s got:%s", test.expr, test.want, got)
+		}
+		if !s.Due(got) {
+			t.Errorf("cron: next not due for:%s at:%s", test.expr, got)
+		}
+	}
+
+	s, _ := Parse("0 0 30 2 *")
+	if !s.Next(from).IsZero() {
+		t.Errorf("cron: expression never due returned:%s", s.Next(from))
+	}
+
+	invalid := []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "*/0 * * * *", "5-1 * * * *", "a * * * *"}
+	for _, expr := range invalid {
+		_, err := Parse(expr)
+		if err == nil {
+			t.Errorf("cron: parsed invalid expression:%s", expr)
+		}
+	}
+}
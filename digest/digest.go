@@ -0,0 +1,153 @@
+// Package digest writes summaries of the totals and changes in the dataset on one day
+// the same digest is written as plain text or Markdown, for use by email, feeds and social posts
+package digest
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kennygrant/coronavirus/series"
+)
+
+// DefaultFastest is the number of fastest growing areas listed if not configured
+const DefaultFastest = 5
+
+// GrowthDays is the number of days over which growth in confirmed cases is measured
+const GrowthDays = 7
+
+// minConfirmed is the number of confirmed cases an area needs at the start of GrowthDays to be listed as growing
+// below this growth of a few cases is a large percentage
+const minConfirmed = 1000
+
+// Area is the summary of one area in a digest
+type Area struct {
+	Title     string `json:"title"`
+	Deaths    int    `json:"deaths"`
+	Confirmed int    `json:"confirmed"`
+
+	// NewDeaths and NewConfirmed are the changes from the day before
+	NewDeaths    int `json:"new_deaths"`
+	NewConfirmed int `json:"new_confirmed"`
+
+	// Growth is the percentage increase in confirmed cases over GrowthDays
+	Growth float64 `json:"growth"`
+}
+
+// Digest summarises the dataset on one date: global totals, selected areas and the areas growing fastest
+type Digest struct {
+	Date    time.Time `json:"date"`
+	Global  Area      `json:"global"`
+	Areas   []Area    `json:"areas"`
+	Fastest []Area    `json:"fastest"`
+}
+
+// New returns the digest for date, with the global series, the selected areas in order,
+// and the fastest growing of candidates, at most fastest of them
+func New(date time.Time, global *series.Data, selected, candidates series.Slice, fastest int) *Digest {
+	d := &Digest{
+		Date:    date,
+		Global:  summary(global, date),
+		Areas:   []Area{},
+		Fastest: []Area{},
+	}
+	for _, s := range selected {
+		d.Areas = append(d.Areas, summary(s, date))
+	}
+
+	for _, s := range candidates {
+		if s.FetchDate(date.AddDate(0, 0, -GrowthDays), series.DataConfirmed) < minConfirmed {
+			continue
+		}
+		d.Fastest = append(d.Fastest, summary(s, date))
+	}
+	sort.SliceStable(d.Fastest, func(i, j int) bool {
+		return d.Fastest[i].Growth > d.Fastest[j].Growth
+	})
+	if len(d.Fastest) > fastest {
+		d.Fastest = d.Fastest[:fastest]
+	}
+	return d
+}
+
+// summary returns the summary of the series s on date
+func summary(s *series.Data, date time.Time) Area {
+	a := Area{
+		Title:     s.Title(),
+		Deaths:    s.FetchDate(date, series.DataDeaths),
+		Confirmed: s.FetchDate(date, series.DataConfirmed),
+	}
+	yesterday := date.AddDate(0, 0, -1)
+	a.NewDeaths = a.Deaths - s.FetchDate(yesterday, series.DataDeaths)
+	a.NewConfirmed = a.Confirmed - s.FetchDate(yesterday, series.DataConfirmed)
+
+	before := s.FetchDate(date.AddDate(0, 0, -GrowthDays), series.DataConfirmed)
+	if before > 0 {
+		a.Growth = float64(a.Confirmed-before) * 100 / float64(before)
+	}
+	return a
+}
+
+// Title returns the title of the digest
+func (d *Digest) Title() string {
+	return fmt.Sprintf("COVID-19 digest for %s", d.Date.Format("Mon 2 Jan 2006"))
+}
+
+// Formatters for totals, daily changes and growth
+var (
+	total   = series.Formatter{Locale: series.DefaultLocale, Full: true}
+	change  = series.Formatter{Locale: series.DefaultLocale, Full: true, Sign: true}
+	percent = series.Formatter{Locale: series.DefaultLocale, Decimals: 1, Sign: true}
+)
+
+// String returns a line with the totals and changes of this area, like 1,000 confirmed (+100), 10 deaths (+1)
+func (a Area) String() string {
+	return fmt.Sprintf("%s confirmed (%s), %s deaths (%s)",
+		total.Format(a.Confirmed), change.Format(a.NewConfirmed), total.Format(a.Deaths), change.Format(a.NewDeaths))
+}
+
+// growth returns a line with the growth of this area, like +50.0% to 1,500 confirmed
+func (a Area) growth() string {
+	return fmt.Sprintf("%s%% to %s confirmed", percent.FormatFloat(a.Growth), total.Format(a.Confirmed))
+}
+
+// Text returns the digest as plain text, suitable for email or short messages
+func (d *Digest) Text() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n\n", d.Title())
+	fmt.Fprintf(&b, "Global: %s\n", d.Global)
+	if len(d.Areas) > 0 {
+		fmt.Fprintf(&b, "\nSelected areas\n")
+		for _, a := range d.Areas {
+			fmt.Fprintf(&b, "  %s: %s\n", a.Title, a)
+		}
+	}
+	if len(d.Fastest) > 0 {
+		fmt.Fprintf(&b, "\nFastest growing over %d days\n", GrowthDays)
+		for i, a := range d.Fastest {
+			fmt.Fprintf(&b, "  %d. %s: %s\n", i+1, a.Title, a.growth())
+		}
+	}
+	return b.String()
+}
+
+// Markdown returns the digest as Markdown, suitable for feeds and pages
+func (d *Digest) Markdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", d.Title())
+	fmt.Fprintf(&b, "**Global**: %s\n", d.Global)
+	if len(d.Areas) > 0 {
+		fmt.Fprintf(&b, "\n## Selected areas\n\n")
+		for _, a := range d.Areas {
+			fmt.Fprintf(&b, "- **%s**: %s\n", a.Title, a)
+		}
+	}
+	if len(d.Fastest) > 0 {
+		fmt.Fprintf(&b, "\n## Fastest growing over %d days\n\n", GrowthDays)
+		for i, a := range d.Fastest {
+			fmt.Fprintf(&b, "%d. **%s**: %s\n", i+1, a.Title, a.growth())
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,97 @@
+package digest
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/kennygrant/coronavirus/series"
+)
+
+func TestDigest(t *testing.T) {
+	start := time.Date(2020, 1, 22, 0, 0, 0, 0, time.UTC)
+	global := &series.Data{}
+	global.SetData(start, series.DataConfirmed, []int{10000, 11000, 12000, 13000, 14000, 15000, 16000, 17000})
+	global.SetData(start, series.DataDeaths, []int{100, 110, 120, 130, 140, 150, 160, 175})
+	fast := &series.Data{Country: "Fast"}
+	fast.SetData(start, series.DataConfirmed, []int{1000, 1200, 1400, 1600, 1800, 2000, 2500, 3000})
+	slow := &series.Data{Country: "Slow"}
+	slow.SetData(start, series.DataConfirmed, []int{5000, 5100, 5200, 5300, 5400, 5500, 5600, 5700})
+	small := &series.Data{Country: "Small"}
+	small.SetData(start, series.DataConfirmed, []int{10, 20, 40, 80, 160, 320, 640, 1280})
+
+	// Growth is measured over a week, areas with few cases a week ago are not listed
+	date := start.AddDate(0, 0, 7)
+	d := New(date, global, series.Slice{slow}, series.Slice{slow, small, fast}, 5)
+	if d.Global.Confirmed != 17000 || d.Global.NewConfirmed != 1000 || d.Global.NewDeaths != 15 {
+		t.Errorf("digest: wrong global got:%+v", d.Global)
+	}
+	if len(d.Fastest) != 2 || d.Fastest[0].Title != "Fast" || d.Fastest[0].Growth != 200 || d.Fastest[1].Title != "Slow" {
+		t.Errorf("digest: wrong fastest got:%+v", d.Fastest)
+	}
+	if len(New(date, global, nil, series.Slice{slow, small, fast}, 1).Fastest) != 1 {
+		t.Errorf("digest: fastest not limited")
+	}
+
+	text := d.Text()
+	if !strings.HasPrefix(text, "COVID-19 digest for Wed 29 Jan 2020\n") || !strings.Contains(text, "Global: 17,000 confirmed (+1,000), 175 deaths (+15)") || !strings.Contains(text, "  1. Fast: +200.0% to 3,000 confirmed") {
+		t.Errorf("digest: wrong text got:%s", text)
+	}
+	markdown := d.Markdown()
+	if !strings.HasPrefix(markdown, "# COVID-19 digest") || !strings.Contains(markdown, "- **Slow**: 5,700 confirmed (+100), 0 deaths (0)") {
+		t.Errorf("digest: wrong markdown got:%s", markdown)
+	}
+}
+
+func TestSocial(t *testing.T) {
+	values := make([]int, 30)
+	for i := range values {
+		values[i] = i * i * 10
+	}
+	s := &series.Data{Country: "Growing"}
+	s.SetData(time.Date(2020, 1, 22, 0, 0, 0, 0, time.UTC), series.DataDeaths, values)
+	s.SetData(time.Date(2020, 1, 22, 0, 0, 0, 0, time.UTC), series.DataConfirmed, values)
+	s.SetProvisional(s.LastDay().Date)
+
+	// The provisional day is left out, sparklines show the 14 days before it
+	summary := Social(s, "https://example.com/growing")
+	lines := strings.Split(summary, "\n")
+	if len(lines) != 8 || lines[0] != "Growing, 14 days to 19 Feb" || lines[1] != "Deaths 7,840 (+550)" || utf8.RuneCountInString(lines[2]) != SocialDays {
+		t.Errorf("social: wrong summary got:%s", summary)
+	}
+	if lines[5] != "Deaths rising ↑" || lines[7] != "https://example.com/growing" {
+		t.Errorf("social: wrong trend or link got:%s", summary)
+	}
+
+	// Lines which do not fit are left out
+	summary = Social(s, "https://example.com/"+strings.Repeat("x", SocialLength))
+	if strings.Contains(summary, "https") || !strings.HasSuffix(summary, socialTag) || utf8.RuneCountInString(summary) > SocialLength {
+		t.Errorf("social: long link not left out got:%s", summary)
+	}
+}
+
+func TestReport(t *testing.T) {
+	s := &series.Data{Country: "Reported", Population: 1000000}
+	s.SetData(time.Date(2020, 1, 22, 0, 0, 0, 0, time.UTC), series.DataDeaths, []int{1, 2, 4, 8, 16, 32, 64, 128, 256, 512})
+	s.SetData(time.Date(2020, 1, 22, 0, 0, 0, 0, time.UTC), series.DataConfirmed, []int{10, 20, 40, 80, 160, 320, 640, 1280, 2560, 5120})
+	s.SetProvisional(s.LastDay().Date)
+
+	// Days are listed latest first, with the provisional day marked
+	report := Report(s, 3, "https://example.com/")
+	for _, want := range []string{
+		"# Reported COVID-19 report\n",
+		"Data to 31 Jan, 2020 from [example.com](https://example.com/reported).",
+		"| Deaths | 512 | +508 | 1 days |",
+		"- Deaths per million: 512.0",
+		"![Daily deaths](https://example.com/api/v1/chart/reported.png?kind=deaths&cumulative=0&period=112)",
+		"| 2020-01-31* | 512 | +256 | 5,120 | +2,560 |\n| 2020-01-30 | 256 | +128 |",
+	} {
+		if !strings.Contains(report, want) {
+			t.Errorf("report: missing:%s got:%s", want, report)
+		}
+	}
+	if strings.Count(report, "| 2020-01-") != 3 {
+		t.Errorf("report: wrong days listed got:%s", report)
+	}
+}
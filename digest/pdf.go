@@ -0,0 +1,86 @@
+package digest
+
+import (
+	"fmt"
+	"image/color"
+	"io"
+
+	"github.com/kennygrant/coronavirus/charts"
+	"github.com/kennygrant/coronavirus/pdf"
+	"github.com/kennygrant/coronavirus/series"
+)
+
+// Sizes of the charts in PDF reports, in pixels as rendered and points as drawn on the page
+const (
+	pdfChartWidth  = 1030
+	pdfChartHeight = 340
+	pdfChartPoints = 0.5
+)
+
+// Colors used in PDF reports
+var (
+	pdfText  = color.RGBA{51, 51, 51, 255}
+	pdfMuted = color.RGBA{119, 119, 119, 255}
+	pdfRule  = color.RGBA{230, 230, 230, 255}
+)
+
+// WritePDF writes a one page situation report of the area s as a PDF document to w
+// with the key statistics, trends and charts of daily deaths and confirmed cases over reportChartDays
+// site is the name of the server shown in the footer
+func WritePDF(w io.Writer, s *series.Data, site string) error {
+	doc := pdf.New(fmt.Sprintf("%s COVID-19 situation report", s.Title()))
+	page := doc.AddPage()
+	left, y := 40.0, float64(pdf.A4Height-60)
+
+	page.Text(left, y, pdf.HelveticaBold, 22, pdfText, s.Title())
+	y -= 20
+	page.Text(left, y, pdf.Helvetica, 11, pdfMuted, fmt.Sprintf("COVID-19 situation report, data to %s", s.LastDay().DateDisplay()))
+
+	// Key statistics in columns, with trends classified from the recent daily values
+	y -= 36
+	columns := []float64{left, left + 100, left + 200, left + 300, left + 400}
+	for i, heading := range []string{"", "Total", "Last 7 days", "Doubling in", "Trend"} {
+		page.Text(columns[i], y, pdf.Helvetica, 10, pdfMuted, heading)
+	}
+	for _, row := range reportStats(s) {
+		y -= 6
+		page.Rect(left, y, float64(pdf.A4Width)-2*left, 0.5, pdfRule)
+		y -= 14
+		for i, v := range []string{row.name, row.total, row.week, row.doubling, trend(row.trend, false)} {
+			font := pdf.Helvetica
+			if i == 0 {
+				font = pdf.HelveticaBold
+			}
+			page.Text(columns[i], y, font, 11, pdfText, v)
+		}
+	}
+
+	y -= 10
+	for _, fact := range reportFacts(s) {
+		y -= 16
+		page.Text(left, y, pdf.Helvetica, 11, pdfText, fact)
+	}
+
+	// Charts of daily values, with their moving averages
+	period := s.Period(reportChartDays)
+	options := charts.DefaultOptions()
+	for _, chart := range []struct {
+		title    string
+		dataKind int
+	}{
+		{"Daily deaths", series.DataDeaths},
+		{"Daily confirmed cases", series.DataConfirmed},
+	} {
+		img, err := charts.New(period, chart.dataKind, options).Render(pdfChartWidth, pdfChartHeight)
+		if err != nil {
+			return err
+		}
+		y -= 30
+		page.Text(left, y, pdf.HelveticaBold, 12, pdfText, fmt.Sprintf("%s, last %d days", chart.title, reportChartDays))
+		y -= 8 + pdfChartHeight*pdfChartPoints
+		page.Image(left, y, pdfChartWidth*pdfChartPoints, pdfChartHeight*pdfChartPoints, img)
+	}
+
+	page.Text(left, 30, pdf.Helvetica, 8, pdfMuted, fmt.Sprintf("Data from Johns Hopkins University and national sources, generated by %s", site))
+	return doc.Write(w)
+}
@@ -0,0 +1,117 @@
+package digest
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/kennygrant/coronavirus/series"
+)
+
+// ReportDays is the number of recent days listed in reports if not specified
+const ReportDays = 14
+
+// reportChartDays is the number of days shown in the charts linked from reports
+const reportChartDays = 112
+
+// rate formats rates in reports to one decimal place
+var rate = series.Formatter{Locale: series.DefaultLocale, Decimals: 1}
+
+// Report returns a full report of the area s as Markdown, for inclusion in wikis and READMEs
+// with key statistics, links to chart images and a table of the last days
+// base is the url of the server the chart images are fetched from, like https://coronavirus.projectpage.app
+func Report(s *series.Data, days int, base string) string {
+	var b strings.Builder
+	base = strings.TrimSuffix(base, "/")
+	last := s.LastDay()
+
+	fmt.Fprintf(&b, "# %s COVID-19 report\n\n", s.Title())
+	fmt.Fprintf(&b, "Data to %s from [%s](%s/%s).\n", last.DateDisplay(), strings.TrimPrefix(strings.TrimPrefix(base, "https://"), "http://"), base, s.Slug())
+
+	fmt.Fprintf(&b, "\n## Key statistics\n\n")
+	fmt.Fprintf(&b, "| | Total | Last 7 days | Doubling in | Trend |\n")
+	fmt.Fprintf(&b, "|---|---:|---:|---:|---|\n")
+	for _, row := range reportStats(s) {
+		fmt.Fprintf(&b, "| %s | %s | %s | %s | %s |\n", row.name, row.total, row.week, row.doubling, trend(row.trend, true))
+	}
+
+	fmt.Fprintf(&b, "\n")
+	for _, fact := range reportFacts(s) {
+		fmt.Fprintf(&b, "- %s\n", fact)
+	}
+
+	fmt.Fprintf(&b, "\n## Charts\n\n")
+	for _, kind := range []string{"deaths", "confirmed"} {
+		fmt.Fprintf(&b, "![Daily %s](%s/api/v1/chart/%s.png?kind=%s&cumulative=0&period=%d)\n\n", kind, base, s.Slug(), kind, reportChartDays)
+	}
+
+	fmt.Fprintf(&b, "## Last %d days\n\n", days)
+	fmt.Fprintf(&b, "| Date | Deaths | Daily | Confirmed | Daily |\n")
+	fmt.Fprintf(&b, "|---|---:|---:|---:|---:|\n")
+	period := s.Period(days)
+	deaths, confirmed := period.DeathsDaily(), period.ConfirmedDaily()
+	for i := len(period.Days) - 1; i >= 0; i-- {
+		day := period.Days[i]
+		date := day.DateMachine()
+		if day.Provisional {
+			date += "*"
+		}
+		fmt.Fprintf(&b, "| %s | %s | %s | %s | %s |\n", date, total.Format(day.Value(series.DataDeaths)), change.Format(deaths[i]), total.Format(day.Value(series.DataConfirmed)), change.Format(confirmed[i]))
+	}
+	if period.ProvisionalCount() > 0 {
+		fmt.Fprintf(&b, "\n\\* provisional, may be revised\n")
+	}
+	return b.String()
+}
+
+// statRow is one row of the key statistics of a report, for one metric of the dataset
+type statRow struct {
+	name     string
+	total    string
+	week     string
+	doubling string
+	trend    series.Trend
+}
+
+// reportStats returns the key statistics of the area s, the total, change over the last week, doubling time and trend of each data kind
+// rows are labelled with the metrics of the dataset, in order
+func reportStats(s *series.Data) []statRow {
+	last := s.LastDay()
+	var rows []statRow
+	for _, kind := range series.DataKinds() {
+		rows = append(rows, statRow{series.MetricLabel(kind), total.Format(last.Value(kind)), change.Format(week(s, kind)), fmt.Sprintf("%d days", s.DoubleDays(kind)), s.Trend(kind)})
+	}
+	return rows
+}
+
+// reportFacts returns lines of facts about the area s listed under the key statistics of a report
+func reportFacts(s *series.Data) []string {
+	var facts []string
+	if peak := s.HighestPeak(series.DataDeaths); peak != nil {
+		facts = append(facts, fmt.Sprintf("Peak daily deaths: %s on %s", total.Format(peak.Value), peak.DateDisplay()))
+	}
+	facts = append(facts, fmt.Sprintf("Case fatality rate: %s%%", rate.FormatFloat(s.CaseFatalityRate())))
+	if s.Population > 0 {
+		facts = append(facts, fmt.Sprintf("Deaths per million: %s", rate.FormatFloat(s.PerMillion(float64(s.LastDay().Value(series.DataDeaths))))))
+	}
+	return facts
+}
+
+// week returns the change in dataKind over the last 7 days
+func week(s *series.Data, dataKind int) int {
+	sum := 0
+	for _, v := range s.Period(7).DailyValues(dataKind) {
+		sum += v
+	}
+	return sum
+}
+
+// trend returns the trend for display, with its arrow if arrow is true, or blank if there was too little data
+func trend(t series.Trend, arrow bool) string {
+	if t == series.TrendInsufficient {
+		return ""
+	}
+	if arrow {
+		return t.String() + " " + t.Arrow()
+	}
+	return t.String()
+}
@@ -0,0 +1,56 @@
+package digest
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/kennygrant/coronavirus/charts"
+	"github.com/kennygrant/coronavirus/series"
+)
+
+// SocialLength is the maximum length in characters of a social summary, the length of a tweet
+const SocialLength = 280
+
+// SocialDays is the number of days shown in the sparklines of social summaries
+const SocialDays = 14
+
+// socialTag is added to social summaries if it fits
+const socialTag = "#COVID19"
+
+// Social returns a summary of the area s short enough for a social post, with sparklines of
+// daily deaths and confirmed cases over the last SocialDays complete days, for bots posting daily updates
+// the trend, tag and link, which may be blank, are each added if the summary still fits in SocialLength
+func Social(s *series.Data, link string) string {
+	n := s.CompleteCount()
+	if n == 0 {
+		return s.Title()
+	}
+	start := n - SocialDays
+	if start < 0 {
+		start = 0
+	}
+	deaths := s.DailyValues(series.DataDeaths)[start:n]
+	confirmed := s.DailyValues(series.DataConfirmed)[start:n]
+
+	lines := []string{
+		fmt.Sprintf("%s, %d days to %s", s.Title(), n-start, s.Days[n-1].Date.Format("2 Jan")),
+		fmt.Sprintf("Deaths %s (%s)", total.Format(s.Days[n-1].Value(series.DataDeaths)), change.Format(deaths[len(deaths)-1])),
+		charts.Sparkline(deaths),
+		fmt.Sprintf("Cases %s (%s)", total.Format(s.Days[n-1].Value(series.DataConfirmed)), change.Format(confirmed[len(confirmed)-1])),
+		charts.Sparkline(confirmed),
+	}
+	summary := strings.Join(lines, "\n")
+
+	optional := []string{socialTag, link}
+	if t := s.Trend(series.DataDeaths); t != series.TrendInsufficient {
+		optional = append([]string{fmt.Sprintf("Deaths %s %s", strings.ToLower(t.String()), t.Arrow())}, optional...)
+	}
+	for _, line := range optional {
+		if line == "" || utf8.RuneCountInString(summary)+1+utf8.RuneCountInString(line) > SocialLength {
+			continue
+		}
+		summary += "\n" + line
+	}
+	return summary
+}
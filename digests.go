@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/kennygrant/coronavirus/digest"
+	"github.com/kennygrant/coronavirus/notify"
+	"github.com/kennygrant/coronavirus/series"
+)
+
+// buildDigest returns the digest for date of the global series, the areas configured and the fastest growing countries
+// a zero date uses the last day of the global series
+func buildDigest(date time.Time) (*digest.Digest, error) {
+	global, err := series.FetchSeries("", "")
+	if err != nil {
+		return nil, err
+	}
+	if date.IsZero() {
+		date = global.LastDay().Date
+	}
+
+	var selected series.Slice
+	for _, name := range cfg.Digest.Areas {
+		parts := strings.SplitN(name, "/", 2)
+		province := ""
+		if len(parts) == 2 {
+			province = parts[1]
+		}
+		s, err := series.FetchSeries(strings.TrimSpace(parts[0]), strings.TrimSpace(province))
+		if err != nil {
+			log.Printf("digest: area not found:%s", name)
+			continue
+		}
+		selected = append(selected, s)
+	}
+
+	return digest.New(date, global, selected, series.Countries(), cfg.Digest.Fastest), nil
+}
+
+// sendDigest sends the digest for date to transports routed for digests
+func sendDigest(date time.Time) {
+	d, err := buildDigest(date)
+	if err != nil {
+		log.Printf("digest: failed to build digest:%s", err)
+		return
+	}
+	err = notify.Send(notify.Message{Rule: ruleDigest, Title: d.Title(), Body: d.Text()})
+	if err != nil {
+		log.Printf("digest: failed to send digest:%s", err)
+	}
+}
+
+// handleDigest serves the digest of one day as plain text, Markdown or json
+// params: date=2006-01-02 (default the last day), format=text|markdown|json (default text)
+func handleDigest(w http.ResponseWriter, r *http.Request) {
+
+	log.Printf("request:%s", r.URL)
+
+	var date time.Time
+	if param(r, "date") != "" {
+		var err error
+		date, err = time.Parse("2006-01-02", param(r, "date"))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid date:%s", param(r, "date")), http.StatusBadRequest)
+			return
+		}
+	}
+
+	d, err := buildDigest(date)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch param(r, "format") {
+	case "", "text":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(200)
+		w.Write([]byte(d.Text()))
+	case "markdown":
+		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+		w.WriteHeader(200)
+		w.Write([]byte(d.Markdown()))
+	case "json":
+		renderJSON(w, r, d)
+	default:
+		http.Error(w, fmt.Sprintf("invalid format:%s", param(r, "format")), http.StatusBadRequest)
+	}
+}
+
+// handleSocial serves a summary of an area short enough for a social post, with sparklines of the last two weeks
+// the path after /api/v1/social is parsed as for the home page
+func handleSocial(w http.ResponseWriter, r *http.Request) {
+
+	log.Printf("request:social%s", r.URL)
+
+	country, province, _, _ := parseParams(r)
+
+	s, err := series.FetchSeries(country, province)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	if notModified(w, r, s) {
+		return
+	}
+
+	link := ""
+	if len(cfg.Server.Domains) > 0 && !development {
+		link = fmt.Sprintf("https://%s/%s", cfg.Server.Domains[0], s.Slug())
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(200)
+	w.Write([]byte(digest.Social(s, link)))
+}
+
+// handleReport serves a full report of an area as Markdown at /report/{area}.md, for inclusion in wikis and READMEs
+// or a one page situation report as a PDF at /report/{area}.pdf, downloaded from the area page
+// params: days=n the number of recent days listed in Markdown reports (default 14)
+func handleReport(w http.ResponseWriter, r *http.Request) {
+
+	log.Printf("request:report%s", r.URL)
+
+	markdown := strings.HasSuffix(r.URL.Path, ".md")
+	if !markdown && !strings.HasSuffix(r.URL.Path, ".pdf") {
+		http.NotFound(w, r)
+		return
+	}
+
+	country, province, _, _ := parseParams(r)
+
+	s, err := series.FetchSeries(country, province)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	if notModified(w, r, s) {
+		return
+	}
+
+	if !markdown {
+		var buf bytes.Buffer
+		err = digest.WritePDF(&buf, s, r.Host)
+		if err != nil {
+			log.Printf("pdf render error:%s", err)
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", reportName(s)+".pdf"))
+		w.WriteHeader(200)
+		w.Write(buf.Bytes())
+		return
+	}
+
+	days := intParam(r, "days")
+	if days <= 0 {
+		days = digest.ReportDays
+	}
+
+	// Chart images are linked to this server, so reports can be copied elsewhere
+	scheme := "https"
+	if r.TLS == nil {
+		scheme = "http"
+	}
+
+	w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+	w.WriteHeader(200)
+	w.Write([]byte(digest.Report(s, days, scheme+"://"+r.Host)))
+}
+
+// reportName returns the name of report files for the area s, like us-new-york
+func reportName(s *series.Data) string {
+	if s.IsGlobal() {
+		return "global"
+	}
+	return strings.Replace(s.Slug(), "/", "-", -1)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/kennygrant/coronavirus/series"
+)
+
+// FeatureCollection is a GeoJSON feature collection
+type FeatureCollection struct {
+	Type     string    `json:"type"`
+	Features []Feature `json:"features"`
+}
+
+// Feature is a GeoJSON feature for one area
+type Feature struct {
+	Type       string         `json:"type"`
+	ID         int            `json:"id"`
+	Geometry   *Geometry      `json:"geometry"`
+	Properties AreaProperties `json:"properties"`
+}
+
+// Geometry is a GeoJSON point, coordinates are longitude then latitude
+type Geometry struct {
+	Type        string     `json:"type"`
+	Coordinates [2]float64 `json:"coordinates"`
+}
+
+// AreaProperties holds the latest totals for an area
+type AreaProperties struct {
+	Title       string    `json:"title"`
+	Country     string    `json:"country"`
+	Province    string    `json:"province"`
+	Code        string    `json:"code"`
+	Alpha2      string    `json:"alpha2"`
+	Subdivision string    `json:"subdivision,omitempty"`
+	Population  int       `json:"population"`
+	Deaths      int       `json:"deaths"`
+	Confirmed   int       `json:"confirmed"`
+	Recovered   int       `json:"recovered"`
+	Tested      int       `json:"tested"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// handleGeoJSON serves all areas as a GeoJSON feature collection of points
+// areas without coordinates have a null geometry
+func handleGeoJSON(w http.ResponseWriter, r *http.Request) {
+
+	log.Printf("request:%s", r.URL)
+
+	areas := series.Areas()
+	collection := FeatureCollection{
+		Type:     "FeatureCollection",
+		Features: make([]Feature, 0, len(areas)),
+	}
+
+	for _, s := range areas {
+		feature := Feature{
+			Type:       "Feature",
+			ID:         s.ID,
+			Properties: areaProperties(s),
+		}
+		if s.Latitude != 0 || s.Longitude != 0 {
+			feature.Geometry = &Geometry{
+				Type:        "Point",
+				Coordinates: [2]float64{s.Longitude, s.Latitude},
+			}
+		}
+		collection.Features = append(collection.Features, feature)
+	}
+
+	w.Header().Set("Content-Type", "application/geo+json")
+	renderJSON(w, r, collection)
+}
+
+// areaProperties returns the latest totals for the area s
+func areaProperties(s *series.Data) AreaProperties {
+	last := s.LastDay()
+	return AreaProperties{
+		Title:       s.Title(),
+		Country:     s.Country,
+		Province:    s.Province,
+		Code:        s.CountryCode(),
+		Alpha2:      s.CountryAlpha2(),
+		Subdivision: s.SubdivisionCode(),
+		Population:  s.Population,
+		Deaths:      last.Value(series.DataDeaths),
+		Confirmed:   last.Value(series.DataConfirmed),
+		Recovered:   last.Value(series.DataRecovered),
+		Tested:      last.Value(series.DataTested),
+		UpdatedAt:   s.UpdatedAt,
+	}
+}
@@ -0,0 +1,375 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kennygrant/coronavirus/series"
+)
+
+// livePingInterval is the interval between pings to live clients, to keep connections open through proxies
+const livePingInterval = 30 * time.Second
+
+// liveRetry is the delay before event stream clients reconnect
+const liveRetry = 10 * time.Second
+
+// liveBuffer is the number of updates queued for a live client, updates are dropped for clients this far behind
+const liveBuffer = 8
+
+// LiveArea is the latest totals of one area pushed to live clients, with the change from the day before
+type LiveArea struct {
+	AreaID         int       `json:"area_id"`
+	Country        string    `json:"country"`
+	Province       string    `json:"province"`
+	Date           string    `json:"date"`
+	Deaths         int       `json:"deaths"`
+	Confirmed      int       `json:"confirmed"`
+	Recovered      int       `json:"recovered"`
+	Tested         int       `json:"tested"`
+	DeathsDaily    int       `json:"deaths_daily"`
+	ConfirmedDaily int       `json:"confirmed_daily"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// LiveUpdate is pushed to live clients, type is snapshot for the totals on subscribing
+// and refresh for the areas changed by a refresh
+type LiveUpdate struct {
+	Type    string     `json:"type"`
+	Version int        `json:"version"`
+	Areas   []LiveArea `json:"areas"`
+}
+
+// liveArea returns the latest totals of series s
+func liveArea(s *series.Data) LiveArea {
+	a := LiveArea{
+		AreaID:    s.ID,
+		Country:   s.Country,
+		Province:  s.Province,
+		UpdatedAt: s.UpdatedAt,
+	}
+	last := s.LastDay()
+	if last == nil {
+		return a
+	}
+	a.Date = last.Date.Format("2006-01-02")
+	a.Deaths, a.Confirmed, a.Recovered, a.Tested = last.Value(series.DataDeaths), last.Value(series.DataConfirmed), last.Value(series.DataRecovered), last.Value(series.DataTested)
+	a.DeathsDaily, a.ConfirmedDaily = last.Value(series.DataDeaths), last.Value(series.DataConfirmed)
+	if previous := s.PenultimateDay(); previous != nil {
+		a.DeathsDaily -= previous.Value(series.DataDeaths)
+		a.ConfirmedDaily -= previous.Value(series.DataConfirmed)
+	}
+	return a
+}
+
+// liveSubscriber receives updates for the areas it is subscribed to, or all areas if all is set
+type liveSubscriber struct {
+	mutex   sync.Mutex
+	all     bool
+	areas   map[int]bool
+	updates chan LiveUpdate
+}
+
+// liveSubscribers are the clients connected for live updates, use liveMutex to access
+var (
+	liveSubscribers = make(map[*liveSubscriber]bool)
+	liveMutex       sync.Mutex
+)
+
+// subscribeLive adds a subscriber for the areas given, or all areas if none are given
+func subscribeLive(areaIDs []int) *liveSubscriber {
+	sub := &liveSubscriber{
+		all:     len(areaIDs) == 0,
+		areas:   make(map[int]bool),
+		updates: make(chan LiveUpdate, liveBuffer),
+	}
+	sub.subscribe(areaIDs)
+
+	liveMutex.Lock()
+	liveSubscribers[sub] = true
+	liveMutex.Unlock()
+	return sub
+}
+
+// close removes this subscriber, no more updates are sent to it
+func (sub *liveSubscriber) close() {
+	liveMutex.Lock()
+	delete(liveSubscribers, sub)
+	liveMutex.Unlock()
+}
+
+// subscribe adds areas to those this subscriber receives
+func (sub *liveSubscriber) subscribe(areaIDs []int) {
+	sub.mutex.Lock()
+	defer sub.mutex.Unlock()
+	for _, id := range areaIDs {
+		sub.areas[id] = true
+	}
+	if len(areaIDs) > 0 {
+		sub.all = false
+	}
+}
+
+// unsubscribe removes areas from those this subscriber receives
+func (sub *liveSubscriber) unsubscribe(areaIDs []int) {
+	sub.mutex.Lock()
+	defer sub.mutex.Unlock()
+	for _, id := range areaIDs {
+		delete(sub.areas, id)
+	}
+}
+
+// filter returns the areas this subscriber is subscribed to
+func (sub *liveSubscriber) filter(areas []LiveArea) []LiveArea {
+	sub.mutex.Lock()
+	defer sub.mutex.Unlock()
+	if sub.all {
+		return areas
+	}
+	result := []LiveArea{}
+	for _, a := range areas {
+		if sub.areas[a.AreaID] {
+			result = append(result, a)
+		}
+	}
+	return result
+}
+
+// snapshot returns the current totals of the areas this subscriber is subscribed to
+func (sub *liveSubscriber) snapshot(version int) LiveUpdate {
+	update := LiveUpdate{Type: "snapshot", Version: version, Areas: []LiveArea{}}
+	for _, s := range series.Areas() {
+		update.Areas = append(update.Areas, liveArea(s))
+	}
+	update.Areas = sub.filter(update.Areas)
+	return update
+}
+
+// publishLive sends the totals of the areas in change to subscribers, after each refresh
+// updates are not queued for subscribers too far behind, they receive later updates
+func publishLive(change *series.Change) {
+	if len(change.Areas) == 0 {
+		return
+	}
+
+	var areas []LiveArea
+	for _, a := range change.Areas {
+		s, err := series.FindSeries(a.AreaID)
+		if err == nil {
+			areas = append(areas, liveArea(s))
+		}
+	}
+
+	liveMutex.Lock()
+	defer liveMutex.Unlock()
+	for sub := range liveSubscribers {
+		update := LiveUpdate{Type: "refresh", Version: change.Version, Areas: sub.filter(areas)}
+		if len(update.Areas) == 0 {
+			continue
+		}
+		select {
+		case sub.updates <- update:
+		default:
+			log.Printf("live: dropped update version:%d for slow client", change.Version)
+		}
+	}
+}
+
+// liveRequest is a message from a live client changing its subscriptions
+type liveRequest struct {
+	Subscribe   []int `json:"subscribe"`
+	Unsubscribe []int `json:"unsubscribe"`
+}
+
+// parseAreaIDs parses a comma separated list of area ids, invalid ids are ignored
+func parseAreaIDs(list string) (ids []int) {
+	for _, v := range strings.Split(list, ",") {
+		id, err := strconv.Atoi(strings.TrimSpace(v))
+		if err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// latestVersion returns the version of the last change recorded
+func latestVersion() int {
+	changes := series.ChangesSince(0)
+	if len(changes) == 0 {
+		return 0
+	}
+	return changes[len(changes)-1].Version
+}
+
+// handleLive upgrades the request to a WebSocket, and pushes the totals of subscribed areas after each refresh
+// params: area_ids=1,2,3 to subscribe to these areas, all areas if blank
+// clients may send {"subscribe":[4],"unsubscribe":[1]} to change subscriptions, clients subscribed
+// to all areas receive only the areas given once they subscribe to any
+// the current totals are sent on connecting, then areas changed by each refresh as json LiveUpdates
+func handleLive(w http.ResponseWriter, r *http.Request) {
+
+	log.Printf("request:%s", r.URL)
+
+	ws, err := upgradeWebSocket(w, r)
+	if err != nil {
+		log.Printf("live: %s", err)
+		return
+	}
+	defer ws.Close()
+
+	sub := subscribeLive(parseAreaIDs(param(r, "area_ids")))
+	defer sub.close()
+
+	// Read subscription changes until the client closes the connection
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			message, err := ws.ReadMessage()
+			if err != nil {
+				return
+			}
+			var request liveRequest
+			err = json.Unmarshal(message, &request)
+			if err != nil {
+				log.Printf("live: invalid message:%s", err)
+				continue
+			}
+			sub.subscribe(request.Subscribe)
+			sub.unsubscribe(request.Unsubscribe)
+		}
+	}()
+
+	send := func(update LiveUpdate) error {
+		data, err := json.Marshal(update)
+		if err != nil {
+			return err
+		}
+		return ws.WriteText(data)
+	}
+
+	err = send(sub.snapshot(latestVersion()))
+	ticker := time.NewTicker(livePingInterval)
+	defer ticker.Stop()
+	for err == nil {
+		select {
+		case update := <-sub.updates:
+			err = send(update)
+		case <-ticker.C:
+			err = ws.Ping()
+		case <-done:
+			return
+		}
+	}
+	log.Printf("live: closed connection:%s", err)
+}
+
+// liveEvent is the compact event sent to event stream clients for one refreshed area
+type liveEvent struct {
+	AreaID         int    `json:"area_id"`
+	Date           string `json:"date"`
+	Deaths         int    `json:"deaths"`
+	Confirmed      int    `json:"confirmed"`
+	Recovered      int    `json:"recovered"`
+	Tested         int    `json:"tested"`
+	DeathsDaily    int    `json:"deaths_daily"`
+	ConfirmedDaily int    `json:"confirmed_daily"`
+}
+
+// handleEvents streams an area event for each area changed by a refresh as server-sent events
+// params: area_ids=1,2,3 to receive these areas, all areas if blank
+// event ids are the version of the change, clients reconnecting with Last-Event-ID receive
+// the current totals of areas changed since that version, no totals are sent on connecting otherwise
+func handleEvents(w http.ResponseWriter, r *http.Request) {
+
+	log.Printf("request:%s", r.URL)
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	// The stream stays open for updates, so the server write timeout does not apply
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	sub := subscribeLive(parseAreaIDs(param(r, "area_ids")))
+	defer sub.close()
+
+	send := func(update LiveUpdate) error {
+		for _, a := range update.Areas {
+			data, err := json.Marshal(liveEvent{
+				AreaID:         a.AreaID,
+				Date:           a.Date,
+				Deaths:         a.Deaths,
+				Confirmed:      a.Confirmed,
+				Recovered:      a.Recovered,
+				Tested:         a.Tested,
+				DeathsDaily:    a.DeathsDaily,
+				ConfirmedDaily: a.ConfirmedDaily,
+			})
+			if err != nil {
+				return err
+			}
+			_, err = fmt.Fprintf(w, "id: %d\nevent: area\ndata: %s\n\n", update.Version, data)
+			if err != nil {
+				return err
+			}
+		}
+		flusher.Flush()
+		return nil
+	}
+
+	_, err := fmt.Fprintf(w, "retry: %d\n\n", liveRetry.Milliseconds())
+	flusher.Flush()
+
+	// Send areas missed by a client reconnecting
+	if last, parseErr := strconv.Atoi(r.Header.Get("Last-Event-ID")); parseErr == nil && err == nil {
+		err = send(sub.since(last))
+	}
+
+	ticker := time.NewTicker(livePingInterval)
+	defer ticker.Stop()
+	for err == nil {
+		select {
+		case update := <-sub.updates:
+			err = send(update)
+		case <-ticker.C:
+			_, err = fmt.Fprintf(w, ": ping\n\n")
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+	log.Printf("live: closed event stream:%s", err)
+}
+
+// since returns the current totals of subscribed areas changed after version
+func (sub *liveSubscriber) since(version int) LiveUpdate {
+	update := LiveUpdate{Type: "refresh", Version: version, Areas: []LiveArea{}}
+	seen := make(map[int]bool)
+	for _, change := range series.ChangesSince(version) {
+		update.Version = change.Version
+		for _, a := range change.Areas {
+			if seen[a.AreaID] {
+				continue
+			}
+			seen[a.AreaID] = true
+			s, err := series.FindSeries(a.AreaID)
+			if err == nil {
+				update.Areas = append(update.Areas, liveArea(s))
+			}
+		}
+	}
+	update.Areas = sub.filter(update.Areas)
+	return update
+}
@@ -0,0 +1,16 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/kennygrant/coronavirus/series"
+)
+
+// requestLocale returns the locale used to format numbers for the viewer of the request
+// the lang param (e.g. lang=de) is used first, then the Accept-Language header
+func requestLocale(r *http.Request) series.Locale {
+	if lang := param(r, "lang"); lang != "" {
+		return series.LocaleForLanguage(lang)
+	}
+	return series.LocaleForLanguage(acceptLanguage(r))
+}
@@ -14,20 +14,54 @@ import (
 
 	"golang.org/x/crypto/acme/autocert"
 
+	"github.com/kennygrant/coronavirus/charts"
+	"github.com/kennygrant/coronavirus/config"
+	"github.com/kennygrant/coronavirus/rpc"
 	"github.com/kennygrant/coronavirus/series"
 )
 
+// defaultConfigPath is the config file read unless COVID_CONFIG is set
+const defaultConfigPath = "covid.toml"
+
 var development = false
 
+// Store our configuration globally, don't touch it after server start
+var cfg = config.Default()
+
 // Store our templates globally, don't touch them after server start
 var htmlTemplate *template.Template
 var jsonTemplate *template.Template
+var mortalityHTMLTemplate *template.Template
+var mortalityJSONTemplate *template.Template
+var compareTemplate *template.Template
+var heatmapTemplate *template.Template
+var monthlyTemplate *template.Template
+var rankingsTemplate *template.Template
 
 // Main loads data, sets up a periodic fetch, and starts a web server to serve that data
 func main() {
 
-	if os.Getenv("COVID") == "dev" {
-		development = true
+	// Read the config file then the environment, settings in neither keep their defaults
+	configPath := os.Getenv("COVID_CONFIG")
+	if configPath == "" {
+		configPath = defaultConfigPath
+	}
+	var err error
+	cfg, err = config.Load(configPath)
+	if err != nil {
+		log.Fatalf("server: failed to load config:%s", err)
+	}
+
+	err = cfg.Overlay(os.Environ())
+	if err != nil {
+		log.Fatalf("server: invalid config in environment:%s", err)
+	}
+	development = cfg.Server.Development
+	if cfg.Path != "" {
+		log.Printf("server: loaded config:%s", cfg.Path)
+	}
+	for _, setting := range cfg.Settings() {
+		log.Printf("server: config %s", setting)
 	}
 
 	if development {
@@ -36,38 +70,190 @@ func main() {
 		log.Printf("server: starting in production mode")
 	}
 
+	// In demo mode synthetic data is generated and served in place of the data files
+	if cfg.Feature("demo") {
+		cfg.Data.Path, err = writeDemoData()
+		if err != nil {
+			log.Fatalf("server: failed to generate demo data:%s", err)
+		}
+		log.Printf("server: serving demo data from:%s", cfg.Data.Path)
+	}
+	setDataPaths(cfg.Data.Path)
+
+	// Set up the store from config before any data is loaded, it is used by the series functions from here on
+	store, err := newStore()
+	if err != nil {
+		log.Fatalf("server: invalid data config:%s", err)
+	}
+	series.SetDefaultStore(store)
+
+	// Open the audit log before loading, so that every change to values after this is recorded
+	err = series.OpenAudit(auditPath)
+	if err != nil {
+		log.Printf("server: failed to open audit log:%s", err)
+	}
+
 	// Load our data
-	err := series.LoadData("./data")
+	err = series.LoadData(cfg.Data.Path)
 	if err != nil {
 		log.Fatalf("server: failed to load new data:%s", err)
 	}
 
-	// Schedule a regular data update/reload - don't bother in development except when testing
-	if !development {
+	// Archive days older than the days kept in memory, they are read from the archive on demand
+	err = series.Archive()
+	if err != nil {
+		log.Printf("server: failed to archive days:%s", err)
+	}
+
+	// Optionally rebuild the global series if it doesn't match the other series
+	if cfg.Feature("rebuild_global") {
+		discrepancies, err := series.CheckGlobalSeries(true)
+		if err != nil {
+			log.Fatalf("server: failed to check global series:%s", err)
+		}
+		log.Printf("server: checked global series, discrepancies:%d", len(discrepancies))
+	}
+
+	// Load the changelog of previous refreshes
+	err = series.LoadChanges(changesPath)
+	if err != nil {
+		log.Printf("server: failed to load changes:%s", err)
+	}
+
+	// Load the rank snapshots of previous days, and record ranks for the data loaded
+	err = series.LoadRanks(ranksPath)
+	if err != nil {
+		log.Printf("server: failed to load ranks:%s", err)
+	}
+	recordRanks()
+
+	// Set up transports for alerts
+	setupNotifiers()
+
+	// Limit the rate of json requests from each ip
+	setupRateLimit()
+	setupAPIKeys()
+
+	// Schedule a regular data update/reload if enabled - don't bother in development except when testing
+	if cfg.Refresh.Enabled && !development && !cfg.Feature("demo") {
 		ScheduleUpdates()
 	}
 
+	// Set up the secret used to sign cookies
+	setupCookieSecret()
+
 	// Load our template files into memory
 	loadTemplates()
 
 	// Set up the https server with the handler attached to serve this data in a template
 	http.HandleFunc("/favicon.ico", handleFile)
+	http.HandleFunc("/annotations.js", handleFile)
 	http.HandleFunc("/", handleHome)
 	http.HandleFunc("/reload", handleReload)
-
-	// Start a server on port 443 (or another port if dev specified)
+	http.HandleFunc("/areas/", handleAreaCode)
+	http.HandleFunc("/compare", handleCompare)
+	http.Handle("/heatmap/", http.StripPrefix("/heatmap", http.HandlerFunc(handleHeatmap)))
+	http.Handle("/monthly/", http.StripPrefix("/monthly", http.HandlerFunc(handleMonthly)))
+	http.HandleFunc("/compare.json", handleCompare)
+	http.HandleFunc("/compare.xlsx", handleCompareXLSX)
+	http.HandleFunc("/compare/correlation", handleCorrelation)
+	http.HandleFunc("/compare/vaccination", handleVaccination)
+	http.HandleFunc("/rankings", handleRankings)
+	http.HandleFunc("/rankings.json", handleRankings)
+	http.HandleFunc("/compare/deaths-per-million", handleDeathsPerMillion)
+	http.HandleFunc("/compare/deaths-per-million.json", handleDeathsPerMillion)
+	http.HandleFunc("/api/v1/changes", handleChanges)
+	http.HandleFunc("/api/v1/live", handleLive)
+	http.HandleFunc("/api/v1/events", handleEvents)
+	http.HandleFunc("/api/v1/digest", handleDigest)
+	http.HandleFunc("/admin/discrepancies", handleDiscrepancies)
+	http.HandleFunc("/admin/snapshots", handleSnapshots)
+	http.HandleFunc("/admin/snapshots/diff", handleSnapshotDiff)
+	http.HandleFunc("/admin/backtest", handleBacktest)
+	http.HandleFunc("/admin/validate", handleValidate)
+	http.HandleFunc("/admin/rollback", handleRollback)
+	http.HandleFunc("/admin/refresh", handleRefresh)
+	http.HandleFunc("/admin/overrides", handleOverrides)
+	http.HandleFunc("/admin/status", handleStatus)
+	http.HandleFunc("/admin/audit", handleAudit)
+	http.HandleFunc("/admin/apikeys", handleAPIKeys)
+	http.HandleFunc("/api/v1/bulk/series.csv", requireAPIKey(handleBulkSeries))
+	http.HandleFunc("/api/v1/bulk/series.parquet", requireAPIKey(handleBulkParquet))
+	http.HandleFunc("/api/v1/bulk/series.pb", requireAPIKey(handleBulkProtobuf))
+	http.HandleFunc("/api/v1/dump.jsonl", handleDump)
+	http.HandleFunc("/api/v1/choropleth", handleChoropleth)
+	http.HandleFunc("/api/v1/areas", handleAreas)
+	http.HandleFunc("/api/v1/dataset", handleDataset)
+	http.HandleFunc("/api/v1/search", handleSearch)
+	http.HandleFunc("/api/v1/autocomplete", handleAutocomplete)
+	http.HandleFunc("/api/v1/areas.geojson", handleGeoJSON)
+	http.Handle("/api/v1/sources/", http.StripPrefix("/api/v1/sources", http.HandlerFunc(handleSources)))
+	http.Handle("/api/v1/waves/", http.StripPrefix("/api/v1/waves", http.HandlerFunc(handleWaves)))
+	http.Handle("/api/v1/monthly/", http.StripPrefix("/api/v1/monthly", http.HandlerFunc(handleMonthlyAPI)))
+	http.Handle("/api/v1/outliers/", http.StripPrefix("/api/v1/outliers", http.HandlerFunc(handleOutliers)))
+	http.Handle("/report/", http.StripPrefix("/report", http.HandlerFunc(handleReport)))
+	http.Handle("/api/v1/social/", http.StripPrefix("/api/v1/social", http.HandlerFunc(handleSocial)))
+	http.Handle("/api/v1/sex/", http.StripPrefix("/api/v1/sex", http.HandlerFunc(handleSex)))
+	http.Handle("/api/v1/variants/", http.StripPrefix("/api/v1/variants", http.HandlerFunc(handleVariants)))
+	http.Handle("/api/v1/chart/", http.StripPrefix("/api/v1/chart", http.HandlerFunc(handleChart)))
+	http.Handle("/mortality/", http.StripPrefix("/mortality", http.HandlerFunc(handleMortality)))
+
+	// Series data over gRPC for internal consumers, which is only available over http/2 from the TLS server
+	http.Handle(rpc.Prefix, requireAPIKey(rpc.Handler().ServeHTTP))
+
+	// Start a server on port 443 (or another port if configured)
 	if development {
-		// In development just serve with http on local port 3000
+		// In development just serve with http, on local port 3000 unless configured
 		// reload templates on each page load
-		err := http.ListenAndServe(":3000", nil)
+		err := http.ListenAndServe(cfg.ListenAddr(), rateLimit(http.DefaultServeMux))
 		if err != nil {
 			log.Fatal(err)
 		}
 	} else {
-		domains := []string{"coronavirus.projectpage.app"}
-		StartTLSServer(development, domains)
+		StartTLSServer(development, cfg.Server.Domains)
+	}
+
+}
+
+// newStore returns a series store with the data config, the store config is fixed once it is created
+func newStore() (*series.Store, error) {
+	storeConfig := series.StoreConfig{
+		DataPath:      cfg.Data.Path,
+		StartDate:     cfg.Data.StartDate,
+		Groups:        cfg.Groups,
+		LazyProvinces: cfg.Data.LazyProvinces,
+		RetentionDays: cfg.Data.RetentionDays,
+		ArchiveDays:   cfg.Data.ArchiveDays,
+		Cache:         cfg.Data.Cache,
+	}
+	if cfg.Data.StartDate.IsZero() {
+		return nil, fmt.Errorf("invalid zero start date")
+	}
+
+	// Track another disease with its own metrics if set, the series engine is the same
+	var err error
+	if len(cfg.Data.Metrics) > 0 {
+		storeConfig.Dataset, err = series.NewDataset(cfg.Data.Disease, cfg.Data.Metrics...)
+		if err != nil {
+			return nil, fmt.Errorf("invalid metrics:%s", err)
+		}
+	}
+
+	// Optionally fill days skipped by sources
+	storeConfig.Interpolation, err = series.ParseInterpolation(cfg.Data.Interpolation)
+	if err != nil {
+		return nil, fmt.Errorf("invalid interpolation:%s", err)
 	}
 
+	// Set the priority of sources providing the same values if configured, highest first e.g. ukgov,jhu
+	if len(cfg.Data.SourcePriority) > 0 {
+		storeConfig.SourcePriority, err = series.ParseSourcePriority(cfg.Data.SourcePriority)
+		if err != nil {
+			return nil, fmt.Errorf("invalid source priority:%s", err)
+		}
+	}
+
+	return series.NewStore(storeConfig), nil
 }
 
 func loadTemplates() {
@@ -77,14 +263,39 @@ func loadTemplates() {
 		log.Fatalf("template error:%s", err)
 	}
 	funcMap := map[string]interface{}{
-		"e":  escapeJSON,
-		"l":  outputList,
-		"ls": outputStringList,
+		"e":   escapeJSON,
+		"l":   outputList,
+		"ls":  outputStringList,
+		"inc": func(i int) int { return i + 1 },
 	}
 	jsonTemplate, err = template.New("index.json.got").Funcs(funcMap).ParseFiles("index.json.got")
 	if err != nil {
 		log.Fatalf("template error:%s", err)
 	}
+	mortalityHTMLTemplate, err = template.ParseFiles("mortality.html.got")
+	if err != nil {
+		log.Fatalf("template error:%s", err)
+	}
+	mortalityJSONTemplate, err = template.New("mortality.json.got").Funcs(funcMap).ParseFiles("mortality.json.got")
+	if err != nil {
+		log.Fatalf("template error:%s", err)
+	}
+	compareTemplate, err = template.ParseFiles("compare.html.got")
+	if err != nil {
+		log.Fatalf("template error:%s", err)
+	}
+	heatmapTemplate, err = template.New("heatmap.html.got").Funcs(funcMap).ParseFiles("heatmap.html.got")
+	if err != nil {
+		log.Fatalf("template error:%s", err)
+	}
+	monthlyTemplate, err = template.ParseFiles("monthly.html.got")
+	if err != nil {
+		log.Fatalf("template error:%s", err)
+	}
+	rankingsTemplate, err = template.ParseFiles("rankings.html.got")
+	if err != nil {
+		log.Fatalf("template error:%s", err)
+	}
 }
 
 // handleHome shows our website
@@ -102,6 +313,10 @@ func handleHome(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if notModified(w, r, s) {
+		return
+	}
+
 	// Get the total counts first for the page
 	allTimeDeaths := s.TotalDeaths()
 	allTimeConfirmed := s.TotalConfirmed()
@@ -125,21 +340,59 @@ func handleHome(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Limit by period if applied
-	if period > 0 {
+	// Limit by date range if applied, or else by period
+	from, to := parseDateRange(r)
+	jsonURL := fmt.Sprintf("%s.json?period=%d", r.URL.Path, period)
+	if !from.IsZero() || !to.IsZero() {
+		s = withArchive(s, from).PeriodRange(from, to)
+		jsonURL = fmt.Sprintf("%s.json?%s", strings.TrimSuffix(r.URL.Path, ".json"), dateRangeQuery(from, to))
+	} else if period > 0 {
 		s = s.Period(period)
 	}
 
-	scale := "linear"
+	// Read chart options from params or the user's cookie
+	options := chartOptions(w, r)
+	scale := options.Scale
 	scaleURL := r.URL.Path + "?scale=log#growth"
-	if param(r, "scale") == "log" {
-		scale = "logarithmic"
-		scaleURL = r.URL.Path + "#growth"
+	if scale == charts.ScaleLogarithmic {
+		scaleURL = r.URL.Path + "?scale=linear#growth"
+	}
+	averageURL := r.URL.Path + "?average=7#deaths_daily"
+	if options.Average {
+		averageURL = r.URL.Path + "?average=0#deaths_daily"
+	}
+	perCapitaURL := r.URL.Path + "?per100k=1#deaths_daily"
+	if options.PerCapita {
+		perCapitaURL = r.URL.Path + "?per100k=0#deaths_daily"
+	}
+
+	// Today's deltas use the viewer's local date
+	loc := requestLocation(r)
+
+	// Daily charts always show daily values, but may be averaged
+	daily := options
+	daily.Cumulative = false
+
+	// Total charts always show cumulative values
+	total := options
+	total.Cumulative = true
+	total.Average = false
+
+	// Unless averaged, daily bars have a moving average line overlaid
+	var deathsAverage, confirmedAverage []float64
+	if !options.Average {
+		deathsAverage = charts.Average(s, series.DataDeaths, options)
+		confirmedAverage = charts.Average(s, series.DataConfirmed, options)
 	}
 
 	// For global compare growth rate of top 20 series
 	var comparisons series.Slice
-	if s.IsGlobal() {
+	if s.IsGlobal() && options.PerCapita {
+		comparisons = series.Countries().Rank(series.DataDeaths, true)
+		if len(comparisons) > 10 {
+			comparisons = comparisons[:10]
+		}
+	} else if s.IsGlobal() {
 		comparisons = series.TopSeriesGlobal(country, 10)
 	} else if s.IsEuropean() {
 		comparisons = series.SelectedEuropeanSeries(country, 10)
@@ -153,6 +406,12 @@ func handleHome(w http.ResponseWriter, r *http.Request) {
 
 	log.Printf("comparisons:%d", len(comparisons))
 
+	// Generate ticks for the growth chart to suit the scale
+	growth := options
+	growth.Cumulative = true
+	growth.Average = false
+	growthTicks := charts.Aligned(comparisons, series.DataDeaths, startDeaths, growth).Ticks
+
 	// Set up context with data
 	context := map[string]interface{}{
 		"period":           strconv.Itoa(period),
@@ -167,9 +426,29 @@ func handleHome(w http.ResponseWriter, r *http.Request) {
 		"periodOptions":    series.PeriodOptions(),
 		"countryOptions":   series.CountryOptions(),
 		"provinceOptions":  series.ProvinceOptions(s.Country),
-		"jsonURL":          fmt.Sprintf("%s.json?period=%d", r.URL.Path, period),
+		"jsonURL":          jsonURL,
 		"scale":            scale,
 		"scaleURL":         scaleURL,
+		"average":          options.Average,
+		"averageURL":       averageURL,
+		"perCapita":        options.PerCapita,
+		"perCapitaURL":     perCapitaURL,
+		"growthTicks":      growthTicks,
+		"deathsDaily":      charts.Values(s, series.DataDeaths, daily),
+		"confirmedDaily":   charts.Values(s, series.DataConfirmed, daily),
+		"deathsTotal":      charts.Values(s, series.DataDeaths, total),
+		"confirmedTotal":   charts.Values(s, series.DataConfirmed, total),
+		"deathsAverage":    deathsAverage,
+		"annotations":      charts.Annotations(s),
+		"variantsChart":    charts.Variants(s),
+		"confirmedAverage": confirmedAverage,
+		"deathsToday":      s.TodayIn(series.DataDeaths, loc),
+		"deathsPeak":       s.HighestPeak(series.DataDeaths),
+		"recovery":         s.RecoveryDuration(),
+		"locale":           requestLocale(r),
+		"confirmedToday":   s.TodayIn(series.DataConfirmed, loc),
+		"lastHours":        s.LastHoursIn(loc),
+		"updatedAt":        s.UpdatedAtDisplayIn(loc),
 		"mobile":           mobile,
 		"startDeaths":      startDeaths, // Deaths to start comparison chart from
 	}
@@ -198,13 +477,225 @@ func handleHome(w http.ResponseWriter, r *http.Request) {
 
 }
 
+// handleAreaCode redirects /areas/{code} to the page for the area with that ISO code
+// codes may be alpha-2 or alpha-3 country codes or subdivision codes like US-NY, a .json suffix is kept
+func handleAreaCode(w http.ResponseWriter, r *http.Request) {
+
+	log.Printf("request:%s", r.URL)
+
+	code := strings.TrimPrefix(r.URL.Path, "/areas/")
+	suffix := ""
+	if strings.HasSuffix(code, ".json") {
+		code = strings.TrimSuffix(code, ".json")
+		suffix = ".json"
+	}
+
+	s, err := series.FetchCode(code)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	url := "/" + s.Slug() + suffix
+	if r.URL.RawQuery != "" {
+		url += "?" + r.URL.RawQuery
+	}
+	http.Redirect(w, r, url, http.StatusFound)
+}
+
+// handleMortality shows combined mortality metrics for an area
+// the path after /mortality is parsed as for the home page
+// params: lag_mean=n&lag_sd=n for the days from confirmation to death used to adjust the case fatality rate
+func handleMortality(w http.ResponseWriter, r *http.Request) {
+
+	log.Printf("request:mortality%s", r.URL)
+
+	country, province, _, _ := parseParams(r)
+
+	s, err := series.FetchSeries(country, province)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	if notModified(w, r, s) {
+		return
+	}
+
+	// The global series is at the root, use global.json for the feed
+	path := strings.TrimSuffix(r.URL.Path, ".json")
+	if path == "/" {
+		path = "/global"
+	}
+
+	// Compare total reported deaths with excess deaths
+	excess := charts.DefaultOptions()
+	excess.Cumulative = true
+
+	// Compare daily deaths with the flu baseline, averaged as the baseline is spread over each week
+	flu := charts.DefaultOptions()
+	flu.Average = true
+
+	// Match deaths to cases by the lag chosen, or the default if invalid
+	lagMean, lagSD := floatParam(r, "lag_mean"), floatParam(r, "lag_sd")
+	if lagMean == 0 {
+		lagMean = series.DefaultLagMean
+	}
+	if lagSD == 0 {
+		lagSD = series.DefaultLagSD
+	}
+	lag, err := series.NewDeathLag(lagMean, lagSD)
+	if err != nil {
+		lag = series.DefaultDeathLag()
+	}
+
+	// Headings are abbreviated, the table shows totals in full
+	locale := requestLocale(r)
+
+	context := map[string]interface{}{
+		"series":      s,
+		"mortality":   s.MortalityWithLag(lag),
+		"excessChart": charts.Excess(s, excess),
+		"fluChart":    charts.Flu(s, flu),
+		"cfrChart":    charts.CFR(s, lag, charts.DefaultOptions()),
+		"locale":      locale,
+		"full":        series.Formatter{Locale: locale, Full: true},
+		"jsonURL":     fmt.Sprintf("/mortality%s.json", path),
+		"updatedAt":   s.UpdatedAtDisplayIn(requestLocation(r)),
+	}
+
+	if development {
+		loadTemplates()
+	}
+
+	if strings.HasSuffix(r.URL.Path, ".json") {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(200)
+		err = mortalityJSONTemplate.Execute(w, context)
+	} else {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(200)
+		err = mortalityHTMLTemplate.Execute(w, context)
+	}
+
+	if err != nil {
+		log.Printf("template render error:%s", err)
+		http.Error(w, err.Error(), 500)
+	}
+}
+
+// handleHeatmap shows a calendar heatmap of daily values for an area
+// the path after /heatmap is parsed as for the home page, kind selects the metric
+func handleHeatmap(w http.ResponseWriter, r *http.Request) {
+
+	log.Printf("request:heatmap%s", r.URL)
+
+	country, province, _, _ := parseParams(r)
+
+	s, err := series.FetchSeries(country, province)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	if notModified(w, r, s) {
+		return
+	}
+
+	dataKind := series.DataKindFromName(param(r, "kind"))
+	if dataKind == series.DataNone {
+		dataKind = series.DataConfirmed
+	}
+	heatmap := charts.NewHeatmap(s, dataKind)
+
+	if strings.HasSuffix(r.URL.Path, ".json") {
+		renderJSON(w, r, heatmap)
+		return
+	}
+
+	path := r.URL.Path
+	if path == "/" {
+		path = "/global"
+	}
+
+	context := map[string]interface{}{
+		"heatmap": heatmap,
+		"jsonURL": fmt.Sprintf("/heatmap%s.json?kind=%s", path, series.DataKindName(dataKind)),
+	}
+
+	if development {
+		loadTemplates()
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(200)
+	err = heatmapTemplate.Execute(w, context)
+	if err != nil {
+		log.Printf("template render error:%s", err)
+	}
+}
+
+// monthlyReport is the monthly summary of one data kind shown on the monthly report page
+type monthlyReport struct {
+	Title  string
+	Months []series.Month
+}
+
+// handleMonthly shows a report of monthly totals, averages and peak days of deaths and confirmed cases for an area
+// the path after /monthly is parsed as for the home page
+func handleMonthly(w http.ResponseWriter, r *http.Request) {
+
+	log.Printf("request:monthly%s", r.URL)
+
+	country, province, _, _ := parseParams(r)
+
+	s, err := series.FetchSeries(country, province)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	if notModified(w, r, s) {
+		return
+	}
+
+	path := r.URL.Path
+	if path == "/" {
+		path = "/global"
+	}
+
+	locale := requestLocale(r)
+	context := map[string]interface{}{
+		"series": s,
+		"reports": []monthlyReport{
+			{Title: "Deaths", Months: s.Monthly(series.DataDeaths)},
+			{Title: "Confirmed cases", Months: s.Monthly(series.DataConfirmed)},
+		},
+		"locale":    locale,
+		"full":      series.Formatter{Locale: locale, Full: true},
+		"jsonURL":   fmt.Sprintf("/api/v1/monthly%s", path),
+		"updatedAt": s.UpdatedAtDisplayIn(requestLocation(r)),
+	}
+
+	if development {
+		loadTemplates()
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(200)
+	err = monthlyTemplate.Execute(w, context)
+	if err != nil {
+		log.Printf("template render error:%s", err)
+	}
+}
+
 // handleReload
 // FIXME - require authentication to avoid DOS
 func handleReload(w http.ResponseWriter, r *http.Request) {
 
 	log.Printf("reload:%s", r.URL)
 
-	err := series.LoadData("./data")
+	err := series.LoadData(cfg.Data.Path)
 
 	// Check for errors on reload
 	if err != nil {
@@ -228,6 +719,24 @@ func param(r *http.Request, key string) string {
 	return ""
 }
 
+// intParam returns one param as an int value, or 0 if missing or invalid
+func intParam(r *http.Request, key string) int {
+	v, err := strconv.Atoi(param(r, key))
+	if err != nil {
+		return 0
+	}
+	return v
+}
+
+// floatParam returns one param as a float value, or 0 if missing or invalid
+func floatParam(r *http.Request, key string) float64 {
+	v, err := strconv.ParseFloat(param(r, key), 64)
+	if err != nil {
+		return 0
+	}
+	return v
+}
+
 // parseParams parses the parts of the url path (if any) and params
 func parseParams(r *http.Request) (country, province string, period, startDeaths int) {
 
@@ -236,8 +745,11 @@ func parseParams(r *http.Request) (country, province string, period, startDeaths
 	// Parse the path
 	p := r.URL.Path
 
-	// First remove .json if it exists
+	// First remove .json, .png, .md or .pdf if it exists
 	p = strings.Replace(p, ".json", "", 1)
+	p = strings.Replace(p, ".png", "", 1)
+	p = strings.Replace(p, ".md", "", 1)
+	p = strings.Replace(p, ".pdf", "", 1)
 
 	// Now parse parts
 	parts := strings.Split(strings.Trim(p, "/"), "/")
@@ -278,6 +790,11 @@ func parseParams(r *http.Request) (country, province string, period, startDeaths
 		province = queryParams["province"][0]
 	}
 
+	return countryAlias(country), province, period, startDeaths
+}
+
+// countryAlias returns the country name for abbreviations used in urls
+func countryAlias(country string) string {
 	// Allow some abreviations for urls
 	if country == "uk" {
 		country = "United Kingdom"
@@ -288,7 +805,44 @@ func parseParams(r *http.Request) (country, province string, period, startDeaths
 		country = ""
 	}
 
-	return country, province, period, startDeaths
+	return country
+}
+
+// parseDateRange parses the from and to params (if any) in the format 2006-01-02
+// invalid or missing dates are returned as zero times
+func parseDateRange(r *http.Request) (from, to time.Time) {
+	from, err := time.Parse("2006-01-02", param(r, "from"))
+	if err != nil {
+		from = time.Time{}
+	}
+	to, err = time.Parse("2006-01-02", param(r, "to"))
+	if err != nil {
+		to = time.Time{}
+	}
+	return from, to
+}
+
+// withArchive returns s with archived days from the date given added, so ranges before the days in memory are complete
+// s is returned unchanged if the archive cannot be read
+func withArchive(s *series.Data, from time.Time) *series.Data {
+	archived, err := series.WithArchive(s, from)
+	if err != nil {
+		log.Printf("archive: failed to read days:%s", err)
+		return s
+	}
+	return archived
+}
+
+// dateRangeQuery returns a query string for the from and to dates given
+func dateRangeQuery(from, to time.Time) string {
+	var params []string
+	if !from.IsZero() {
+		params = append(params, "from="+from.Format("2006-01-02"))
+	}
+	if !to.IsZero() {
+		params = append(params, "to="+to.Format("2006-01-02"))
+	}
+	return strings.Join(params, "&")
 }
 
 // handleFile shows a file (if it exists)
@@ -358,7 +912,10 @@ func StartTLSServer(dev bool, domains []string) {
 
 	server := &http.Server{
 		// Set the port in the preferred string format
-		Addr: ":443",
+		Addr: cfg.ListenAddr(),
+
+		// Serve our handlers with json requests rate limited
+		Handler: rateLimit(http.DefaultServeMux),
 
 		// The default server from net/http has no timeouts - set some limits
 		ReadHeaderTimeout: 30 * time.Second,
@@ -391,7 +948,7 @@ func StartTLSServer(dev bool, domains []string) {
 
 	// Handle all :80 traffic using autocert to allow http-01 challenge responses
 	go func() {
-		http.ListenAndServe(":80", certManager.HTTPHandler(nil))
+		http.ListenAndServe(cfg.Server.HTTPListen, certManager.HTTPHandler(nil))
 	}()
 
 	err := server.ListenAndServeTLS("", "")
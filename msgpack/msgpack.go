@@ -0,0 +1,161 @@
+// Package msgpack encodes values as MessagePack, a binary equivalent of json which is smaller to send
+// values are converted from their json encoding, so json tags and marshalers decide the fields encoded
+// numbers are written as integers where they have no fraction, and as float64 otherwise
+package msgpack
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// ContentType is the media type of MessagePack, clients request it in the Accept header
+const ContentType = "application/msgpack"
+
+// FromJSON returns the json document data encoded as MessagePack
+func FromJSON(data []byte) ([]byte, error) {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	var v interface{}
+	err := d.Decode(&v)
+	if err != nil {
+		return nil, fmt.Errorf("msgpack: invalid json:%s", err)
+	}
+	return Marshal(v)
+}
+
+// Marshal returns v encoded as MessagePack, v may hold the values json decodes to
+// nil, bool, string, float64, json.Number, []interface{} and map[string]interface{}, and ints
+// map keys are sorted so the encoding is stable
+func Marshal(v interface{}) ([]byte, error) {
+	e := &encoder{}
+	err := e.encode(v)
+	if err != nil {
+		return nil, err
+	}
+	return e.buf, nil
+}
+
+// encoder appends values to buf
+type encoder struct {
+	buf []byte
+}
+
+// encode appends the value v
+func (e *encoder) encode(v interface{}) error {
+	switch v := v.(type) {
+	case nil:
+		e.buf = append(e.buf, 0xc0)
+	case bool:
+		if v {
+			e.buf = append(e.buf, 0xc3)
+		} else {
+			e.buf = append(e.buf, 0xc2)
+		}
+	case int:
+		e.int(int64(v))
+	case int64:
+		e.int(v)
+	case float64:
+		e.float(v)
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			e.int(i)
+			return nil
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return fmt.Errorf("msgpack: invalid number:%s", v)
+		}
+		e.float(f)
+	case string:
+		e.string(v)
+	case []interface{}:
+		e.header(len(v), 0x90, 0xdc)
+		for _, item := range v {
+			err := e.encode(item)
+			if err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		e.header(len(v), 0x80, 0xde)
+		for _, k := range keys {
+			e.string(k)
+			err := e.encode(v[k])
+			if err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("msgpack: unsupported type:%T", v)
+	}
+	return nil
+}
+
+// int appends v in the smallest integer format which holds it
+func (e *encoder) int(v int64) {
+	switch {
+	case v >= 0 && v <= 0x7f:
+		e.buf = append(e.buf, byte(v))
+	case v >= -32 && v < 0:
+		e.buf = append(e.buf, byte(0xe0|(v+32)))
+	case v >= 0 && v <= math.MaxUint8:
+		e.buf = append(e.buf, 0xcc, byte(v))
+	case v >= 0 && v <= math.MaxUint16:
+		e.buf = binary.BigEndian.AppendUint16(append(e.buf, 0xcd), uint16(v))
+	case v >= 0 && v <= math.MaxUint32:
+		e.buf = binary.BigEndian.AppendUint32(append(e.buf, 0xce), uint32(v))
+	case v >= 0:
+		e.buf = binary.BigEndian.AppendUint64(append(e.buf, 0xcf), uint64(v))
+	case v >= math.MinInt8:
+		e.buf = append(e.buf, 0xd0, byte(v))
+	case v >= math.MinInt16:
+		e.buf = binary.BigEndian.AppendUint16(append(e.buf, 0xd1), uint16(v))
+	case v >= math.MinInt32:
+		e.buf = binary.BigEndian.AppendUint32(append(e.buf, 0xd2), uint32(v))
+	default:
+		e.buf = binary.BigEndian.AppendUint64(append(e.buf, 0xd3), uint64(v))
+	}
+}
+
+// float appends v as a float64
+func (e *encoder) float(v float64) {
+	e.buf = binary.BigEndian.AppendUint64(append(e.buf, 0xcb), math.Float64bits(v))
+}
+
+// string appends the string v with its length
+func (e *encoder) string(v string) {
+	switch n := len(v); {
+	case n < 32:
+		e.buf = append(e.buf, byte(0xa0|n))
+	case n <= math.MaxUint8:
+		e.buf = append(e.buf, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		e.buf = binary.BigEndian.AppendUint16(append(e.buf, 0xda), uint16(n))
+	default:
+		e.buf = binary.BigEndian.AppendUint32(append(e.buf, 0xdb), uint32(n))
+	}
+	e.buf = append(e.buf, v...)
+}
+
+// header appends the header of an array or map of n items, fix is the format for up to 15 items
+// and format16 the format with a 16 bit length, which is followed by the format with a 32 bit length
+func (e *encoder) header(n int, fix, format16 byte) {
+	switch {
+	case n < 16:
+		e.buf = append(e.buf, fix|byte(n))
+	case n <= math.MaxUint16:
+		e.buf = binary.BigEndian.AppendUint16(append(e.buf, format16), uint16(n))
+	default:
+		e.buf = binary.BigEndian.AppendUint32(append(e.buf, format16+1), uint32(n))
+	}
+}
@@ -0,0 +1,55 @@
+package msgpack
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestMarshal(t *testing.T) {
+	tests := []struct {
+		v    interface{}
+		want []byte
+	}{
+		{nil, []byte{0xc0}},
+		{true, []byte{0xc3}},
+		{5, []byte{0x05}},
+		{-1, []byte{0xff}},
+		{-33, []byte{0xd0, 0xdf}},
+		{200, []byte{0xcc, 0xc8}},
+		{70000, []byte{0xce, 0x00, 0x01, 0x11, 0x70}},
+		{-70000, []byte{0xd2, 0xff, 0xfe, 0xee, 0x90}},
+		{1.5, []byte{0xcb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0}},
+		{"ab", []byte{0xa2, 'a', 'b'}},
+		{[]interface{}{1, "a"}, []byte{0x92, 0x01, 0xa1, 'a'}},
+		{map[string]interface{}{"b": 2, "a": 1}, []byte{0x82, 0xa1, 'a', 0x01, 0xa1, 'b', 0x02}},
+	}
+	for _, test := range tests {
+		got, err := Marshal(test.v)
+		if err != nil || !bytes.Equal(got, test.want) {
+			t.Errorf("msgpack: wrong encoding of:%v got:%x want:%x err:%v", test.v, got, test.want, err)
+		}
+	}
+
+	long, _ := Marshal(strings.Repeat("x", 40))
+	if !bytes.HasPrefix(long, []byte{0xd9, 40}) {
+		t.Errorf("msgpack: wrong long string header got:%x", long[:2])
+	}
+	_, err := Marshal(struct{}{})
+	if err == nil {
+		t.Errorf("msgpack: encoded unsupported type")
+	}
+}
+
+func TestFromJSON(t *testing.T) {
+	got, err := FromJSON([]byte(`{"deaths":12,"rate":0.5,"areas":[],"name":null}`))
+	want := []byte{0x84, 0xa5, 'a', 'r', 'e', 'a', 's', 0x90, 0xa6, 'd', 'e', 'a', 't', 'h', 's', 0x0c,
+		0xa4, 'n', 'a', 'm', 'e', 0xc0, 0xa4, 'r', 'a', 't', 'e', 0xcb, 0x3f, 0xe0, 0, 0, 0, 0, 0, 0}
+	if err != nil || !bytes.Equal(got, want) {
+		t.Errorf("msgpack: wrong encoding got:%x want:%x err:%v", got, want, err)
+	}
+	_, err = FromJSON([]byte(`{"deaths":`))
+	if err == nil {
+		t.Errorf("msgpack: encoded invalid json")
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"log"
+	"os"
+	"strings"
+
+	"github.com/kennygrant/coronavirus/notify"
+)
+
+// Rules of the alerts sent after a refresh, transports are only sent the rules routed to them
+const (
+	// ruleNewDay is sent when a refresh adds a new last day to the data
+	ruleNewDay = "new_day"
+
+	// ruleRevisions is sent when a refresh revises values on days before the last day
+	ruleRevisions = "revisions"
+
+	// ruleDigest is sent with the digest of the day before after the first refresh of each day
+	ruleDigest = "digest"
+)
+
+// setupNotifiers registers notification transports configured in the environment
+// and routes new day and revision alerts and daily digests to all of them
+func setupNotifiers() {
+	if u := os.Getenv("COVID_WEBHOOK_URL"); u != "" {
+		notify.Register(&notify.Webhook{URL: u})
+	}
+	if u := os.Getenv("COVID_SLACK_WEBHOOK"); u != "" {
+		notify.Register(&notify.Slack{WebhookURL: u})
+	}
+	if u := os.Getenv("COVID_DISCORD_WEBHOOK"); u != "" {
+		notify.Register(&notify.Discord{WebhookURL: u})
+	}
+	if token := os.Getenv("COVID_TELEGRAM_TOKEN"); token != "" {
+		notify.Register(&notify.Telegram{Token: token, ChatID: os.Getenv("COVID_TELEGRAM_CHAT")})
+	}
+	if addr := os.Getenv("COVID_SMTP_ADDR"); addr != "" {
+		notify.Register(&notify.Email{
+			Addr:     addr,
+			Username: os.Getenv("COVID_SMTP_USER"),
+			Password: os.Getenv("COVID_SMTP_PASSWORD"),
+			From:     os.Getenv("COVID_EMAIL_FROM"),
+			To:       strings.Split(os.Getenv("COVID_EMAIL_TO"), ","),
+		})
+	}
+
+	names := notify.Registered()
+	if len(names) > 0 {
+		log.Printf("server: notifying refreshes via:%s", strings.Join(names, ","))
+		notify.Route(ruleNewDay, names...)
+		notify.Route(ruleRevisions, names...)
+		notify.Route(ruleDigest, names...)
+	}
+}
@@ -0,0 +1,105 @@
+// Package notify sends alerts through a set of pluggable transports
+// transports are registered by name and rules route alerts to one or more transports
+package notify
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Message is one alert to be sent
+type Message struct {
+	// Rule is the name of the rule which raised this alert, used for routing
+	Rule string
+
+	// Title is a short summary, Body the full text
+	Title string
+	Body  string
+}
+
+// String returns a plain text representation of this message
+func (m Message) String() string {
+	if m.Body == "" {
+		return m.Title
+	}
+	return m.Title + "\n" + m.Body
+}
+
+// Notifier is implemented by every transport
+type Notifier interface {
+	// Name returns the name of this transport, e.g. slack
+	Name() string
+
+	// Notify sends the message, returning an error on failure
+	Notify(m Message) error
+}
+
+// Mutex to protect access to notifiers and routes below
+var mutex sync.RWMutex
+
+// Registered notifiers by name
+var notifiers = make(map[string]Notifier)
+
+// Routes from rule name to notifier names, the rule * matches all rules
+var routes = make(map[string][]string)
+
+// Register adds a notifier to the registry, replacing any with the same name
+func Register(n Notifier) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	notifiers[n.Name()] = n
+}
+
+// Registered returns the names of all registered notifiers
+func Registered() []string {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	var names []string
+	for name := range notifiers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Route sends alerts raised by rule to the named notifiers
+// use the rule * to route all rules
+func Route(rule string, names ...string) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	routes[rule] = append(routes[rule], names...)
+}
+
+// Send sends the message to every notifier routed for its rule
+// each notifier is tried even if others fail, errors are combined
+func Send(m Message) error {
+	mutex.RLock()
+	var targets []Notifier
+	seen := make(map[string]bool)
+	for _, name := range append(routes[m.Rule], routes["*"]...) {
+		n, ok := notifiers[name]
+		if !ok || seen[name] {
+			continue
+		}
+		seen[name] = true
+		targets = append(targets, n)
+	}
+	mutex.RUnlock()
+
+	var failures []string
+	for _, n := range targets {
+		err := n.Notify(m)
+		if err != nil {
+			log.Printf("notify: failed to send via %s:%s", n.Name(), err)
+			failures = append(failures, fmt.Sprintf("%s:%s", n.Name(), err))
+		}
+	}
+
+	if len(failures) > 0 {
+		return fmt.Errorf("notify: failed to send:%s", strings.Join(failures, ", "))
+	}
+	return nil
+}
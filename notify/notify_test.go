@@ -0,0 +1,97 @@
+package notify
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// fake is a transport which records the messages sent to it, and fails if err is set
+type fake struct {
+	name string
+	err  error
+	sent []Message
+}
+
+func (t *fake) Name() string { return t.name }
+
+func (t *fake) Notify(m Message) error {
+	t.sent = append(t.sent, m)
+	return t.err
+}
+
+// reset clears the registry and routes, and returns a func to restore them
+func reset() func() {
+	mutex.Lock()
+	defer mutex.Unlock()
+	savedNotifiers, savedRoutes := notifiers, routes
+	notifiers, routes = make(map[string]Notifier), make(map[string][]string)
+	return func() {
+		mutex.Lock()
+		defer mutex.Unlock()
+		notifiers, routes = savedNotifiers, savedRoutes
+	}
+}
+
+func TestRoutes(t *testing.T) {
+	defer reset()()
+	slack, email, unrouted := &fake{name: "slack"}, &fake{name: "email"}, &fake{name: "discord"}
+	Register(slack)
+	Register(email)
+	Register(unrouted)
+	if names := Registered(); strings.Join(names, ",") != "discord,email,slack" {
+		t.Errorf("notify: registered wrong got:%v", names)
+	}
+
+	// Rules are sent to their own routes and those of *, each notifier once however often it is routed
+	Route("new_day", "slack", "email", "slack")
+	Route("*", "email", "missing")
+	err := Send(Message{Rule: "new_day", Title: "Data updated"})
+	if err != nil || len(slack.sent) != 1 || len(email.sent) != 1 || len(unrouted.sent) != 0 {
+		t.Errorf("notify: new_day sent wrong err:%v got:%d,%d,%d", err, len(slack.sent), len(email.sent), len(unrouted.sent))
+	}
+	err = Send(Message{Rule: "digest", Title: "Digest"})
+	if err != nil || len(slack.sent) != 1 || len(email.sent) != 2 || email.sent[1].Title != "Digest" {
+		t.Errorf("notify: digest sent wrong err:%v got:%d,%d", err, len(slack.sent), len(email.sent))
+	}
+
+	// Replacing a notifier sends to the new one
+	replaced := &fake{name: "slack"}
+	Register(replaced)
+	Send(Message{Rule: "new_day"})
+	if len(slack.sent) != 1 || len(replaced.sent) != 1 {
+		t.Errorf("notify: replaced notifier wrong got:%d,%d", len(slack.sent), len(replaced.sent))
+	}
+}
+
+func TestSendErrors(t *testing.T) {
+	defer reset()()
+	webhook, slack, email := &fake{name: "webhook", err: fmt.Errorf("timeout")}, &fake{name: "slack"}, &fake{name: "email", err: fmt.Errorf("refused")}
+	Register(webhook)
+	Register(slack)
+	Register(email)
+	Route("revisions", "webhook", "slack", "email")
+
+	// Every notifier is tried, and the failures are combined in one error
+	err := Send(Message{Rule: "revisions", Title: "Revisions"})
+	if err == nil || !strings.Contains(err.Error(), "webhook:timeout") || !strings.Contains(err.Error(), "email:refused") {
+		t.Errorf("notify: combined error wrong got:%v", err)
+	}
+	if len(webhook.sent) != 1 || len(slack.sent) != 1 || len(email.sent) != 1 {
+		t.Errorf("notify: notifiers not all tried got:%d,%d,%d", len(webhook.sent), len(slack.sent), len(email.sent))
+	}
+
+	// Rules without routes send nothing
+	if err := Send(Message{Rule: "unknown"}); err != nil || len(slack.sent) != 1 {
+		t.Errorf("notify: unrouted rule sent err:%v", err)
+	}
+}
+
+func TestMessage(t *testing.T) {
+	if s := (Message{Title: "Title"}).String(); s != "Title" {
+		t.Errorf("notify: message wrong got:%q", s)
+	}
+	if s := (Message{Title: "Title", Body: "Body"}).String(); s != "Title\nBody" {
+		t.Errorf("notify: message with body wrong got:%q", s)
+	}
+}
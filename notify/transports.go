@@ -0,0 +1,111 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// client is used for all http transports
+var client = &http.Client{Timeout: 30 * time.Second}
+
+// postJSON posts the value as json to the url given
+func postJSON(u string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Post(u, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status:%s", resp.Status)
+	}
+	return nil
+}
+
+// Webhook posts the message as json to a url
+type Webhook struct {
+	URL string
+}
+
+// Name returns the name of this transport
+func (t *Webhook) Name() string { return "webhook" }
+
+// Notify sends the message
+func (t *Webhook) Notify(m Message) error {
+	return postJSON(t.URL, map[string]string{"rule": m.Rule, "title": m.Title, "body": m.Body})
+}
+
+// Slack posts the message to a slack incoming webhook
+type Slack struct {
+	WebhookURL string
+}
+
+// Name returns the name of this transport
+func (t *Slack) Name() string { return "slack" }
+
+// Notify sends the message
+func (t *Slack) Notify(m Message) error {
+	return postJSON(t.WebhookURL, map[string]string{"text": m.String()})
+}
+
+// Discord posts the message to a discord webhook
+type Discord struct {
+	WebhookURL string
+}
+
+// Name returns the name of this transport
+func (t *Discord) Name() string { return "discord" }
+
+// Notify sends the message
+func (t *Discord) Notify(m Message) error {
+	return postJSON(t.WebhookURL, map[string]string{"content": m.String()})
+}
+
+// Telegram sends the message to a chat using the telegram bot api
+type Telegram struct {
+	Token  string
+	ChatID string
+}
+
+// Name returns the name of this transport
+func (t *Telegram) Name() string { return "telegram" }
+
+// Notify sends the message
+func (t *Telegram) Notify(m Message) error {
+	u := fmt.Sprintf("https://api.telegram.org/bot%s/sendMessage", url.PathEscape(t.Token))
+	return postJSON(u, map[string]string{"chat_id": t.ChatID, "text": m.String()})
+}
+
+// Email sends the message by smtp
+// Addr is the host:port of the smtp server, auth is used if Username is set
+type Email struct {
+	Addr     string
+	Username string
+	Password string
+	From     string
+	To       []string
+}
+
+// Name returns the name of this transport
+func (t *Email) Name() string { return "email" }
+
+// Notify sends the message
+func (t *Email) Notify(m Message) error {
+	var auth smtp.Auth
+	if t.Username != "" {
+		host := strings.Split(t.Addr, ":")[0]
+		auth = smtp.PlainAuth("", t.Username, t.Password, host)
+	}
+
+	msg := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\n\r\n%s\r\n", t.From, strings.Join(t.To, ", "), m.Title, m.Body)
+	return smtp.SendMail(t.Addr, auth, t.From, t.To, []byte(msg))
+}
@@ -0,0 +1,318 @@
+// Package parquet writes tables as Parquet files, which pandas, Spark and other analysis tools load directly
+// columns are flat and required, written with plain encoding and gzip compression in row groups of up to rowGroupRows
+// the thrift metadata is encoded here, so no library is required
+package parquet
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Kinds of column, dates are stored as days since 1970-01-01 and strings as UTF-8
+const (
+	Int32 = iota
+	Int64
+	String
+	Date
+	Bool
+)
+
+// rowGroupRows is the maximum number of rows in each row group
+const rowGroupRows = 1 << 16
+
+// Values of the enums in the parquet metadata
+const (
+	typeBoolean   = 0
+	typeInt32     = 1
+	typeInt64     = 2
+	typeByteArray = 6
+
+	repetitionRequired = 0
+
+	convertedUTF8 = 0
+	convertedDate = 6
+
+	encodingPlain = 0
+	encodingRLE   = 3
+
+	codecGzip = 2
+
+	pageData = 0
+)
+
+// magic starts and ends every parquet file
+var magic = []byte("PAR1")
+
+// Table is a table of columns with the same number of rows
+type Table struct {
+	// CreatedBy names the application writing the file
+	CreatedBy string
+
+	columns []*Column
+}
+
+// Column is one column of a table, values are appended with the method for its kind
+type Column struct {
+	Name string
+
+	kind    int
+	ints    []int64
+	strings []string
+	bools   []bool
+}
+
+// New returns an empty table
+func New(createdBy string) *Table {
+	return &Table{CreatedBy: createdBy}
+}
+
+// AddColumn adds a column of kind to the table and returns it
+func (t *Table) AddColumn(name string, kind int) *Column {
+	c := &Column{Name: name, kind: kind}
+	t.columns = append(t.columns, c)
+	return c
+}
+
+// Rows returns the number of rows in the table, which is the length of the first column
+func (t *Table) Rows() int {
+	if len(t.columns) == 0 {
+		return 0
+	}
+	return t.columns[0].Len()
+}
+
+// AppendInt appends a value to an Int32 or Int64 column
+func (c *Column) AppendInt(v int) {
+	c.ints = append(c.ints, int64(v))
+}
+
+// AppendDate appends the day of d to a Date column
+func (c *Column) AppendDate(d time.Time) {
+	c.ints = append(c.ints, d.Unix()/86400)
+}
+
+// AppendString appends a value to a String column
+func (c *Column) AppendString(s string) {
+	c.strings = append(c.strings, s)
+}
+
+// AppendBool appends a value to a Bool column
+func (c *Column) AppendBool(b bool) {
+	c.bools = append(c.bools, b)
+}
+
+// Len returns the number of values in the column
+func (c *Column) Len() int {
+	switch c.kind {
+	case String:
+		return len(c.strings)
+	case Bool:
+		return len(c.bools)
+	}
+	return len(c.ints)
+}
+
+// physicalType returns the type the column is stored as
+func (c *Column) physicalType() int32 {
+	switch c.kind {
+	case Int64:
+		return typeInt64
+	case String:
+		return typeByteArray
+	case Bool:
+		return typeBoolean
+	}
+	return typeInt32
+}
+
+// plain returns the values of rows start to end in the plain encoding of the column's type
+func (c *Column) plain(start, end int) []byte {
+	var buf bytes.Buffer
+	var b [8]byte
+	switch c.kind {
+	case Int64:
+		for _, v := range c.ints[start:end] {
+			binary.LittleEndian.PutUint64(b[:], uint64(v))
+			buf.Write(b[:8])
+		}
+	case String:
+		for _, s := range c.strings[start:end] {
+			binary.LittleEndian.PutUint32(b[:], uint32(len(s)))
+			buf.Write(b[:4])
+			buf.WriteString(s)
+		}
+	case Bool:
+		// Booleans are packed into bits, the first value in the lowest bit
+		packed := make([]byte, (end-start+7)/8)
+		for i, v := range c.bools[start:end] {
+			if v {
+				packed[i/8] |= 1 << uint(i%8)
+			}
+		}
+		buf.Write(packed)
+	default:
+		for _, v := range c.ints[start:end] {
+			binary.LittleEndian.PutUint32(b[:], uint32(v))
+			buf.Write(b[:4])
+		}
+	}
+	return buf.Bytes()
+}
+
+// chunk records where one column of a row group was written
+type chunk struct {
+	offset       int64
+	uncompressed int64
+	compressed   int64
+}
+
+// Write writes the table to w as a parquet file
+func (t *Table) Write(w io.Writer) error {
+	rows := t.Rows()
+	for _, c := range t.columns {
+		if c.Len() != rows {
+			return fmt.Errorf("parquet: column:%s has %d values, want:%d", c.Name, c.Len(), rows)
+		}
+	}
+
+	out := &countWriter{w: w}
+	out.Write(magic)
+
+	// Each row group holds a single data page for each column
+	var groups [][]chunk
+	for start := 0; start < rows; start += rowGroupRows {
+		end := start + rowGroupRows
+		if end > rows {
+			end = rows
+		}
+		var chunks []chunk
+		for _, c := range t.columns {
+			data := c.plain(start, end)
+			var compressed bytes.Buffer
+			z := gzip.NewWriter(&compressed)
+			z.Write(data)
+			err := z.Close()
+			if err != nil {
+				return err
+			}
+
+			header := &thrift{}
+			header.fields(func() {
+				header.i32(1, pageData)
+				header.i32(2, int32(len(data)))
+				header.i32(3, int32(compressed.Len()))
+				header.strct(5, func() {
+					header.i32(1, int32(end-start))
+					header.i32(2, encodingPlain)
+					header.i32(3, encodingRLE)
+					header.i32(4, encodingRLE)
+				})
+			})
+
+			chunks = append(chunks, chunk{
+				offset:       out.n,
+				uncompressed: int64(header.buf.Len() + len(data)),
+				compressed:   int64(header.buf.Len() + compressed.Len()),
+			})
+			out.Write(header.buf.Bytes())
+			out.Write(compressed.Bytes())
+		}
+		groups = append(groups, chunks)
+	}
+
+	meta := t.metadata(groups)
+	out.Write(meta)
+	var size [4]byte
+	binary.LittleEndian.PutUint32(size[:], uint32(len(meta)))
+	out.Write(size[:])
+	out.Write(magic)
+	return out.err
+}
+
+// metadata returns the file metadata describing the schema and the row groups written
+func (t *Table) metadata(groups [][]chunk) []byte {
+	rows := t.Rows()
+	m := &thrift{}
+	m.fields(func() {
+		m.i32(1, 1)
+
+		// The schema is a root element followed by the columns
+		m.structList(2, len(t.columns)+1, func(i int) {
+			if i == 0 {
+				m.string(4, "schema")
+				m.i32(5, int32(len(t.columns)))
+				return
+			}
+			c := t.columns[i-1]
+			m.i32(1, c.physicalType())
+			m.i32(3, repetitionRequired)
+			m.string(4, c.Name)
+			switch c.kind {
+			case String:
+				m.i32(6, convertedUTF8)
+				m.strct(10, func() { m.strct(1, func() {}) })
+			case Date:
+				m.i32(6, convertedDate)
+				m.strct(10, func() { m.strct(6, func() {}) })
+			}
+		})
+
+		m.i64(3, int64(rows))
+
+		m.structList(4, len(groups), func(g int) {
+			var total int64
+			m.structList(1, len(t.columns), func(i int) {
+				c, ch := t.columns[i], groups[g][i]
+				total += ch.uncompressed
+				m.i64(2, ch.offset)
+				m.strct(3, func() {
+					m.i32(1, c.physicalType())
+					m.i32List(2, encodingPlain, encodingRLE)
+					m.stringList(3, c.Name)
+					m.i32(4, codecGzip)
+					m.i64(5, int64(groupRows(rows, g)))
+					m.i64(6, ch.uncompressed)
+					m.i64(7, ch.compressed)
+					m.i64(9, ch.offset)
+				})
+			})
+			m.i64(2, total)
+			m.i64(3, int64(groupRows(rows, g)))
+		})
+
+		if t.CreatedBy != "" {
+			m.string(6, t.CreatedBy)
+		}
+	})
+	return m.buf.Bytes()
+}
+
+// groupRows returns the number of rows in row group g of a table with rows
+func groupRows(rows, g int) int {
+	if n := rows - g*rowGroupRows; n < rowGroupRows {
+		return n
+	}
+	return rowGroupRows
+}
+
+// countWriter counts the bytes written to w, and records the first error so that writes may be checked once
+type countWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+// Write writes p to w unless a write has failed
+func (cw *countWriter) Write(p []byte) (int, error) {
+	if cw.err != nil {
+		return 0, cw.err
+	}
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	cw.err = err
+	return n, err
+}
@@ -0,0 +1,109 @@
+package parquet
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+func TestThrift(t *testing.T) {
+	th := &thrift{}
+	th.fields(func() {
+		th.i32(1, -2)
+		th.string(4, "ab")
+		th.i64(20, 300)
+		th.strct(21, func() { th.i32(1, 1) })
+		th.i32List(22, make([]int32, 15)...)
+	})
+	want := []byte{
+		0x15, 0x03, // field 1 i32 zigzag -2
+		0x38, 0x02, 'a', 'b', // field 4 binary
+		0x06, 0x28, 0xd8, 0x04, // field 20 i64 in long form, zigzag 300
+		0x1c, 0x15, 0x02, 0x00, // field 21 struct with field 1 i32 1
+		0x19, 0xf5, 0x0f, // field 22 list of 15 i32 in long form
+		0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+		0x00,
+	}
+	if !bytes.Equal(th.buf.Bytes(), want) {
+		t.Errorf("parquet: wrong thrift got:%x want:%x", th.buf.Bytes(), want)
+	}
+}
+
+func TestPlain(t *testing.T) {
+	tb := New("test")
+	date := tb.AddColumn("date", Date)
+	name := tb.AddColumn("name", String)
+	flag := tb.AddColumn("flag", Bool)
+	big := tb.AddColumn("big", Int64)
+	for i := 0; i < 9; i++ {
+		date.AppendDate(time.Date(1970, 1, 2+i, 0, 0, 0, 0, time.UTC))
+		name.AppendString("x")
+		flag.AppendBool(i%3 == 0)
+		big.AppendInt(-i)
+	}
+
+	if got := date.plain(0, 2); !bytes.Equal(got, []byte{1, 0, 0, 0, 2, 0, 0, 0}) {
+		t.Errorf("parquet: wrong dates got:%v", got)
+	}
+	if got := name.plain(0, 1); !bytes.Equal(got, []byte{1, 0, 0, 0, 'x'}) {
+		t.Errorf("parquet: wrong strings got:%v", got)
+	}
+	if got := flag.plain(0, 9); !bytes.Equal(got, []byte{0x49, 0x00}) {
+		t.Errorf("parquet: wrong bools got:%v", got)
+	}
+	if got := big.plain(1, 2); !bytes.Equal(got, []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}) {
+		t.Errorf("parquet: wrong int64 got:%v", got)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	tb := New("test")
+	id := tb.AddColumn("id", Int32)
+	for i := 0; i < rowGroupRows+10; i++ {
+		id.AppendInt(i)
+	}
+
+	var buf bytes.Buffer
+	err := tb.Write(&buf)
+	if err != nil {
+		t.Fatalf("parquet: failed to write:%s", err)
+	}
+	data := buf.Bytes()
+	size := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	if !bytes.HasPrefix(data, magic) || !bytes.HasSuffix(data, magic) || size <= 0 || size > len(data)-12 {
+		t.Fatalf("parquet: wrong magic or metadata size:%d", size)
+	}
+
+	// The first page follows the magic and its header, and holds the first row group
+	header := &thrift{}
+	header.fields(func() {
+		header.i32(1, pageData)
+		header.i32(2, rowGroupRows*4)
+	})
+	start := len(magic) + bytes.Index(data[len(magic):], []byte{0x1f, 0x8b})
+	z, err := gzip.NewReader(bytes.NewReader(data[start:]))
+	if err != nil {
+		t.Fatalf("parquet: failed to read page:%s", err)
+	}
+	z.Multistream(false)
+	page, err := ioutil.ReadAll(z)
+	if err != nil || len(page) != rowGroupRows*4 || binary.LittleEndian.Uint32(page[len(page)-4:]) != rowGroupRows-1 {
+		t.Errorf("parquet: wrong first page length:%d err:%v", len(page), err)
+	}
+	if !bytes.HasPrefix(data[len(magic):], header.buf.Bytes()[:len(header.buf.Bytes())-1]) {
+		t.Errorf("parquet: wrong page header got:%x", data[len(magic):start])
+	}
+
+	if groupRows(rowGroupRows+10, 0) != rowGroupRows || groupRows(rowGroupRows+10, 1) != 10 {
+		t.Errorf("parquet: wrong row group rows")
+	}
+
+	// Columns must have the same number of values
+	tb.AddColumn("short", Int64).AppendInt(1)
+	if tb.Write(&bytes.Buffer{}) == nil {
+		t.Errorf("parquet: wrote columns of different lengths")
+	}
+}
@@ -0,0 +1,42 @@
+package parquet
+
+import (
+	"io"
+
+	"github.com/kennygrant/coronavirus/series"
+)
+
+// WriteSeries writes every day of the areas to w as a parquet file with a row per area per day
+// columns are date, area_id, country, province, deaths, confirmed, recovered, tested and provisional
+// values are cumulative as in the series file, and days with no data are omitted
+func WriteSeries(w io.Writer, areas series.Slice) error {
+	t := New("coronavirus")
+	date := t.AddColumn("date", Date)
+	areaID := t.AddColumn("area_id", Int32)
+	country := t.AddColumn("country", String)
+	province := t.AddColumn("province", String)
+	deaths := t.AddColumn("deaths", Int64)
+	confirmed := t.AddColumn("confirmed", Int64)
+	recovered := t.AddColumn("recovered", Int64)
+	tested := t.AddColumn("tested", Int64)
+	provisional := t.AddColumn("provisional", Bool)
+
+	for _, s := range areas {
+		for _, day := range s.Days {
+			if day.IsZero() {
+				continue
+			}
+			date.AppendDate(day.Date)
+			areaID.AppendInt(s.ID)
+			country.AppendString(s.Country)
+			province.AppendString(s.Province)
+			deaths.AppendInt(day.Value(series.DataDeaths))
+			confirmed.AppendInt(day.Value(series.DataConfirmed))
+			recovered.AppendInt(day.Value(series.DataRecovered))
+			tested.AppendInt(day.Value(series.DataTested))
+			provisional.AppendBool(day.Provisional)
+		}
+	}
+
+	return t.Write(w)
+}
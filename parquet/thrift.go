@@ -0,0 +1,115 @@
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// Types of the thrift compact protocol used in field and list headers
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thrift encodes structs with the thrift compact protocol, used for page headers and file metadata
+// fields must be written in order of their ids, as ids are written as deltas from the field before
+type thrift struct {
+	buf  bytes.Buffer
+	last int16
+}
+
+// field writes the header of field id with type
+func (t *thrift) field(id int16, kind byte) {
+	if delta := id - t.last; delta > 0 && delta <= 15 {
+		t.buf.WriteByte(byte(delta)<<4 | kind)
+	} else {
+		t.buf.WriteByte(kind)
+		t.varint(uint64(zigzag(int64(id))))
+	}
+	t.last = id
+}
+
+// i32 writes an i32 field
+func (t *thrift) i32(id int16, v int32) {
+	t.field(id, thriftI32)
+	t.varint(zigzag(int64(v)))
+}
+
+// i64 writes an i64 field
+func (t *thrift) i64(id int16, v int64) {
+	t.field(id, thriftI64)
+	t.varint(zigzag(v))
+}
+
+// string writes a binary field
+func (t *thrift) string(id int16, s string) {
+	t.field(id, thriftBinary)
+	t.varint(uint64(len(s)))
+	t.buf.WriteString(s)
+}
+
+// strct writes a struct field, with the fields written by f
+func (t *thrift) strct(id int16, f func()) {
+	t.field(id, thriftStruct)
+	t.fields(f)
+}
+
+// fields writes the fields of a struct with f, followed by the stop byte
+// field ids within the struct start again from zero
+func (t *thrift) fields(f func()) {
+	last := t.last
+	t.last = 0
+	f()
+	t.buf.WriteByte(0)
+	t.last = last
+}
+
+// list writes the header of a list field of n elements of type kind, the elements follow without headers
+func (t *thrift) list(id int16, kind byte, n int) {
+	t.field(id, thriftList)
+	if n < 15 {
+		t.buf.WriteByte(byte(n)<<4 | kind)
+	} else {
+		t.buf.WriteByte(0xf0 | kind)
+		t.varint(uint64(n))
+	}
+}
+
+// i32List writes a list field of i32 values
+func (t *thrift) i32List(id int16, values ...int32) {
+	t.list(id, thriftI32, len(values))
+	for _, v := range values {
+		t.varint(zigzag(int64(v)))
+	}
+}
+
+// stringList writes a list field of binary values
+func (t *thrift) stringList(id int16, values ...string) {
+	t.list(id, thriftBinary, len(values))
+	for _, v := range values {
+		t.varint(uint64(len(v)))
+		t.buf.WriteString(v)
+	}
+}
+
+// structList writes a list field of n structs, with the fields of each written by f
+func (t *thrift) structList(id int16, n int, f func(i int)) {
+	t.list(id, thriftStruct, n)
+	for i := 0; i < n; i++ {
+		t.fields(func() { f(i) })
+	}
+}
+
+// varint writes v as an unsigned varint
+func (t *thrift) varint(v uint64) {
+	var b [binary.MaxVarintLen64]byte
+	t.buf.Write(b[:binary.PutUvarint(b[:], v)])
+}
+
+// zigzag encodes signed values so that small negative values have short varints
+func zigzag(v int64) uint64 {
+	return uint64(v<<1) ^ uint64(v>>63)
+}
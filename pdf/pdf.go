@@ -0,0 +1,181 @@
+// Package pdf writes simple PDF documents of text, filled rectangles and images
+// only the standard Helvetica fonts are used, so no fonts are embedded, and text is limited to the WinAnsi characters
+// this is enough for reports, without depending on a full PDF library
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"strings"
+)
+
+// Page sizes in points, 72 to the inch
+const (
+	A4Width  = 595
+	A4Height = 842
+)
+
+// Fonts available for text, the standard fonts every reader provides
+const (
+	Helvetica     = "F1"
+	HelveticaBold = "F2"
+)
+
+// fontNames are the base font names of each font resource
+var fontNames = map[string]string{
+	Helvetica:     "Helvetica",
+	HelveticaBold: "Helvetica-Bold",
+}
+
+// Document is a PDF document of A4 pages
+type Document struct {
+	Title string
+	pages []*Page
+}
+
+// Page is one page of a document, positions are in points from the bottom left corner
+type Page struct {
+	content bytes.Buffer
+	images  []image.Image
+}
+
+// New returns an empty document with the title given
+func New(title string) *Document {
+	return &Document{Title: title}
+}
+
+// AddPage adds a page to the end of the document and returns it
+func (d *Document) AddPage() *Page {
+	p := &Page{}
+	d.pages = append(d.pages, p)
+	return p
+}
+
+// Text draws s with its baseline starting at x, y in font at size points
+// characters which are not in the WinAnsi encoding are replaced by ?
+func (p *Page) Text(x, y float64, font string, size float64, col color.Color, s string) {
+	fmt.Fprintf(&p.content, "BT %s rg /%s %.1f Tf %.2f %.2f Td (%s) Tj ET\n", rgb(col), font, size, x, y, escape(s))
+}
+
+// Rect fills the rectangle with its bottom left corner at x, y
+func (p *Page) Rect(x, y, width, height float64, col color.Color) {
+	fmt.Fprintf(&p.content, "%s rg %.2f %.2f %.2f %.2f re f\n", rgb(col), x, y, width, height)
+}
+
+// Image draws img scaled to width x height points with its bottom left corner at x, y
+func (p *Page) Image(x, y, width, height float64, img image.Image) {
+	p.images = append(p.images, img)
+	fmt.Fprintf(&p.content, "q %.2f 0 0 %.2f %.2f %.2f cm /Im%d Do Q\n", width, height, x, y, len(p.images))
+}
+
+// Write writes the document to w
+func (d *Document) Write(w io.Writer) error {
+	var buf bytes.Buffer
+	var offsets []int
+
+	// Objects are numbered from 1 in the order written, and their offsets listed in the xref table
+	object := func(body string) int {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+		return len(offsets)
+	}
+	stream := func(dict string, data []byte) int {
+		return object(fmt.Sprintf("<< %s /Length %d >>\nstream\n%s\nendstream", dict, len(data), data))
+	}
+
+	buf.WriteString("%PDF-1.4\n")
+
+	// The catalog and page tree are written first, referring to pages by their numbers
+	// each page is written after its images and contents
+	catalog := object("<< /Type /Catalog /Pages 2 0 R >>")
+	var kids []string
+	next := catalog + 4
+	for _, p := range d.pages {
+		next += len(p.images) + 2
+		kids = append(kids, fmt.Sprintf("%d 0 R", next))
+	}
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+	fonts := object(fmt.Sprintf("<< /Type /Font /Subtype /Type1 /BaseFont /%s /Encoding /WinAnsiEncoding >>", fontNames[Helvetica]))
+	object(fmt.Sprintf("<< /Type /Font /Subtype /Type1 /BaseFont /%s /Encoding /WinAnsiEncoding >>", fontNames[HelveticaBold]))
+	object(fmt.Sprintf("<< /Title (%s) /Producer (coronavirus) >>", escape(d.Title)))
+
+	for _, p := range d.pages {
+		var xobjects []string
+		for i, img := range p.images {
+			data, err := imageData(img)
+			if err != nil {
+				return err
+			}
+			b := img.Bounds()
+			n := stream(fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceRGB /BitsPerComponent 8 /Filter /FlateDecode", b.Dx(), b.Dy()), data)
+			xobjects = append(xobjects, fmt.Sprintf("/Im%d %d 0 R", i+1, n))
+		}
+		contents := stream("", p.content.Bytes())
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Contents %d 0 R /Resources << /Font << /%s %d 0 R /%s %d 0 R >> /XObject << %s >> >> >>",
+			A4Width, A4Height, contents, Helvetica, fonts, HelveticaBold, fonts+1, strings.Join(xobjects, " ")))
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, o := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", o)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root %d 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, catalog, fonts+2, xref)
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// imageData returns the pixels of img as compressed 8 bit rgb
+func imageData(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	z := zlib.NewWriter(&buf)
+	bounds := img.Bounds()
+	row := make([]byte, 0, bounds.Dx()*3)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		row = row[:0]
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, b, _ := img.At(x, y).RGBA()
+			row = append(row, byte(r>>8), byte(g>>8), byte(b>>8))
+		}
+		_, err := z.Write(row)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err := z.Close()
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// rgb returns the color as operands of the rg operator
+func rgb(col color.Color) string {
+	r, g, b, _ := col.RGBA()
+	return fmt.Sprintf("%.3f %.3f %.3f", float64(r)/0xffff, float64(g)/0xffff, float64(b)/0xffff)
+}
+
+// escape returns s as the contents of a PDF string in the WinAnsi encoding
+// Latin-1 characters have the same codes in WinAnsi, other characters are replaced by ?
+func escape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '\\' || r == '(' || r == ')':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r >= 32 && r < 127:
+			b.WriteRune(r)
+		case r >= 160 && r <= 255:
+			fmt.Fprintf(&b, "\\%03o", r)
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
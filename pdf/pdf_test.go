@@ -0,0 +1,60 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	doc := New("Test (report)")
+	page := doc.AddPage()
+	page.Text(40, 800, HelveticaBold, 20, color.Black, "Côte d'Ivoire (total) → up")
+	page.Rect(40, 790, 500, 1, color.Gray{200})
+	page.Image(40, 600, 200, 100, image.NewRGBA(image.Rect(0, 0, 4, 2)))
+	doc.AddPage().Text(40, 800, Helvetica, 10, color.Black, "Second page")
+
+	var buf bytes.Buffer
+	err := doc.Write(&buf)
+	if err != nil {
+		t.Fatalf("pdf: failed to write:%s", err)
+	}
+	data := buf.Bytes()
+	if !bytes.HasPrefix(data, []byte("%PDF-1.4\n")) || !bytes.HasSuffix(data, []byte("%%EOF\n")) {
+		t.Fatalf("pdf: wrong header or trailer got:%s", data)
+	}
+
+	// Text is escaped, with characters outside WinAnsi replaced
+	if !bytes.Contains(data, []byte(`(C\364te d'Ivoire \(total\) ? up) Tj`)) || !bytes.Contains(data, []byte("/Title (Test \\(report\\))")) {
+		t.Errorf("pdf: text not escaped got:%s", data)
+	}
+
+	// Every object is at the offset listed in the xref table
+	start, err := strconv.Atoi(strings.Fields(string(data[bytes.LastIndex(data, []byte("startxref"))+9:]))[0])
+	if err != nil || !bytes.HasPrefix(data[start:], []byte("xref\n")) {
+		t.Fatalf("pdf: wrong startxref got:%d", start)
+	}
+	entries := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllSubmatch(data[start:], -1)
+	if len(entries) != 10 {
+		t.Errorf("pdf: wrong object count got:%d", len(entries))
+	}
+	for i, e := range entries {
+		offset, _ := strconv.Atoi(string(e[1]))
+		if !bytes.HasPrefix(data[offset:], []byte(fmt.Sprintf("%d 0 obj\n", i+1))) {
+			t.Errorf("pdf: wrong offset for object:%d", i+1)
+		}
+	}
+
+	// Both pages are listed in the page tree
+	kids := regexp.MustCompile(`/Kids \[(\d+) 0 R (\d+) 0 R\]`).FindSubmatch(data)
+	for _, kid := range kids[1:] {
+		if !bytes.Contains(data, []byte(fmt.Sprintf("%s 0 obj\n<< /Type /Page ", kid))) {
+			t.Errorf("pdf: kid:%s is not a page", kid)
+		}
+	}
+}
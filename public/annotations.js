@@ -0,0 +1,57 @@
+/* Draw annotations from chart options as dashed vertical lines with labels */
+/* each annotation has an index into the chart labels, a label and a color */
+/* notes are drawn as markers at the top of the chart, with the label shown in tooltips on hover */
+Chart.plugins.register({
+    beforeInit: function(chart) {
+        // Leave any footer set by the chart options in place
+        var tooltips = chart.options.tooltips;
+        if (tooltips.callbacks.footer !== Chart.defaults.global.tooltips.callbacks.footer) {
+            return;
+        }
+        tooltips.callbacks.footer = function(items) {
+            var annotations = chart.options.annotations || [];
+            var notes = [];
+            for (var i = 0; i < annotations.length; i++) {
+                if (annotations[i].note && items.length > 0 && annotations[i].index == items[0].index) {
+                    notes.push(annotations[i].label);
+                }
+            }
+            return notes;
+        };
+    },
+    afterDatasetsDraw: function(chart) {
+        var annotations = chart.options.annotations;
+        if (!annotations || annotations.length == 0) {
+            return;
+        }
+
+        var xAxis = chart.scales['x-axis-0'];
+        var yAxis = chart.scales['y-axis-0'];
+        var ctx = chart.ctx;
+
+        ctx.save();
+        var line = 0;
+        for (var i = 0; i < annotations.length; i++) {
+            var a = annotations[i];
+            var x = xAxis.getPixelForValue(null, a.index);
+            ctx.strokeStyle = a.color;
+            ctx.fillStyle = a.color;
+            if (a.note) {
+                ctx.beginPath();
+                ctx.arc(x, yAxis.top + 4, 4, 0, 2 * Math.PI);
+                ctx.fill();
+                continue;
+            }
+            ctx.lineWidth = 2;
+            ctx.setLineDash([4, 4]);
+            ctx.beginPath();
+            ctx.moveTo(x, yAxis.top);
+            ctx.lineTo(x, yAxis.bottom);
+            ctx.stroke();
+            ctx.textAlign = 'left';
+            ctx.fillText(a.label, x + 4, yAxis.top + 12 + line * 14);
+            line++;
+        }
+        ctx.restore();
+    }
+});
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/kennygrant/coronavirus/series"
+)
+
+// RankingRow is one row of the rankings table, a ranking with the area it ranks
+type RankingRow struct {
+	series.Ranking
+	Title string `json:"title"`
+	Path  string `json:"path"`
+	Value int    `json:"value"`
+}
+
+// handleRankings shows countries ranked by one data kind with the places each moved since last week, or as json at .json
+// params: kind=deaths|confirmed|tested (default deaths)
+func handleRankings(w http.ResponseWriter, r *http.Request) {
+
+	log.Printf("request:rankings%s", r.URL)
+
+	kind := param(r, "kind")
+	if kind == "" {
+		kind = "deaths"
+	}
+	dataKind := series.DataKindFromName(kind)
+	ranked := false
+	for _, k := range series.RankKinds {
+		ranked = ranked || k == dataKind
+	}
+	if !ranked {
+		http.Error(w, fmt.Sprintf("invalid kind:%s", kind), http.StatusBadRequest)
+		return
+	}
+
+	kinds := make([]string, len(series.RankKinds))
+	for i, k := range series.RankKinds {
+		kinds[i] = series.DataKindName(k)
+	}
+
+	rows := []RankingRow{}
+	for _, ranking := range series.Rankings(dataKind) {
+		s, err := series.FindSeries(ranking.AreaID)
+		if err != nil {
+			continue
+		}
+		rows = append(rows, RankingRow{
+			Ranking: ranking,
+			Title:   s.Title(),
+			Path:    "/" + s.Key(s.Country),
+			Value:   s.LastDay().Value(dataKind),
+		})
+	}
+
+	if strings.HasSuffix(r.URL.Path, ".json") {
+		renderJSON(w, r, rows)
+		return
+	}
+
+	locale := requestLocale(r)
+	context := map[string]interface{}{
+		"kind":    kind,
+		"kinds":   kinds,
+		"rows":    rows,
+		"days":    series.RankChangeDays,
+		"full":    series.Formatter{Locale: locale, Full: true},
+		"jsonURL": fmt.Sprintf("/rankings.json?kind=%s", kind),
+	}
+
+	if development {
+		loadTemplates()
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(200)
+	err := rankingsTemplate.Execute(w, context)
+	if err != nil {
+		log.Printf("template render error:%s", err)
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kennygrant/coronavirus/rpc"
+)
+
+// limiter limits the rate of json requests from each ip with a token bucket
+// each ip may make burst requests at once, then rate requests per second
+type limiter struct {
+	mu        sync.Mutex
+	rate      float64
+	burst     float64
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// bucket holds the tokens available to one ip
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// apiLimiter limits requests to json endpoints, nil if rate limiting is disabled
+var apiLimiter *limiter
+
+// setupRateLimit sets the rate limit for json endpoints from config
+// server.rate_limit sets requests per minute per ip, 0 disables limits, and server.rate_burst the burst allowed
+func setupRateLimit() {
+	if cfg.Server.RateLimit <= 0 {
+		log.Printf("server: rate limiting disabled")
+		return
+	}
+	apiLimiter = newLimiter(float64(cfg.Server.RateLimit)/60, cfg.Server.RateBurst)
+}
+
+// newLimiter returns a limiter allowing rate requests per second after a burst
+func newLimiter(rate float64, burst int) *limiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &limiter{
+		rate:    rate,
+		burst:   float64(burst),
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// allow takes a token for ip at now if one is available
+// if not it returns false and the time until one will be
+func (l *limiter) allow(ip string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Remove buckets which have refilled, so that the map does not grow without limit
+	if now.Sub(l.lastSweep) > time.Minute {
+		for k, b := range l.buckets {
+			if b.refill(now, l.rate, l.burst) >= l.burst {
+				delete(l.buckets, k)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	b, ok := l.buckets[ip]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[ip] = b
+	}
+	if b.refill(now, l.rate, l.burst) < 1 {
+		return false, time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	}
+	b.tokens--
+	return true, 0
+}
+
+// refill adds the tokens earned since the bucket was last used, up to burst, and returns the tokens available
+func (b *bucket) refill(now time.Time, rate, burst float64) float64 {
+	b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+	return b.tokens
+}
+
+// rateLimit limits json requests handled by h to the rate of apiLimiter per ip
+// requests over the limit receive a 429 with a Retry-After header in seconds
+// requests with a valid api key are recorded against the key instead of being limited
+func rateLimit(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isAPIRequest(r) && useAPIKey(r) {
+			h.ServeHTTP(w, r)
+			return
+		}
+		if apiLimiter != nil && isAPIRequest(r) {
+			ok, wait := apiLimiter.allow(remoteIP(r), time.Now())
+			if !ok {
+				w.Header().Set("Retry-After", fmt.Sprintf("%d", int(math.Ceil(wait.Seconds()))))
+				http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
+				return
+			}
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// isAPIRequest returns true if the request is for json data or a gRPC call rather than a page
+func isAPIRequest(r *http.Request) bool {
+	p := r.URL.Path
+	return strings.HasPrefix(p, "/api/") || strings.HasSuffix(p, ".json") || p == "/compare/correlation" || p == "/compare/vaccination" || strings.HasPrefix(p, rpc.Prefix)
+}
+
+// remoteIP returns the ip of the client, the server is not run behind a proxy so forwarded headers are ignored
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
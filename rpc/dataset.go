@@ -0,0 +1,47 @@
+package rpc
+
+import (
+	"io"
+	"io/ioutil"
+
+	"github.com/kennygrant/coronavirus/series"
+)
+
+// WriteDataset writes the areas to w as a Dataset message with every day of each, see series.proto
+// a repeated field may be written in parts, so each series is written as it is encoded
+func WriteDataset(w io.Writer, areas series.Slice) error {
+	for _, s := range areas {
+		e := &encoder{}
+		e.message(1, NewSeries(s).Marshal())
+		_, err := w.Write(e.buf)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadDataset reads a Dataset message from r and returns the series it holds
+func ReadDataset(r io.Reader) (series.Slice, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var slice series.Slice
+	err = decode(b, func(f field) error {
+		if f.number != 1 || f.wireType != wireBytes {
+			return nil
+		}
+		var m Series
+		err := m.Unmarshal(f.data)
+		if err != nil {
+			return err
+		}
+		slice = append(slice, m.Data())
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return slice, nil
+}
@@ -0,0 +1,352 @@
+package rpc
+
+import (
+	"math"
+	"time"
+
+	"github.com/kennygrant/coronavirus/series"
+)
+
+// AreaQuery filters the areas listed, see series.proto
+type AreaQuery struct {
+	Country       string
+	CountriesOnly bool
+	Search        string
+	Limit         int
+}
+
+// Unmarshal decodes the query from the protobuf message b
+func (q *AreaQuery) Unmarshal(b []byte) error {
+	return decode(b, func(f field) error {
+		switch {
+		case f.number == 1 && f.wireType == wireBytes:
+			q.Country = f.string()
+		case f.number == 2 && f.wireType == wireVarint:
+			q.CountriesOnly = f.value != 0
+		case f.number == 3 && f.wireType == wireBytes:
+			q.Search = f.string()
+		case f.number == 4 && f.wireType == wireVarint:
+			q.Limit = int(int32(f.value))
+		}
+		return nil
+	})
+}
+
+// Marshal encodes the query as a protobuf message
+func (q *AreaQuery) Marshal() []byte {
+	e := &encoder{}
+	e.string(1, q.Country)
+	e.bool(2, q.CountriesOnly)
+	e.string(3, q.Search)
+	e.int(4, int64(q.Limit))
+	return e.buf
+}
+
+// Area is a country or province with its latest totals, see series.proto
+type Area struct {
+	ID         int
+	Country    string
+	Province   string
+	Population int
+	Latitude   float64
+	Longitude  float64
+	Deaths     int
+	Confirmed  int
+	Recovered  int
+	Tested     int
+	UpdatedAt  time.Time
+}
+
+// NewArea returns the area for series s with its totals on the last day
+func NewArea(s *series.Data) *Area {
+	a := &Area{
+		ID:         s.ID,
+		Country:    s.Country,
+		Province:   s.Province,
+		Population: s.Population,
+		Latitude:   s.Latitude,
+		Longitude:  s.Longitude,
+		UpdatedAt:  s.UpdatedAt,
+	}
+	if day := s.LastDay(); day != nil {
+		a.Deaths, a.Confirmed, a.Recovered, a.Tested = day.Value(series.DataDeaths), day.Value(series.DataConfirmed), day.Value(series.DataRecovered), day.Value(series.DataTested)
+	}
+	return a
+}
+
+// Marshal encodes the area as a protobuf message
+func (a *Area) Marshal() []byte {
+	e := &encoder{}
+	e.int(1, int64(a.ID))
+	e.string(2, a.Country)
+	e.string(3, a.Province)
+	e.int(4, int64(a.Population))
+	e.double(5, a.Latitude)
+	e.double(6, a.Longitude)
+	e.int(7, int64(a.Deaths))
+	e.int(8, int64(a.Confirmed))
+	e.int(9, int64(a.Recovered))
+	e.int(10, int64(a.Tested))
+	if !a.UpdatedAt.IsZero() {
+		e.string(11, a.UpdatedAt.UTC().Format(time.RFC3339))
+	}
+	return e.buf
+}
+
+// Unmarshal decodes the area from the protobuf message b
+func (a *Area) Unmarshal(b []byte) error {
+	return decode(b, func(f field) error {
+		switch {
+		case f.wireType == wireBytes && f.number == 2:
+			a.Country = f.string()
+		case f.wireType == wireBytes && f.number == 3:
+			a.Province = f.string()
+		case f.wireType == wireBytes && f.number == 11:
+			a.UpdatedAt, _ = time.Parse(time.RFC3339, f.string())
+		case f.wireType == wireFixed64 && f.number == 5:
+			a.Latitude = math.Float64frombits(f.value)
+		case f.wireType == wireFixed64 && f.number == 6:
+			a.Longitude = math.Float64frombits(f.value)
+		case f.wireType == wireVarint:
+			v := int(f.int())
+			switch f.number {
+			case 1:
+				a.ID = v
+			case 4:
+				a.Population = v
+			case 7:
+				a.Deaths = v
+			case 8:
+				a.Confirmed = v
+			case 9:
+				a.Recovered = v
+			case 10:
+				a.Tested = v
+			}
+		}
+		return nil
+	})
+}
+
+// DayQuery selects an area and optionally a range of dates, see series.proto
+type DayQuery struct {
+	AreaID   int
+	Country  string
+	Province string
+	From     string
+	To       string
+}
+
+// Unmarshal decodes the query from the protobuf message b
+func (q *DayQuery) Unmarshal(b []byte) error {
+	return decode(b, func(f field) error {
+		switch {
+		case f.number == 1 && f.wireType == wireVarint:
+			q.AreaID = int(int32(f.value))
+		case f.number == 2 && f.wireType == wireBytes:
+			q.Country = f.string()
+		case f.number == 3 && f.wireType == wireBytes:
+			q.Province = f.string()
+		case f.number == 4 && f.wireType == wireBytes:
+			q.From = f.string()
+		case f.number == 5 && f.wireType == wireBytes:
+			q.To = f.string()
+		}
+		return nil
+	})
+}
+
+// Marshal encodes the query as a protobuf message
+func (q *DayQuery) Marshal() []byte {
+	e := &encoder{}
+	e.int(1, int64(q.AreaID))
+	e.string(2, q.Country)
+	e.string(3, q.Province)
+	e.string(4, q.From)
+	e.string(5, q.To)
+	return e.buf
+}
+
+// Day holds the cumulative counts for one area on one date, see series.proto
+type Day struct {
+	Date        time.Time
+	Deaths      int
+	Confirmed   int
+	Recovered   int
+	Tested      int
+	Provisional bool
+}
+
+// NewDay returns the message for a day of a series
+func NewDay(d *series.Day) *Day {
+	return &Day{
+		Date:        d.Date,
+		Deaths:      d.Value(series.DataDeaths),
+		Confirmed:   d.Value(series.DataConfirmed),
+		Recovered:   d.Value(series.DataRecovered),
+		Tested:      d.Value(series.DataTested),
+		Provisional: d.Provisional,
+	}
+}
+
+// Marshal encodes the day as a protobuf message
+func (d *Day) Marshal() []byte {
+	e := &encoder{}
+	e.string(1, d.Date.Format("2006-01-02"))
+	e.int(2, int64(d.Deaths))
+	e.int(3, int64(d.Confirmed))
+	e.int(4, int64(d.Recovered))
+	e.int(5, int64(d.Tested))
+	e.bool(6, d.Provisional)
+	return e.buf
+}
+
+// Unmarshal decodes the day from the protobuf message b
+func (d *Day) Unmarshal(b []byte) error {
+	return decode(b, func(f field) error {
+		switch {
+		case f.number == 1 && f.wireType == wireBytes:
+			d.Date, _ = time.Parse("2006-01-02", f.string())
+		case f.wireType == wireVarint:
+			v := int(f.int())
+			switch f.number {
+			case 2:
+				d.Deaths = v
+			case 3:
+				d.Confirmed = v
+			case 4:
+				d.Recovered = v
+			case 5:
+				d.Tested = v
+			case 6:
+				d.Provisional = v != 0
+			}
+		}
+		return nil
+	})
+}
+
+// Series is an area with the cumulative counts of every day in columns, see series.proto
+// columns are packed, so a series is far smaller than a message for each day
+type Series struct {
+	Area        Area
+	StartDate   time.Time
+	Deaths      []int
+	Confirmed   []int
+	Recovered   []int
+	Tested      []int
+	Provisional []bool
+}
+
+// NewSeries returns the message for series s with all of its days
+func NewSeries(s *series.Data) *Series {
+	m := &Series{Area: *NewArea(s)}
+	if len(s.Days) == 0 {
+		return m
+	}
+	m.StartDate = s.Days[0].Date
+	provisional := false
+	for _, day := range s.Days {
+		m.Deaths = append(m.Deaths, day.Value(series.DataDeaths))
+		m.Confirmed = append(m.Confirmed, day.Value(series.DataConfirmed))
+		m.Recovered = append(m.Recovered, day.Value(series.DataRecovered))
+		m.Tested = append(m.Tested, day.Value(series.DataTested))
+		m.Provisional = append(m.Provisional, day.Provisional)
+		provisional = provisional || day.Provisional
+	}
+	// Provisional days are rare, so the column is left out if there are none
+	if !provisional {
+		m.Provisional = nil
+	}
+	return m
+}
+
+// Data returns the series with these days, so series encoded as messages can be restored
+func (m *Series) Data() *series.Data {
+	s := &series.Data{
+		ID:         m.Area.ID,
+		Country:    m.Area.Country,
+		Province:   m.Area.Province,
+		Population: m.Area.Population,
+		Latitude:   m.Area.Latitude,
+		Longitude:  m.Area.Longitude,
+		UpdatedAt:  m.Area.UpdatedAt,
+	}
+
+	// Columns of zeros are left out when encoded, so the days are the longest column
+	count := 0
+	for _, values := range [][]int{m.Deaths, m.Confirmed, m.Recovered, m.Tested} {
+		if len(values) > count {
+			count = len(values)
+		}
+	}
+	if len(m.Provisional) > count {
+		count = len(m.Provisional)
+	}
+
+	s.AddDaysFrom(m.StartDate, count)
+	s.SetData(m.StartDate, series.DataDeaths, m.Deaths)
+	s.SetData(m.StartDate, series.DataConfirmed, m.Confirmed)
+	s.SetData(m.StartDate, series.DataRecovered, m.Recovered)
+	s.SetData(m.StartDate, series.DataTested, m.Tested)
+	for i, day := range s.Days {
+		day.Provisional = i < len(m.Provisional) && m.Provisional[i]
+	}
+	return s
+}
+
+// Marshal encodes the series as a protobuf message
+func (m *Series) Marshal() []byte {
+	e := &encoder{}
+	e.message(1, m.Area.Marshal())
+	if !m.StartDate.IsZero() {
+		e.string(2, m.StartDate.Format("2006-01-02"))
+	}
+	for i, values := range [][]int{m.Deaths, m.Confirmed, m.Recovered, m.Tested} {
+		packed := make([]int64, len(values))
+		for j, v := range values {
+			packed[j] = int64(v)
+		}
+		e.packed(3+i, packed)
+	}
+	packed := make([]int64, len(m.Provisional))
+	for i, v := range m.Provisional {
+		if v {
+			packed[i] = 1
+		}
+	}
+	e.packed(7, packed)
+	return e.buf
+}
+
+// Unmarshal decodes the series from the protobuf message b
+func (m *Series) Unmarshal(b []byte) error {
+	return decode(b, func(f field) error {
+		switch {
+		case f.number == 1 && f.wireType == wireBytes:
+			return m.Area.Unmarshal(f.data)
+		case f.number == 2 && f.wireType == wireBytes:
+			m.StartDate, _ = time.Parse("2006-01-02", f.string())
+		case f.number >= 3 && f.number <= 7:
+			values, err := f.ints()
+			if err != nil {
+				return err
+			}
+			for _, v := range values {
+				switch f.number {
+				case 3:
+					m.Deaths = append(m.Deaths, int(v))
+				case 4:
+					m.Confirmed = append(m.Confirmed, int(v))
+				case 5:
+					m.Recovered = append(m.Recovered, int(v))
+				case 6:
+					m.Tested = append(m.Tested, int(v))
+				case 7:
+					m.Provisional = append(m.Provisional, v != 0)
+				}
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,252 @@
+// Package rpc serves the series data over gRPC for internal consumers, alongside the http api
+// the messages defined in series.proto are encoded by this package, so no generated code is required
+// gRPC requires http/2, so the service is only available from the TLS server
+package rpc
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kennygrant/coronavirus/series"
+)
+
+// ServiceName is the full name of the service in series.proto
+const ServiceName = "coronavirus.v1.Series"
+
+// Prefix is the path prefix of the methods of the service, as /coronavirus.v1.Series/ListAreas
+const Prefix = "/" + ServiceName + "/"
+
+// maxMessageSize limits the size of request messages, queries are small
+const maxMessageSize = 64 * 1024
+
+// gRPC status codes returned by the service
+const (
+	CodeOK              = 0
+	CodeInvalidArgument = 3
+	CodeNotFound        = 5
+	CodeUnimplemented   = 12
+	CodeInternal        = 13
+)
+
+// Error is an error returned to the client with a gRPC status code
+type Error struct {
+	Code    int
+	Message string
+}
+
+// Error returns the message and code of this error
+func (e *Error) Error() string {
+	return fmt.Sprintf("rpc: code:%d %s", e.Code, e.Message)
+}
+
+// errorf returns an error with code and a formatted message
+func errorf(code int, format string, args ...interface{}) *Error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// Handler returns the handler for the methods of the service, it should be registered at Prefix
+func Handler() http.Handler {
+	return http.HandlerFunc(serve)
+}
+
+// serve reads the request message, then calls the method which streams responses,
+// the status of the call is sent in trailers
+func serve(w http.ResponseWriter, r *http.Request) {
+
+	log.Printf("request:%s", r.URL)
+
+	if r.ProtoMajor != 2 {
+		http.Error(w, "grpc requires http/2", http.StatusHTTPVersionNotSupported)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+		http.Error(w, "unsupported content type", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/grpc+proto")
+	w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+	w.WriteHeader(http.StatusOK)
+
+	// Each response message is flushed as it is written, so clients receive streams as they are read
+	flusher, _ := w.(http.Flusher)
+	send := func(message []byte) error {
+		header := make([]byte, 5)
+		binary.BigEndian.PutUint32(header[1:], uint32(len(message)))
+		_, err := w.Write(append(header, message...))
+		if err != nil {
+			return err
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return r.Context().Err()
+	}
+
+	request, err := readMessage(r.Body)
+	if err == nil {
+		switch strings.TrimPrefix(r.URL.Path, Prefix) {
+		case "ListAreas":
+			err = listAreas(request, send)
+		case "ListDays":
+			err = listDays(request, send)
+		case "ListSeries":
+			err = listSeries(request, send)
+		default:
+			err = errorf(CodeUnimplemented, "unknown method:%s", r.URL.Path)
+		}
+	}
+
+	code, message := CodeOK, ""
+	if err != nil {
+		code, message = CodeInternal, err.Error()
+		if e, ok := err.(*Error); ok {
+			code, message = e.Code, e.Message
+		}
+		log.Printf("rpc: error in call:%s code:%d %s", r.URL.Path, code, message)
+	}
+	w.Header().Set("Grpc-Status", strconv.Itoa(code))
+	w.Header().Set("Grpc-Message", encodeMessage(message))
+}
+
+// readMessage reads one length prefixed message from r, compressed messages are not supported
+func readMessage(r io.Reader) ([]byte, error) {
+	header := make([]byte, 5)
+	_, err := io.ReadFull(r, header)
+	if err != nil {
+		return nil, errorf(CodeInvalidArgument, "missing request message")
+	}
+	if header[0] != 0 {
+		return nil, errorf(CodeUnimplemented, "compressed messages are not supported")
+	}
+	length := binary.BigEndian.Uint32(header[1:])
+	if length > maxMessageSize {
+		return nil, errorf(CodeInvalidArgument, "request message too large:%d", length)
+	}
+	message := make([]byte, length)
+	_, err = io.ReadFull(r, message)
+	if err != nil {
+		return nil, errorf(CodeInvalidArgument, "short request message")
+	}
+	return message, nil
+}
+
+// encodeMessage percent encodes a status message as required in the grpc-message trailer
+func encodeMessage(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c < 0x20 || c > 0x7e || c == '%' {
+			fmt.Fprintf(&b, "%%%02X", c)
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// listAreas streams the areas matching the AreaQuery in request
+func listAreas(request []byte, send func([]byte) error) error {
+	var q AreaQuery
+	err := q.Unmarshal(request)
+	if err != nil {
+		return errorf(CodeInvalidArgument, "%s", err)
+	}
+
+	for _, s := range queryAreas(q) {
+		err = send(NewArea(s).Marshal())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// listSeries streams the areas matching the AreaQuery in request with all of their days
+func listSeries(request []byte, send func([]byte) error) error {
+	var q AreaQuery
+	err := q.Unmarshal(request)
+	if err != nil {
+		return errorf(CodeInvalidArgument, "%s", err)
+	}
+
+	for _, s := range queryAreas(q) {
+		err = send(NewSeries(s).Marshal())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// queryAreas returns the areas matching the query
+func queryAreas(q AreaQuery) series.Slice {
+	areas := series.Areas()
+	if q.Search != "" {
+		areas = areas.Search(q.Search)
+	}
+
+	var matched series.Slice
+	for _, s := range areas {
+		if q.Limit > 0 && len(matched) >= q.Limit {
+			break
+		}
+		if (q.Country != "" && !s.MatchCountry(q.Country)) || (q.CountriesOnly && !s.IsCountry()) {
+			continue
+		}
+		matched = append(matched, s)
+	}
+	return matched
+}
+
+// listDays streams the days of the area selected by the DayQuery in request
+func listDays(request []byte, send func([]byte) error) error {
+	var q DayQuery
+	err := q.Unmarshal(request)
+	if err != nil {
+		return errorf(CodeInvalidArgument, "%s", err)
+	}
+
+	var from, to time.Time
+	if q.From != "" {
+		from, err = time.Parse("2006-01-02", q.From)
+		if err != nil {
+			return errorf(CodeInvalidArgument, "invalid from:%s", q.From)
+		}
+	}
+	if q.To != "" {
+		to, err = time.Parse("2006-01-02", q.To)
+		if err != nil {
+			return errorf(CodeInvalidArgument, "invalid to:%s", q.To)
+		}
+	}
+
+	var s *series.Data
+	if q.AreaID != 0 {
+		s, err = series.FindSeries(q.AreaID)
+	} else {
+		s, err = series.FetchSeries(q.Country, q.Province)
+	}
+	if err != nil {
+		return errorf(CodeNotFound, "area not found")
+	}
+
+	s.ForEachDay(func(day *series.Day) bool {
+		if day.Date.Before(from) || (!to.IsZero() && day.Date.After(to)) {
+			return true
+		}
+		err = send(NewDay(day).Marshal())
+		return err == nil
+	})
+	return err
+}
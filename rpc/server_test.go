@@ -0,0 +1,187 @@
+package rpc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/kennygrant/coronavirus/series"
+	"github.com/kennygrant/coronavirus/seriesgen"
+)
+
+// TestMessages tests messages are decoded as they were encoded
+func TestMessages(t *testing.T) {
+	q := AreaQuery{Country: "Arcadia", CountriesOnly: true, Limit: 3}
+	var decoded AreaQuery
+	err := decoded.Unmarshal(q.Marshal())
+	if err != nil || decoded != q {
+		t.Errorf("rpc: wrong area query err:%v got:%v", err, decoded)
+	}
+
+	a := Area{ID: 2, Country: "Arcadia", Population: 1000, Latitude: -12.5, Longitude: 100.25, Deaths: 7, Tested: 1 << 40}
+	var area Area
+	err = area.Unmarshal(a.Marshal())
+	if err != nil || area != a {
+		t.Errorf("rpc: wrong area err:%v got:%v", err, area)
+	}
+
+	// Unknown fields are skipped
+	e := &encoder{}
+	e.string(20, "unknown")
+	e.double(21, 1)
+	e.int(1, 5)
+	var day DayQuery
+	err = day.Unmarshal(e.buf)
+	if err != nil || day.AreaID != 5 {
+		t.Errorf("rpc: wrong day query err:%v got:%v", err, day)
+	}
+
+	err = day.Unmarshal([]byte{0x0a, 0x05, 'a'})
+	if err == nil {
+		t.Errorf("rpc: decoded truncated message")
+	}
+}
+
+// TestDataset tests series are restored from a dataset with all their days
+func TestDataset(t *testing.T) {
+	slice, err := seriesgen.Generate(seriesgen.Options{Areas: 3, Days: 20, Seed: 1})
+	if err != nil {
+		t.Fatalf("rpc: failed to generate:%s", err)
+	}
+	slice[0].SetProvisional(slice[0].LastDay().Date)
+
+	var buf bytes.Buffer
+	err = WriteDataset(&buf, slice)
+	if err != nil {
+		t.Fatalf("rpc: failed to write dataset:%s", err)
+	}
+	restored, err := ReadDataset(&buf)
+	if err != nil || len(restored) != len(slice) {
+		t.Fatalf("rpc: wrong dataset err:%v got:%d", err, len(restored))
+	}
+	for i, s := range slice {
+		r := restored[i]
+		if r.ID != s.ID || r.Country != s.Country || r.Province != s.Province || len(r.Days) != len(s.Days) {
+			t.Fatalf("rpc: wrong series got:%v want:%v", r, s)
+		}
+		for j, day := range s.Days {
+			got := r.Days[j]
+			if !got.Date.Equal(day.Date) || got.Value(series.DataDeaths) != day.Value(series.DataDeaths) || got.Value(series.DataConfirmed) != day.Value(series.DataConfirmed) || got.Value(series.DataRecovered) != day.Value(series.DataRecovered) || got.Value(series.DataTested) != day.Value(series.DataTested) || got.Provisional != day.Provisional {
+				t.Errorf("rpc: wrong day got:%v want:%v", got, day)
+			}
+		}
+	}
+
+	// Packed columns are smaller than a message per day
+	size := 0
+	slice[1].ForEachDay(func(day *series.Day) bool {
+		size += len(NewDay(day).Marshal()) + 2
+		return true
+	})
+	if n := len(NewSeries(slice[1]).Marshal()); n >= size {
+		t.Errorf("rpc: series not smaller than days got:%d days:%d", n, size)
+	}
+
+	_, err = ReadDataset(bytes.NewReader([]byte{0x0a, 0x04, 0x1a, 0x02, 0x80}))
+	if err == nil {
+		t.Errorf("rpc: decoded invalid packed column")
+	}
+}
+
+// TestServe tests calls to the service over http/2
+func TestServe(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rpc")
+	if err != nil {
+		t.Fatalf("rpc: failed to create dir:%s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	slice, err := seriesgen.Generate(seriesgen.Options{Areas: 5, Days: 30, Seed: 1})
+	if err != nil {
+		t.Fatalf("rpc: failed to generate:%s", err)
+	}
+	err = seriesgen.WriteData(dir, slice)
+	if err == nil {
+		err = series.LoadData(dir)
+	}
+	if err != nil {
+		t.Fatalf("rpc: failed to load data:%s", err)
+	}
+
+	server := httptest.NewUnstartedServer(Handler())
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+
+	// call returns the response messages and the status of a call
+	call := func(method string, request []byte) ([][]byte, string, string) {
+		body := make([]byte, 5, 5+len(request))
+		binary.BigEndian.PutUint32(body[1:], uint32(len(request)))
+		req, _ := http.NewRequest(http.MethodPost, server.URL+Prefix+method, bytes.NewReader(append(body, request...)))
+		req.Header.Set("Content-Type", "application/grpc")
+		resp, err := server.Client().Do(req)
+		if err != nil {
+			t.Fatalf("rpc: failed to call:%s error:%s", method, err)
+		}
+		defer resp.Body.Close()
+		if resp.ProtoMajor != 2 {
+			t.Fatalf("rpc: call not made over http/2")
+		}
+
+		var messages [][]byte
+		for {
+			message, err := readMessage(resp.Body)
+			if err != nil {
+				break
+			}
+			messages = append(messages, message)
+		}
+		io.Copy(ioutil.Discard, resp.Body)
+		return messages, resp.Trailer.Get("Grpc-Status"), resp.Trailer.Get("Grpc-Message")
+	}
+
+	messages, status, _ := call("ListAreas", (&AreaQuery{Limit: 3}).Marshal())
+	if status != "0" || len(messages) != 3 {
+		t.Fatalf("rpc: wrong areas status:%s got:%d", status, len(messages))
+	}
+	var area Area
+	area.Unmarshal(messages[0])
+	first, _ := series.FindSeries(area.ID)
+	if first == nil || area.Country != first.Country || area.Deaths != first.LastDay().Value(series.DataDeaths) {
+		t.Errorf("rpc: wrong area got:%v", area)
+	}
+
+	messages, status, _ = call("ListDays", (&DayQuery{AreaID: area.ID, From: "2020-01-25", To: "2020-01-31"}).Marshal())
+	if status != "0" || len(messages) != 7 {
+		t.Fatalf("rpc: wrong days status:%s got:%d", status, len(messages))
+	}
+	var day Day
+	day.Unmarshal(messages[6])
+	if day.Date.Format("2006-01-02") != "2020-01-31" || day.Confirmed != first.FetchDate(day.Date, series.DataConfirmed) {
+		t.Errorf("rpc: wrong day got:%v", day)
+	}
+
+	messages, status, _ = call("ListSeries", (&AreaQuery{Limit: 2}).Marshal())
+	if status != "0" || len(messages) != 2 {
+		t.Fatalf("rpc: wrong series status:%s got:%d", status, len(messages))
+	}
+	var m Series
+	m.Unmarshal(messages[0])
+	if m.Area.ID != area.ID || len(m.Deaths) != len(first.Days) || m.Confirmed[len(m.Confirmed)-1] != first.LastDay().Value(series.DataConfirmed) {
+		t.Errorf("rpc: wrong series got:%v", m)
+	}
+
+	_, status, message := call("ListDays", (&DayQuery{AreaID: 999}).Marshal())
+	if status != "5" || message != "area not found" {
+		t.Errorf("rpc: wrong status for missing area status:%s message:%s", status, message)
+	}
+	_, status, _ = call("Missing", nil)
+	if status != "12" {
+		t.Errorf("rpc: wrong status for unknown method status:%s", status)
+	}
+}
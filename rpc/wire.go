@@ -0,0 +1,162 @@
+package rpc
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// Protobuf wire types used by the messages in series.proto
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// encoder appends fields to a protobuf message, fields with zero values are left out as in proto3
+type encoder struct {
+	buf []byte
+}
+
+// tag appends the key of a field
+func (e *encoder) tag(field, wireType int) {
+	e.buf = binary.AppendUvarint(e.buf, uint64(field)<<3|uint64(wireType))
+}
+
+// int appends an int32 or int64 field
+func (e *encoder) int(field int, v int64) {
+	if v == 0 {
+		return
+	}
+	e.tag(field, wireVarint)
+	e.buf = binary.AppendUvarint(e.buf, uint64(v))
+}
+
+// bool appends a bool field
+func (e *encoder) bool(field int, v bool) {
+	if v {
+		e.int(field, 1)
+	}
+}
+
+// double appends a double field
+func (e *encoder) double(field int, v float64) {
+	if v == 0 {
+		return
+	}
+	e.tag(field, wireFixed64)
+	e.buf = binary.LittleEndian.AppendUint64(e.buf, math.Float64bits(v))
+}
+
+// string appends a string field
+func (e *encoder) string(field int, v string) {
+	if v == "" {
+		return
+	}
+	e.tag(field, wireBytes)
+	e.buf = binary.AppendUvarint(e.buf, uint64(len(v)))
+	e.buf = append(e.buf, v...)
+}
+
+// message appends an embedded message field, which is written even if empty
+func (e *encoder) message(field int, v []byte) {
+	e.tag(field, wireBytes)
+	e.buf = binary.AppendUvarint(e.buf, uint64(len(v)))
+	e.buf = append(e.buf, v...)
+}
+
+// packed appends a packed repeated int64 field, such as a column of counts
+func (e *encoder) packed(field int, values []int64) {
+	if len(values) == 0 {
+		return
+	}
+	var data []byte
+	for _, v := range values {
+		data = binary.AppendUvarint(data, uint64(v))
+	}
+	e.message(field, data)
+}
+
+// field is one field read from a message, value holds varints and fixed values, data holds bytes
+type field struct {
+	number   int
+	wireType int
+	value    uint64
+	data     []byte
+}
+
+// decode calls fn for each field in the message b, unknown fields should be ignored by fn
+func decode(b []byte, fn func(f field) error) error {
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 {
+			return fmt.Errorf("rpc: invalid field key")
+		}
+		b = b[n:]
+
+		f := field{number: int(key >> 3), wireType: int(key & 7)}
+		switch f.wireType {
+		case wireVarint:
+			f.value, n = binary.Uvarint(b)
+			if n <= 0 {
+				return fmt.Errorf("rpc: invalid varint in field:%d", f.number)
+			}
+			b = b[n:]
+		case wireFixed64:
+			if len(b) < 8 {
+				return fmt.Errorf("rpc: short fixed64 in field:%d", f.number)
+			}
+			f.value = binary.LittleEndian.Uint64(b)
+			b = b[8:]
+		case wireFixed32:
+			if len(b) < 4 {
+				return fmt.Errorf("rpc: short fixed32 in field:%d", f.number)
+			}
+			f.value = uint64(binary.LittleEndian.Uint32(b))
+			b = b[4:]
+		case wireBytes:
+			length, n := binary.Uvarint(b)
+			if n <= 0 || uint64(len(b)-n) < length {
+				return fmt.Errorf("rpc: invalid length in field:%d", f.number)
+			}
+			f.data = b[n : n+int(length)]
+			b = b[n+int(length):]
+		default:
+			return fmt.Errorf("rpc: unsupported wire type:%d in field:%d", f.wireType, f.number)
+		}
+
+		err := fn(f)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// int returns the value of a varint field as an int64
+func (f field) int() int64 {
+	return int64(f.value)
+}
+
+// string returns the value of a bytes field as a string
+func (f field) string() string {
+	return string(f.data)
+}
+
+// ints returns the values of a repeated varint field, which may be packed or a single value
+func (f field) ints() ([]int64, error) {
+	if f.wireType == wireVarint {
+		return []int64{f.int()}, nil
+	}
+	var values []int64
+	for b := f.data; len(b) > 0; {
+		v, n := binary.Uvarint(b)
+		if n <= 0 {
+			return nil, fmt.Errorf("rpc: invalid packed varint in field:%d", f.number)
+		}
+		values = append(values, int64(v))
+		b = b[n:]
+	}
+	return values, nil
+}
@@ -0,0 +1,127 @@
+package series
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Aggregate is the sum of a set of series, such as the countries in a region group
+// it is calculated when created, so it is not changed by later updates to the series
+type Aggregate struct {
+	name    string
+	members Slice
+
+	// sum holds the sum of the members on each date, its previous day is the sum of the days before
+	// the first day of the members, used for daily values of periods
+	sum *Data
+}
+
+// NewAggregate returns the sum of the series in members, with the name given
+// members should cover the same dates, as the series of one dataset do, days are summed by date
+func NewAggregate(name string, members Slice) *Aggregate {
+	a := &Aggregate{name: name, members: members, sum: &Data{store: members.store()}}
+
+	// The sum has a day for each date of any member, in date order
+	index := make(map[int64]int)
+	var block []Day
+	for _, s := range members {
+		for _, day := range s.Days {
+			if _, ok := index[day.Date.Unix()]; !ok {
+				index[day.Date.Unix()] = len(block)
+				block = append(block, Day{Date: day.Date})
+			}
+		}
+	}
+	sort.Slice(block, func(i, j int) bool {
+		return block[i].Date.Before(block[j].Date)
+	})
+	for i, day := range block {
+		index[day.Date.Unix()] = i
+	}
+	a.sum.setPreviousDay(time.Time{})
+	a.sum.appendDays(block)
+
+	for _, s := range members {
+		for _, day := range s.Days {
+			a.sum.Days[index[day.Date.Unix()]].add(day)
+		}
+		if s.PreviousDay != nil {
+			a.sum.PreviousDay.add(s.PreviousDay)
+		}
+	}
+	return a
+}
+
+// add adds the values of day to this day, the sum is provisional or interpolated if either day is
+func (d *Day) add(day *Day) {
+	if day.series != nil {
+		for _, kind := range day.series.kinds() {
+			d.MergeData(kind, day.Value(kind))
+		}
+	}
+	d.Provisional = d.Provisional || day.Provisional
+	d.Interpolated = d.Interpolated || day.Interpolated
+}
+
+// GroupSeries returns the aggregate of the countries in a region group in the default store
+func GroupSeries(name string) (*Aggregate, error) {
+	return DefaultStore().GroupSeries(name)
+}
+
+// GroupSeries returns the aggregate of the countries in the region group name, see StoreConfig.Groups
+// pending provinces are not members of groups, so none are loaded
+func (st *Store) GroupSeries(name string) (*Aggregate, error) {
+	st.mutex.RLock()
+	defer st.mutex.RUnlock()
+
+	var members Slice
+	for _, s := range st.dataset {
+		if s.InGroup(name) {
+			members = append(members, s)
+		}
+	}
+	if len(members) == 0 {
+		return nil, fmt.Errorf("series: no series in group:%s", name)
+	}
+	return NewAggregate(name, members), nil
+}
+
+// Members returns the series summed in this aggregate
+func (a *Aggregate) Members() Slice {
+	return a.members
+}
+
+// Totals returns the cumulative values of dataKind for each day
+func (a *Aggregate) Totals(dataKind int) []int {
+	return a.sum.Values(dataKind)
+}
+
+// Daily returns the values of dataKind per day
+// the slice is shared with the aggregate and must not be modified
+func (a *Aggregate) Daily(dataKind int) []int {
+	return a.sum.DailyValues(dataKind)
+}
+
+// Range returns the dates of the first and last days, zero times if there are no days
+func (a *Aggregate) Range() (from, to time.Time) {
+	return a.sum.Range()
+}
+
+// Metadata returns the name of this aggregate with the population and area of its members
+func (a *Aggregate) Metadata() Metadata {
+	m := Metadata{Name: a.name}
+	for _, s := range a.members {
+		m.Population += s.Population
+		m.AreaKm2 += s.AreaKm2
+		if s.UpdatedAt.After(m.UpdatedAt) {
+			m.UpdatedAt = s.UpdatedAt
+		}
+	}
+	return m
+}
+
+// ForEachDay calls fn with each day in date order until fn returns false
+func (a *Aggregate) ForEachDay(fn func(*Day) bool) {
+	a.sum.ForEachDay(fn)
+}
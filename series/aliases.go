@@ -0,0 +1,101 @@
+package series
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// defaultAliases maps names used by sources and people for countries to the names used in our data
+// aliases.csv in the data directory may add to or override these
+var defaultAliases = map[string]string{
+	"Burma":                    "Myanmar",
+	"Taiwan*":                  "Taiwan",
+	"Korea, South":             "South Korea",
+	"Republic of Korea":        "South Korea",
+	"Korea":                    "South Korea",
+	"USA":                      "US",
+	"America":                  "US",
+	"United States":            "US",
+	"United States of America": "US",
+	"UK":                       "United Kingdom",
+	"Britain":                  "United Kingdom",
+	"Great Britain":            "United Kingdom",
+	"UAE":                      "United Arab Emirates",
+	"DRC":                      "Congo (Kinshasa)",
+	"Czech Republic":           "Czechia",
+	"Ivory Coast":              "Cote d'Ivoire",
+	"Vatican":                  "Holy See",
+	"Vatican City":             "Holy See",
+	"Macedonia":                "North Macedonia",
+	"Swaziland":                "Eswatini",
+	"East Timor":               "Timor-Leste",
+	"Mainland China":           "China",
+}
+
+// aliasKeys returns the aliases keyed by their url key, so that matches ignore case
+func aliasKeys(names map[string]string) map[string]string {
+	keys := make(map[string]string, len(names))
+	for alias, country := range names {
+		keys[urlKey(alias)] = country
+	}
+	return keys
+}
+
+// urlKey converts a value into one suitable for use in urls, see Data.Key
+func urlKey(v string) string {
+	return strings.Replace(strings.ToLower(v), " ", "-", -1)
+}
+
+// CountryName returns the name used in our data for the country given in the default store, see Store.CountryName
+func CountryName(country string) string {
+	return DefaultStore().CountryName(country)
+}
+
+// CountryName returns the name used in our data for the country given,
+// which may be an alias used by a source, or the name unchanged if it has no alias
+func (st *Store) CountryName(country string) string {
+	st.aliasMutex.RLock()
+	defer st.aliasMutex.RUnlock()
+	name, ok := st.aliases[urlKey(country)]
+	if ok {
+		return name
+	}
+	return country
+}
+
+// LoadAliases loads country aliases into the default store, see Store.LoadAliases
+func LoadAliases(p string) error {
+	return DefaultStore().LoadAliases(p)
+}
+
+// LoadAliases loads country aliases from the specified file in addition to the defaults
+// rows are alias,country, for example USA,US - a missing file is not an error
+func (st *Store) LoadAliases(p string) error {
+	names := make(map[string]string, len(defaultAliases))
+	for alias, country := range defaultAliases {
+		names[alias] = country
+	}
+
+	header := func(row []string) error {
+		if len(row) < 2 || row[0] != "alias" || row[1] != "country" {
+			return fmt.Errorf("aliases: invalid header row in file:%s row:%s", p, row)
+		}
+		return nil
+	}
+	_, err := streamCSV(p, header, func(row []string) error {
+		if len(row) < 2 || row[0] == "" || row[1] == "" {
+			return fmt.Errorf("aliases: invalid row:%s", row)
+		}
+		names[row[0]] = row[1]
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	st.aliasMutex.Lock()
+	defer st.aliasMutex.Unlock()
+	st.aliases = aliasKeys(names)
+	return nil
+}
@@ -0,0 +1,275 @@
+package series
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// ArchiveName is the name of the archive of days moved out of memory in the data directory
+const ArchiveName = "archive.csv.gz"
+
+// archiveIndex is the name of the file recording the days archived in the data directory
+const archiveIndex = "archive.json"
+
+// ArchiveFile records the days moved from the series file to the archive
+type ArchiveFile struct {
+	// Before is the first day not archived, days before it are in the archive
+	Before time.Time `json:"before"`
+
+	// Days is the number of days archived, the day number of the last day in the archive
+	Days int `json:"days"`
+
+	// UTC time days were last archived
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Archive moves days older than the archive days set in the default store to the archive, see Store.Archive
+func Archive() error {
+	return DefaultStore().Archive()
+}
+
+// Archive moves days older than the archive days set to the archive, a compressed series file in the data directory
+// the series are left starting on the first day kept, with the last day archived as their previous day
+// and the series file is saved with the days kept, there is nothing to do if every day is kept in memory
+// archived days are read again on demand with WithArchive
+func (st *Store) Archive() error {
+	config := st.Config()
+	before := daysAgo(config.ArchiveDays)
+	if before.IsZero() {
+		return nil
+	}
+
+	var dataPath string
+	archived := 0
+	err := st.Update("archive", func(next Slice) error {
+		dataPath = next.dataPath(config.DataPath)
+		if len(next) == 0 {
+			return nil
+		}
+		first, last := next.firstDay(), st.dayNumber(before)-1
+		if last < first || last-first+1 >= len(next[0].Days) {
+			return nil
+		}
+
+		// Every series is archived, pending provinces are read into copies so they stay pending
+		loaded, err := next.withPending(all)
+		if err != nil {
+			return err
+		}
+
+		// Days are appended to the archive before the index records them, so no day is lost if either fails
+		var rows [][]string
+		for i := 0; i <= last-first; i++ {
+			for _, s := range loaded {
+				d := s.Days[i]
+				if !d.IsZero() && !d.Interpolated {
+					row := []string{strconv.Itoa(first + i), strconv.Itoa(s.ID)}
+					for _, v := range d.values() {
+						row = append(row, strconv.Itoa(v))
+					}
+					rows = append(rows, append(row, d.formatSources()))
+				}
+			}
+		}
+		var header []string
+		if first == 1 {
+			header = seriesHeader(st.kinds)
+		}
+		err = appendArchive(filepath.Join(dataPath, ArchiveName), header, rows)
+		if err != nil {
+			return err
+		}
+		err = saveArchiveIndex(dataPath, ArchiveFile{Before: before, Days: last, UpdatedAt: time.Now().UTC()})
+		if err != nil {
+			return err
+		}
+
+		// Days kept are copied so that the memory of days archived is released
+		// pending series have no days, their rows for days archived are skipped when loaded
+		archived = last - first + 1
+		for _, s := range next {
+			if s.pending {
+				s.setPreviousDay(before.AddDate(0, 0, -1))
+			} else {
+				s.dropDays(archived)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("series: failed to archive days:%s", err)
+	}
+	if archived == 0 {
+		return nil
+	}
+	log.Printf("series: archived %d days before:%s", archived, before.Format("2006-01-02"))
+
+	return st.Save(filepath.Join(dataPath, "series.csv"))
+}
+
+// WithArchive returns s with archived days from the date given in the default store, see Store.WithArchive
+func WithArchive(s *Data, from time.Time) (*Data, error) {
+	return DefaultStore().WithArchive(s, from)
+}
+
+// WithArchive returns a copy of s with the days from the date given read from the archive added before its days
+// s is returned unchanged if from is zero or no earlier than its first day, as no days are needed from the archive
+// days are filled and downsampled as when loaded, and the previous day is set so daily values are complete
+func (st *Store) WithArchive(s *Data, from time.Time) (*Data, error) {
+	if from.IsZero() || s.PreviousDay == nil || len(s.Days) == 0 || !from.Before(s.Days[0].Date) {
+		return s, nil
+	}
+	if from.Before(st.config.StartDate) {
+		from = st.config.StartDate
+	}
+
+	config := st.Config()
+	dataPath := config.DataPath
+	if s.files != nil {
+		dataPath = s.files.path
+	}
+
+	c := &Data{store: st}
+	start := st.dayNumber(from)
+	if start > 1 {
+		c.setPreviousDay(from.AddDate(0, 0, -1))
+	}
+	c.AddDays(st.dayNumber(s.Days[0].Date) - start)
+
+	columns := 2 + len(st.kinds)
+	err := readArchive(filepath.Join(dataPath, ArchiveName), columns, func(day, areaID int, row []string) {
+		if areaID != s.ID || day < start-1 || day >= start+len(c.Days) {
+			return
+		}
+		d := c.PreviousDay
+		if day >= start {
+			d = c.Days[day-start]
+		}
+		d.setValues(intValues(row[2:columns])...)
+		if len(row) > columns {
+			d.parseSources(row[columns])
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	archived := s.clone()
+	archived.prependDays(c)
+	archived.Interpolate(config.Interpolation)
+	archived.Downsample(daysAgo(config.RetentionDays))
+	return archived, nil
+}
+
+// appendArchive appends the rows to the archive at p as a gzip member, gzip readers read the members in turn
+// if header is given the archive is started again, with the header row of the series file first
+func appendArchive(p string, header []string, rows [][]string) error {
+	flag := os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	if header != nil {
+		flag |= os.O_TRUNC
+	}
+	f, err := os.OpenFile(p, flag, 0644)
+	if err != nil {
+		return err
+	}
+
+	z := gzip.NewWriter(f)
+	w := csv.NewWriter(z)
+	if header != nil {
+		w.Write(header)
+	}
+	w.WriteAll(rows)
+	err = w.Error()
+	if err == nil {
+		err = z.Close()
+	}
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("archive: failed to write:%s", err)
+	}
+	return f.Close()
+}
+
+// readArchive reads each row of the archive at p in turn, passing the day number and area id to fn
+// rows with fewer than columns values are skipped, and a missing archive has no rows
+func readArchive(p string, columns int, fn func(day, areaID int, row []string)) error {
+	f, err := os.Open(p)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	z, err := gzip.NewReader(bufio.NewReader(f))
+	if err != nil {
+		return fmt.Errorf("archive: invalid file:%s", err)
+	}
+	r := csv.NewReader(z)
+	r.FieldsPerRecord = -1
+	for {
+		row, err := r.Read()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("archive: failed to read:%s", err)
+		}
+		// Skip the header row and invalid rows
+		if len(row) < columns || row[0] == "day" {
+			continue
+		}
+		values := intValues(row[:2])
+		fn(values[0], values[1], row)
+	}
+}
+
+// loadArchiveIndex returns the record of days archived in dataPath, or nil if no days are archived
+func loadArchiveIndex(dataPath string) (*ArchiveFile, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dataPath, archiveIndex))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var archive ArchiveFile
+	err = json.Unmarshal(data, &archive)
+	if err != nil {
+		return nil, fmt.Errorf("archive: failed to decode index:%s", err)
+	}
+	return &archive, nil
+}
+
+// saveArchiveIndex writes the record of days archived to dataPath
+func saveArchiveIndex(dataPath string, archive ArchiveFile) error {
+	data, err := json.MarshalIndent(archive, "", "  ")
+	if err != nil {
+		return fmt.Errorf("archive: failed to encode index:%s", err)
+	}
+	return ioutil.WriteFile(filepath.Join(dataPath, archiveIndex), data, 0644)
+}
+
+// dayNumber returns the day number of date in the series file, the start date of the store is day 1
+func (st *Store) dayNumber(date time.Time) int {
+	return int(date.Sub(st.config.StartDate).Hours()/24) + 1
+}
+
+// firstDay returns the day number of the first day of the series in memory, days before it are archived
+// archived series have the last day archived as their previous day
+func (slice Slice) firstDay() int {
+	for _, s := range slice {
+		if s.PreviousDay != nil {
+			return s.settings().dayNumber(s.PreviousDay.Date) + 1
+		}
+	}
+	return 1
+}
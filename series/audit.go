@@ -0,0 +1,183 @@
+package series
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// maxAuditEntries is the number of audit entries kept in memory, older entries remain in the audit file
+const maxAuditEntries = 50000
+
+// AuditEntry records one change to a stored value on one day in an area
+type AuditEntry struct {
+	CreatedAt time.Time `json:"created_at"`
+
+	// Actor is who or what made the change, for example refresh, reload, import:file or admin:ip
+	Actor string `json:"actor"`
+
+	AreaID int       `json:"area_id"`
+	Date   time.Time `json:"date"`
+	Kind   string    `json:"kind"`
+	Before int       `json:"before"`
+	After  int       `json:"after"`
+
+	// Source is the name of the source of the value after the change
+	Source string `json:"source"`
+}
+
+// OpenAudit opens the audit log of the default store, see Store.OpenAudit
+func OpenAudit(p string) error {
+	return DefaultStore().OpenAudit(p)
+}
+
+// OpenAudit loads the audit log from the json lines file at path p, and appends entries recorded after this to it
+// a missing file is not an error, it is created when the first entry is recorded
+func (st *Store) OpenAudit(p string) error {
+	p = filepath.Clean(p)
+
+	var loaded []AuditEntry
+	f, err := os.Open(p)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err == nil {
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			var e AuditEntry
+			err = json.Unmarshal(scanner.Bytes(), &e)
+			if err != nil {
+				return fmt.Errorf("audit: failed to decode audit file:%s", err)
+			}
+			loaded = append(loaded, e)
+			if len(loaded) > maxAuditEntries {
+				loaded = loaded[1:]
+			}
+		}
+		err = scanner.Err()
+		if err != nil {
+			return fmt.Errorf("audit: failed to read audit file:%s", err)
+		}
+	}
+
+	st.auditMutex.Lock()
+	defer st.auditMutex.Unlock()
+	st.audit = loaded
+	st.auditPath = p
+	return nil
+}
+
+// AuditLog returns entries from the audit log of the default store, see Store.AuditLog
+func AuditLog(match func(AuditEntry) bool, limit int) []AuditEntry {
+	return DefaultStore().AuditLog(match, limit)
+}
+
+// AuditLog returns the audit entries for which match returns true, most recent first
+// at most limit entries are returned, a nil match returns all entries
+func (st *Store) AuditLog(match func(AuditEntry) bool, limit int) []AuditEntry {
+	st.auditMutex.RLock()
+	defer st.auditMutex.RUnlock()
+
+	result := []AuditEntry{}
+	for i := len(st.audit) - 1; i >= 0 && len(result) < limit; i-- {
+		if match == nil || match(st.audit[i]) {
+			result = append(result, st.audit[i])
+		}
+	}
+	return result
+}
+
+// recordAudit adds entries to the audit log and appends them to the audit file if open
+func (st *Store) recordAudit(entries []AuditEntry) {
+	if len(entries) == 0 {
+		return
+	}
+
+	st.auditMutex.Lock()
+	defer st.auditMutex.Unlock()
+
+	st.audit = append(st.audit, entries...)
+	if len(st.audit) > maxAuditEntries {
+		st.audit = append([]AuditEntry(nil), st.audit[len(st.audit)-maxAuditEntries:]...)
+	}
+
+	if st.auditPath == "" {
+		return
+	}
+	f, err := os.OpenFile(st.auditPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		log.Printf("audit: failed to open audit file:%s", err)
+		return
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+	encoder := json.NewEncoder(w)
+	for _, e := range entries {
+		err = encoder.Encode(e)
+		if err != nil {
+			log.Printf("audit: failed to write audit file:%s", err)
+			return
+		}
+	}
+	err = w.Flush()
+	if err != nil {
+		log.Printf("audit: failed to write audit file:%s", err)
+	}
+}
+
+// audit returns an entry for each value changed on days present in both this slice and next
+// days added or removed are not recorded, nor are series pending in next
+// series pending in this slice and loaded in next are compared with copies read from the data files
+// the dataset must be locked for reading if this slice is the dataset
+func (slice Slice) audit(next Slice, actor string) []AuditEntry {
+	loaded := make(map[int]bool, len(next))
+	for _, s := range next {
+		loaded[s.ID] = !s.pending
+	}
+	compared, err := slice.withPending(func(d *Data) bool { return loaded[d.ID] })
+	if err != nil {
+		log.Printf("audit: failed to load pending series:%s", err)
+		compared = slice
+	}
+
+	previous := make(map[int]*Data, len(compared))
+	for _, s := range compared {
+		previous[s.ID] = s
+	}
+
+	now := time.Now().UTC()
+	var entries []AuditEntry
+	for _, s := range next {
+		old := previous[s.ID]
+		if old == nil || old.pending || s.pending {
+			continue
+		}
+		for _, day := range s.Days {
+			oldDay := old.dayAt(day.Date)
+			if oldDay == nil {
+				continue
+			}
+			for _, kind := range s.settings().kinds {
+				if oldDay.Value(kind) == day.Value(kind) {
+					continue
+				}
+				entries = append(entries, AuditEntry{
+					CreatedAt: now,
+					Actor:     actor,
+					AreaID:    s.ID,
+					Date:      day.Date,
+					Kind:      DataKindName(kind),
+					Before:    oldDay.Value(kind),
+					After:     day.Value(kind),
+					Source:    day.Source(kind).String(),
+				})
+			}
+		}
+	}
+	return entries
+}
@@ -0,0 +1,106 @@
+package series
+
+import (
+	"sort"
+	"strings"
+)
+
+// Completion is an area name suggested for the text typed in the search box
+// Slug is the path of the area page
+type Completion struct {
+	AreaID int    `json:"area_id"`
+	Name   string `json:"name"`
+	Slug   string `json:"slug"`
+}
+
+// completionEntry is a normalized name in the prefix index, from the start of one of its words
+type completionEntry struct {
+	key        string
+	wordStart  bool
+	rank       int
+	completion Completion
+}
+
+// buildCompletions returns the prefix index for the series in the slice
+// each name is indexed from the start of every word, so that "york" completes New York
+func (slice Slice) buildCompletions() []completionEntry {
+	var entries []completionEntry
+	for i, s := range slice {
+		if s.IsGlobal() {
+			continue
+		}
+		c := Completion{
+			AreaID: s.ID,
+			Name:   s.Title(),
+			Slug:   s.Slug(),
+		}
+		name := normalizeName(s.Country)
+		if s.IsProvince() {
+			name = normalizeName(s.Province)
+		}
+
+		words := strings.Fields(name)
+		for w := range words {
+			entries = append(entries, completionEntry{
+				key:        strings.Join(words[w:], " "),
+				wordStart:  w > 0,
+				rank:       i,
+				completion: c,
+			})
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].key < entries[j].key
+	})
+	return entries
+}
+
+// Autocomplete returns completions from the default store, see Store.Autocomplete
+func Autocomplete(q string, n int) []Completion {
+	return DefaultStore().Autocomplete(q, n)
+}
+
+// Autocomplete returns up to n areas with names starting with q, or with a word starting with q
+// names which start with q are first, then areas are in dataset order, by deaths
+func (st *Store) Autocomplete(q string, n int) []Completion {
+	q = normalizeName(q)
+	results := []Completion{}
+	if q == "" || n <= 0 {
+		return results
+	}
+
+	st.mutex.RLock()
+	defer st.mutex.RUnlock()
+
+	completions := st.completions
+	start := sort.Search(len(completions), func(i int) bool {
+		return completions[i].key >= q
+	})
+	var matches []completionEntry
+	seen := make(map[int]int)
+	for _, e := range completions[start:] {
+		if !strings.HasPrefix(e.key, q) {
+			break
+		}
+		// An area may match more than one of its words, prefer a match of the whole name
+		i, ok := seen[e.completion.AreaID]
+		if !ok {
+			seen[e.completion.AreaID] = len(matches)
+			matches = append(matches, e)
+		} else if !e.wordStart {
+			matches[i] = e
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].wordStart != matches[j].wordStart {
+			return !matches[i].wordStart
+		}
+		return matches[i].rank < matches[j].rank
+	})
+	for i := 0; i < len(matches) && i < n; i++ {
+		results = append(results, matches[i].completion)
+	}
+	return results
+}
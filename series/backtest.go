@@ -0,0 +1,111 @@
+package series
+
+import (
+	"math"
+	"sort"
+)
+
+// growthDays is the number of days of recent growth used by GrowthForecast
+const growthDays = 7
+
+// ForecastModel projects the cumulative values of dataKind for the days after the end of d
+// it should return one value for each of the days requested, or nil if it cannot forecast
+type ForecastModel func(d *Data, dataKind, days int) []float64
+
+// ForecastModels are the models available for backtesting by name
+var ForecastModels = map[string]ForecastModel{
+	"growth":      GrowthForecast,
+	"exponential": ExponentialForecast,
+}
+
+// GrowthForecast projects values by assuming the average daily growth rate over the last week continues
+// this is the model behind the doubling times shown on area pages, and is used as a baseline
+func GrowthForecast(d *Data, dataKind, days int) []float64 {
+	values := d.Values(dataKind)
+	n := len(values)
+	if n <= growthDays || values[n-1-growthDays] <= 0 {
+		return nil
+	}
+
+	last := float64(values[n-1])
+	rate := math.Pow(last/float64(values[n-1-growthDays]), 1/float64(growthDays))
+	projected := make([]float64, days)
+	for i := range projected {
+		last *= rate
+		projected[i] = last
+	}
+	return projected
+}
+
+// BacktestScore records the accuracy of a forecast model on the history of one area
+// MAE is the mean absolute error, MAPE the mean absolute percentage error over non-zero actual values
+type BacktestScore struct {
+	AreaID   int     `json:"area_id"`
+	Country  string  `json:"country"`
+	Province string  `json:"province"`
+	Kind     string  `json:"kind"`
+	Horizon  int     `json:"horizon"`
+	Points   int     `json:"points"`
+	MAE      float64 `json:"mae"`
+	MAPE     float64 `json:"mape"`
+}
+
+// Backtest replays the complete days of this series, forecasting horizon days ahead with model
+// after each day from minDays on, and scores the forecasts against the values later reported
+func (d *Data) Backtest(dataKind, horizon, minDays int, model ForecastModel) BacktestScore {
+	score := BacktestScore{
+		AreaID:   d.ID,
+		Country:  d.Country,
+		Province: d.Province,
+		Kind:     DataKindName(dataKind),
+		Horizon:  horizon,
+	}
+
+	var errors, percents float64
+	var percentPoints int
+	end := d.CompleteCount()
+	for i := minDays; i+horizon <= end; i++ {
+		forecast := model(d.copyDays(0, i), dataKind, horizon)
+		if len(forecast) < horizon {
+			continue
+		}
+		for h, f := range forecast[:horizon] {
+			actual := float64(d.Days[i+h].Value(dataKind))
+			e := math.Abs(f - actual)
+			errors += e
+			score.Points++
+			if actual > 0 {
+				percents += e / actual * 100
+				percentPoints++
+			}
+		}
+	}
+
+	if score.Points > 0 {
+		score.MAE = errors / float64(score.Points)
+	}
+	if percentPoints > 0 {
+		score.MAPE = percents / float64(percentPoints)
+	}
+	return score
+}
+
+// Backtest scores the model on each series in the slice, most accurate first by MAPE
+// series for which the model could not make any forecasts are omitted
+func (slice Slice) Backtest(dataKind, horizon, minDays int, model ForecastModel) []BacktestScore {
+	scores := []BacktestScore{}
+	for _, s := range slice {
+		score := s.Backtest(dataKind, horizon, minDays, model)
+		if score.Points > 0 {
+			scores = append(scores, score)
+		}
+	}
+
+	sort.Slice(scores, func(i, j int) bool {
+		if scores[i].MAPE == scores[j].MAPE {
+			return scores[i].AreaID < scores[j].AreaID
+		}
+		return scores[i].MAPE < scores[j].MAPE
+	})
+	return scores
+}
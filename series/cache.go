@@ -0,0 +1,177 @@
+package series
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// CacheName is the name of the cache file in the data directory
+const CacheName = "cache.gob.gz"
+
+// cacheVersion is incremented when the types saved in the cache change, so that older caches are ignored
+const cacheVersion = 6
+
+// cacheFiles are the data files a dataset is loaded from, the cache is only used if none have changed
+var cacheFiles = []string{"aliases.csv", "areas.csv", "events.csv", "series.csv", "mortality.csv", "flu.csv", "vaccinations.csv", "variants.csv", "sex.csv", "notes.csv", "overrides.csv", "archive.json"}
+
+// cacheFile is the contents of the cache file, a dataset with the key of the files it was loaded from
+type cacheFile struct {
+	Key     string
+	Dataset Slice
+}
+
+// cachedData is a series as saved in the cache, the fields of the series with its columns of values by data kind
+type cachedData struct {
+	Series  *dataFields
+	Columns map[int]*column
+}
+
+// dataFields has the fields of Data without its methods, so it is encoded without calling GobEncode
+type dataFields Data
+
+// GobEncode encodes the series with its columns of values, which are not exported
+func (d *Data) GobEncode() ([]byte, error) {
+	var b bytes.Buffer
+	cached := cachedData{Series: (*dataFields)(d), Columns: make(map[int]*column)}
+	for _, kind := range d.kinds() {
+		cached.Columns[kind] = d.columns[kind]
+	}
+	err := gob.NewEncoder(&b).Encode(cached)
+	return b.Bytes(), err
+}
+
+// GobDecode decodes a series encoded by GobEncode, and attaches its days to its columns
+func (d *Data) GobDecode(data []byte) error {
+	cached := cachedData{Series: (*dataFields)(d)}
+	err := gob.NewDecoder(bytes.NewReader(data)).Decode(&cached)
+	if err != nil {
+		return err
+	}
+	d.columns = nil
+	for kind, c := range cached.Columns {
+		if c.Deltas == nil {
+			c.Deltas = make([]int, len(d.Days))
+		}
+		c.index()
+		d.columnFor(kind)
+		d.columns[kind] = c
+	}
+	block := make([]Day, len(d.Days))
+	for i, day := range d.Days {
+		block[i] = *day
+	}
+	d.setDays(block)
+	return nil
+}
+
+// SaveCache saves the dataset of the default store to the cache, see Store.SaveCache
+func SaveCache() error {
+	return DefaultStore().SaveCache()
+}
+
+// SaveCache saves the dataset as a compressed gob in the data directory it was loaded from, with a key
+// of the data files, it should be called after the data files are saved so the key matches them
+// nothing is saved unless caching is set, or while provinces are pending as they have not been loaded
+func (st *Store) SaveCache() error {
+	config := st.Config()
+	if !config.Cache || config.LazyProvinces {
+		return nil
+	}
+
+	// Datasets are replaced rather than changed once swapped in, so this one may be read without the lock
+	st.mutex.RLock()
+	dataset := st.dataset
+	st.mutex.RUnlock()
+
+	// The cache is saved with the data files the dataset was loaded from
+	dataPath := config.DataPath
+	if len(dataset) > 0 && dataset[0].files != nil {
+		dataPath = dataset[0].files.path
+	}
+	key, err := dataCacheKey(dataPath, config)
+	if err != nil {
+		return err
+	}
+	return saveCache(filepath.Join(dataPath, CacheName), cacheFile{Key: key, Dataset: dataset})
+}
+
+// saveCache writes the cache file to p, writing alongside and renaming so a load never reads a partial file
+func saveCache(p string, cache cacheFile) error {
+	start := time.Now()
+	f, err := ioutil.TempFile(filepath.Dir(p), "cache-*.gob.gz")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	z := gzip.NewWriter(f)
+	err = gob.NewEncoder(z).Encode(cache)
+	if err == nil {
+		err = z.Close()
+	}
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("cache: failed to encode:%s", err)
+	}
+	err = f.Close()
+	if err != nil {
+		return err
+	}
+	log.Printf("series: saved cache of %d series in %s", len(cache.Dataset), time.Since(start))
+	return os.Rename(f.Name(), p)
+}
+
+// loadCache returns the cached dataset in dataPath if its key is key, or nil if there is no current cache
+func loadCache(dataPath, key string) Slice {
+	f, err := os.Open(filepath.Join(dataPath, CacheName))
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	z, err := gzip.NewReader(f)
+	if err != nil {
+		log.Printf("series: ignored invalid cache:%s", err)
+		return nil
+	}
+	var cache cacheFile
+	err = gob.NewDecoder(z).Decode(&cache)
+	if err != nil {
+		log.Printf("series: ignored invalid cache:%s", err)
+		return nil
+	}
+	if cache.Key != key {
+		log.Printf("series: ignored cache as data files have changed")
+		return nil
+	}
+	return cache.Dataset
+}
+
+// dataCacheKey returns a hash of the data files in dataPath with the settings and day they are loaded with
+// days added to reach today and the provisional days depend on the day, so caches are used on the day saved
+func dataCacheKey(dataPath string, config StoreConfig) (string, error) {
+	h := sha256.New()
+	fmt.Fprintf(h, "%d|%s|%s|%d|%d|%v|", cacheVersion, time.Now().UTC().Format("2006-01-02"), config.StartDate.Format("2006-01-02"), config.Interpolation, config.RetentionDays, config.Dataset)
+	for _, name := range cacheFiles {
+		data, err := ioutil.ReadFile(filepath.Join(dataPath, name))
+		if os.IsNotExist(err) {
+			// Files other than areas and series are optional
+			fmt.Fprintf(h, "%s:missing|", name)
+			continue
+		} else if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "%s:%d|", name, len(data))
+		h.Write(data)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,114 @@
+package series
+
+import (
+	"fmt"
+	"math"
+)
+
+// DefaultLagMean and DefaultLagSD are the mean and standard deviation in days of the default lag from confirmation to death
+const (
+	DefaultLagMean = 14.0
+	DefaultLagSD   = 7.0
+)
+
+// cfrMinResolved is the number of cases with outcomes expected to be known below which no adjusted rate is given
+const cfrMinResolved = 100
+
+// DeathLag is the distribution of the days from confirmation to death of the cases which die
+// it is a gamma distribution, which is skewed towards late deaths as observed in most countries
+type DeathLag struct {
+	Mean float64
+	SD   float64
+
+	// cumulative is the share of deaths expected by each day after confirmation, reaching 1 on the last day
+	cumulative []float64
+}
+
+// NewDeathLag returns a lag with the mean and standard deviation in days given
+// the distribution is cut off when almost all deaths are expected, at 4 standard deviations after the mean
+func NewDeathLag(mean, sd float64) (DeathLag, error) {
+	if mean <= 0 || sd <= 0 || mean+4*sd > 365 {
+		return DeathLag{}, fmt.Errorf("series: invalid death lag mean:%g sd:%g", mean, sd)
+	}
+
+	shape, scale := mean*mean/(sd*sd), sd*sd/mean
+	lgamma, _ := math.Lgamma(shape)
+	days := int(math.Ceil(mean + 4*sd))
+
+	// The density at the middle of each day is used for the share of deaths on that day
+	lag := DeathLag{Mean: mean, SD: sd, cumulative: make([]float64, days)}
+	total := 0.0
+	for i := range lag.cumulative {
+		x := float64(i) + 0.5
+		total += math.Exp((shape-1)*math.Log(x) - x/scale - lgamma - shape*math.Log(scale))
+		lag.cumulative[i] = total
+	}
+	for i := range lag.cumulative {
+		lag.cumulative[i] /= total
+	}
+	return lag, nil
+}
+
+// DefaultDeathLag returns the lag from confirmation to death used unless another is chosen
+func DefaultDeathLag() DeathLag {
+	lag, _ := NewDeathLag(DefaultLagMean, DefaultLagSD)
+	return lag
+}
+
+// Share returns the share of deaths expected within days of confirmation
+func (l DeathLag) Share(days int) float64 {
+	if days < 0 || len(l.cumulative) == 0 {
+		return 0
+	}
+	if days >= len(l.cumulative) {
+		return 1
+	}
+	return l.cumulative[days]
+}
+
+// CaseFatalityRates returns deaths as a percentage of confirmed cases on each day, the rate on the last day is CaseFatalityRate
+// this underestimates the rate while cases grow, as many of the cases confirmed recently have not died yet
+func (d *Data) CaseFatalityRates() []float64 {
+	rates := make([]float64, len(d.Days))
+	for i, day := range d.Days {
+		rates[i] = percent(day.Value(DataDeaths), day.Value(DataConfirmed))
+	}
+	return rates
+}
+
+// AdjustedCaseFatalityRates returns deaths on each day as a percentage of the cases confirmed by then whose outcome
+// is expected to be known, matching deaths to cases by the lag given rather than dividing by cases on the same day
+// cases confirmed on each day count in proportion to the share of their deaths expected by then, see DeathLag
+// rates are zero until there are cfrMinResolved cases with known outcomes
+func (d *Data) AdjustedCaseFatalityRates(lag DeathLag) []float64 {
+	return d.Memo(fmt.Sprintf("cfr:%g:%g", lag.Mean, lag.SD), func() interface{} {
+		daily := d.DailyValues(DataConfirmed)
+		rates := make([]float64, len(d.Days))
+		for i, day := range d.Days {
+			// Cases confirmed before the lag have outcomes known, the rest count by the share of deaths expected
+			resolved, j := 0.0, i
+			for ; j >= 0 && j > i-len(lag.cumulative); j-- {
+				resolved += float64(daily[j]) * lag.Share(i-j)
+			}
+			if j >= 0 {
+				resolved += float64(d.Days[j].Value(DataConfirmed))
+			} else if d.PreviousDay != nil {
+				resolved += float64(d.PreviousDay.Value(DataConfirmed))
+			}
+
+			if resolved >= cfrMinResolved {
+				rates[i] = float64(day.Value(DataDeaths)) * 100 / resolved
+			}
+		}
+		return rates
+	}).([]float64)
+}
+
+// AdjustedCaseFatalityRate returns the rate on the last day adjusted for the lag given, see AdjustedCaseFatalityRates
+func (d *Data) AdjustedCaseFatalityRate(lag DeathLag) float64 {
+	rates := d.AdjustedCaseFatalityRates(lag)
+	if len(rates) == 0 {
+		return 0
+	}
+	return rates[len(rates)-1]
+}
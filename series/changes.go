@@ -0,0 +1,270 @@
+package series
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// maxRevisions is the number of biggest revisions recorded per change
+const maxRevisions = 20
+
+// Change records what changed in the dataset after one refresh
+type Change struct {
+	// Version is incremented for each change recorded
+	Version int `json:"version"`
+
+	// UTC time the change was recorded
+	CreatedAt time.Time `json:"created_at"`
+
+	// Names of the sources fetched successfully for this refresh
+	Sources []string `json:"sources"`
+
+	// Areas which have any changed values
+	Areas []AreaChange `json:"areas"`
+
+	// The biggest revisions to days before the last day, largest first
+	Revisions []Revision `json:"revisions"`
+
+	// The last date in the dataset before and after this change
+	PreviousMaxDate time.Time `json:"previous_max_date"`
+	MaxDate         time.Time `json:"max_date"`
+}
+
+// AreaChange records an area changed by a refresh
+type AreaChange struct {
+	AreaID   int    `json:"area_id"`
+	Country  string `json:"country"`
+	Province string `json:"province"`
+	Days     int    `json:"days"`
+}
+
+// Revision records a change to one value on one day in an area
+type Revision struct {
+	AreaID int       `json:"area_id"`
+	Date   time.Time `json:"date"`
+	Kind   string    `json:"kind"`
+	Before int       `json:"before"`
+	After  int       `json:"after"`
+}
+
+// Delta returns the absolute size of this revision
+func (r Revision) Delta() int {
+	if r.After > r.Before {
+		return r.After - r.Before
+	}
+	return r.Before - r.After
+}
+
+// Snapshot stores a copy of the day data for every area at a point in time
+type Snapshot struct {
+	CreatedAt time.Time
+	Days      map[int][]Day
+}
+
+// MaxDate returns the last date held in this snapshot
+func (s *Snapshot) MaxDate() time.Time {
+	var max time.Time
+	for _, days := range s.Days {
+		if len(days) > 0 && days[len(days)-1].Date.After(max) {
+			max = days[len(days)-1].Date
+		}
+	}
+	return max
+}
+
+// TakeSnapshot returns a copy of the data in the default store, see Store.TakeSnapshot
+func TakeSnapshot() *Snapshot {
+	return DefaultStore().TakeSnapshot()
+}
+
+// TakeSnapshot returns a copy of the current data for all areas
+// pending provinces are read from the data files into copies, so that changes to them are recorded once loaded
+func (st *Store) TakeSnapshot() *Snapshot {
+	st.mutex.RLock()
+	defer st.mutex.RUnlock()
+	dataset, err := st.dataset.withPending(all)
+	if err != nil {
+		log.Printf("series: failed to load pending series for snapshot:%s", err)
+		dataset = st.dataset
+	}
+	return dataset.snapshot()
+}
+
+// snapshot returns a copy of the data for all areas in this slice
+func (slice Slice) snapshot() *Snapshot {
+	s := &Snapshot{
+		CreatedAt: time.Now().UTC(),
+		Days:      make(map[int][]Day, len(slice)),
+	}
+	for _, series := range slice {
+		days := make([]Day, len(series.Days))
+		for i, day := range series.Days {
+			days[i] = *day
+		}
+		s.Days[series.ID] = days
+	}
+	return s
+}
+
+// RecordChanges records changes in the default store since before, see Store.RecordChanges
+func RecordChanges(sources []string, before *Snapshot) *Change {
+	return DefaultStore().RecordChanges(sources, before)
+}
+
+// RecordChanges compares the current dataset with the snapshot before a refresh
+// and records a new Change in the changelog, which is returned
+func (st *Store) RecordChanges(sources []string, before *Snapshot) *Change {
+	after := st.TakeSnapshot()
+
+	change := &Change{
+		CreatedAt:       after.CreatedAt,
+		Sources:         sources,
+		PreviousMaxDate: before.MaxDate(),
+		MaxDate:         after.MaxDate(),
+		Areas:           []AreaChange{},
+		Revisions:       []Revision{},
+	}
+
+	st.mutex.RLock()
+	for _, series := range st.dataset {
+		revisions, days := diffDays(series.ID, before.Days[series.ID], after.Days[series.ID])
+		if days == 0 {
+			continue
+		}
+		change.Areas = append(change.Areas, AreaChange{
+			AreaID:   series.ID,
+			Country:  series.Country,
+			Province: series.Province,
+			Days:     days,
+		})
+		change.Revisions = append(change.Revisions, revisions...)
+	}
+	st.mutex.RUnlock()
+
+	// Keep only the biggest revisions
+	sort.SliceStable(change.Revisions, func(i, j int) bool {
+		return change.Revisions[i].Delta() > change.Revisions[j].Delta()
+	})
+	if len(change.Revisions) > maxRevisions {
+		change.Revisions = change.Revisions[:maxRevisions]
+	}
+
+	// Lock changes to record this change with the next version
+	st.changesMutex.Lock()
+	defer st.changesMutex.Unlock()
+	change.Version = 1
+	if len(st.changes) > 0 {
+		change.Version = st.changes[len(st.changes)-1].Version + 1
+	}
+	st.changes = append(st.changes, change)
+
+	return change
+}
+
+// diffDays returns the revisions to existing days (excluding the last day, which is expected to change)
+// and the count of days which changed or were added in after
+func diffDays(areaID int, before, after []Day) (revisions []Revision, changed int) {
+	for i, day := range after {
+		if i >= len(before) {
+			changed++
+			continue
+		}
+		old := before[i]
+		if old.Equal(&day) {
+			continue
+		}
+		changed++
+
+		// The last day is updated throughout the day so is not a revision
+		if i == len(before)-1 {
+			continue
+		}
+		for _, kind := range day.dataKinds() {
+			if old.Value(kind) != day.Value(kind) {
+				revisions = append(revisions, Revision{
+					AreaID: areaID,
+					Date:   day.Date,
+					Kind:   DataKindName(kind),
+					Before: old.Value(kind),
+					After:  day.Value(kind),
+				})
+			}
+		}
+	}
+	return revisions, changed
+}
+
+// ChangesSince returns changes recorded in the default store after version, see Store.ChangesSince
+func ChangesSince(version int) []*Change {
+	return DefaultStore().ChangesSince(version)
+}
+
+// ChangesSince returns all changes recorded after the version given
+func (st *Store) ChangesSince(version int) []*Change {
+	st.changesMutex.RLock()
+	defer st.changesMutex.RUnlock()
+
+	result := []*Change{}
+	for _, c := range st.changes {
+		if c.Version > version {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+// LoadChanges loads the changelog of the default store, see Store.LoadChanges
+func LoadChanges(p string) error {
+	return DefaultStore().LoadChanges(p)
+}
+
+// LoadChanges loads the changelog from the json file at path p
+// a missing file is not an error, the changelog is left empty
+func (st *Store) LoadChanges(p string) error {
+	f, err := os.Open(filepath.Clean(p))
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var loaded []*Change
+	err = json.NewDecoder(f).Decode(&loaded)
+	if err != nil {
+		return fmt.Errorf("changes: failed to decode changes file:%s", err)
+	}
+
+	st.changesMutex.Lock()
+	defer st.changesMutex.Unlock()
+	st.changes = loaded
+	return nil
+}
+
+// SaveChanges saves the changelog of the default store, see Store.SaveChanges
+func SaveChanges(p string) error {
+	return DefaultStore().SaveChanges(p)
+}
+
+// SaveChanges saves the changelog to a json file at path p
+func (st *Store) SaveChanges(p string) error {
+	st.changesMutex.RLock()
+	defer st.changesMutex.RUnlock()
+
+	f, err := os.Create(filepath.Clean(p))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	err = json.NewEncoder(f).Encode(st.changes)
+	if err != nil {
+		return fmt.Errorf("changes: failed to write changes file:%s", err)
+	}
+	return nil
+}
@@ -0,0 +1,344 @@
+package series
+
+// checkpointDays is the number of days between the cumulative values kept by a column
+// a value is found by adding at most this many deltas to the checkpoint before it
+const checkpointDays = 32
+
+// column holds the values of one data kind in a series, indexed by the index of the day in the series
+// values are stored as the change from the day before, so daily values are read as a slice rather than copied
+// and cumulative values are rebuilt on demand from checkpoints kept every checkpointDays days
+// fields are exported only so that columns are saved in the cache, checkpoints are rebuilt when loaded
+type column struct {
+	// Deltas are the changes in value on each day from the day before
+	Deltas []int
+
+	// Previous is the value on the day before the first, which the first delta is from
+	Previous int
+
+	// Sources records the source of each value, it is nil until a source is set
+	Sources []Source
+
+	// checkpoints hold the cumulative value on every checkpointDays day, from the first
+	// they are kept up to date by every change to the column, as series are read concurrently once swapped in
+	checkpoints []int
+}
+
+// newColumn returns a column of n days with zero values
+func newColumn(n int) *column {
+	c := &column{Deltas: make([]int, n)}
+	c.index()
+	return c
+}
+
+// columnFromValues returns a column of the cumulative values given, starting from previous
+func columnFromValues(values []int, previous int) *column {
+	c := &column{Deltas: DailyFromCumulative(values, previous), Previous: previous}
+	c.index()
+	return c
+}
+
+// index rebuilds the checkpoints of the column from its deltas
+func (c *column) index() {
+	c.checkpoints = make([]int, 0, (len(c.Deltas)+checkpointDays-1)/checkpointDays)
+	v := c.Previous
+	for i, delta := range c.Deltas {
+		v += delta
+		if i%checkpointDays == 0 {
+			c.checkpoints = append(c.checkpoints, v)
+		}
+	}
+}
+
+// len returns the number of days in the column
+func (c *column) len() int {
+	return len(c.Deltas)
+}
+
+// value returns the value on day i, or on the day before the first if i is -1
+func (c *column) value(i int) int {
+	if i < 0 {
+		return c.Previous
+	}
+	start := i - i%checkpointDays
+	v := c.checkpoints[start/checkpointDays]
+	for _, delta := range c.Deltas[start+1 : i+1] {
+		v += delta
+	}
+	return v
+}
+
+// set sets the value on day i, or on the day before the first if i is -1
+// only the value on day i changes, so the delta of the day after moves by the opposite amount
+func (c *column) set(i, v int) {
+	if i < 0 {
+		if len(c.Deltas) > 0 {
+			c.Deltas[0] -= v - c.Previous
+		}
+		c.Previous = v
+		return
+	}
+	change := v - c.value(i)
+	if change == 0 {
+		return
+	}
+	c.Deltas[i] += change
+	if i+1 < len(c.Deltas) {
+		c.Deltas[i+1] -= change
+	}
+	if i%checkpointDays == 0 {
+		c.checkpoints[i/checkpointDays] += change
+	}
+}
+
+// setDaily sets the change in value on day i, which moves the values on it and all the days after by the same amount
+func (c *column) setDaily(i, v int) {
+	change := v - c.Deltas[i]
+	if change == 0 {
+		return
+	}
+	c.Deltas[i] = v
+	for j := (i + checkpointDays - 1) / checkpointDays; j < len(c.checkpoints); j++ {
+		c.checkpoints[j] += change
+	}
+}
+
+// cumulative returns the values on each day, rebuilt from the deltas
+func (c *column) cumulative() []int {
+	return CumulativeFromDaily(c.Deltas, c.Previous)
+}
+
+// daily returns the change in value on each day, the slice is shared and must not be modified
+func (c *column) daily() []int {
+	return c.Deltas
+}
+
+// source returns the source of the value on day i, or on the day before the first if i is -1
+// the previous day has no sources recorded
+func (c *column) source(i int) Source {
+	if i < 0 || c.Sources == nil {
+		return SourceUnknown
+	}
+	return c.Sources[i]
+}
+
+// setSource sets the source of the value on day i, the previous day is ignored
+func (c *column) setSource(i int, source Source) {
+	if i < 0 {
+		return
+	}
+	if c.Sources == nil {
+		if source == SourceUnknown {
+			return
+		}
+		c.Sources = make([]Source, len(c.Deltas), cap(c.Deltas))
+	}
+	c.Sources[i] = source
+}
+
+// grow adds n days with zero values to the end of the column
+func (c *column) grow(n int) {
+	if n <= 0 {
+		return
+	}
+	last := c.value(len(c.Deltas) - 1)
+	for i := len(c.Deltas); i < len(c.Deltas)+n; i++ {
+		if i%checkpointDays == 0 {
+			c.checkpoints = append(c.checkpoints, 0)
+		}
+	}
+	c.Deltas = append(c.Deltas, make([]int, n)...)
+	c.Deltas[len(c.Deltas)-n] = -last
+	if c.Sources != nil {
+		c.Sources = append(c.Sources, make([]Source, n)...)
+	}
+}
+
+// apply sets the values of the first days of the column to values, or adds values to them if merge is true
+// the column must have at least as many days as values
+func (c *column) apply(values []int, merge bool) {
+	if len(values) == 0 {
+		return
+	}
+	n := len(values)
+	if merge {
+		previous := 0
+		for i, v := range values {
+			c.Deltas[i] += v - previous
+			previous = v
+		}
+		if n < len(c.Deltas) {
+			c.Deltas[n] -= previous
+		}
+	} else {
+		if n < len(c.Deltas) {
+			c.Deltas[n] += c.value(n-1) - values[n-1]
+		}
+		previous := c.Previous
+		for i, v := range values {
+			c.Deltas[i] = v - previous
+			previous = v
+		}
+	}
+	c.index()
+}
+
+// slice returns a copy of the days from start to end, with the value of the day before start as the previous value
+func (c *column) slice(start, end int) *column {
+	s := &column{
+		Deltas:   append([]int(nil), c.Deltas[start:end]...),
+		Previous: c.value(start - 1),
+	}
+	if c.Sources != nil {
+		s.Sources = append([]Source(nil), c.Sources[start:end]...)
+	}
+	s.index()
+	return s
+}
+
+// clone returns a copy of the column which shares no values with it
+func (c *column) clone() *column {
+	return c.slice(0, len(c.Deltas))
+}
+
+// gather returns a copy of the days at the indexes given, in the order given
+func (c *column) gather(indexes []int) *column {
+	cumulative := c.cumulative()
+	values := make([]int, len(indexes))
+	for i, index := range indexes {
+		values[i] = cumulative[index]
+	}
+	g := columnFromValues(values, c.Previous)
+	if c.Sources != nil {
+		g.Sources = make([]Source, len(indexes))
+		for i, index := range indexes {
+			g.Sources[i] = c.Sources[index]
+		}
+	}
+	return g
+}
+
+// concat returns the days of this column followed by those of next, with the previous value of this column
+func (c *column) concat(next *column) *column {
+	s := &column{
+		Deltas:   append(append(make([]int, 0, len(c.Deltas)+len(next.Deltas)), c.Deltas...), next.Deltas...),
+		Previous: c.Previous,
+	}
+	if len(c.Deltas) > 0 && len(next.Deltas) > 0 {
+		s.Deltas[len(c.Deltas)] += next.Previous - c.value(len(c.Deltas)-1)
+	}
+	if c.Sources != nil || next.Sources != nil {
+		s.Sources = make([]Source, len(s.Deltas))
+		for i := range s.Deltas {
+			if i < len(c.Deltas) {
+				s.Sources[i] = c.source(i)
+			} else {
+				s.Sources[i] = next.source(i - len(c.Deltas))
+			}
+		}
+	}
+	s.index()
+	return s
+}
+
+// merge adds the values of in to the first n days of this column, in may be nil if it has no values
+// the sources of the days merged are SourceCalculated, and previous values are added if previous is true
+// as values add, so do their deltas and checkpoints, apart from the delta of the day after the last merged
+func (c *column) merge(in *column, n int, previous bool) {
+	if in != nil && n > 0 {
+		deltas := c.Deltas[:n]
+		for i, delta := range in.Deltas[:n] {
+			deltas[i] += delta
+		}
+		if previous {
+			c.Previous += in.Previous
+		} else {
+			deltas[0] += in.Previous
+		}
+		if n < len(c.Deltas) {
+			c.Deltas[n] -= in.value(n - 1)
+		}
+		checkpoints := c.checkpoints[:(n+checkpointDays-1)/checkpointDays]
+		for i, v := range in.checkpoints[:len(checkpoints)] {
+			checkpoints[i] += v
+		}
+	}
+
+	// Sources are filled by copying those already set, which is much faster than setting each in turn
+	if c.Sources == nil {
+		c.Sources = make([]Source, len(c.Deltas), cap(c.Deltas))
+	}
+	if n > 0 {
+		sources := c.Sources[:n]
+		sources[0] = SourceCalculated
+		for i := 1; i < n; i *= 2 {
+			copy(sources[i:], sources[:i])
+		}
+	}
+}
+
+// reset sets every value in the column to zero and clears sources
+func (c *column) reset() {
+	for i := range c.Deltas {
+		c.Deltas[i] = 0
+	}
+	for i := range c.checkpoints {
+		c.checkpoints[i] = 0
+	}
+	c.Previous = 0
+	c.Sources = nil
+}
+
+// column returns the column of values of dataKind, or nil if the series has no values of that kind
+func (d *Data) column(dataKind int) *column {
+	if dataKind < 0 || dataKind >= len(d.columns) {
+		return nil
+	}
+	return d.columns[dataKind]
+}
+
+// columnFor returns the column of values of dataKind, adding a column of zero values if there is none
+func (d *Data) columnFor(dataKind int) *column {
+	for len(d.columns) <= dataKind {
+		d.columns = append(d.columns, nil)
+	}
+	if d.columns[dataKind] == nil {
+		d.columns[dataKind] = newColumn(len(d.Days))
+	}
+	return d.columns[dataKind]
+}
+
+// kinds returns the data kinds with values in this series, in order
+func (d *Data) kinds() []int {
+	var kinds []int
+	for kind, c := range d.columns {
+		if c != nil {
+			kinds = append(kinds, kind)
+		}
+	}
+	return kinds
+}
+
+// dataKinds returns the data kinds stored in the series file by the store of the series this day is in
+func (d *Day) dataKinds() []int {
+	return d.series.settings().kinds
+}
+
+// setValues sets the values of the data kinds stored in the series file on this day, in the order of dataKinds
+func (d *Day) setValues(values ...int) {
+	kinds := d.dataKinds()
+	for i, v := range values {
+		if i < len(kinds) {
+			d.SetData(kinds[i], v)
+		}
+	}
+}
+
+// values returns the values of the data kinds stored in the series file on this day, in the order of dataKinds
+func (d *Day) values() []int {
+	kinds := d.dataKinds()
+	values := make([]int, len(kinds))
+	for i, kind := range kinds {
+		values[i] = d.Value(kind)
+	}
+	return values
+}
@@ -0,0 +1,72 @@
+package series
+
+import (
+	"fmt"
+	"time"
+)
+
+// DailyFromCumulative returns the change in each of totals from the total before it
+// previous is the total before the first, zero if totals start at the beginning of a series
+// totals revised down give negative daily values, see SpreadRevisions
+func DailyFromCumulative(totals []int, previous int) []int {
+	daily := make([]int, len(totals))
+	for i, v := range totals {
+		daily[i] = v - previous
+		previous = v
+	}
+	return daily
+}
+
+// CumulativeFromDaily returns the running totals of daily values, starting from previous
+// previous is the total before the first day, zero if daily values start at the beginning of a series
+func CumulativeFromDaily(daily []int, previous int) []int {
+	totals := make([]int, len(daily))
+	for i, v := range daily {
+		previous += v
+		totals[i] = previous
+	}
+	return totals
+}
+
+// SpreadRevisions returns daily values with negative values removed, by taking them from the days before
+// sources revise totals down when earlier reports are corrected, which appear as a day with negative values
+// the sum of the values is unchanged, a revision larger than all values before it is left on the first day
+func SpreadRevisions(daily []int) []int {
+	result := make([]int, len(daily))
+	carry := 0
+	for i := len(daily) - 1; i >= 0; i-- {
+		v := daily[i] + carry
+		carry = 0
+		if v < 0 && i > 0 {
+			carry, v = v, 0
+		}
+		result[i] = v
+	}
+	return result
+}
+
+// SetDaily sets the values of dataKind from daily values, for sources which publish new values per day rather than totals
+// totals are calculated from the total on the day before startDate, and days after those given are moved by the
+// same amount as the last day given, so their daily values are unchanged when a source revises earlier days
+func (d *Data) SetDaily(startDate time.Time, dataKind int, daily []int) error {
+	if len(daily) == 0 {
+		return nil
+	}
+	if DataKindName(dataKind) == "" {
+		return fmt.Errorf("series: invalid data kind:%d", dataKind)
+	}
+	start := d.dayIndex(startDate)
+	if start < 0 || start+len(daily) > len(d.Days) {
+		return fmt.Errorf("series: daily values outside series from:%s days:%d", startDate.Format("2006-01-02"), len(daily))
+	}
+
+	for i, v := range daily {
+		d.setDailyValue(start+i, dataKind, v)
+	}
+	return nil
+}
+
+// setDailyValue sets the value of dataKind on day i from its daily value, and moves the totals on later days by the same amount
+func (d *Data) setDailyValue(i, dataKind, daily int) {
+	d.columnFor(dataKind).setDaily(i, daily)
+}
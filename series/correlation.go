@@ -0,0 +1,107 @@
+package series
+
+import (
+	"math"
+	"time"
+)
+
+// correlationAverageDays is the number of days averaged before correlating, to remove weekly reporting cycles
+const correlationAverageDays = 7
+
+// correlationMinPoints is the minimum number of days two series must overlap to be correlated
+const correlationMinPoints = 14
+
+// LagCorrelation is the correlation between two series with the second shifted by Lag days
+type LagCorrelation struct {
+	Lag         int     `json:"lag"`
+	Correlation float64 `json:"correlation"`
+	Points      int     `json:"points"`
+}
+
+// Correlation records the lagged correlation of daily values between two areas
+// a positive BestLag means the first area leads the second by that many days
+type Correlation struct {
+	AreaID          int              `json:"area_id"`
+	OtherID         int              `json:"other_id"`
+	Kind            string           `json:"kind"`
+	BestLag         int              `json:"best_lag"`
+	BestCorrelation float64          `json:"best_correlation"`
+	Lags            []LagCorrelation `json:"lags"`
+}
+
+// LaggedCorrelation correlates the averaged daily values of dataKind for a with those of b
+// for each lag from -maxLag to maxLag days, matching days by date and ignoring provisional days
+// lags with fewer than correlationMinPoints overlapping days or no variation are omitted
+func LaggedCorrelation(a, b *Data, dataKind, maxLag int) Correlation {
+	c := Correlation{
+		AreaID:  a.ID,
+		OtherID: b.ID,
+		Kind:    DataKindName(dataKind),
+		Lags:    []LagCorrelation{},
+	}
+
+	for _, l := range correlateLags(a.averagesByDate(dataKind), b.averagesByDate(dataKind), -maxLag, maxLag) {
+		if len(c.Lags) == 0 || l.Correlation > c.BestCorrelation {
+			c.BestLag, c.BestCorrelation = l.Lag, l.Correlation
+		}
+		c.Lags = append(c.Lags, l)
+	}
+	return c
+}
+
+// correlateLags correlates the values of av with those of bv shifted by each lag from minLag to maxLag days
+// lags with fewer than correlationMinPoints overlapping days or no variation are omitted
+func correlateLags(av, bv map[time.Time]float64, minLag, maxLag int) []LagCorrelation {
+	var lags []LagCorrelation
+	for lag := minLag; lag <= maxLag; lag++ {
+		var xs, ys []float64
+		for date, x := range av {
+			if y, ok := bv[date.AddDate(0, 0, lag)]; ok {
+				xs = append(xs, x)
+				ys = append(ys, y)
+			}
+		}
+		if len(xs) < correlationMinPoints {
+			continue
+		}
+		r, ok := pearson(xs, ys)
+		if !ok {
+			continue
+		}
+		lags = append(lags, LagCorrelation{Lag: lag, Correlation: r, Points: len(xs)})
+	}
+	return lags
+}
+
+// averagesByDate returns the moving average of daily values for complete days keyed by date
+func (d *Data) averagesByDate(dataKind int) map[time.Time]float64 {
+	n := d.CompleteCount()
+	averages := MovingAverage(d.DailyValues(dataKind)[:n], correlationAverageDays)
+	values := make(map[time.Time]float64, n)
+	for i, v := range averages {
+		values[d.Days[i].Date] = v
+	}
+	return values
+}
+
+// pearson returns the correlation coefficient of xs and ys
+// ok is false if either has no variation
+func pearson(xs, ys []float64) (r float64, ok bool) {
+	n := float64(len(xs))
+	var xMean, yMean float64
+	for i := range xs {
+		xMean += xs[i] / n
+		yMean += ys[i] / n
+	}
+	var sxy, sxx, syy float64
+	for i := range xs {
+		dx, dy := xs[i]-xMean, ys[i]-yMean
+		sxy += dx * dy
+		sxx += dx * dx
+		syy += dy * dy
+	}
+	if sxx == 0 || syy == 0 {
+		return 0, false
+	}
+	return sxy / math.Sqrt(sxx*syy), true
+}
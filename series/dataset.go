@@ -0,0 +1,180 @@
+package series
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Metric is a value counted each day for a disease, such as deaths or confirmed cases
+type Metric struct {
+	// Name is the name of the metric for machines, it is used in the series file header and api params
+	Name string `json:"name"`
+
+	// Label is the name of the metric for display
+	Label string `json:"label"`
+}
+
+// metrics holds the metric stored in each data kind, indexed by data kind, use metricsMutex to access
+// each name has one kind whichever dataset counts it, so deaths are always read with DataDeaths
+// and metrics without a fixed kind are given one from DataCustom when a dataset names them
+var metrics = []Metric{
+	DataDeaths:     {Name: "deaths", Label: "Deaths"},
+	DataConfirmed:  {Name: "confirmed", Label: "Confirmed"},
+	DataRecovered:  {Name: "recovered", Label: "Recovered"},
+	DataTested:     {Name: "tested", Label: "Tested"},
+	DataExcess:     {Name: "excess", Label: "Excess Deaths"},
+	DataFlu:        {Name: "flu", Label: "Flu Baseline Deaths"},
+	DataVaccinated: {Name: "vaccinated", Label: "Vaccinated"},
+}
+
+var metricsMutex sync.RWMutex
+
+// loadedKinds are the data kinds loaded from their own data files rather than the series file
+// they are not counted by datasets, but may be read whatever the dataset
+var loadedKinds = []int{DataExcess, DataFlu, DataVaccinated}
+
+// metricKind returns the data kind of the metric named, or DataNone if no metric has the name
+func metricKind(name string) int {
+	metricsMutex.RLock()
+	defer metricsMutex.RUnlock()
+	return findMetric(name)
+}
+
+// findMetric returns the data kind of the metric named, metricsMutex must be held
+func findMetric(name string) int {
+	for kind, m := range metrics {
+		if m.Name != "" && m.Name == name {
+			return kind
+		}
+	}
+	return DataNone
+}
+
+// addMetric returns the data kind of the metric, giving it the next kind from DataCustom if it has none
+func addMetric(m Metric) int {
+	kind := metricKind(m.Name)
+	if kind != DataNone {
+		return kind
+	}
+	metricsMutex.Lock()
+	defer metricsMutex.Unlock()
+	kind = findMetric(m.Name)
+	if kind == DataNone {
+		metrics = append(metrics, m)
+		kind = len(metrics) - 1
+	}
+	return kind
+}
+
+// metric returns the metric stored in the data kind, which is blank for unknown kinds
+func metric(dataKind int) Metric {
+	metricsMutex.RLock()
+	defer metricsMutex.RUnlock()
+	if dataKind <= DataNone || dataKind >= len(metrics) {
+		return Metric{}
+	}
+	return metrics[dataKind]
+}
+
+// Dataset describes the disease tracked and the metrics counted for it each day
+// the values of each metric are stored in the data kind of its name, so a dataset may count any number of metrics
+// and kinds of metrics which the dataset does not count are always zero
+type Dataset struct {
+	// Name is the name of the disease for display
+	Name string `json:"name"`
+
+	// Metrics are the metrics counted each day, at least one
+	Metrics []Metric `json:"metrics"`
+}
+
+// COVID19 is the dataset of COVID-19, which is tracked unless another dataset is set
+var COVID19 = Dataset{
+	Name: "COVID-19",
+	Metrics: []Metric{
+		metrics[DataDeaths],
+		metrics[DataConfirmed],
+		metrics[DataRecovered],
+		metrics[DataTested],
+	},
+}
+
+// NewDataset returns a dataset for the disease with the metrics named, labels are the names in title case
+// names must be unique, lower case letters and underscores, and not the name of another column of the series file
+// or of a metric loaded from its own data file, metrics named for the first time are given a data kind
+func NewDataset(name string, names ...string) (Dataset, error) {
+	if strings.TrimSpace(name) == "" {
+		return Dataset{}, fmt.Errorf("series: invalid blank dataset name")
+	}
+	if len(names) == 0 {
+		return Dataset{}, fmt.Errorf("series: dataset must have at least 1 metric")
+	}
+
+	d := Dataset{Name: name}
+	for _, m := range names {
+		if m == "" || strings.Trim(m, "abcdefghijklmnopqrstuvwxyz_") != "" {
+			return Dataset{}, fmt.Errorf("series: invalid metric name:%q", m)
+		}
+		if m == "day" || m == "area_id" || m == "sources" || isLoadedKind(metricKind(m)) || d.Kind(m) != DataNone {
+			return Dataset{}, fmt.Errorf("series: duplicate or reserved metric name:%s", m)
+		}
+		known := metric(metricKind(m))
+		if known.Name == "" {
+			known = Metric{Name: m, Label: strings.Title(strings.ReplaceAll(m, "_", " "))}
+		}
+		d.Metrics = append(d.Metrics, known)
+	}
+	for _, m := range d.Metrics {
+		addMetric(m)
+	}
+	return d, nil
+}
+
+// isLoadedKind returns true if the data kind is loaded from its own data file, see loadedKinds
+func isLoadedKind(dataKind int) bool {
+	for _, kind := range loadedKinds {
+		if kind == dataKind {
+			return true
+		}
+	}
+	return false
+}
+
+// CurrentDataset returns the dataset tracked by the default store, see Store.Dataset
+func CurrentDataset() Dataset {
+	return DefaultStore().Dataset()
+}
+
+// Kind returns the data kind the metric named is stored in, or DataNone if the dataset has no such metric
+func (d Dataset) Kind(name string) int {
+	for _, m := range d.Metrics {
+		if m.Name == name {
+			return addMetric(m)
+		}
+	}
+	return DataNone
+}
+
+// Metric returns the metric stored in the data kind, and false if the dataset has no metric for it
+func (d Dataset) Metric(dataKind int) (Metric, bool) {
+	for _, m := range d.Metrics {
+		if addMetric(m) == dataKind {
+			return m, true
+		}
+	}
+	return Metric{}, false
+}
+
+// Kinds returns the data kinds of the metrics in this dataset, in order
+func (d Dataset) Kinds() []int {
+	kinds := make([]int, len(d.Metrics))
+	for i, m := range d.Metrics {
+		kinds[i] = addMetric(m)
+	}
+	return kinds
+}
+
+// MetricLabel returns the label of the metric stored in the data kind for display, or blank if there is none
+func MetricLabel(dataKind int) string {
+	return metric(dataKind).Label
+}
@@ -2,27 +2,58 @@ package series
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 )
 
-// Day represents data for a day in a series
-// Totals are cumulative deaths etc, not single day counts
+// Day represents a day in a series, with the date and notes of the day
+// the values of the day are held by the series in a column for each data kind, see Value
+// values are cumulative deaths etc, not single day counts
 type Day struct {
-	Date      time.Time
-	Deaths    int
-	Confirmed int
-	Recovered int
-	Tested    int
+	Date time.Time
+
+	// Sex holds counts by sex where the source publishes them, otherwise it is nil
+	Sex *SexCounts
+
+	// Variants holds the share of sequenced cases by variant from the latest report, otherwise it is nil
+	// shares are shared by the days of a report, and are replaced rather than changed
+	Variants []VariantShare
+
+	// Notes explain changes in the data on this day, like a change in reporting methodology
+	Notes []string
+
+	// Provisional is true until the source publishes a complete file for this day
+	Provisional bool
+
+	// Interpolated is true if the source skipped this day, and its values were filled from the days around it
+	// interpolated days are not saved, so they are filled again when loaded
+	Interpolated bool
+
+	// series holds the values of this day at index, the previous day of a series has index -1
+	// days not in a series have no values
+	series *Data
+	index  int
 }
 
 // IsZero returns true if this day has all zero data (and thus doesn't need to be recorded)
 func (d *Day) IsZero() bool {
-	return d.Deaths+d.Confirmed+d.Recovered+d.Tested == 0
+	for _, kind := range d.dataKinds() {
+		if d.Value(kind) != 0 {
+			return false
+		}
+	}
+	return true
 }
 
 // String returns a string representation of this Day
 func (d *Day) String() string {
-	return fmt.Sprintf("%s %d-%d-%d-%d", d.DateMachine(), d.Deaths, d.Confirmed, d.Recovered, d.Tested)
+	kinds := d.dataKinds()
+	values := make([]string, len(kinds))
+	for i, kind := range kinds {
+		values[i] = strconv.Itoa(d.Value(kind))
+	}
+	return fmt.Sprintf("%s %s", d.DateMachine(), strings.Join(values, "-"))
 }
 
 // DateMachine returns a string for machines
@@ -35,48 +66,80 @@ func (d *Day) DateDisplay() string {
 	return d.Date.Format("2 Jan, 2006")
 }
 
+// Equal returns true if this day has the same date and data as day
+func (d *Day) Equal(day *Day) bool {
+	if !d.Date.Equal(day.Date) {
+		return false
+	}
+	for _, kind := range d.dataKinds() {
+		if d.Value(kind) != day.Value(kind) {
+			return false
+		}
+	}
+	return true
+}
+
+// Value returns the data on this day for the given data kind
+// zero is returned for unknown data kinds, or kinds with no values in the series
+func (d *Day) Value(dataKind int) int {
+	c := d.column(dataKind)
+	if c == nil {
+		return 0
+	}
+	return c.value(d.index)
+}
+
+// column returns the column of the series holding values of dataKind, or nil if there is none
+func (d *Day) column(dataKind int) *column {
+	if d.series == nil {
+		return nil
+	}
+	return d.series.column(dataKind)
+}
+
 // SetData sets data to this day for the given data kind
 // the data replaces existing data
 func (d *Day) SetData(dataKind, value int) error {
-	switch dataKind {
-	case DataDeaths:
-		d.Deaths = value
-	case DataConfirmed:
-		d.Confirmed = value
-	case DataRecovered:
-		d.Recovered = value
-	case DataTested:
-		d.Tested = value
-	default:
+	if DataKindName(dataKind) == "" {
 		return fmt.Errorf("invalid data kind:%d", dataKind)
 	}
-
+	if d.series == nil {
+		return fmt.Errorf("series: day not in a series:%s", d.DateMachine())
+	}
+	// Kinds without a column are zero, so there is no need to add one for a zero value
+	if value == 0 && d.column(dataKind) == nil {
+		return nil
+	}
+	d.series.columnFor(dataKind).set(d.index, value)
 	return nil
 }
 
-// SetAllData sets all the data on the day at once (save date)
-func (d *Day) SetAllData(deaths, confirmed, recovered, tested int) {
-	d.Deaths = deaths
-	d.Confirmed = confirmed
-	d.Recovered = recovered
-	d.Tested = tested
-}
-
 // MergeData adds to the data to this day for the given data kind
 // the data is combined with existing data with +=
 func (d *Day) MergeData(dataKind, value int) error {
-	switch dataKind {
-	case DataDeaths:
-		d.Deaths += value
-	case DataConfirmed:
-		d.Confirmed += value
-	case DataRecovered:
-		d.Recovered += value
-	case DataTested:
-		d.Tested += value
-	default:
-		return fmt.Errorf("invalid data kind:%d", dataKind)
+	return d.SetData(dataKind, d.Value(dataKind)+value)
+}
+
+// MaxDay combines a duplicate of this day by keeping the highest value of each data kind
+// along with its source, notes from both days are kept
+func (d *Day) MaxDay(day *Day) error {
+	if !d.Date.Equal(day.Date) {
+		return fmt.Errorf("series: mismatch on date:%s inday:%s", d.Date, day.Date)
+	}
+	for _, kind := range d.series.kinds() {
+		if day.Value(kind) > d.Value(kind) {
+			d.SetData(kind, day.Value(kind))
+			d.SetSource(kind, day.Source(kind))
+		}
 	}
+	if d.Sex == nil {
+		d.Sex = day.Sex
+	}
+	if d.Variants == nil {
+		d.Variants = day.Variants
+	}
+	d.Notes = append(d.Notes, day.Notes...)
+	d.Provisional = d.Provisional && day.Provisional
 
 	return nil
 }
@@ -87,10 +150,16 @@ func (d *Day) MergeDay(day *Day) error {
 	if !d.Date.Equal(day.Date) {
 		return fmt.Errorf("series: mismatch on date:%s inday:%s", d.Date, day.Date)
 	}
-	d.Deaths += day.Deaths
-	d.Confirmed += day.Confirmed
-	d.Recovered += day.Recovered
-	d.Tested += day.Tested
+	if day.series != nil {
+		for _, kind := range day.series.kinds() {
+			d.MergeData(kind, day.Value(kind))
+		}
+	}
+	for _, kind := range d.dataKinds() {
+		d.SetSource(kind, SourceCalculated)
+	}
+	d.Provisional = d.Provisional || day.Provisional
+	d.Interpolated = d.Interpolated || day.Interpolated
 
 	return nil
 }
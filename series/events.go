@@ -0,0 +1,130 @@
+package series
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Types of intervention event
+const (
+	EventLockdown  = "lockdown"
+	EventMasks     = "masks"
+	EventReopening = "reopening"
+	EventSchools   = "schools"
+)
+
+// Event records an intervention in one area, like a lockdown or school closure
+type Event struct {
+	// Type is one of the event types above
+	Type string `json:"type"`
+
+	// UTC dates the event started and ended - end is zero if ongoing or unknown
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+
+	// A short description for display
+	Description string `json:"description"`
+}
+
+// HasEnd returns true if the event has an end date
+func (e Event) HasEnd() bool {
+	return !e.End.IsZero()
+}
+
+// validEventType returns true if t is a known event type
+func validEventType(t string) bool {
+	switch t {
+	case EventLockdown, EventMasks, EventReopening, EventSchools:
+		return true
+	}
+	return false
+}
+
+// LockdownAt returns the start of the first lockdown, or zero time if none
+func (d *Data) LockdownAt() time.Time {
+	for _, e := range d.Events {
+		if e.Type == EventLockdown {
+			return e.Start
+		}
+	}
+	return time.Time{}
+}
+
+// HasLockdownAt returns true if we have a lockdown date
+func (d *Data) HasLockdownAt() bool {
+	return !d.LockdownAt().IsZero()
+}
+
+// LoadEvents loads events into the default store, see Store.LoadEvents
+func LoadEvents(p string) error {
+	return DefaultStore().LoadEvents(p)
+}
+
+// LoadEvents loads events from the specified events file and adds them to areas by id
+// a missing file is not an error as events are optional
+// dataset must be locked while performing this operation
+func (st *Store) LoadEvents(p string) error {
+	return st.dataset.loadEvents(p)
+}
+
+// loadEvents loads events from the specified events file and adds them to areas in the slice
+func (slice Slice) loadEvents(p string) error {
+	header := func(row []string) error {
+		if len(row) < 5 || row[0] != "area_id" || row[1] != "type" || row[2] != "start" {
+			return fmt.Errorf("events: invalid header row in file:%s row:%s", p, row)
+		}
+		return nil
+	}
+
+	_, err := streamCSV(p, header, func(row []string) error {
+		if len(row) < 5 {
+			return fmt.Errorf("events: invalid row len for row:%s", row)
+		}
+		areaID, err := strconv.Atoi(row[0])
+		if err != nil {
+			return fmt.Errorf("events: invalid area id at row:%s", row)
+		}
+		s, err := slice.FindSeries(areaID)
+		if err != nil {
+			return fmt.Errorf("events: unknown area at row:%s", row)
+		}
+
+		event, err := readEventRow(row)
+		if err != nil {
+			return err
+		}
+		s.Events = append(s.Events, event)
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// readEventRow reads an event from the row type,start,end,description after the area id
+func readEventRow(row []string) (Event, error) {
+	e := Event{
+		Type:        row[1],
+		Description: row[4],
+	}
+	if !validEventType(e.Type) {
+		return e, fmt.Errorf("events: invalid type at row:%s", row)
+	}
+
+	var err error
+	e.Start, err = time.Parse("2006-01-02", row[2])
+	if err != nil {
+		return e, fmt.Errorf("events: invalid start at row:%s", row)
+	}
+	if row[3] != "" {
+		e.End, err = time.Parse("2006-01-02", row[3])
+		if err != nil || e.End.Before(e.Start) {
+			return e, fmt.Errorf("events: invalid end at row:%s", row)
+		}
+	}
+
+	return e, nil
+}
@@ -0,0 +1,91 @@
+package series
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+)
+
+// isoWeek identifies an ISO 8601 week, as used by weekly mortality sources
+type isoWeek struct {
+	year int
+	week int
+}
+
+// LoadExcess loads mortality into the default store, see Store.LoadExcess
+func LoadExcess(p string) error {
+	return DefaultStore().LoadExcess(p)
+}
+
+// LoadExcess loads weekly all-cause mortality from the specified file and sets excess deaths on each day
+// rows are area_id,year,week,deaths,baseline in ISO weeks, as published by sources like EuroMOMO and HMD
+// the excess for each week is spread evenly over its days and stored cumulatively from the start of the series
+// a missing file is not an error as excess mortality is only available for some areas
+// dataset must be locked while performing this operation
+func (st *Store) LoadExcess(p string) error {
+	return st.dataset.loadExcess(p, all)
+}
+
+// loadExcess loads weekly mortality from the specified file for the series in the slice for which include returns true
+func (slice Slice) loadExcess(p string, include func(*Data) bool) error {
+	header := func(row []string) error {
+		if len(row) < 5 || row[0] != "area_id" || row[1] != "year" || row[2] != "week" || row[3] != "deaths" || row[4] != "baseline" {
+			return fmt.Errorf("excess: invalid header row in file:%s row:%s", p, row)
+		}
+		return nil
+	}
+
+	weeks := make(map[int]map[isoWeek]int)
+	_, err := streamCSV(p, header, func(row []string) error {
+		if len(row) < 5 {
+			return fmt.Errorf("excess: invalid row len for row:%s", row)
+		}
+		var err error
+		values := make([]int, 5)
+		for j := range values {
+			values[j], err = strconv.Atoi(row[j])
+			if err != nil {
+				return fmt.Errorf("excess: invalid value at row:%s", row)
+			}
+		}
+		if values[2] < 1 || values[2] > 53 {
+			return fmt.Errorf("excess: invalid week at row:%s", row)
+		}
+
+		areaID := values[0]
+		if weeks[areaID] == nil {
+			weeks[areaID] = make(map[isoWeek]int)
+		}
+		weeks[areaID][isoWeek{year: values[1], week: values[2]}] = values[3] - values[4]
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	for areaID, excess := range weeks {
+		s, err := slice.FindSeries(areaID)
+		if err != nil {
+			return fmt.Errorf("excess: unknown area:%d", areaID)
+		}
+		if include(s) {
+			s.setExcess(excess)
+		}
+	}
+
+	return nil
+}
+
+// setExcess sets cumulative excess deaths on each day from weekly excess deaths
+// days in weeks without data add no excess
+func (d *Data) setExcess(weeks map[isoWeek]int) {
+	var total float64
+	for _, day := range d.Days {
+		year, week := day.Date.ISOWeek()
+		total += float64(weeks[isoWeek{year: year, week: week}]) / 7
+		day.SetData(DataExcess, int(math.Round(total)))
+	}
+}
@@ -0,0 +1,117 @@
+package series
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+)
+
+// LoadFlu loads flu seasons into the default store, see Store.LoadFlu
+func LoadFlu(p string) error {
+	return DefaultStore().LoadFlu(p)
+}
+
+// LoadFlu loads weekly flu deaths in past seasons from the specified file and sets the flu baseline on each day
+// rows are area_id,season,week,deaths in ISO weeks, with a row per week of each season published by flu surveillance
+// the baseline for a week is the mean of its deaths over the seasons, spread evenly over its days
+// and stored cumulatively from the start of the series, so it is compared with deaths like excess deaths
+// a missing file is not an error as flu data is only available for some areas
+// dataset must be locked while performing this operation
+func (st *Store) LoadFlu(p string) error {
+	return st.dataset.loadFlu(p, all)
+}
+
+// loadFlu loads flu seasons from the specified file for the series in the slice for which include returns true
+func (slice Slice) loadFlu(p string, include func(*Data) bool) error {
+	header := func(row []string) error {
+		if len(row) < 4 || row[0] != "area_id" || row[1] != "season" || row[2] != "week" || row[3] != "deaths" {
+			return fmt.Errorf("flu: invalid header row in file:%s row:%s", p, row)
+		}
+		return nil
+	}
+
+	// Deaths are totalled by week, with the seasons seen for each week to take the mean
+	type weekTotal struct {
+		deaths  int
+		seasons int
+	}
+	weeks := make(map[int]map[int]*weekTotal)
+	_, err := streamCSV(p, header, func(row []string) error {
+		if len(row) < 4 {
+			return fmt.Errorf("flu: invalid row len for row:%s", row)
+		}
+		var err error
+		values := make([]int, 4)
+		for j := range values {
+			values[j], err = strconv.Atoi(row[j])
+			if err != nil {
+				return fmt.Errorf("flu: invalid value at row:%s", row)
+			}
+		}
+		if values[2] < 1 || values[2] > 53 {
+			return fmt.Errorf("flu: invalid week at row:%s", row)
+		}
+
+		areaID := values[0]
+		if weeks[areaID] == nil {
+			weeks[areaID] = make(map[int]*weekTotal)
+		}
+		total := weeks[areaID][values[2]]
+		if total == nil {
+			total = &weekTotal{}
+			weeks[areaID][values[2]] = total
+		}
+		total.deaths += values[3]
+		total.seasons++
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	for areaID, totals := range weeks {
+		s, err := slice.FindSeries(areaID)
+		if err != nil {
+			return fmt.Errorf("flu: unknown area:%d", areaID)
+		}
+		if !include(s) {
+			continue
+		}
+		baseline := make(map[int]float64, len(totals))
+		for week, total := range totals {
+			baseline[week] = float64(total.deaths) / float64(total.seasons)
+		}
+		s.setFlu(baseline)
+	}
+
+	return nil
+}
+
+// setFlu sets the cumulative flu baseline on each day from the mean deaths of each ISO week
+// week 53 is only in some seasons, so uses the baseline of week 52 if missing, other weeks without data add nothing
+func (d *Data) setFlu(weeks map[int]float64) {
+	if _, ok := weeks[53]; !ok {
+		if v, ok := weeks[52]; ok {
+			weeks[53] = v
+		}
+	}
+	var total float64
+	for _, day := range d.Days {
+		_, week := day.Date.ISOWeek()
+		total += weeks[week] / 7
+		day.SetData(DataFlu, int(math.Round(total)))
+	}
+}
+
+// FluBaselineDeaths returns the flu baseline deaths for this area over the series
+// this is zero if no flu data is loaded for the area
+func (d *Data) FluBaselineDeaths() int {
+	first := d.PreviousDay
+	if first == nil {
+		first = &Day{}
+	}
+	return d.LastDay().Value(DataFlu) - first.Value(DataFlu)
+}
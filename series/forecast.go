@@ -0,0 +1,107 @@
+package series
+
+import (
+	"math"
+	"time"
+)
+
+// forecastFitDays is the number of recent complete days to which forecasts are fitted
+const forecastFitDays = 21
+
+// forecastMinPoints is the minimum number of non-zero days required to fit a forecast
+const forecastMinPoints = 7
+
+// forecastAverageDays is the number of days averaged before fitting, to remove weekly reporting cycles
+const forecastAverageDays = 7
+
+// forecastZ is the z score for the 95% prediction interval of forecasts
+const forecastZ = 1.96
+
+// Projection is one forecast day with the bounds of its prediction interval
+// values are cumulative totals
+type Projection struct {
+	Date  time.Time `json:"date"`
+	Value float64   `json:"value"`
+	Low   float64   `json:"low"`
+	High  float64   `json:"high"`
+}
+
+// Forecast projects the cumulative values of dataKind for days after the last complete day
+// an exponential curve is fitted by least squares to the log of the recent averaged daily values
+// so if daily values are falling the total levels off, as a logistic curve would
+// nil is returned if there is not enough recent data to fit the curve
+func (d *Data) Forecast(dataKind, days int) []Projection {
+	n := d.CompleteCount()
+	if days <= 0 || n <= forecastFitDays {
+		return nil
+	}
+
+	// Fit ln(daily) = a + b*x over the window, ignoring days with no values
+	// values are adjusted for the day of the week, so the fit is not skewed by the weekday the window ends on
+	averages := movingAverageFloats(d.AdjustedDaily(dataKind)[:n], forecastAverageDays)
+	var xs, ys []float64
+	for x, v := range averages[n-forecastFitDays:] {
+		if v > 0 {
+			xs = append(xs, float64(x))
+			ys = append(ys, math.Log(v))
+		}
+	}
+	m := float64(len(xs))
+	if len(xs) < forecastMinPoints {
+		return nil
+	}
+
+	var xMean, yMean float64
+	for i := range xs {
+		xMean += xs[i] / m
+		yMean += ys[i] / m
+	}
+	var sxx, sxy float64
+	for i := range xs {
+		sxx += (xs[i] - xMean) * (xs[i] - xMean)
+		sxy += (xs[i] - xMean) * (ys[i] - yMean)
+	}
+	b := sxy / sxx
+	a := yMean - b*xMean
+
+	// Residual standard error of the fit, used for the prediction interval
+	var sse float64
+	for i := range xs {
+		r := ys[i] - a - b*xs[i]
+		sse += r * r
+	}
+	se := math.Sqrt(sse / (m - 2))
+
+	total := float64(d.Days[n-1].Value(dataKind))
+	low, high := total, total
+	last := d.Days[n-1].Date
+	projections := make([]Projection, days)
+	for h := range projections {
+		x := float64(forecastFitDays + h)
+		y := a + b*x
+		interval := forecastZ * se * math.Sqrt(1+1/m+(x-xMean)*(x-xMean)/sxx)
+		total += math.Exp(y)
+		low += math.Exp(y - interval)
+		high += math.Exp(y + interval)
+		projections[h] = Projection{
+			Date:  last.AddDate(0, 0, h+1),
+			Value: total,
+			Low:   low,
+			High:  high,
+		}
+	}
+	return projections
+}
+
+// ExponentialForecast is a ForecastModel using the values projected by Forecast
+func ExponentialForecast(d *Data, dataKind, days int) []float64 {
+	projections := d.Forecast(dataKind, days)
+	if projections == nil {
+		return nil
+	}
+	values := make([]float64, len(projections))
+	for i, p := range projections {
+		values[i] = p.Value
+	}
+	return values
+}
@@ -0,0 +1,107 @@
+package series
+
+import (
+	"sort"
+	"time"
+)
+
+// Reasons for gaps in the data
+const (
+	// GapMissing is used for days the source skipped, which were interpolated or have no value
+	GapMissing = "missing"
+
+	// GapFrozen is used for days on which the cumulative value did not change
+	GapFrozen = "frozen"
+)
+
+// Gap is a run of days on which one data kind of an area is missing or frozen
+type Gap struct {
+	AreaID   int    `json:"area_id"`
+	Country  string `json:"country"`
+	Province string `json:"province"`
+	Kind     string `json:"kind"`
+	Reason   string `json:"reason"`
+
+	// From and To are the first and last days of the gap
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+	Days int       `json:"days"`
+}
+
+// Gaps returns the runs of days on which each data kind is missing or frozen, in date order for each kind
+// a value is missing if the source skipped the day, so it was interpolated or has no value after values were reported
+// a value is frozen if it is unchanged from the day before for at least frozenDays days in a row
+// areas with few cases can have no new values for a while, so frozen gaps are a prompt to check the source, not errors
+func (d *Data) Gaps(frozenDays int) []Gap {
+	var gaps []Gap
+	for _, kind := range d.settings().kinds {
+		reported := false
+		start, reason := 0, ""
+
+		// Record the run of days before i if it is a gap
+		flush := func(i int) {
+			if reason == GapMissing || (reason == GapFrozen && i-start >= frozenDays) {
+				gaps = append(gaps, Gap{
+					AreaID:   d.ID,
+					Country:  d.Country,
+					Province: d.Province,
+					Kind:     DataKindName(kind),
+					Reason:   reason,
+					From:     d.Days[start].Date,
+					To:       d.Days[i-1].Date,
+					Days:     i - start,
+				})
+			}
+		}
+
+		for i, day := range d.Days {
+			v := day.Value(kind)
+			state := ""
+			if day.Interpolated || (reported && v == 0) {
+				state = GapMissing
+			} else if i > 0 && v > 0 && v == d.Days[i-1].Value(kind) && !d.Days[i-1].Interpolated {
+				state = GapFrozen
+			}
+			reported = reported || v > 0
+
+			if state != reason {
+				flush(i)
+				start, reason = i, state
+			}
+		}
+		flush(len(d.Days))
+	}
+	return gaps
+}
+
+// Gaps returns the gaps found in each series, see Data.Gaps, the most recent first then ordered by area id
+// pending series are not checked as their days are not loaded
+func (slice Slice) Gaps(frozenDays int) []Gap {
+	gaps := []Gap{}
+	for _, s := range slice {
+		if s.pending {
+			continue
+		}
+		gaps = append(gaps, s.Gaps(frozenDays)...)
+	}
+
+	sort.SliceStable(gaps, func(i, j int) bool {
+		if gaps[i].To.Equal(gaps[j].To) {
+			return gaps[i].AreaID < gaps[j].AreaID
+		}
+		return gaps[i].To.After(gaps[j].To)
+	})
+	return gaps
+}
+
+// Gaps returns the gaps found in the default store, see Store.Gaps
+func Gaps(frozenDays int) []Gap {
+	return DefaultStore().Gaps(frozenDays)
+}
+
+// Gaps returns the gaps found in the dataset, see Data.Gaps
+func (st *Store) Gaps(frozenDays int) []Gap {
+	st.mutex.RLock()
+	defer st.mutex.RUnlock()
+	return st.dataset.Gaps(frozenDays)
+}
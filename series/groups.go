@@ -0,0 +1,48 @@
+package series
+
+// Names of the region groups used by pages
+const (
+	// GroupEurope is the group of countries treated as European
+	GroupEurope = "europe"
+
+	// GroupEuropeComparisons are compared with European countries
+	GroupEuropeComparisons = "europe_comparisons"
+
+	// GroupComparisons are compared with countries outside Europe
+	GroupComparisons = "comparisons"
+)
+
+// defaultGroups are the region groups used unless others are set in the config of a store
+var defaultGroups = map[string][]string{
+	GroupEurope:            {"United Kingdom", "France", "Italy", "Belgium", "Spain", "Germany", "Netherlands", "Switzerland", "Sweden", "Portugal"},
+	GroupEuropeComparisons: {"Italy", "Spain", "France", "Switzerland", "Germany", "United Kingdom", "Sweden", "Netherlands"},
+	GroupComparisons:       {"Italy", "US", "Japan", "China", "Germany", "United Kingdom"},
+}
+
+// groupSets returns the groups given as sets of country names, groups not given keep their defaults
+func groupSets(names map[string][]string) map[string]map[string]bool {
+	all := make(map[string][]string, len(defaultGroups)+len(names))
+	for name, countries := range defaultGroups {
+		all[name] = countries
+	}
+	for name, countries := range names {
+		all[name] = countries
+	}
+
+	sets := make(map[string]map[string]bool, len(all))
+	for name, countries := range all {
+		sets[name] = make(map[string]bool, len(countries))
+		for _, country := range countries {
+			sets[name][country] = true
+		}
+	}
+	return sets
+}
+
+// InGroup returns true if this series is a country in the region group given
+func (d *Data) InGroup(name string) bool {
+	if d.Province != "" {
+		return false
+	}
+	return d.settings().groups[name][d.Country]
+}
@@ -3,6 +3,7 @@ package series
 import (
 	"encoding/csv"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"path/filepath"
@@ -10,6 +11,62 @@ import (
 	"time"
 )
 
+// MovingAverage returns the trailing average of values over n days
+// days before n values are available are averaged over the values so far
+func MovingAverage(values []int, n int) []float64 {
+	averages := make([]float64, len(values))
+	sum := 0
+	for i, v := range values {
+		sum += v
+		count := i + 1
+		if i >= n {
+			sum -= values[i-n]
+			count = n
+		}
+		averages[i] = float64(sum) / float64(count)
+	}
+	return averages
+}
+
+// MovingAverageExcluding returns the trailing average of values over n days as MovingAverage
+// leaving out values for which exclude is true, windows with every value excluded have the average before them
+func MovingAverageExcluding(values []int, exclude []bool, n int) []float64 {
+	averages := make([]float64, len(values))
+	sum, count := 0, 0
+	for i, v := range values {
+		if !exclude[i] {
+			sum += v
+			count++
+		}
+		if i >= n && !exclude[i-n] {
+			sum -= values[i-n]
+			count--
+		}
+		if count > 0 {
+			averages[i] = float64(sum) / float64(count)
+		} else if i > 0 {
+			averages[i] = averages[i-1]
+		}
+	}
+	return averages
+}
+
+// movingAverageFloats returns the trailing average of values over n days, as MovingAverage
+func movingAverageFloats(values []float64, n int) []float64 {
+	averages := make([]float64, len(values))
+	sum := 0.0
+	for i, v := range values {
+		sum += v
+		count := i + 1
+		if i >= n {
+			sum -= values[i-n]
+			count = n
+		}
+		averages[i] = sum / float64(count)
+	}
+	return averages
+}
+
 // loadCSV loads the given CSV file into memory
 func loadCSV(p string) ([][]string, error) {
 	p = filepath.Clean(p)
@@ -20,6 +77,7 @@ func loadCSV(p string) ([][]string, error) {
 	if err != nil {
 		return nil, err
 	}
+	defer f.Close()
 	r := csv.NewReader(f)
 	rows, err := r.ReadAll()
 	if err != nil {
@@ -28,6 +86,65 @@ func loadCSV(p string) ([][]string, error) {
 	return rows, nil
 }
 
+// maxRowErrors is the number of row errors logged for each file streamed, later errors are only counted
+const maxRowErrors = 10
+
+// streamCSV reads the given CSV file one row at a time, so that large files are not held in memory
+// the header row is passed to header, and an error from header aborts the load
+// each later row is passed to fn, rows which fail to parse or for which fn returns an error
+// are logged and skipped so that one bad row does not abort the load
+// the number of rows skipped is returned
+func streamCSV(p string, header func(row []string) error, fn func(row []string) error) (int, error) {
+	p = filepath.Clean(p)
+	log.Printf("data: streaming file at path:%v", p)
+
+	f, err := os.Open(p)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	r.ReuseRecord = true
+
+	row, err := r.Read()
+	if err == io.EOF {
+		return 0, fmt.Errorf("data: empty file:%s", p)
+	} else if err != nil {
+		return 0, err
+	}
+	err = header(row)
+	if err != nil {
+		return 0, err
+	}
+
+	skipped := 0
+	for i := 1; ; i++ {
+		row, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		// Parse errors are limited to one row, other errors end the read
+		if _, ok := err.(*csv.ParseError); !ok && err != nil {
+			return skipped, err
+		}
+		if err == nil {
+			err = fn(row)
+		}
+		if err != nil {
+			skipped++
+			if skipped <= maxRowErrors {
+				log.Printf("data: skipped row:%d in file:%s error:%s", i, p, err)
+			}
+		}
+	}
+
+	if skipped > maxRowErrors {
+		log.Printf("data: skipped %d rows in file:%s", skipped, p)
+	}
+	return skipped, nil
+}
+
 func readStateRow(row []string) (time.Time, int, int, error) {
 
 	// Dates are, remarkably, in two different formats in one file
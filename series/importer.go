@@ -0,0 +1,238 @@
+package series
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// importFields are the fields which may be mapped to columns of an imported file, with the metrics of the dataset
+var importFields = map[string]bool{
+	"date":     true,
+	"area_id":  true,
+	"code":     true,
+	"country":  true,
+	"province": true,
+}
+
+// ImportMapping maps our fields to the names of columns in a file to import
+// areas are identified by area_id, code, or country with an optional province
+// if Daily is true values are new per day rather than cumulative, as some sources publish
+type ImportMapping struct {
+	Columns    map[string]string
+	DateFormat string
+	Daily      bool
+}
+
+// ParseImportMapping parses a mapping for the dataset of the default store, see Store.ParseImportMapping
+func ParseImportMapping(spec, dateFormat string) (ImportMapping, error) {
+	return DefaultStore().ParseImportMapping(spec, dateFormat)
+}
+
+// ParseImportMapping parses a mapping in the form field=column,field=column
+// for example date=Date,country=Region,deaths=Total Deaths
+// a date, an area and at least one metric of the dataset of the store must be mapped
+func (st *Store) ParseImportMapping(spec, dateFormat string) (ImportMapping, error) {
+	m := ImportMapping{Columns: make(map[string]string), DateFormat: dateFormat}
+	if m.DateFormat == "" {
+		m.DateFormat = "2006-01-02"
+	}
+
+	for _, pair := range strings.Split(spec, ",") {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || parts[1] == "" {
+			return m, fmt.Errorf("import: invalid mapping:%s", pair)
+		}
+		field := strings.TrimSpace(parts[0])
+		if !importFields[field] && st.config.Dataset.Kind(field) == DataNone {
+			return m, fmt.Errorf("import: unknown field:%s", field)
+		}
+		m.Columns[field] = strings.TrimSpace(parts[1])
+	}
+
+	if m.Columns["date"] == "" {
+		return m, fmt.Errorf("import: no date column mapped")
+	}
+	if m.Columns["area_id"] == "" && m.Columns["code"] == "" && m.Columns["country"] == "" {
+		return m, fmt.Errorf("import: no area column mapped, map area_id, code or country")
+	}
+	if len(m.dataKinds(st.kinds)) == 0 {
+		return m, fmt.Errorf("import: no data columns mapped")
+	}
+	return m, nil
+}
+
+// dataKinds returns the data kinds of those given which are mapped to columns, in the order given
+func (m ImportMapping) dataKinds(dataKinds []int) (kinds []int) {
+	for _, kind := range dataKinds {
+		if m.Columns[DataKindName(kind)] != "" {
+			kinds = append(kinds, kind)
+		}
+	}
+	return kinds
+}
+
+// ImportSummary reports the result of importing one file
+type ImportSummary struct {
+	Path     string
+	Rows     int
+	Imported int
+	Skipped  int
+	Changed  int
+	Areas    int
+	Errors   []string
+}
+
+// String returns a one line summary of the import
+func (s ImportSummary) String() string {
+	return fmt.Sprintf("%s rows:%d imported:%d skipped:%d values changed:%d areas:%d", s.Path, s.Rows, s.Imported, s.Skipped, s.Changed, s.Areas)
+}
+
+// ImportCSV imports values from the csv file at p into the default store, see Store.ImportCSV
+func ImportCSV(p string, m ImportMapping) (ImportSummary, error) {
+	return DefaultStore().ImportCSV(p, m)
+}
+
+// ImportCSV imports cumulative values from the csv file at p into the dataset using the mapping given
+// rows which fail validation are skipped and the first errors recorded in the summary
+// imported values replace existing values, and are recorded with the source import
+func (st *Store) ImportCSV(p string, m ImportMapping) (ImportSummary, error) {
+	var summary ImportSummary
+	err := st.Update("import:"+filepath.Base(p), func(next Slice) error {
+		var err error
+		summary, err = next.ImportCSV(p, m)
+		return err
+	})
+	return summary, err
+}
+
+// ImportCSV imports cumulative values from the csv file at p into the series in the slice
+// this should be called on a copy of the dataset within Update
+func (slice Slice) ImportCSV(p string, m ImportMapping) (ImportSummary, error) {
+	summary := ImportSummary{Path: p}
+
+	// Pending series must be loaded first or imported values would be lost on load
+	err := slice.loadPending(all)
+	if err != nil {
+		return summary, err
+	}
+
+	columns := make(map[string]int)
+	header := func(row []string) error {
+		for field, name := range m.Columns {
+			columns[field] = -1
+			for i, col := range row {
+				if strings.TrimSpace(col) == name {
+					columns[field] = i
+					break
+				}
+			}
+			if columns[field] < 0 {
+				return fmt.Errorf("import: column:%s for field:%s not found in file:%s", name, field, p)
+			}
+		}
+		return nil
+	}
+
+	areas := make(map[int]bool)
+	now := time.Now().UTC()
+	summary.Skipped, err = streamCSV(p, header, func(row []string) error {
+		err := slice.importRow(row, columns, m, areas, now, &summary)
+		if err != nil {
+			if len(summary.Errors) < maxRowErrors {
+				summary.Errors = append(summary.Errors, fmt.Sprintf("%s row:%s", err, row))
+			}
+			return err
+		}
+		summary.Imported++
+		return nil
+	})
+	summary.Rows = summary.Imported + summary.Skipped
+	summary.Areas = len(areas)
+	return summary, err
+}
+
+// importRow validates one row and sets its values on the day of the matching series in the slice
+func (slice Slice) importRow(row []string, columns map[string]int, m ImportMapping, areas map[int]bool, now time.Time, summary *ImportSummary) error {
+	value := func(field string) string {
+		i, ok := columns[field]
+		if !ok || i >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[i])
+	}
+
+	s, err := slice.importSeries(value("area_id"), value("code"), value("country"), value("province"))
+	if err != nil {
+		return err
+	}
+
+	date, err := time.Parse(m.DateFormat, value("date"))
+	if err != nil {
+		return fmt.Errorf("import: invalid date:%s", value("date"))
+	}
+	date = time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+	if len(s.Days) == 0 {
+		return fmt.Errorf("import: no days for area:%s", s)
+	}
+	i := s.dayIndex(date)
+	if i < 0 {
+		return fmt.Errorf("import: date outside series:%s", date.Format("2006-01-02"))
+	}
+
+	// Validate every value before any are set, so that rows are imported completely or not at all
+	kinds := m.dataKinds(slice.settings().kinds)
+	values := make([]int, len(kinds))
+	for j, kind := range kinds {
+		v := value(DataKindName(kind))
+		values[j], err = strconv.Atoi(strings.Replace(v, ",", "", -1))
+		if err != nil || values[j] < 0 {
+			return fmt.Errorf("import: invalid %s:%s", DataKindName(kind), v)
+		}
+	}
+
+	// Daily values set the total from the day before, and move later totals by the same amount
+	// so rows may be in any order, and rows for earlier days revise the totals after them
+	day := s.Days[i]
+	for j, kind := range kinds {
+		if m.Daily {
+			before := day.Value(kind)
+			s.setDailyValue(i, kind, values[j])
+			if day.Value(kind) != before {
+				summary.Changed++
+			}
+		} else if day.Value(kind) != values[j] {
+			day.SetData(kind, values[j])
+			summary.Changed++
+		}
+		day.SetSource(kind, SourceImport)
+	}
+	s.SetUpdated(now)
+	areas[s.ID] = true
+	return nil
+}
+
+// importSeries returns the series in the slice for an imported row, by area id, then ISO code, then country and province
+func (slice Slice) importSeries(areaID, code, country, province string) (*Data, error) {
+	if areaID != "" {
+		id, err := strconv.Atoi(areaID)
+		if err != nil {
+			return nil, fmt.Errorf("import: invalid area id:%s", areaID)
+		}
+		s, err := slice.FindSeries(id)
+		if err != nil {
+			return nil, fmt.Errorf("import: unknown area id:%d", id)
+		}
+		return s, nil
+	}
+	if code != "" {
+		return slice.FetchCode(code)
+	}
+	s, err := slice.FetchSeries(country, province)
+	if err != nil || country == "" {
+		return nil, fmt.Errorf("import: unknown area:%s,%s", country, province)
+	}
+	return s, nil
+}
@@ -0,0 +1,86 @@
+package series
+
+import (
+	"time"
+)
+
+// Series is implemented by the series of an area and by aggregates of several areas
+// consumers should use it rather than the days of Data, so they work with either
+type Series interface {
+	// Totals returns the cumulative values of dataKind for each day
+	Totals(dataKind int) []int
+
+	// Daily returns the values of dataKind per day, the change from the day before
+	// the slice may be shared with the series and must not be modified
+	Daily(dataKind int) []int
+
+	// Range returns the dates of the first and last days, zero times if there are no days
+	Range() (from, to time.Time)
+
+	// Metadata returns the name and details of the area or areas in the series
+	Metadata() Metadata
+
+	// ForEachDay calls fn with each day in date order until fn returns false
+	// days are shared with the series and must not be modified
+	ForEachDay(fn func(*Day) bool)
+}
+
+// Metadata describes the area or areas of a series
+type Metadata struct {
+	// ID is the area id, zero for aggregates
+	ID int
+
+	// Name is the display title of the series
+	Name string
+
+	// Country and Province are blank for aggregates
+	Country  string
+	Province string
+
+	// Population and AreaKm2 are totals for aggregates, zero if not known
+	Population int
+	AreaKm2    float64
+
+	Color string
+
+	// UpdatedAt is the latest update of the areas in the series
+	UpdatedAt time.Time
+}
+
+// Totals returns the cumulative values of dataKind for each day
+func (d *Data) Totals(dataKind int) []int {
+	return d.Values(dataKind)
+}
+
+// Daily returns the values of dataKind per day
+func (d *Data) Daily(dataKind int) []int {
+	return d.DailyValues(dataKind)
+}
+
+// Range returns the dates of the first and last days, zero times if there are no days
+func (d *Data) Range() (from, to time.Time) {
+	return d.FirstDay().Date, d.LastDay().Date
+}
+
+// Metadata returns the name and details of this area
+func (d *Data) Metadata() Metadata {
+	return Metadata{
+		ID:         d.ID,
+		Name:       d.Title(),
+		Country:    d.Country,
+		Province:   d.Province,
+		Population: d.Population,
+		AreaKm2:    d.AreaKm2,
+		Color:      d.Color,
+		UpdatedAt:  d.UpdatedAt,
+	}
+}
+
+// ForEachDay calls fn with each day in date order until fn returns false
+func (d *Data) ForEachDay(fn func(*Day) bool) {
+	for _, day := range d.Days {
+		if !fn(day) {
+			return
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package series
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Interpolation is the method used to fill days skipped by sources, see Data.Interpolate
+type Interpolation int
+
+// Methods of interpolation, InterpolateNone leaves skipped days without values
+const (
+	InterpolateNone Interpolation = iota
+
+	// InterpolateCarry fills skipped days with the values of the day before
+	InterpolateCarry
+
+	// InterpolateLinear fills skipped days with values on a straight line between the days either side
+	InterpolateLinear
+)
+
+// interpolationNames are the names of each interpolation for config, indexed by Interpolation
+var interpolationNames = []string{"none", "carry", "linear"}
+
+// String returns the name of this interpolation
+func (m Interpolation) String() string {
+	if m < 0 || int(m) >= len(interpolationNames) {
+		return ""
+	}
+	return interpolationNames[m]
+}
+
+// ParseInterpolation returns the interpolation for a name returned by String, a blank name is none
+func ParseInterpolation(name string) (Interpolation, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return InterpolateNone, nil
+	}
+	for i, n := range interpolationNames {
+		if n == name {
+			return Interpolation(i), nil
+		}
+	}
+	return InterpolateNone, fmt.Errorf("series: unknown interpolation:%s", name)
+}
+
+// Interpolate fills days skipped by the source using method, marks them as interpolated and returns the number filled
+// a skipped day has no values between days with values, as the series file has no row for it
+// days without values at the end of the series are left, as the source may have stopped reporting the area
+// the days remain one per date, so offsets from the start date and day counts are unchanged
+func (d *Data) Interpolate(method Interpolation) int {
+	if method == InterpolateNone {
+		return 0
+	}
+
+	filled := 0
+	for i := 1; i < len(d.Days); i++ {
+		if !d.Days[i].IsZero() || d.Days[i-1].IsZero() {
+			continue
+		}
+
+		// Find the next day with values, the gap runs from i up to it
+		end := i
+		for end < len(d.Days) && d.Days[end].IsZero() {
+			end++
+		}
+		if end == len(d.Days) {
+			break
+		}
+
+		before, after := d.Days[i-1], d.Days[end]
+		for j := i; j < end; j++ {
+			day := d.Days[j]
+			for _, kind := range d.settings().kinds {
+				v := before.Value(kind)
+				if method == InterpolateLinear {
+					v += (after.Value(kind) - v) * (j - i + 1) / (end - i + 1)
+				}
+				day.SetData(kind, v)
+			}
+			day.Interpolated = true
+		}
+		filled += end - i
+		i = end
+	}
+	return filled
+}
+
+// Interpolate fills days skipped by sources in every series, and returns the number of days filled
+func (slice Slice) Interpolate(method Interpolation) int {
+	filled := 0
+	for _, s := range slice {
+		filled += s.Interpolate(method)
+	}
+	return filled
+}
@@ -0,0 +1,591 @@
+package series
+
+import (
+	"fmt"
+	"strings"
+)
+
+// countryCodes maps the country names used in our data to ISO 3166-1 alpha-3 codes
+// Kosovo has no official code so uses the user-assigned XKX, as used by the EU
+var countryCodes = map[string]string{
+	"Afghanistan":                      "AFG",
+	"Albania":                          "ALB",
+	"Algeria":                          "DZA",
+	"Andorra":                          "AND",
+	"Angola":                           "AGO",
+	"Antigua and Barbuda":              "ATG",
+	"Argentina":                        "ARG",
+	"Armenia":                          "ARM",
+	"Australia":                        "AUS",
+	"Austria":                          "AUT",
+	"Azerbaijan":                       "AZE",
+	"Bahamas":                          "BHS",
+	"Bahrain":                          "BHR",
+	"Bangladesh":                       "BGD",
+	"Barbados":                         "BRB",
+	"Belarus":                          "BLR",
+	"Belgium":                          "BEL",
+	"Belize":                           "BLZ",
+	"Benin":                            "BEN",
+	"Bhutan":                           "BTN",
+	"Bolivia":                          "BOL",
+	"Bosnia and Herzegovina":           "BIH",
+	"Botswana":                         "BWA",
+	"Brazil":                           "BRA",
+	"Brunei":                           "BRN",
+	"Bulgaria":                         "BGR",
+	"Burkina Faso":                     "BFA",
+	"Burundi":                          "BDI",
+	"Cabo Verde":                       "CPV",
+	"Cambodia":                         "KHM",
+	"Cameroon":                         "CMR",
+	"Canada":                           "CAN",
+	"Central African Republic":         "CAF",
+	"Chad":                             "TCD",
+	"Chile":                            "CHL",
+	"China":                            "CHN",
+	"Colombia":                         "COL",
+	"Congo (Brazzaville)":              "COG",
+	"Congo (Kinshasa)":                 "COD",
+	"Costa Rica":                       "CRI",
+	"Cote d'Ivoire":                    "CIV",
+	"Croatia":                          "HRV",
+	"Cuba":                             "CUB",
+	"Cyprus":                           "CYP",
+	"Czechia":                          "CZE",
+	"Denmark":                          "DNK",
+	"Djibouti":                         "DJI",
+	"Dominica":                         "DMA",
+	"Dominican Republic":               "DOM",
+	"Ecuador":                          "ECU",
+	"Egypt":                            "EGY",
+	"El Salvador":                      "SLV",
+	"Equatorial Guinea":                "GNQ",
+	"Eritrea":                          "ERI",
+	"Estonia":                          "EST",
+	"Eswatini":                         "SWZ",
+	"Ethiopia":                         "ETH",
+	"Fiji":                             "FJI",
+	"Finland":                          "FIN",
+	"France":                           "FRA",
+	"Gabon":                            "GAB",
+	"Gambia":                           "GMB",
+	"Georgia":                          "GEO",
+	"Germany":                          "DEU",
+	"Ghana":                            "GHA",
+	"Greece":                           "GRC",
+	"Grenada":                          "GRD",
+	"Guatemala":                        "GTM",
+	"Guinea":                           "GIN",
+	"Guinea-Bissau":                    "GNB",
+	"Guyana":                           "GUY",
+	"Haiti":                            "HTI",
+	"Holy See":                         "VAT",
+	"Honduras":                         "HND",
+	"Hungary":                          "HUN",
+	"Iceland":                          "ISL",
+	"India":                            "IND",
+	"Indonesia":                        "IDN",
+	"Iran":                             "IRN",
+	"Iraq":                             "IRQ",
+	"Ireland":                          "IRL",
+	"Israel":                           "ISR",
+	"Italy":                            "ITA",
+	"Jamaica":                          "JAM",
+	"Japan":                            "JPN",
+	"Jordan":                           "JOR",
+	"Kazakhstan":                       "KAZ",
+	"Kenya":                            "KEN",
+	"Kosovo":                           "XKX",
+	"Kuwait":                           "KWT",
+	"Kyrgyzstan":                       "KGZ",
+	"Laos":                             "LAO",
+	"Latvia":                           "LVA",
+	"Lebanon":                          "LBN",
+	"Liberia":                          "LBR",
+	"Libya":                            "LBY",
+	"Liechtenstein":                    "LIE",
+	"Lithuania":                        "LTU",
+	"Luxembourg":                       "LUX",
+	"Madagascar":                       "MDG",
+	"Malawi":                           "MWI",
+	"Malaysia":                         "MYS",
+	"Maldives":                         "MDV",
+	"Mali":                             "MLI",
+	"Malta":                            "MLT",
+	"Mauritania":                       "MRT",
+	"Mauritius":                        "MUS",
+	"Mexico":                           "MEX",
+	"Moldova":                          "MDA",
+	"Monaco":                           "MCO",
+	"Mongolia":                         "MNG",
+	"Montenegro":                       "MNE",
+	"Morocco":                          "MAR",
+	"Mozambique":                       "MOZ",
+	"Myanmar":                          "MMR",
+	"Namibia":                          "NAM",
+	"Nepal":                            "NPL",
+	"Netherlands":                      "NLD",
+	"New Zealand":                      "NZL",
+	"Nicaragua":                        "NIC",
+	"Niger":                            "NER",
+	"Nigeria":                          "NGA",
+	"North Macedonia":                  "MKD",
+	"Norway":                           "NOR",
+	"Oman":                             "OMN",
+	"Pakistan":                         "PAK",
+	"Panama":                           "PAN",
+	"Papua New Guinea":                 "PNG",
+	"Paraguay":                         "PRY",
+	"Peru":                             "PER",
+	"Philippines":                      "PHL",
+	"Poland":                           "POL",
+	"Portugal":                         "PRT",
+	"Qatar":                            "QAT",
+	"Romania":                          "ROU",
+	"Russia":                           "RUS",
+	"Rwanda":                           "RWA",
+	"Saint Kitts and Nevis":            "KNA",
+	"Saint Lucia":                      "LCA",
+	"Saint Vincent and the Grenadines": "VCT",
+	"San Marino":                       "SMR",
+	"Sao Tome and Principe":            "STP",
+	"Saudi Arabia":                     "SAU",
+	"Senegal":                          "SEN",
+	"Serbia":                           "SRB",
+	"Seychelles":                       "SYC",
+	"Sierra Leone":                     "SLE",
+	"Singapore":                        "SGP",
+	"Slovakia":                         "SVK",
+	"Slovenia":                         "SVN",
+	"Somalia":                          "SOM",
+	"South Africa":                     "ZAF",
+	"South Korea":                      "KOR",
+	"South Sudan":                      "SSD",
+	"Spain":                            "ESP",
+	"Sri Lanka":                        "LKA",
+	"Sudan":                            "SDN",
+	"Suriname":                         "SUR",
+	"Sweden":                           "SWE",
+	"Switzerland":                      "CHE",
+	"Syria":                            "SYR",
+	"Taiwan":                           "TWN",
+	"Tanzania":                         "TZA",
+	"Thailand":                         "THA",
+	"Timor-Leste":                      "TLS",
+	"Togo":                             "TGO",
+	"Trinidad and Tobago":              "TTO",
+	"Tunisia":                          "TUN",
+	"Turkey":                           "TUR",
+	"Uganda":                           "UGA",
+	"Ukraine":                          "UKR",
+	"United Arab Emirates":             "ARE",
+	"United Kingdom":                   "GBR",
+	"Uruguay":                          "URY",
+	"US":                               "USA",
+	"Uzbekistan":                       "UZB",
+	"Venezuela":                        "VEN",
+	"Vietnam":                          "VNM",
+	"West Bank and Gaza":               "PSE",
+	"Western Sahara":                   "ESH",
+	"Yemen":                            "YEM",
+	"Zambia":                           "ZMB",
+	"Zimbabwe":                         "ZWE",
+}
+
+// alpha2Codes maps ISO 3166-1 alpha-3 codes to alpha-2 codes
+var alpha2Codes = map[string]string{
+	"AFG": "AF",
+	"ALB": "AL",
+	"DZA": "DZ",
+	"AND": "AD",
+	"AGO": "AO",
+	"ATG": "AG",
+	"ARG": "AR",
+	"ARM": "AM",
+	"AUS": "AU",
+	"AUT": "AT",
+	"AZE": "AZ",
+	"BHS": "BS",
+	"BHR": "BH",
+	"BGD": "BD",
+	"BRB": "BB",
+	"BLR": "BY",
+	"BEL": "BE",
+	"BLZ": "BZ",
+	"BEN": "BJ",
+	"BTN": "BT",
+	"BOL": "BO",
+	"BIH": "BA",
+	"BWA": "BW",
+	"BRA": "BR",
+	"BRN": "BN",
+	"BGR": "BG",
+	"BFA": "BF",
+	"BDI": "BI",
+	"CPV": "CV",
+	"KHM": "KH",
+	"CMR": "CM",
+	"CAN": "CA",
+	"CAF": "CF",
+	"TCD": "TD",
+	"CHL": "CL",
+	"CHN": "CN",
+	"COL": "CO",
+	"COG": "CG",
+	"COD": "CD",
+	"CRI": "CR",
+	"CIV": "CI",
+	"HRV": "HR",
+	"CUB": "CU",
+	"CYP": "CY",
+	"CZE": "CZ",
+	"DNK": "DK",
+	"DJI": "DJ",
+	"DMA": "DM",
+	"DOM": "DO",
+	"ECU": "EC",
+	"EGY": "EG",
+	"SLV": "SV",
+	"GNQ": "GQ",
+	"ERI": "ER",
+	"EST": "EE",
+	"SWZ": "SZ",
+	"ETH": "ET",
+	"FJI": "FJ",
+	"FIN": "FI",
+	"FRA": "FR",
+	"GAB": "GA",
+	"GMB": "GM",
+	"GEO": "GE",
+	"DEU": "DE",
+	"GHA": "GH",
+	"GRC": "GR",
+	"GRD": "GD",
+	"GTM": "GT",
+	"GIN": "GN",
+	"GNB": "GW",
+	"GUY": "GY",
+	"HTI": "HT",
+	"VAT": "VA",
+	"HND": "HN",
+	"HUN": "HU",
+	"ISL": "IS",
+	"IND": "IN",
+	"IDN": "ID",
+	"IRN": "IR",
+	"IRQ": "IQ",
+	"IRL": "IE",
+	"ISR": "IL",
+	"ITA": "IT",
+	"JAM": "JM",
+	"JPN": "JP",
+	"JOR": "JO",
+	"KAZ": "KZ",
+	"KEN": "KE",
+	"XKX": "XK",
+	"KWT": "KW",
+	"KGZ": "KG",
+	"LAO": "LA",
+	"LVA": "LV",
+	"LBN": "LB",
+	"LBR": "LR",
+	"LBY": "LY",
+	"LIE": "LI",
+	"LTU": "LT",
+	"LUX": "LU",
+	"MDG": "MG",
+	"MWI": "MW",
+	"MYS": "MY",
+	"MDV": "MV",
+	"MLI": "ML",
+	"MLT": "MT",
+	"MRT": "MR",
+	"MUS": "MU",
+	"MEX": "MX",
+	"MDA": "MD",
+	"MCO": "MC",
+	"MNG": "MN",
+	"MNE": "ME",
+	"MAR": "MA",
+	"MOZ": "MZ",
+	"MMR": "MM",
+	"NAM": "NA",
+	"NPL": "NP",
+	"NLD": "NL",
+	"NZL": "NZ",
+	"NIC": "NI",
+	"NER": "NE",
+	"NGA": "NG",
+	"MKD": "MK",
+	"NOR": "NO",
+	"OMN": "OM",
+	"PAK": "PK",
+	"PAN": "PA",
+	"PNG": "PG",
+	"PRY": "PY",
+	"PER": "PE",
+	"PHL": "PH",
+	"POL": "PL",
+	"PRT": "PT",
+	"QAT": "QA",
+	"ROU": "RO",
+	"RUS": "RU",
+	"RWA": "RW",
+	"KNA": "KN",
+	"LCA": "LC",
+	"VCT": "VC",
+	"SMR": "SM",
+	"STP": "ST",
+	"SAU": "SA",
+	"SEN": "SN",
+	"SRB": "RS",
+	"SYC": "SC",
+	"SLE": "SL",
+	"SGP": "SG",
+	"SVK": "SK",
+	"SVN": "SI",
+	"SOM": "SO",
+	"ZAF": "ZA",
+	"KOR": "KR",
+	"SSD": "SS",
+	"ESP": "ES",
+	"LKA": "LK",
+	"SDN": "SD",
+	"SUR": "SR",
+	"SWE": "SE",
+	"CHE": "CH",
+	"SYR": "SY",
+	"TWN": "TW",
+	"TZA": "TZ",
+	"THA": "TH",
+	"TLS": "TL",
+	"TGO": "TG",
+	"TTO": "TT",
+	"TUN": "TN",
+	"TUR": "TR",
+	"UGA": "UG",
+	"UKR": "UA",
+	"ARE": "AE",
+	"GBR": "GB",
+	"URY": "UY",
+	"USA": "US",
+	"UZB": "UZ",
+	"VEN": "VE",
+	"VNM": "VN",
+	"PSE": "PS",
+	"ESH": "EH",
+	"YEM": "YE",
+	"ZMB": "ZM",
+	"ZWE": "ZW",
+}
+
+// subdivisionCodes maps the provinces of each country in our data to ISO 3166-2 subdivision codes
+// territories with their own ISO 3166-1 code, such as Gibraltar, use their alpha-2 code
+var subdivisionCodes = map[string]map[string]string{
+	"Australia": {
+		"Australian Capital Territory": "AU-ACT",
+		"New South Wales":              "AU-NSW",
+		"Northern Territory":           "AU-NT",
+		"Queensland":                   "AU-QLD",
+		"South Australia":              "AU-SA",
+		"Tasmania":                     "AU-TAS",
+		"Victoria":                     "AU-VIC",
+		"Western Australia":            "AU-WA",
+	},
+	"Canada": {
+		"Alberta":                   "CA-AB",
+		"British Columbia":          "CA-BC",
+		"Manitoba":                  "CA-MB",
+		"New Brunswick":             "CA-NB",
+		"Newfoundland and Labrador": "CA-NL",
+		"Northwest Territories":     "CA-NT",
+		"Nova Scotia":               "CA-NS",
+		"Ontario":                   "CA-ON",
+		"Prince Edward Island":      "CA-PE",
+		"Quebec":                    "CA-QC",
+		"Saskatchewan":              "CA-SK",
+		"Yukon":                     "CA-YT",
+	},
+	"China": {
+		"Anhui":          "CN-AH",
+		"Beijing":        "CN-BJ",
+		"Chongqing":      "CN-CQ",
+		"Fujian":         "CN-FJ",
+		"Gansu":          "CN-GS",
+		"Guangdong":      "CN-GD",
+		"Guangxi":        "CN-GX",
+		"Guizhou":        "CN-GZ",
+		"Hainan":         "CN-HI",
+		"Hebei":          "CN-HE",
+		"Heilongjiang":   "CN-HL",
+		"Henan":          "CN-HA",
+		"Hong Kong":      "CN-HK",
+		"Hubei":          "CN-HB",
+		"Hunan":          "CN-HN",
+		"Inner Mongolia": "CN-NM",
+		"Jiangsu":        "CN-JS",
+		"Jiangxi":        "CN-JX",
+		"Jilin":          "CN-JL",
+		"Liaoning":       "CN-LN",
+		"Macau":          "CN-MO",
+		"Ningxia":        "CN-NX",
+		"Qinghai":        "CN-QH",
+		"Shaanxi":        "CN-SN",
+		"Shandong":       "CN-SD",
+		"Shanghai":       "CN-SH",
+		"Shanxi":         "CN-SX",
+		"Sichuan":        "CN-SC",
+		"Tianjin":        "CN-TJ",
+		"Tibet":          "CN-XZ",
+		"Xinjiang":       "CN-XJ",
+		"Yunnan":         "CN-YN",
+		"Zhejiang":       "CN-ZJ",
+	},
+	"Denmark": {
+		"Faroe Islands": "FO",
+		"Greenland":     "GL",
+	},
+	"France": {
+		"French Guiana":             "GF",
+		"French Polynesia":          "PF",
+		"Guadeloupe":                "GP",
+		"Martinique":                "MQ",
+		"Mayotte":                   "YT",
+		"New Caledonia":             "NC",
+		"Reunion":                   "RE",
+		"Saint Barthelemy":          "BL",
+		"Saint Pierre and Miquelon": "PM",
+		"St Martin":                 "MF",
+	},
+	"Netherlands": {
+		"Aruba":                            "AW",
+		"Bonaire, Sint Eustatius and Saba": "BQ",
+		"Curacao":                          "CW",
+		"Sint Maarten":                     "SX",
+	},
+	"US": {
+		"Alabama":                  "US-AL",
+		"Alaska":                   "US-AK",
+		"American Samoa":           "US-AS",
+		"Arizona":                  "US-AZ",
+		"Arkansas":                 "US-AR",
+		"California":               "US-CA",
+		"Colorado":                 "US-CO",
+		"Connecticut":              "US-CT",
+		"Delaware":                 "US-DE",
+		"District of Columbia":     "US-DC",
+		"Florida":                  "US-FL",
+		"Georgia":                  "US-GA",
+		"Guam":                     "US-GU",
+		"Hawaii":                   "US-HI",
+		"Idaho":                    "US-ID",
+		"Illinois":                 "US-IL",
+		"Indiana":                  "US-IN",
+		"Iowa":                     "US-IA",
+		"Kansas":                   "US-KS",
+		"Kentucky":                 "US-KY",
+		"Louisiana":                "US-LA",
+		"Maine":                    "US-ME",
+		"Maryland":                 "US-MD",
+		"Massachusetts":            "US-MA",
+		"Michigan":                 "US-MI",
+		"Minnesota":                "US-MN",
+		"Mississippi":              "US-MS",
+		"Missouri":                 "US-MO",
+		"Montana":                  "US-MT",
+		"Nebraska":                 "US-NE",
+		"Nevada":                   "US-NV",
+		"New Hampshire":            "US-NH",
+		"New Jersey":               "US-NJ",
+		"New Mexico":               "US-NM",
+		"New York":                 "US-NY",
+		"North Carolina":           "US-NC",
+		"North Dakota":             "US-ND",
+		"Northern Mariana Islands": "US-MP",
+		"Ohio":                     "US-OH",
+		"Oklahoma":                 "US-OK",
+		"Oregon":                   "US-OR",
+		"Pennsylvania":             "US-PA",
+		"Puerto Rico":              "US-PR",
+		"Rhode Island":             "US-RI",
+		"South Carolina":           "US-SC",
+		"South Dakota":             "US-SD",
+		"Tennessee":                "US-TN",
+		"Texas":                    "US-TX",
+		"Utah":                     "US-UT",
+		"Vermont":                  "US-VT",
+		"Virgin Islands":           "US-VI",
+		"Virginia":                 "US-VA",
+		"Washington":               "US-WA",
+		"West Virginia":            "US-WV",
+		"Wisconsin":                "US-WI",
+		"Wyoming":                  "US-WY",
+	},
+	"United Kingdom": {
+		"Anguilla":                 "AI",
+		"Bermuda":                  "BM",
+		"Cayman Islands":           "KY",
+		"England":                  "GB-ENG",
+		"Falkland Islands":         "FK",
+		"Gibraltar":                "GI",
+		"Isle of Man":              "IM",
+		"Montserrat":               "MS",
+		"Northern Ireland":         "GB-NIR",
+		"Scotland":                 "GB-SCT",
+		"Turks and Caicos Islands": "TC",
+		"Virgin Islands":           "VG",
+		"Wales":                    "GB-WLS",
+	},
+}
+
+// CountryCode returns the ISO 3166-1 alpha-3 code for the country of this series
+// or an empty string if the country is unknown
+func (d *Data) CountryCode() string {
+	return countryCodes[d.Country]
+}
+
+// CountryAlpha2 returns the ISO 3166-1 alpha-2 code for the country of this series
+// or an empty string if the country is unknown
+func (d *Data) CountryAlpha2() string {
+	return alpha2Codes[d.CountryCode()]
+}
+
+// SubdivisionCode returns the ISO 3166-2 code for the province of this series
+// or an empty string if this is not a province or the province is unknown
+func (d *Data) SubdivisionCode() string {
+	return subdivisionCodes[d.Country][d.Province]
+}
+
+// FetchCode returns the series for an ISO alpha-2 or alpha-3 country code, or a subdivision code
+// codes are matched without case, and countries are preferred to territories with the same code
+func (slice Slice) FetchCode(code string) (*Data, error) {
+	code = strings.ToUpper(code)
+	if code != "" {
+		for _, s := range slice {
+			if !s.IsProvince() && !s.IsGlobal() && (s.CountryCode() == code || s.CountryAlpha2() == code) {
+				return s, nil
+			}
+		}
+		for _, s := range slice {
+			if s.IsProvince() && s.SubdivisionCode() == code {
+				return s, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("series: code not found:%s", code)
+}
+
+// FetchCode fetches a series by ISO code from the default store, see Store.FetchCode
+func FetchCode(code string) (*Data, error) {
+	return DefaultStore().FetchCode(code)
+}
+
+// FetchCode uses our stored dataset to fetch a series by ISO code
+// pending provinces are loaded first
+func (st *Store) FetchCode(code string) (*Data, error) {
+	st.mutex.RLock()
+	s, err := st.dataset.FetchCode(code)
+	st.mutex.RUnlock()
+	return st.loadSeries(s, err)
+}
@@ -0,0 +1,228 @@
+package series
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// dataFiles records the data directory a dataset was loaded from, it is shared by the series of one load
+// pending series are loaded from these files on first use
+type dataFiles struct {
+	// path is the data directory
+	path string
+
+	// last is the day number of the last day read from the series file
+	// days after it were added by AddToday, and rows for days before the first day in memory are skipped when loaded
+	last int
+
+	// interpolation is the method used to fill skipped days in pending series once loaded
+	interpolation Interpolation
+
+	// retentionDays is the number of days before today kept at daily resolution, older days are downsampled once loaded
+	// as days pass out of retention after startup too, the first day kept is found when each series is loaded
+	retentionDays int
+}
+
+// Pending returns true if the days of this series have not been loaded yet
+// series swapped in are never loaded in place, a copy with its days loaded replaces a pending series in the dataset
+func (d *Data) Pending() bool {
+	return d.pending
+}
+
+// LoadPending loads all pending series in the default store, see Store.LoadPending
+func LoadPending() error {
+	return DefaultStore().LoadPending()
+}
+
+// LoadPending loads all pending series, updates need not call this as they load the series they change
+func (st *Store) LoadPending() error {
+	return st.loadPending(all)
+}
+
+// loadPending swaps in a dataset with loaded copies of the pending series for which include returns true
+// the series of the current dataset are not changed, as callers may hold them without the dataset locked
+// copies are loaded without the dataset locked, so reads are not blocked while the data files are parsed
+// an update may swap in a dataset while they load, so copies only replace series still pending from the same files
+func (st *Store) loadPending(include func(*Data) bool) error {
+	st.mutex.RLock()
+	current := st.dataset
+	st.mutex.RUnlock()
+	loaded, err := current.withPending(include)
+	if err != nil {
+		return err
+	}
+	pending := make(map[int]bool)
+	for _, s := range current {
+		if s.pending && include(s) {
+			pending[s.ID] = true
+		}
+	}
+	copies := make(map[int]*Data)
+	for _, s := range loaded {
+		if pending[s.ID] && !s.pending {
+			copies[s.ID] = s
+		}
+	}
+	if len(copies) == 0 {
+		return nil
+	}
+
+	st.updateMutex.Lock()
+	defer st.updateMutex.Unlock()
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+	next := make(Slice, len(st.dataset))
+	swapped := false
+	for i, s := range st.dataset {
+		next[i] = s
+		if c := copies[s.ID]; c != nil && s.pending && s.files == c.files {
+			next[i] = c
+			swapped = true
+		}
+	}
+	if swapped {
+		sort.Stable(next)
+		st.dataset = next
+	}
+	return nil
+}
+
+// loadSeries returns s, or a loaded copy of s from the dataset if s is pending
+// the dataset must not be locked by the caller
+func (st *Store) loadSeries(s *Data, err error) (*Data, error) {
+	if err != nil || !s.pending {
+		return s, err
+	}
+	// A reload swapped in while the series loads leaves it pending from the new files, so it is loaded again
+	id := s.ID
+	for attempt := 0; attempt < 3 && err == nil && s.pending; attempt++ {
+		err = st.loadPending(func(d *Data) bool { return d.ID == id })
+		if err != nil {
+			break
+		}
+		st.mutex.RLock()
+		s, err = st.dataset.FindSeries(id)
+		st.mutex.RUnlock()
+	}
+	return s, err
+}
+
+// withPending returns the series in the slice with loaded copies in place of the pending series for which include returns true
+// the slice is returned unchanged if there are none, otherwise a new slice, so series in the slice stay pending
+// include is called with the copies as well as the series in the slice, so should not compare series by pointer
+func (slice Slice) withPending(include func(*Data) bool) (Slice, error) {
+	loaded := make(Slice, len(slice))
+	copied := false
+	for i, s := range slice {
+		loaded[i] = s
+		if s.pending && include(s) {
+			loaded[i] = s.clone()
+			copied = true
+		}
+	}
+	if !copied {
+		return slice, nil
+	}
+	err := loaded.loadPending(include)
+	if err != nil {
+		return nil, err
+	}
+	return loaded, nil
+}
+
+// all includes every series
+func all(d *Data) bool {
+	return true
+}
+
+// isLoaded returns true if the days of the series have been loaded
+func isLoaded(d *Data) bool {
+	return !d.pending
+}
+
+// loadPending loads the pending series for which include returns true from the data files they were loaded from
+// days are added to match the series loaded at startup, and the slice is sorted again
+// the series are changed in place, so the slice must not be the dataset of a store, see withPending
+func (slice Slice) loadPending(include func(*Data) bool) error {
+	var series []*Data
+	for _, s := range slice {
+		if s.pending && include(s) {
+			series = append(series, s)
+		}
+	}
+	if len(series) == 0 {
+		return nil
+	}
+	files := series[0].files
+	if files == nil {
+		return fmt.Errorf("series: no data files for pending series:%s", series[0])
+	}
+	target := func(d *Data) bool {
+		return d.pending && include(d)
+	}
+
+	_, _, err := slice.loadRows(filepath.Join(files.path, "series.csv"), target)
+	if err != nil {
+		return err
+	}
+
+	// Days may have been added to the dataset since startup, copy the last day forward as AddToday does
+	first, days := slice.firstDay(), 0
+	global, err := slice.FetchSeries("", "")
+	if err == nil {
+		days = len(global.Days)
+	}
+	retainFrom := daysAgo(files.retentionDays)
+	now := time.Now().UTC()
+	yesterday := time.Date(now.Year(), now.Month(), now.Day()-1, 0, 0, 0, 0, time.UTC)
+	for _, s := range series {
+		s.AddDays(files.last - first + 1 - len(s.Days))
+		for len(s.Days) > 0 && len(s.Days) < days {
+			s.AddToday()
+		}
+		s.Normalize()
+		s.Interpolate(files.interpolation)
+		s.Downsample(retainFrom)
+		s.SetProvisional(yesterday)
+	}
+
+	// Optional data files are applied to these series only
+	err = slice.loadExcess(filepath.Join(files.path, "mortality.csv"), target)
+	if err != nil {
+		return err
+	}
+	err = slice.loadFlu(filepath.Join(files.path, "flu.csv"), target)
+	if err != nil {
+		return err
+	}
+	err = slice.loadVaccinations(filepath.Join(files.path, "vaccinations.csv"), target)
+	if err != nil {
+		return err
+	}
+	err = slice.loadVariants(filepath.Join(files.path, "variants.csv"), target)
+	if err != nil {
+		return err
+	}
+	err = slice.loadSex(filepath.Join(files.path, "sex.csv"), target)
+	if err != nil {
+		return err
+	}
+	err = slice.loadNotes(filepath.Join(files.path, "notes.csv"), target)
+	if err != nil {
+		return err
+	}
+	if series[0].store != nil {
+		series[0].store.applyOverrides(slice, target)
+	}
+
+	for _, s := range series {
+		s.pending = false
+	}
+	sort.Stable(slice)
+
+	log.Printf("series: loaded %d pending series", len(series))
+	return nil
+}
@@ -0,0 +1,168 @@
+package series
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Locale holds the conventions used to display numbers in one language
+type Locale struct {
+	// Tag is the language tag, for example en or de-ch
+	Tag string
+
+	// Separators for thousands and decimals
+	Thousands string
+	Decimal   string
+
+	// Suffixes for abbreviated thousands, millions and billions
+	Thousand string
+	Million  string
+	Billion  string
+}
+
+// locales maps lower case language tags to number conventions
+// tags with a region are tried before the language alone
+var locales = map[string]Locale{
+	"en":    {Tag: "en", Thousands: ",", Decimal: ".", Thousand: "k", Million: "m", Billion: "b"},
+	"de":    {Tag: "de", Thousands: ".", Decimal: ",", Thousand: " Tsd.", Million: " Mio.", Billion: " Mrd."},
+	"de-ch": {Tag: "de-ch", Thousands: "’", Decimal: ".", Thousand: " Tsd.", Million: " Mio.", Billion: " Mrd."},
+	"fr":    {Tag: "fr", Thousands: " ", Decimal: ",", Thousand: " k", Million: " M", Billion: " Md"},
+	"es":    {Tag: "es", Thousands: ".", Decimal: ",", Thousand: " mil", Million: " M", Billion: " mil M"},
+	"es-mx": {Tag: "es-mx", Thousands: ",", Decimal: ".", Thousand: " mil", Million: " M", Billion: " mil M"},
+	"it":    {Tag: "it", Thousands: ".", Decimal: ",", Thousand: " mila", Million: " Mln", Billion: " Mld"},
+	"pt":    {Tag: "pt", Thousands: ".", Decimal: ",", Thousand: " mil", Million: " mi", Billion: " bi"},
+	"nl":    {Tag: "nl", Thousands: ".", Decimal: ",", Thousand: "k", Million: " mln", Billion: " mld"},
+	"sv":    {Tag: "sv", Thousands: " ", Decimal: ",", Thousand: " tn", Million: " mn", Billion: " md"},
+	"pl":    {Tag: "pl", Thousands: " ", Decimal: ",", Thousand: " tys.", Million: " mln", Billion: " mld"},
+	"ru":    {Tag: "ru", Thousands: " ", Decimal: ",", Thousand: " тыс.", Million: " млн", Billion: " млрд"},
+}
+
+// DefaultLocale is used when no locale is known for a language
+var DefaultLocale = locales["en"]
+
+// LocaleForLanguage returns the locale for a language tag like en-GB, falling back to the language
+// then to DefaultLocale if the language is unknown
+func LocaleForLanguage(tag string) Locale {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	for _, t := range []string{tag, strings.SplitN(tag, "-", 2)[0]} {
+		if l, ok := locales[t]; ok {
+			return l
+		}
+	}
+	return DefaultLocale
+}
+
+// Format formats a number for display, abbreviating numbers of 10,000 or more
+func (l Locale) Format(i int) string {
+	return Formatter{Locale: l}.Format(i)
+}
+
+// FormatFull formats a number in full with thousands separators
+func (l Locale) FormatFull(i int) string {
+	return Formatter{Locale: l, Full: true}.Format(i)
+}
+
+// FormatFloat formats a number with thousands separators and the given number of decimals
+func (l Locale) FormatFloat(v float64, decimals int) string {
+	return Formatter{Locale: l, Decimals: decimals}.FormatFloat(v)
+}
+
+// Formatter formats numbers for one view using a locale and display options
+type Formatter struct {
+	Locale Locale
+
+	// Full shows integers in full with thousands separators rather than abbreviated
+	Full bool
+
+	// Decimals is the number of decimals shown for floats
+	// and for abbreviated integers if not zero, otherwise they use a default precision
+	Decimals int
+
+	// Sign shows a plus sign on positive values, for daily changes
+	Sign bool
+}
+
+// Format formats an integer according to the formatter options
+// unless Full is set numbers of 10,000 or more are abbreviated
+func (f Formatter) Format(i int) string {
+	if f.Full {
+		return f.sign(float64(i), f.Locale.separate(fmt.Sprintf("%d", abs(i))))
+	}
+
+	l, v := f.Locale, math.Abs(float64(i))
+	var s string
+	switch {
+	case v < 10000:
+		s = fmt.Sprintf("%d", abs(i))
+	case v < 1000000:
+		s = l.decimal(f.abbreviate(v/1000, "%.1f")) + l.Thousand
+	case v < 1000000000:
+		s = l.decimal(f.abbreviate(v/1000000, "%.3g")) + l.Million
+	default:
+		s = l.decimal(f.abbreviate(v/1000000000, "%.2g")) + l.Billion
+	}
+	return f.sign(float64(i), s)
+}
+
+// FormatFloat formats a float with thousands separators and the formatter decimals
+func (f Formatter) FormatFloat(v float64) string {
+	s := fmt.Sprintf("%.*f", f.Decimals, math.Abs(v))
+	// Values which round to zero have no sign
+	if strings.Trim(s, "0.") == "" {
+		v = 0
+	}
+	return f.sign(v, f.Locale.separate(s))
+}
+
+// abbreviate formats an abbreviated value with fixed decimals if set, or the default format given
+func (f Formatter) abbreviate(v float64, format string) string {
+	if f.Decimals > 0 {
+		return fmt.Sprintf("%.*f", f.Decimals, v)
+	}
+	return fmt.Sprintf(format, v)
+}
+
+// sign prefixes a formatted value with a minus sign if negative, or plus if positive and Sign is set
+func (f Formatter) sign(v float64, s string) string {
+	if v < 0 {
+		return "-" + s
+	} else if v > 0 && f.Sign {
+		return "+" + s
+	}
+	return s
+}
+
+// separate adds thousands separators and the decimal separator to an unsigned number formatted by fmt
+func (l Locale) separate(s string) string {
+	whole, fraction := s, ""
+	if i := strings.Index(s, "."); i >= 0 {
+		whole, fraction = s[:i], s[i+1:]
+	}
+
+	var b strings.Builder
+	for i, c := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			b.WriteString(l.Thousands)
+		}
+		b.WriteRune(c)
+	}
+	if fraction != "" {
+		b.WriteString(l.Decimal)
+		b.WriteString(fraction)
+	}
+	return b.String()
+}
+
+// abs returns the absolute value of i
+func abs(i int) int {
+	if i < 0 {
+		return -i
+	}
+	return i
+}
+
+// decimal replaces the decimal point in a formatted number with the locale decimal separator
+func (l Locale) decimal(s string) string {
+	return strings.Replace(s, ".", l.Decimal, 1)
+}
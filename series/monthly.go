@@ -0,0 +1,61 @@
+package series
+
+import (
+	"fmt"
+	"time"
+)
+
+// Month summarises one data kind over a calendar month
+type Month struct {
+	Year  int `json:"year"`
+	Month int `json:"month"`
+
+	// Days is the number of days of the month in the series, less than a full month for the first and last months if partial
+	Days int `json:"days"`
+
+	// Total is the sum of daily values in the month, and Average the total per day
+	Total   int     `json:"total"`
+	Average float64 `json:"average"`
+
+	// Peak is the highest daily value in the month, on PeakDate, the first such day if several
+	Peak     int       `json:"peak"`
+	PeakDate time.Time `json:"peak_date"`
+
+	// Provisional is true if any day of the month may still be revised
+	Provisional bool `json:"provisional"`
+}
+
+// Name returns the month and year for display, as April 2020
+func (m Month) Name() string {
+	return fmt.Sprintf("%s %d", time.Month(m.Month), m.Year)
+}
+
+// PeakDateDisplay returns the date of the peak for display
+func (m Month) PeakDateDisplay() string {
+	return m.PeakDate.Format("2 Jan")
+}
+
+// Monthly returns the totals, averages and peak days of dataKind for each calendar month in the series, in date order
+func (d *Data) Monthly(dataKind int) []Month {
+	daily := d.DailyValues(dataKind)
+
+	var months []Month
+	for i, day := range d.Days {
+		year, month := day.Date.Year(), int(day.Date.Month())
+		if len(months) == 0 || months[len(months)-1].Year != year || months[len(months)-1].Month != month {
+			months = append(months, Month{Year: year, Month: month, Peak: daily[i], PeakDate: day.Date})
+		}
+		m := &months[len(months)-1]
+		m.Days++
+		m.Total += daily[i]
+		if daily[i] > m.Peak {
+			m.Peak, m.PeakDate = daily[i], day.Date
+		}
+		m.Provisional = m.Provisional || day.Provisional
+	}
+
+	for i := range months {
+		months[i].Average = float64(months[i].Total) / float64(months[i].Days)
+	}
+	return months
+}
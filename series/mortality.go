@@ -0,0 +1,117 @@
+package series
+
+// defaultDeathLag is the default number of days between confirmation and death
+// used for lag-adjusted case fatality rates
+const defaultDeathLag = 14
+
+// Mortality holds a set of mortality metrics for one area
+// rates are expressed as percentages
+type Mortality struct {
+	// Cumulative deaths and confirmed cases on the last day
+	Deaths    int
+	Confirmed int
+
+	// Case fatality rate - deaths / confirmed on the same day
+	CFR float64
+
+	// Lag-adjusted case fatality rate - deaths / confirmed Lag days before
+	LaggedCFR float64
+	Lag       int
+
+	// Case fatality rate with deaths matched to cases by the distribution of lags in DeathLag
+	AdjustedCFR float64
+	DeathLag    DeathLag
+
+	// Deaths per 100k population (zero if population unknown)
+	DeathsPer100k float64
+
+	// Excess deaths over the same period (zero if no excess data available)
+	// and the ratio of reported deaths to excess deaths as a percentage
+	ExcessDeaths int
+	ExcessRatio  float64
+
+	// Flu baseline deaths expected over the same period in past flu seasons (zero if no flu data available)
+	// and the ratio of reported deaths to the flu baseline as a percentage
+	FluDeaths int
+	FluRatio  float64
+}
+
+// HasExcess returns true if excess death data is available
+func (m Mortality) HasExcess() bool {
+	return m.ExcessDeaths > 0
+}
+
+// HasFlu returns true if flu baseline data is available
+func (m Mortality) HasFlu() bool {
+	return m.FluDeaths > 0
+}
+
+// Mortality returns the combined mortality metrics for this series, using the default lag from confirmation to death
+func (d *Data) Mortality() Mortality {
+	return d.MortalityWithLag(DefaultDeathLag())
+}
+
+// MortalityWithLag returns the combined mortality metrics for this series, with the case fatality rate adjusted by lag
+func (d *Data) MortalityWithLag(lag DeathLag) Mortality {
+	last := d.LastDay()
+	m := Mortality{
+		Deaths:        last.Value(DataDeaths),
+		Confirmed:     last.Value(DataConfirmed),
+		CFR:           d.CaseFatalityRate(),
+		LaggedCFR:     d.LaggedCaseFatalityRate(defaultDeathLag),
+		Lag:           defaultDeathLag,
+		AdjustedCFR:   d.AdjustedCaseFatalityRate(lag),
+		DeathLag:      lag,
+		DeathsPer100k: d.DeathsPer100k(),
+		ExcessDeaths:  d.ExcessDeaths(),
+		FluDeaths:     d.FluBaselineDeaths(),
+	}
+
+	if m.ExcessDeaths > 0 {
+		m.ExcessRatio = percent(m.Deaths, m.ExcessDeaths)
+	}
+	if m.FluDeaths > 0 {
+		m.FluRatio = percent(m.Deaths, m.FluDeaths)
+	}
+
+	return m
+}
+
+// CaseFatalityRate returns deaths as a percentage of confirmed cases on the last day
+func (d *Data) CaseFatalityRate() float64 {
+	last := d.LastDay()
+	return percent(last.Value(DataDeaths), last.Value(DataConfirmed))
+}
+
+// LaggedCaseFatalityRate returns deaths on the last day as a percentage of
+// confirmed cases lag days earlier, to allow for the delay between diagnosis and death
+func (d *Data) LaggedCaseFatalityRate(lag int) float64 {
+	i := len(d.Days) - 1 - lag
+	if i < 0 {
+		return 0
+	}
+	return percent(d.LastDay().Value(DataDeaths), d.Days[i].Value(DataConfirmed))
+}
+
+// DeathsPer100k returns cumulative deaths per 100,000 population
+func (d *Data) DeathsPer100k() float64 {
+	return d.Per100k(float64(d.LastDay().Value(DataDeaths)))
+}
+
+// ExcessDeaths returns excess deaths for this area over the series
+// this is zero if no mortality data is loaded for the area
+func (d *Data) ExcessDeaths() int {
+	first := d.PreviousDay
+	if first == nil {
+		first = &Day{}
+	}
+	return d.LastDay().Value(DataExcess) - first.Value(DataExcess)
+}
+
+// percent returns a as a percentage of b, or 0 if b is 0
+func percent(a, b int) float64 {
+	if b == 0 {
+		return 0
+	}
+	return float64(a) * 100 / float64(b)
+}
@@ -0,0 +1,68 @@
+package series
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// LoadNotes loads notes into the default store, see Store.LoadNotes
+func LoadNotes(p string) error {
+	return DefaultStore().LoadNotes(p)
+}
+
+// LoadNotes loads curated notes from the specified file and adds them to days by area id and date
+// rows are area_id,date,note, for example to explain a jump when reporting methodology changed
+// a missing file is not an error as notes are optional
+// dataset must be locked while performing this operation
+func (st *Store) LoadNotes(p string) error {
+	return st.dataset.loadNotes(p, all)
+}
+
+// loadNotes loads notes from the specified file for the series in the slice for which include returns true
+func (slice Slice) loadNotes(p string, include func(*Data) bool) error {
+	header := func(row []string) error {
+		if len(row) < 3 || row[0] != "area_id" || row[1] != "date" || row[2] != "note" {
+			return fmt.Errorf("notes: invalid header row in file:%s row:%s", p, row)
+		}
+		return nil
+	}
+
+	_, err := streamCSV(p, header, func(row []string) error {
+		if len(row) < 3 {
+			return fmt.Errorf("notes: invalid row len for row:%s", row)
+		}
+		areaID, err := strconv.Atoi(row[0])
+		if err != nil {
+			return fmt.Errorf("notes: invalid area id at row:%s", row)
+		}
+		s, err := slice.FindSeries(areaID)
+		if err != nil {
+			return fmt.Errorf("notes: unknown area at row:%s", row)
+		}
+		if !include(s) {
+			return nil
+		}
+		date, err := time.Parse("2006-01-02", row[1])
+		if err != nil {
+			return fmt.Errorf("notes: invalid date at row:%s", row)
+		}
+		if row[2] == "" {
+			return fmt.Errorf("notes: empty note at row:%s", row)
+		}
+
+		// Ignore days outside the series
+		for _, day := range s.Days {
+			if day.Date.Equal(date) {
+				day.Notes = append(day.Notes, row[2])
+				break
+			}
+		}
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
@@ -11,7 +11,9 @@ func PeriodOptions() (options []Option) {
 
 	options = append(options, Option{Name: "All Time", Value: "-1"})
 	options = append(options, Option{Name: "112 Days", Value: "112"})
+	options = append(options, Option{Name: "90 Days", Value: "90"})
 	options = append(options, Option{Name: "56 Days", Value: "56"})
+	options = append(options, Option{Name: "30 Days", Value: "30"})
 	options = append(options, Option{Name: "28 Days", Value: "28"})
 	options = append(options, Option{Name: "14 Days", Value: "14"})
 	options = append(options, Option{Name: "7 Days", Value: "7"})
@@ -20,16 +22,26 @@ func PeriodOptions() (options []Option) {
 	return options
 }
 
-// CountryOptions uses our stored dataset to fetch country options
+// CountryOptions returns country options from the default store
 func CountryOptions() (options []Option) {
-	mutex.RLock()
-	defer mutex.RUnlock()
-	return dataset.CountryOptions()
+	return DefaultStore().CountryOptions()
 }
 
-// ProvinceOptions uses our stored dataset to fetch province options for a country
+// CountryOptions uses our stored dataset to fetch country options
+func (st *Store) CountryOptions() (options []Option) {
+	st.mutex.RLock()
+	defer st.mutex.RUnlock()
+	return st.dataset.CountryOptions()
+}
+
+// ProvinceOptions returns province options for a country from the default store
 func ProvinceOptions(country string) (options []Option) {
-	mutex.RLock()
-	defer mutex.RUnlock()
-	return dataset.ProvinceOptions(country)
+	return DefaultStore().ProvinceOptions(country)
+}
+
+// ProvinceOptions uses our stored dataset to fetch province options for a country
+func (st *Store) ProvinceOptions(country string) (options []Option) {
+	st.mutex.RLock()
+	defer st.mutex.RUnlock()
+	return st.dataset.ProvinceOptions(country)
 }
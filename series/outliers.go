@@ -0,0 +1,96 @@
+package series
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// OutlierDeviations is the number of standard deviations from the trailing window beyond which a day is an outlier by default
+const OutlierDeviations = 4.0
+
+// outlierWindow is the number of days before each day used to find the values expected on it
+const outlierWindow = 14
+
+// outlierMinDays is the number of days in the window, not flagged themselves, required to flag a day
+const outlierMinDays = 7
+
+// outlierMinDaily is the minimum average daily value in the window for days to be flagged, below this noise dominates
+const outlierMinDaily = 5.0
+
+// Outlier is a day on which the daily value of one data kind deviates from the days before it
+// these are usually backlogs reported on one day, or revisions which remove cases reported earlier
+type Outlier struct {
+	Date  time.Time `json:"date"`
+	Value int       `json:"value"`
+
+	// Expected is the average daily value over the trailing window, and Deviations the distance from it
+	// in standard deviations, negative if the value was lower than expected
+	Expected   float64 `json:"expected"`
+	Deviations float64 `json:"deviations"`
+}
+
+// Outliers returns the days on which the daily value of dataKind is more than k standard deviations
+// from the average of the window of days before it, while the day after is not, in date order
+// a value which stays high is growth rather than a backlog, so the last complete day is not flagged until the next is known
+// days already flagged are left out of the windows of later days, so one backlog does not hide the next
+// the deviation is at least the square root of the average, as counts vary by that much by chance
+// provisional days are not flagged as they may still be revised
+func (d *Data) Outliers(dataKind int, k float64) []Outlier {
+	return d.Memo(fmt.Sprintf("outliers:%d:%g", dataKind, k), func() interface{} {
+		return d.outliers(dataKind, k)
+	}).([]Outlier)
+}
+
+// outliers calculates the days returned by Outliers
+func (d *Data) outliers(dataKind int, k float64) []Outlier {
+	daily := d.DailyValues(dataKind)
+	flagged := make([]bool, len(daily))
+	outliers := []Outlier{}
+
+	for i := outlierMinDays; i < d.CompleteCount()-1; i++ {
+		count, sum, squares := 0, 0.0, 0.0
+		for j := i - outlierWindow; j < i; j++ {
+			if j < 0 || flagged[j] {
+				continue
+			}
+			v := float64(daily[j])
+			count++
+			sum += v
+			squares += v * v
+		}
+		if count < outlierMinDays {
+			continue
+		}
+
+		mean := sum / float64(count)
+		if mean < outlierMinDaily {
+			continue
+		}
+		deviation := math.Sqrt(math.Max(squares/float64(count)-mean*mean, mean))
+		deviations := (float64(daily[i]) - mean) / deviation
+		next := (float64(daily[i+1]) - mean) / deviation
+		if math.Abs(deviations) > k && math.Abs(next) <= k {
+			flagged[i] = true
+			outliers = append(outliers, Outlier{
+				Date:       d.Days[i].Date,
+				Value:      daily[i],
+				Expected:   mean,
+				Deviations: deviations,
+			})
+		}
+	}
+	return outliers
+}
+
+// OutlierDays returns true for each day on which dataKind is an outlier at k standard deviations, see Outliers
+func (d *Data) OutlierDays(dataKind int, k float64) []bool {
+	flags := make([]bool, len(d.Days))
+	for _, o := range d.Outliers(dataKind, k) {
+		i := d.dayIndex(o.Date)
+		if i >= 0 {
+			flags[i] = true
+		}
+	}
+	return flags
+}
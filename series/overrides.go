@@ -0,0 +1,240 @@
+package series
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Override is a correction to the cumulative value of one data kind on one day in an area
+// overrides are applied after values from sources are merged, so they survive refreshes
+type Override struct {
+	AreaID    int       `json:"area_id"`
+	Date      time.Time `json:"date"`
+	Kind      string    `json:"kind"`
+	Value     int       `json:"value"`
+	Reason    string    `json:"reason"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// key returns the area, date and kind which an override replaces the value of
+func (o Override) key() string {
+	return fmt.Sprintf("%d-%s-%s", o.AreaID, o.Date.Format("2006-01-02"), o.Kind)
+}
+
+// LoadOverrides loads overrides into the default store, see Store.LoadOverrides
+func LoadOverrides(p string) error {
+	return DefaultStore().LoadOverrides(p)
+}
+
+// LoadOverrides loads overrides from the specified file, replacing those loaded before
+// rows are area_id,date,kind,value,reason,created_at
+// a missing file is not an error as overrides are optional
+func (st *Store) LoadOverrides(p string) error {
+	header := func(row []string) error {
+		if len(row) < 6 || row[0] != "area_id" || row[1] != "date" || row[2] != "kind" || row[3] != "value" {
+			return fmt.Errorf("overrides: invalid header row in file:%s row:%s", p, row)
+		}
+		return nil
+	}
+
+	var loaded []Override
+	_, err := streamCSV(p, header, func(row []string) error {
+		if len(row) < 6 {
+			return fmt.Errorf("overrides: invalid row len for row:%s", row)
+		}
+		areaID, err := strconv.Atoi(row[0])
+		if err != nil {
+			return fmt.Errorf("overrides: invalid area id at row:%s", row)
+		}
+		date, err := time.Parse("2006-01-02", row[1])
+		if err != nil {
+			return fmt.Errorf("overrides: invalid date at row:%s", row)
+		}
+		value, err := strconv.Atoi(row[3])
+		if err != nil || DataKindFromName(row[2]) == DataNone {
+			return fmt.Errorf("overrides: invalid value at row:%s", row)
+		}
+		created, err := time.Parse(time.RFC3339, row[5])
+		if err != nil {
+			return fmt.Errorf("overrides: invalid created at row:%s", row)
+		}
+		loaded = append(loaded, Override{AreaID: areaID, Date: date, Kind: row[2], Value: value, Reason: row[4], CreatedAt: created})
+		return nil
+	})
+	if os.IsNotExist(err) {
+		err = nil
+	}
+
+	st.overridesMutex.Lock()
+	st.overrides = loaded
+	st.overridesMutex.Unlock()
+	return err
+}
+
+// SaveOverrides saves the overrides of the default store, see Store.SaveOverrides
+func SaveOverrides(p string) error {
+	return DefaultStore().SaveOverrides(p)
+}
+
+// SaveOverrides saves all overrides to the specified file
+func (st *Store) SaveOverrides(p string) error {
+	st.overridesMutex.RLock()
+	defer st.overridesMutex.RUnlock()
+
+	f, err := os.Create(p)
+	if err != nil {
+		return fmt.Errorf("overrides: failed to create file:%s", err)
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	w.Write([]string{"area_id", "date", "kind", "value", "reason", "created_at"})
+	for _, o := range st.overrides {
+		w.Write([]string{strconv.Itoa(o.AreaID), o.Date.Format("2006-01-02"), o.Kind, strconv.Itoa(o.Value), o.Reason, o.CreatedAt.Format(time.RFC3339)})
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// Overrides returns the overrides of the default store, see Store.Overrides
+func Overrides() []Override {
+	return DefaultStore().Overrides()
+}
+
+// Overrides returns all overrides ordered by area id then date
+func (st *Store) Overrides() []Override {
+	st.overridesMutex.RLock()
+	result := make([]Override, len(st.overrides))
+	copy(result, st.overrides)
+	st.overridesMutex.RUnlock()
+
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].AreaID == result[j].AreaID {
+			return result[i].Date.Before(result[j].Date)
+		}
+		return result[i].AreaID < result[j].AreaID
+	})
+	return result
+}
+
+// AddOverride adds an override to the default store, see Store.AddOverride
+func AddOverride(o Override, actor string) error {
+	return DefaultStore().AddOverride(o, actor)
+}
+
+// AddOverride adds an override, replacing any for the same area, date and kind
+// it is applied to the dataset at once and the global series recalculated, the change is audited as made by actor
+func (st *Store) AddOverride(o Override, actor string) error {
+	if DataKindFromName(o.Kind) == DataNone {
+		return fmt.Errorf("overrides: invalid kind:%s", o.Kind)
+	}
+	if o.Value < 0 {
+		return fmt.Errorf("overrides: invalid value:%d", o.Value)
+	}
+	if o.Reason == "" {
+		return fmt.Errorf("overrides: reason required")
+	}
+	o.Date = time.Date(o.Date.Year(), o.Date.Month(), o.Date.Day(), 0, 0, 0, 0, time.UTC)
+	if o.CreatedAt.IsZero() {
+		o.CreatedAt = time.Now().UTC()
+	}
+
+	return st.Update(actor, func(next Slice) error {
+		s, err := next.FindSeries(o.AreaID)
+		if err != nil {
+			return fmt.Errorf("overrides: unknown area:%d", o.AreaID)
+		}
+		if s.IsGlobal() {
+			return fmt.Errorf("overrides: the global series is calculated from other series")
+		}
+		err = next.loadPending(func(d *Data) bool { return d == s })
+		if err != nil {
+			return err
+		}
+		if !o.apply(s) {
+			return fmt.Errorf("overrides: date outside series:%s", o.Date.Format("2006-01-02"))
+		}
+		err = next.CalculateGlobalSeriesData()
+		if err != nil {
+			return err
+		}
+
+		// The override is kept with updates locked, so that the next refresh applies it
+		st.overridesMutex.Lock()
+		defer st.overridesMutex.Unlock()
+		for i, existing := range st.overrides {
+			if existing.key() == o.key() {
+				st.overrides[i] = o
+				return nil
+			}
+		}
+		st.overrides = append(st.overrides, o)
+		return nil
+	})
+}
+
+// ApplyOverrides sets the overrides of the default store on the series in the slice, see Store.ApplyOverrides
+func ApplyOverrides(slice Slice) int {
+	return DefaultStore().ApplyOverrides(slice)
+}
+
+// ApplyOverrides sets the values of all overrides on the series in the slice, pending series are skipped
+// it should be called after values from sources are merged, and returns the number of values applied
+func (st *Store) ApplyOverrides(slice Slice) int {
+	return st.applyOverrides(slice, isLoaded)
+}
+
+// applyOverrides sets the values of overrides on the series in the slice for which include returns true
+func (st *Store) applyOverrides(slice Slice, include func(*Data) bool) int {
+	st.overridesMutex.RLock()
+	defer st.overridesMutex.RUnlock()
+
+	applied := 0
+	for _, o := range st.overrides {
+		s, err := slice.FindSeries(o.AreaID)
+		if err != nil || !include(s) {
+			continue
+		}
+		if o.apply(s) {
+			applied++
+		}
+	}
+	return applied
+}
+
+// apply sets the value of this override on the day of series s, and returns false if s has no such day
+func (o Override) apply(s *Data) bool {
+	day := s.dayAt(o.Date)
+	if day == nil {
+		return false
+	}
+	kind := DataKindFromName(o.Kind)
+	day.SetData(kind, o.Value)
+	day.SetSource(kind, SourceOverride)
+	return true
+}
+
+// dayAt returns the day in this series at date, or nil if the series does not include date
+func (d *Data) dayAt(date time.Time) *Day {
+	i := d.dayIndex(date)
+	if i < 0 {
+		return nil
+	}
+	return d.Days[i]
+}
+
+// dayIndex returns the index of the day with date, or -1 if the series has no such day
+func (d *Data) dayIndex(date time.Time) int {
+	if len(d.Days) == 0 {
+		return -1
+	}
+	i := int(date.Sub(d.Days[0].Date).Hours() / 24)
+	if i < 0 || i >= len(d.Days) || !d.Days[i].Date.Equal(date) {
+		return -1
+	}
+	return i
+}
@@ -0,0 +1,134 @@
+package series
+
+import (
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Page is one page of a slice of series, with a cursor for the page after it
+// Next is empty on the last page
+type Page struct {
+	Series Slice
+	Total  int
+	Next   string
+}
+
+// pageKey orders series in pages, values largest first, then names and ids ascending
+// as ids are unique the order is total, so cursors remain valid as values change
+type pageKey struct {
+	value int
+	name  string
+	id    int
+}
+
+// less returns true if k sorts before o
+func (k pageKey) less(o pageKey) bool {
+	if k.value != o.value {
+		return k.value > o.value
+	}
+	if k.name != o.name {
+		return k.name < o.name
+	}
+	return k.id < o.id
+}
+
+// cursor encodes the key as an opaque string for clients
+func (k pageKey) cursor() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d:%d:%s", k.value, k.id, k.name)))
+}
+
+// parseCursor decodes a cursor returned by pageKey.cursor
+func parseCursor(cursor string) (pageKey, error) {
+	var k pageKey
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return k, fmt.Errorf("series: invalid cursor:%s", err)
+	}
+	parts := strings.SplitN(string(data), ":", 3)
+	if len(parts) != 3 {
+		return k, fmt.Errorf("series: invalid cursor:%s", cursor)
+	}
+	k.value, err = strconv.Atoi(parts[0])
+	if err != nil {
+		return k, fmt.Errorf("series: invalid cursor:%s", err)
+	}
+	k.id, err = strconv.Atoi(parts[1])
+	if err != nil {
+		return k, fmt.Errorf("series: invalid cursor:%s", err)
+	}
+	k.name = parts[2]
+	return k, nil
+}
+
+// pageKeyFunc returns the function giving the key of a series for the sort given
+// sort may be the name of a data kind, name or id
+func pageKeyFunc(sortName string) (func(*Data) pageKey, error) {
+	switch sortName {
+	case "name":
+		return func(d *Data) pageKey { return pageKey{name: d.Title(), id: d.ID} }, nil
+	case "id":
+		return func(d *Data) pageKey { return pageKey{id: d.ID} }, nil
+	}
+	dataKind := DataKindFromName(sortName)
+	if dataKind == DataNone {
+		return nil, fmt.Errorf("series: invalid sort:%s", sortName)
+	}
+	return func(d *Data) pageKey { return pageKey{value: d.LastDay().Value(dataKind), id: d.ID} }, nil
+}
+
+// Page returns perPage series ordered by sortName, starting after the cursor if given, else at page (from 1)
+// cursors start after the last series of the previous page, so clients do not skip or repeat
+// series when values change between requests, as they may with page numbers
+func (slice Slice) Page(sortName, cursor string, page, perPage int) (Page, error) {
+	key, err := pageKeyFunc(sortName)
+	if err != nil {
+		return Page{}, err
+	}
+	if perPage < 1 {
+		return Page{}, fmt.Errorf("series: invalid per page:%d", perPage)
+	}
+
+	sorted := make(Slice, len(slice))
+	copy(sorted, slice)
+	sort.Slice(sorted, func(i, j int) bool {
+		return key(sorted[i]).less(key(sorted[j]))
+	})
+
+	start := 0
+	if cursor != "" {
+		after, err := parseCursor(cursor)
+		if err != nil {
+			return Page{}, err
+		}
+		start = sort.Search(len(sorted), func(i int) bool {
+			return after.less(key(sorted[i]))
+		})
+	} else if page > 1 {
+		// Pages past the last are empty, checked before multiplying so large pages cannot overflow
+		if page-1 >= (len(sorted)+perPage-1)/perPage {
+			start = len(sorted)
+		} else {
+			start = (page - 1) * perPage
+		}
+	}
+	if start < 0 || start > len(sorted) {
+		start = len(sorted)
+	}
+
+	end := len(sorted)
+	if perPage < end-start {
+		end = start + perPage
+	}
+
+	p := Page{
+		Series: sorted[start:end],
+		Total:  len(sorted),
+	}
+	if end < len(sorted) && end > start {
+		p.Next = key(sorted[end-1]).cursor()
+	}
+	return p, nil
+}
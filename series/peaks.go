@@ -0,0 +1,73 @@
+package series
+
+import (
+	"math"
+	"time"
+)
+
+// peakWindow is the number of days either side of a peak which must be lower than it
+const peakWindow = 7
+
+// peakAverageDays is the number of days averaged to smooth daily values before finding peaks
+const peakAverageDays = 7
+
+// Peak records a local maximum of smoothed daily values
+type Peak struct {
+	Date  time.Time `json:"date"`
+	Value int       `json:"value"`
+}
+
+// DateDisplay returns the date of the peak for humans
+func (p *Peak) DateDisplay() string {
+	return p.Date.Format("2 Jan, 2006")
+}
+
+// Peaks returns the local maxima of the 7 day average of daily values for dataKind, oldest first
+// a peak must be the highest value for peakWindow days either side, so peaks in the last week
+// are not reported until values have fallen, provisional days are ignored
+func (d *Data) Peaks(dataKind int) []Peak {
+	n := d.CompleteCount()
+	averages := MovingAverage(d.DailyValues(dataKind)[:n], peakAverageDays)
+
+	var peaks []Peak
+	for _, i := range peakIndexes(averages) {
+		peaks = append(peaks, Peak{Date: d.Days[i].Date, Value: int(math.Round(averages[i]))})
+	}
+	return peaks
+}
+
+// HighestPeak returns the highest peak of daily values for dataKind or nil if none
+func (d *Data) HighestPeak(dataKind int) *Peak {
+	var highest *Peak
+	peaks := d.Peaks(dataKind)
+	for i, p := range peaks {
+		if highest == nil || p.Value > highest.Value {
+			highest = &peaks[i]
+		}
+	}
+	return highest
+}
+
+// peakIndexes returns the indexes of peaks in values with a full window after them
+func peakIndexes(values []float64) (indexes []int) {
+	for i := 0; i+peakWindow < len(values); i++ {
+		if values[i] > 0 && isPeak(values, i) {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes
+}
+
+// isPeak returns true if values[i] is higher than the values before it in the window
+// and at least as high as those after, so the first day of a flat top is the peak
+func isPeak(values []float64, i int) bool {
+	for j := i - peakWindow; j <= i+peakWindow && j < len(values); j++ {
+		if j < 0 || j == i {
+			continue
+		}
+		if (j < i && values[j] >= values[i]) || (j > i && values[j] > values[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -1,30 +1,52 @@
 package series
 
-import ()
+import (
+	"log"
+	"time"
+)
 
-// FetchSeries uses our stored dataset to fetch a series
+// FetchSeries fetches a series from the default store, see Store.FetchSeries
 func FetchSeries(country string, province string) (*Data, error) {
-	mutex.RLock()
-	defer mutex.RUnlock()
-	return dataset.FetchSeries(country, province)
+	return DefaultStore().FetchSeries(country, province)
 }
 
-// FindSeries uses our stored dataset to fetch a series by series id
+// FetchSeries uses our stored dataset to fetch a series
+// pending provinces are loaded first
+func (st *Store) FetchSeries(country string, province string) (*Data, error) {
+	st.mutex.RLock()
+	s, err := st.dataset.FetchSeries(country, province)
+	st.mutex.RUnlock()
+	return st.loadSeries(s, err)
+}
+
+// FindSeries fetches a series by id from the default store, see Store.FindSeries
 func FindSeries(seriesID int) (*Data, error) {
-	mutex.RLock()
-	defer mutex.RUnlock()
-	return dataset.FindSeries(seriesID)
+	return DefaultStore().FindSeries(seriesID)
 }
 
-// SelectedEuropeanSeries selects a set of comparative series of interest from Europe
+// FindSeries uses our stored dataset to fetch a series by series id
+// pending provinces are loaded first
+func (st *Store) FindSeries(seriesID int) (*Data, error) {
+	st.mutex.RLock()
+	s, err := st.dataset.FindSeries(seriesID)
+	st.mutex.RUnlock()
+	return st.loadSeries(s, err)
+}
+
+// SelectedEuropeanSeries selects comparative series from Europe in the default store
 func SelectedEuropeanSeries(country string, n int) Slice {
-	mutex.RLock()
-	defer mutex.RUnlock()
+	return DefaultStore().SelectedEuropeanSeries(country, n)
+}
+
+// SelectedEuropeanSeries selects a set of comparative series of interest from Europe
+func (st *Store) SelectedEuropeanSeries(country string, n int) Slice {
+	st.mutex.RLock()
+	defer st.mutex.RUnlock()
 
 	// Fetch all top series
 	var count int
 	var collection Slice
-	for _, s := range dataset {
+	for _, s := range st.dataset {
 		if count >= n {
 			break
 		}
@@ -43,7 +65,7 @@ func SelectedEuropeanSeries(country string, n int) Slice {
 		if s.Country == country {
 			collection = append(collection, s)
 			count++
-		} else if s.Country == "Italy" || s.Country == "Spain" || s.Country == "France" || s.Country == "Switzerland" || s.Country == "Germany" || s.Country == "United Kingdom" || s.Country == "Sweden" || s.Country == "Netherlands" {
+		} else if s.InGroup(GroupEuropeComparisons) {
 			collection = append(collection, s)
 			count++
 		}
@@ -54,22 +76,27 @@ func SelectedEuropeanSeries(country string, n int) Slice {
 
 }
 
-// SelectedSeries selects a set of comparative series of interest
+// SelectedSeries selects comparative series in the default store
 func SelectedSeries(country string, n int) Slice {
-	mutex.RLock()
-	defer mutex.RUnlock()
+	return DefaultStore().SelectedSeries(country, n)
+}
 
+// SelectedSeries selects a set of comparative series of interest
+func (st *Store) SelectedSeries(country string, n int) Slice {
 	var count int
 	var collection Slice
 
 	// Always include this country in the selected series
-	s, err := FetchSeries(country, "")
+	s, err := st.FetchSeries(country, "")
 	if err == nil {
 		collection = append(collection, s)
 	}
 
+	st.mutex.RLock()
+	defer st.mutex.RUnlock()
+
 	// Fetch all top series
-	for _, s := range dataset {
+	for _, s := range st.dataset {
 		if count >= n {
 			break
 		}
@@ -84,18 +111,7 @@ func SelectedSeries(country string, n int) Slice {
 			continue
 		}
 
-		switch s.Country {
-		case "Italy":
-			fallthrough
-		case "US":
-			fallthrough
-		case "Japan":
-			fallthrough
-		case "China":
-			fallthrough
-		case "Germany":
-			fallthrough
-		case "United Kingdom":
+		if s.InGroup(GroupComparisons) {
 			collection = append(collection, s)
 			count++
 		}
@@ -105,15 +121,20 @@ func SelectedSeries(country string, n int) Slice {
 
 }
 
-// TopSeriesGlobal selects the top n series by deaths for global page
+// TopSeriesGlobal selects the top n series by deaths in the default store
 func TopSeriesGlobal(country string, n int) Slice {
-	mutex.RLock()
-	defer mutex.RUnlock()
+	return DefaultStore().TopSeriesGlobal(country, n)
+}
+
+// TopSeriesGlobal selects the top n series by deaths for global page
+func (st *Store) TopSeriesGlobal(country string, n int) Slice {
+	st.mutex.RLock()
+	defer st.mutex.RUnlock()
 
 	// Fetch all top series
 	var count int
 	var collection Slice
-	for _, s := range dataset {
+	for _, s := range st.dataset {
 		if count >= n {
 			break
 		}
@@ -134,15 +155,115 @@ func TopSeriesGlobal(country string, n int) Slice {
 	return collection
 }
 
-// TopSeries selects the top n series by deaths
+// Areas returns all areas in the default store, see Store.Areas
+func Areas() Slice {
+	return DefaultStore().Areas()
+}
+
+// Areas returns all country and province series excluding the global series, ordered by deaths
+// pending provinces are loaded first
+func (st *Store) Areas() Slice {
+	err := st.LoadPending()
+	if err != nil {
+		log.Printf("series: failed to load pending series:%s", err)
+	}
+
+	st.mutex.RLock()
+	defer st.mutex.RUnlock()
+
+	var collection Slice
+	for _, s := range st.dataset {
+		if !s.IsGlobal() {
+			collection = append(collection, s)
+		}
+	}
+
+	return collection
+}
+
+// Countries returns all countries in the default store, see Store.Countries
+func Countries() Slice {
+	return DefaultStore().Countries()
+}
+
+// Countries returns all country series excluding the global series, ordered by deaths
+func (st *Store) Countries() Slice {
+	st.mutex.RLock()
+	defer st.mutex.RUnlock()
+
+	var collection Slice
+	for _, s := range st.dataset {
+		if s.IsCountry() {
+			collection = append(collection, s)
+		}
+	}
+
+	return collection
+}
+
+// Counts records the number of series in the dataset and the dates they cover
+type Counts struct {
+	Series    int       `json:"series"`
+	Countries int       `json:"countries"`
+	Provinces int       `json:"provinces"`
+	Pending   int       `json:"pending"`
+	Days      int       `json:"days"`
+	FirstDate time.Time `json:"first_date"`
+	LastDate  time.Time `json:"last_date"`
+}
+
+// CountSeries counts the series in the default store, see Store.CountSeries
+func CountSeries() Counts {
+	return DefaultStore().CountSeries()
+}
+
+// CountSeries returns the number of series in the dataset, the days are those of the global series
+// pending provinces are counted but not loaded
+func (st *Store) CountSeries() Counts {
+	st.mutex.RLock()
+	defer st.mutex.RUnlock()
+
+	counts := Counts{Series: len(st.dataset)}
+	for _, s := range st.dataset {
+		if s.IsCountry() {
+			counts.Countries++
+		} else if s.IsProvince() {
+			counts.Provinces++
+		}
+		if s.pending {
+			counts.Pending++
+		}
+	}
+
+	global, err := st.dataset.FetchSeries("", "")
+	if err == nil && len(global.Days) > 0 {
+		counts.Days = len(global.Days)
+		counts.FirstDate = global.FirstDay().Date
+		counts.LastDate = global.LastDay().Date
+	}
+	return counts
+}
+
+// TopSeries selects the top n provinces of country by deaths in the default store
 func TopSeries(country string, n int) Slice {
-	mutex.RLock()
-	defer mutex.RUnlock()
+	return DefaultStore().TopSeries(country, n)
+}
+
+// TopSeries selects the top n provinces of country by deaths
+// pending provinces of the country are loaded first so that they are ordered correctly
+func (st *Store) TopSeries(country string, n int) Slice {
+	err := st.loadPending(func(d *Data) bool { return d.MatchCountry(country) })
+	if err != nil {
+		log.Printf("series: failed to load pending series:%s", err)
+	}
+
+	st.mutex.RLock()
+	defer st.mutex.RUnlock()
 
 	// Fetch all top series
 	var count int
 	var collection Slice
-	for _, s := range dataset {
+	for _, s := range st.dataset {
 		if count >= n {
 			break
 		}
@@ -161,5 +282,5 @@ func TopSeries(country string, n int) Slice {
 
 // DataSet - REMOVE AFTER SETUP FIXME this is not thread safe - it is only called to construct initial series data from JHU historical data
 func DataSet() Slice {
-	return dataset
+	return DefaultStore().dataset
 }
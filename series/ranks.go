@@ -0,0 +1,254 @@
+package series
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// RankChangeDays is the number of days over which changes in rank are reported
+const RankChangeDays = 7
+
+// maxRankSnapshots is the number of daily rank snapshots kept, older snapshots are removed
+const maxRankSnapshots = 90
+
+// RankKinds are the data kinds countries are ranked by each day
+var RankKinds = []int{DataDeaths, DataConfirmed, DataTested}
+
+// RankSnapshot records the rank of every country by each of RankKinds on one date
+type RankSnapshot struct {
+	Date time.Time `json:"date"`
+
+	// Ranks holds the rank from 1 of each area id by data kind name, areas without a value are not ranked
+	Ranks map[string]map[int]int `json:"ranks"`
+}
+
+// Ranking is the rank of an area by one data kind on the last date, and its change over RankChangeDays
+type Ranking struct {
+	AreaID int    `json:"area_id"`
+	Kind   string `json:"kind"`
+	Rank   int    `json:"rank"`
+
+	// Previous is the rank on the date Since, or 0 if the area was not ranked or no snapshot was recorded then
+	// Change is the number of places moved up since then, negative if the area moved down
+	Previous int       `json:"previous"`
+	Change   int       `json:"change"`
+	Since    time.Time `json:"since"`
+}
+
+// Movement describes the change in rank for display, for example "up 2"
+func (r Ranking) Movement() string {
+	switch {
+	case r.Previous == 0:
+		return "new"
+	case r.Change > 0:
+		return fmt.Sprintf("up %d", r.Change)
+	case r.Change < 0:
+		return fmt.Sprintf("down %d", -r.Change)
+	}
+	return "unchanged"
+}
+
+// rankSnapshot returns the ranks of the countries in slice on date, by their values on that day
+// areas with equal values share a rank, and the next rank is skipped
+func (slice Slice) rankSnapshot(date time.Time) RankSnapshot {
+	snapshot := RankSnapshot{Date: date, Ranks: make(map[string]map[int]int, len(RankKinds))}
+	for _, kind := range RankKinds {
+		type value struct{ id, v int }
+		var values []value
+		for _, s := range slice {
+			i := s.dayIndex(date)
+			if !s.IsCountry() || i < 0 || s.Days[i].Value(kind) == 0 {
+				continue
+			}
+			values = append(values, value{id: s.ID, v: s.Days[i].Value(kind)})
+		}
+		sort.SliceStable(values, func(i, j int) bool {
+			return values[i].v > values[j].v
+		})
+
+		ranks := make(map[int]int, len(values))
+		for i, v := range values {
+			ranks[v.id] = i + 1
+			if i > 0 && v.v == values[i-1].v {
+				ranks[v.id] = ranks[values[i-1].id]
+			}
+		}
+		snapshot.Ranks[DataKindName(kind)] = ranks
+	}
+	return snapshot
+}
+
+// RecordRanks records the ranks of countries in the default store, see Store.RecordRanks
+func RecordRanks() int {
+	return DefaultStore().RecordRanks()
+}
+
+// RecordRanks records the ranks of countries on the last date in the dataset, and returns the number of snapshots added
+// ranks on the last date are replaced, as values change during the day, but earlier snapshots are kept as recorded
+// dates within RankChangeDays which have no snapshot, as on the first run, are filled from the values on those days
+func (st *Store) RecordRanks() int {
+	st.mutex.RLock()
+	var last time.Time
+	for _, s := range st.dataset {
+		if s.IsCountry() && len(s.Days) > 0 && s.LastDay().Date.After(last) {
+			last = s.LastDay().Date
+		}
+	}
+
+	st.ranksMutex.Lock()
+	defer st.ranksMutex.Unlock()
+	recorded := make(map[time.Time]int, len(st.ranks))
+	for i, r := range st.ranks {
+		recorded[r.Date] = i
+	}
+
+	added := 0
+	for days := 0; days <= RankChangeDays && !last.IsZero(); days++ {
+		date := last.AddDate(0, 0, -days)
+		i, ok := recorded[date]
+		if ok && days > 0 {
+			continue
+		}
+		snapshot := st.dataset.rankSnapshot(date)
+		if ok {
+			st.ranks[i] = snapshot
+			continue
+		}
+		st.ranks = append(st.ranks, snapshot)
+		added++
+	}
+	st.mutex.RUnlock()
+
+	sort.Slice(st.ranks, func(i, j int) bool {
+		return st.ranks[i].Date.Before(st.ranks[j].Date)
+	})
+	if len(st.ranks) > maxRankSnapshots {
+		st.ranks = st.ranks[len(st.ranks)-maxRankSnapshots:]
+	}
+	return added
+}
+
+// Rankings returns the rankings of countries by dataKind in the default store, see Store.Rankings
+func Rankings(dataKind int) []Ranking {
+	return DefaultStore().Rankings(dataKind)
+}
+
+// Rankings returns the ranking of every country ranked by dataKind in the last snapshot, highest first
+func (st *Store) Rankings(dataKind int) []Ranking {
+	st.ranksMutex.RLock()
+	defer st.ranksMutex.RUnlock()
+
+	rankings := []Ranking{}
+	if len(st.ranks) == 0 {
+		return rankings
+	}
+	kind := DataKindName(dataKind)
+	for id := range st.ranks[len(st.ranks)-1].Ranks[kind] {
+		rankings = append(rankings, st.ranking(id, kind))
+	}
+	sort.Slice(rankings, func(i, j int) bool {
+		if rankings[i].Rank != rankings[j].Rank {
+			return rankings[i].Rank < rankings[j].Rank
+		}
+		return rankings[i].AreaID < rankings[j].AreaID
+	})
+	return rankings
+}
+
+// AreaRankings returns the rankings of an area in the default store, see Store.AreaRankings
+func AreaRankings(areaID int) []Ranking {
+	return DefaultStore().AreaRankings(areaID)
+}
+
+// AreaRankings returns the rankings of an area by each of RankKinds it is ranked by in the last snapshot
+func (st *Store) AreaRankings(areaID int) []Ranking {
+	st.ranksMutex.RLock()
+	defer st.ranksMutex.RUnlock()
+
+	rankings := []Ranking{}
+	if len(st.ranks) == 0 {
+		return rankings
+	}
+	for _, kind := range RankKinds {
+		if st.ranks[len(st.ranks)-1].Ranks[DataKindName(kind)][areaID] > 0 {
+			rankings = append(rankings, st.ranking(areaID, DataKindName(kind)))
+		}
+	}
+	return rankings
+}
+
+// ranking returns the ranking of an area by kind in the last snapshot, compared with the last snapshot
+// at least RankChangeDays before it, the caller must hold the ranks mutex
+func (st *Store) ranking(areaID int, kind string) Ranking {
+	last := st.ranks[len(st.ranks)-1]
+	r := Ranking{AreaID: areaID, Kind: kind, Rank: last.Ranks[kind][areaID]}
+
+	since := last.Date.AddDate(0, 0, -RankChangeDays)
+	for i := len(st.ranks) - 1; i >= 0; i-- {
+		if st.ranks[i].Date.After(since) {
+			continue
+		}
+		r.Since = st.ranks[i].Date
+		r.Previous = st.ranks[i].Ranks[kind][areaID]
+		if r.Previous > 0 {
+			r.Change = r.Previous - r.Rank
+		}
+		break
+	}
+	return r
+}
+
+// LoadRanks loads the rank snapshots of the default store, see Store.LoadRanks
+func LoadRanks(p string) error {
+	return DefaultStore().LoadRanks(p)
+}
+
+// LoadRanks loads rank snapshots from the json file at path p
+// a missing file is not an error, the snapshots are left empty
+func (st *Store) LoadRanks(p string) error {
+	f, err := os.Open(filepath.Clean(p))
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var loaded []RankSnapshot
+	err = json.NewDecoder(f).Decode(&loaded)
+	if err != nil {
+		return fmt.Errorf("ranks: failed to decode ranks file:%s", err)
+	}
+
+	st.ranksMutex.Lock()
+	defer st.ranksMutex.Unlock()
+	st.ranks = loaded
+	return nil
+}
+
+// SaveRanks saves the rank snapshots of the default store, see Store.SaveRanks
+func SaveRanks(p string) error {
+	return DefaultStore().SaveRanks(p)
+}
+
+// SaveRanks saves rank snapshots to a json file at path p
+func (st *Store) SaveRanks(p string) error {
+	st.ranksMutex.RLock()
+	defer st.ranksMutex.RUnlock()
+
+	f, err := os.Create(filepath.Clean(p))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	err = json.NewEncoder(f).Encode(st.ranks)
+	if err != nil {
+		return fmt.Errorf("ranks: failed to write ranks file:%s", err)
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package series
+
+// recoveryMaxDays is the longest average time to recovery considered, in days
+const recoveryMaxDays = 42
+
+// recoveryMinCorrelation is the correlation below which the time to recovery is not estimated
+const recoveryMinCorrelation = 0.6
+
+// Recovery is an estimate of the average days from confirmation to recovery in an area
+type Recovery struct {
+	Days        int     `json:"days"`
+	Correlation float64 `json:"correlation"`
+	Points      int     `json:"points"`
+}
+
+// RecoveryDuration estimates the average days from confirmation to recovery, or returns nil if it cannot
+// averaged daily recoveries are correlated with daily confirmed cases shifted by each lag up to recoveryMaxDays
+// and the lag which correlates best is the estimate, as recoveries follow cases by about the time to recover
+// many areas do not report recoveries, or report them in irregular batches, so no estimate is given
+// unless the best correlation is at least recoveryMinCorrelation
+func (d *Data) RecoveryDuration() *Recovery {
+	return d.Memo("recovery", func() interface{} {
+		var best *Recovery
+		lags := correlateLags(d.averagesByDate(DataConfirmed), d.averagesByDate(DataRecovered), 0, recoveryMaxDays)
+		for _, l := range lags {
+			if l.Correlation >= recoveryMinCorrelation && (best == nil || l.Correlation > best.Correlation) {
+				best = &Recovery{Days: l.Lag, Correlation: l.Correlation, Points: l.Points}
+			}
+		}
+		return best
+	}).(*Recovery)
+}
@@ -0,0 +1,99 @@
+package series
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"time"
+)
+
+// daysAgo returns the date the number of days given before today, or the zero time if days is 0
+func daysAgo(days int) time.Time {
+	if days <= 0 {
+		return time.Time{}
+	}
+	now := time.Now().UTC()
+	return time.Date(now.Year(), now.Month(), now.Day()-days, 0, 0, 0, 0, time.UTC)
+}
+
+// Downsample keeps days before the date given at weekly resolution, and returns the number of days downsampled
+// the first day and the last day of each week (Sunday) are kept, and the days between are filled
+// on a straight line from the days kept either side, and marked as interpolated
+// interpolated days are not saved, so the series file holds one row per week for these days
+// and they are filled again here when loaded, the days remain one per date
+func (d *Data) Downsample(before time.Time) int {
+	if before.IsZero() || len(d.Days) == 0 {
+		return 0
+	}
+
+	downsampled := 0
+	kept := 0
+	for i := 1; i < len(d.Days); i++ {
+		day := d.Days[i]
+		if day.Date.Before(before) && day.Date.Weekday() != time.Sunday {
+			continue
+		}
+
+		// Fill the days between the last day kept and this one
+		first, last := d.Days[kept], day
+		for j := kept + 1; j < i; j++ {
+			for _, kind := range d.settings().kinds {
+				v := first.Value(kind)
+				v += (last.Value(kind) - v) * (j - kept) / (i - kept)
+				d.Days[j].SetData(kind, v)
+			}
+			d.Days[j].Interpolated = true
+		}
+		downsampled += i - kept - 1
+		kept = i
+
+		// Days from the cutoff are kept at daily resolution
+		if !day.Date.Before(before) {
+			break
+		}
+	}
+	return downsampled
+}
+
+// Downsample keeps days before the date given at weekly resolution in every series, and returns the number of days downsampled
+func (slice Slice) Downsample(before time.Time) int {
+	downsampled := 0
+	for _, s := range slice {
+		downsampled += s.Downsample(before)
+	}
+	return downsampled
+}
+
+// Downsample downsamples days older than the retention of the default store, see Store.Downsample
+func Downsample() error {
+	return DefaultStore().Downsample()
+}
+
+// Downsample keeps days older than the retention at weekly resolution, and saves the series file
+// it is called daily as the cutoff moves, there is nothing to do if every day is kept
+func (st *Store) Downsample() error {
+	config := st.Config()
+	before := daysAgo(config.RetentionDays)
+	if before.IsZero() {
+		return nil
+	}
+
+	var seriesPath string
+	downsampled := 0
+	err := st.Update("retention", func(next Slice) error {
+		// The series file is saved in the data directory it was loaded from
+		seriesPath = filepath.Join(next.dataPath(config.DataPath), "series.csv")
+		downsampled = next.Downsample(before)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	log.Printf("series: downsampled %d days before:%s", downsampled, before.Format("2006-01-02"))
+
+	err = st.Save(seriesPath)
+	if err != nil {
+		return fmt.Errorf("series: failed to save series data:%s", err)
+	}
+	return nil
+}
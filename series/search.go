@@ -0,0 +1,146 @@
+package series
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// searchAccents replaces accented letters with their unaccented forms
+var searchAccents = strings.NewReplacer(
+	"à", "a", "á", "a", "â", "a", "ã", "a", "ä", "a", "å", "a",
+	"ç", "c", "è", "e", "é", "e", "ê", "e", "ë", "e",
+	"ì", "i", "í", "i", "î", "i", "ï", "i", "ñ", "n",
+	"ò", "o", "ó", "o", "ô", "o", "õ", "o", "ö", "o", "ø", "o",
+	"ù", "u", "ú", "u", "û", "u", "ü", "u", "ý", "y", "ÿ", "y", "ß", "ss",
+)
+
+// Search scores for matching names, lower is a closer match
+const (
+	searchExact = iota
+	searchPrefix
+	searchContains
+	searchTypo
+)
+
+// normalizeName returns the name lower case without accents or punctuation, for comparison
+func normalizeName(name string) string {
+	name = searchAccents.Replace(strings.ToLower(name))
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return ' '
+	}, name)
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// Search returns the series with names matching q, closest matches first, then in the order of the slice
+// names are compared without case, accents or punctuation, country aliases and ISO codes
+// are recognised, and names within a few typos of q also match
+func (slice Slice) Search(q string) Slice {
+	// Aliases are matched as typed, then without punctuation or extra spaces
+	name := slice.settings().CountryName(q)
+	if name == q {
+		name = slice.settings().CountryName(normalizeName(q))
+	}
+	q = normalizeName(name)
+	if q == "" {
+		return nil
+	}
+
+	scores := make(map[*Data]int)
+	var results Slice
+	for _, s := range slice {
+		if s.IsGlobal() {
+			continue
+		}
+		score, ok := s.searchScore(q)
+		if ok {
+			scores[s] = score
+			results = append(results, s)
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return scores[results[i]] < scores[results[j]]
+	})
+	return results
+}
+
+// searchScore returns the score of the closest of the names of this series to q, and false if none match
+// provinces are matched on the province name, countries on the country name and ISO code
+func (d *Data) searchScore(q string) (int, bool) {
+	name := normalizeName(d.Country)
+	if d.IsProvince() {
+		name = normalizeName(d.Province)
+	} else if strings.ToLower(d.CountryCode()) == q {
+		return searchExact, true
+	}
+
+	switch {
+	case name == q:
+		return searchExact, true
+	case strings.HasPrefix(name, q):
+		return searchPrefix, true
+	case len(q) > 2 && strings.Contains(name, q):
+		return searchContains, true
+	}
+
+	// Allow one typo for every four letters, in the whole name or in its prefix of the same length
+	n := len([]rune(q))
+	allowed := n / 4
+	if allowed == 0 {
+		return 0, false
+	}
+	distance := editDistance(q, name)
+	if runes := []rune(name); len(runes) > n {
+		if prefix := editDistance(q, string(runes[:n])); prefix < distance {
+			distance = prefix
+		}
+	}
+	if distance > allowed {
+		return 0, false
+	}
+	return searchTypo + distance, true
+}
+
+// editDistance returns the number of insertions, deletions, substitutions
+// or transpositions of adjacent letters needed to change a into b
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	rows := make([][]int, len(ra)+1)
+	for i := range rows {
+		rows[i] = make([]int, len(rb)+1)
+		rows[i][0] = i
+	}
+	for j := range rows[0] {
+		rows[0][j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			d := min3(rows[i-1][j]+1, rows[i][j-1]+1, rows[i-1][j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] && rows[i-2][j-2]+1 < d {
+				d = rows[i-2][j-2] + 1
+			}
+			rows[i][j] = d
+		}
+	}
+	return rows[len(ra)][len(rb)]
+}
+
+// min3 returns the smallest of a, b and c
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
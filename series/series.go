@@ -2,14 +2,18 @@ package series
 
 import (
 	"fmt"
-	"log"
+	"sort"
 	"strconv"
-	"strings"
 	"time"
 )
 
-// seriesStartDate is our default start date
-var seriesStartDate = time.Date(2020, 1, 22, 0, 0, 0, 0, time.UTC)
+// rollupCountries are countries for which our sources only provide province data
+// the country series is calculated by summing all provinces
+var rollupCountries = map[string]bool{
+	"China":     true,
+	"Australia": true,
+	"Canada":    true,
+}
 
 // NewData returns a new Data series based on the row values
 // We expect the cols
@@ -35,16 +39,27 @@ func NewData(row []string) (*Data, error) {
 		return nil, fmt.Errorf("areas: invalid population at row:%s", row)
 	}
 
-	var lockdown time.Time
+	// The lockdown column is kept for older files, events are now loaded from events.csv
+	var events []Event
 	if row[6] != "" {
-		lockdown, err = time.Parse("2006-01-02", row[6])
+		lockdown, err := time.Parse("2006-01-02", row[6])
 		if err != nil {
 			return nil, fmt.Errorf("areas: invalid lockdown at row:%s", row)
 		}
+		events = append(events, Event{Type: EventLockdown, Start: lockdown, Description: "Lockdown"})
 	}
 
 	color := row[7]
 
+	// Area is an optional column, older files may not include it
+	var areaKm2 float64
+	if len(row) > 8 && row[8] != "" {
+		areaKm2, err = strconv.ParseFloat(row[8], 64)
+		if err != nil {
+			return nil, fmt.Errorf("areas: invalid area at row:%s", row)
+		}
+	}
+
 	// NB updated at left at zero time
 	s := &Data{
 		ID:         areaID,
@@ -53,9 +68,12 @@ func NewData(row []string) (*Data, error) {
 		Latitude:   latitude,
 		Longitude:  longitude,
 		Population: population,
+		AreaKm2:    areaKm2,
 		Color:      color,
-		LockdownAt: lockdown,
+		Events:     events,
+		Rollup:     province == "" && rollupCountries[country],
 		Days:       make([]*Day, 0),
+		stats:      newStats(),
 	}
 
 	return s, nil
@@ -76,6 +94,9 @@ type Data struct {
 	// The population of the area (if known)
 	Population int
 
+	// The area in square kilometres (if known)
+	AreaKm2 float64
+
 	// Coordinates for this area
 	Latitude, Longitude float64
 
@@ -85,28 +106,41 @@ type Data struct {
 	// UTC Date data last updated
 	UpdatedAt time.Time
 
-	// UTC Date full area lockdown started
-	LockdownAt time.Time
+	// Interventions in this area such as lockdowns, in the order loaded
+	Events []Event
 
-	// Days containing all our data - each day holds cumulative totals
+	// Rollup is true if this country series is the sum of its provinces
+	// rollups are not included in the global series to avoid double counting
+	Rollup bool
+
+	// Days containing all our data - the date and notes of each day, with values in columns
 	Days []*Day
 
 	// Previous day stores the previous day for this period (if any)
 	// Used to calculate daily totals when truncated with Period
 	PreviousDay *Day
+
+	// columns hold the values of the days by data kind, indexed by data kind, see column
+	// kinds without values have no column
+	columns []*column
+
+	// pending is true until the days of a lazily loaded series are read, see StoreConfig.LazyProvinces
+	// files are the data files the series was loaded from, which pending series are read from
+	pending bool
+	files   *dataFiles
+
+	// store is the store which loaded the series, its config is used by the series, see settings
+	store *Store
+
+	// stats caches derived values, see Memo, statsKey distinguishes copies of periods
+	stats    *stats
+	statsKey string
 }
 
-// Format formats a given number for display and returns a string
+// Format formats a given number for display using the default locale and returns a string
+// views should use the locale of the viewer instead where known
 func (d *Data) Format(i int) string {
-	if i < 10000 {
-		return fmt.Sprintf("%d", i)
-	} else if i < 1000000 {
-		return fmt.Sprintf("%.1fk", float64(i)/1000)
-	} else if i < 1000000000 {
-		return fmt.Sprintf("%.3gm", float64(i)/1000000)
-	}
-
-	return fmt.Sprintf("%.2gb", float64(i)/1000000000)
+	return DefaultLocale.Format(i)
 }
 
 // Global returns true if this is the global series
@@ -135,7 +169,7 @@ func (d *Data) Title() string {
 func (d *Data) StartsAt() time.Time {
 	day := d.FirstDay()
 	if day.Date.IsZero() {
-		return seriesStartDate
+		return d.settings().config.StartDate
 	}
 
 	return day.Date
@@ -154,6 +188,15 @@ func (d *Data) UpdatedAtDisplay() string {
 	return fmt.Sprintf("Data last updated at %s", d.UpdatedAt.Format("2006-01-02 15:04 MST"))
 }
 
+// UpdatedAtDisplayIn returns a string to display updated at in the location given, with the age of the data
+func (d *Data) UpdatedAtDisplayIn(loc *time.Location) string {
+	if d.UpdatedAt.IsZero() {
+		return ""
+	}
+	hours := int(time.Now().UTC().Sub(d.UpdatedAt).Hours())
+	return fmt.Sprintf("Data last updated at %s, %d hours ago", d.UpdatedAt.In(loc).Format("2006-01-02 15:04 MST"), hours)
+}
+
 // SetUpdated updates UpdatedAt if it is before this new time
 func (d *Data) SetUpdated(updated time.Time) {
 	if d.UpdatedAt.Before(updated) {
@@ -166,12 +209,9 @@ func (d *Data) IsGlobal() bool {
 	return d.Country == "" && d.Province == ""
 }
 
-// IsEuropean returns true if this is a European country
+// IsEuropean returns true if this is a European country, see GroupEurope
 func (d *Data) IsEuropean() bool {
-	if d.Province != "" {
-		return false
-	}
-	return d.Country == "United Kingdom" || d.Country == "France" || d.Country == "Italy" || d.Country == "Belgium" || d.Country == "Spain" || d.Country == "Germany" || d.Country == "Netherlands" || d.Country == "Switzerland" || d.Country == "Sweden" || d.Country == "Portugal"
+	return d.InGroup(GroupEurope)
 }
 
 // HasProvinces returns true if this series has significant provinces to compare (e.g. US, china)
@@ -189,15 +229,23 @@ func (d *Data) IsProvince() bool {
 	return d.Country != "" && d.Province != ""
 }
 
-// Valid returns true if this series is valid
-// a series without days is considered invalid
+// Valid returns true if this series has days
+// use Validate for a full check of the data
 func (d *Data) Valid() bool {
-	return len(d.Days) == 0
+	return len(d.Days) > 0
 }
 
 // Key converts a value into one suitable for use in urls
 func (d *Data) Key(v string) string {
-	return strings.Replace(strings.ToLower(v), " ", "-", -1)
+	return urlKey(v)
+}
+
+// Slug returns the path of the page for this series, without a leading slash
+func (d *Data) Slug() string {
+	if d.IsProvince() {
+		return d.Key(d.Country) + "/" + d.Key(d.Province)
+	}
+	return d.Key(d.Country)
 }
 
 // Match returns true if this series matches country and province
@@ -207,9 +255,9 @@ func (d *Data) Match(country string, province string) bool {
 }
 
 // MatchCountry return true if this series matches country
-// performs a case insensitive match
+// performs a case insensitive match, and aliases for the country also match
 func (d *Data) MatchCountry(country string) bool {
-	return d.Key(d.Country) == d.Key(country)
+	return d.Key(d.Country) == d.Key(d.settings().CountryName(country))
 }
 
 // MatchProvince return true if this series matches province
@@ -223,52 +271,88 @@ func (d *Data) FetchDate(date time.Time, dataKind int) int {
 
 	for _, d := range d.Days {
 		if d.Date.Equal(date) {
-			switch dataKind {
-			case DataDeaths:
-				return d.Deaths
-			case DataConfirmed:
-				return d.Confirmed
-			case DataRecovered:
-				return d.Recovered
-			case DataTested:
-				return d.Tested
-			}
+			return d.Value(dataKind)
 		}
 	}
 
 	return 0
 }
 
-// Period returns a subset of the series data just for the no of days specified
+// Period returns a copy of the series data just for the no of days specified
 func (d *Data) Period(days int) *Data {
-	// If we are not long enough, just return full series
-	if days >= len(d.Days) {
-		return d
+	// If we are not long enough, just return a copy of the full series
+	if days < 0 || days >= len(d.Days) {
+		return d.copyDays(0, len(d.Days))
 	}
 
 	// Else return series with truncated days
-	i := len(d.Days) - days
+	return d.copyDays(len(d.Days)-days, len(d.Days))
+}
 
-	// Previous is used to calculate daily totals for the first day
-	// on truncated series
+// PeriodRange returns a copy of the series data for days between from and to inclusive
+// zero times leave that end of the range open
+func (d *Data) PeriodRange(from, to time.Time) *Data {
+	start, end := 0, len(d.Days)
+	for i, day := range d.Days {
+		if !from.IsZero() && day.Date.Before(from) {
+			start = i + 1
+		}
+		if !to.IsZero() && day.Date.After(to) {
+			end = i
+			break
+		}
+	}
+
+	// If range is empty, return a series with no days
+	if start > end {
+		start = end
+	}
+
+	return d.copyDays(start, end)
+}
+
+// copyDays returns a copy of this series with copies of the days from start to end
+// it also sets PreviousDay, which is used to calculate daily totals
+// for the first day on truncated series
+func (d *Data) copyDays(start, end int) *Data {
 	previous := &Day{}
-	if i > 0 {
-		previous = d.Days[i-1]
+	if start > 0 {
+		previous.Date = d.Days[start-1].Date
+	} else if d.PreviousDay != nil {
+		previous.Date = d.PreviousDay.Date
+	}
+
+	days := make([]Day, end-start)
+	for i, day := range d.Days[start:end] {
+		days[i] = *day
+	}
+
+	columns := make([]*column, len(d.columns))
+	for kind, c := range d.columns {
+		if c != nil {
+			columns[kind] = c.slice(start, end)
+		}
 	}
 
-	return &Data{
+	c := &Data{
 		ID:          d.ID,
 		Country:     d.Country,
 		Province:    d.Province,
 		Population:  d.Population,
+		AreaKm2:     d.AreaKm2,
 		Latitude:    d.Latitude,
 		Longitude:   d.Longitude,
 		Color:       d.Color,
 		UpdatedAt:   d.UpdatedAt,
-		LockdownAt:  d.LockdownAt,
-		Days:        d.Days[i:],
+		Events:      d.Events,
+		Rollup:      d.Rollup,
 		PreviousDay: previous,
+		columns:     columns,
+		store:       d.store,
 	}
+	c.setDays(days)
+	d.shareStats(c, start, end)
+	return c
 }
 
 // FirstDay returns the last day in the series
@@ -300,92 +384,92 @@ func (d *Data) PenultimateDay() *Day {
 
 // TotalDeaths returns the cumulative death due to COVID-19 for this series
 func (d *Data) TotalDeaths() int {
-	return d.LastDay().Deaths - d.FirstDay().Deaths
+	return d.LastDay().Value(DataDeaths) - d.FirstDay().Value(DataDeaths)
 }
 
 // TotalConfirmed returns the cumulative confirmed cases of COVID-19 for this series
 func (d *Data) TotalConfirmed() int {
-	return d.LastDay().Confirmed - d.FirstDay().Confirmed
+	return d.LastDay().Value(DataConfirmed) - d.FirstDay().Value(DataConfirmed)
 }
 
 // TotalRecovered returns the cumulative recovered cases of COVID-19 for this series
 func (d *Data) TotalRecovered() int {
-	return d.LastDay().Recovered - d.FirstDay().Recovered
+	return d.LastDay().Value(DataRecovered) - d.FirstDay().Value(DataRecovered)
 }
 
 // TotalTested returns the cumulative tested cases of COVID-19 for this series
 func (d *Data) TotalTested() int {
-	return d.LastDay().Tested - d.FirstDay().Tested
+	return d.LastDay().Value(DataTested) - d.FirstDay().Value(DataTested)
 }
 
 // DeathsToday returns deaths for last day in series - day before
 func (d *Data) DeathsToday() int {
-	return d.LastDay().Deaths - d.PenultimateDay().Deaths
+	return d.LastDay().Value(DataDeaths) - d.PenultimateDay().Value(DataDeaths)
 }
 
 // ConfirmedToday returns confirmed for last day in series - day before
 func (d *Data) ConfirmedToday() int {
-	return d.LastDay().Confirmed - d.PenultimateDay().Confirmed
+	return d.LastDay().Value(DataConfirmed) - d.PenultimateDay().Value(DataConfirmed)
 }
 
 // Deaths returns cumulative totals of deaths as integer values
-func (d *Data) Deaths() (values []int) {
-	for _, day := range d.Days {
-		values = append(values, day.Deaths)
-	}
-	return values
+func (d *Data) Deaths() []int {
+	return d.Values(DataDeaths)
 }
 
 // Confirmed returns cumulative totals of confirmed as integer values
-func (d *Data) Confirmed() (values []int) {
-	for _, day := range d.Days {
-		values = append(values, day.Confirmed)
-	}
-	return values
+func (d *Data) Confirmed() []int {
+	return d.Values(DataConfirmed)
 }
 
 // Recovered returns cumulative totals of recovered as integer values
 // values are typically 0 if not available
-func (d *Data) Recovered() (values []int) {
-	for _, day := range d.Days {
-		values = append(values, day.Recovered)
-	}
-	return values
+func (d *Data) Recovered() []int {
+	return d.Values(DataRecovered)
 }
 
 // Tested returns cumulative totals of Tested as integer values
 // values are typically 0 if not available
-func (d *Data) Tested() (values []int) {
-	for _, day := range d.Days {
-		values = append(values, day.Tested)
-	}
-	return values
+func (d *Data) Tested() []int {
+	return d.Values(DataTested)
 }
 
-// DeathsDaily returns an array of int values for deaths per day
-func (d *Data) DeathsDaily() (values []int) {
-	var previous int
-	if d.PreviousDay != nil {
-		previous = d.PreviousDay.Deaths
+// Values returns cumulative totals for the given data kind as integer values, or nil if there are no days
+// the series holds the change on each day, so the totals are rebuilt from them on each call
+func (d *Data) Values(dataKind int) []int {
+	if len(d.Days) == 0 {
+		return nil
 	}
-	for _, day := range d.Days {
-		values = append(values, day.Deaths-previous)
-		previous = day.Deaths
+	c := d.column(dataKind)
+	if c == nil {
+		return make([]int, len(d.Days))
 	}
-	return values
+	return c.cumulative()
 }
 
-// ConfirmedDaily returns an array of int values for confirmed per day
-func (d *Data) ConfirmedDaily() (values []int) {
-	var previous int
-	if d.PreviousDay != nil {
-		previous = d.PreviousDay.Confirmed
+// DailyValues returns an array of int values per day for the given data kind, or nil if there are no days
+// the slice is the column of values held by the series, so it must not be modified
+func (d *Data) DailyValues(dataKind int) []int {
+	if len(d.Days) == 0 {
+		return nil
 	}
-	for _, day := range d.Days {
-		values = append(values, day.Confirmed-previous)
-		previous = day.Confirmed
+	c := d.column(dataKind)
+	if c == nil {
+		return make([]int, len(d.Days))
 	}
-	return values
+	return c.daily()
+}
+
+// DeathsDaily returns an array of int values for deaths per day
+// the slice is shared with the series and must not be modified
+func (d *Data) DeathsDaily() []int {
+	return d.DailyValues(DataDeaths)
+}
+
+// ConfirmedDaily returns an array of int values for confirmed per day
+// the slice is shared with the series and must not be modified
+func (d *Data) ConfirmedDaily() []int {
+	return d.DailyValues(DataConfirmed)
 }
 
 // DaysFrom returns day counts from a series of numbers
@@ -401,38 +485,54 @@ func (d *Data) DaysFrom(values []int) []string {
 
 // DeathsFrom returns series after death number n
 func (d *Data) DeathsFrom(n int) []int {
-	// Walk through deaths looking for death n, then return series from that day
+	return d.AlignedFrom(DataDeaths, n)
+}
+
+// AlignedFrom returns cumulative values for dataKind from the day value n was reached
+// this ignores today's incomplete data, nil is returned if n is never reached
+func (d *Data) AlignedFrom(dataKind, n int) []int {
+	i := d.AlignedIndex(dataKind, n)
+	if i < 0 {
+		return nil
+	}
+	return d.Values(dataKind)[i : len(d.Days)-1]
+}
+
+// AlignedIndex returns the index of the first day on which dataKind reached n, or -1 if never
+func (d *Data) AlignedIndex(dataKind, n int) int {
 	for i, day := range d.Days {
-		if day.Deaths >= n {
-			return d.Deaths()[i : len(d.Days)-1]
+		if day.Value(dataKind) >= n {
+			return i
 		}
 	}
-	return nil
+	return -1
 }
 
-// AverageDeaths returns the average deaths per day over the last 3 days
+// AverageDeaths returns the average deaths per day over the last 3 complete days
 func (d *Data) AverageDeaths() int {
 	// If not enough days, return 0
-	if len(d.Days) < 3 {
+	n := d.CompleteCount()
+	if n < 3 {
 		return 0
 	}
 
 	// Get deaths over last 3 days
-	sum := d.Days[len(d.Days)-1].Deaths - d.Days[len(d.Days)-3].Deaths
+	sum := d.Days[n-1].Value(DataDeaths) - d.Days[n-3].Value(DataDeaths)
 
 	// return simple average
 	return sum / 3
 }
 
-// AverageConfirmed returns the average confirmed per day over the last 3 days
+// AverageConfirmed returns the average confirmed per day over the last 3 complete days
 func (d *Data) AverageConfirmed() int {
 	// If not enough days, return 0
-	if len(d.Days) < 3 {
+	n := d.CompleteCount()
+	if n < 3 {
 		return 0
 	}
 
 	// Get deaths over last 3 days
-	sum := d.Days[len(d.Days)-1].Confirmed - d.Days[len(d.Days)-3].Confirmed
+	sum := d.Days[n-1].Value(DataConfirmed) - d.Days[n-3].Value(DataConfirmed)
 
 	// return simple average
 	return sum / 3
@@ -440,26 +540,33 @@ func (d *Data) AverageConfirmed() int {
 
 // DoubleDeathDays returns the number of days it took to more than double deaths
 // this ignores today's incomplete data
-func (d *Data) DoubleDeathDays() (days int) {
-	i := d.Count() - 1
-	half := d.Days[i].Deaths / 2
-	for i--; i >= 0; i-- {
-		if d.Days[i].Deaths < half {
-			break
-		}
-		days++
-	}
-	// Return the number of days required to halve count
-	return days
+func (d *Data) DoubleDeathDays() int {
+	return d.DoubleDays(DataDeaths)
 }
 
 // DoubleConfirmedDays returns the number of days it took to more than double confirmed
 // this ignores today's incomplete data
-func (d *Data) DoubleConfirmedDays() (days int) {
-	i := d.Count() - 1
-	half := d.Days[i].Confirmed / 2
+func (d *Data) DoubleConfirmedDays() int {
+	return d.DoubleDays(DataConfirmed)
+}
+
+// DoubleDays returns the number of days it took to more than double the values of dataKind
+// this ignores today's incomplete data
+func (d *Data) DoubleDays(dataKind int) int {
+	return d.Memo(fmt.Sprintf("double:%d", dataKind), func() interface{} {
+		return d.doubleDays(dataKind)
+	}).(int)
+}
+
+// doubleDays calculates the value returned by DoubleDays
+func (d *Data) doubleDays(dataKind int) (days int) {
+	i := d.CompleteCount() - 1
+	if i < 0 {
+		return 0
+	}
+	half := d.Days[i].Value(dataKind) / 2
 	for i--; i >= 0; i-- {
-		if d.Days[i].Confirmed < half {
+		if d.Days[i].Value(dataKind) < half {
 			break
 		}
 		days++
@@ -473,6 +580,51 @@ func (d *Data) LastHours() int {
 	return time.Now().UTC().Hour()
 }
 
+// dayIndexIn returns the index of the day for the current date in the location given
+// days are UTC dates, if the local date is ahead of the data the last day is used
+// returns -1 if there are no days on or before the local date
+func (d *Data) dayIndexIn(loc *time.Location) int {
+	now := time.Now().In(loc)
+	local := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	for i := len(d.Days) - 1; i >= 0; i-- {
+		if !d.Days[i].Date.After(local) {
+			return i
+		}
+	}
+	return -1
+}
+
+// TodayIn returns the change in the data kind on the current date in the location given
+// if the local date is behind UTC this is the last complete day
+func (d *Data) TodayIn(dataKind int, loc *time.Location) int {
+	i := d.dayIndexIn(loc)
+	if i < 0 {
+		return 0
+	}
+	previous := d.PreviousDay
+	if i > 0 {
+		previous = d.Days[i-1]
+	}
+	if previous == nil {
+		return d.Days[i].Value(dataKind)
+	}
+	return d.Days[i].Value(dataKind) - previous.Value(dataKind)
+}
+
+// LastHoursIn returns the number of hours of data included in TodayIn for the location given
+// this is 24 for complete days
+func (d *Data) LastHoursIn(loc *time.Location) int {
+	i := d.dayIndexIn(loc)
+	if i < 0 {
+		return 0
+	}
+	hours := int(time.Now().UTC().Sub(d.Days[i].Date).Hours())
+	if hours > 24 {
+		return 24
+	}
+	return hours
+}
+
 // Dates returns a set of date labels as an array of strings
 // for every datapoint in this series for use in chart labels
 func (d *Data) Dates() (dates []string) {
@@ -480,7 +632,7 @@ func (d *Data) Dates() (dates []string) {
 		dates = append(dates, day.Date.Format("Jan 2"))
 		/*
 			// Lockdown date gets lockdown label no longer
-			if d.LockdownAt.Equal(day.Date) {
+			if d.LockdownAt().Equal(day.Date) {
 				dates = append(dates, day.Date.Format("Jan 2 (Lockdown)"))
 			} else {
 				dates = append(dates, day.Date.Format("Jan 2"))
@@ -495,7 +647,7 @@ func (d *Data) Dates() (dates []string) {
 func (d *Data) Colors(color string) (colors []string) {
 	for _, day := range d.Days {
 		// Lockdown date gets red colour
-		if d.LockdownAt.Equal(day.Date) {
+		if d.LockdownAt().Equal(day.Date) {
 			colors = append(colors, "#ff0000")
 		} else {
 			colors = append(colors, color)
@@ -504,9 +656,25 @@ func (d *Data) Colors(color string) (colors []string) {
 	return colors
 }
 
-// HasLockdownAt returns true if we have a lockdown date
-func (d *Data) HasLockdownAt() bool {
-	return !d.LockdownAt.IsZero()
+// Density returns the population per square kilometre, or 0 if area or population are unknown
+func (d *Data) Density() float64 {
+	if d.AreaKm2 == 0 {
+		return 0
+	}
+	return float64(d.Population) / d.AreaKm2
+}
+
+// Per100k returns the value given per 100,000 population, or 0 if population is unknown
+func (d *Data) Per100k(v float64) float64 {
+	if d.Population == 0 {
+		return 0
+	}
+	return v * 100000 / float64(d.Population)
+}
+
+// PerMillion returns v per million population, or 0 if population is unknown
+func (d *Data) PerMillion(v float64) float64 {
+	return d.Per100k(v) * 10
 }
 
 // Count returns the count of days in this series
@@ -514,83 +682,97 @@ func (d *Data) Count() int {
 	return len(d.Days)
 }
 
-// SetDayData sets the data for a given day,
+// CompleteCount returns the count of days up to the last day which is not provisional
+// provisional days are only expected at the end of the series
+func (d *Data) CompleteCount() int {
+	n := len(d.Days)
+	for n > 0 && d.Days[n-1].Provisional {
+		n--
+	}
+	return n
+}
+
+// ProvisionalCount returns the count of provisional days at the end of the series
+func (d *Data) ProvisionalCount() int {
+	return len(d.Days) - d.CompleteCount()
+}
+
+// IsProvisional returns true if the last day in the series is provisional
+func (d *Data) IsProvisional() bool {
+	return d.LastDay().Provisional
+}
+
+// CompleteDays marks days on or before date as complete, once the source has published data for them
+func (d *Data) CompleteDays(date time.Time) {
+	for _, day := range d.Days {
+		if !day.Date.After(date) {
+			day.Provisional = false
+		}
+	}
+}
+
+// SetProvisional marks days on or after date as provisional
+func (d *Data) SetProvisional(date time.Time) {
+	for _, day := range d.Days {
+		if !day.Date.Before(date) {
+			day.Provisional = true
+		}
+	}
+}
+
+// SetDayData sets the data for a given day, with a value for each metric of the dataset in the order of DataKinds
 // the day should be added first with AddDays if required
-func (d *Data) SetDayData(dayNo, deaths, confirmed, recovered, tested int) error {
+func (d *Data) SetDayData(dayNo int, values ...int) error {
 	index := dayNo - 1
 	if index > len(d.Days)-1 {
 		return fmt.Errorf("series: index out of range for set day:%d len:%d", index, len(d.Days))
 	}
 
-	day := d.Days[index]
-	day.SetAllData(deaths, confirmed, recovered, tested)
+	d.Days[index].setValues(values...)
 	return nil
 }
 
 // SetData adds the given series of data to this series
 // existing data for that dataKind will be replaced
 func (d *Data) SetData(startDate time.Time, dataKind int, values []int) error {
-
-	//log.Printf("data: set data of kind:%d data:%v", dataKind, values)
-
-	// If we don't have enough days, add some
-	if len(d.Days) < len(values) {
-		//log.Printf("addDays:%d %d", len(d.Days), len(values))
-		d.AddDays(len(values) - len(d.Days))
-	}
-
-	// Now set the values for this datakind on each day we have
-	for i, day := range d.Days {
-
-		// Check date on first day matches
-		if i == 0 && !day.Date.Equal(startDate) {
-			return fmt.Errorf("series: mismatch on start date for data:%v %v", startDate, day.Date)
-		}
-		//log.Printf("day:%d", values[i])
-		// Fill in the value on each day from values
-		err := day.SetData(dataKind, values[i])
-		if err != nil {
-			return fmt.Errorf("series: failed to add day:%v error:%s", day, err)
-		}
-	}
-
-	return nil
+	return d.applyData(startDate, dataKind, values, false)
 }
 
 // MergeData adds the given series of data to this series
 // existing data for that dataKind will have these values added
 func (d *Data) MergeData(startDate time.Time, dataKind int, values []int) error {
+	return d.applyData(startDate, dataKind, values, true)
+}
 
-	if false {
-		log.Printf("data: merge data of kind:%d data:%v", dataKind, values)
+// applyData sets or merges the values given onto our days for dataKind
+// the kind and start date are checked once before the loop over values
+func (d *Data) applyData(startDate time.Time, dataKind int, values []int, merge bool) error {
+	if len(values) == 0 {
+		return nil
+	}
+
+	if DataKindName(dataKind) == "" {
+		return fmt.Errorf("series: invalid data kind:%d", dataKind)
 	}
 
 	// If we don't have enough days, add some
 	if len(d.Days) < len(values) {
-		//log.Printf("addDays:%d %d", len(d.Days), len(values))
 		d.AddDays(len(values) - len(d.Days))
 	}
 
-	// Now set the values for this datakind on each day we have
-	for i, day := range d.Days {
-
-		// Check date on first day matches
-		if i == 0 && !day.Date.Equal(startDate) {
-			return fmt.Errorf("series: mismatch on start date for data:%v %v", startDate, day.Date)
-		}
-		//log.Printf("day:%d", values[i])
-		// Fill in the value on each day from values
-		err := day.MergeData(dataKind, values[i])
-		if err != nil {
-			return fmt.Errorf("series: failed to add day:%v error:%s", day, err)
-		}
+	// Check date on first day matches
+	if !d.Days[0].Date.Equal(startDate) {
+		return fmt.Errorf("series: mismatch on start date for data:%v %v", startDate, d.Days[0].Date)
 	}
 
+	// Fill in the values of the kind in one pass over its column
+	d.columnFor(dataKind).apply(values, merge)
+
 	return nil
 }
 
 // MergeSeries will merge the data from the incoming series with this one
-// start dates are assumed to be the same
+// start dates must be the same, as days are consecutive only the first date is checked
 func (d *Data) MergeSeries(series *Data) error {
 
 	// Change updated at if required
@@ -598,49 +780,174 @@ func (d *Data) MergeSeries(series *Data) error {
 		d.UpdatedAt = series.UpdatedAt
 	}
 
+	// Nothing to merge
+	if len(series.Days) == 0 {
+		return nil
+	}
+
 	// Add days if required
 	if len(d.Days) < len(series.Days) {
-		//log.Printf("addDays:%d", len(series.Days)-len(d.Days))
 		d.AddDays(len(series.Days) - len(d.Days))
 	}
 
-	//log.Printf("days:%d sdays:%d", len(d.Days), len(series.Days))
+	// Check the start dates match once rather than for every day
+	if !d.Days[0].Date.Equal(series.Days[0].Date) {
+		return fmt.Errorf("series: mismatch on start date:%s in:%s", d.Days[0].Date, series.Days[0].Date)
+	}
 
-	// Now add this dataset on top of ours using MergeDay
-	for i, day := range d.Days {
+	// Now add this dataset on top of ours kind by kind, a column at a time
+	// we silently ignore days in series beyond the end of ours
+	n := len(series.Days)
+	if n > len(d.Days) {
+		n = len(d.Days)
+	}
+	previous := d.PreviousDay != nil && series.PreviousDay != nil
+	for _, kind := range d.settings().kinds {
+		d.columnFor(kind).merge(series.column(kind), n, previous)
+	}
 
-		// Fill in the value on each day from values
-		// if dates don't match an error will be returned
-		// check we have this day first in series - we silenty ignore too many days
-		if i < len(series.Days) {
-			err := day.MergeDay(series.Days[i])
-			if err != nil {
-				return fmt.Errorf("series: failed to add day:%v error:%s", day, err)
-			}
-		} else {
-			//	log.Printf("days overflow on series:%s", series)
+	// Flags are set on few days, so only days flagged in series are written
+	for i, day := range series.Days[:n] {
+		if day.Provisional || day.Interpolated {
+			out := d.Days[i]
+			out.Provisional = out.Provisional || day.Provisional
+			out.Interpolated = out.Interpolated || day.Interpolated
 		}
-
 	}
 
 	return nil
 }
 
 // AddDays adds the given number of days to the end of our series
+// days are allocated in one block to avoid many small allocations, and have zero values
 func (d *Data) AddDays(count int) {
-	// Get the last day (if any), and start a day after, otherwise start afresh
-	date := seriesStartDate
+	if count <= 0 {
+		return
+	}
+
+	// Get the last day (if any), and start a day after, otherwise start after the previous day or afresh
+	date := d.settings().config.StartDate
 	if len(d.Days) > 0 {
 		date = d.LastDay().Date.AddDate(0, 0, 1)
+	} else if d.PreviousDay != nil {
+		date = d.PreviousDay.Date.AddDate(0, 0, 1)
 	}
 
-	for i := 0; i < count; i++ {
-		day := &Day{
-			Date: date,
-		}
-		d.Days = append(d.Days, day)
+	block := make([]Day, count)
+	for i := range block {
+		block[i].Date = date
 		date = date.AddDate(0, 0, 1)
 	}
+	d.appendDays(block)
+}
+
+// AddDaysFrom adds count days with zero values starting on date to a series which has no days
+// it is used to restore series which start on a date other than the series start date
+func (d *Data) AddDaysFrom(date time.Time, count int) error {
+	if len(d.Days) > 0 {
+		return fmt.Errorf("series: days already added from:%s", d.FirstDay().Date)
+	}
+	block := make([]Day, count)
+	for i := range block {
+		block[i].Date = date.AddDate(0, 0, i)
+	}
+	d.appendDays(block)
+	return nil
+}
+
+// appendDays adds the days in block to the end of our series, with zero values
+func (d *Data) appendDays(block []Day) {
+	// Grow the slice once to fit all days
+	if cap(d.Days)-len(d.Days) < len(block) {
+		days := make([]*Day, len(d.Days), len(d.Days)+len(block))
+		copy(days, d.Days)
+		d.Days = days
+	}
+
+	for i := range block {
+		block[i].series = d
+		block[i].index = len(d.Days)
+		d.Days = append(d.Days, &block[i])
+	}
+	for _, c := range d.columns {
+		if c != nil {
+			c.grow(len(block))
+		}
+	}
+}
+
+// setDays replaces the days of our series with those in block, attached to the columns of the series
+// the columns must already hold the values of the days
+func (d *Data) setDays(block []Day) {
+	d.Days = make([]*Day, len(block))
+	for i := range block {
+		block[i].series = d
+		block[i].index = i
+		d.Days[i] = &block[i]
+	}
+	if d.PreviousDay != nil {
+		d.PreviousDay.series = d
+		d.PreviousDay.index = -1
+	}
+}
+
+// dropDays removes the first n days of our series, the last day removed becomes the previous day
+// days kept are copied so that the memory of the days removed is released
+func (d *Data) dropDays(n int) {
+	block := make([]Day, len(d.Days)-n)
+	for i, day := range d.Days[n:] {
+		block[i] = *day
+	}
+	for kind, c := range d.columns {
+		if c != nil {
+			d.columns[kind] = c.slice(n, len(d.Days))
+		}
+	}
+	d.setPreviousDay(d.Days[n-1].Date)
+	d.setDays(block)
+}
+
+// prependDays adds the days of series before the days of our series, and its previous day becomes ours
+// the days of series should end on the day before our first day
+func (d *Data) prependDays(series *Data) {
+	block := make([]Day, 0, len(series.Days)+len(d.Days))
+	for _, day := range series.Days {
+		block = append(block, *day)
+	}
+	for _, day := range d.Days {
+		block = append(block, *day)
+	}
+
+	kinds := append(series.kinds(), d.kinds()...)
+	columns := make([]*column, len(d.columns))
+	for _, kind := range kinds {
+		for len(columns) <= kind {
+			columns = append(columns, nil)
+		}
+		if columns[kind] != nil {
+			continue
+		}
+		before, after := series.column(kind), d.column(kind)
+		if before == nil {
+			before = newColumn(len(series.Days))
+		}
+		if after == nil {
+			after = newColumn(len(d.Days))
+		}
+		columns[kind] = before.concat(after)
+	}
+	d.columns = columns
+
+	d.PreviousDay = nil
+	if series.PreviousDay != nil {
+		d.setPreviousDay(series.PreviousDay.Date)
+	}
+	d.setDays(block)
+}
+
+// setPreviousDay sets the previous day of our series to a day on date, with the previous values of the columns
+func (d *Data) setPreviousDay(date time.Time) {
+	d.PreviousDay = &Day{Date: date, series: d, index: -1}
 }
 
 // AddToday adds a day, but sets the data to that of the last day
@@ -653,47 +960,50 @@ func (d *Data) AddToday() {
 	// Get data for the last day, change the date, but use other data unchanged
 	// this will be updated throughout the day as more data comes in
 	lastDay := d.LastDay()
-	day := &Day{
-		Date:      lastDay.Date.AddDate(0, 0, 1),
-		Deaths:    lastDay.Deaths,
-		Confirmed: lastDay.Confirmed,
-		Recovered: lastDay.Recovered,
-		Tested:    lastDay.Tested,
+	d.appendDays([]Day{{
+		Date:        lastDay.Date.AddDate(0, 0, 1),
+		Variants:    lastDay.Variants,
+		Provisional: true,
+	}})
+	for _, kind := range d.kinds() {
+		d.LastDay().SetData(kind, lastDay.Value(kind))
 	}
-	d.Days = append(d.Days, day)
 }
 
-// UpdateToday updates today's values only if lower than the values given
-// it also updates the date
-func (d *Data) UpdateToday(updated time.Time, deaths, confirmed, recovered, tested int) {
+// UpdateToday updates today's values from the source given, see updateValue for the rules used
+// it also updates the date, and records the source of any values changed
+func (d *Data) UpdateToday(source Source, updated time.Time, deaths, confirmed, recovered, tested int) {
 	d.UpdatedAt = updated
 
 	today := d.LastDay()
-
-	if today.Deaths < deaths {
-		today.Deaths = deaths
-	}
-	if today.Confirmed < confirmed {
-		today.Confirmed = confirmed
-	}
-	if today.Recovered < recovered {
-		today.Recovered = recovered
-	}
-	if today.Tested < tested {
-		today.Tested = tested
-	}
+	d.updateValue(today, DataDeaths, deaths, source)
+	d.updateValue(today, DataConfirmed, confirmed, source)
+	d.updateValue(today, DataRecovered, recovered, source)
+	d.updateValue(today, DataTested, tested, source)
 }
 
 // ResetDays clears all days stored for this time series
+// days are reset in place from the series start date, or the day after the previous day if days are archived
 func (d *Data) ResetDays() {
-	count := len(d.Days)
-	d.Days = []*Day{}
-	d.AddDays(count)
+	date := d.settings().config.StartDate
+	if d.PreviousDay != nil {
+		d.setPreviousDay(d.PreviousDay.Date)
+		date = d.PreviousDay.Date.AddDate(0, 0, 1)
+	}
+	for i, day := range d.Days {
+		*day = Day{Date: date, series: d, index: i}
+		date = date.AddDate(0, 0, 1)
+	}
+	for _, c := range d.columns {
+		if c != nil {
+			c.reset()
+		}
+	}
 }
 
 // FIXME - I think this won't be required
 
-// AddDay adds a day to this series
+// AddDay adds a day to this series with the values of COVID-19 sources, which publish these metrics
 // an error is returned if the date is not at the end of the series
 func (d *Data) AddDay(date time.Time, deaths, confirmed, recovered, tested int) error {
 	// Check data is valid
@@ -706,21 +1016,61 @@ func (d *Data) AddDay(date time.Time, deaths, confirmed, recovered, tested int)
 		if !d.LastDay().Date.Before(date) {
 			return fmt.Errorf("series: invalid date added")
 		}
+
+		// Dates skipped are added without values, so that there is one day per date, see Interpolate
+		d.AddDays(int(date.Sub(d.LastDay().Date).Hours()/24) - 1)
 	}
 
 	// What about updating an existing day, do we ever do that?
 	// Different function for that.
 
-	day := &Day{
-		Date:      date,
-		Deaths:    deaths,
-		Confirmed: confirmed,
-		Recovered: recovered,
-		Tested:    tested,
+	d.appendDays([]Day{{Date: date}})
+	day := d.LastDay()
+	day.SetData(DataDeaths, deaths)
+	day.SetData(DataConfirmed, confirmed)
+	day.SetData(DataRecovered, recovered)
+	day.SetData(DataTested, tested)
+	return nil
+}
+
+// Normalize sorts days by date and combines days with the same date using MaxDay
+// so that calculations on consecutive days are not thrown by out of order or repeated rows
+// the number of duplicate days removed is returned
+func (d *Data) Normalize() int {
+	order := make([]int, len(d.Days))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return d.Days[order[i]].Date.Before(d.Days[order[j]].Date)
+	})
+
+	// Duplicates are combined into the first day on their date before the days kept are gathered in order
+	kept := order[:0]
+	for _, i := range order {
+		if len(kept) > 0 && d.Days[kept[len(kept)-1]].Date.Equal(d.Days[i].Date) {
+			d.Days[kept[len(kept)-1]].MaxDay(d.Days[i])
+			continue
+		}
+		kept = append(kept, i)
 	}
+	removed := len(d.Days) - len(kept)
+	d.gatherDays(kept)
+	return removed
+}
 
-	d.Days = append(d.Days, day)
-	return nil
+// gatherDays replaces the days of our series with the days at indexes given, in the order given
+func (d *Data) gatherDays(indexes []int) {
+	block := make([]Day, len(indexes))
+	for i, index := range indexes {
+		block[i] = *d.Days[index]
+	}
+	for kind, c := range d.columns {
+		if c != nil {
+			d.columns[kind] = c.gather(indexes)
+		}
+	}
+	d.setDays(block)
 }
 
 // ShouldIncludeInGlobal returns true if this series should be added to global
@@ -729,11 +1079,9 @@ func (d *Data) ShouldIncludeInGlobal() bool {
 		return false
 	}
 
-	// Exclude our extra series from global
-	if d.IsCountry() {
-		if d.Country == "China" || d.Country == "Australia" || d.Country == "Canada" {
-			return false
-		}
+	// Exclude rollups of provinces from global
+	if d.Rollup {
+		return false
 	}
 
 	// Exclude US provinces from totals as we have a global entry
@@ -2,12 +2,20 @@ package series
 
 import (
 	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 )
 
+// startDate is the first day of series outside a store
+var startDate = defaults.config.StartDate
+
 var formatTests = map[int]string{
 	10:       "10",
 	999:      "999",
@@ -33,6 +41,47 @@ func TestFormat(t *testing.T) {
 
 }
 
+func TestLocale(t *testing.T) {
+	de := LocaleForLanguage("de-AT")
+	if de.Tag != "de" || de.Format(22400499) != "22,4 Mio." || de.Format(10101) != "10,1 Tsd." {
+		t.Errorf("locale: wrong abbreviated format got:%s %s", de.Format(22400499), de.Format(10101))
+	}
+	if de.FormatFull(1234567) != "1.234.567" || de.FormatFloat(-1234.5, 2) != "-1.234,50" {
+		t.Errorf("locale: wrong full format got:%s %s", de.FormatFull(1234567), de.FormatFloat(-1234.5, 2))
+	}
+
+	// Unknown languages use the default locale
+	en := LocaleForLanguage("xx")
+	if en.Tag != "en" || en.FormatFull(999) != "999" || en.FormatFull(1000) != "1,000" || en.FormatFloat(-0.001, 1) != "0.0" {
+		t.Errorf("locale: wrong default format got:%s %s", en.FormatFull(1000), en.FormatFloat(-0.001, 1))
+	}
+}
+
+func TestFormatter(t *testing.T) {
+	tests := []struct {
+		f     Formatter
+		value int
+		want  string
+	}{
+		{Formatter{Locale: DefaultLocale}, -22400499, "-22.4m"},
+		{Formatter{Locale: DefaultLocale, Full: true}, 22400499, "22,400,499"},
+		{Formatter{Locale: DefaultLocale, Decimals: 2}, 22400499, "22.40m"},
+		{Formatter{Locale: DefaultLocale, Sign: true}, 523, "+523"},
+		{Formatter{Locale: DefaultLocale, Sign: true, Full: true}, -12345, "-12,345"},
+		{Formatter{Locale: DefaultLocale, Sign: true}, 0, "0"},
+	}
+	for _, test := range tests {
+		if got := test.f.Format(test.value); got != test.want {
+			t.Errorf("formatter: wrong format for:%d want:%s got:%s", test.value, test.want, got)
+		}
+	}
+
+	f := Formatter{Locale: LocaleForLanguage("fr"), Decimals: 1, Sign: true}
+	if got := f.FormatFloat(1234.56); got != "+1\u00a0234,6" {
+		t.Errorf("formatter: wrong float format got:%s", got)
+	}
+}
+
 // Test parse of UK json
 func TestUKJSON(t *testing.T) {
 
@@ -77,9 +126,9 @@ func TestUKJSONAssign(t *testing.T) {
 	}
 
 	// Add days up to today so that we can update them
-	days := int(time.Now().UTC().Sub(seriesStartDate).Hours() / 24)
+	days := int(time.Now().UTC().Sub(startDate).Hours() / 24)
 	// For every series add the right number of days up to and including today
-	for _, series := range dataset {
+	for _, series := range std.dataset {
 		series.AddDays(days)
 	}
 
@@ -96,13 +145,13 @@ func TestUKJSONAssign(t *testing.T) {
 		t.Fatalf("json parse err:%s", err)
 	}
 
-	err = UpdateFromUKStats(jsonData)
+	err = std.dataset.UpdateFromUKStats(jsonData)
 	if err != nil {
 		t.Fatalf("failed to update from UK json:%s", err)
 	}
 
 	// Test fetch of wales and value
-	wales, err := dataset.FetchSeries("United Kingdom", "Wales")
+	wales, err := std.dataset.FetchSeries("United Kingdom", "Wales")
 	if err != nil {
 		t.Fatalf("failed to fetch wales:%s", err)
 	}
@@ -121,12 +170,12 @@ func TestLoadAreas(t *testing.T) {
 	}
 
 	count := 8
-	if len(dataset) != count {
-		t.Fatalf("areas: count wrong want:%d got:%d", count, len(dataset))
+	if len(std.dataset) != count {
+		t.Fatalf("areas: count wrong want:%d got:%d", count, len(std.dataset))
 	}
 
 	// Fetch global, should not fail
-	global, err := dataset.FetchSeries("", "")
+	global, err := std.dataset.FetchSeries("", "")
 	if err != nil {
 		t.Fatalf("areas: global not in dataset: s:%v", global)
 	}
@@ -136,9 +185,9 @@ func TestLoadAreas(t *testing.T) {
 	}
 
 	// Fetch US, should not fail
-	us, err := dataset.FetchSeries("US", "")
+	us, err := std.dataset.FetchSeries("US", "")
 	if err != nil {
-		t.Fatalf("areas: failed to load us:%s %v", err, dataset)
+		t.Fatalf("areas: failed to load us:%s %v", err, std.dataset)
 	}
 
 	if us.Country != "US" || us.Province != "" {
@@ -155,9 +204,9 @@ func TestLoadAreas(t *testing.T) {
 
 	// Fetch UK province
 	// United Kingdom,England,54,-2.0,55977178,#201234
-	england, err := dataset.FetchSeries("United Kingdom", "England")
+	england, err := std.dataset.FetchSeries("United Kingdom", "England")
 	if err != nil {
-		t.Fatalf("areas: failed to load england,uk:%s %v", err, dataset)
+		t.Fatalf("areas: failed to load england,uk:%s %v", err, std.dataset)
 	}
 
 	if england.Longitude != -2.0 {
@@ -168,9 +217,9 @@ func TestLoadAreas(t *testing.T) {
 		t.Fatalf("areas: failed to load England color got:%s", england.Color)
 	}
 
-	venezuela, err := dataset.FetchSeries("Venezuela", "")
+	venezuela, err := std.dataset.FetchSeries("Venezuela", "")
 	if err != nil {
-		t.Fatalf("areas: failed to load venezuela:%s %v", err, dataset)
+		t.Fatalf("areas: failed to load venezuela:%s %v", err, std.dataset)
 	}
 
 	if venezuela.Population != 32219521 {
@@ -192,7 +241,7 @@ func TestLoadSeries(t *testing.T) {
 	}
 
 	// Fetch UK, should not fail
-	uk, err := dataset.FetchSeries("United Kingdom", "")
+	uk, err := std.dataset.FetchSeries("United Kingdom", "")
 	if err != nil {
 		t.Fatalf("series: uk not in dataset: s:%v", uk)
 	}
@@ -205,7 +254,7 @@ func TestLoadSeries(t *testing.T) {
 		t.Fatalf("series: uk deaths incorrect on date:%v want:%d got:%d", date, want, deaths)
 	}
 
-	t.Logf("dataset:%v", dataset)
+	t.Logf("dataset:%v", std.dataset)
 
 	date = time.Date(2020, 3, 26, 0, 0, 0, 0, time.UTC)
 	deaths = uk.FetchDate(date, DataDeaths)
@@ -222,7 +271,7 @@ func TestLoadSeries(t *testing.T) {
 	}
 
 	// Test US
-	us, err := dataset.FetchSeries("US", "")
+	us, err := std.dataset.FetchSeries("US", "")
 	if err != nil {
 		t.Fatalf("series: us not in dataset: s:%v", uk)
 	}
@@ -235,7 +284,7 @@ func TestLoadSeries(t *testing.T) {
 	}
 
 	// Test Wyoming, fake test data inserted
-	wyoming, err := dataset.FetchSeries("US", "Wyoming")
+	wyoming, err := std.dataset.FetchSeries("US", "Wyoming")
 	if err != nil {
 		t.Fatalf("series: us not in dataset: s:%v", uk)
 	}
@@ -252,9 +301,2122 @@ func TestLoadSeries(t *testing.T) {
 // Source serious is missing a view overall series including global
 /*
 	// Fetch global, should not fail
-	global, err := dataset.FetchSeries("", "")
+	global, err := std.dataset.FetchSeries("", "")
 	if err != nil {
 		t.Fatalf("areas: global not in dataset: s:%v", global)
 	}
 
 */
+
+// TestPeriod tests truncating a series with Period and PeriodRange
+func TestPeriod(t *testing.T) {
+	d := &Data{Country: "Testland"}
+	err := d.SetData(startDate, DataDeaths, []int{1, 2, 4, 8, 16, 32})
+	if err != nil {
+		t.Fatalf("period: failed to set data:%s", err)
+	}
+
+	p := d.Period(3)
+	if p.Count() != 3 {
+		t.Fatalf("period: count wrong want:%d got:%d", 3, p.Count())
+	}
+	if p.FirstDay().Value(DataDeaths) != 8 || p.DeathsDaily()[0] != 4 {
+		t.Errorf("period: first day wrong got:%v daily:%v", p.FirstDay(), p.DeathsDaily())
+	}
+
+	// Changes to the period should not affect the original series
+	p.LastDay().SetData(DataDeaths, 99)
+	if d.LastDay().Value(DataDeaths) != 32 {
+		t.Errorf("period: original series modified got:%d", d.LastDay().Value(DataDeaths))
+	}
+
+	if d.Period(10).Count() != 6 {
+		t.Errorf("period: full period count wrong got:%d", d.Period(10).Count())
+	}
+
+	from := startDate.AddDate(0, 0, 1)
+	to := startDate.AddDate(0, 0, 3)
+	r := d.PeriodRange(from, to)
+	if r.Count() != 3 || !r.FirstDay().Date.Equal(from) || !r.LastDay().Date.Equal(to) {
+		t.Errorf("period: range wrong got:%v", r.Days)
+	}
+
+	r = d.PeriodRange(to, from)
+	if r.Count() != 0 {
+		t.Errorf("period: inverted range should be empty got:%v", r.Days)
+	}
+}
+
+// TestRollupProvinces tests summing provinces into a rollup country series
+func TestRollupProvinces(t *testing.T) {
+	country := &Data{Country: "Canada", Rollup: true}
+	country.AddDays(3)
+	a := &Data{Country: "Canada", Province: "Ontario"}
+	a.SetData(startDate, DataDeaths, []int{1, 2, 3})
+	b := &Data{Country: "Canada", Province: "Quebec"}
+	b.SetData(startDate, DataDeaths, []int{10, 20, 30})
+	other := &Data{Country: "France", Province: "Reunion"}
+	other.SetData(startDate, DataDeaths, []int{100, 200, 300})
+
+	slice := Slice{country, a, b, other}
+	err := slice.RollupProvinces()
+	if err != nil {
+		t.Fatalf("rollup: failed:%s", err)
+	}
+
+	if country.LastDay().Value(DataDeaths) != 33 {
+		t.Errorf("rollup: deaths wrong want:%d got:%d", 33, country.LastDay().Value(DataDeaths))
+	}
+
+	// Running again should not double count
+	slice.RollupProvinces()
+	if country.LastDay().Value(DataDeaths) != 33 {
+		t.Errorf("rollup: deaths wrong on second pass want:%d got:%d", 33, country.LastDay().Value(DataDeaths))
+	}
+
+	if country.ShouldIncludeInGlobal() {
+		t.Errorf("rollup: rollup should not be included in global")
+	}
+}
+
+// benchmarkSlice returns a slice of n province series each with days of data
+func benchmarkSlice(n, days int) Slice {
+	values := make([]int, days)
+	for i := range values {
+		values[i] = i * 10
+	}
+	var slice Slice
+	for i := 0; i < n; i++ {
+		s := &Data{ID: i + 1, Country: "Benchmark", Province: strconv.Itoa(i)}
+		s.SetData(startDate, DataDeaths, values)
+		s.SetData(startDate, DataConfirmed, values)
+		slice = append(slice, s)
+	}
+	return slice
+}
+
+// BenchmarkMergeSeries benchmarks a full rebuild of a series from many others
+func BenchmarkMergeSeries(b *testing.B) {
+	slice := benchmarkSlice(500, 365)
+	total := &Data{}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		total.ResetDays()
+		for _, s := range slice {
+			err := total.MergeSeries(s)
+			if err != nil {
+				b.Fatalf("merge: failed:%s", err)
+			}
+		}
+	}
+}
+
+// TestRank tests ranking series by total and per capita values, leaving out areas of unknown population
+func TestRank(t *testing.T) {
+	big := &Data{Country: "Big", Population: 10000000, AreaKm2: 1000}
+	big.SetData(startDate, DataDeaths, []int{100, 200})
+	small := &Data{Country: "Small", Population: 100000}
+	small.SetData(startDate, DataDeaths, []int{10, 20})
+	unknown := &Data{Country: "Unknown"}
+	unknown.SetData(startDate, DataDeaths, []int{1, 2})
+
+	slice := Slice{unknown, small, big}
+	ranked := slice.Rank(DataDeaths, false)
+	if len(ranked) != 3 || ranked[0] != big || ranked[2] != unknown {
+		t.Errorf("rank: order wrong got:%v", ranked)
+	}
+
+	// Per capita small has 20 per 100k and big 2 per 100k, unknown population is excluded
+	ranked = slice.Rank(DataDeaths, true)
+	if len(ranked) != 2 || ranked[0] != small || ranked[1] != big {
+		t.Errorf("rank: per capita order wrong got:%v", ranked)
+	}
+
+	if big.Density() != 10000 || small.Density() != 0 {
+		t.Errorf("rank: density wrong got:%f %f", big.Density(), small.Density())
+	}
+}
+
+func TestRanks(t *testing.T) {
+	// Rising overtakes Steady and Level over the ten days, Level ties with Steady on the last day
+	rising := &Data{ID: 1, Country: "Rising"}
+	rising.SetData(startDate, DataDeaths, []int{1, 2, 4, 8, 16, 32, 64, 128, 256, 512})
+	steady := &Data{ID: 2, Country: "Steady"}
+	steady.SetData(startDate, DataDeaths, []int{100, 110, 120, 130, 140, 150, 160, 170, 180, 190})
+	level := &Data{ID: 3, Country: "Level"}
+	level.SetData(startDate, DataDeaths, []int{0, 0, 0, 190, 190, 190, 190, 190, 190, 190})
+
+	st := NewStore(StoreConfig{})
+	st.dataset = Slice{rising, steady, level}
+
+	// The first run fills the snapshots of the week before the last day
+	if added := st.RecordRanks(); added != RankChangeDays+1 {
+		t.Fatalf("ranks: wrong snapshots added got:%d", added)
+	}
+	rankings := st.Rankings(DataDeaths)
+	if len(rankings) != 3 || rankings[0].AreaID != 1 || rankings[1].Rank != 2 || rankings[2].Rank != 2 {
+		t.Fatalf("ranks: wrong rankings got:%v", rankings)
+	}
+	if r := rankings[0]; r.Previous != 2 || r.Change != 1 || r.Movement() != "up 1" || !r.Since.Equal(startDate.AddDate(0, 0, 2)) {
+		t.Errorf("ranks: wrong change for rising got:%v", r)
+	}
+	if r := st.AreaRankings(3); len(r) != 1 || r[0].Previous != 0 || r[0].Movement() != "new" {
+		t.Errorf("ranks: wrong change for level got:%v", r)
+	}
+
+	// Snapshots before the last day are kept as recorded, the last day is replaced
+	steady.Days[8].SetData(DataDeaths, 1000)
+	steady.LastDay().SetData(DataDeaths, 1000)
+	if added := st.RecordRanks(); added != 0 || st.AreaRankings(2)[0].Rank != 1 || st.ranks[len(st.ranks)-2].Ranks["deaths"][2] != 3 {
+		t.Errorf("ranks: wrong snapshots after revision got:%d %v", added, st.ranks)
+	}
+
+	// Snapshots are saved and loaded with the ranks unchanged
+	dir, err := ioutil.TempDir("", "ranks")
+	if err != nil {
+		t.Fatalf("ranks: failed to create dir:%s", err)
+	}
+	defer os.RemoveAll(dir)
+	p := filepath.Join(dir, "ranks.json")
+	err = st.SaveRanks(p)
+	if err != nil {
+		t.Fatalf("ranks: failed to save:%s", err)
+	}
+	loaded := NewStore(StoreConfig{})
+	err = loaded.LoadRanks(p)
+	if err != nil {
+		t.Fatalf("ranks: failed to load:%s", err)
+	}
+	got, want := loaded.Rankings(DataDeaths), st.Rankings(DataDeaths)
+	for i := range want {
+		if len(got) != len(want) || got[i].Rank != want[i].Rank || got[i].Previous != want[i].Previous || !got[i].Since.Equal(want[i].Since) {
+			t.Fatalf("ranks: wrong rankings loaded got:%v want:%v", got, want)
+		}
+	}
+}
+
+func TestBacktest(t *testing.T) {
+	values := make([]int, 20)
+	for i := range values {
+		values[i] = 1 << uint(i)
+	}
+	s := &Data{ID: 1, Country: "Doubling"}
+	s.SetData(startDate, DataDeaths, values)
+
+	// Steady growth is forecast exactly by the growth model
+	score := s.Backtest(DataDeaths, 3, 8, GrowthForecast)
+	if score.Points != 30 || score.MAE > 0.001 || score.MAPE > 0.001 {
+		t.Errorf("backtest: wrong score for steady growth got:%v", score)
+	}
+
+	// When growth stops the forecasts overshoot
+	flat := &Data{ID: 2, Country: "Flat"}
+	flat.SetData(startDate, DataDeaths, append(values[:12:12], 2048, 2048, 2048, 2048))
+	scores := Slice{flat, s}.Backtest(DataDeaths, 3, 8, GrowthForecast)
+	if len(scores) != 2 || scores[0].AreaID != 1 || scores[1].MAPE <= 0 {
+		t.Errorf("backtest: wrong scores got:%v", scores)
+	}
+}
+
+func TestForecast(t *testing.T) {
+	s := &Data{}
+	values := make([]int, 40)
+	daily := 10.0
+	for i := range values {
+		daily *= 1.1
+		values[i] = int(daily)
+		if i > 0 {
+			values[i] += values[i-1]
+		}
+	}
+	s.SetData(startDate, DataDeaths, values)
+
+	projections := s.Forecast(DataDeaths, 5)
+	if len(projections) != 5 || !projections[0].Date.Equal(startDate.AddDate(0, 0, 40)) {
+		t.Fatalf("forecast: wrong projections got:%v", projections)
+	}
+	last := float64(values[39])
+	for _, p := range projections {
+		if p.Value <= last || p.Low > p.Value || p.High < p.Value {
+			t.Errorf("forecast: wrong projection got:%v", p)
+		}
+		last = p.Value
+	}
+
+	// Growth continues at about 10% a day
+	growth := (projections[1].Value - projections[0].Value) / (projections[0].Value - float64(values[39]))
+	if growth < 1.09 || growth > 1.11 {
+		t.Errorf("forecast: wrong growth got:%f", growth)
+	}
+
+	if s.Period(10).Forecast(DataDeaths, 5) != nil {
+		t.Errorf("forecast: short series should not be forecast")
+	}
+}
+
+func TestPeaks(t *testing.T) {
+	// Daily deaths rise to 50 on day 10, fall, then rise to a smaller peak of 30 on day 30
+	var daily []int
+	for i := 0; i < 45; i++ {
+		switch {
+		case i <= 10:
+			daily = append(daily, i*5)
+		case i <= 20:
+			daily = append(daily, 50-(i-10)*4)
+		case i <= 30:
+			daily = append(daily, 10+(i-20)*2)
+		default:
+			daily = append(daily, 30-(i-30))
+		}
+	}
+	values := make([]int, len(daily))
+	for i, v := range daily {
+		values[i] = v
+		if i > 0 {
+			values[i] += values[i-1]
+		}
+	}
+	s := &Data{}
+	s.SetData(startDate, DataDeaths, values)
+
+	peaks := s.Peaks(DataDeaths)
+	if len(peaks) != 2 || peaks[0].Value <= peaks[1].Value {
+		t.Fatalf("peaks: wrong peaks got:%v", peaks)
+	}
+	if s.HighestPeak(DataDeaths).Date != peaks[0].Date || peaks[1].Date.Before(startDate.AddDate(0, 0, 30)) {
+		t.Errorf("peaks: wrong peak dates got:%v", peaks)
+	}
+
+	// Series which are still rising have no peaks
+	rising := &Data{}
+	rising.SetData(startDate, DataDeaths, values[:11])
+	if rising.HighestPeak(DataDeaths) != nil {
+		t.Errorf("peaks: rising series has peak got:%v", rising.Peaks(DataDeaths))
+	}
+}
+
+func TestWaves(t *testing.T) {
+	// Two waves of cases peaking on days 10 and 40, with a small bump on day 45 in the second
+	var daily []int
+	for i := 0; i < 60; i++ {
+		switch {
+		case i <= 10:
+			daily = append(daily, i*10)
+		case i <= 25:
+			daily = append(daily, 100-(i-10)*6)
+		case i <= 40:
+			daily = append(daily, 10+(i-25)*4)
+		case i <= 43:
+			daily = append(daily, 60)
+		case i <= 46:
+			daily = append(daily, 65)
+		default:
+			daily = append(daily, 40)
+		}
+	}
+	confirmed := make([]int, len(daily))
+	deaths := make([]int, len(daily))
+	for i, v := range daily {
+		confirmed[i], deaths[i] = v, 1
+		if i > 0 {
+			confirmed[i] += confirmed[i-1]
+			deaths[i] += deaths[i-1]
+		}
+	}
+	s := &Data{}
+	s.SetData(startDate, DataConfirmed, confirmed)
+	s.SetData(startDate, DataDeaths, deaths)
+
+	waves := s.Waves()
+	if len(waves) != 2 {
+		t.Fatalf("waves: wrong count got:%v", waves)
+	}
+	first, second := waves[0], waves[1]
+	if first.Days+second.Days != 59 || first.Deaths != first.Days || second.Confirmed+first.Confirmed != confirmed[59] {
+		t.Errorf("waves: wrong totals got:%v", waves)
+	}
+	if first.PeakConfirmed <= second.PeakConfirmed || !second.Start.After(first.PeakDate) || !first.End.Before(second.PeakDate) {
+		t.Errorf("waves: wrong peaks got:%v", waves)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	s := &Data{Country: "Testland", Latitude: 10, Longitude: 20, Population: 1000}
+	s.SetData(startDate, DataDeaths, []int{1, 2, 3, 5})
+	if errors := s.Validate(); len(errors) > 0 {
+		t.Errorf("validate: unexpected errors:%v", errors)
+	}
+	if !s.Valid() {
+		t.Errorf("validate: series with days not valid")
+	}
+
+	// Falling totals, a missing day, no coordinates and no population
+	s.Days[2].SetData(DataDeaths, 0)
+	s.Days[3].Date = s.Days[3].Date.AddDate(0, 0, 1)
+	s.Latitude, s.Longitude, s.Population = 0, 0, 0
+	if errors := s.Validate(); len(errors) != 4 {
+		t.Errorf("validate: wrong errors want:4 got:%d %v", len(errors), errors)
+	}
+
+	if errors := (&Data{Latitude: 1, Population: 1}).Validate(); len(errors) != 1 {
+		t.Errorf("validate: wrong errors for empty series:%v", errors)
+	}
+}
+
+// TestColumns tests values are read from a column per data kind without copying, and periods copy their columns
+func TestColumns(t *testing.T) {
+	s := &Data{}
+	s.SetData(startDate, DataDeaths, []int{1, 3, 6, 10})
+	s.SetData(startDate, DataConfirmed, []int{5, 8, 20, 40})
+
+	p := s.Period(3)
+	if p.Count() != 3 || !p.LastDay().Date.Equal(startDate.AddDate(0, 0, 3)) || p.PreviousDay.Value(DataDeaths) != 1 {
+		t.Fatalf("columns: wrong days got:%d %s", p.Count(), p.LastDay())
+	}
+	if p.Deaths()[2] != 10 || p.Values(DataConfirmed)[0] != 8 || p.Days[1].Value(DataDeaths) != 6 || p.Days[1].Value(DataNone) != 0 {
+		t.Errorf("columns: wrong values got:%v %v", p.Deaths(), p.Confirmed())
+	}
+	daily := p.DeathsDaily()
+	if daily[0] != 2 || daily[2] != 4 || p.ConfirmedDaily()[1] != 12 {
+		t.Errorf("columns: wrong daily values got:%v", daily)
+	}
+
+	// Daily values are the column of the series, so a change to a day is seen in them
+	// but not in cumulative values read before, or in a period copied before
+	deaths, daily := s.Deaths(), s.DeathsDaily()
+	s.Days[3].SetData(DataDeaths, 12)
+	if deaths[3] != 10 || daily[3] != 6 || s.Deaths()[3] != 12 || p.Deaths()[2] != 10 {
+		t.Errorf("columns: wrong values after change got:%v %v %v", deaths, daily, p.Deaths())
+	}
+	s.Days[1].SetData(DataDeaths, 4)
+	if fmt.Sprint(s.Deaths()) != "[1 4 6 12]" || fmt.Sprint(daily) != "[1 3 2 6]" {
+		t.Errorf("columns: wrong values after change got:%v %v", s.Deaths(), daily)
+	}
+	if s.DeathsDaily()[0] != 1 || len(s.Tested()) != 4 || (&Data{}).Values(DataDeaths) != nil {
+		t.Errorf("columns: wrong series values got:%v", s.DeathsDaily())
+	}
+}
+
+// TestColumnDeltas tests that values read from deltas match the values set, across checkpoints
+func TestColumnDeltas(t *testing.T) {
+	values := make([]int, 100)
+	for i := range values {
+		values[i] = i * i
+	}
+	c := columnFromValues(values, 7)
+	check := func(name string, c *column, want []int) {
+		t.Helper()
+		for i, v := range want {
+			if c.value(i) != v {
+				t.Fatalf("columns: %s wrong value on day:%d want:%d got:%d", name, i, v, c.value(i))
+			}
+		}
+		if fmt.Sprint(c.cumulative()) != fmt.Sprint(want) {
+			t.Fatalf("columns: %s wrong cumulative values got:%v", name, c.cumulative())
+		}
+	}
+	check("from values", c, values)
+
+	c.set(64, 1)
+	c.set(70, 3)
+	c.set(-1, 2)
+	values[64], values[70] = 1, 3
+	check("set", c, values)
+	if c.Deltas[0] != -2 || c.Deltas[65] != 65*65-1 {
+		t.Errorf("columns: wrong deltas after set got:%d %d", c.Deltas[0], c.Deltas[65])
+	}
+
+	c.setDaily(33, 10)
+	change := 10 - (33*33 - 32*32)
+	for i := 33; i < len(values); i++ {
+		values[i] += change
+	}
+	check("set daily", c, values)
+
+	c.grow(40)
+	values = append(values, make([]int, 40)...)
+	check("grow", c, values)
+
+	s := c.slice(30, 90)
+	check("slice", s, values[30:90])
+	check("concat", s.concat(c.slice(90, 140)), values[30:140])
+
+	in := columnFromValues(values[:50], 5)
+	c.merge(in, 50, false)
+	for i := range values[:50] {
+		values[i] *= 2
+	}
+	check("merge", c, values)
+
+	c.apply([]int{1, 2, 3}, false)
+	copy(values, []int{1, 2, 3})
+	check("apply", c, values)
+}
+
+func TestMemo(t *testing.T) {
+	s := &Data{stats: newStats()}
+	s.SetData(startDate, DataDeaths, []int{1, 2, 4, 8})
+
+	calls := 0
+	sum := func(d *Data) func() interface{} {
+		return func() interface{} {
+			calls++
+			return d.TotalDeaths()
+		}
+	}
+	if s.Memo("sum", sum(s)) != 7 || s.Memo("sum", sum(s)) != 7 || calls != 1 {
+		t.Errorf("memo: wrong cached value calls:%d", calls)
+	}
+
+	// Copies of periods have their own values in the shared cache
+	p := s.Period(2)
+	if p.Memo("sum", sum(p)) != 4 || s.Period(2).Memo("sum", sum(p)) != 4 || calls != 2 {
+		t.Errorf("memo: wrong period value calls:%d", calls)
+	}
+
+	// Updates invalidate cached values
+	s.SetUpdated(time.Now().UTC())
+	s.LastDay().SetData(DataDeaths, 9)
+	if s.Memo("sum", sum(s)) != 8 || calls != 3 {
+		t.Errorf("memo: value not invalidated calls:%d", calls)
+	}
+	if s.DoubleDeathDays() != s.doubleDays(DataDeaths) {
+		t.Errorf("memo: wrong double death days")
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	date := startDate
+	s := &Data{}
+	s.appendDays([]Day{{Date: date.AddDate(0, 0, 2)}, {Date: date}, {Date: date.AddDate(0, 0, 1)}, {Date: date.AddDate(0, 0, 1)}})
+	for i, deaths := range []int{5, 1, 2, 3} {
+		s.Days[i].SetData(DataDeaths, deaths)
+	}
+	s.Days[2].SetData(DataConfirmed, 9)
+	s.Days[2].SetSource(DataDeaths, SourceJHU)
+	s.Days[2].SetSource(DataConfirmed, SourceJHU)
+	s.Days[3].SetData(DataConfirmed, 8)
+	s.Days[3].SetSource(DataDeaths, SourceUKGov)
+	s.Days[3].SetSource(DataConfirmed, SourceUKGov)
+	if removed := s.Normalize(); removed != 1 {
+		t.Fatalf("normalize: wrong removed want:1 got:%d", removed)
+	}
+	if len(s.Days) != 3 || !s.Days[0].Date.Equal(date) || !s.Days[2].Date.Equal(date.AddDate(0, 0, 2)) {
+		t.Fatalf("normalize: days not sorted:%v", s.Days)
+	}
+	day := s.Days[1]
+	if day.Value(DataDeaths) != 3 || day.Value(DataConfirmed) != 9 || day.Source(DataDeaths) != SourceUKGov || day.Source(DataConfirmed) != SourceJHU {
+		t.Errorf("normalize: wrong merged day:%s sources:%s", day, day.formatSources())
+	}
+}
+
+func TestTrend(t *testing.T) {
+	tests := map[string]struct {
+		daily func(i int) int
+		want  Trend
+	}{
+		"rising":       {func(i int) int { return 10 + i*2 }, TrendRising},
+		"falling":      {func(i int) int { return 100 - i*2 }, TrendFalling},
+		"plateau":      {func(i int) int { return 50 + i%2 }, TrendPlateau},
+		"insufficient": {func(i int) int { return i % 10 / 9 }, TrendInsufficient},
+	}
+	for name, test := range tests {
+		values := make([]int, 30)
+		for i := range values {
+			values[i] = test.daily(i)
+			if i > 0 {
+				values[i] += values[i-1]
+			}
+		}
+		s := &Data{}
+		s.SetData(startDate, DataDeaths, values)
+		if got := s.Trend(DataDeaths); got != test.want {
+			t.Errorf("trend: %s wrong want:%s got:%s", name, test.want, got)
+		}
+	}
+}
+
+func TestWeekdayFactors(t *testing.T) {
+	// Half of weekend reports are delayed until Monday
+	start := startDate
+	values := make([]int, 70)
+	for i := range values {
+		daily := 100
+		switch start.AddDate(0, 0, i).Weekday() {
+		case time.Saturday, time.Sunday:
+			daily = 50
+		case time.Monday:
+			daily = 200
+		}
+		values[i] = daily
+		if i > 0 {
+			values[i] += values[i-1]
+		}
+	}
+	s := &Data{}
+	s.SetData(start, DataDeaths, values)
+
+	factors := s.WeekdayFactors(DataDeaths)
+	if factors[time.Sunday] > 0.6 || factors[time.Monday] < 1.8 || math.Abs(factors[time.Wednesday]-1) > 0.1 {
+		t.Errorf("weekday: wrong factors got:%v", factors)
+	}
+	for i, v := range s.AdjustedDaily(DataDeaths)[1:] {
+		if math.Abs(v-100) > 10 {
+			t.Errorf("weekday: wrong adjusted value day:%d got:%.1f", i+1, v)
+		}
+	}
+
+	// Short series are not adjusted
+	s = &Data{}
+	s.SetData(start, DataDeaths, values[:20])
+	if s.WeekdayFactors(DataDeaths) != noWeekdayEffect {
+		t.Errorf("weekday: adjusted short series")
+	}
+}
+
+func TestWeekly(t *testing.T) {
+	// The series starts on a Wednesday, so the first week is partial
+	start := time.Date(2020, 1, 22, 0, 0, 0, 0, time.UTC)
+	if !startDate.Equal(start) {
+		t.Skipf("weekly: series start date changed:%s", startDate)
+	}
+	s := &Data{}
+	s.SetData(start, DataDeaths, []int{1, 2, 3, 4, 5, 7, 9, 11, 13, 15, 17, 19, 29})
+
+	weeks := s.Weekly(DataDeaths)
+	if len(weeks) != 3 || weeks[0].Label() != "2020-W04" || weeks[0].Days != 5 || weeks[0].Value != 5 || !weeks[0].Start.Equal(start.AddDate(0, 0, -2)) {
+		t.Fatalf("weekly: wrong first week got:%v", weeks)
+	}
+	if w := weeks[1]; w.Days != 7 || w.Value != 14 || w.Change != 9 || w.ChangePercent != 180 {
+		t.Errorf("weekly: wrong second week got:%v", w)
+	}
+	if w := weeks[2]; w.Days != 1 || w.Value != 10 || w.Change != -4 {
+		t.Errorf("weekly: wrong last week got:%v", w)
+	}
+}
+
+func TestMonthly(t *testing.T) {
+	start := time.Date(2020, 1, 22, 0, 0, 0, 0, time.UTC)
+	if !startDate.Equal(start) {
+		t.Skipf("monthly: series start date changed:%s", startDate)
+	}
+	values := make([]int, 20)
+	for i := range values {
+		values[i] = i + 1
+		if i == 15 {
+			values[i] += 10
+		}
+		if i > 0 {
+			values[i] += values[i-1]
+		}
+	}
+	s := &Data{}
+	s.SetData(start, DataDeaths, values)
+	s.SetProvisional(start.AddDate(0, 0, 19))
+
+	// January has 10 days from the 22nd, February the rest
+	months := s.Monthly(DataDeaths)
+	if len(months) != 2 || months[0].Name() != "January 2020" || months[0].Days != 10 || months[0].Total != 55 || months[0].Average != 5.5 || months[0].Provisional {
+		t.Fatalf("monthly: wrong first month got:%v", months)
+	}
+	if m := months[1]; m.Days != 10 || m.Peak != 26 || !m.PeakDate.Equal(start.AddDate(0, 0, 15)) || !m.Provisional {
+		t.Errorf("monthly: wrong second month got:%v", m)
+	}
+}
+
+func TestConvert(t *testing.T) {
+	totals := []int{5, 8, 4, 6}
+	daily := DailyFromCumulative(totals, 0)
+	if fmt.Sprint(daily) != "[5 3 -4 2]" {
+		t.Errorf("convert: wrong daily values got:%v", daily)
+	}
+	if got := CumulativeFromDaily(daily, 0); fmt.Sprint(got) != fmt.Sprint(totals) {
+		t.Errorf("convert: wrong totals got:%v", got)
+	}
+	if got := CumulativeFromDaily(DailyFromCumulative(totals, 2), 2); fmt.Sprint(got) != fmt.Sprint(totals) {
+		t.Errorf("convert: wrong totals from previous got:%v", got)
+	}
+
+	// Revisions are taken from the days before, leaving the sum unchanged
+	if got := SpreadRevisions(daily); fmt.Sprint(got) != "[4 0 0 2]" {
+		t.Errorf("convert: wrong spread revisions got:%v", got)
+	}
+	if got := SpreadRevisions([]int{1, -3, 2}); fmt.Sprint(got) != "[-2 0 2]" {
+		t.Errorf("convert: wrong spread of large revision got:%v", got)
+	}
+
+	s := &Data{}
+	s.SetData(startDate, DataDeaths, []int{1, 2, 3, 4})
+	err := s.SetDaily(startDate.AddDate(0, 0, 1), DataDeaths, []int{5})
+	if err != nil || fmt.Sprint(s.Totals(DataDeaths)) != "[1 6 7 8]" {
+		t.Errorf("convert: wrong totals after daily values err:%v got:%v", err, s.Totals(DataDeaths))
+	}
+	if err = s.SetDaily(startDate.AddDate(0, 0, 3), DataDeaths, []int{1, 1}); err == nil {
+		t.Errorf("convert: set daily values outside series")
+	}
+}
+
+func TestDownsample(t *testing.T) {
+	// The series starts on a Wednesday, so the first Sundays are days 4 and 11
+	values := make([]int, 20)
+	for i := range values {
+		values[i] = i * i
+	}
+	s := &Data{}
+	s.SetData(startDate, DataDeaths, values)
+	if s.Downsample(time.Time{}) != 0 {
+		t.Errorf("downsample: downsampled without a cutoff")
+	}
+
+	// Days from the cutoff on day 14 are kept, before it the first day and Sundays are kept
+	before := startDate.AddDate(0, 0, 14)
+	if n := s.Downsample(before); n != 11 || s.Days[2].Value(DataDeaths) != 8 || s.Days[12].Value(DataDeaths) != 146 || s.Days[11].Value(DataDeaths) != 121 {
+		t.Errorf("downsample: wrong days got:%d %v", n, s.Totals(DataDeaths))
+	}
+	if !s.Days[13].Interpolated || s.Days[11].Interpolated || s.Days[14].Interpolated || s.Days[15].Value(DataDeaths) != 225 {
+		t.Errorf("downsample: wrong days marked interpolated got:%v", s.Days)
+	}
+
+	// Days missing from the series file once saved are filled again when loaded
+	s.Days[2].SetData(DataDeaths, 0)
+	if s.Downsample(before); s.Days[2].Value(DataDeaths) != 8 {
+		t.Errorf("downsample: missing day not filled got:%d", s.Days[2].Value(DataDeaths))
+	}
+}
+
+func TestInterpolate(t *testing.T) {
+	if m, err := ParseInterpolation("Linear"); err != nil || m != InterpolateLinear || m.String() != "linear" {
+		t.Errorf("interpolate: wrong interpolation got:%s err:%v", m, err)
+	}
+	if _, err := ParseInterpolation("spline"); err == nil {
+		t.Errorf("interpolate: parsed unknown interpolation")
+	}
+
+	// Days are skipped after the first, with none at the start or end which are not filled
+	values := []int{0, 10, 0, 0, 16, 0}
+	s := &Data{}
+	s.SetData(startDate, DataDeaths, values)
+	if filled := s.Interpolate(InterpolateNone); filled != 0 {
+		t.Errorf("interpolate: filled days with none got:%d", filled)
+	}
+	if filled := s.Interpolate(InterpolateLinear); filled != 2 || fmt.Sprint(s.Totals(DataDeaths)) != "[0 10 12 14 16 0]" {
+		t.Errorf("interpolate: wrong linear values got:%d %v", filled, s.Totals(DataDeaths))
+	}
+	if !s.Days[2].Interpolated || s.Days[1].Interpolated || s.Days[5].Interpolated {
+		t.Errorf("interpolate: wrong days marked interpolated got:%v", s.Days)
+	}
+
+	s = &Data{}
+	s.SetData(startDate, DataDeaths, values)
+	if filled := s.Interpolate(InterpolateCarry); filled != 2 || fmt.Sprint(s.Totals(DataDeaths)) != "[0 10 10 10 16 0]" {
+		t.Errorf("interpolate: wrong carried values got:%d %v", filled, s.Totals(DataDeaths))
+	}
+
+	// Dates skipped when adding days are added, so days remain one per date
+	s = &Data{}
+	s.AddDay(startDate, 1, 0, 0, 0)
+	s.AddDay(startDate.AddDate(0, 0, 3), 4, 0, 0, 0)
+	if len(s.Days) != 4 || s.FetchDate(startDate.AddDate(0, 0, 3), DataDeaths) != 4 {
+		t.Errorf("interpolate: wrong days after skipped dates got:%v", s.Days)
+	}
+	if filled := s.Interpolate(InterpolateLinear); filled != 2 || s.Days[2].Value(DataDeaths) != 3 {
+		t.Errorf("interpolate: wrong values for skipped dates got:%v", s.Days)
+	}
+}
+
+func TestGaps(t *testing.T) {
+	s := &Data{ID: 2, Country: "Testland"}
+	s.SetData(startDate, DataDeaths, []int{0, 1, 2, 0, 0, 3, 3, 3, 3, 4})
+	s.SetData(startDate, DataConfirmed, []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10})
+
+	// Deaths are missing for two days, then unchanged for three
+	gaps := s.Gaps(3)
+	if len(gaps) != 2 || gaps[0].Reason != GapMissing || gaps[0].Kind != "deaths" || gaps[0].Days != 2 || !gaps[0].From.Equal(startDate.AddDate(0, 0, 3)) {
+		t.Fatalf("gaps: wrong gaps got:%v", gaps)
+	}
+	if g := gaps[1]; g.Reason != GapFrozen || g.Days != 3 || !g.To.Equal(startDate.AddDate(0, 0, 8)) {
+		t.Errorf("gaps: wrong frozen gap got:%v", g)
+	}
+	if gaps = s.Gaps(4); len(gaps) != 1 {
+		t.Errorf("gaps: wrong gaps for longer frozen days got:%v", gaps)
+	}
+
+	// Interpolated days are still missing from the source
+	s.Interpolate(InterpolateCarry)
+	if gaps = s.Gaps(4); len(gaps) != 1 || gaps[0].Days != 2 || gaps[0].Reason != GapMissing {
+		t.Errorf("gaps: wrong gaps after interpolation got:%v", gaps)
+	}
+
+	defer func(d Slice) { std.dataset = d }(std.dataset)
+	std.dataset = Slice{s}
+	if gaps = Gaps(3); len(gaps) != 2 || gaps[0].Reason != GapFrozen {
+		t.Errorf("gaps: wrong order of gaps got:%v", gaps)
+	}
+}
+
+func TestOutliers(t *testing.T) {
+	// Daily values alternate between 9 and 11, with a backlog of 100 on day 30 and a revision on day 40
+	daily := make([]int, 50)
+	for i := range daily {
+		daily[i] = 9 + 2*(i%2)
+	}
+	daily[30], daily[40] = 100, -20
+	s := &Data{}
+	s.SetData(startDate, DataConfirmed, CumulativeFromDaily(daily, 0))
+
+	outliers := s.Outliers(DataConfirmed, OutlierDeviations)
+	if len(outliers) != 2 || outliers[0].Value != 100 || outliers[0].Expected != 10 || outliers[1].Deviations > -OutlierDeviations {
+		t.Fatalf("outliers: wrong outliers got:%v", outliers)
+	}
+	flags := s.OutlierDays(DataConfirmed, OutlierDeviations)
+	if !flags[30] || !flags[40] || flags[31] {
+		t.Errorf("outliers: wrong days flagged got:%v", flags)
+	}
+	if outliers = s.Outliers(DataConfirmed, 50); len(outliers) != 0 {
+		t.Errorf("outliers: wrong outliers for large k got:%v", outliers)
+	}
+
+	// Provisional days are not flagged
+	s.SetProvisional(s.Days[40].Date)
+	if outliers = s.outliers(DataConfirmed, OutlierDeviations); len(outliers) != 1 {
+		t.Errorf("outliers: provisional day flagged got:%v", outliers)
+	}
+
+	// Averages leave out excluded days
+	averages := MovingAverageExcluding([]int{2, 4, 100, 6}, []bool{false, false, true, false}, 2)
+	if fmt.Sprint(averages) != "[2 3 4 6]" {
+		t.Errorf("outliers: wrong averages got:%v", averages)
+	}
+}
+
+func TestSmooth(t *testing.T) {
+	// A straight line is fitted exactly by loess, and a constant followed by kalman
+	line, constant := make([]float64, 30), make([]float64, 30)
+	for i := range line {
+		line[i] = float64(2*i + 1)
+		constant[i] = 10
+	}
+	for i, v := range LoessSmooth(line) {
+		if math.Abs(v-line[i]) > 1e-9 {
+			t.Fatalf("smooth: wrong loess value for line at:%d got:%g", i, v)
+		}
+	}
+	for i, v := range KalmanSmooth(constant) {
+		if math.Abs(v-10) > 1e-9 {
+			t.Fatalf("smooth: wrong kalman value for constant at:%d got:%g", i, v)
+		}
+	}
+	if len(LoessSmooth(line[:3])) != 3 || len(KalmanSmooth(nil)) != 0 {
+		t.Errorf("smooth: wrong length for short values")
+	}
+
+	// Noisy values vary less once smoothed, around the same level
+	daily := make([]int, 60)
+	for i := range daily {
+		daily[i] = 5 + []int{-4, 3, 0, 5, -2, -1, 4}[i%7]
+	}
+	s := &Data{}
+	s.SetData(startDate, DataConfirmed, CumulativeFromDaily(daily, 0))
+	s.SetProvisional(s.Days[59].Date)
+	for name := range Smoothers {
+		smoothed := s.Smoothed(DataConfirmed, name)
+		if len(smoothed) != 59 {
+			t.Fatalf("smooth: wrong length for %s got:%d", name, len(smoothed))
+		}
+		for _, v := range smoothed[14:] {
+			if v < 3 || v > 8 {
+				t.Errorf("smooth: %s values not smoothed got:%v", name, smoothed)
+				break
+			}
+		}
+	}
+	if s.Smoothed(DataConfirmed, "unknown") != nil {
+		t.Errorf("smooth: smoothed with unknown smoother")
+	}
+}
+
+func TestAdjustedCaseFatality(t *testing.T) {
+	if _, err := NewDeathLag(0, 5); err == nil {
+		t.Errorf("cfr: created lag with zero mean")
+	}
+	lag := DefaultDeathLag()
+	if lag.Share(-1) != 0 || lag.Share(14) < 0.5 || lag.Share(14) > 0.7 || lag.Share(100) != 1 {
+		t.Errorf("cfr: wrong shares got:%g %g", lag.Share(14), lag.Share(100))
+	}
+
+	// 1000 cases are confirmed on the first day, and 2% of them die over the days after by the lag
+	n := 60
+	confirmed, deaths := make([]int, n), make([]int, n)
+	for i := range confirmed {
+		confirmed[i] = 1000
+		deaths[i] = int(math.Round(20 * lag.Share(i)))
+	}
+	s := &Data{}
+	s.SetData(startDate, DataConfirmed, confirmed)
+	s.SetData(startDate, DataDeaths, deaths)
+
+	rates := s.AdjustedCaseFatalityRates(lag)
+	if math.Abs(rates[14]-2) > 0.1 || math.Abs(rates[n-1]-2) > 1e-9 || s.CaseFatalityRates()[14] > 1.5 {
+		t.Errorf("cfr: wrong rates got:%g %g same day:%g", rates[14], rates[n-1], s.CaseFatalityRates()[14])
+	}
+	if m := s.MortalityWithLag(lag); m.AdjustedCFR != rates[n-1] || m.DeathLag.Mean != DefaultLagMean {
+		t.Errorf("cfr: wrong mortality got:%v", m)
+	}
+}
+
+func TestRecoveryDuration(t *testing.T) {
+	// Cases rise and fall, and recover 12 days after confirmation
+	n := 90
+	confirmed, recovered := make([]int, n), make([]int, n)
+	for i := range confirmed {
+		confirmed[i] = int(1000 * math.Exp(-math.Pow(float64(i-40)/10, 2)))
+		if i >= 12 {
+			recovered[i] = confirmed[i-12]
+		}
+	}
+	s := &Data{}
+	s.SetData(startDate, DataConfirmed, CumulativeFromDaily(confirmed, 0))
+	s.SetData(startDate, DataRecovered, CumulativeFromDaily(recovered, 0))
+
+	r := s.RecoveryDuration()
+	if r == nil || r.Days != 12 || r.Correlation < 0.99 {
+		t.Errorf("recovery: wrong duration got:%v", r)
+	}
+
+	// No recoveries reported
+	s = &Data{}
+	s.SetData(startDate, DataConfirmed, CumulativeFromDaily(confirmed, 0))
+	if r = s.RecoveryDuration(); r != nil {
+		t.Errorf("recovery: estimated without recoveries got:%v", r)
+	}
+}
+
+func TestTestsPerCase(t *testing.T) {
+	// 100 tests and 10 cases a day, with tests for the first week reported in one batch on day 7
+	n := 14
+	tested, confirmed := make([]int, n), make([]int, n)
+	for i := range tested {
+		confirmed[i] = 10
+		if i >= 7 {
+			tested[i] = 100
+		}
+	}
+	tested[6] = 700
+	s := &Data{}
+	s.SetData(startDate, DataTested, CumulativeFromDaily(tested, 0))
+	s.SetData(startDate, DataConfirmed, CumulativeFromDaily(confirmed, 0))
+
+	ratios := s.TestsPerCase()
+	if len(ratios) != n || ratios[0] != 0 || ratios[6] != 10 || ratios[13] != 10 {
+		t.Errorf("tests per case: wrong ratios got:%v", ratios)
+	}
+}
+
+func TestLaggedCorrelation(t *testing.T) {
+	// The second series follows the same curve 5 days later
+	cumulative := func(shift int) []int {
+		values := make([]int, 60)
+		for i := range values {
+			x := float64(i - 25 - shift)
+			values[i] = int(1000 * math.Exp(-x*x/100))
+			if i > 0 {
+				values[i] += values[i-1]
+			}
+		}
+		return values
+	}
+	a, b := &Data{ID: 1}, &Data{ID: 2}
+	a.SetData(startDate, DataDeaths, cumulative(0))
+	b.SetData(startDate, DataDeaths, cumulative(5))
+
+	c := LaggedCorrelation(a, b, DataDeaths, 10)
+	if c.BestLag != 5 || c.BestCorrelation < 0.99 || len(c.Lags) != 21 {
+		t.Errorf("correlation: wrong best lag got:%d %f lags:%d", c.BestLag, c.BestCorrelation, len(c.Lags))
+	}
+
+	// Reversed the first area follows the second
+	if c := LaggedCorrelation(b, a, DataDeaths, 10); c.BestLag != -5 {
+		t.Errorf("correlation: wrong reversed lag got:%d", c.BestLag)
+	}
+}
+
+func TestLoadExcess(t *testing.T) {
+	dir, err := ioutil.TempDir("", "excess")
+	if err != nil {
+		t.Fatalf("excess: failed to create dir:%s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// The series starts on Wednesday 22 Jan 2020, in ISO week 4
+	s := &Data{ID: 1}
+	s.SetData(startDate, DataDeaths, make([]int, 12))
+	defer func(d Slice) { std.dataset = d }(std.dataset)
+	std.dataset = Slice{s}
+
+	p := filepath.Join(dir, "mortality.csv")
+	ioutil.WriteFile(p, []byte("area_id,year,week,deaths,baseline\n1,2020,4,170,100\n1,2020,5,114,100\n"), 0644)
+	err = LoadExcess(p)
+	if err != nil {
+		t.Fatalf("excess: failed to load:%s", err)
+	}
+
+	// Five days of week 4 at 10 a day, then seven of week 5 at 2 a day
+	if s.ExcessDeaths() != 64 || s.Days[4].Value(DataExcess) != 50 || s.Period(7).ExcessDeaths() != 14 {
+		t.Errorf("excess: wrong excess deaths got:%d %d %d", s.ExcessDeaths(), s.Days[4].Value(DataExcess), s.Period(7).ExcessDeaths())
+	}
+
+	ioutil.WriteFile(p, []byte("area_id,year,week,deaths,baseline\n2,2020,4,170,100\n"), 0644)
+	if LoadExcess(p) == nil {
+		t.Errorf("excess: loaded unknown area")
+	}
+	if LoadExcess(filepath.Join(dir, "missing.csv")) != nil {
+		t.Errorf("excess: missing file should be ignored")
+	}
+}
+
+func TestLoadFlu(t *testing.T) {
+	dir, err := ioutil.TempDir("", "flu")
+	if err != nil {
+		t.Fatalf("flu: failed to create dir:%s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// The series starts on Wednesday 22 Jan 2020, in ISO week 4
+	s := &Data{ID: 1}
+	s.SetData(startDate, DataDeaths, make([]int, 12))
+	defer func(d Slice) { std.dataset = d }(std.dataset)
+	std.dataset = Slice{s}
+
+	// Week 4 has a mean of 70 deaths over two seasons, and week 5 a mean of 14
+	p := filepath.Join(dir, "flu.csv")
+	ioutil.WriteFile(p, []byte("area_id,season,week,deaths\n1,2017,4,63\n1,2018,4,77\n1,2017,5,14\n1,2018,5,14\n"), 0644)
+	err = LoadFlu(p)
+	if err != nil {
+		t.Fatalf("flu: failed to load:%s", err)
+	}
+
+	// Five days of week 4 at 10 a day, then seven of week 5 at 2 a day
+	if s.FluBaselineDeaths() != 64 || s.Days[4].Value(DataFlu) != 50 || s.Period(7).FluBaselineDeaths() != 14 {
+		t.Errorf("flu: wrong baseline deaths got:%d %d %d", s.FluBaselineDeaths(), s.Days[4].Value(DataFlu), s.Period(7).FluBaselineDeaths())
+	}
+	if m := s.Mortality(); !m.HasFlu() || m.FluDeaths != 64 {
+		t.Errorf("flu: wrong mortality got:%+v", m)
+	}
+
+	ioutil.WriteFile(p, []byte("area_id,year,week,deaths\n1,2017,4,63\n"), 0644)
+	if LoadFlu(p) == nil {
+		t.Errorf("flu: loaded invalid header")
+	}
+	ioutil.WriteFile(p, []byte("area_id,season,week,deaths\n2,2017,4,63\n"), 0644)
+	if LoadFlu(p) == nil {
+		t.Errorf("flu: loaded unknown area")
+	}
+	if LoadFlu(filepath.Join(dir, "missing.csv")) != nil {
+		t.Errorf("flu: missing file should be ignored")
+	}
+}
+
+func TestVaccinationCorrelation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vaccinations")
+	if err != nil {
+		t.Fatalf("vaccinations: failed to create dir:%s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Each area confirms 10 cases a day, then fewer from day 10 the more of it is vaccinated
+	var slice Slice
+	for id := 1; id <= 4; id++ {
+		values := make([]int, 20)
+		for i := range values {
+			daily := 10
+			if i > 10 {
+				daily -= 2 * id
+			}
+			values[i] = daily
+			if i > 0 {
+				values[i] += values[i-1]
+			}
+		}
+		s := &Data{ID: id, Country: fmt.Sprintf("Area %d", id), Population: 1000}
+		s.SetData(startDate, DataConfirmed, values)
+		slice = append(slice, s)
+	}
+	defer func(d Slice) { std.dataset = d }(std.dataset)
+	std.dataset = slice
+
+	// Areas 1 to 3 report 10% of population vaccinated per id on day 5, which is carried forward, area 4 reports nothing
+	p := filepath.Join(dir, "vaccinations.csv")
+	ioutil.WriteFile(p, []byte("area_id,date,vaccinated\n1,2020-01-27,100\n2,2020-01-27,200\n3,2020-01-27,300\n"), 0644)
+	err = LoadVaccinations(p)
+	if err != nil {
+		t.Fatalf("vaccinations: failed to load:%s", err)
+	}
+	date := startDate.AddDate(0, 0, 10)
+	if slice[0].Days[4].Value(DataVaccinated) != 0 || slice[2].Days[19].Value(DataVaccinated) != 300 || slice[1].VaccinationCoverage(date) != 20 {
+		t.Errorf("vaccinations: wrong vaccinated got:%d %d %f", slice[0].Days[4].Value(DataVaccinated), slice[2].Days[19].Value(DataVaccinated), slice[1].VaccinationCoverage(date))
+	}
+
+	// Growth falls by 20% for each 10% vaccinated, so correlates perfectly negatively
+	c := slice.VaccinationCorrelation(DataConfirmed, date, 5)
+	if len(c.Points) != 3 || c.Points[2].Growth != -60 || c.Points[2].Coverage != 30 || c.Correlation > -0.99 {
+		t.Errorf("vaccinations: wrong correlation got:%+v", c)
+	}
+
+	// Days after the date must be complete
+	if c := slice.VaccinationCorrelation(DataConfirmed, date, 10); len(c.Points) != 0 || c.Correlation != 0 {
+		t.Errorf("vaccinations: correlated incomplete days got:%+v", c)
+	}
+
+	ioutil.WriteFile(p, []byte("area_id,date,doses\n1,2020-01-27,100\n"), 0644)
+	if LoadVaccinations(p) == nil {
+		t.Errorf("vaccinations: loaded invalid header")
+	}
+	if LoadVaccinations(filepath.Join(dir, "missing.csv")) != nil {
+		t.Errorf("vaccinations: missing file should be ignored")
+	}
+}
+
+func TestLoadVariants(t *testing.T) {
+	dir, err := ioutil.TempDir("", "variants")
+	if err != nil {
+		t.Fatalf("variants: failed to create dir:%s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	s := &Data{ID: 1}
+	s.SetData(startDate, DataConfirmed, make([]int, 20))
+	defer func(d Slice) { std.dataset = d }(std.dataset)
+	std.dataset = Slice{s}
+
+	// Weekly reports from day 3, with delta first reported in the second
+	p := filepath.Join(dir, "variants.csv")
+	ioutil.WriteFile(p, []byte("area_id,date,variant,percent\n1,2020-01-25,beta,20\n1,2020-01-25,alpha,80\n1,2020-02-01,delta,50\n1,2020-02-01,alpha,50\n1,2020-02-01,beta,200\n"), 0644)
+	err = LoadVariants(p)
+	if err != nil {
+		t.Fatalf("variants: failed to load:%s", err)
+	}
+
+	// Invalid percents are skipped, and each report is carried forward
+	if !s.HasVariants() || s.Days[2].Variants != nil || len(s.Days[10].Variants) != 2 || len(s.VariantDays()) != 17 {
+		t.Errorf("variants: wrong days got:%v", s.VariantDays())
+	}
+	if got, want := strings.Join(s.Variants(), ","), "alpha,beta,delta"; got != want {
+		t.Errorf("variants: wrong names want:%v got:%v", want, got)
+	}
+	if shares := s.VariantShares("alpha"); shares[2] != 0 || shares[3] != 80 || shares[9] != 80 || shares[19] != 50 {
+		t.Errorf("variants: wrong shares got:%v", shares)
+	}
+
+	ioutil.WriteFile(p, []byte("area_id,date,lineage,percent\n1,2020-01-25,alpha,80\n"), 0644)
+	if LoadVariants(p) == nil {
+		t.Errorf("variants: loaded invalid header")
+	}
+	if LoadVariants(filepath.Join(dir, "missing.csv")) != nil {
+		t.Errorf("variants: missing file should be ignored")
+	}
+}
+
+func TestLoadStream(t *testing.T) {
+	dir, err := ioutil.TempDir("", "stream")
+	if err != nil {
+		t.Fatalf("stream: failed to create dir:%s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	s := &Data{ID: 1}
+	defer func(d Slice) { std.dataset = d }(std.dataset)
+	std.dataset = Slice{s}
+
+	// Bad rows are skipped, and the rest of the file loaded
+	p := filepath.Join(dir, "series.csv")
+	ioutil.WriteFile(p, []byte("day,area_id,deaths,confirmed,recovered,tested\n1,1,1,2,0,0\n2,9,5,5,0,0\n2,1\n3,1,1\"x,0,0,0\n3,1,4,8,0,0,jhu;jhu;;\n"), 0644)
+	err = Load(p)
+	if err != nil {
+		t.Fatalf("stream: failed to load:%s", err)
+	}
+	if len(s.Days) != 3 || s.Days[0].Value(DataConfirmed) != 2 || s.LastDay().Value(DataDeaths) != 4 || s.LastDay().Source(DataDeaths) != SourceJHU {
+		t.Errorf("stream: wrong days got:%v", s.Days)
+	}
+
+	ioutil.WriteFile(p, []byte("day,area,deaths\n1,1,1\n"), 0644)
+	if Load(p) == nil {
+		t.Errorf("stream: loaded invalid header")
+	}
+}
+
+func TestLazyProvinces(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lazy")
+	if err != nil {
+		t.Fatalf("lazy: failed to create dir:%s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	ioutil.WriteFile(filepath.Join(dir, "areas.csv"), []byte("country,province,area_id,latitude,longitude,population,lockdown,colour,area_km2\n,,1,0,0,100,,#000000,\nTestland,,2,1,1,100,,#000000,\nTestland,North,3,1,1,50,,#000000,\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "series.csv"), []byte("day,area_id,deaths,confirmed,recovered,tested\n1,1,2,4,0,0\n1,2,2,4,0,0\n1,3,1,2,0,0\n2,3,3,5,0,0\n"), 0644)
+
+	st := NewStore(StoreConfig{DataPath: dir, LazyProvinces: true})
+	err = st.LoadData()
+	if err != nil {
+		t.Fatalf("lazy: failed to load:%s", err)
+	}
+
+	country, _ := st.dataset.FetchSeries("Testland", "")
+	province, _ := st.dataset.FetchSeries("Testland", "North")
+	if country.Pending() || !province.Pending() || len(province.Days) != 0 {
+		t.Fatalf("lazy: wrong pending country:%t province:%t", country.Pending(), province.Pending())
+	}
+
+	// Pending provinces are saved from the data files, and stay pending
+	savePath := filepath.Join(dir, "saved.csv")
+	err = st.Save(savePath)
+	if err != nil {
+		t.Fatalf("lazy: failed to save:%s", err)
+	}
+	saved, err := ioutil.ReadFile(savePath)
+	if err != nil || !strings.Contains(string(saved), "\n2,3,3,5,0,0,") {
+		t.Errorf("lazy: pending province not saved got:%s", saved)
+	}
+
+	// Series held by callers are not changed, the loaded province replaces the pending one in the dataset
+	s, err := st.FetchSeries("Testland", "North")
+	if err != nil || s == province || s.Pending() || !province.Pending() || len(province.Days) != 0 {
+		t.Fatalf("lazy: province not loaded err:%v", err)
+	}
+	if len(s.Days) != len(country.Days) || s.Days[1].Value(DataDeaths) != 3 || s.LastDay().Value(DataConfirmed) != 5 {
+		t.Errorf("lazy: wrong province days got:%d want:%d %v", len(s.Days), len(country.Days), s.Days[:2])
+	}
+	again, _ := st.FetchSeries("Testland", "North")
+	if again != s {
+		t.Errorf("lazy: province loaded twice")
+	}
+}
+
+// TestStores tests stores load their own data files with their own config, and keep their own overrides
+func TestStores(t *testing.T) {
+	var stores []*Store
+	starts := []time.Time{startDate, time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)}
+	for i, deaths := range []int{3, 7} {
+		dir, err := ioutil.TempDir("", "store")
+		if err != nil {
+			t.Fatalf("store: failed to create dir:%s", err)
+		}
+		defer os.RemoveAll(dir)
+
+		ioutil.WriteFile(filepath.Join(dir, "areas.csv"), []byte("country,province,area_id,latitude,longitude,population,lockdown,colour,area_km2\n,,1,0,0,100,,#000000,\nTestland,,2,1,1,100,,#000000,\nTestland,North,3,1,1,50,,#000000,\n"), 0644)
+		ioutil.WriteFile(filepath.Join(dir, "series.csv"), []byte(fmt.Sprintf("day,area_id,deaths,confirmed,recovered,tested\n1,1,%d,9,0,0\n1,2,%d,9,0,0\n1,3,%d,9,0,0\n", deaths, deaths, deaths)), 0644)
+
+		st := NewStore(StoreConfig{DataPath: dir, LazyProvinces: i == 1, StartDate: starts[i]})
+		err = st.LoadData()
+		if err != nil {
+			t.Fatalf("store: failed to load:%s", err)
+		}
+		stores = append(stores, st)
+	}
+
+	for i, deaths := range []int{3, 7} {
+		s, err := stores[i].FetchSeries("Testland", "North")
+		if err != nil || s.Pending() || s.Days[0].Value(DataDeaths) != deaths || !s.Days[0].Date.Equal(starts[i]) {
+			t.Fatalf("store: wrong province in store:%d err:%v got:%v", i, err, s)
+		}
+	}
+
+	date := stores[0].CountSeries().FirstDate
+	err := stores[0].AddOverride(Override{AreaID: 2, Date: date, Kind: "deaths", Value: 5, Reason: "revised"}, "test")
+	if err != nil {
+		t.Fatalf("store: failed to add override:%s", err)
+	}
+	first, _ := stores[0].FetchSeries("Testland", "")
+	second, _ := stores[1].FetchSeries("Testland", "")
+	if first.Days[0].Value(DataDeaths) != 5 || second.Days[0].Value(DataDeaths) != 7 || len(stores[1].Overrides()) != 0 {
+		t.Errorf("store: override applied to wrong store got:%d,%d", first.Days[0].Value(DataDeaths), second.Days[0].Value(DataDeaths))
+	}
+	if len(stores[0].AuditLog(nil, 10)) == 0 || len(stores[1].AuditLog(nil, 10)) != 0 {
+		t.Errorf("store: audit recorded in wrong store")
+	}
+}
+
+// TestDataset tests series track the metrics of the dataset of their store
+func TestDataset(t *testing.T) {
+	for _, metrics := range [][]string{nil, {"deaths", "deaths"}, {"Cases"}, {"excess"}, {"day"}} {
+		if _, err := NewDataset("flu", metrics...); err == nil {
+			t.Errorf("dataset: invalid metrics accepted:%v", metrics)
+		}
+	}
+	flu, err := NewDataset("Influenza", "deaths", "cases", "icu_admissions", "hospitalised", "vaccinated_doses")
+	if err != nil || flu.Metrics[2].Label != "Icu Admissions" || len(flu.Kinds()) != 5 {
+		t.Fatalf("dataset: wrong dataset err:%v got:%v", err, flu)
+	}
+
+	dir, err := ioutil.TempDir("", "dataset")
+	if err != nil {
+		t.Fatalf("dataset: failed to create dir:%s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Metrics are stored in the kind of their name whatever the dataset, so deaths are read with DataDeaths
+	st := NewStore(StoreConfig{DataPath: dir, Dataset: flu})
+	cases := st.DataKindFromName("cases")
+	if flu.Kinds()[0] != DataDeaths || cases < DataCustom || DataKindName(cases) != "cases" || st.DataKindFromName("confirmed") != DataNone || st.DataKindFromName("flu") != DataFlu || MetricLabel(st.DataKindFromName("icu_admissions")) != "Icu Admissions" {
+		t.Errorf("dataset: wrong kinds for metrics got:%v", flu.Kinds())
+	}
+	if DataKindFromName("confirmed") != DataConfirmed || DataKindFromName("cases") != DataNone {
+		t.Errorf("dataset: dataset of store changed default store")
+	}
+	s := &Data{store: st}
+	s.AddDaysFrom(startDate, 2)
+	s.SetDayData(1, 1, 10, 2, 3, 4)
+	s.SetDayData(2, 2, 30, 2, 3, 4)
+	if s.LastDay().Value(DataDeaths) != 2 || s.LastDay().Value(DataConfirmed) != 0 || s.Days[1].Value(cases) != 30 || s.DoubleDays(cases) != 0 {
+		t.Errorf("dataset: wrong values got:%v %v", s.Deaths(), s.Values(cases))
+	}
+
+	// Series files must name the metrics of the dataset, and are saved with them
+	seriesPath := filepath.Join(dir, "series.csv")
+	ioutil.WriteFile(filepath.Join(dir, "areas.csv"), []byte("country,province,area_id,latitude,longitude,population,lockdown,colour,area_km2\n,,1,0,0,100,,#000000,\n"), 0644)
+	ioutil.WriteFile(seriesPath, []byte("day,area_id,deaths,confirmed,recovered,tested\n1,1,2,9,0,0\n"), 0644)
+	if st.LoadData() == nil {
+		t.Errorf("dataset: loaded series file with other metrics")
+	}
+	ioutil.WriteFile(seriesPath, []byte("day,area_id,deaths,cases,icu_admissions,hospitalised,vaccinated_doses\n1,1,2,9,1,0,5\n"), 0644)
+	err = st.LoadData()
+	if err == nil {
+		err = st.Save(seriesPath)
+	}
+	data, _ := ioutil.ReadFile(seriesPath)
+	if err != nil || !strings.HasPrefix(string(data), "day,area_id,deaths,cases,icu_admissions,hospitalised,vaccinated_doses,sources\n1,1,2,9,1,0,5,") {
+		t.Errorf("dataset: wrong series file err:%v got:%s", err, data)
+	}
+}
+
+// TestArchive tests days archived are not loaded, and are read from the archive on demand
+func TestArchive(t *testing.T) {
+	dir, err := ioutil.TempDir("", "archive")
+	if err != nil {
+		t.Fatalf("archive: failed to create dir:%s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	rows := "day,area_id,deaths,confirmed,recovered,tested\n"
+	for day := 1; day <= 8; day++ {
+		rows += fmt.Sprintf("%d,1,%d,9,0,0\n%d,2,%d,9,0,0\n", day, day*2, day, day*2)
+	}
+	seriesPath := filepath.Join(dir, "series.csv")
+	ioutil.WriteFile(filepath.Join(dir, "areas.csv"), []byte("country,province,area_id,latitude,longitude,population,lockdown,colour,area_km2\n,,1,0,0,100,,#000000,\nTestland,,2,1,1,100,,#000000,\n"), 0644)
+	ioutil.WriteFile(seriesPath, []byte(rows), 0644)
+
+	// Days before day 6 are archived
+	before := startDate.AddDate(0, 0, 5)
+	days := int(time.Now().UTC().Sub(before).Hours() / 24)
+	st := NewStore(StoreConfig{DataPath: dir, ArchiveDays: days})
+	err = st.LoadData()
+	if err == nil {
+		err = st.Archive()
+	}
+	if err != nil {
+		t.Fatalf("archive: failed to archive:%s", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		s, err := st.FetchSeries("Testland", "")
+		if err != nil || !s.Days[0].Date.Equal(before) || s.PreviousDay.Value(DataDeaths) != 10 || s.Days[0].Value(DataDeaths) != 12 || s.Daily(DataDeaths)[0] != 2 {
+			t.Fatalf("archive: wrong days kept err:%v got:%v previous:%v", err, s.Days, s.PreviousDay)
+		}
+
+		// The series file keeps the last day archived, and is loaded starting from the days kept
+		data, _ := ioutil.ReadFile(seriesPath)
+		if strings.Contains(string(data), "\n4,2,") || !strings.Contains(string(data), "\n5,2,10,9,0,0,") {
+			t.Errorf("archive: wrong series file got:%s", data)
+		}
+		st = NewStore(StoreConfig{DataPath: dir})
+		err = st.LoadData()
+		if err != nil {
+			t.Fatalf("archive: failed to load:%s", err)
+		}
+	}
+
+	s, _ := st.FetchSeries("Testland", "")
+	archived, err := st.WithArchive(s, startDate.AddDate(0, 0, 1))
+	if err != nil || len(archived.Days) != len(s.Days)+4 || archived.Days[0].Value(DataDeaths) != 4 || archived.PreviousDay.Value(DataDeaths) != 2 || archived.Days[4].Value(DataDeaths) != 12 {
+		t.Errorf("archive: wrong archived days err:%v got:%v previous:%v", err, archived.Days, archived.PreviousDay)
+	}
+	if again, _ := st.WithArchive(s, before); again != s {
+		t.Errorf("archive: read archive for days in memory")
+	}
+}
+
+// TestCache tests the dataset is loaded from the cache while the data files are unchanged
+func TestCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cache")
+	if err != nil {
+		t.Fatalf("cache: failed to create dir:%s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	seriesPath := filepath.Join(dir, "series.csv")
+	ioutil.WriteFile(filepath.Join(dir, "areas.csv"), []byte("country,province,area_id,latitude,longitude,population,lockdown,colour,area_km2\n,,1,0,0,100,,#000000,\nTestland,,2,1,1,100,,#000000,\n"), 0644)
+	ioutil.WriteFile(seriesPath, []byte("day,area_id,deaths,confirmed,recovered,tested\n1,1,3,9,0,0\n1,2,3,9,0,0,jhu;jhu;;\n"), 0644)
+
+	st := NewStore(StoreConfig{DataPath: dir, Cache: true})
+	err = st.LoadData()
+	if err != nil {
+		t.Fatalf("cache: failed to load:%s", err)
+	}
+	key, err := dataCacheKey(dir, st.Config())
+	if err != nil || loadCache(dir, key) == nil {
+		t.Fatalf("cache: cache not saved on load err:%v", err)
+	}
+
+	// A cache with the same key is loaded in place of the data files
+	var cached Slice
+	for _, id := range []int{1, 2} {
+		s := &Data{ID: id, Country: []string{"", "Testland"}[id-1]}
+		s.SetData(startDate, DataDeaths, []int{5})
+		s.Days[0].SetSource(DataDeaths, SourceJHU)
+		cached = append(cached, s)
+	}
+	err = saveCache(filepath.Join(dir, CacheName), cacheFile{Key: key, Dataset: cached})
+	if err != nil {
+		t.Fatalf("cache: failed to save:%s", err)
+	}
+	err = st.LoadData()
+	if err != nil {
+		t.Fatalf("cache: failed to load cache:%s", err)
+	}
+	s, err := st.FetchSeries("Testland", "")
+	if err != nil || s.Days[0].Value(DataDeaths) != 5 || s.Days[0].Source(DataDeaths) != SourceJHU || len(s.Days) != 1 {
+		t.Fatalf("cache: wrong series from cache err:%v got:%v", err, s)
+	}
+
+	// Changing a data file makes the cache stale, so the files are parsed
+	ioutil.WriteFile(seriesPath, []byte("day,area_id,deaths,confirmed,recovered,tested\n1,1,4,9,0,0\n1,2,4,9,0,0\n"), 0644)
+	err = st.LoadData()
+	if err != nil {
+		t.Fatalf("cache: failed to load:%s", err)
+	}
+	s, err = st.FetchSeries("Testland", "")
+	if err != nil || s.Days[0].Value(DataDeaths) != 4 || len(s.Days) == 1 {
+		t.Errorf("cache: stale cache loaded err:%v got:%v", err, s)
+	}
+}
+
+func TestLoadSex(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sex")
+	if err != nil {
+		t.Fatalf("sex: failed to create dir:%s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	s := &Data{ID: 1}
+	s.SetData(startDate, DataDeaths, []int{5, 10, 20})
+	defer func(d Slice) { std.dataset = d }(std.dataset)
+	std.dataset = Slice{s}
+
+	p := filepath.Join(dir, "sex.csv")
+	ioutil.WriteFile(p, []byte("area_id,date,male_deaths,female_deaths,male_confirmed,female_confirmed\n1,2020-01-23,6,3,30,30\n"), 0644)
+	err = LoadSex(p)
+	if err != nil {
+		t.Fatalf("sex: failed to load:%s", err)
+	}
+
+	// Totals are unchanged, the breakdown is only on the day given
+	days := s.SexDays()
+	if len(days) != 1 || s.LastDay().Value(DataDeaths) != 20 || s.Days[0].Sex != nil || !s.HasSexBreakdown() {
+		t.Fatalf("sex: wrong days got:%v", days)
+	}
+	if days[0].MaleDeaths != 6 || days[0].MaleConfirmedPercent != 50 || s.LastSexCounts().FemaleDeathsPercent() < 33.3 {
+		t.Errorf("sex: wrong counts got:%v", days[0])
+	}
+}
+
+func TestTodayIn(t *testing.T) {
+	now := time.Now().UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	s := &Data{}
+	for i, deaths := range []int{1, 3, 6} {
+		s.AddDay(today.AddDate(0, 0, i-2), deaths, 0, 0, 0)
+	}
+
+	if s.TodayIn(DataDeaths, time.UTC) != 3 || s.LastHoursIn(time.UTC) != now.Hour() {
+		t.Errorf("today: wrong for utc got:%d %d", s.TodayIn(DataDeaths, time.UTC), s.LastHoursIn(time.UTC))
+	}
+
+	// Almost a day behind UTC the local date is always yesterday, a complete day
+	behind := time.FixedZone("behind", -(24*3600 - 1))
+	if s.TodayIn(DataDeaths, behind) != 2 || s.LastHoursIn(behind) != 24 {
+		t.Errorf("today: wrong for zone behind got:%d %d", s.TodayIn(DataDeaths, behind), s.LastHoursIn(behind))
+	}
+
+	// Ahead of the data the last day is used
+	ahead := time.FixedZone("ahead", 24*3600-1)
+	if s.TodayIn(DataDeaths, ahead) != 3 {
+		t.Errorf("today: wrong for zone ahead got:%d", s.TodayIn(DataDeaths, ahead))
+	}
+}
+
+func TestProvisional(t *testing.T) {
+	s := &Data{}
+	s.SetData(startDate, DataDeaths, []int{0, 3, 6, 9})
+	s.AddToday()
+	s.UpdateToday(SourceJHU, time.Now().UTC(), 100, 0, 0, 0)
+
+	if !s.IsProvisional() || s.ProvisionalCount() != 1 {
+		t.Fatalf("provisional: last day should be provisional got:%d", s.ProvisionalCount())
+	}
+
+	// The provisional day is excluded from the average
+	if s.AverageDeaths() != 2 {
+		t.Errorf("provisional: average wrong want:%d got:%d", 2, s.AverageDeaths())
+	}
+
+	s.CompleteDays(s.LastDay().Date)
+	if s.IsProvisional() || s.AverageDeaths() != 31 {
+		t.Errorf("provisional: complete day wrong got:%d", s.AverageDeaths())
+	}
+}
+
+func TestSources(t *testing.T) {
+	s := &Data{}
+	s.SetData(startDate, DataDeaths, []int{1, 2})
+	s.UpdateToday(SourceJHU, time.Now().UTC(), 5, 0, 0, 0)
+
+	day := s.LastDay()
+	if day.Source(DataDeaths) != SourceJHU || day.Source(DataConfirmed) != SourceUnknown {
+		t.Errorf("sources: wrong sources after update got:%s", day.formatSources())
+	}
+
+	// Sources should survive a round trip through the series file format
+	formatted := day.formatSources()
+	if formatted != "jhu;;;" {
+		t.Errorf("sources: format wrong got:%s", formatted)
+	}
+	parsed := s.Days[0]
+	parsed.parseSources(formatted)
+	if parsed.formatSources() != formatted {
+		t.Errorf("sources: parse wrong want:%s got:%s", formatted, parsed.formatSources())
+	}
+}
+
+func TestSourcePriority(t *testing.T) {
+	st := NewStore(StoreConfig{})
+	s := &Data{ID: 99, store: st}
+	s.SetData(startDate, DataDeaths, []int{1, 2})
+
+	// Higher priority ukgov values replace jhu values even if lower
+	s.UpdateToday(SourceJHU, time.Now().UTC(), 10, 0, 0, 0)
+	s.UpdateToday(SourceUKGov, time.Now().UTC(), 8, 0, 0, 0)
+	if s.LastDay().Value(DataDeaths) != 8 || s.LastDay().Source(DataDeaths) != SourceUKGov {
+		t.Errorf("priority: ukgov should win got:%v", s.LastDay())
+	}
+
+	// Lower priority values are recorded but not used
+	s.UpdateToday(SourceJHU, time.Now().UTC(), 12, 0, 0, 0)
+	if s.LastDay().Value(DataDeaths) != 8 {
+		t.Errorf("priority: jhu should lose got:%v", s.LastDay())
+	}
+
+	found := 0
+	for _, c := range st.Conflicts() {
+		if c.AreaID == 99 {
+			found++
+		}
+	}
+	if found != 2 {
+		t.Errorf("priority: conflicts wrong want:2 got:%d", found)
+	}
+
+	// Only the latest conflict of 8 against 12 is reported, a difference of 33%
+	discrepancies := st.SourceDiscrepancies(30)
+	if len(discrepancies) != 1 || discrepancies[0].LoserValue != 12 {
+		t.Errorf("priority: discrepancies wrong got:%v", discrepancies)
+	}
+	if len(st.SourceDiscrepancies(50)) != 0 || len(Conflicts()) != 0 {
+		t.Errorf("priority: discrepancies over 50%% should be empty")
+	}
+}
+
+func TestSnapshots(t *testing.T) {
+	dir, err := ioutil.TempDir("", "snapshots")
+	if err != nil {
+		t.Fatalf("snapshots: failed to create dir:%s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	p := filepath.Join(dir, "series.csv")
+	ioutil.WriteFile(p, []byte("day,area_id\n1,1\n"), 0644)
+	first, err := SaveSnapshot(dir, p)
+	if err != nil || first.Version != 1 {
+		t.Fatalf("snapshots: failed to save:%v %s", first, err)
+	}
+
+	// Unchanged files are not saved again
+	again, _ := SaveSnapshot(dir, p)
+	if again.Version != 1 {
+		t.Errorf("snapshots: unchanged file saved as version:%d", again.Version)
+	}
+
+	ioutil.WriteFile(p, []byte("corrupt"), 0644)
+	second, _ := SaveSnapshot(dir, p)
+	if second.Version != 2 || second.Hash == first.Hash {
+		t.Errorf("snapshots: changed file wrong got:%v", second)
+	}
+
+	err = RestoreSnapshot(dir, 1, p)
+	data, _ := ioutil.ReadFile(p)
+	if err != nil || string(data) != "day,area_id\n1,1\n" {
+		t.Errorf("snapshots: restore failed got:%s %s", data, err)
+	}
+
+	// Corrupted snapshots are not restored
+	ioutil.WriteFile(filepath.Join(dir, second.Name), []byte("changed"), 0644)
+	if RestoreSnapshot(dir, 2, p) == nil {
+		t.Errorf("snapshots: restored snapshot with bad checksum")
+	}
+}
+
+func TestDiffSnapshots(t *testing.T) {
+	dir, err := ioutil.TempDir("", "snapshots")
+	if err != nil {
+		t.Fatalf("snapshots: failed to create dir:%s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	p := filepath.Join(dir, "series.csv")
+	header := "day,area_id,deaths,confirmed,recovered,tested,sources\n"
+	ioutil.WriteFile(p, []byte(header+"1,1,2,10,0,0,\n2,1,3,12,0,0,\n"), 0644)
+	snapshot, _ := SaveSnapshot(dir, p)
+
+	// Values are saved as the change from the previous row of the area
+	data, _ := ioutil.ReadFile(filepath.Join(dir, snapshot.Name))
+	if string(data) != header+"1,1,2,10,0,0,\n2,1,1,2,0,0,\n" {
+		t.Errorf("snapshots: wrong delta encoding got:%s", data)
+	}
+	rows := header + "1,1,2,10,0,0,jhu\n1,2,x,007\n2,2,5,-1\n2,1,1,9,0,0,jhu;jhu\n3\n"
+	if got := deltaEncode(deltaEncode([]byte(rows), 1), -1); string(got) != rows {
+		t.Errorf("snapshots: wrong delta decoding got:%s", got)
+	}
+
+	ioutil.WriteFile(p, []byte(header+"1,1,2,10,0,0,\n2,1,4,12,0,0,\n1,2,1,5,0,0,\n"), 0644)
+
+	// Diff the snapshot against the current series file
+	revisions, err := DiffSnapshots(dir, 1, 0, p)
+	if err != nil || len(revisions) != 3 {
+		t.Fatalf("snapshots: diff wrong got:%v %s", revisions, err)
+	}
+	r := revisions[0]
+	if r.AreaID != 1 || r.Kind != "deaths" || r.Before != 3 || r.After != 4 || !r.Date.Equal(startDate.AddDate(0, 0, 1)) {
+		t.Errorf("snapshots: diff wrong revision got:%v", r)
+	}
+	if revisions[1].AreaID != 2 || revisions[1].Before != 0 {
+		t.Errorf("snapshots: diff wrong new row got:%v", revisions[1])
+	}
+
+	if _, err := DiffSnapshots(dir, 1, 5, p); err == nil {
+		t.Errorf("snapshots: diff with missing version succeeded")
+	}
+}
+
+// TestAlignedFrom tests aligning series from the day a threshold was crossed
+// TestChanges tests refreshes are recorded in the changelog with revisions to earlier days, and saved
+func TestChanges(t *testing.T) {
+	dir, err := ioutil.TempDir("", "changes")
+	if err != nil {
+		t.Fatalf("changes: failed to create dir:%s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	st := NewStore(StoreConfig{})
+	global := &Data{ID: 1, store: st}
+	global.SetData(startDate, DataDeaths, []int{1, 2, 3})
+	s := &Data{ID: 2, Country: "Testland", store: st}
+	s.SetData(startDate, DataDeaths, []int{1, 2, 3})
+	st.dataset = Slice{global, s}
+
+	// The first day is revised, the last day updated and a day added, only the first is a revision
+	before := st.TakeSnapshot()
+	st.Update("test", func(next Slice) error {
+		s, _ := next.FindSeries(2)
+		s.Days[0].SetData(DataDeaths, 2)
+		s.Days[2].SetData(DataDeaths, 4)
+		s.AddDays(1)
+		s.Days[3].SetData(DataDeaths, 6)
+		return nil
+	})
+	change := st.RecordChanges([]string{"jhu"}, before)
+	if change.Version != 1 || len(change.Areas) != 1 || change.Areas[0].AreaID != 2 || change.Areas[0].Days != 3 || change.Areas[0].Country != "Testland" {
+		t.Fatalf("changes: wrong areas changed got:%+v", change)
+	}
+	if len(change.Revisions) != 1 || change.Revisions[0].Kind != "deaths" || change.Revisions[0].Before != 1 || change.Revisions[0].After != 2 || !change.Revisions[0].Date.Equal(startDate) {
+		t.Errorf("changes: wrong revisions got:%+v", change.Revisions)
+	}
+	if !change.PreviousMaxDate.Equal(startDate.AddDate(0, 0, 2)) || !change.MaxDate.Equal(startDate.AddDate(0, 0, 3)) || change.Sources[0] != "jhu" {
+		t.Errorf("changes: wrong dates got:%s to:%s", change.PreviousMaxDate, change.MaxDate)
+	}
+
+	// Refreshes without changes are recorded with the next version, and clients read the changes after their version
+	unchanged := st.RecordChanges(nil, st.TakeSnapshot())
+	if unchanged.Version != 2 || len(unchanged.Areas) != 0 || len(unchanged.Revisions) != 0 {
+		t.Errorf("changes: wrong unchanged refresh got:%+v", unchanged)
+	}
+	if changes := st.ChangesSince(1); len(changes) != 1 || changes[0] != unchanged || len(st.ChangesSince(0)) != 2 || len(st.ChangesSince(2)) != 0 {
+		t.Errorf("changes: wrong changes since got:%v", changes)
+	}
+
+	// The changelog is saved and loaded into another store, a missing file leaves it empty
+	p := filepath.Join(dir, "changes.json")
+	err = st.SaveChanges(p)
+	if err != nil {
+		t.Fatalf("changes: failed to save:%s", err)
+	}
+	loaded := NewStore(StoreConfig{})
+	err = loaded.LoadChanges(p)
+	changes := loaded.ChangesSince(0)
+	if err != nil || len(changes) != 2 || changes[0].Version != 1 || changes[1].Version != 2 {
+		t.Fatalf("changes: wrong changes loaded err:%v got:%v", err, changes)
+	}
+	if r := changes[0].Revisions; len(r) != 1 || r[0] != change.Revisions[0] || changes[0].Areas[0] != change.Areas[0] || !changes[0].MaxDate.Equal(change.MaxDate) {
+		t.Errorf("changes: change not loaded as saved got:%+v", changes[0])
+	}
+	if next := loaded.RecordChanges(nil, loaded.TakeSnapshot()); next.Version != 3 {
+		t.Errorf("changes: wrong version after load got:%d", next.Version)
+	}
+	empty := NewStore(StoreConfig{})
+	if err = empty.LoadChanges(filepath.Join(dir, "missing.json")); err != nil || len(empty.ChangesSince(0)) != 0 {
+		t.Errorf("changes: missing file loaded err:%v", err)
+	}
+	ioutil.WriteFile(p, []byte("{"), 0644)
+	if loaded.LoadChanges(p) == nil {
+		t.Errorf("changes: loaded invalid file")
+	}
+}
+
+func TestAlignedFrom(t *testing.T) {
+	d := &Data{}
+	d.SetData(startDate, DataConfirmed, []int{0, 5, 10, 20, 40, 41})
+
+	values := d.AlignedFrom(DataConfirmed, 10)
+	if len(values) != 3 || values[0] != 10 || values[2] != 40 {
+		t.Errorf("aligned: values wrong got:%v", values)
+	}
+
+	if d.AlignedFrom(DataConfirmed, 100) != nil {
+		t.Errorf("aligned: values should be nil if never reached")
+	}
+}
+
+func TestPage(t *testing.T) {
+	var slice Slice
+	for i, deaths := range []int{5, 9, 5, 1, 7} {
+		s := &Data{ID: i + 1, Country: "Country " + strconv.Itoa(i+1)}
+		s.SetData(startDate, DataDeaths, []int{deaths})
+		slice = append(slice, s)
+	}
+
+	p, err := slice.Page("deaths", "", 1, 2)
+	if err != nil || p.Total != 5 || len(p.Series) != 2 || p.Series[0].ID != 2 || p.Series[1].ID != 5 {
+		t.Fatalf("page: wrong first page:%v %v", p.Series, err)
+	}
+
+	// Changing a value already served must not repeat or skip series on the next page
+	slice[1].Days[0].SetData(DataDeaths, 0)
+	p, err = slice.Page("deaths", p.Next, 0, 2)
+	if err != nil || len(p.Series) != 2 || p.Series[0].ID != 1 || p.Series[1].ID != 3 {
+		t.Fatalf("page: wrong page after cursor:%v %v", p.Series, err)
+	}
+	p, err = slice.Page("deaths", p.Next, 0, 2)
+	if err != nil || len(p.Series) != 2 || p.Series[0].ID != 4 || p.Next != "" {
+		t.Errorf("page: wrong last page:%v next:%s %v", p.Series, p.Next, err)
+	}
+
+	p, err = slice.Page("name", "", 3, 2)
+	if err != nil || len(p.Series) != 1 || p.Series[0].ID != 5 {
+		t.Errorf("page: wrong page by number:%v %v", p.Series, err)
+	}
+
+	// Pages past the last are empty, however large the page number
+	for _, page := range []int{4, 184467440737095518, math.MaxInt64} {
+		p, err = slice.Page("deaths", "", page, 50)
+		if err != nil || len(p.Series) != 0 || p.Total != 5 || p.Next != "" {
+			t.Errorf("page: wrong page past the last:%d %v %v", page, p.Series, err)
+		}
+	}
+	if _, err = slice.Page("deaths", "invalid", 0, 2); err == nil {
+		t.Errorf("page: invalid cursor accepted")
+	}
+}
+
+func TestSearch(t *testing.T) {
+	slice := Slice{
+		{ID: 1},
+		{ID: 2, Country: "United Kingdom"},
+		{ID: 3, Country: "US"},
+		{ID: 4, Country: "US", Province: "New York"},
+		{ID: 5, Country: "Côte d'Ivoire"},
+		{ID: 6, Country: "United Arab Emirates"},
+	}
+
+	tests := map[string]int{
+		"Untied Kingdm":  2,
+		"USA":            3,
+		"united states":  3,
+		"new york":       4,
+		"cote d'ivoire":  5,
+		"COTE D IVOIRE":  5,
+		"united arab":    6,
+		"Unitd Arab Emi": 6,
+		"uae":            6,
+		"Great  Britain": 2,
+		"ivory coast!":   5,
+	}
+	for q, id := range tests {
+		results := slice.Search(q)
+		if len(results) == 0 || results[0].ID != id {
+			t.Errorf("search: wrong results for q:%s want:%d got:%v", q, id, results)
+		}
+	}
+
+	if results := slice.Search("Narnia"); len(results) != 0 {
+		t.Errorf("search: unexpected results:%v", results)
+	}
+}
+
+func TestAutocomplete(t *testing.T) {
+	slice := Slice{
+		{ID: 1},
+		{ID: 2, Country: "United Kingdom"},
+		{ID: 3, Country: "US", Province: "New York"},
+		{ID: 4, Country: "United Arab Emirates"},
+		{ID: 5, Country: "Yorkland"},
+	}
+
+	std.mutex.Lock()
+	std.completions = slice.buildCompletions()
+	std.mutex.Unlock()
+	defer func() {
+		std.mutex.Lock()
+		std.completions = nil
+		std.mutex.Unlock()
+	}()
+
+	results := Autocomplete("Uni", 10)
+	if len(results) != 2 || results[0].AreaID != 2 || results[0].Slug != "united-kingdom" || results[1].AreaID != 4 {
+		t.Errorf("autocomplete: wrong results:%v", results)
+	}
+
+	// Names starting with q come before names with a later word starting with q
+	results = Autocomplete("york", 10)
+	if len(results) != 2 || results[0].AreaID != 5 || results[1].Slug != "us/new-york" {
+		t.Errorf("autocomplete: wrong results for word:%v", results)
+	}
+
+	if results = Autocomplete("uni", 1); len(results) != 1 {
+		t.Errorf("autocomplete: results not limited:%v", results)
+	}
+}
+
+func TestFetchCode(t *testing.T) {
+	slice := Slice{
+		{ID: 1},
+		{ID: 2, Country: "Germany"},
+		{ID: 3, Country: "US", Province: "New York"},
+		{ID: 4, Country: "United Kingdom", Province: "Gibraltar"},
+	}
+
+	tests := map[string]int{"DE": 2, "deu": 2, "US-NY": 3, "gi": 4}
+	for code, id := range tests {
+		s, err := slice.FetchCode(code)
+		if err != nil || s.ID != id {
+			t.Errorf("code: wrong series for code:%s want:%d got:%v %v", code, id, s, err)
+		}
+	}
+
+	if s := slice[2]; s.CountryAlpha2() != "US" || s.SubdivisionCode() != "US-NY" || s.Slug() != "us/new-york" {
+		t.Errorf("code: wrong codes got:%s %s %s", s.CountryAlpha2(), s.SubdivisionCode(), s.Slug())
+	}
+	if _, err := slice.FetchCode("ZZ"); err == nil {
+		t.Errorf("code: unknown code found")
+	}
+}
+
+func TestAliases(t *testing.T) {
+	dir, err := ioutil.TempDir("", "aliases")
+	if err != nil {
+		t.Fatalf("aliases: temp dir error:%s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	p := filepath.Join(dir, "aliases.csv")
+	err = ioutil.WriteFile(p, []byte("alias,country\nViet Nam,Vietnam\n"), 0644)
+	if err != nil {
+		t.Fatalf("aliases: write error:%s", err)
+	}
+	err = LoadAliases(p)
+	if err != nil {
+		t.Fatalf("aliases: load error:%s", err)
+	}
+	defer LoadAliases("")
+
+	tests := map[string]string{"viet nam": "Vietnam", "USA": "US", "Korea, South": "South Korea", "France": "France"}
+	for alias, country := range tests {
+		if name := CountryName(alias); name != country {
+			t.Errorf("aliases: wrong name for alias:%s want:%s got:%s", alias, country, name)
+		}
+	}
+
+	s := &Data{Country: "US"}
+	if !s.MatchCountry("United States of America") || s.MatchCountry("UK") {
+		t.Errorf("aliases: match country failed for aliases")
+	}
+}
+
+func TestImportCSV(t *testing.T) {
+	dir, err := ioutil.TempDir("", "import")
+	if err != nil {
+		t.Fatalf("import: failed to create dir:%s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	s := &Data{ID: 2, Country: "Testland"}
+	s.SetData(startDate, DataDeaths, []int{1, 2, 3})
+	defer func(d Slice) { std.dataset = d }(std.dataset)
+	std.dataset = Slice{s}
+
+	_, err = ParseImportMapping("date=Date,deaths=Deaths", "")
+	if err == nil {
+		t.Errorf("import: parsed mapping without an area")
+	}
+	_, err = ParseImportMapping("date=Date,area_id=Area,cases=Cases", "")
+	if err == nil {
+		t.Errorf("import: parsed mapping with unknown field")
+	}
+
+	m, err := ParseImportMapping("date=Date,country=Region,deaths=Deaths,confirmed=Cases", "02/01/2006")
+	if err != nil {
+		t.Fatalf("import: failed to parse mapping:%s", err)
+	}
+
+	p := filepath.Join(dir, "import.csv")
+	ioutil.WriteFile(p, []byte("Region,Date,Deaths,Cases\nTestland,23/01/2020,4,\"1,000\"\nTestland,24/01/2020,x,10\nNowhere,22/01/2020,1,1\nTestland,01/02/2020,9,9\n"), 0644)
+	summary, err := ImportCSV(p, m)
+	if err != nil {
+		t.Fatalf("import: failed to import:%s", err)
+	}
+	if summary.Rows != 4 || summary.Imported != 1 || summary.Skipped != 3 || summary.Changed != 2 || summary.Areas != 1 || len(summary.Errors) != 3 {
+		t.Errorf("import: wrong summary got:%s errors:%v", summary, summary.Errors)
+	}
+	imported, err := std.dataset.FindSeries(2)
+	if err != nil || imported.Days[1].Value(DataDeaths) != 4 || imported.Days[1].Value(DataConfirmed) != 1000 || imported.Days[1].Source(DataDeaths) != SourceImport || imported.Days[2].Value(DataDeaths) != 3 {
+		t.Errorf("import: wrong days got:%v", imported)
+	}
+
+	// Daily values set totals from the day before, and move later totals
+	m.Daily = true
+	ioutil.WriteFile(p, []byte("Region,Date,Deaths,Cases\nTestland,24/01/2020,2,0\nTestland,23/01/2020,1,1000\n"), 0644)
+	summary, err = ImportCSV(p, m)
+	if err != nil || summary.Imported != 2 {
+		t.Fatalf("import: failed to import daily values err:%v got:%s", err, summary)
+	}
+	imported, _ = std.dataset.FindSeries(2)
+	if imported.Days[1].Value(DataDeaths) != 2 || imported.Days[2].Value(DataDeaths) != 4 || imported.Days[2].Value(DataConfirmed) != 1000 {
+		t.Errorf("import: wrong days from daily values got:%v", imported.Days)
+	}
+	m.Daily = false
+
+	ioutil.WriteFile(p, []byte("Region,Day,Deaths,Cases\n"), 0644)
+	_, err = ImportCSV(p, m)
+	if err == nil {
+		t.Errorf("import: imported file with missing column")
+	}
+}
+
+// TestUpdate tests updates are applied to a copy of the dataset which is swapped in only if they succeed
+func TestUpdate(t *testing.T) {
+	s := &Data{ID: 2, Country: "Testland"}
+	s.SetData(startDate, DataDeaths, []int{1, 2, 3})
+	s.Days[2].Notes = []string{"note"}
+	defer func(d Slice) { std.dataset = d }(std.dataset)
+	std.dataset = Slice{s}
+
+	err := Update("test", func(next Slice) error {
+		next[0].Days[2].SetData(DataDeaths, 5)
+		return fmt.Errorf("failed")
+	})
+	if err == nil || std.dataset[0] != s || s.Days[2].Value(DataDeaths) != 3 {
+		t.Errorf("update: failed update changed dataset got:%v", std.dataset[0].Days)
+	}
+
+	err = Update("test", func(next Slice) error {
+		next[0].Days[2].SetData(DataDeaths, 5)
+		next[0].Days[2].Notes[0] = "changed"
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("update: failed to update:%s", err)
+	}
+	updated, err := FindSeries(2)
+	if err != nil || updated == s || updated.Days[2].Value(DataDeaths) != 5 || updated.Days[2].Notes[0] != "changed" {
+		t.Errorf("update: update not swapped in got:%v", updated)
+	}
+
+	// Series held from the previous dataset are unchanged
+	if s.Days[2].Value(DataDeaths) != 3 || s.Days[2].Notes[0] != "note" {
+		t.Errorf("update: previous series changed got:%v", s.Days)
+	}
+}
+
+// TestOverrides tests overrides are applied, survive updates from sources and are saved
+func TestOverrides(t *testing.T) {
+	dir, err := ioutil.TempDir("", "overrides")
+	if err != nil {
+		t.Fatalf("overrides: failed to create dir:%s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	global := &Data{ID: 1}
+	global.SetData(startDate, DataDeaths, []int{1, 2, 3})
+	s := &Data{ID: 2, Country: "Testland"}
+	s.SetData(startDate, DataDeaths, []int{1, 2, 3})
+	defer func(d Slice) { std.dataset = d }(std.dataset)
+	std.dataset = Slice{global, s}
+	defer LoadOverrides(filepath.Join(dir, "missing.csv"))
+
+	date := startDate.AddDate(0, 0, 2)
+	err = AddOverride(Override{AreaID: 2, Date: date, Kind: "deaths", Value: 4, Reason: "revised by ministry"}, "admin:test")
+	if err != nil {
+		t.Fatalf("overrides: failed to add:%s", err)
+	}
+	for _, o := range []Override{
+		{AreaID: 2, Date: date, Kind: "deaths", Value: 4},
+		{AreaID: 1, Date: date, Kind: "deaths", Value: 4, Reason: "global"},
+		{AreaID: 2, Date: date.AddDate(0, 0, 1), Kind: "deaths", Value: 4, Reason: "outside"},
+	} {
+		if AddOverride(o, "admin:test") == nil {
+			t.Errorf("overrides: added invalid override:%v", o)
+		}
+	}
+
+	updated, _ := FindSeries(2)
+	total, _ := FindSeries(1)
+	if updated.Days[2].Value(DataDeaths) != 4 || updated.Days[2].Source(DataDeaths) != SourceOverride || total.Days[2].Value(DataDeaths) != 4 {
+		t.Errorf("overrides: override not applied got:%v global:%v", updated.Days, total.Days)
+	}
+
+	// Sources don't replace overrides
+	Update("test", func(next Slice) error {
+		s, _ := next.FindSeries(2)
+		s.updateValue(s.Days[2], DataDeaths, 9, SourceJHU)
+		return nil
+	})
+	updated, _ = FindSeries(2)
+	if updated.Days[2].Value(DataDeaths) != 4 {
+		t.Errorf("overrides: source replaced override got:%v", updated.Days)
+	}
+
+	p := filepath.Join(dir, "overrides.csv")
+	err = SaveOverrides(p)
+	if err != nil {
+		t.Fatalf("overrides: failed to save:%s", err)
+	}
+	err = LoadOverrides(p)
+	overrides := Overrides()
+	if err != nil || len(overrides) != 1 || overrides[0].Reason != "revised by ministry" || !overrides[0].Date.Equal(date) {
+		t.Errorf("overrides: wrong overrides loaded err:%v got:%v", err, overrides)
+	}
+}
+
+// TestAudit tests changes to values are recorded in the audit log and saved to the audit file
+func TestAudit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	if err != nil {
+		t.Fatalf("audit: failed to create dir:%s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	s := &Data{ID: 2, Country: "Testland"}
+	s.SetData(startDate, DataDeaths, []int{1, 2, 3})
+	defer func(d Slice) { std.dataset = d }(std.dataset)
+	std.dataset = Slice{s}
+	defer func() { std.audit, std.auditPath = nil, "" }()
+
+	p := filepath.Join(dir, "audit.jsonl")
+	err = OpenAudit(p)
+	if err != nil {
+		t.Fatalf("audit: failed to open:%s", err)
+	}
+
+	err = Update("refresh", func(next Slice) error {
+		next[0].updateValue(next[0].Days[2], DataDeaths, 4, SourceJHU)
+		next[0].AddDays(1)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("audit: failed to update:%s", err)
+	}
+
+	// Only the changed value is recorded, days added are not changes to stored values
+	entries := AuditLog(nil, 10)
+	if len(entries) != 1 {
+		t.Fatalf("audit: wrong entries got:%v", entries)
+	}
+	e := entries[0]
+	if e.Actor != "refresh" || e.AreaID != 2 || e.Kind != "deaths" || e.Before != 3 || e.After != 4 || e.Source != "jhu" || !e.Date.Equal(s.Days[2].Date) {
+		t.Errorf("audit: wrong entry got:%v", e)
+	}
+
+	// Entries are reloaded from the audit file
+	std.audit = nil
+	err = OpenAudit(p)
+	if err != nil {
+		t.Fatalf("audit: failed to reopen:%s", err)
+	}
+	entries = AuditLog(func(e AuditEntry) bool { return e.AreaID == 2 }, 10)
+	if len(entries) != 1 || entries[0].After != 4 {
+		t.Errorf("audit: wrong entries loaded got:%v", entries)
+	}
+	if len(AuditLog(func(e AuditEntry) bool { return e.Actor == "admin" }, 10)) != 0 {
+		t.Errorf("audit: entries matched wrong actor")
+	}
+}
+
+// TestSeriesInterface tests areas and aggregates are read in the same way through Series
+func TestSeriesInterface(t *testing.T) {
+	date := time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC)
+	france := &Data{ID: 2, Country: "France", Population: 60}
+	france.AddDaysFrom(date, 2)
+	france.SetData(date, DataDeaths, []int{1, 4})
+	italy := &Data{ID: 3, Country: "Italy", Population: 50}
+	italy.AddDaysFrom(date, 2)
+	italy.SetData(date, DataDeaths, []int{2, 3})
+	italy.LastDay().Provisional = true
+
+	var s Series = france
+	from, to := s.Range()
+	if s.Metadata().Name != "France" || !from.Equal(date) || !to.Equal(date.AddDate(0, 0, 1)) || s.Daily(DataDeaths)[1] != 3 {
+		t.Errorf("series: wrong area series got:%v %s-%s", s.Metadata(), from, to)
+	}
+
+	s = NewAggregate("europe", Slice{france, italy})
+	totals, daily := s.Totals(DataDeaths), s.Daily(DataDeaths)
+	if len(totals) != 2 || totals[1] != 7 || daily[0] != 3 || daily[1] != 4 || s.Metadata().Population != 110 {
+		t.Errorf("series: wrong aggregate got:%v %v %v", totals, daily, s.Metadata())
+	}
+
+	// Iteration stops when fn returns false
+	var days []*Day
+	s.ForEachDay(func(day *Day) bool {
+		days = append(days, day)
+		return false
+	})
+	if len(days) != 1 || days[0].Value(DataDeaths) != 3 || days[0].Provisional {
+		t.Errorf("series: wrong days iterated got:%v", days)
+	}
+
+	defer func(d Slice) { std.dataset = d }(std.dataset)
+	std.dataset = Slice{france, italy}
+	group, err := GroupSeries(GroupEurope)
+	if err != nil || len(group.Members()) != 2 || group.Totals(DataDeaths)[1] != 7 {
+		t.Errorf("series: wrong group series err:%v", err)
+	}
+	if _, err = GroupSeries("unknown"); err == nil {
+		t.Errorf("series: found unknown group")
+	}
+}
@@ -0,0 +1,147 @@
+package series
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// SexCounts holds cumulative deaths and confirmed cases by sex on a day
+// totals may be less than the day totals, as sources do not always know the sex of each case
+type SexCounts struct {
+	MaleDeaths      int `json:"male_deaths"`
+	FemaleDeaths    int `json:"female_deaths"`
+	MaleConfirmed   int `json:"male_confirmed"`
+	FemaleConfirmed int `json:"female_confirmed"`
+}
+
+// MaleDeathsPercent returns male deaths as a percentage of deaths with a known sex
+func (c *SexCounts) MaleDeathsPercent() float64 {
+	return percent(c.MaleDeaths, c.MaleDeaths+c.FemaleDeaths)
+}
+
+// FemaleDeathsPercent returns female deaths as a percentage of deaths with a known sex
+func (c *SexCounts) FemaleDeathsPercent() float64 {
+	return percent(c.FemaleDeaths, c.MaleDeaths+c.FemaleDeaths)
+}
+
+// MaleConfirmedPercent returns male cases as a percentage of cases with a known sex
+func (c *SexCounts) MaleConfirmedPercent() float64 {
+	return percent(c.MaleConfirmed, c.MaleConfirmed+c.FemaleConfirmed)
+}
+
+// FemaleConfirmedPercent returns female cases as a percentage of cases with a known sex
+func (c *SexCounts) FemaleConfirmedPercent() float64 {
+	return percent(c.FemaleConfirmed, c.MaleConfirmed+c.FemaleConfirmed)
+}
+
+// SexDay is the breakdown by sex for one day, with the male share of values with a known sex
+type SexDay struct {
+	Date time.Time `json:"date"`
+	SexCounts
+	MaleDeathsPercent    float64 `json:"male_deaths_percent"`
+	MaleConfirmedPercent float64 `json:"male_confirmed_percent"`
+}
+
+// HasSexBreakdown returns true if any day in this series has counts by sex
+func (d *Data) HasSexBreakdown() bool {
+	return d.LastSexCounts() != nil
+}
+
+// LastSexCounts returns the latest counts by sex in this series, or nil if none
+func (d *Data) LastSexCounts() *SexCounts {
+	for i := len(d.Days) - 1; i >= 0; i-- {
+		if d.Days[i].Sex != nil {
+			return d.Days[i].Sex
+		}
+	}
+	return nil
+}
+
+// SexDays returns the breakdown by sex for each day which has one, oldest first
+func (d *Data) SexDays() []SexDay {
+	days := []SexDay{}
+	for _, day := range d.Days {
+		if day.Sex == nil {
+			continue
+		}
+		days = append(days, SexDay{
+			Date:                 day.Date,
+			SexCounts:            *day.Sex,
+			MaleDeathsPercent:    day.Sex.MaleDeathsPercent(),
+			MaleConfirmedPercent: day.Sex.MaleConfirmedPercent(),
+		})
+	}
+	return days
+}
+
+// LoadSex loads counts by sex into the default store, see Store.LoadSex
+func LoadSex(p string) error {
+	return DefaultStore().LoadSex(p)
+}
+
+// LoadSex loads counts by sex from the specified file and sets them on days by area id and date
+// rows are area_id,date,male_deaths,female_deaths,male_confirmed,female_confirmed with cumulative values
+// a missing file is not an error as only some sources publish a breakdown by sex
+// dataset must be locked while performing this operation
+func (st *Store) LoadSex(p string) error {
+	return st.dataset.loadSex(p, all)
+}
+
+// loadSex loads counts by sex from the specified file for the series in the slice for which include returns true
+func (slice Slice) loadSex(p string, include func(*Data) bool) error {
+	header := func(row []string) error {
+		if len(row) < 6 || row[0] != "area_id" || row[1] != "date" || row[2] != "male_deaths" {
+			return fmt.Errorf("sex: invalid header row in file:%s row:%s", p, row)
+		}
+		return nil
+	}
+
+	_, err := streamCSV(p, header, func(row []string) error {
+		if len(row) < 6 {
+			return fmt.Errorf("sex: invalid row len for row:%s", row)
+		}
+		areaID, err := strconv.Atoi(row[0])
+		if err != nil {
+			return fmt.Errorf("sex: invalid area id at row:%s", row)
+		}
+		s, err := slice.FindSeries(areaID)
+		if err != nil {
+			return fmt.Errorf("sex: unknown area at row:%s", row)
+		}
+		if !include(s) {
+			return nil
+		}
+		date, err := time.Parse("2006-01-02", row[1])
+		if err != nil {
+			return fmt.Errorf("sex: invalid date at row:%s", row)
+		}
+
+		values := make([]int, 4)
+		for j := range values {
+			values[j], err = strconv.Atoi(row[j+2])
+			if err != nil || values[j] < 0 {
+				return fmt.Errorf("sex: invalid value at row:%s", row)
+			}
+		}
+
+		// Ignore days outside the series
+		for _, day := range s.Days {
+			if day.Date.Equal(date) {
+				day.Sex = &SexCounts{
+					MaleDeaths:      values[0],
+					FemaleDeaths:    values[1],
+					MaleConfirmed:   values[2],
+					FemaleConfirmed: values[3],
+				}
+				break
+			}
+		}
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
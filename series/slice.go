@@ -3,6 +3,7 @@ package series
 import (
 	"fmt"
 	"log"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -22,6 +23,31 @@ func (slice Slice) Less(i, j int) bool {
 	return slice[i].Country < slice[j].Country
 }
 
+// Rank returns a copy of the slice ordered by the latest value of the data kind given, largest first
+// if perCapita is true values are per 100k population and areas without population are excluded
+func (slice Slice) Rank(dataKind int, perCapita bool) Slice {
+	ranked := make(Slice, 0, len(slice))
+	for _, s := range slice {
+		if perCapita && s.Population == 0 {
+			continue
+		}
+		ranked = append(ranked, s)
+	}
+
+	value := func(s *Data) float64 {
+		v := float64(s.LastDay().Value(dataKind))
+		if perCapita {
+			return s.Per100k(v)
+		}
+		return v
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return value(ranked[i]) > value(ranked[j])
+	})
+	return ranked
+}
+
 // AddToday adds a day for today's date to the end of the dataset
 // if today already exists on the global slice, it does nothing
 func (slice Slice) AddToday() error {
@@ -33,7 +59,8 @@ func (slice Slice) AddToday() error {
 
 	// Work out whether we already have today in the first slice data
 	// NB we assume a certain start date for today
-	days := int(time.Now().UTC().Sub(seriesStartDate).Hours()/24) + 1
+	// Days archived are not in memory
+	days := int(time.Now().UTC().Sub(slice.settings().config.StartDate).Hours()/24) + 2 - slice.firstDay()
 	if days <= len(slice[0].Days) {
 		log.Printf("series: addtoday have enough days:%d global days:%d", days, len(slice[0].Days))
 		return nil
@@ -87,6 +114,11 @@ func (slice Slice) FindSeries(seriesID int) (*Data, error) {
 
 // DaysFrom returns day counts from a series of numbers
 func (slice Slice) DaysFrom(startDeaths int) []string {
+	return slice.AlignedDaysFrom(DataDeaths, startDeaths)
+}
+
+// AlignedDaysFrom returns day labels for the longest series aligned from value n of dataKind
+func (slice Slice) AlignedDaysFrom(dataKind, n int) []string {
 	if len(slice) == 0 {
 		return nil
 	}
@@ -96,15 +128,46 @@ func (slice Slice) DaysFrom(startDeaths int) []string {
 	count := 0
 	longest := slice[0]
 	for _, s := range slice {
-		deaths := s.DeathsFrom(startDeaths)
-		if len(deaths) > count {
-			count = len(deaths)
+		values := s.AlignedFrom(dataKind, n)
+		if len(values) > count {
+			count = len(values)
 			longest = s
 		}
 	}
 
 	// Return date labels from that series
-	return longest.DaysFrom(longest.DeathsFrom(startDeaths))
+	return longest.DaysFrom(longest.AlignedFrom(dataKind, n))
+}
+
+// RollupProvinces recalculates all rollup country series by summing their provinces
+// dataset must be locked while performing this operation
+func (slice Slice) RollupProvinces() error {
+
+	// Find the rollup series and reset them as we're recalculating from scratch
+	rollups := make(map[string]*Data)
+	for _, s := range slice {
+		if s.Rollup {
+			s.ResetDays()
+			rollups[s.Country] = s
+		}
+	}
+
+	// Merge each province into the rollup for its country (if any)
+	for _, s := range slice {
+		if !s.IsProvince() {
+			continue
+		}
+		rollup, ok := rollups[s.Country]
+		if !ok {
+			continue
+		}
+		err := rollup.MergeSeries(s)
+		if err != nil {
+			return fmt.Errorf("series: failed to roll up province:%s error:%s", s, err)
+		}
+	}
+
+	return nil
 }
 
 // PrintSeries uses our stored data to fetch a series
@@ -127,6 +190,7 @@ func (slice Slice) PrintToday() {
 }
 
 // CountryOptions returns a set of options for the country dropdown (including a global one)
+// countries are labelled with total deaths and an arrow for the trend in daily deaths
 func (slice Slice) CountryOptions() (options []Option) {
 
 	options = append(options, Option{Name: "Global", Value: ""})
@@ -137,6 +201,10 @@ func (slice Slice) CountryOptions() (options []Option) {
 			if s.TotalDeaths() > 0 {
 				name = fmt.Sprintf("%s (%d Deaths)", s.Country, s.TotalDeaths())
 			}
+			// Show the trend in daily deaths where known
+			if arrow := s.Trend(DataDeaths).Arrow(); arrow != "" {
+				name = fmt.Sprintf("%s %s", name, arrow)
+			}
 			options = append(options, Option{Name: name, Value: s.Key(s.Country)})
 		}
 	}
@@ -201,8 +269,8 @@ func (slice Slice) mergeTimeSeriesCSV(records [][]string, dataType int) (Slice,
 
 		} else {
 
-			// Fetch data to match series
-			country := row[1]
+			// Fetch data to match series, using our names for countries so that aliases do not create new series
+			country := slice.settings().CountryName(row[1])
 			province := row[0]
 
 			// We ignore rows which match ,CA etc
@@ -218,14 +286,14 @@ func (slice Slice) mergeTimeSeriesCSV(records [][]string, dataType int) (Slice,
 			}
 
 			// Fetch the series
-			var series *Data
-			series, _ = slice.FetchSeries(country, province)
+			series, err := slice.FetchSeries(country, province)
 
 			// If we don't have one yet, create one
-			if !series.Valid() {
+			if err != nil {
 				series = &Data{
 					Country:  country,
 					Province: province,
+					store:    slice.store(),
 				}
 				slice = append(slice, series)
 			}
@@ -297,14 +365,9 @@ func (slice Slice) mergeDailyCountryCSV(records [][]string, dataType int) (Slice
 		} else {
 
 			// Fetch data to match series
-			country := row[0]
+			country := slice.settings().CountryName(row[0])
 			province := ""
 
-			// Fix data
-			if country == "Taiwan*" {
-				country = "Taiwan"
-			}
-
 			// Fetch the series
 			series, err := slice.FetchSeries(country, province)
 			if err != nil {
@@ -358,7 +421,7 @@ func (slice Slice) mergeDailyStateCSV(records [][]string, dataType int) (Slice,
 		} else {
 
 			// Fetch data to match series
-			country := row[2]
+			country := slice.settings().CountryName(row[2])
 			province := row[1]
 
 			if province == "Virgin Islands, U.S" {
@@ -0,0 +1,110 @@
+package series
+
+import (
+	"fmt"
+	"math"
+)
+
+// Smoother returns smoothed values for a series of daily values, one for each value
+type Smoother func(values []float64) []float64
+
+// Smoothers are the smoothers available for charts by name
+var Smoothers = map[string]Smoother{
+	"kalman": KalmanSmooth,
+	"loess":  LoessSmooth,
+}
+
+// kalmanLevelChange is the standard deviation of the daily change in level assumed by KalmanSmooth, as a fraction of the level
+const kalmanLevelChange = 0.1
+
+// loessSpan is the number of days used for each local regression by LoessSmooth
+const loessSpan = 15
+
+// Smoothed returns the daily values of dataKind on complete days smoothed by the smoother name, or nil if unknown
+// provisional days are left out as their values are incomplete and would pull down the smoothed values before them
+// smoothers are most useful for small areas, where daily values are too noisy for a moving average to show the trend
+func (d *Data) Smoothed(dataKind int, name string) []float64 {
+	smoother, ok := Smoothers[name]
+	if !ok {
+		return nil
+	}
+	return d.Memo(fmt.Sprintf("smoothed:%d:%s", dataKind, name), func() interface{} {
+		daily := d.DailyValues(dataKind)[:d.CompleteCount()]
+		values := make([]float64, len(daily))
+		for i, v := range daily {
+			values[i] = float64(v)
+		}
+		return smoother(values)
+	}).([]float64)
+}
+
+// KalmanSmooth estimates the level underlying values with a Kalman filter, treating each value as a noisy count of it
+// the level is assumed to change by about kalmanLevelChange each day, and counts to vary by their square root by chance
+// so large areas follow the values closely while small areas with a few cases a day are smoothed strongly
+// the filter uses only the values up to each day, so like a trailing average it lags changes in the trend
+func KalmanSmooth(values []float64) []float64 {
+	smoothed := make([]float64, len(values))
+	if len(values) == 0 {
+		return smoothed
+	}
+
+	level, variance := values[0], math.Max(values[0], 1)
+	smoothed[0] = level
+	for i := 1; i < len(values); i++ {
+		// Predict the level is unchanged, with uncertainty growing in proportion to it
+		change := kalmanLevelChange * math.Max(level, 1)
+		variance += change * change
+
+		// Update from the value, counts vary by the square root of the level
+		noise := math.Max(level, 1)
+		gain := variance / (variance + noise)
+		level += gain * (values[i] - level)
+		variance *= 1 - gain
+		smoothed[i] = level
+	}
+	return smoothed
+}
+
+// LoessSmooth fits a line to the loessSpan values around each value, weighted by the tricube of their distance
+// the fit uses values either side, so unlike a trailing average it does not lag, but the last values may be revised
+// as later values are added, values at the ends use the nearest loessSpan values
+func LoessSmooth(values []float64) []float64 {
+	smoothed := make([]float64, len(values))
+	span := loessSpan
+	if span > len(values) {
+		span = len(values)
+	}
+
+	for i := range values {
+		// Centre the window on i where possible, or shift it at the ends
+		start := i - span/2
+		if start < 0 {
+			start = 0
+		}
+		if start+span > len(values) {
+			start = len(values) - span
+		}
+
+		maxDistance := math.Max(float64(i-start), float64(start+span-1-i)) + 1
+		var sw, sx, sy, sxx, sxy float64
+		for j := start; j < start+span; j++ {
+			x := float64(j - i)
+			u := math.Abs(x) / maxDistance
+			w := math.Pow(1-u*u*u, 3)
+			sw += w
+			sx += w * x
+			sy += w * values[j]
+			sxx += w * x * x
+			sxy += w * x * values[j]
+		}
+
+		// The fitted line at x = 0 is its intercept, or the weighted mean if the slope cannot be found
+		det := sw*sxx - sx*sx
+		if det == 0 {
+			smoothed[i] = sy / sw
+			continue
+		}
+		smoothed[i] = (sy*sxx - sx*sxy) / det
+	}
+	return smoothed
+}
@@ -0,0 +1,327 @@
+package series
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxSnapshots is the number of snapshot files kept, older files are removed
+const maxSnapshots = 30
+
+// snapshotIndex is the name of the index file in the snapshots directory
+const snapshotIndex = "snapshots.json"
+
+// deltaSuffix ends the names of snapshots saved with values as the change from the previous row of the area
+// snapshots saved before without it hold a copy of the series file
+const deltaSuffix = ".delta.csv"
+
+// SnapshotFile records a saved copy of the series file after a refresh
+type SnapshotFile struct {
+	// Version is incremented for each snapshot saved
+	Version int `json:"version"`
+
+	// UTC time the snapshot was saved
+	CreatedAt time.Time `json:"created_at"`
+
+	// Hex encoded sha256 hash of the series file contents, before values are delta encoded
+	Hash string `json:"hash"`
+
+	// Name of the file within the snapshots directory
+	Name string `json:"name"`
+}
+
+// Snapshots returns the snapshots saved in dir, oldest first
+// a missing index is not an error, no snapshots are returned
+func Snapshots(dir string) ([]SnapshotFile, error) {
+	data, err := ioutil.ReadFile(filepath.Join(filepath.Clean(dir), snapshotIndex))
+	if os.IsNotExist(err) {
+		return []SnapshotFile{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var snapshots []SnapshotFile
+	err = json.Unmarshal(data, &snapshots)
+	if err != nil {
+		return nil, fmt.Errorf("snapshots: failed to decode index:%s", err)
+	}
+	return snapshots, nil
+}
+
+// SaveSnapshot saves a copy of the series file at seriesPath to dir with its checksum
+// values are saved as the change from the previous row of the area, which are small beside cumulative totals
+// if the contents are unchanged since the last snapshot no snapshot is saved and the last is returned
+func SaveSnapshot(dir, seriesPath string) (*SnapshotFile, error) {
+	dir = filepath.Clean(dir)
+	data, err := ioutil.ReadFile(filepath.Clean(seriesPath))
+	if err != nil {
+		return nil, err
+	}
+
+	snapshots, err := Snapshots(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	hash := checksum(data)
+	if len(snapshots) > 0 && snapshots[len(snapshots)-1].Hash == hash {
+		return &snapshots[len(snapshots)-1], nil
+	}
+
+	snapshot := SnapshotFile{
+		Version:   1,
+		CreatedAt: time.Now().UTC(),
+		Hash:      hash,
+	}
+	if len(snapshots) > 0 {
+		snapshot.Version = snapshots[len(snapshots)-1].Version + 1
+	}
+	snapshot.Name = fmt.Sprintf("series-%d-%s%s", snapshot.Version, hash[:12], deltaSuffix)
+
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+	err = ioutil.WriteFile(filepath.Join(dir, snapshot.Name), deltaEncode(data, 1), 0644)
+	if err != nil {
+		return nil, err
+	}
+	snapshots = append(snapshots, snapshot)
+
+	// Remove the oldest snapshots over our limit
+	for len(snapshots) > maxSnapshots {
+		os.Remove(filepath.Join(dir, snapshots[0].Name))
+		snapshots = snapshots[1:]
+	}
+
+	err = saveSnapshotIndex(dir, snapshots)
+	if err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
+// FindSnapshot returns the snapshot with version in dir
+func FindSnapshot(dir string, version int) (*SnapshotFile, error) {
+	snapshots, err := Snapshots(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range snapshots {
+		if s.Version == version {
+			return &s, nil
+		}
+	}
+	return nil, fmt.Errorf("snapshots: version not found:%d", version)
+}
+
+// ReadSnapshot returns the contents of the series file saved in the snapshot, verifying the checksum
+// cumulative values are reconstructed from the changes in delta encoded snapshots
+func ReadSnapshot(dir string, snapshot *SnapshotFile) ([]byte, error) {
+	data, err := ioutil.ReadFile(filepath.Join(filepath.Clean(dir), snapshot.Name))
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasSuffix(snapshot.Name, deltaSuffix) {
+		data = deltaEncode(data, -1)
+	}
+	if checksum(data) != snapshot.Hash {
+		return nil, fmt.Errorf("snapshots: checksum mismatch for version:%d", snapshot.Version)
+	}
+	return data, nil
+}
+
+// RestoreSnapshot replaces the series file at seriesPath with the snapshot version given
+// the checksum is verified first, callers should reload data after restoring
+func RestoreSnapshot(dir string, version int, seriesPath string) error {
+	snapshot, err := FindSnapshot(dir, version)
+	if err != nil {
+		return err
+	}
+	data, err := ReadSnapshot(dir, snapshot)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Clean(seriesPath), data, 0644)
+}
+
+// DiffSnapshots returns the values which differ between snapshot versions in the default store, see Store.DiffSnapshots
+func DiffSnapshots(dir string, from, to int, seriesPath string) ([]Revision, error) {
+	return DefaultStore().DiffSnapshots(dir, from, to, seriesPath)
+}
+
+// DiffSnapshots returns every value which differs between snapshot versions from and to in dir
+// a version of 0 uses the current series file at seriesPath, so changes can be checked before a rollback
+// changes are ordered by area and date, values missing from a file are zero
+// the files are read with the metrics and start date of this store
+func (st *Store) DiffSnapshots(dir string, from, to int, seriesPath string) ([]Revision, error) {
+	before, err := readSnapshotValues(dir, from, seriesPath, len(st.kinds))
+	if err != nil {
+		return nil, err
+	}
+	after, err := readSnapshotValues(dir, to, seriesPath, len(st.kinds))
+	if err != nil {
+		return nil, err
+	}
+
+	// Include keys from both files, as rows are omitted for zero values
+	keys := make(map[snapshotKey]bool, len(after))
+	for k := range before {
+		keys[k] = true
+	}
+	for k := range after {
+		keys[k] = true
+	}
+
+	revisions := []Revision{}
+	zero := make([]int, len(st.kinds))
+	for k := range keys {
+		b, a := before[k], after[k]
+		if b == nil {
+			b = zero
+		}
+		if a == nil {
+			a = zero
+		}
+		for i, kind := range st.kinds {
+			if b[i] != a[i] {
+				revisions = append(revisions, Revision{
+					AreaID: k.areaID,
+					Date:   st.config.StartDate.AddDate(0, 0, k.day-1),
+					Kind:   DataKindName(kind),
+					Before: b[i],
+					After:  a[i],
+				})
+			}
+		}
+	}
+
+	sort.Slice(revisions, func(i, j int) bool {
+		ri, rj := revisions[i], revisions[j]
+		if ri.AreaID != rj.AreaID {
+			return ri.AreaID < rj.AreaID
+		}
+		if !ri.Date.Equal(rj.Date) {
+			return ri.Date.Before(rj.Date)
+		}
+		return ri.Kind < rj.Kind
+	})
+	return revisions, nil
+}
+
+// snapshotKey identifies one row of a series file by day number and area
+type snapshotKey struct {
+	day    int
+	areaID int
+}
+
+// readSnapshotValues reads the values of each row in snapshot version, or the series file if version is 0
+// rows have the number of metrics given, rows with fewer values are skipped
+func readSnapshotValues(dir string, version int, seriesPath string, metrics int) (map[snapshotKey][]int, error) {
+	var data []byte
+	var err error
+	if version == 0 {
+		data, err = ioutil.ReadFile(filepath.Clean(seriesPath))
+	} else {
+		var snapshot *SnapshotFile
+		snapshot, err = FindSnapshot(dir, version)
+		if err == nil {
+			data, err = ReadSnapshot(dir, snapshot)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = -1
+	rows, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("snapshots: failed to read version:%d error:%s", version, err)
+	}
+
+	values := make(map[snapshotKey][]int, len(rows))
+	for i, row := range rows {
+		// Skip the header row
+		if i == 0 || len(row) < 2+metrics {
+			continue
+		}
+		v := intValues(row[:2+metrics])
+		values[snapshotKey{day: v[0], areaID: v[1]}] = v[2:]
+	}
+	return values, nil
+}
+
+// saveSnapshotIndex writes the index of snapshots to dir
+func saveSnapshotIndex(dir string, snapshots []SnapshotFile) error {
+	data, err := json.MarshalIndent(snapshots, "", "  ")
+	if err != nil {
+		return fmt.Errorf("snapshots: failed to encode index:%s", err)
+	}
+	return ioutil.WriteFile(filepath.Join(dir, snapshotIndex), data, 0644)
+}
+
+// deltaEncode returns the series file data with the values of each row replaced by the change from the previous row
+// of the same area if sign is 1, or the delta encoded data with changes replaced by the values again if sign is -1
+// rows are matched to areas by the area_id column, and values are only changed where they are written as plain ints
+// so that decoding returns exactly the bytes encoded, and rows in files from older versions are left as they are
+// the values of each row are the columns named for metrics in the header row, which is left unchanged
+func deltaEncode(data []byte, sign int) []byte {
+	lines := bytes.Split(data, []byte("\n"))
+	previous := make(map[string][]int)
+	header := strings.Split(string(lines[0]), ",")
+	metrics := len(header) - 2
+	if header[len(header)-1] == "sources" {
+		metrics--
+	}
+	if metrics < 0 {
+		metrics = 0
+	}
+	for i, line := range lines {
+		// Skip the header row
+		if i == 0 {
+			continue
+		}
+		cols := strings.Split(string(line), ",")
+		if len(cols) < 3 {
+			continue
+		}
+		last := previous[cols[1]]
+		if last == nil {
+			last = make([]int, metrics)
+			previous[cols[1]] = last
+		}
+		for j := 2; j < len(cols) && j < 2+metrics; j++ {
+			v, err := strconv.Atoi(cols[j])
+			if err != nil || strconv.Itoa(v) != cols[j] {
+				continue
+			}
+			if sign > 0 {
+				cols[j] = strconv.Itoa(v - last[j-2])
+				last[j-2] = v
+			} else {
+				last[j-2] += v
+				cols[j] = strconv.Itoa(last[j-2])
+			}
+		}
+		lines[i] = []byte(strings.Join(cols, ","))
+	}
+	return bytes.Join(lines, []byte("\n"))
+}
+
+// checksum returns the hex encoded sha256 hash of data
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
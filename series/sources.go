@@ -0,0 +1,265 @@
+package series
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Source identifies the loader which populated a value on a day
+type Source uint8
+
+// Sources of day data, SourceUnknown is used for values loaded before sources were recorded
+const (
+	SourceUnknown Source = iota
+	SourceJHU
+	SourceUKGov
+	SourceCalculated
+	SourceImport
+	SourceOverride
+)
+
+// sourceNames are the names of each source for machines, indexed by Source
+var sourceNames = []string{"", "jhu", "ukgov", "calculated", "import", "override"}
+
+// String returns the name of this source, or an empty string if unknown
+func (s Source) String() string {
+	if int(s) >= len(sourceNames) {
+		return ""
+	}
+	return sourceNames[s]
+}
+
+// SourceFromName returns the source for a name returned by String
+// SourceUnknown is returned if the name is unknown
+func SourceFromName(name string) Source {
+	for i, n := range sourceNames {
+		if n == name && n != "" {
+			return Source(i)
+		}
+	}
+	return SourceUnknown
+}
+
+// maxConflicts is the number of conflicts kept for audit, older conflicts are dropped
+const maxConflicts = 1000
+
+// defaultSourcePriority ranks sources when more than one provide the same value, unless a store is given another
+// values with an unknown source have the lowest priority, imported values have the highest
+var defaultSourcePriority = map[Source]int{
+	SourceImport: 3,
+	SourceUKGov:  2,
+	SourceJHU:    1,
+}
+
+// Conflict records a value which was not used because another source had priority
+type Conflict struct {
+	AreaID      int       `json:"area_id"`
+	Date        time.Time `json:"date"`
+	Kind        string    `json:"kind"`
+	Winner      string    `json:"winner"`
+	WinnerValue int       `json:"winner_value"`
+	Loser       string    `json:"loser"`
+	LoserValue  int       `json:"loser_value"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// ParseSourcePriority returns the priority of sources from the names given, highest priority first, see StoreConfig
+// sources not named have the lowest priority
+func ParseSourcePriority(names []string) (map[Source]int, error) {
+	priority := make(map[Source]int, len(names))
+	for i, name := range names {
+		source := SourceFromName(strings.TrimSpace(name))
+		if source == SourceUnknown {
+			return nil, fmt.Errorf("series: unknown source in priority:%s", name)
+		}
+		priority[source] = len(names) - i
+	}
+	return priority, nil
+}
+
+// Conflicts returns the values which lost to higher priority sources in the default store, see Store.Conflicts
+func Conflicts() []Conflict {
+	return DefaultStore().Conflicts()
+}
+
+// Conflicts returns the values which lost to higher priority sources in updates of this store, oldest first
+func (st *Store) Conflicts() []Conflict {
+	st.conflictsMutex.RLock()
+	defer st.conflictsMutex.RUnlock()
+	result := make([]Conflict, len(st.conflicts))
+	copy(result, st.conflicts)
+	return result
+}
+
+// SourceDiscrepancy is a value on which two sources disagree
+// Percent is the difference as a percentage of the larger value
+type SourceDiscrepancy struct {
+	Conflict
+	Percent float64 `json:"percent"`
+}
+
+// SourceDiscrepancies returns the source discrepancies in the default store, see Store.SourceDiscrepancies
+func SourceDiscrepancies(percent float64) []SourceDiscrepancy {
+	return DefaultStore().SourceDiscrepancies(percent)
+}
+
+// SourceDiscrepancies returns the values on which sources disagree by more than percent, largest first
+// only the latest conflict is used for each area, day and data kind
+func (st *Store) SourceDiscrepancies(percent float64) []SourceDiscrepancy {
+	type key struct {
+		areaID int
+		date   time.Time
+		kind   string
+	}
+
+	st.conflictsMutex.RLock()
+	latest := make(map[key]Conflict)
+	for _, c := range st.conflicts {
+		latest[key{c.AreaID, c.Date, c.Kind}] = c
+	}
+	st.conflictsMutex.RUnlock()
+
+	result := []SourceDiscrepancy{}
+	for _, c := range latest {
+		p := c.Percent()
+		if p > percent {
+			result = append(result, SourceDiscrepancy{Conflict: c, Percent: p})
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Percent == result[j].Percent {
+			return result[i].AreaID < result[j].AreaID
+		}
+		return result[i].Percent > result[j].Percent
+	})
+	return result
+}
+
+// Percent returns the difference between the values as a percentage of the larger value
+func (c Conflict) Percent() float64 {
+	max, diff := c.WinnerValue, c.WinnerValue-c.LoserValue
+	if c.LoserValue > max {
+		max = c.LoserValue
+	}
+	if diff < 0 {
+		diff = -diff
+	}
+	return percent(diff, max)
+}
+
+// String returns a summary of this discrepancy for logs
+func (d SourceDiscrepancy) String() string {
+	return fmt.Sprintf("area:%d date:%s kind:%s %s:%d %s:%d (%.1f%%)", d.AreaID, d.Date.Format("2006-01-02"), d.Kind, d.Winner, d.WinnerValue, d.Loser, d.LoserValue, d.Percent)
+}
+
+// updateValue updates the value of the data kind on day from source
+// if the existing value is from a different source, the source with higher priority in the store of the series wins
+// and the losing value is recorded as a conflict, otherwise values only increase
+// zero values are treated as not provided by the source
+// the series must not be in use by requests, it should be in a copy of the dataset within Update
+func (d *Data) updateValue(day *Day, dataKind, value int, source Source) {
+	current, existing := day.Value(dataKind), day.Source(dataKind)
+	if value <= 0 || current == value {
+		return
+	}
+
+	// Overrides are corrections made by hand, so are never replaced by sources
+	if existing == SourceOverride {
+		d.recordConflict(day.Date, dataKind, existing, current, source, value)
+		return
+	}
+
+	// The same source, or values with no source, are replaced only by higher values
+	if existing == source || existing == SourceUnknown {
+		if current < value {
+			day.SetData(dataKind, value)
+			day.SetSource(dataKind, source)
+		}
+		return
+	}
+
+	// Otherwise the higher priority source wins, and the loser is recorded
+	priority := d.settings().config.SourcePriority
+	if priority[source] > priority[existing] {
+		day.SetData(dataKind, value)
+		day.SetSource(dataKind, source)
+		d.recordConflict(day.Date, dataKind, source, value, existing, current)
+	} else {
+		d.recordConflict(day.Date, dataKind, existing, current, source, value)
+	}
+}
+
+// recordConflict records a conflict between sources in the store of the series, keeping only the latest maxConflicts
+// conflicts in series outside a store are not recorded
+func (d *Data) recordConflict(date time.Time, dataKind int, winner Source, winnerValue int, loser Source, loserValue int) {
+	st := d.store
+	if st == nil {
+		return
+	}
+	st.conflictsMutex.Lock()
+	defer st.conflictsMutex.Unlock()
+	st.conflicts = append(st.conflicts, Conflict{
+		AreaID:      d.ID,
+		Date:        date,
+		Kind:        DataKindName(dataKind),
+		Winner:      winner.String(),
+		WinnerValue: winnerValue,
+		Loser:       loser.String(),
+		LoserValue:  loserValue,
+		CreatedAt:   time.Now().UTC(),
+	})
+	if len(st.conflicts) > maxConflicts {
+		st.conflicts = st.conflicts[len(st.conflicts)-maxConflicts:]
+	}
+}
+
+// Source returns the source of the data on this day for the given data kind
+func (d *Day) Source(dataKind int) Source {
+	c := d.column(dataKind)
+	if c == nil {
+		return SourceUnknown
+	}
+	return c.source(d.index)
+}
+
+// SetSource sets the source of the data on this day for the given data kind
+// days not in a series are ignored
+func (d *Day) SetSource(dataKind int, source Source) {
+	if d.series == nil {
+		return
+	}
+	d.series.columnFor(dataKind).setSource(d.index, source)
+}
+
+// formatSources returns the sources on this day in the form jhu;jhu;jhu;
+// an empty string is returned if no sources are known
+func (d *Day) formatSources() string {
+	kinds := d.dataKinds()
+	names := make([]string, len(kinds))
+	known := false
+	for i, kind := range kinds {
+		s := d.Source(kind)
+		names[i] = s.String()
+		known = known || s != SourceUnknown
+	}
+	if !known {
+		return ""
+	}
+	return strings.Join(names, ";")
+}
+
+// parseSources sets the sources on this day from a string produced by formatSources
+func (d *Day) parseSources(s string) {
+	if s == "" {
+		return
+	}
+	kinds := d.dataKinds()
+	for i, name := range strings.Split(s, ";") {
+		if i < len(kinds) {
+			d.SetSource(kinds[i], SourceFromName(name))
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package series
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// maxStats is the number of values cached for each series, the cache is cleared when full
+// copies of arbitrary date ranges share the cache, so this bounds its size
+const maxStats = 200
+
+// stats memoizes values derived from a series, it is shared by the series and copies of its periods
+type stats struct {
+	mu     sync.Mutex
+	values map[string]statsValue
+}
+
+// statsValue is a cached value with the state of the series it was computed from
+type statsValue struct {
+	updated time.Time
+	days    int
+	value   interface{}
+}
+
+// newStats returns an empty cache of derived values
+func newStats() *stats {
+	return &stats{values: make(map[string]statsValue)}
+}
+
+// Memo returns the value cached for this series under key, calling compute to set it if required
+// values are computed again once UpdatedAt or the number of days change
+// series without a cache, such as those constructed directly, always call compute
+// the value returned is shared, so callers must not modify it
+func (d *Data) Memo(key string, compute func() interface{}) interface{} {
+	if d.stats == nil {
+		return compute()
+	}
+	key = d.statsKey + key
+
+	d.stats.mu.Lock()
+	v, ok := d.stats.values[key]
+	d.stats.mu.Unlock()
+	if ok && v.updated.Equal(d.UpdatedAt) && v.days == len(d.Days) {
+		return v.value
+	}
+
+	value := compute()
+	d.stats.mu.Lock()
+	if len(d.stats.values) >= maxStats {
+		d.stats.values = make(map[string]statsValue)
+	}
+	d.stats.values[key] = statsValue{updated: d.UpdatedAt, days: len(d.Days), value: value}
+	d.stats.mu.Unlock()
+	return value
+}
+
+// shareStats shares the cache of this series with a copy of the days from start to end
+func (d *Data) shareStats(c *Data, start, end int) {
+	c.stats = d.stats
+	c.statsKey = fmt.Sprintf("%s%d-%d:", d.statsKey, start, end)
+}
@@ -8,16 +8,9 @@ import (
 	"sort"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
 )
 
-// Mutex to protect access to dataset below
-var mutex sync.RWMutex
-
-// Store our dataset as a local global, use mutex to access - no direct access
-var dataset Slice
-
 // FIXME unused except for import - move there
 // Data types for imported series
 const (
@@ -26,28 +19,77 @@ const (
 	DataConfirmed
 	DataRecovered
 	DataTested
+	DataExcess
+	DataFlu
+	DataVaccinated
+
+	// DataCustom is the first data kind of metrics of other datasets, see NewDataset
+	DataCustom
 )
 
+// DataKinds returns the data kinds stored on each day in the series file by the default store, see Store.DataKinds
+// excess deaths, the flu baseline and vaccinations are loaded separately from their own data files
+func DataKinds() []int {
+	return DefaultStore().DataKinds()
+}
+
+// DataKindName returns a name for the data kind suitable for machines, the name of its metric
+// unknown kinds have no name
+func DataKindName(dataKind int) string {
+	return metric(dataKind).Name
+}
+
+// DataKindFromName returns the data kind for a name in the default store, see Store.DataKindFromName
+func DataKindFromName(name string) int {
+	return DefaultStore().DataKindFromName(name)
+}
+
+// DataKindFromName returns the data kind for a name returned by DataKindName
+// DataNone is returned if the name is not a metric of the dataset of the store or loaded from its own data file
+func (st *Store) DataKindFromName(name string) int {
+	kind := st.config.Dataset.Kind(name)
+	if kind == DataNone && isLoadedKind(metricKind(name)) {
+		kind = metricKind(name)
+	}
+	return kind
+}
+
+// seriesHeader returns the header row of the series file, with a column named for each of the data kinds given
+func seriesHeader(kinds []int) []string {
+	header := []string{"day", "area_id"}
+	for _, kind := range kinds {
+		header = append(header, DataKindName(kind))
+	}
+	return append(header, "sources")
+}
+
 // FIXME Now unused, remove
 const (
 	DataTodayState   = 20
 	DataTodayCountry = 21
 )
 
+// AddToday adds a day to the default store, see Store.AddToday
+func AddToday() error {
+	return DefaultStore().AddToday()
+}
+
 // AddToday adds a day to our dataset
 // usually called after zero hours
-func AddToday() error {
+func (st *Store) AddToday() error {
 
 	// If we don't have it already, add a set of data for today
-	// Lock during add operation
-	mutex.Lock()
-	err := dataset.AddToday()
+	var seriesPath string
+	err := st.Update("daily", func(next Slice) error {
+		// The series file is saved in the data directory it was loaded from
+		seriesPath = filepath.Join(next.dataPath(st.config.DataPath), "series.csv")
+		return next.AddToday()
+	})
 	if err != nil {
 		return fmt.Errorf("series: failed to add today on series data:%s", err)
 	}
-	mutex.Unlock()
 
-	err = Save("data/series.csv")
+	err = st.Save(seriesPath)
 	if err != nil {
 		return fmt.Errorf("series: failed to save series data:%s", err)
 	}
@@ -55,9 +97,41 @@ func AddToday() error {
 	return nil
 }
 
-// LoadData reloads all data from our data files in dataPath
-// Dataset is locked for writing inside functions below
+// CompleteDays marks days on or before date as complete in the default store, see Store.CompleteDays
+func CompleteDays(date time.Time) {
+	DefaultStore().CompleteDays(date)
+}
+
+// CompleteDays marks days on or before date as complete in all series
+// this should be called once the source has published a complete file for date
+func (st *Store) CompleteDays(date time.Time) {
+	st.Update("daily", func(next Slice) error {
+		next.CompleteDays(date)
+		return nil
+	})
+}
+
+// CompleteDays marks days on or before date as complete in all series in the slice
+func (slice Slice) CompleteDays(date time.Time) {
+	for _, s := range slice {
+		s.CompleteDays(date)
+	}
+}
+
+// LoadData reloads all data in the default store from our data files in dataPath
 func LoadData(dataPath string) error {
+	return DefaultStore().loadData(dataPath)
+}
+
+// LoadData reloads all data from the data files in the data path of the store config
+// the new dataset is built without locking the dataset, and swapped in once complete
+// so requests are served from the current dataset until then, and never see data partially loaded
+func (st *Store) LoadData() error {
+	return st.loadData(st.Config().DataPath)
+}
+
+// loadData reloads all data from the data files in dataPath
+func (st *Store) loadData(dataPath string) error {
 	start := time.Now().UTC()
 	defer func() {
 		log.Printf("series: loaded data in %s", time.Now().UTC().Sub(start))
@@ -66,49 +140,240 @@ func LoadData(dataPath string) error {
 	// Sanitize input
 	dataPath = filepath.Clean(dataPath)
 
-	// Lock during load operation
-	mutex.Lock()
-	defer mutex.Unlock()
+	// Loads are serialized with updates, so that no update is lost when the new dataset is swapped in
+	st.updateMutex.Lock()
+	defer st.updateMutex.Unlock()
+
+	config := st.Config()
+
+	// Load aliases used by sources for country names before anything is matched on them
+	aliasPath := filepath.Join(dataPath, "aliases.csv")
+	err := st.LoadAliases(aliasPath)
+	if err != nil {
+		return fmt.Errorf("data: error loading aliases:%s data:%s", aliasPath, err)
+	}
+
+	// Corrections made by hand are kept with the store, they are applied to the dataset when parsed
+	overridesPath := filepath.Join(dataPath, "overrides.csv")
+	err = st.LoadOverrides(overridesPath)
+	if err != nil {
+		return fmt.Errorf("data: error loading overrides:%s data:%s", overridesPath, err)
+	}
+
+	// Use the cached dataset if the data files are unchanged since it was saved, else parse the files
+	var next Slice
+	files := &dataFiles{path: dataPath, interpolation: config.Interpolation, retentionDays: config.RetentionDays}
+	cacheKey := ""
+	if config.Cache && !config.LazyProvinces {
+		cacheKey, err = dataCacheKey(dataPath, config)
+		if err != nil {
+			return fmt.Errorf("data: error reading data files for cache:%s", err)
+		}
+		next = loadCache(dataPath, cacheKey)
+	}
+	if next != nil {
+		log.Printf("series: loaded %d series from cache", len(next))
+		for _, s := range next {
+			s.files = files
+			s.store = st
+		}
+		files.last = next.firstDay() + len(next[0].Days) - 1
+	} else {
+		next, err = st.parseData(dataPath, config, files)
+		if err != nil {
+			return err
+		}
+		if cacheKey != "" {
+			err = saveCache(filepath.Join(dataPath, CacheName), cacheFile{Key: cacheKey, Dataset: next})
+			if err != nil {
+				log.Printf("series: failed to save cache:%s", err)
+			}
+		}
+	}
+
+	// Data for yesterday and today may still be revised until the source publishes complete files
+	now := time.Now().UTC()
+	yesterday := time.Date(now.Year(), now.Month(), now.Day()-1, 0, 0, 0, 0, time.UTC)
+	for _, s := range next {
+		s.SetProvisional(yesterday)
+	}
+
+	// Check the global series is consistent with the other series, provinces are needed for this
+	if config.LazyProvinces {
+		log.Printf("series: skipped global series check with provinces pending")
+	} else {
+		discrepancies, err := next.CheckGlobal()
+		if err != nil {
+			return fmt.Errorf("series: failed to check global series:%s", err)
+		}
+		if len(discrepancies) > 0 {
+			log.Printf("series: global series has %d discrepancies, last:%s", len(discrepancies), discrepancies[len(discrepancies)-1])
+		}
+	}
+
+	// Report problems in the data, sources do revise totals so these are not fatal
+	validations := next.Validate()
+	if len(validations) > 0 {
+		v := validations[0]
+		log.Printf("series: %d series failed validation, first area:%d errors:%d %s", len(validations), v.AreaID, len(v.Errors), v.Errors[0])
+	}
+
+	// Finally sort the dataset by deaths, then alphabetically by country/province
+	sort.Stable(next)
+
+	// Swap in the new dataset, pending series in it are loaded from this data directory
+	// values which differ from those loaded before are audited, as the files may have been edited or restored
+	st.swap(next, "reload")
 
-	// Clear the existing data
-	dataset = Slice{}
+	// For debug, print today's data after load
+	//dataset.PrintToday()
+
+	return nil
+}
 
+// parseData returns a dataset parsed from the data files in dataPath, with the overrides of the store applied
+// the series are given files, the data files pending series are read from
+func (st *Store) parseData(dataPath string, config StoreConfig, files *dataFiles) (Slice, error) {
 	// First load the areas data - this sets up a series per area
 	areaPath := filepath.Join(dataPath, "areas.csv")
-	err := LoadAreas(areaPath)
+	next, err := Slice{}.loadAreas(areaPath)
 	if err != nil {
-		return fmt.Errorf("data: error loading areas:%s data:%s", areaPath, err)
+		return nil, fmt.Errorf("data: error loading areas:%s data:%s", areaPath, err)
+	}
+	for _, s := range next {
+		s.store = st
+	}
+
+	// Add any intervention events to areas
+	eventsPath := filepath.Join(dataPath, "events.csv")
+	err = next.loadEvents(eventsPath)
+	if err != nil {
+		return nil, fmt.Errorf("data: error loading events:%s data:%s", eventsPath, err)
+	}
+
+	// Days archived are not loaded, series start on the day after the last day archived
+	archive, err := loadArchiveIndex(dataPath)
+	if err != nil {
+		return nil, fmt.Errorf("data: error loading archive index:%s", err)
+	}
+	if archive != nil {
+		for _, s := range next {
+			s.setPreviousDay(archive.Before.AddDate(0, 0, -1))
+		}
+	}
+
+	// In lazy mode provinces are left pending, and loaded from the data files on first use
+	for _, s := range next {
+		s.files = files
+		s.pending = config.LazyProvinces && s.IsProvince()
 	}
 
 	// Now load our main series file - this contains all historical data
 	seriesPath := filepath.Join(dataPath, "series.csv")
-	err = Load(seriesPath)
+	days, err := next.load(seriesPath)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	files.last = next.firstDay() + days - 1
+
+	// Sort days and remove duplicates before anything is calculated from them
+	for _, s := range next {
+		removed := s.Normalize()
+		if removed > 0 {
+			log.Printf("series: removed %d duplicate days from series:%s", removed, s)
+		}
+	}
+
+	// Fill days skipped by the source, before values are calculated from the days either side
+	filled := next.Interpolate(config.Interpolation)
+	if filled > 0 {
+		log.Printf("series: interpolated %d skipped days with:%s", filled, config.Interpolation)
+	}
+
+	// Keep days older than the retention at weekly resolution, filling the days between weeks
+	retainFrom := daysAgo(files.retentionDays)
+	downsampled := next.Downsample(retainFrom)
+	if downsampled > 0 {
+		log.Printf("series: downsampled %d days before:%s", downsampled, retainFrom.Format("2006-01-02"))
 	}
 
 	// Add today if we don't have it
-	err = dataset.AddToday()
+	err = next.AddToday()
 	if err != nil {
-		return fmt.Errorf("series: failed to add today on series data:%s", err)
+		return nil, fmt.Errorf("series: failed to add today on series data:%s", err)
 	}
 
-	// Finally sort the dataset by deaths, then alphabetically by country/province
-	sort.Stable(dataset)
+	// Set excess deaths from weekly mortality data once all days are present
+	excessPath := filepath.Join(dataPath, "mortality.csv")
+	err = next.loadExcess(excessPath, all)
+	if err != nil {
+		return nil, fmt.Errorf("data: error loading mortality:%s data:%s", excessPath, err)
+	}
 
-	// For debug, print today's data after load
-	//dataset.PrintToday()
+	// Set the flu baseline from past flu seasons, compared with deaths like excess deaths
+	fluPath := filepath.Join(dataPath, "flu.csv")
+	err = next.loadFlu(fluPath, all)
+	if err != nil {
+		return nil, fmt.Errorf("data: error loading flu:%s data:%s", fluPath, err)
+	}
 
-	return nil
+	// Set people vaccinated from reports of vaccinations, carried forward to today
+	vaccinationsPath := filepath.Join(dataPath, "vaccinations.csv")
+	err = next.loadVaccinations(vaccinationsPath, all)
+	if err != nil {
+		return nil, fmt.Errorf("data: error loading vaccinations:%s data:%s", vaccinationsPath, err)
+	}
+
+	// Set the share of each variant from variant reports, carried forward to today
+	variantsPath := filepath.Join(dataPath, "variants.csv")
+	err = next.loadVariants(variantsPath, all)
+	if err != nil {
+		return nil, fmt.Errorf("data: error loading variants:%s data:%s", variantsPath, err)
+	}
+
+	// Set counts by sex where available
+	sexPath := filepath.Join(dataPath, "sex.csv")
+	err = next.loadSex(sexPath, all)
+	if err != nil {
+		return nil, fmt.Errorf("data: error loading sex:%s data:%s", sexPath, err)
+	}
+
+	// Add curated notes to days
+	notesPath := filepath.Join(dataPath, "notes.csv")
+	err = next.loadNotes(notesPath, all)
+	if err != nil {
+		return nil, fmt.Errorf("data: error loading notes:%s data:%s", notesPath, err)
+	}
+
+	// Apply corrections made by hand last, so that they replace values from the data files
+	st.ApplyOverrides(next)
+
+	return next, nil
+
+}
+
+// LoadAreas loads areas into the default store, see Store.LoadAreas
+func LoadAreas(p string) error {
+	return DefaultStore().LoadAreas(p)
 }
 
 // LoadAreas loads our areas from the specified areas file
 // dataset must be locked while performing this operation
-func LoadAreas(p string) error {
+func (st *Store) LoadAreas(p string) error {
+	areas, err := st.dataset.loadAreas(p)
+	if err != nil {
+		return err
+	}
+	st.dataset = areas
+	return nil
+}
+
+// loadAreas returns the slice with a series added for each area in the specified areas file
+func (slice Slice) loadAreas(p string) (Slice, error) {
 	// Open the areas CSV
 	rows, err := loadCSV(p)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// Walk rows reading area data (countries and provinces)
@@ -117,65 +382,89 @@ func LoadAreas(p string) error {
 		// validate header row
 		if i == 0 {
 			if row[0] != "country" || row[1] != "province" || row[2] != "area_id" || row[7] != "colour" {
-				return fmt.Errorf("areas: invalid header row in file:%s row:%s", p, row)
+				return nil, fmt.Errorf("areas: invalid header row in file:%s row:%s", p, row)
 			}
 			continue
 		}
 
 		s, err := NewData(row)
 		if err != nil {
-			return fmt.Errorf("areas: invalid row in file:%s row:%s error:%s", p, row, err)
+			return nil, fmt.Errorf("areas: invalid row in file:%s row:%s error:%s", p, row, err)
 		}
 
-		dataset = append(dataset, s)
+		slice = append(slice, s)
 	}
 
-	return nil
+	return slice, nil
+}
+
+// Save saves the series in the default store, see Store.Save
+func Save(p string) error {
+	return DefaultStore().Save(p)
 }
 
 // Save saves the existing series to a file at the path given
 // this is used for automatic updates of data from data sources
-func Save(p string) error {
+func (st *Store) Save(p string) error {
 
-	if len(dataset) == 0 {
+	// Lock the dataset for reading during save
+	st.mutex.RLock()
+	defer st.mutex.RUnlock()
+
+	if len(st.dataset) == 0 {
 		return fmt.Errorf("series: save on empty data set")
 	}
 
-	days := len(dataset[0].Days)
+	days := len(st.dataset[0].Days)
 	if days == 0 {
 		return fmt.Errorf("series: save on empty data set")
 	}
 
-	// Lock during save operation
-	mutex.Lock()
-	defer mutex.Unlock()
+	// Pending series are saved from copies loaded from the data files, so they stay pending in the dataset
+	// the series are sorted in a new slice, as requests may be reading the dataset
+	dataset, err := st.dataset.withPending(all)
+	if err != nil {
+		return err
+	}
+	dataset = append(Slice(nil), dataset...)
 
 	var seriesData [][]int
+	var sourcesData []string
 
 	// Sort the dataset by id for saving
 	sort.Slice(dataset, func(i, j int) bool {
 		return dataset[i].ID < dataset[j].ID
 	})
 
+	// The last day archived is saved when days are archived, so daily values for the first day are known when loaded
+	first := dataset.firstDay()
+	if first > 1 {
+		for _, s := range dataset {
+			d := s.PreviousDay
+			if d != nil && !d.IsZero() {
+				seriesData = append(seriesData, append([]int{first - 1, s.ID}, d.values()...))
+				sourcesData = append(sourcesData, d.formatSources())
+			}
+		}
+	}
+
 	// We save the series per day rather than every series at once
 	// so that additional days are at the end of the file
 	// For every series, save the data to an array (if non-zero)
 	// it would perhaps be more intuitive to order by area_id instead first
 	for i := 0; i < days; i++ {
-		dayNumber := i + 1
+		dayNumber := first + i
 		for _, s := range dataset {
 			d := s.Days[i]
-			if !d.IsZero() {
-				seriesData = append(seriesData, []int{dayNumber, s.ID, d.Deaths, d.Confirmed, d.Recovered, d.Tested})
+			if !d.IsZero() && !d.Interpolated {
+				seriesData = append(seriesData, append([]int{dayNumber, s.ID}, d.values()...))
+				sourcesData = append(sourcesData, d.formatSources())
 			}
 		}
 	}
 
-	// Resort the dataset in our preferred order
-	sort.Sort(dataset)
-
 	// Write the data out to files - our data is simple so we write directly
-	headerRow := fmt.Sprintf("day,area_id,deaths,confirmed,recovered,tested\n")
+	headerRow := strings.Join(seriesHeader(st.kinds), ",") + "\n"
 	var row string
 
 	// SERIES DATA file is saved at path given, over existing file if required
@@ -190,8 +479,12 @@ func Save(p string) error {
 		return fmt.Errorf("failed to write series file:%s", err)
 	}
 	// Write days
-	for _, d := range seriesData {
-		row = fmt.Sprintf("%d,%d,%d,%d,%d,%d\n", d[0], d[1], d[2], d[3], d[4], d[5])
+	for i, d := range seriesData {
+		row = ""
+		for _, v := range d {
+			row += strconv.Itoa(v) + ","
+		}
+		row += sourcesData[i] + "\n"
 		_, err = f.WriteString(row)
 		if err != nil {
 			return fmt.Errorf("failed to write day file:%s", err)
@@ -201,67 +494,140 @@ func Save(p string) error {
 	return nil
 }
 
+// Load loads the series file into the default store, see Store.Load
+func Load(p string) error {
+	return DefaultStore().Load(p)
+}
+
 // Load loads our global series file
 // this contains all data in the sparse format (no rows for zero data):
-// day, area_id, deaths, confirmed, recovered, tested
+// day, area_id, a column for each metric of the dataset, e.g. deaths, confirmed, recovered, tested, then sources
+// the sources column is optional, older files do not include it
 // dataset must be locked while performing this operation
-func Load(p string) error {
-
-	// Open the CSV file - one row per day per area
-	rows, err := loadCSV(p)
+func (st *Store) Load(p string) error {
+	files := &dataFiles{path: filepath.Dir(p)}
+	for _, s := range st.dataset {
+		s.files = files
+		s.store = st
+	}
+	days, err := st.dataset.load(p)
 	if err != nil {
 		return err
 	}
+	files.last = st.dataset.firstDay() + days - 1
+	return nil
+}
+
+// dataPath returns the data directory the slice was loaded from, or fallback if it was not loaded from files
+func (slice Slice) dataPath(fallback string) string {
+	for _, s := range slice {
+		if s.files != nil {
+			return s.files.path
+		}
+	}
+	return fallback
+}
 
-	// Check the day number on the last row in the series - we want this many days to load into
-	// we assume we start from 1 up to this day number
-	// this may or may not include today
-	days, err := strconv.Atoi(rows[len(rows)-1][0])
+// load loads the series file into the series in the slice which are not pending
+// and returns the number of days read
+func (slice Slice) load(p string) (int, error) {
+	days, skipped, err := slice.loadRows(p, isLoaded)
 	if err != nil {
-		// If dayno read fails, fall back to days up to but not including today
-		days = int(time.Now().UTC().Sub(seriesStartDate).Hours() / 24)
+		return 0, err
 	}
 
-	log.Printf("load: loading series:%s days:%d", p, days)
-
-	// For every series add the right number of days up to but not including today
-	// these days are initially zeroed out before loading from the file
-	for _, series := range dataset {
-		series.AddDays(days)
+	// If the file has no rows, fall back to days up to but not including today
+	if days == 0 {
+		days = int(time.Now().UTC().Sub(slice.settings().config.StartDate).Hours()/24) - slice.firstDay() + 1
+		for _, s := range slice {
+			if isLoaded(s) {
+				s.AddDays(days)
+			}
+		}
 	}
 
-	// Range rows loading data for each country from each row
-	for i, row := range rows {
-		// Validate header row
-		if i == 0 {
-			// We make assumptions about the start date rather than parsing the first date
-			// we could instead parse this date to be more flexible
-			if row[0] != "day" || row[1] != "area_id" || row[5] != "tested" {
-				return fmt.Errorf("series: invalid header row in file:%s row:%s", p, row)
+	log.Printf("load: loaded series:%s days:%d skipped:%d", p, days, skipped)
+	return days, nil
+}
+
+// loadRows loads rows from the series file at p into the series in the slice for which include returns true
+// days are added to those series as rows for later days are read, initially zeroed out
+// if days are archived, rows before the first day in memory are skipped, except the last day archived which sets the previous day
+// the number of days read from the first day in memory and the number of rows skipped are returned
+func (slice Slice) loadRows(p string, include func(*Data) bool) (int, int, error) {
+	days := 0
+	// Rows have the day, area id and a value for each metric, followed by sources
+	kinds := slice.settings().kinds
+	columns := 2 + len(kinds)
+	first := slice.firstDay()
+	header := func(row []string) error {
+		// We make assumptions about the start date rather than parsing the first date
+		// we could instead parse this date to be more flexible
+		// the metric columns must match the current dataset, so values are not read as the wrong metric
+		want := seriesHeader(kinds)
+		if len(row) < columns {
+			return fmt.Errorf("series: invalid header row in file:%s row:%s", p, row)
+		}
+		for i := 0; i < columns; i++ {
+			if row[i] != want[i] {
+				return fmt.Errorf("series: invalid header row in file:%s row:%s want:%s", p, row, want[:columns])
 			}
-			continue
 		}
+		return nil
+	}
 
-		values := intValues(row)
-		if len(values) != 6 {
+	// Load data for each country from each row - one row per day per area
+	skipped, err := streamCSV(p, header, func(row []string) error {
+		if len(row) < columns {
 			return fmt.Errorf("series: invalid row len for row:%s", row)
 		}
+		values := intValues(row[:columns])
+		if values[0] < 1 {
+			return fmt.Errorf("series: invalid day for row:%s", row)
+		}
 
-		series, err := dataset.FindSeries(values[1])
+		series, err := slice.FindSeries(values[1])
 		if err != nil || series == nil {
-			log.Printf("series: series not found for id:%d index:%d row:%v", values[1], i, row)
-			continue
+			return fmt.Errorf("series: series not found for id:%d row:%v", values[1], row)
+		}
+		if !include(series) {
+			return nil
 		}
 
-		//	log.Printf("series:%s day:%v", series, values)
-		// Set the series data from this row
-		series.SetDayData(values[0], values[2], values[3], values[4], values[5])
-	}
+		// Day numbers count from the first day in memory
+		day := values[0] - first + 1
+		if day < 1 {
+			if day == 0 && series.PreviousDay != nil {
+				series.PreviousDay.setValues(values[2:]...)
+				if len(row) > columns {
+					series.PreviousDay.parseSources(row[columns])
+				}
+			}
+			return nil
+		}
 
-	return nil
-}
+		// Grow every series loaded at once, so that days are allocated in blocks
+		if day > days {
+			for _, s := range slice {
+				if include(s) {
+					s.AddDays(day - len(s.Days))
+				}
+			}
+			days = day
+		}
 
-// MergeData on series may not be required either
+		// Set the series data from this row
+		err = series.SetDayData(day, values[2:]...)
+		if err != nil {
+			return err
+		}
+		if len(row) > columns {
+			series.Days[day-1].parseSources(row[columns])
+		}
+		return nil
+	})
+	return days, skipped, err
+}
 
 /* This is no longer required - would go in import */
 
@@ -0,0 +1,181 @@
+package series
+
+import (
+	"sync"
+	"time"
+)
+
+// StoreConfig configures a Store, it is given when the store is created
+type StoreConfig struct {
+	// DataPath is the directory data files are loaded from by Store.LoadData
+	DataPath string
+
+	// LazyProvinces is true if province series are loaded on first use rather than when data is loaded
+	// this reduces memory for deployments which mostly serve country pages
+	LazyProvinces bool
+
+	// Interpolation is the method used to fill days skipped by sources when data is loaded
+	Interpolation Interpolation
+
+	// RetentionDays is the number of days before today kept at daily resolution, older days are kept weekly
+	// 0 keeps every day, see Downsample
+	RetentionDays int
+
+	// ArchiveDays is the number of days before today kept in memory, older days are moved to the archive
+	// 0 keeps every day in memory, see Archive
+	ArchiveDays int
+
+	// Cache is true if the dataset is saved as a compressed cache in the data path
+	// while the data files are unchanged the dataset is loaded from the cache, without parsing the files
+	Cache bool
+
+	// StartDate is the date of the first day in the series file, 2020-01-22 if zero
+	StartDate time.Time
+
+	// Dataset is the disease tracked and the metrics stored in the series file, COVID19 if it has no metrics
+	Dataset Dataset
+
+	// Groups are the countries in region groups by name, groups not given keep their defaults
+	Groups map[string][]string
+
+	// SourcePriority ranks sources when more than one provide the same value, higher wins, see ParseSourcePriority
+	// values with an unknown source have the lowest priority, the default ranks imported values highest
+	SourcePriority map[Source]int
+}
+
+// Store holds a dataset loaded from data files, with the overrides, changelog and audit log kept with it
+// stores are independent, so a program may load several datasets with different config, and all methods are safe for concurrent use
+// the config of a store is fixed when it is created, and is used by the series it loads
+// the package level functions use the default store, which is the one used by the server
+type Store struct {
+	// config is set by NewStore and never changed, so it is read without locking
+	// kinds are the data kinds of the metrics of the dataset, and groups the region groups as sets of country names
+	config StoreConfig
+	kinds  []int
+	groups map[string]map[string]bool
+
+	// mutex guards the dataset, the completions index and the pending location
+	mutex   sync.RWMutex
+	dataset Slice
+
+	// completions is the prefix index of area names sorted by key, built when a dataset is swapped in
+	completions []completionEntry
+
+	// updateMutex serializes loads and updates of the dataset, so that none is lost when a new dataset is swapped in
+	// it is locked before the dataset mutex, never while holding it
+	updateMutex sync.Mutex
+
+	// overrides are the corrections loaded with the data or added with AddOverride
+	overridesMutex sync.RWMutex
+	overrides      []Override
+
+	// changes is the changelog of refreshes
+	changesMutex sync.RWMutex
+	changes      []*Change
+
+	// ranks are the daily snapshots of country ranks, in date order
+	ranksMutex sync.RWMutex
+	ranks      []RankSnapshot
+
+	// audit holds the most recent entries in the order recorded, and auditPath the file entries are appended to
+	auditMutex sync.RWMutex
+	audit      []AuditEntry
+	auditPath  string
+
+	// aliases maps the keys of aliases to country names, see LoadAliases
+	aliasMutex sync.RWMutex
+	aliases    map[string]string
+
+	// conflicts records values which lost to a higher priority source
+	conflictsMutex sync.RWMutex
+	conflicts      []Conflict
+}
+
+// NewStore returns an empty store with the configuration given, call LoadData to load the data files
+// config left zero is set to the defaults, which are those of COVID-19
+func NewStore(config StoreConfig) *Store {
+	if config.StartDate.IsZero() {
+		config.StartDate = time.Date(2020, 1, 22, 0, 0, 0, 0, time.UTC)
+	}
+	config.StartDate = time.Date(config.StartDate.Year(), config.StartDate.Month(), config.StartDate.Day(), 0, 0, 0, 0, time.UTC)
+	if len(config.Dataset.Metrics) == 0 {
+		config.Dataset = COVID19
+	}
+	if config.SourcePriority == nil {
+		config.SourcePriority = defaultSourcePriority
+	}
+	priority := make(map[Source]int, len(config.SourcePriority))
+	for source, rank := range config.SourcePriority {
+		priority[source] = rank
+	}
+	config.SourcePriority = priority
+	return &Store{
+		config:  config,
+		kinds:   config.Dataset.Kinds(),
+		groups:  groupSets(config.Groups),
+		aliases: aliasKeys(defaultAliases),
+	}
+}
+
+// defaults is the store whose config is used by series outside a store, it holds no data
+var defaults = NewStore(StoreConfig{})
+
+// std is the default store used by the package level functions, use stdMutex to access
+var std = NewStore(StoreConfig{DataPath: "data"})
+
+var stdMutex sync.RWMutex
+
+// DefaultStore returns the default store used by the package level functions
+func DefaultStore() *Store {
+	stdMutex.RLock()
+	defer stdMutex.RUnlock()
+	return std
+}
+
+// SetDefaultStore sets the store used by the package level functions
+// this lets programs which serve one dataset use the package level functions with their own config
+func SetDefaultStore(st *Store) {
+	stdMutex.Lock()
+	defer stdMutex.Unlock()
+	std = st
+}
+
+// Config returns the configuration of this store, its maps are shared with the store and must not be modified
+func (st *Store) Config() StoreConfig {
+	return st.config
+}
+
+// Dataset returns the dataset tracked by this store
+func (st *Store) Dataset() Dataset {
+	return st.config.Dataset
+}
+
+// DataKinds returns the data kinds stored in the series file by this store, in the order of the metrics of its dataset
+func (st *Store) DataKinds() []int {
+	return append([]int(nil), st.kinds...)
+}
+
+// settings returns the store the series belongs to, whose config it uses
+// series not loaded by a store use the defaults
+func (d *Data) settings() *Store {
+	if d == nil || d.store == nil {
+		return defaults
+	}
+	return d.store
+}
+
+// store returns the store the series in the slice were loaded by, or nil if they were not loaded by a store
+func (slice Slice) store() *Store {
+	if len(slice) == 0 {
+		return nil
+	}
+	return slice[0].store
+}
+
+// settings returns the store the series in the slice belong to, see Data.settings
+func (slice Slice) settings() *Store {
+	if len(slice) == 0 {
+		return defaults
+	}
+	return slice[0].settings()
+}
@@ -0,0 +1,87 @@
+package series
+
+// Update updates the dataset of the default store, see Store.Update
+func Update(actor string, fn func(next Slice) error) error {
+	return DefaultStore().Update(actor, fn)
+}
+
+// Update calls fn with a copy of the dataset, and swaps the copy in as the dataset if fn succeeds
+// fn runs without the dataset locked, so requests are served from the current dataset meanwhile
+// and never see data partially updated, if fn returns an error the dataset is left unchanged
+// values changed by fn are recorded in the audit log as changed by actor
+func (st *Store) Update(actor string, fn func(next Slice) error) error {
+	st.updateMutex.Lock()
+	defer st.updateMutex.Unlock()
+
+	st.mutex.RLock()
+	next := st.dataset.Copy()
+	st.mutex.RUnlock()
+
+	err := fn(next)
+	if err != nil {
+		return err
+	}
+
+	st.swap(next, actor)
+	return nil
+}
+
+// swap replaces the dataset with next, series held by callers from the previous dataset are unchanged
+// values changed from the previous dataset are recorded in the audit log as changed by actor
+// updateMutex must be locked while performing this operation
+func (st *Store) swap(next Slice, actor string) {
+	index := next.buildCompletions()
+	st.mutex.RLock()
+	entries := st.dataset.audit(next, actor)
+	st.mutex.RUnlock()
+	st.mutex.Lock()
+	st.dataset = next
+	st.completions = index
+	st.mutex.Unlock()
+	st.recordAudit(entries)
+}
+
+// Copy returns a deep copy of the series in the slice, so that the copy can be changed
+// without changing the series in the slice
+func (slice Slice) Copy() Slice {
+	c := make(Slice, len(slice))
+	for i, s := range slice {
+		c[i] = s.clone()
+	}
+	return c
+}
+
+// clone returns a deep copy of this series with its own days and columns and an empty stats cache
+func (d *Data) clone() *Data {
+	c := *d
+	c.Events = append([]Event(nil), d.Events...)
+	block := make([]Day, len(d.Days))
+	for i, day := range d.Days {
+		block[i] = day.clone()
+	}
+	if d.PreviousDay != nil {
+		c.setPreviousDay(d.PreviousDay.Date)
+	}
+	c.columns = make([]*column, len(d.columns))
+	for kind, col := range d.columns {
+		if col != nil {
+			c.columns[kind] = col.clone()
+		}
+	}
+	c.setDays(block)
+	c.stats = newStats()
+	c.statsKey = ""
+	return &c
+}
+
+// clone returns a copy of this day which shares no notes or counts by sex with it
+// variant shares are shared, as they are replaced rather than changed
+func (d *Day) clone() Day {
+	c := *d
+	c.Notes = append([]string(nil), d.Notes...)
+	if d.Sex != nil {
+		sex := *d.Sex
+		c.Sex = &sex
+	}
+	return c
+}
@@ -0,0 +1,29 @@
+package series
+
+// testsPerCaseDays is the number of days over which tests and cases are summed by TestsPerCase
+const testsPerCaseDays = 7
+
+// TestsPerCase returns the ratio of tests to confirmed cases over the testsPerCaseDays to each day
+// this indicates whether testing is adequate, the WHO suggested 20 or more tests per case (positivity below 5%)
+// as areas testing too little find a larger share of their tests positive, and miss more cases
+// tests and cases are summed over several days as many areas report tests in batches
+// the ratio is zero on days with no tests or cases reported over the days summed
+func (d *Data) TestsPerCase() []float64 {
+	return d.Memo("testspercase", func() interface{} {
+		tested, confirmed := d.DailyValues(DataTested), d.DailyValues(DataConfirmed)
+		ratios := make([]float64, len(d.Days))
+		tests, cases := 0, 0
+		for i := range ratios {
+			tests += tested[i]
+			cases += confirmed[i]
+			if i >= testsPerCaseDays {
+				tests -= tested[i-testsPerCaseDays]
+				cases -= confirmed[i-testsPerCaseDays]
+			}
+			if tests > 0 && cases > 0 {
+				ratios[i] = float64(tests) / float64(cases)
+			}
+		}
+		return ratios
+	}).([]float64)
+}
@@ -0,0 +1,78 @@
+package series
+
+// trendDays is the number of recent complete days used to classify trends
+const trendDays = 14
+
+// trendChange is the relative change over trendDays above which values are rising or falling
+const trendChange = 0.2
+
+// trendMinDaily is the minimum average daily value for which a trend is classified
+const trendMinDaily = 1.0
+
+// Trend classifies the recent direction of daily values
+type Trend int
+
+// Trends of daily values, TrendInsufficient is used if there is not enough data
+const (
+	TrendInsufficient Trend = iota
+	TrendRising
+	TrendFalling
+	TrendPlateau
+)
+
+// trendNames are the names of each trend for display, indexed by Trend
+var trendNames = []string{"Insufficient", "Rising", "Falling", "Plateau"}
+
+// trendArrows are the arrows shown for each trend, indexed by Trend
+var trendArrows = []string{"", "↑", "↓", "→"}
+
+// String returns the name of this trend
+func (t Trend) String() string {
+	if int(t) >= len(trendNames) {
+		return trendNames[TrendInsufficient]
+	}
+	return trendNames[t]
+}
+
+// Arrow returns an arrow for this trend, or an empty string if insufficient
+func (t Trend) Arrow() string {
+	if int(t) >= len(trendArrows) {
+		return ""
+	}
+	return trendArrows[t]
+}
+
+// Trend classifies the 7 day average of daily values for dataKind over the last two complete weeks
+// the change is estimated from a least squares line through the averages, relative to their mean
+// values are adjusted for the day of the week, so that a weekend dip within the window is not taken as a fall
+func (d *Data) Trend(dataKind int) Trend {
+	n := d.CompleteCount()
+	if n < trendDays {
+		return TrendInsufficient
+	}
+	averages := movingAverageFloats(d.AdjustedDaily(dataKind)[:n], peakAverageDays)[n-trendDays:]
+
+	var xMean, yMean float64
+	for x, y := range averages {
+		xMean += float64(x) / trendDays
+		yMean += y / trendDays
+	}
+	if yMean < trendMinDaily {
+		return TrendInsufficient
+	}
+
+	var sxx, sxy float64
+	for x, y := range averages {
+		sxx += (float64(x) - xMean) * (float64(x) - xMean)
+		sxy += (float64(x) - xMean) * (y - yMean)
+	}
+	change := sxy / sxx * trendDays / yMean
+
+	switch {
+	case change > trendChange:
+		return TrendRising
+	case change < -trendChange:
+		return TrendFalling
+	}
+	return TrendPlateau
+}
@@ -8,14 +8,11 @@ import (
 	"time"
 )
 
-// UpdateFromJHUCountryCases updates from JHU country cases data files
+// UpdateFromJHUCountryCases updates the series in the slice from JHU country cases data files
 // several files are required to get all data, all with different formats
 // Cols: Country_Region,Last_Update,Lat,Long_,Confirmed,Deaths,Recovered,Active
-func UpdateFromJHUCountryCases(rows [][]string) error {
-
-	// Lock during add operation
-	mutex.Lock()
-	defer mutex.Unlock()
+// this should be called on a copy of the dataset within Update
+func (slice Slice) UpdateFromJHUCountryCases(rows [][]string) error {
 
 	log.Printf("series: update from JHU country cases %d rows", len(rows))
 
@@ -29,20 +26,11 @@ func UpdateFromJHUCountryCases(rows [][]string) error {
 			continue
 		}
 
-		country := row[0]
+		country := slice.settings().CountryName(row[0])
 		province := ""
 
-		// Transform countries
-		switch country {
-		case "Burma":
-			country = "Myanmar"
-		case "Taiwan*":
-			country = "Taiwan"
-		case "Korea, South":
-			country = "South Korea"
-		}
 		// Find the series for this row
-		series, err := dataset.FetchSeries(country, province)
+		series, err := slice.FetchSeries(country, province)
 		if err != nil || series == nil {
 			continue
 		}
@@ -54,7 +42,7 @@ func UpdateFromJHUCountryCases(rows [][]string) error {
 		}
 
 		// We don't hav etested data from JHU so leave it unchanged
-		series.UpdateToday(updated, deaths, confirmed, recovered, 0)
+		series.UpdateToday(SourceJHU, updated, deaths, confirmed, recovered, 0)
 
 		log.Printf("update: %s u:%v d:%d c:%d r:%d", series, updated, deaths, confirmed, recovered)
 
@@ -63,18 +51,35 @@ func UpdateFromJHUCountryCases(rows [][]string) error {
 	return nil
 }
 
-// UpdateFromJHUStatesCases updates from JHU states cases data files
+// UpdateFromJHUStatesCases updates the series in the slice from JHU states cases data files
 // several files are required to get all data, all with different formats
 //  0    1    			2				3			4  5     	6		7		8		9
 // FIPS,Province_State,Country_Region,Last_Update,Lat,Long_,Confirmed,Deaths,Recovered,Active
-func UpdateFromJHUStatesCases(rows [][]string) error {
-
-	// Lock during add operation
-	mutex.Lock()
-	defer mutex.Unlock()
+// this should be called on a copy of the dataset within Update
+func (slice Slice) UpdateFromJHUStatesCases(rows [][]string) error {
 
 	log.Printf("series: update from JHU states cases %d rows", len(rows))
 
+	// Provinces may be pending, so load those in the rows first, the rest are left pending
+	updated := make(map[*Data]bool)
+	for i, row := range rows {
+		if i == 0 || len(row) < 2 {
+			continue
+		}
+		province, ok := jhuProvinceName(row[0])
+		if !ok {
+			continue
+		}
+		series, err := slice.FetchSeries(slice.settings().CountryName(row[1]), province)
+		if err == nil && series != nil {
+			updated[series] = true
+		}
+	}
+	err := slice.loadPending(func(d *Data) bool { return updated[d] })
+	if err != nil {
+		return err
+	}
+
 	// For each row in the input data, reject if admin2 completed
 	for i, row := range rows {
 		// Check format on row 0
@@ -85,25 +90,14 @@ func UpdateFromJHUStatesCases(rows [][]string) error {
 			continue
 		}
 
-		country := row[1]
-		province := row[0]
-
-		// Rename or ignore some series
-		switch province {
-		case "Falkland Islands (Malvinas)":
-			province = "Falkland Islands"
-		case "British Virgin Islands":
-			province = "Virgin Islands"
-		case "Grand Princess":
-			continue
-		case "Diamond Princess":
-			continue
-		case "Recovered":
+		country := slice.settings().CountryName(row[1])
+		province, ok := jhuProvinceName(row[0])
+		if !ok {
 			continue
 		}
 
 		// Find the series concerned
-		series, err := dataset.FetchSeries(country, province)
+		series, err := slice.FetchSeries(country, province)
 		if err != nil || series == nil {
 			log.Printf("series: state series not found for:%s,%s", country, province)
 			continue
@@ -117,7 +111,7 @@ func UpdateFromJHUStatesCases(rows [][]string) error {
 		}
 
 		// We don't have tested data from JHU so leave it unchanged
-		series.UpdateToday(updated, deaths, confirmed, recovered, 0)
+		series.UpdateToday(SourceJHU, updated, deaths, confirmed, recovered, 0)
 
 		log.Printf("update province: %s u:%v d:%d c:%d r:%d", series, updated, deaths, confirmed, recovered)
 
@@ -126,6 +120,20 @@ func UpdateFromJHUStatesCases(rows [][]string) error {
 	return nil
 }
 
+// jhuProvinceName returns our name for the JHU province given, or false if the province is ignored
+func jhuProvinceName(province string) (string, bool) {
+	// Rename or ignore some series
+	switch province {
+	case "Falkland Islands (Malvinas)":
+		return "Falkland Islands", true
+	case "British Virgin Islands":
+		return "Virgin Islands", true
+	case "Grand Princess", "Diamond Princess", "Recovered":
+		return "", false
+	}
+	return province, true
+}
+
 // Note csv col order is different from our standard order
 func readJHURowData(updatedstr, deathsstr, confirmedstr, recoveredstr string) (time.Time, int, int, int, error) {
 
@@ -182,10 +190,11 @@ type UKStats struct {
 	NIDeaths       int
 }
 
-// UpdateFromUKStats reads stats from the official UK source
+// UpdateFromUKStats updates the series in the slice with stats from the official UK source
 // unfortunately this changes every day to the latest day's stats
 // there is no coherent UK historical record
-func UpdateFromUKStats(jsonData map[string]interface{}) error {
+// this should be called on a copy of the dataset within Update
+func (slice Slice) UpdateFromUKStats(jsonData map[string]interface{}) error {
 
 	stats, err := parseUKJSON(jsonData)
 	if err != nil {
@@ -194,40 +203,36 @@ func UpdateFromUKStats(jsonData map[string]interface{}) error {
 
 	log.Printf("uk stats:%v", stats)
 
-	// Lock during add operation
-	mutex.Lock()
-	defer mutex.Unlock()
-
 	// Grab the series concerned and update them
-	uk, err := dataset.FetchSeries("United Kingdom", "")
+	uk, err := slice.FetchSeries("United Kingdom", "")
 	if err != nil {
 		return fmt.Errorf("failed to fetch uk series")
 	}
-	uk.UpdateToday(time.Now().UTC(), stats.UKDeaths, stats.UKCases, 0, 0)
+	uk.UpdateToday(SourceUKGov, time.Now().UTC(), stats.UKDeaths, stats.UKCases, 0, 0)
 
-	england, err := dataset.FetchSeries("United Kingdom", "England")
+	england, err := slice.FetchSeries("United Kingdom", "England")
 	if err != nil {
 		return fmt.Errorf("failed to fetch England series")
 	}
-	england.UpdateToday(time.Now().UTC(), stats.EnglandDeaths, stats.EnglandCases, 0, 0)
+	england.UpdateToday(SourceUKGov, time.Now().UTC(), stats.EnglandDeaths, stats.EnglandCases, 0, 0)
 
-	scotland, err := dataset.FetchSeries("United Kingdom", "Scotland")
+	scotland, err := slice.FetchSeries("United Kingdom", "Scotland")
 	if err != nil {
 		return fmt.Errorf("failed to fetch Scotland series")
 	}
-	scotland.UpdateToday(time.Now().UTC(), stats.ScotlandDeaths, stats.ScotlandCases, 0, 0)
+	scotland.UpdateToday(SourceUKGov, time.Now().UTC(), stats.ScotlandDeaths, stats.ScotlandCases, 0, 0)
 
-	wales, err := dataset.FetchSeries("United Kingdom", "Wales")
+	wales, err := slice.FetchSeries("United Kingdom", "Wales")
 	if err != nil {
 		return fmt.Errorf("failed to fetch Wales series")
 	}
-	wales.UpdateToday(time.Now().UTC(), stats.WalesDeaths, stats.WalesCases, 0, 0)
+	wales.UpdateToday(SourceUKGov, time.Now().UTC(), stats.WalesDeaths, stats.WalesCases, 0, 0)
 
-	northernIreland, err := dataset.FetchSeries("United Kingdom", "Northern Ireland")
+	northernIreland, err := slice.FetchSeries("United Kingdom", "Northern Ireland")
 	if err != nil {
 		return fmt.Errorf("failed to fetch NI series")
 	}
-	northernIreland.UpdateToday(time.Now().UTC(), stats.NIDeaths, stats.NICases, 0, 0)
+	northernIreland.UpdateToday(SourceUKGov, time.Now().UTC(), stats.NIDeaths, stats.NICases, 0, 0)
 
 	return nil
 }
@@ -274,81 +279,142 @@ func parseUKJSON(jsonData map[string]interface{}) (UKStats, error) {
 	return stats, nil
 }
 
+// CalculateGlobalSeriesData calculates the global series of the default store, see Store.CalculateGlobalSeriesData
+func CalculateGlobalSeriesData() error {
+	return DefaultStore().CalculateGlobalSeriesData()
+}
+
 // CalculateGlobalSeriesData adds some top level countries which are inexplicably missing from the original dataset
 // presumably they calculate these on the fly
-func CalculateGlobalSeriesData() error {
+func (st *Store) CalculateGlobalSeriesData() error {
+	return st.Update("calculate", func(next Slice) error {
+		return next.CalculateGlobalSeriesData()
+	})
+}
 
-	// Lock during add operation
-	mutex.Lock()
-	defer mutex.Unlock()
+// CalculateGlobalSeriesData calculates the rollups and the global series in the slice from the other series
+// this should be called on a copy of the dataset within Update
+func (slice Slice) CalculateGlobalSeriesData() error {
 
-	// Fetch series
-	China, err := dataset.FetchSeries("China", "")
-	if err != nil {
-		return err
-	}
-	Australia, err := dataset.FetchSeries("Australia", "")
+	// Rollups are calculated from provinces, pending provinces are read into copies so they stay pending
+	loaded, err := slice.withPending(all)
 	if err != nil {
 		return err
 	}
-	Canada, err := dataset.FetchSeries("Canada", "")
+
+	// Synthesize country series for countries which only have province data
+	err = loaded.RollupProvinces()
 	if err != nil {
 		return err
 	}
-	Global, err := dataset.FetchSeries("", "")
+
+	Global, err := loaded.FetchSeries("", "")
 	if err != nil {
 		return err
 	}
 
-	// Reset all these series as we're recalculating from scratch
-	China.ResetDays()
-	Australia.ResetDays()
-	Canada.ResetDays()
+	// Reset the global series as we're recalculating from scratch
 	Global.ResetDays()
 
-	// Add global country entries for countries with data broken down at province level
-	// these are missing in the datasets from JHU for some reason, though US is now included
-	for _, s := range dataset {
-
-		// Build an overall China series
-		if s.Country == "China" {
-			err = China.MergeSeries(s)
+	// Add all series which should be included, rollups are excluded to avoid double counting
+	for _, s := range loaded {
+		if s.ShouldIncludeInGlobal() {
+			err = Global.MergeSeries(s)
 			if err != nil {
 				return err
 			}
 		}
+	}
 
-		// Build an overall Australia series
-		if s.Country == "Australia" {
-			err = Australia.MergeSeries(s)
-			if err != nil {
-				return err
-			}
-		}
+	// Sort entire dataset by deaths desc to get the right order
+	sort.Stable(slice)
 
-		// Build an overall Canada series
-		if s.Country == "Canada" {
-			err = Canada.MergeSeries(s)
-			if err != nil {
-				return err
-			}
+	return nil
+}
+
+// Discrepancy records a difference between the stored global series
+// and the global totals recalculated from all other series
+type Discrepancy struct {
+	Date       time.Time
+	Kind       string
+	Stored     int
+	Calculated int
+}
+
+// String returns a string representation of this Discrepancy
+func (d Discrepancy) String() string {
+	return fmt.Sprintf("%s %s stored:%d calculated:%d", d.Date.Format("2006-01-02"), d.Kind, d.Stored, d.Calculated)
+}
+
+// CheckGlobalSeries checks the global series of the default store, see Store.CheckGlobalSeries
+func CheckGlobalSeries(rebuild bool) ([]Discrepancy, error) {
+	return DefaultStore().CheckGlobalSeries(rebuild)
+}
+
+// CheckGlobalSeries recalculates the global totals from all series and reports
+// any discrepancies with the stored global series
+// if rebuild is true the global series (and rollups) are rebuilt from scratch after checking
+func (st *Store) CheckGlobalSeries(rebuild bool) ([]Discrepancy, error) {
+	// Provinces are needed for the totals, pending provinces are read into copies
+	var discrepancies []Discrepancy
+	st.mutex.RLock()
+	dataset, err := st.dataset.withPending(all)
+	if err == nil {
+		discrepancies, err = dataset.CheckGlobal()
+	}
+	st.mutex.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+
+	if rebuild && len(discrepancies) > 0 {
+		log.Printf("series: rebuilding global series with %d discrepancies", len(discrepancies))
+		err = st.CalculateGlobalSeriesData()
+		if err != nil {
+			return discrepancies, err
 		}
+	}
+
+	return discrepancies, nil
+}
+
+// CheckGlobal recalculates the global totals from all series in this slice
+// and returns any discrepancies with the stored global series
+// rollups are excluded as their provinces are included
+func (slice Slice) CheckGlobal() ([]Discrepancy, error) {
+	global, err := slice.FetchSeries("", "")
+	if err != nil {
+		return nil, err
+	}
 
+	calculated := &Data{store: global.store}
+	if global.PreviousDay != nil {
+		calculated.setPreviousDay(global.PreviousDay.Date)
+	}
+	calculated.AddDays(len(global.Days))
+	for _, s := range slice {
 		if s.ShouldIncludeInGlobal() {
-			//	log.Printf("global:%s-%d", s.Country, s.TotalDeaths())
-			err = Global.MergeSeries(s)
+			err = calculated.MergeSeries(s)
 			if err != nil {
-				return err
-			}
-		} else {
-			if s.TotalDeaths() > 0 {
-				//	log.Printf("ignore for global:%s deaths:%d", s, s.TotalDeaths())
+				return nil, err
 			}
 		}
 	}
 
-	// Sort entire dataset by deaths desc to get the right order
-	sort.Stable(dataset)
+	var discrepancies []Discrepancy
+	for i, day := range global.Days {
+		other := calculated.Days[i]
+		for _, kind := range slice.settings().kinds {
+			if day.Value(kind) != other.Value(kind) {
+				discrepancies = append(discrepancies, Discrepancy{
+					Date:       day.Date,
+					Kind:       DataKindName(kind),
+					Stored:     day.Value(kind),
+					Calculated: other.Value(kind),
+				})
+			}
+		}
+	}
 
-	return nil
+	return discrepancies, nil
 }
@@ -0,0 +1,173 @@
+package series
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// vaccinationMinAreas is the minimum number of areas with coverage and growth before they are correlated
+const vaccinationMinAreas = 3
+
+// vaccinationReport is the cumulative people vaccinated reported for an area on a date
+type vaccinationReport struct {
+	date       time.Time
+	vaccinated int
+}
+
+// LoadVaccinations loads vaccinations into the default store, see Store.LoadVaccinations
+func LoadVaccinations(p string) error {
+	return DefaultStore().LoadVaccinations(p)
+}
+
+// LoadVaccinations loads people vaccinated from the specified file and sets them on days by area id and date
+// rows are area_id,date,vaccinated with cumulative people vaccinated with at least one dose
+// sources report irregularly, so each report is carried forward to the days after it until the next
+// a missing file is not an error as vaccination data is only available for some areas
+// dataset must be locked while performing this operation
+func (st *Store) LoadVaccinations(p string) error {
+	return st.dataset.loadVaccinations(p, all)
+}
+
+// loadVaccinations loads vaccinations from the specified file for the series in the slice for which include returns true
+func (slice Slice) loadVaccinations(p string, include func(*Data) bool) error {
+	header := func(row []string) error {
+		if len(row) < 3 || row[0] != "area_id" || row[1] != "date" || row[2] != "vaccinated" {
+			return fmt.Errorf("vaccinations: invalid header row in file:%s row:%s", p, row)
+		}
+		return nil
+	}
+
+	reports := make(map[*Data][]vaccinationReport)
+	_, err := streamCSV(p, header, func(row []string) error {
+		if len(row) < 3 {
+			return fmt.Errorf("vaccinations: invalid row len for row:%s", row)
+		}
+		areaID, err := strconv.Atoi(row[0])
+		if err != nil {
+			return fmt.Errorf("vaccinations: invalid area id at row:%s", row)
+		}
+		s, err := slice.FindSeries(areaID)
+		if err != nil {
+			return fmt.Errorf("vaccinations: unknown area at row:%s", row)
+		}
+		if !include(s) {
+			return nil
+		}
+		date, err := time.Parse("2006-01-02", row[1])
+		if err != nil {
+			return fmt.Errorf("vaccinations: invalid date at row:%s", row)
+		}
+		vaccinated, err := strconv.Atoi(row[2])
+		if err != nil || vaccinated < 0 {
+			return fmt.Errorf("vaccinations: invalid value at row:%s", row)
+		}
+		reports[s] = append(reports[s], vaccinationReport{date: date, vaccinated: vaccinated})
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	for s, r := range reports {
+		s.setVaccinated(r)
+	}
+	return nil
+}
+
+// setVaccinated sets people vaccinated on each day from the latest report on or before it
+// days before the first report have none vaccinated
+func (d *Data) setVaccinated(reports []vaccinationReport) {
+	sort.SliceStable(reports, func(i, j int) bool {
+		return reports[i].date.Before(reports[j].date)
+	})
+	vaccinated, j := 0, 0
+	for _, day := range d.Days {
+		for j < len(reports) && !reports[j].date.After(day.Date) {
+			vaccinated = reports[j].vaccinated
+			j++
+		}
+		day.SetData(DataVaccinated, vaccinated)
+	}
+}
+
+// VaccinationCoverage returns people vaccinated on the date as a percentage of population
+// this is zero if the population is unknown or the series has no such day
+func (d *Data) VaccinationCoverage(date time.Time) float64 {
+	i := d.dayIndex(date)
+	if i < 0 || d.Population == 0 {
+		return 0
+	}
+	return float64(d.Days[i].Value(DataVaccinated)) * 100 / float64(d.Population)
+}
+
+// VaccinationPoint is one area in a vaccination correlation, a point on a scatter plot of growth against coverage
+type VaccinationPoint struct {
+	AreaID int    `json:"area_id"`
+	Name   string `json:"name"`
+
+	// Coverage is people vaccinated on the date as a percentage of population
+	Coverage float64 `json:"coverage"`
+
+	// Before and After are the values added in the days before and after the date
+	// and Growth is the percentage change from before to after
+	Before int     `json:"before"`
+	After  int     `json:"after"`
+	Growth float64 `json:"growth"`
+}
+
+// VaccinationCorrelation records the correlation across areas of vaccination coverage on a date
+// with the growth of a data kind in the days after it, a negative correlation means growth fell as coverage rose
+type VaccinationCorrelation struct {
+	Kind        string             `json:"kind"`
+	Date        time.Time          `json:"date"`
+	Days        int                `json:"days"`
+	Correlation float64            `json:"correlation"`
+	Points      []VaccinationPoint `json:"points"`
+}
+
+// VaccinationCorrelation correlates vaccination coverage on the date with the growth of dataKind over the days after it
+// growth compares the values added in the days after the date with those added in the days before it, so
+// areas are only included with population, coverage on the date, complete days either side and values before
+// the correlation is zero if fewer than vaccinationMinAreas areas are included or either has no variation
+func (slice Slice) VaccinationCorrelation(dataKind int, date time.Time, days int) VaccinationCorrelation {
+	c := VaccinationCorrelation{
+		Kind:   DataKindName(dataKind),
+		Date:   date,
+		Days:   days,
+		Points: []VaccinationPoint{},
+	}
+
+	var xs, ys []float64
+	for _, s := range slice {
+		i := s.dayIndex(date)
+		if i < days || i+days >= s.CompleteCount() || s.Population == 0 || s.Days[i].Value(DataVaccinated) == 0 {
+			continue
+		}
+		before := s.Days[i].Value(dataKind) - s.Days[i-days].Value(dataKind)
+		after := s.Days[i+days].Value(dataKind) - s.Days[i].Value(dataKind)
+		if before <= 0 {
+			continue
+		}
+		p := VaccinationPoint{
+			AreaID:   s.ID,
+			Name:     s.Title(),
+			Coverage: s.VaccinationCoverage(date),
+			Before:   before,
+			After:    after,
+			Growth:   float64(after-before) * 100 / float64(before),
+		}
+		c.Points = append(c.Points, p)
+		xs = append(xs, p.Coverage)
+		ys = append(ys, p.Growth)
+	}
+
+	if len(c.Points) >= vaccinationMinAreas {
+		c.Correlation, _ = pearson(xs, ys)
+	}
+	return c
+}
@@ -0,0 +1,92 @@
+package series
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Validate checks this series for problems in the data loaded, and returns an error for each found
+// cumulative values must not fall, days must be consecutive, and areas need coordinates and population
+// sources do revise totals downwards occasionally, so callers should report rather than reject these
+func (d *Data) Validate() []error {
+	var errors []error
+	if len(d.Days) == 0 {
+		errors = append(errors, fmt.Errorf("series: no days for series:%s", d))
+	}
+
+	for i := 1; i < len(d.Days); i++ {
+		previous, day := d.Days[i-1], d.Days[i]
+		if !day.Date.Equal(previous.Date.AddDate(0, 0, 1)) {
+			errors = append(errors, fmt.Errorf("series: date not continuous at day:%d date:%s previous:%s", i, day.Date.Format("2006-01-02"), previous.Date.Format("2006-01-02")))
+		}
+		for _, kind := range d.settings().kinds {
+			if day.Value(kind) < previous.Value(kind) {
+				errors = append(errors, fmt.Errorf("series: %s fell from %d to %d on date:%s", DataKindName(kind), previous.Value(kind), day.Value(kind), day.Date.Format("2006-01-02")))
+			}
+		}
+	}
+
+	// The global series has no meaningful location
+	if !d.IsGlobal() {
+		if d.Latitude < -90 || d.Latitude > 90 || d.Longitude < -180 || d.Longitude > 180 {
+			errors = append(errors, fmt.Errorf("series: invalid coordinates:%g,%g", d.Latitude, d.Longitude))
+		} else if d.Latitude == 0 && d.Longitude == 0 {
+			errors = append(errors, fmt.Errorf("series: missing coordinates"))
+		}
+	}
+	if d.Population <= 0 {
+		errors = append(errors, fmt.Errorf("series: missing population"))
+	}
+
+	return errors
+}
+
+// Validation records the problems found in one series by Validate
+type Validation struct {
+	AreaID   int      `json:"area_id"`
+	Country  string   `json:"country"`
+	Province string   `json:"province"`
+	Errors   []string `json:"errors"`
+}
+
+// Validate returns the problems found in each series with any, ordered by area id
+// pending series are not checked as their days are not loaded
+func (slice Slice) Validate() []Validation {
+	validations := []Validation{}
+	for _, s := range slice {
+		if s.pending {
+			continue
+		}
+		errors := s.Validate()
+		if len(errors) == 0 {
+			continue
+		}
+		v := Validation{
+			AreaID:   s.ID,
+			Country:  s.Country,
+			Province: s.Province,
+			Errors:   make([]string, len(errors)),
+		}
+		for i, err := range errors {
+			v.Errors[i] = err.Error()
+		}
+		validations = append(validations, v)
+	}
+
+	sort.Slice(validations, func(i, j int) bool {
+		return validations[i].AreaID < validations[j].AreaID
+	})
+	return validations
+}
+
+// Validate returns the problems found in the default store, see Store.Validate
+func Validate() []Validation {
+	return DefaultStore().Validate()
+}
+
+// Validate returns the problems found in the dataset, see Data.Validate
+func (st *Store) Validate() []Validation {
+	st.mutex.RLock()
+	defer st.mutex.RUnlock()
+	return st.dataset.Validate()
+}
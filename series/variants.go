@@ -0,0 +1,163 @@
+package series
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// VariantShare is the share of sequenced cases which were of a variant
+type VariantShare struct {
+	Variant string  `json:"variant"`
+	Percent float64 `json:"percent"`
+}
+
+// VariantDay is the share of each variant in the report used for one day
+type VariantDay struct {
+	Date   time.Time      `json:"date"`
+	Shares []VariantShare `json:"shares"`
+}
+
+// LoadVariants loads variant shares into the default store, see Store.LoadVariants
+func LoadVariants(p string) error {
+	return DefaultStore().LoadVariants(p)
+}
+
+// LoadVariants loads the share of sequenced cases by variant from the specified file and sets them on days by area id and date
+// rows are area_id,date,variant,percent with a row per variant per report, as published weekly by sources like ECDC and GISAID
+// each report is carried forward to the days after it until the next, so shares have a value on every day
+// a missing file is not an error as variant data is only available for some areas
+// dataset must be locked while performing this operation
+func (st *Store) LoadVariants(p string) error {
+	return st.dataset.loadVariants(p, all)
+}
+
+// loadVariants loads variant shares from the specified file for the series in the slice for which include returns true
+func (slice Slice) loadVariants(p string, include func(*Data) bool) error {
+	header := func(row []string) error {
+		if len(row) < 4 || row[0] != "area_id" || row[1] != "date" || row[2] != "variant" || row[3] != "percent" {
+			return fmt.Errorf("variants: invalid header row in file:%s row:%s", p, row)
+		}
+		return nil
+	}
+
+	reports := make(map[*Data]map[time.Time][]VariantShare)
+	_, err := streamCSV(p, header, func(row []string) error {
+		if len(row) < 4 {
+			return fmt.Errorf("variants: invalid row len for row:%s", row)
+		}
+		areaID, err := strconv.Atoi(row[0])
+		if err != nil {
+			return fmt.Errorf("variants: invalid area id at row:%s", row)
+		}
+		s, err := slice.FindSeries(areaID)
+		if err != nil {
+			return fmt.Errorf("variants: unknown area at row:%s", row)
+		}
+		if !include(s) {
+			return nil
+		}
+		date, err := time.Parse("2006-01-02", row[1])
+		if err != nil {
+			return fmt.Errorf("variants: invalid date at row:%s", row)
+		}
+		if row[2] == "" {
+			return fmt.Errorf("variants: invalid variant at row:%s", row)
+		}
+		percent, err := strconv.ParseFloat(row[3], 64)
+		if err != nil || percent < 0 || percent > 100 {
+			return fmt.Errorf("variants: invalid percent at row:%s", row)
+		}
+
+		if reports[s] == nil {
+			reports[s] = make(map[time.Time][]VariantShare)
+		}
+		reports[s][date] = append(reports[s][date], VariantShare{Variant: row[2], Percent: percent})
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	for s, r := range reports {
+		s.setVariants(r)
+	}
+	return nil
+}
+
+// setVariants sets the variant shares on each day from the latest report on or before it
+// days share the shares of their report, which are replaced rather than changed
+// days before the first report have no variant shares
+func (d *Data) setVariants(reports map[time.Time][]VariantShare) {
+	dates := make([]time.Time, 0, len(reports))
+	for date, shares := range reports {
+		sort.Slice(shares, func(i, j int) bool {
+			return shares[i].Variant < shares[j].Variant
+		})
+		dates = append(dates, date)
+	}
+	sort.Slice(dates, func(i, j int) bool {
+		return dates[i].Before(dates[j])
+	})
+
+	var shares []VariantShare
+	j := 0
+	for _, day := range d.Days {
+		for j < len(dates) && !dates[j].After(day.Date) {
+			shares = reports[dates[j]]
+			j++
+		}
+		day.Variants = shares
+	}
+}
+
+// HasVariants returns true if any day in this series has variant shares
+func (d *Data) HasVariants() bool {
+	return d.LastDay().Variants != nil
+}
+
+// Variants returns the names of the variants reported in this series, in the order first reported
+// variants first reported on the same day are in name order
+func (d *Data) Variants() []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, day := range d.Days {
+		for _, share := range day.Variants {
+			if !seen[share.Variant] {
+				seen[share.Variant] = true
+				names = append(names, share.Variant)
+			}
+		}
+	}
+	return names
+}
+
+// VariantShares returns the percent share of the variant on each day, zero on days without it
+func (d *Data) VariantShares(variant string) []float64 {
+	values := make([]float64, len(d.Days))
+	for i, day := range d.Days {
+		for _, share := range day.Variants {
+			if share.Variant == variant {
+				values[i] = share.Percent
+				break
+			}
+		}
+	}
+	return values
+}
+
+// VariantDays returns the variant shares for each day which has them, oldest first
+func (d *Data) VariantDays() []VariantDay {
+	days := []VariantDay{}
+	for _, day := range d.Days {
+		if day.Variants == nil {
+			continue
+		}
+		days = append(days, VariantDay{Date: day.Date, Shares: day.Variants})
+	}
+	return days
+}
@@ -0,0 +1,112 @@
+package series
+
+import (
+	"math"
+	"time"
+)
+
+// waveTroughRatio is the fraction of the lower peak which values must fall below between two waves
+// peaks separated by a shallower dip are treated as part of the same wave
+const waveTroughRatio = 0.5
+
+// waveMinRatio is the fraction of the highest peak a peak must reach to start a new wave
+// this ignores small bumps in the tail of a wave
+const waveMinRatio = 0.1
+
+// Wave records one epidemic wave, a period of sustained rise then fall in confirmed cases
+type Wave struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	Days  int       `json:"days"`
+
+	// Totals reported during the wave
+	Deaths    int `json:"deaths"`
+	Confirmed int `json:"confirmed"`
+
+	// The highest 7 day average of daily confirmed cases and the day it occurred
+	PeakConfirmed int       `json:"peak_confirmed"`
+	PeakDate      time.Time `json:"peak_date"`
+}
+
+// Waves segments this series into waves using peaks in the averaged daily confirmed cases
+// waves are split at the lowest day between peaks, the first starts with the first case
+// and the last runs to the last complete day, nil is returned if there are no peaks
+func (d *Data) Waves() []Wave {
+	n := d.CompleteCount()
+	averages := MovingAverage(d.DailyValues(DataConfirmed)[:n], peakAverageDays)
+
+	var highest float64
+	for _, p := range peakIndexes(averages) {
+		highest = math.Max(highest, averages[p])
+	}
+
+	// Merge peaks which are not separated by a deep enough trough, keeping the higher
+	var peaks []int
+	for _, p := range peakIndexes(averages) {
+		if averages[p] < waveMinRatio*highest {
+			continue
+		}
+		if len(peaks) > 0 {
+			last := peaks[len(peaks)-1]
+			trough := averages[minIndex(averages, last, p)]
+			if trough > waveTroughRatio*math.Min(averages[last], averages[p]) {
+				if averages[p] > averages[last] {
+					peaks[len(peaks)-1] = p
+				}
+				continue
+			}
+		}
+		peaks = append(peaks, p)
+	}
+	if len(peaks) == 0 {
+		return nil
+	}
+
+	start := 0
+	for start < peaks[0] && averages[start] <= 0 {
+		start++
+	}
+
+	waves := make([]Wave, len(peaks))
+	for i, p := range peaks {
+		end := n - 1
+		if i < len(peaks)-1 {
+			end = minIndex(averages, p, peaks[i+1])
+		}
+		waves[i] = d.wave(start, end, p, averages[p])
+		start = end + 1
+	}
+	return waves
+}
+
+// wave returns the wave for days from start to end inclusive, peaking at index peak
+func (d *Data) wave(start, end, peak int, peakValue float64) Wave {
+	before := d.PreviousDay
+	if start > 0 {
+		before = d.Days[start-1]
+	}
+	if before == nil {
+		before = &Day{}
+	}
+	return Wave{
+		Start:         d.Days[start].Date,
+		End:           d.Days[end].Date,
+		Days:          end - start + 1,
+		Deaths:        d.Days[end].Value(DataDeaths) - before.Value(DataDeaths),
+		Confirmed:     d.Days[end].Value(DataConfirmed) - before.Value(DataConfirmed),
+		PeakConfirmed: int(math.Round(peakValue)),
+		PeakDate:      d.Days[peak].Date,
+	}
+}
+
+// minIndex returns the index of the lowest value between start and end inclusive
+// the first is returned if several are equally low
+func minIndex(values []float64, start, end int) int {
+	min := start
+	for i := start; i <= end; i++ {
+		if values[i] < values[min] {
+			min = i
+		}
+	}
+	return min
+}
@@ -0,0 +1,91 @@
+package series
+
+import (
+	"fmt"
+)
+
+// weekdayWeeks is the number of recent complete weeks from which weekday factors are learned
+const weekdayWeeks = 8
+
+// weekdayMinDaily is the minimum average daily value of days used to learn factors, below this noise dominates
+const weekdayMinDaily = 5.0
+
+// weekdayMinFactor and weekdayMaxFactor bound the factors, so that a day without reports
+// does not inflate adjusted values without limit
+const (
+	weekdayMinFactor = 0.2
+	weekdayMaxFactor = 3.0
+)
+
+// noWeekdayEffect is the factors used if too little data is available to learn them
+var noWeekdayEffect = [7]float64{1, 1, 1, 1, 1, 1, 1}
+
+// WeekdayFactors returns the ratio of reported to expected daily values of dataKind for each weekday, indexed by time.Weekday
+// they are learned from the recent complete weeks of this series, as the value of each day relative to the 7 day average
+// centred on it, so areas which report little at weekends have factors below 1 on those days and above 1 on Mondays
+// factors average 1 over the week, they are all 1 if there is not enough data
+func (d *Data) WeekdayFactors(dataKind int) [7]float64 {
+	return d.Memo(fmt.Sprintf("weekday:%d", dataKind), func() interface{} {
+		return d.weekdayFactors(dataKind)
+	}).([7]float64)
+}
+
+// weekdayFactors calculates the factors returned by WeekdayFactors
+func (d *Data) weekdayFactors(dataKind int) [7]float64 {
+	// Averages are centred, so the last 3 complete days have none
+	daily := d.DailyValues(dataKind)
+	end := d.CompleteCount() - 3
+	start := end - weekdayWeeks*7
+	if start < 3 {
+		return noWeekdayEffect
+	}
+
+	var sums [7]float64
+	var counts [7]int
+	for i := start; i < end; i++ {
+		sum := 0
+		for _, v := range daily[i-3 : i+4] {
+			sum += v
+		}
+		average := float64(sum) / 7
+		if average < weekdayMinDaily {
+			continue
+		}
+		weekday := d.Days[i].Date.Weekday()
+		sums[weekday] += float64(daily[i]) / average
+		counts[weekday]++
+	}
+
+	// Every weekday must be seen in at least half the weeks
+	var factors [7]float64
+	var mean float64
+	for weekday := range factors {
+		if counts[weekday] < weekdayWeeks/2 {
+			return noWeekdayEffect
+		}
+		factors[weekday] = sums[weekday] / float64(counts[weekday])
+		mean += factors[weekday] / 7
+	}
+	for weekday := range factors {
+		factors[weekday] /= mean
+		if factors[weekday] < weekdayMinFactor {
+			factors[weekday] = weekdayMinFactor
+		} else if factors[weekday] > weekdayMaxFactor {
+			factors[weekday] = weekdayMaxFactor
+		}
+	}
+	return factors
+}
+
+// AdjustedDaily returns the values of dataKind per day divided by the weekday factor of each day, see WeekdayFactors
+// weekly totals are about the same, but dips at weekends and the backlog reported on Mondays are removed
+// these values should be used to estimate trends rather than the values reported
+func (d *Data) AdjustedDaily(dataKind int) []float64 {
+	factors := d.WeekdayFactors(dataKind)
+	daily := d.DailyValues(dataKind)
+	adjusted := make([]float64, len(daily))
+	for i, v := range daily {
+		adjusted[i] = float64(v) / factors[d.Days[i].Date.Weekday()]
+	}
+	return adjusted
+}
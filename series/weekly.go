@@ -0,0 +1,61 @@
+package series
+
+import (
+	"fmt"
+	"time"
+)
+
+// Week is the total of one data kind over an ISO 8601 week, Monday to Sunday, with the change from the week before
+type Week struct {
+	Year int `json:"year"`
+	Week int `json:"week"`
+
+	// Start is the date of the Monday of the week
+	Start time.Time `json:"start"`
+
+	// Days is the number of days of the week in the series, less than 7 for the first and last weeks if partial
+	Days int `json:"days"`
+
+	Value int `json:"value"`
+
+	// Change is the value less the value of the week before, and ChangePercent that as a percentage of it
+	// both are zero for the first week, and ChangePercent if the week before had no value
+	Change        int     `json:"change"`
+	ChangePercent float64 `json:"change_percent"`
+
+	// Provisional is true if any day of the week may still be revised
+	Provisional bool `json:"provisional"`
+}
+
+// Label returns the ISO name of the week, as 2020-W14
+func (w Week) Label() string {
+	return fmt.Sprintf("%d-W%02d", w.Year, w.Week)
+}
+
+// Weekly returns the totals of dataKind for each ISO week in the series, in date order
+// many agencies report weekly rather than daily, and weekly totals are not affected by the day of the week
+func (d *Data) Weekly(dataKind int) []Week {
+	daily := d.DailyValues(dataKind)
+
+	var weeks []Week
+	for i, day := range d.Days {
+		year, week := day.Date.ISOWeek()
+		if len(weeks) == 0 || weeks[len(weeks)-1].Year != year || weeks[len(weeks)-1].Week != week {
+			weekday := (int(day.Date.Weekday()) + 6) % 7
+			weeks = append(weeks, Week{Year: year, Week: week, Start: day.Date.AddDate(0, 0, -weekday)})
+		}
+		w := &weeks[len(weeks)-1]
+		w.Days++
+		w.Value += daily[i]
+		w.Provisional = w.Provisional || day.Provisional
+	}
+
+	for i := 1; i < len(weeks); i++ {
+		previous := weeks[i-1].Value
+		weeks[i].Change = weeks[i].Value - previous
+		if previous != 0 {
+			weeks[i].ChangePercent = float64(weeks[i].Change) * 100 / float64(previous)
+		}
+	}
+	return weeks
+}
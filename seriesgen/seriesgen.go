@@ -0,0 +1,269 @@
+// Package seriesgen generates synthetic but realistic epidemic curves
+// for unit tests, benchmarks and demo mode
+package seriesgen
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/kennygrant/coronavirus/series"
+)
+
+// GlobalID is the area id of the global series, generated areas are numbered from 2
+const GlobalID = 1
+
+// names are fictional countries used for generated areas, numbered once all are used
+var names = []string{
+	"Arcadia", "Borduria", "Cascadia", "Elbonia", "Freedonia", "Genovia", "Grand Fenwick", "Kamistan",
+	"Latveria", "Molvania", "Nambutu", "Orsinia", "Qumar", "Ruritania", "Sokovia", "Syldavia",
+	"Taronia", "Urkesh", "Vulgaria", "Zubrowka",
+}
+
+// Options controls the areas and curves generated, zero values are replaced with the defaults
+type Options struct {
+	// Areas is the number of countries generated, default 10
+	Areas int
+
+	// Days is the number of days in each series from the series start date, default 120
+	Days int
+
+	// Seed seeds the random numbers, the same options always generate the same series
+	Seed int64
+
+	// Noise is the relative standard deviation of daily counts, default 0.15
+	Noise float64
+
+	// WeekendEffect is the fraction of weekend counts reported on the following Monday instead, default 0.4
+	WeekendEffect float64
+}
+
+// withDefaults returns these options with zero values replaced by the defaults
+func (o Options) withDefaults() Options {
+	if o.Areas <= 0 {
+		o.Areas = 10
+	}
+	if o.Days <= 0 {
+		o.Days = 120
+	}
+	if o.Noise <= 0 {
+		o.Noise = 0.15
+	}
+	if o.WeekendEffect <= 0 {
+		o.WeekendEffect = 0.4
+	}
+	return o
+}
+
+// curve describes the epidemic in one area
+type curve struct {
+	// total cases at the end of the epidemic, growth rate per day and the day of peak growth
+	total    float64
+	rate     float64
+	midpoint float64
+
+	// fatality of cases, days from case to death and to recovery, and tests per case
+	fatality     float64
+	deathLag     int
+	recoveryLag  int
+	testsPerCase float64
+}
+
+// Generate returns a global series and the number of countries given in options
+// each country follows a logistic curve of cases with noise and fewer counts reported at weekends
+// deaths and recoveries follow cases after a lag, and the global series is the sum of the countries
+func Generate(options Options) (series.Slice, error) {
+	o := options.withDefaults()
+	r := rand.New(rand.NewSource(o.Seed))
+
+	global, err := series.NewData([]string{"", "", strconv.Itoa(GlobalID), "0", "0", "0", "", "#000000", ""})
+	if err != nil {
+		return nil, err
+	}
+	global.AddDays(o.Days)
+	slice := series.Slice{global}
+
+	for i := 0; i < o.Areas; i++ {
+		name := names[i%len(names)]
+		if i >= len(names) {
+			name = fmt.Sprintf("%s %d", name, i/len(names)+1)
+		}
+		population := 1000000 + r.Intn(80000000)
+		row := []string{
+			name,
+			"",
+			strconv.Itoa(GlobalID + 1 + i),
+			strconv.FormatFloat(r.Float64()*140-70, 'f', 4, 64),
+			strconv.FormatFloat(r.Float64()*360-180, 'f', 4, 64),
+			strconv.Itoa(population),
+			"",
+			fmt.Sprintf("#%06x", r.Intn(0x1000000)),
+			strconv.Itoa(population / (20 + r.Intn(300))),
+		}
+		s, err := series.NewData(row)
+		if err != nil {
+			return nil, err
+		}
+
+		c := curve{
+			total:        float64(population) * (0.002 + r.Float64()*0.03),
+			rate:         0.1 + r.Float64()*0.2,
+			midpoint:     float64(o.Days) * (0.3 + r.Float64()*0.4),
+			fatality:     0.01 + r.Float64()*0.07,
+			deathLag:     7 + r.Intn(8),
+			recoveryLag:  14 + r.Intn(8),
+			testsPerCase: 5 + r.Float64()*25,
+		}
+		err = c.generate(s, o, r)
+		if err != nil {
+			return nil, err
+		}
+		slice = append(slice, s)
+	}
+
+	err = slice.CalculateGlobalSeriesData()
+	if err != nil {
+		return nil, err
+	}
+	return slice, nil
+}
+
+// generate sets the days of series s from this curve
+func (c curve) generate(s *series.Data, o Options, r *rand.Rand) error {
+	s.AddDays(o.Days)
+	start := s.Days[0].Date
+
+	// Expected new cases each day from the logistic curve, deaths and recoveries lag cases
+	cases := make([]float64, o.Days)
+	deaths := make([]float64, o.Days)
+	recovered := make([]float64, o.Days)
+	tested := make([]float64, o.Days)
+	previous := c.total / (1 + math.Exp(c.rate*c.midpoint))
+	for i := range cases {
+		cumulative := c.total / (1 + math.Exp(-c.rate*(float64(i)-c.midpoint)))
+		cases[i] = cumulative - previous
+		previous = cumulative
+		tested[i] = cases[i] * c.testsPerCase
+		if i >= c.deathLag {
+			deaths[i] = cases[i-c.deathLag] * c.fatality
+		}
+		if i >= c.recoveryLag {
+			recovered[i] = cases[i-c.recoveryLag] * (1 - c.fatality)
+		}
+	}
+
+	for _, v := range []struct {
+		kind  int
+		daily []float64
+	}{
+		{series.DataConfirmed, cases},
+		{series.DataDeaths, deaths},
+		{series.DataRecovered, recovered},
+		{series.DataTested, tested},
+	} {
+		err := s.SetData(start, v.kind, reported(v.daily, s, o, r))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reported returns cumulative totals of the expected daily counts given, as they would be reported
+// counts vary randomly, and some of those at weekends are not reported until Monday
+func reported(daily []float64, s *series.Data, o Options, r *rand.Rand) []int {
+	values := make([]int, len(daily))
+	total, delayed := 0, 0.0
+	for i, expected := range daily {
+		count := expected * (1 + r.NormFloat64()*o.Noise)
+		if count < 0 {
+			count = 0
+		}
+
+		switch s.Days[i].Date.Weekday() {
+		case time.Saturday, time.Sunday:
+			delay := count * o.WeekendEffect
+			delayed += delay
+			count -= delay
+		case time.Monday:
+			count += delayed
+			delayed = 0
+		}
+
+		total += int(math.Round(count))
+		values[i] = total
+	}
+	return values
+}
+
+// WriteData writes the areas and series files for the series in slice to the data directory dir
+// the server loads these with a data path of dir
+func WriteData(dir string, slice series.Slice) error {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+
+	sorted := make(series.Slice, len(slice))
+	copy(sorted, slice)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].ID < sorted[j].ID
+	})
+
+	err = writeFile(filepath.Join(dir, "areas.csv"), func(w *bufio.Writer) {
+		fmt.Fprintf(w, "country,province,area_id,latitude,longitude,population,lockdown,colour,area_km2\n")
+		for _, s := range sorted {
+			fmt.Fprintf(w, "%s,%s,%d,%g,%g,%d,,%s,%g\n", s.Country, s.Province, s.ID, s.Latitude, s.Longitude, s.Population, s.Color, s.AreaKm2)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	// Rows are written day by day with zero days left out, as in the series file saved by the server
+	return writeFile(filepath.Join(dir, "series.csv"), func(w *bufio.Writer) {
+		fmt.Fprintf(w, "day,area_id,deaths,confirmed,recovered,tested\n")
+		days := 0
+		for _, s := range sorted {
+			if len(s.Days) > days {
+				days = len(s.Days)
+			}
+		}
+		for i := 0; i < days; i++ {
+			for _, s := range sorted {
+				if i >= len(s.Days) || s.Days[i].IsZero() {
+					continue
+				}
+				d := s.Days[i]
+				fmt.Fprintf(w, "%d,%d,%d,%d,%d,%d\n", i+1, s.ID, d.Value(series.DataDeaths), d.Value(series.DataConfirmed), d.Value(series.DataRecovered), d.Value(series.DataTested))
+			}
+		}
+	})
+}
+
+// writeFile creates the file at p and writes it with fn
+func writeFile(p string, fn func(w *bufio.Writer)) error {
+	f, err := os.Create(p)
+	if err != nil {
+		return fmt.Errorf("seriesgen: failed to create file:%s", err)
+	}
+
+	w := bufio.NewWriter(f)
+	fn(w)
+	err = w.Flush()
+	if err == nil {
+		err = f.Close()
+	} else {
+		f.Close()
+	}
+	if err != nil {
+		return fmt.Errorf("seriesgen: failed to write file:%s error:%s", p, err)
+	}
+	return nil
+}
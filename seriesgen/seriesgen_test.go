@@ -0,0 +1,120 @@
+package seriesgen
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kennygrant/coronavirus/series"
+)
+
+// TestGenerate tests generated series are consistent, repeatable and have fewer counts at weekends
+func TestGenerate(t *testing.T) {
+	options := Options{Areas: 25, Days: 140, Seed: 7}
+	slice, err := Generate(options)
+	if err != nil {
+		t.Fatalf("seriesgen: failed to generate:%s", err)
+	}
+	if len(slice) != 26 {
+		t.Fatalf("seriesgen: wrong area count got:%d", len(slice))
+	}
+
+	global, err := slice.FindSeries(GlobalID)
+	if err != nil {
+		t.Fatalf("seriesgen: no global series:%s", err)
+	}
+	discrepancies, err := slice.CheckGlobal()
+	if err != nil || len(discrepancies) > 0 {
+		t.Errorf("seriesgen: global series is not the sum of areas err:%v got:%v", err, discrepancies)
+	}
+
+	weekend, weekday := 0, 0
+	for _, s := range slice {
+		if len(s.Days) != options.Days {
+			t.Fatalf("seriesgen: wrong days for:%s got:%d", s, len(s.Days))
+		}
+		for _, kind := range series.DataKinds() {
+			values := s.Values(kind)
+			for i := 1; i < len(values); i++ {
+				if values[i] < values[i-1] {
+					t.Fatalf("seriesgen: cumulative %s fell for:%s on day:%d", series.DataKindName(kind), s, i)
+				}
+			}
+		}
+		if s.TotalDeaths() >= s.TotalConfirmed() || s.TotalConfirmed() == 0 {
+			t.Errorf("seriesgen: implausible totals for:%s deaths:%d confirmed:%d", s, s.TotalDeaths(), s.TotalConfirmed())
+		}
+		if s == global {
+			continue
+		}
+		for i, v := range s.ConfirmedDaily() {
+			switch s.Days[i].Date.Weekday() {
+			case time.Saturday, time.Sunday:
+				weekend += v
+			case time.Tuesday, time.Wednesday:
+				weekday += v
+			}
+		}
+	}
+	if weekend >= weekday {
+		t.Errorf("seriesgen: no weekend effect weekend:%d weekday:%d", weekend, weekday)
+	}
+
+	// The same options generate the same series
+	again, err := Generate(options)
+	if err != nil {
+		t.Fatalf("seriesgen: failed to generate:%s", err)
+	}
+	for i, s := range slice {
+		if again[i].Title() != s.Title() || again[i].TotalConfirmed() != s.TotalConfirmed() {
+			t.Errorf("seriesgen: series differ for the same seed got:%s want:%s", again[i], s)
+		}
+	}
+}
+
+// TestWriteData tests generated data files are written in the format loaded by the server
+func TestWriteData(t *testing.T) {
+	dir, err := ioutil.TempDir("", "seriesgen")
+	if err != nil {
+		t.Fatalf("seriesgen: failed to create dir:%s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	slice, err := Generate(Options{Areas: 3, Days: 60, Seed: 1})
+	if err != nil {
+		t.Fatalf("seriesgen: failed to generate:%s", err)
+	}
+	err = WriteData(dir, slice)
+	if err != nil {
+		t.Fatalf("seriesgen: failed to write:%s", err)
+	}
+	for _, name := range []string{"areas.csv", "series.csv"} {
+		info, err := os.Stat(filepath.Join(dir, name))
+		if err != nil || info.Size() == 0 {
+			t.Errorf("seriesgen: missing file:%s err:%v", name, err)
+		}
+	}
+
+	err = series.LoadData(dir)
+	if err != nil {
+		t.Fatalf("seriesgen: failed to load data:%s", err)
+	}
+	for _, s := range slice {
+		loaded, err := series.FindSeries(s.ID)
+		if err != nil || loaded.Title() != s.Title() || loaded.Days[59].Value(series.DataConfirmed) != s.Days[59].Value(series.DataConfirmed) {
+			t.Errorf("seriesgen: wrong series loaded for:%s err:%v", s, err)
+		}
+	}
+}
+
+// BenchmarkGenerate benchmarks generating a year of data for 200 areas
+func BenchmarkGenerate(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		_, err := Generate(Options{Areas: 200, Days: 365, Seed: int64(i)})
+		if err != nil {
+			b.Fatalf("seriesgen: failed to generate:%s", err)
+		}
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/kennygrant/coronavirus/series"
@@ -23,10 +24,17 @@ func main() {
 // processData reads the source data files and outputs data in our preferred format
 func processData() error {
 
-	// First load the areas data - this sets up a series per area
+	// Load aliases first so that the names used by sources match our areas
+	aliasPath := filepath.Join("..", "data", "aliases.csv")
+	err := series.LoadAliases(aliasPath)
+	if err != nil {
+		return fmt.Errorf("data: error loading aliases:%s data:%s", aliasPath, err)
+	}
+
+	// Then load the areas data - this sets up a series per area
 	// this is loaded from the live data director
 	areaPath := filepath.Join("..", "data", "areas.csv")
-	err := series.LoadAreas(areaPath)
+	err = series.LoadAreas(areaPath)
 	if err != nil {
 		return fmt.Errorf("data: error loading areas:%s data:%s", areaPath, err)
 	}
@@ -41,24 +49,16 @@ func processData() error {
 	}
 
 	// Now load our series files in the data dir
+	var seriesFiles []string
 	for _, p := range files {
-		name := filepath.Base(p)
-		if strings.HasPrefix(name, "time_series") {
-
-			if strings.HasSuffix(name, "_US.csv") {
-				err = loadUSJHUSeries(p)
-				if err != nil {
-					return err
-				}
-			} else {
-				err = loadJHUSeries(p)
-				if err != nil {
-					return err
-				}
-			}
-
+		if strings.HasPrefix(filepath.Base(p), "time_series") {
+			seriesFiles = append(seriesFiles, p)
 		}
 	}
+	err = loadSeriesFiles(seriesFiles)
+	if err != nil {
+		return err
+	}
 
 	// Now we've loaded all our files we're in theory ready to write out the historical series file which the app will use.
 	// One thing we must do though is fill in global series not in the original dataset which is inconsistent in this regard
@@ -102,13 +102,13 @@ func writeHistoricSeries() error {
 			if !d.IsZero() {
 				// Day number is days since 2020-01-22 start of dataset
 				dayNumber := i + 1
-				seriesData = append(seriesData, []int{dayNumber, s.ID, d.Deaths, d.Confirmed, d.Recovered, d.Tested})
+				seriesData = append(seriesData, []int{dayNumber, s.ID, d.Value(series.DataDeaths), d.Value(series.DataConfirmed), d.Value(series.DataRecovered), d.Value(series.DataTested)})
 
 				if dayNumber == 73 {
-					dayData = append(dayData, []int{dayNumber, s.ID, d.Deaths, d.Confirmed, d.Recovered, d.Tested})
+					dayData = append(dayData, []int{dayNumber, s.ID, d.Value(series.DataDeaths), d.Value(series.DataConfirmed), d.Value(series.DataRecovered), d.Value(series.DataTested)})
 				}
 				// Write a string for log
-				//log.Printf("%d,%d,%d,%d,%d,%d", dayNo, s.ID, d.Deaths, d.Confirmed, d.Recovered, d.Tested)
+				//log.Printf("%d,%d,%d,%d,%d,%d", dayNo, s.ID, d.Value(series.DataDeaths), d.Value(series.DataConfirmed), d.Value(series.DataRecovered), d.Value(series.DataTested))
 			}
 
 		}
@@ -179,10 +179,56 @@ const (
 // seriesStartDate is our default start date
 var seriesStartDate = time.Date(2020, 1, 22, 0, 0, 0, 0, time.UTC)
 
-// LoadJHUSeries loads a series file for a given datum
+// loadWorkers is the number of series files read and parsed at once
+const loadWorkers = 4
+
+// loadSeriesFiles reads the series files at paths concurrently with a pool of workers
+// parsing is done in parallel, the data is then merged into the dataset one file at a time under a lock
+// merges for different files do not overlap or are summed, so the order files finish in does not matter
+// the first error encountered is returned
+func loadSeriesFiles(paths []string) error {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var loadErr error
+
+	work := make(chan string)
+	for i := 0; i < loadWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for p := range work {
+				// Open the CSV file - one row per country or US county
+				rows, err := loadCSV(p)
+
+				mu.Lock()
+				if err == nil {
+					if strings.HasSuffix(filepath.Base(p), "_US.csv") {
+						err = loadUSJHUSeries(p, rows)
+					} else {
+						err = loadJHUSeries(p, rows)
+					}
+				}
+				if err != nil && loadErr == nil {
+					loadErr = err
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, p := range paths {
+		work <- p
+	}
+	close(work)
+	wg.Wait()
+
+	return loadErr
+}
+
+// LoadJHUSeries loads the rows of a series file for a given datum
 // the file name is used to determine which datum to fill in
 // This reliased on the areas.csv file being loaded first
-func loadJHUSeries(p string) error {
+func loadJHUSeries(p string, rows [][]string) error {
 	// Decide on the datum based on file name
 	dataType := dataTypeForPath(p)
 
@@ -190,12 +236,6 @@ func loadJHUSeries(p string) error {
 		return fmt.Errorf("load: invalid data type for file:%s", p)
 	}
 
-	// Open the CSV file - one row per country
-	rows, err := loadCSV(p)
-	if err != nil {
-		return err
-	}
-
 	// Make an assumption about the starting date for our data - checked below by checking header
 	startDate := seriesStartDate
 
@@ -223,14 +263,9 @@ func loadJHUSeries(p string) error {
 		country := row[1]
 		province := row[0]
 
-		// Transform countries
+		// Transform countries and provinces to the names used in our data
+		country = series.CountryName(country)
 		switch country {
-		case "Burma":
-			country = "Myanmar"
-		case "Taiwan*":
-			country = "Taiwan"
-		case "Korea, South":
-			country = "South Korea"
 		case "United Kingdom":
 			if province == "British Virgin Islands" {
 				province = "Virgin Islands"
@@ -294,12 +329,12 @@ func logSeriesNotFound(country, province string) {
 // State level data format varies
 // UID,iso2,iso3,code3,FIPS,Admin2,Province_State,Country_Region,Lat,Long_,Combined_Key,1/22/20,
 
-// LoadUSJHUSeries loads a series file for use county/state level data
+// LoadUSJHUSeries loads the rows of a series file for use county/state level data
 // this includes unattributed data, and we only care about US states, not counties
 // it doesn't include state level data, so we must sum all counties below states + unattributed
 // the file name is used to determine which datum to fill in
 // This reliased on the areas.csv file being loaded first
-func loadUSJHUSeries(p string) error {
+func loadUSJHUSeries(p string, rows [][]string) error {
 	// Decide on the datum based on file name
 	dataType := dataTypeForPath(p)
 
@@ -307,12 +342,6 @@ func loadUSJHUSeries(p string) error {
 		return fmt.Errorf("load: invalid data type for file:%s", p)
 	}
 
-	// Open the CSV file - one row per country
-	rows, err := loadCSV(p)
-	if err != nil {
-		return err
-	}
-
 	// Make an assumption about the starting date for our data - checked below by checking header
 	startDate := seriesStartDate
 
@@ -338,7 +367,7 @@ func loadUSJHUSeries(p string) error {
 		}
 
 		// Read data row for country
-		country := row[7]
+		country := series.CountryName(row[7])
 		province := row[6]
 
 		series, err := series.FetchSeries(country, province)
@@ -398,6 +427,7 @@ func loadCSV(p string) ([][]string, error) {
 	if err != nil {
 		return nil, err
 	}
+	defer f.Close()
 	r := csv.NewReader(f)
 	rows, err := r.ReadAll()
 	if err != nil {
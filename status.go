@@ -0,0 +1,188 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/kennygrant/coronavirus/series"
+)
+
+// maxStatusValidations is the number of series failing validation listed in the status
+const maxStatusValidations = 10
+
+// maxStatusGaps is the number of gaps in the data listed in the status, the most recent first
+const maxStatusGaps = 50
+
+// defaultFrozenDays is the number of days a cumulative value may be unchanged before it is listed as frozen
+const defaultFrozenDays = 7
+
+// SourceHealth records the results of fetching from one source
+type SourceHealth struct {
+	Name        string    `json:"name"`
+	LastSuccess time.Time `json:"last_success"`
+	LastFailure time.Time `json:"last_failure"`
+	LastError   string    `json:"last_error"`
+
+	// Failures is the number of failures since the last success
+	Failures int `json:"failures"`
+}
+
+// RefreshStatus records the results of refreshes from sources
+type RefreshStatus struct {
+	Running     bool      `json:"running"`
+	LastAttempt time.Time `json:"last_attempt"`
+	LastSuccess time.Time `json:"last_success"`
+	LastError   string    `json:"last_error"`
+	Schedule    string    `json:"schedule"`
+}
+
+// refreshStatus and sourceHealth record refreshes since startup, use statusMutex to access
+var (
+	refreshStatus RefreshStatus
+	sourceHealth  = make(map[string]*SourceHealth)
+	statusMutex   sync.Mutex
+)
+
+// recordSource records the result of fetching from the source name, err is nil on success
+func recordSource(name string, err error) {
+	statusMutex.Lock()
+	defer statusMutex.Unlock()
+
+	h := sourceHealth[name]
+	if h == nil {
+		h = &SourceHealth{Name: name}
+		sourceHealth[name] = h
+	}
+	if err != nil {
+		h.LastFailure = time.Now().UTC()
+		h.LastError = err.Error()
+		h.Failures++
+		return
+	}
+	h.LastSuccess = time.Now().UTC()
+	h.Failures = 0
+}
+
+// recordRefresh records the result of one attempt to refresh, err is nil on success
+func recordRefresh(err error) {
+	statusMutex.Lock()
+	defer statusMutex.Unlock()
+
+	refreshStatus.LastAttempt = time.Now().UTC()
+	if err != nil {
+		refreshStatus.LastError = err.Error()
+		return
+	}
+	refreshStatus.LastSuccess = refreshStatus.LastAttempt
+	refreshStatus.LastError = ""
+}
+
+// Status is the status of the server served to operators
+type Status struct {
+	StartedAt   time.Time           `json:"started_at"`
+	Development bool                `json:"development"`
+	Refresh     RefreshStatus       `json:"refresh"`
+	Sources     []SourceHealth      `json:"sources"`
+	Series      series.Counts       `json:"series"`
+	Failed      int                 `json:"validation_failed"`
+	Validations []series.Validation `json:"validations"`
+	GapsFound   int                 `json:"gaps_found"`
+	Gaps        []series.Gap        `json:"gaps"`
+}
+
+// currentStatus returns the status of refreshes and the data, with the first series failing validation
+// and the most recent gaps, values unchanged for frozenDays are listed as frozen
+func currentStatus(frozenDays int) Status {
+	s := Status{
+		StartedAt:   startedAt,
+		Development: development,
+		Sources:     []SourceHealth{},
+		Series:      series.CountSeries(),
+	}
+
+	statusMutex.Lock()
+	s.Refresh = refreshStatus
+	for _, h := range sourceHealth {
+		s.Sources = append(s.Sources, *h)
+	}
+	statusMutex.Unlock()
+
+	updatingMutex.Lock()
+	s.Refresh.Running = updating
+	updatingMutex.Unlock()
+
+	s.Refresh.Schedule = cfg.Refresh.Schedule
+	if s.Refresh.Schedule == "" && cfg.Refresh.Enabled && !development {
+		s.Refresh.Schedule = "every " + cfg.Refresh.Interval.String()
+	}
+
+	sort.Slice(s.Sources, func(i, j int) bool {
+		return s.Sources[i].Name < s.Sources[j].Name
+	})
+
+	s.Validations = series.Validate()
+	s.Failed = len(s.Validations)
+	if len(s.Validations) > maxStatusValidations {
+		s.Validations = s.Validations[:maxStatusValidations]
+	}
+
+	s.Gaps = series.Gaps(frozenDays)
+	s.GapsFound = len(s.Gaps)
+	if len(s.Gaps) > maxStatusGaps {
+		s.Gaps = s.Gaps[:maxStatusGaps]
+	}
+	return s
+}
+
+// handleStatus serves the status of refreshes, sources and the data as json
+// params: frozen=n for the days a value may be unchanged before it is listed as a gap
+func handleStatus(w http.ResponseWriter, r *http.Request) {
+
+	log.Printf("request:%s", r.URL)
+
+	if !adminAuthorized(r) {
+		http.Error(w, "not authorized", http.StatusUnauthorized)
+		return
+	}
+
+	frozenDays := intParam(r, "frozen")
+	if frozenDays <= 0 {
+		frozenDays = defaultFrozenDays
+	}
+
+	renderJSON(w, r, currentStatus(frozenDays))
+}
+
+// handleRefresh reloads data from the data files, then refreshes today's data from sources
+// in the background, it must be POSTed, the status is served after reloading
+// params: fetch=false to reload the data files only
+func handleRefresh(w http.ResponseWriter, r *http.Request) {
+
+	log.Printf("request:%s", r.URL)
+
+	if !adminAuthorized(r) {
+		http.Error(w, "not authorized", http.StatusUnauthorized)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	err := series.LoadData(cfg.Data.Path)
+	if err != nil {
+		log.Printf("refresh reload error:%s", err)
+		http.Error(w, err.Error(), 500)
+		return
+	}
+
+	if param(r, "fetch") != "false" {
+		go updateFrequent()
+	}
+
+	renderJSON(w, r, currentStatus(defaultFrozenDays))
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// languageZones maps Accept-Language tags to a likely time zone for the viewer
+// more specific tags with a region are tried before the language alone
+var languageZones = map[string]string{
+	"en-us": "America/New_York",
+	"en-ca": "America/Toronto",
+	"en-gb": "Europe/London",
+	"en-ie": "Europe/Dublin",
+	"en-au": "Australia/Sydney",
+	"en-nz": "Pacific/Auckland",
+	"en-in": "Asia/Kolkata",
+	"es-mx": "America/Mexico_City",
+	"es-ar": "America/Argentina/Buenos_Aires",
+	"pt-br": "America/Sao_Paulo",
+	"fr-ca": "America/Toronto",
+	"de":    "Europe/Berlin",
+	"fr":    "Europe/Paris",
+	"it":    "Europe/Rome",
+	"es":    "Europe/Madrid",
+	"pt":    "Europe/Lisbon",
+	"nl":    "Europe/Amsterdam",
+	"sv":    "Europe/Stockholm",
+	"pl":    "Europe/Warsaw",
+	"ru":    "Europe/Moscow",
+	"tr":    "Europe/Istanbul",
+	"ja":    "Asia/Tokyo",
+	"ko":    "Asia/Seoul",
+	"zh":    "Asia/Shanghai",
+	"hi":    "Asia/Kolkata",
+}
+
+// requestLocation returns the time zone of the viewer for the request
+// the tz param (e.g. tz=Europe/London) is used first, then a guess from Accept-Language
+// UTC is used if neither gives a known zone
+func requestLocation(r *http.Request) *time.Location {
+	if tz := param(r, "tz"); tz != "" {
+		loc, err := time.LoadLocation(tz)
+		if err == nil {
+			return loc
+		}
+	}
+
+	lang := acceptLanguage(r)
+	for _, tag := range []string{lang, strings.SplitN(lang, "-", 2)[0]} {
+		if zone, ok := languageZones[tag]; ok {
+			loc, err := time.LoadLocation(zone)
+			if err == nil {
+				return loc
+			}
+		}
+	}
+
+	return time.UTC
+}
+
+// acceptLanguage returns the first language tag in the Accept-Language header in lower case
+// quality values are ignored
+func acceptLanguage(r *http.Request) string {
+	lang := strings.ToLower(strings.TrimSpace(r.Header.Get("Accept-Language")))
+	lang = strings.SplitN(lang, ",", 2)[0]
+	return strings.TrimSpace(strings.SplitN(lang, ";", 2)[0])
+}
@@ -4,13 +4,60 @@ import (
 	"encoding/csv"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"log"
+	"math/rand"
 	"net/http"
+	"path/filepath"
+	"sync"
 	"time"
 
+	"github.com/kennygrant/coronavirus/cron"
+	"github.com/kennygrant/coronavirus/notify"
 	"github.com/kennygrant/coronavirus/series"
+	"github.com/kennygrant/coronavirus/seriesgen"
 )
 
+// Paths for the series file, snapshots of it, the changelog, rank snapshots, overrides, audit log and parquet export, see setDataPaths
+var (
+	seriesPath    = "data/series.csv"
+	snapshotsPath = "data/snapshots"
+	changesPath   = "data/changes.json"
+	ranksPath     = "data/ranks.json"
+	overridesPath = "data/overrides.csv"
+	auditPath     = "data/audit.jsonl"
+	parquetPath   = "data/series.parquet"
+)
+
+// setDataPaths sets the paths of files written by updates within the data directory given
+func setDataPaths(dataPath string) {
+	seriesPath = filepath.Join(dataPath, "series.csv")
+	snapshotsPath = filepath.Join(dataPath, "snapshots")
+	changesPath = filepath.Join(dataPath, "changes.json")
+	ranksPath = filepath.Join(dataPath, "ranks.json")
+	overridesPath = filepath.Join(dataPath, "overrides.csv")
+	auditPath = filepath.Join(dataPath, "audit.jsonl")
+	parquetPath = filepath.Join(dataPath, "series.parquet")
+}
+
+// writeDemoData writes synthetic data files up to yesterday to a temporary directory and returns its path
+// updates are written to this directory too, so the data files are never changed in demo mode
+func writeDemoData() (string, error) {
+	dir, err := ioutil.TempDir("", "covid-demo")
+	if err != nil {
+		return "", err
+	}
+	slice, err := seriesgen.Generate(seriesgen.Options{
+		Areas: 20,
+		Days:  int(time.Now().UTC().Sub(cfg.Data.StartDate).Hours() / 24),
+		Seed:  time.Now().UnixNano(),
+	})
+	if err != nil {
+		return "", err
+	}
+	return dir, seriesgen.WriteData(dir, slice)
+}
+
 // ScheduleUpdates schedules data updates from our data sources
 // after each update data series is resaved and a data reload triggered
 // the changes are also committed to the git repository
@@ -20,14 +67,20 @@ func ScheduleUpdates() {
 	// Call update frequent immediately on load to start loading data for todaay
 	go updateFrequent()
 
-	// Schedule calls daily and every 10 mins to update data
-	now := time.Now()
+	// Schedule calls daily and on the configured schedule or interval to update data
+	now := time.Now().UTC()
 
-	when := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 5, 0, time.UTC)
-	regularly := 15 * time.Minute // every 15 minutes
-	ScheduleAt(updateFrequent, when, regularly)
+	if cfg.Refresh.Schedule != "" {
+		// The schedule is checked when config is loaded
+		schedule, _ := cron.Parse(cfg.Refresh.Schedule)
+		ScheduleCron(updateFrequent, schedule)
+	} else {
+		when := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 5, 0, time.UTC)
+		ScheduleAt(updateFrequent, when, cfg.Refresh.Interval)
+	}
 
-	when = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 1, 0, time.UTC)
+	// The daily time is checked when config is loaded
+	when, _ := cfg.Refresh.DailyTime(now)
 	daily := time.Hour * 24 // daily
 	ScheduleAt(updateDaily, when, daily)
 
@@ -38,24 +91,95 @@ func ScheduleUpdates() {
 func updateDaily() {
 	log.Printf("update: updating daily at:%s", time.Now().UTC())
 
-	// Update the series to add today
+	before := series.TakeSnapshot()
+
+	// Update the series to add today, pending provinces are given the day when loaded
 	err := series.AddToday()
 	if err != nil {
 		log.Printf("update: failed to add today to series:%s", err)
 		return
 	}
 
+	recordChanges(nil, before)
+
+	// Downsample the day which has passed out of the retention to weekly resolution
+	err = series.Downsample()
+	if err != nil {
+		log.Printf("update: failed to downsample series:%s", err)
+	}
+
+	// Move the day which has passed out of the days kept in memory to the archive
+	err = series.Archive()
+	if err != nil {
+		log.Printf("update: failed to archive days:%s", err)
+	}
+
+	// Write the nightly parquet export of the complete dataset
+	err = writeParquet()
+	if err != nil {
+		log.Printf("update: failed to write parquet:%s", err)
+	}
 }
 
 // I think for manual updates just edit files and hit the reload endpont
 
-// updateFrequent updates data frequently (every 30 minutes say)
+// updating is true while updateFrequent is running, use updatingMutex to access
+var updating bool
+
+var updatingMutex sync.Mutex
+
+// updateFrequent updates data frequently (every 15 minutes say)
 // Called in a goroutine
+// failed updates are retried after a delay with jitter, doubling for each retry
+// if the previous update is still running, for example waiting to retry, this update is skipped
+func updateFrequent() {
+	updatingMutex.Lock()
+	running := updating
+	updating = true
+	updatingMutex.Unlock()
+	if running {
+		log.Printf("update: skipped update, previous update still running")
+		return
+	}
+	defer func() {
+		updatingMutex.Lock()
+		updating = false
+		updatingMutex.Unlock()
+	}()
+
+	delay := cfg.Refresh.RetryDelay
+	for retry := 0; ; retry++ {
+		err := refresh()
+		recordRefresh(err)
+		if err == nil {
+			return
+		}
+		if retry >= cfg.Refresh.Retries {
+			log.Printf("update: update failed after %d retries:%s", retry, err)
+			return
+		}
+		wait := jitter(delay)
+		log.Printf("update: update failed, retrying in %s:%s", wait, err)
+		time.Sleep(wait)
+		delay *= 2
+	}
+}
+
+// jitter returns a random duration within half of d either side of d
+// so that retries from several servers don't reach sources at the same time
+func jitter(d time.Duration) time.Duration {
+	if d <= 1 {
+		return d
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d)))
+}
+
+// refresh fetches today's data from sources, rebuilds and validates the series, then swaps them in
 // the dataset is somewhat inconsistent and therefore requires some massaging
 // for example not all countries have global data
 // NB after non-essential failures we just continue rather than log an error
 // some datasources may be down for example, but some not
-func updateFrequent() {
+func refresh() error {
 
 	// Pull the repo first with git to be sure we're up to date
 	err := gitPull()
@@ -63,32 +187,89 @@ func updateFrequent() {
 		log.Printf("update: failed to pull repo:%s", err)
 	}
 
-	// This data source is not reliable - find another source for UK regions
-	/*
-		err = updateUKCases()
+	// Keep a copy of the data before update to record changes
+	before := series.TakeSnapshot()
+	var sources []string
+
+	// Updates are applied to a copy of the data, which is swapped in once complete
+	// so that requests never see data partially updated
+	err = series.Update("refresh", func(next series.Slice) error {
+		// This data source is not reliable - find another source for UK regions
+		/*
+			err := updateUKCases(next)
+			if err != nil {
+				log.Printf("update: UK FAILED:%s", err)
+			}
+		*/
+		err := updateJHUCases(next)
+		recordSource("jhu", err)
 		if err != nil {
-			log.Printf("update: UK FAILED:%s", err)
+			log.Printf("update: JHU FAILED:%s", err)
+		} else {
+			sources = append(sources, "jhu")
+		}
+
+		// Mark yesterday complete if the daily report has been published
+		err = updateJHUComplete(next)
+		recordSource("jhu_daily_report", err)
+		if err != nil {
+			log.Printf("update: JHU complete check FAILED:%s", err)
+		}
+
+		// Without any source there is nothing to update
+		if len(sources) == 0 {
+			return fmt.Errorf("no sources updated")
 		}
-	*/
-	err = updateJHUCases()
+
+		// Corrections made by hand replace values from sources
+		series.ApplyOverrides(next)
+
+		// Now update our global series which are unfortunteley not contained in this data
+		err = next.CalculateGlobalSeriesData()
+		if err != nil {
+			return fmt.Errorf("failed to calculate global series:%s", err)
+		}
+
+		// Reject updates which add problems to the data, the current data is kept
+		failed, previous := len(next.Validate()), len(series.Validate())
+		if failed > previous {
+			return fmt.Errorf("%d series failed validation, %d before update", failed, previous)
+		}
+		return nil
+	})
 	if err != nil {
-		log.Printf("update: JHU FAILED:%s", err)
+		return err
 	}
 
-	// Now update our global series which are unfortunteley not contained in this data
-	err = series.CalculateGlobalSeriesData()
-	if err != nil {
-		log.Printf("update: failed to calculate global series :%s", err)
-		return
+	// Summarise values on which sources disagree
+	discrepancies := series.SourceDiscrepancies(discrepancyPercent(""))
+	if len(discrepancies) > 0 {
+		log.Printf("update: %d source discrepancies, largest:%s", len(discrepancies), discrepancies[0])
 	}
 
 	// Now save the series file to disk
-	err = series.Save("data/series.csv")
+	err = series.Save(seriesPath)
 	if err != nil {
-		log.Printf("server: failed to save series data:%s", err)
-		return
+		return fmt.Errorf("failed to save series data:%s", err)
+	}
+
+	// Cache the dataset with the saved files, so that the next start need not parse them
+	err = series.SaveCache()
+	if err != nil {
+		log.Printf("update: failed to save cache:%s", err)
 	}
 
+	// Keep a versioned copy of the series file so that we can roll back a bad update
+	snapshot, err := series.SaveSnapshot(snapshotsPath, seriesPath)
+	if err != nil {
+		log.Printf("update: failed to save snapshot:%s", err)
+	} else {
+		log.Printf("update: snapshot version:%d hash:%s", snapshot.Version, snapshot.Hash)
+	}
+
+	// Record the changes made by this update in the changelog
+	recordChanges(sources, before)
+
 	// Finally attempt to commit the change to the report with a suitable commit message
 	message := fmt.Sprintf("Updated from external data for %s", time.Now().UTC().Format("2006-01-02"))
 	err = gitCommit(message)
@@ -96,20 +277,82 @@ func updateFrequent() {
 		log.Printf("server: failed to commit change:%s", err)
 	}
 
+	return nil
 }
 
-// Update UK stats linked from gov.uk
-// https://www.gov.uk/guidance/coronavirus-covid-19-information-for-the-public#number-of-cases-and-deaths
-func updateUKCases() error {
+// recordChanges records the changes since the snapshot before in the changelog and saves it
+func recordChanges(sources []string, before *series.Snapshot) {
+	change := series.RecordChanges(sources, before)
+	log.Printf("update: recorded change version:%d areas:%d", change.Version, len(change.Areas))
+
+	// Push the new totals of changed areas to live clients
+	publishLive(change)
+
+	// Notify any transports routed for the kinds of change made
+	notifyChanges(change)
+
+	err := series.SaveChanges(changesPath)
+	if err != nil {
+		log.Printf("update: failed to save changes:%s", err)
+	}
+
+	// Record the ranks of countries on the new last day
+	recordRanks()
+
+	// Send the digest of the day just completed after the first refresh of each day
+	if !change.PreviousMaxDate.IsZero() && change.MaxDate.After(change.PreviousMaxDate) {
+		sendDigest(change.PreviousMaxDate)
+	}
+}
+
+// notifyChanges alerts the transports routed for each kind of change made by a refresh
+// most refreshes only update today's values, which are not alerted, so transports are not sent an alert every refresh
+func notifyChanges(change *series.Change) {
+	var messages []notify.Message
+	if !change.PreviousMaxDate.IsZero() && change.MaxDate.After(change.PreviousMaxDate) {
+		messages = append(messages, notify.Message{
+			Rule:  ruleNewDay,
+			Title: fmt.Sprintf("Data updated to %s", change.MaxDate.Format("2006-01-02")),
+			Body:  fmt.Sprintf("%d areas changed in version %d", len(change.Areas), change.Version),
+		})
+	}
+	if len(change.Revisions) > 0 {
+		r := change.Revisions[0]
+		messages = append(messages, notify.Message{
+			Rule:  ruleRevisions,
+			Title: fmt.Sprintf("Earlier days revised in version %d", change.Version),
+			Body:  fmt.Sprintf("largest revision area:%d %s on %s from %d to %d", r.AreaID, r.Kind, r.Date.Format("2006-01-02"), r.Before, r.After),
+		})
+	}
+	for _, m := range messages {
+		err := notify.Send(m)
+		if err != nil {
+			log.Printf("update: failed to notify:%s", err)
+		}
+	}
+}
+
+// recordRanks records the ranks of countries on the last day and saves the rank snapshots
+func recordRanks() {
+	added := series.RecordRanks()
+	log.Printf("update: recorded ranks snapshots added:%d", added)
+
+	err := series.SaveRanks(ranksPath)
+	if err != nil {
+		log.Printf("update: failed to save ranks:%s", err)
+	}
+}
 
-	filePath := "https://services1.arcgis.com/0IrmI40n5ZYxTUrV/arcgis/rest/services/DailyIndicators/FeatureServer/0/query?where=TotalUKCases%3E0&objectIds=&time=&resultType=standard&outFields=*&returnIdsOnly=false&returnUniqueIdsOnly=false&returnCountOnly=false&returnDistinctValues=false&cacheHint=false&orderByFields=&groupByFieldsForStatistics=&outStatistics=&having=&resultOffset=&resultRecordCount=&sqlFormat=none&f=pgeojson&token="
+// Update UK stats linked from gov.uk in the series given
+// https://www.gov.uk/guidance/coronavirus-covid-19-information-for-the-public#number-of-cases-and-deaths
+func updateUKCases(next series.Slice) error {
 
-	jsonData, err := downloadJSON(filePath)
+	jsonData, err := downloadJSON(cfg.Sources.UKGov)
 	if err != nil {
 		return fmt.Errorf("server: failed to download UK json:%s", err)
 	}
 
-	err = series.UpdateFromUKStats(jsonData)
+	err = next.UpdateFromUKStats(jsonData)
 	if err != nil {
 		return fmt.Errorf("server: failed to parse UK json:%s", err)
 	}
@@ -117,39 +360,50 @@ func updateUKCases() error {
 	return nil
 }
 
-func updateJHUCases() error {
+// updateJHUCases updates today's data in the series given from the JHU country and state files
+func updateJHUCases(next series.Slice) error {
 
-	// Download the country cases file into csv rows
-	filePath := "https://raw.githubusercontent.com/CSSEGISandData/COVID-19/web-data/data/cases_country.csv"
-	rows, err := downloadCSV(filePath)
+	// Download the country cases file and the cases_states file for sub-country state data at once
+	files, err := downloadCSVFiles(cfg.Sources.JHUCountry, cfg.Sources.JHUStates)
 	if err != nil {
 		return fmt.Errorf("server: failed to download JHU csv:%s", err)
 	}
 
 	// This data has a specific format, ask the series to decode
 	// and update the changed series in memory
-	err = series.UpdateFromJHUCountryCases(rows)
+	err = next.UpdateFromJHUCountryCases(files[0])
 	if err != nil {
 		return fmt.Errorf("server: failed to update from JHU data :%s", err)
 	}
 
-	// Update from the cases_states file for sub-country state data
-
-	// Download the US states cases file into csv rows
-	filePath = "https://raw.githubusercontent.com/CSSEGISandData/COVID-19/web-data/data/cases_state.csv"
-	rows, err = downloadCSV(filePath)
+	err = next.UpdateFromJHUStatesCases(files[1])
 	if err != nil {
-		return fmt.Errorf("server: failed to download JHU states csv:%s", err)
+		return fmt.Errorf("server: failed to update from JHU data :%s", err)
 	}
+	return nil
 
-	// This data has a specific format, ask the series to decode
-	// and update the changed series in memory
-	err = series.UpdateFromJHUStatesCases(rows)
+}
+
+// updateJHUComplete marks yesterday as complete in the series given once JHU publish the daily report file for it
+// until then the latest days are provisional and may be revised
+func updateJHUComplete(next series.Slice) error {
+	yesterday := time.Now().UTC().AddDate(0, 0, -1)
+	yesterday = time.Date(yesterday.Year(), yesterday.Month(), yesterday.Day(), 0, 0, 0, 0, time.UTC)
+
+	filePath := fmt.Sprintf(cfg.Sources.JHUDailyReport, yesterday.Format("01-02-2006"))
+	resp, err := http.Head(filePath)
 	if err != nil {
-		return fmt.Errorf("server: failed to update from JHU data :%s", err)
+		return fmt.Errorf("server: failed to check JHU daily report:%s", err)
 	}
-	return nil
+	resp.Body.Close()
 
+	// The report is not published yet, leave the day provisional
+	if resp.StatusCode != http.StatusOK {
+		return nil
+	}
+
+	next.CompleteDays(yesterday)
+	return nil
 }
 
 // downloadJSON downloads and parses the url as generic json
@@ -182,6 +436,30 @@ func downloadCSV(url string) ([][]string, error) {
 	return rows, nil
 }
 
+// downloadCSVFiles downloads and parses each url as csv concurrently
+// rows are returned in the order of urls, the first error is returned if any download fails
+func downloadCSVFiles(urls ...string) ([][][]string, error) {
+	files := make([][][]string, len(urls))
+	errs := make([]error, len(urls))
+
+	var wg sync.WaitGroup
+	for i, url := range urls {
+		wg.Add(1)
+		go func(i int, url string) {
+			defer wg.Done()
+			files[i], errs[i] = downloadCSV(url)
+		}(i, url)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("%s:%s", urls[i], err)
+		}
+	}
+	return files, nil
+}
+
 // gitPull runs a git pull command
 func gitPull() error {
 
@@ -232,3 +510,30 @@ func ScheduleAt(f func(), t time.Time, i time.Duration) chan struct{} {
 
 	return task // call close(task) to stop executing the task for repeated tasks
 }
+
+// ScheduleCron schedules execution each time the cron schedule is due, in UTC
+// Callers should call close(task) before exiting the app or to stop repeating the action.
+func ScheduleCron(f func(), schedule *cron.Schedule) chan struct{} {
+	task := make(chan struct{})
+	go func() {
+		for {
+			now := time.Now().UTC()
+			next := schedule.Next(now)
+			if next.IsZero() {
+				log.Printf("update: schedule:%s is never due", schedule)
+				return
+			}
+
+			timer := time.NewTimer(next.Sub(now))
+			select {
+			case <-timer.C:
+				go f()
+			case <-task:
+				timer.Stop()
+				return
+			}
+		}
+	}()
+
+	return task // call close(task) to stop executing the task
+}
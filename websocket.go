@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// websocketGUID is appended to the key sent by clients to compute the accept header, see RFC 6455
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// maxWebSocketMessage limits the size of messages read from clients, which only send subscriptions
+const maxWebSocketMessage = 16 * 1024
+
+// WebSocket opcodes
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xa
+)
+
+// webSocket is a connection upgraded to the WebSocket protocol, messages are written as text frames
+// reads should be made from one goroutine, writes may be made from any
+type webSocket struct {
+	conn       net.Conn
+	reader     *bufio.Reader
+	writeMutex sync.Mutex
+}
+
+// upgradeWebSocket completes the opening handshake for a WebSocket request and returns the connection
+// if the request is not a valid WebSocket request an error is served and returned
+func upgradeWebSocket(w http.ResponseWriter, r *http.Request) (*webSocket, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if r.Method != http.MethodGet || !headerContains(r.Header, "Connection", "upgrade") || !headerContains(r.Header, "Upgrade", "websocket") || key == "" {
+		http.Error(w, "websocket upgrade required", http.StatusUpgradeRequired)
+		return nil, fmt.Errorf("websocket: invalid upgrade request")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "unsupported websocket version", http.StatusUpgradeRequired)
+		return nil, fmt.Errorf("websocket: unsupported version:%s", r.Header.Get("Sec-WebSocket-Version"))
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "websocket not supported", http.StatusInternalServerError)
+		return nil, fmt.Errorf("websocket: connection cannot be hijacked")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, fmt.Errorf("websocket: failed to hijack connection:%s", err)
+	}
+
+	// Clear deadlines set by the server for requests, the connection stays open for updates
+	conn.SetDeadline(time.Time{})
+
+	hash := sha1.Sum([]byte(key + websocketGUID))
+	accept := base64.StdEncoding.EncodeToString(hash[:])
+	_, err = fmt.Fprintf(conn, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n", accept)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("websocket: failed to write handshake:%s", err)
+	}
+
+	return &webSocket{conn: conn, reader: rw.Reader}, nil
+}
+
+// headerContains returns true if the comma separated header key contains token, ignoring case
+func headerContains(h http.Header, key, token string) bool {
+	for _, v := range h.Values(key) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// WriteText writes message in a text frame
+func (ws *webSocket) WriteText(message []byte) error {
+	return ws.writeFrame(opText, message)
+}
+
+// writeFrame writes a single unmasked frame, as sent by servers
+func (ws *webSocket) writeFrame(opcode byte, payload []byte) error {
+	header := []byte{0x80 | opcode}
+	switch {
+	case len(payload) < 126:
+		header = append(header, byte(len(payload)))
+	case len(payload) <= 0xffff:
+		header = append(header, 126)
+		header = binary.BigEndian.AppendUint16(header, uint16(len(payload)))
+	default:
+		header = append(header, 127)
+		header = binary.BigEndian.AppendUint64(header, uint64(len(payload)))
+	}
+
+	ws.writeMutex.Lock()
+	defer ws.writeMutex.Unlock()
+	ws.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	_, err := ws.conn.Write(append(header, payload...))
+	return err
+}
+
+// ReadMessage reads the next text or binary message, answering pings while waiting
+// io.EOF is returned once the client closes the connection
+func (ws *webSocket) ReadMessage() ([]byte, error) {
+	var message []byte
+	for {
+		fin, opcode, payload, err := ws.readFrame()
+		if err != nil {
+			return nil, err
+		}
+
+		switch opcode {
+		case opPing:
+			err = ws.writeFrame(opPong, payload)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		case opPong:
+			continue
+		case opClose:
+			ws.writeFrame(opClose, nil)
+			return nil, io.EOF
+		case opText, opBinary, opContinuation:
+			message = append(message, payload...)
+			if len(message) > maxWebSocketMessage {
+				return nil, fmt.Errorf("websocket: message too large")
+			}
+			if fin {
+				return message, nil
+			}
+		default:
+			return nil, fmt.Errorf("websocket: unknown opcode:%d", opcode)
+		}
+	}
+}
+
+// readFrame reads one frame, frames from clients must be masked
+func (ws *webSocket) readFrame() (fin bool, opcode byte, payload []byte, err error) {
+	header := make([]byte, 2)
+	_, err = io.ReadFull(ws.reader, header)
+	if err != nil {
+		return false, 0, nil, err
+	}
+	fin, opcode = header[0]&0x80 != 0, header[0]&0x0f
+	if header[1]&0x80 == 0 {
+		return false, 0, nil, fmt.Errorf("websocket: unmasked frame from client")
+	}
+
+	length := uint64(header[1] & 0x7f)
+	switch length {
+	case 126:
+		b := make([]byte, 2)
+		_, err = io.ReadFull(ws.reader, b)
+		length = uint64(binary.BigEndian.Uint16(b))
+	case 127:
+		b := make([]byte, 8)
+		_, err = io.ReadFull(ws.reader, b)
+		length = binary.BigEndian.Uint64(b)
+	}
+	if err != nil {
+		return false, 0, nil, err
+	}
+	if length > maxWebSocketMessage {
+		return false, 0, nil, fmt.Errorf("websocket: frame too large:%d", length)
+	}
+
+	mask := make([]byte, 4)
+	_, err = io.ReadFull(ws.reader, mask)
+	if err != nil {
+		return false, 0, nil, err
+	}
+	payload = make([]byte, length)
+	_, err = io.ReadFull(ws.reader, payload)
+	if err != nil {
+		return false, 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return fin, opcode, payload, nil
+}
+
+// Ping sends a ping to keep the connection open through proxies
+func (ws *webSocket) Ping() error {
+	return ws.writeFrame(opPing, nil)
+}
+
+// Close closes the connection without a closing handshake
+func (ws *webSocket) Close() error {
+	return ws.conn.Close()
+}
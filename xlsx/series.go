@@ -0,0 +1,48 @@
+package xlsx
+
+import (
+	"io"
+
+	"github.com/kennygrant/coronavirus/series"
+)
+
+// averageDays is the number of days in the moving averages of daily values
+const averageDays = 7
+
+// seriesHeadings are the headings of the columns in each sheet of series
+var seriesHeadings = []interface{}{
+	Bold("Date"), Bold("Deaths"), Bold("Confirmed"), Bold("Recovered"), Bold("Tested"),
+	Bold("Daily deaths"), Bold("Daily confirmed"), Bold("Daily recovered"), Bold("Daily tested"),
+	Bold("Deaths 7 day average"), Bold("Confirmed 7 day average"),
+	Bold("Deaths per 100k"), Bold("Confirmed per 100k"), Bold("Provisional"),
+}
+
+// WriteSeries writes a workbook to w with a sheet for each area, named by its title, listing every day
+// with cumulative values, daily values, 7 day averages of daily deaths and confirmed, and values per 100k people
+// per capita columns are empty for areas without a population
+func WriteSeries(w io.Writer, areas series.Slice) error {
+	wb := &Workbook{}
+	for _, s := range areas {
+		sheet := wb.AddSheet(s.Title())
+		sheet.AddRow(seriesHeadings...)
+
+		deaths, confirmed := s.DailyValues(series.DataDeaths), s.DailyValues(series.DataConfirmed)
+		recovered, tested := s.DailyValues(series.DataRecovered), s.DailyValues(series.DataTested)
+		deathsAverage, confirmedAverage := series.MovingAverage(deaths, averageDays), series.MovingAverage(confirmed, averageDays)
+		for i, day := range s.Days {
+			var deathsPer100k, confirmedPer100k, provisional interface{}
+			if s.Population > 0 {
+				deathsPer100k = s.Per100k(float64(day.Value(series.DataDeaths)))
+				confirmedPer100k = s.Per100k(float64(day.Value(series.DataConfirmed)))
+			}
+			if day.Provisional {
+				provisional = "yes"
+			}
+			sheet.AddRow(day.Date, day.Value(series.DataDeaths), day.Value(series.DataConfirmed), day.Value(series.DataRecovered), day.Value(series.DataTested),
+				deaths[i], confirmed[i], recovered[i], tested[i],
+				deathsAverage[i], confirmedAverage[i],
+				deathsPer100k, confirmedPer100k, provisional)
+		}
+	}
+	return wb.Write(w)
+}
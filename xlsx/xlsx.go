@@ -0,0 +1,244 @@
+// Package xlsx writes Excel workbooks, with a sheet of days for each area exported
+// workbooks are written directly as the zipped SpreadsheetML parts Excel reads, so no library is required
+// only numbers, text and dates are supported, with a bold style for headings
+package xlsx
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// maxSheetName is the longest sheet name Excel accepts
+const maxSheetName = 31
+
+// Styles of cells, indexes into the cellXfs of styles.xml
+const (
+	styleDefault = iota
+	styleDate
+	styleDecimal
+	styleBold
+)
+
+// excelEpoch is day zero of Excel dates, which are numbers of days since then
+var excelEpoch = time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+
+// Workbook is an Excel workbook of sheets
+type Workbook struct {
+	sheets []*Sheet
+}
+
+// Sheet is one sheet of a workbook, with rows of cells
+type Sheet struct {
+	Name string
+	rows [][]interface{}
+}
+
+// Bold is a cell value shown in bold, used for headings
+type Bold string
+
+// AddSheet adds a sheet to the workbook with the name given, which is shortened and made unique if required
+// names may not contain the characters []:*?/\ which are replaced by spaces, blank names are replaced by Sheet
+func (wb *Workbook) AddSheet(name string) *Sheet {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return ' '
+		}
+		return r
+	}, name)
+	if strings.TrimSpace(name) == "" {
+		name = "Sheet"
+	}
+
+	unique := truncate(name, maxSheetName)
+	for i := 2; wb.hasSheet(unique); i++ {
+		suffix := fmt.Sprintf(" %d", i)
+		unique = truncate(name, maxSheetName-len(suffix)) + suffix
+	}
+
+	s := &Sheet{Name: unique}
+	wb.sheets = append(wb.sheets, s)
+	return s
+}
+
+// hasSheet returns true if a sheet has this name, which are compared ignoring case as in Excel
+func (wb *Workbook) hasSheet(name string) bool {
+	for _, s := range wb.sheets {
+		if strings.EqualFold(s.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// truncate returns s shortened to at most n characters
+func truncate(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n])
+	}
+	return s
+}
+
+// AddRow adds a row of cells to the sheet, values may be int, float64, string, Bold, time.Time or nil for an empty cell
+func (s *Sheet) AddRow(values ...interface{}) {
+	s.rows = append(s.rows, values)
+}
+
+// Write writes the workbook to w as an xlsx file
+func (wb *Workbook) Write(w io.Writer) error {
+	z := zip.NewWriter(w)
+
+	files := []struct {
+		name string
+		data string
+	}{
+		{"[Content_Types].xml", wb.contentTypes()},
+		{"_rels/.rels", rootRels},
+		{"xl/workbook.xml", wb.workbook()},
+		{"xl/_rels/workbook.xml.rels", wb.workbookRels()},
+		{"xl/styles.xml", styles},
+	}
+	for _, f := range files {
+		err := writeFile(z, f.name, f.data)
+		if err != nil {
+			return err
+		}
+	}
+
+	for i, s := range wb.sheets {
+		data, err := s.xml()
+		if err != nil {
+			return err
+		}
+		err = writeFile(z, fmt.Sprintf("xl/worksheets/sheet%d.xml", i+1), data)
+		if err != nil {
+			return err
+		}
+	}
+
+	return z.Close()
+}
+
+// writeFile adds a file to the zip archive
+func writeFile(z *zip.Writer, name, data string) error {
+	f, err := z.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(f, data)
+	return err
+}
+
+// xmlHeader starts every file in the workbook
+const xmlHeader = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n"
+
+// rootRels relates the package to the workbook
+const rootRels = xmlHeader + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+	`</Relationships>`
+
+// styles defines the cell styles: default, date, decimal to two places and bold
+const styles = xmlHeader + `<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+	`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>` +
+	`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+	`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+	`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+	`<cellXfs count="4">` +
+	`<xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+	`<xf numFmtId="14" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+	`<xf numFmtId="2" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+	`<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/>` +
+	`</cellXfs></styleSheet>`
+
+// contentTypes returns the content types of the files in the workbook
+func (wb *Workbook) contentTypes() string {
+	var b strings.Builder
+	b.WriteString(xmlHeader + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">`)
+	b.WriteString(`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>`)
+	b.WriteString(`<Default Extension="xml" ContentType="application/xml"/>`)
+	b.WriteString(`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>`)
+	b.WriteString(`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>`)
+	for i := range wb.sheets {
+		fmt.Fprintf(&b, `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, i+1)
+	}
+	b.WriteString(`</Types>`)
+	return b.String()
+}
+
+// workbook returns the workbook listing the sheets
+func (wb *Workbook) workbook() string {
+	var b strings.Builder
+	b.WriteString(xmlHeader + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>`)
+	for i, s := range wb.sheets {
+		fmt.Fprintf(&b, `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, escape(s.Name), i+1, i+1)
+	}
+	b.WriteString(`</sheets></workbook>`)
+	return b.String()
+}
+
+// workbookRels relates the workbook to its sheets and styles, the styles follow the sheets
+func (wb *Workbook) workbookRels() string {
+	var b strings.Builder
+	b.WriteString(xmlHeader + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`)
+	for i := range wb.sheets {
+		fmt.Fprintf(&b, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>`, i+1, i+1)
+	}
+	fmt.Fprintf(&b, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>`, len(wb.sheets)+1)
+	b.WriteString(`</Relationships>`)
+	return b.String()
+}
+
+// xml returns the sheet data, text is written inline rather than in a shared strings table
+func (s *Sheet) xml() (string, error) {
+	var b strings.Builder
+	b.WriteString(xmlHeader + `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">`)
+	if len(s.rows) > 0 {
+		// Freeze the heading row so it stays in view
+		b.WriteString(`<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews>`)
+	}
+	b.WriteString(`<sheetData>`)
+	for i, row := range s.rows {
+		fmt.Fprintf(&b, `<row r="%d">`, i+1)
+		for j, v := range row {
+			ref := cellRef(j, i)
+			switch v := v.(type) {
+			case nil:
+				continue
+			case int:
+				fmt.Fprintf(&b, `<c r="%s"><v>%d</v></c>`, ref, v)
+			case float64:
+				fmt.Fprintf(&b, `<c r="%s" s="%d"><v>%g</v></c>`, ref, styleDecimal, v)
+			case string:
+				fmt.Fprintf(&b, `<c r="%s" t="inlineStr"><is><t>%s</t></is></c>`, ref, escape(v))
+			case Bold:
+				fmt.Fprintf(&b, `<c r="%s" s="%d" t="inlineStr"><is><t>%s</t></is></c>`, ref, styleBold, escape(string(v)))
+			case time.Time:
+				fmt.Fprintf(&b, `<c r="%s" s="%d"><v>%d</v></c>`, ref, styleDate, int(v.Sub(excelEpoch).Hours()/24))
+			default:
+				return "", fmt.Errorf("xlsx: unsupported cell type:%T in sheet:%s", v, s.Name)
+			}
+		}
+		b.WriteString(`</row>`)
+	}
+	b.WriteString(`</sheetData></worksheet>`)
+	return b.String(), nil
+}
+
+// cellRef returns the reference of the cell in column col and row from zero, like A1 or AB12
+func cellRef(col, row int) string {
+	name := ""
+	for col++; col > 0; col = (col - 1) / 26 {
+		name = string(rune('A'+(col-1)%26)) + name
+	}
+	return fmt.Sprintf("%s%d", name, row+1)
+}
+
+// escape escapes text for xml
+func escape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
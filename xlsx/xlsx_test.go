@@ -0,0 +1,86 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kennygrant/coronavirus/series"
+)
+
+func TestAddSheet(t *testing.T) {
+	wb := &Workbook{}
+	names := []string{"Italy", "italy", "US/New York", "", "Saint Vincent and the Grenadines", "Saint Vincent and the Grenadines"}
+	want := []string{"Italy", "italy 2", "US New York", "Sheet", "Saint Vincent and the Grenadine", "Saint Vincent and the Grenadi 2"}
+	for i, name := range names {
+		s := wb.AddSheet(name)
+		if s.Name != want[i] {
+			t.Errorf("xlsx: wrong sheet name want:%q got:%q", want[i], s.Name)
+		}
+	}
+}
+
+func TestWriteSeries(t *testing.T) {
+	start := time.Date(2020, 1, 22, 0, 0, 0, 0, time.UTC)
+	italy := &series.Data{Country: "Italy", Population: 1000000}
+	italy.SetData(start, series.DataDeaths, []int{10, 20, 40, 70, 110, 160, 220, 290})
+	italy.SetData(start, series.DataConfirmed, []int{100, 200, 400, 700, 1100, 1600, 2200, 2900})
+	unknown := &series.Data{Country: "Unknown <&>"}
+	unknown.SetData(start, series.DataDeaths, []int{1, 2})
+
+	var buf bytes.Buffer
+	err := WriteSeries(&buf, series.Slice{italy, unknown})
+	if err != nil {
+		t.Fatalf("xlsx: failed to write:%s", err)
+	}
+
+	z, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("xlsx: failed to read zip:%s", err)
+	}
+	files := make(map[string]string)
+	for _, f := range z.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatalf("xlsx: failed to open:%s", f.Name)
+		}
+		data, err := ioutil.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatalf("xlsx: failed to read:%s", f.Name)
+		}
+		files[f.Name] = string(data)
+	}
+
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/styles.xml"} {
+		if files[name] == "" {
+			t.Errorf("xlsx: missing part:%s", name)
+		}
+	}
+	if !strings.Contains(files["xl/workbook.xml"], `<sheet name="Italy" sheetId="1" r:id="rId1"/><sheet name="Unknown &lt;&amp;&gt;" sheetId="2" r:id="rId2"/>`) {
+		t.Errorf("xlsx: wrong sheets got:%s", files["xl/workbook.xml"])
+	}
+
+	// The last day has the date as an Excel serial, daily values, averages and values per 100k
+	sheet := files["xl/worksheets/sheet1.xml"]
+	for _, cell := range []string{
+		`<c r="A1" s="3" t="inlineStr"><is><t>Date</t></is></c>`,
+		`<c r="A9" s="1"><v>43859</v></c>`,
+		`<c r="B9"><v>290</v></c>`,
+		`<c r="F9"><v>70</v></c>`,
+		`<c r="J9" s="2"><v>40</v></c>`,
+		`<c r="L9" s="2"><v>29</v></c>`,
+	} {
+		if !strings.Contains(sheet, cell) {
+			t.Errorf("xlsx: missing cell:%s", cell)
+		}
+	}
+
+	// Areas without a population have no values per 100k
+	if sheet = files["xl/worksheets/sheet2.xml"]; strings.Contains(sheet, `r="L2"`) || !strings.Contains(sheet, `<c r="B3"><v>2</v></c>`) {
+		t.Errorf("xlsx: wrong sheet without population got:%s", sheet)
+	}
+}